@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/auth"
+	"zhihu-downloader/internal/config"
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/health"
+	"zhihu-downloader/internal/jobqueue"
+	"zhihu-downloader/internal/mcptools"
+	"zhihu-downloader/internal/proc"
+	"zhihu-downloader/internal/serve"
+	"zhihu-downloader/internal/store"
+	"zhihu-downloader/internal/tasks"
+	"zhihu-downloader/internal/toolschema"
+	"zhihu-downloader/internal/transcriber"
+)
+
+var (
+	cfg     *config.Config
+	manager *tasks.Manager
+	vault   *auth.Vault
+	// tools 与 stdio MCP 服务共用的工具
+	tools *mcptools.Tools
+)
+
+func main() {
+	var err error
+	cfg, err = config.Load(config.AppMCP, os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		os.Exit(1)
+	}
+	cfg.Apply()
+	if cfg.Doctor {
+		if !health.PrintDoctor(os.Stdout, health.Doctor(context.Background(), cfg.DoctorOptions())) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 与网关、stdio MCP 服务共用数据库：任务、ID 序列和登录 cookies
+	db, err := store.Open(cfg.StoreOptions())
+	if err != nil {
+		slog.Error("数据库初始化失败", "error", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+	vault, err = auth.OpenVault(db, auth.KeyPath(cfg.Storage.DBPath))
+	if err != nil {
+		slog.Error("加载密钥失败", "error", err)
+		os.Exit(1)
+	}
+	// 下载任务附加的请求头和 cookies 用同一个密钥加密后保存
+	db.SetSecrets(vault)
+	downloader.SetCookieSource(func() []auth.Cookie {
+		cookies, err := vault.Load()
+		if err != nil {
+			slog.Warn("读取 cookies 失败", "error", err)
+		}
+		return cookies
+	})
+
+	// 使用 Redis 队列时，下载和转录任务由共用队列的所有进程按各自的并发上限领取执行
+	queue, err := jobqueue.Open(cfg.QueueOptions())
+	if err != nil {
+		slog.Error("连接任务队列失败", "error", err)
+		os.Exit(1)
+	}
+
+	manager = tasks.NewManager(append(cfg.ManagerOptions(),
+		tasks.WithIDGenerator(db.NextID),
+		tasks.WithPersister(db),
+		tasks.WithSharedStore(db),
+		tasks.WithJobQueue(queue),
+	)...)
+	tools = mcptools.New(mcptools.Options{Manager: manager, Vault: vault, Quality: cfg.Quality("hd")})
+	downloads, _ := db.Downloads()
+	transcribes, _ := db.Transcribes()
+	pipelines, _ := db.Pipelines()
+	collections, _ := db.Collections()
+	manager.Restore(downloads, transcribes, pipelines, collections)
+	if n := manager.MarkInterrupted(); n > 0 {
+		slog.Warn("部分任务在上次退出时被中断，可通过网关的 retry 接口继续", "count", n)
+	}
+	go manager.RunRetention(context.Background(), cfg.RetentionPolicy())
+	go manager.RunJanitor(context.Background(), cfg.JanitorOptions())
+	go downloader.KeepSessionAlive(context.Background(), cfg.Auth.CheckInterval)
+	go manager.RunSharedSync(context.Background())
+	go manager.RunJobQueue(context.Background())
+	go manager.IndexEmbeddings(context.Background())
+	proc.ExitOnSignal(func() { db.Close() })
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.Default()
+
+	// 不返回 CORS 头：MCP 客户端不在浏览器中运行，其他网站的页面不能跨域调用工具。
+	// 配置了工作区时按 API 密钥区分工作区
+	router.Use(requireAPIKey)
+
+	// ============ MCP 服务 API ============
+
+	// 列出可用的工具/功能
+	router.GET("/mcp/tools", func(c *gin.Context) {
+		c.JSON(200, gin.H{"tools": tools.List()})
+	})
+
+	// 调用工具
+	router.POST("/mcp/call_tool", func(c *gin.Context) {
+		var req struct {
+			Name  string                 `json:"name"`
+			Input map[string]interface{} `json:"input"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			fail(c, errcode.InvalidArgument, err)
+			return
+		}
+		legacyOutputPath(req.Name, req.Input)
+		schema, ok := tools.Schema(req.Name)
+		if !ok {
+			fail(c, errcode.NotFound, errcode.New(errcode.NotFound, "未知的工具"))
+			return
+		}
+		if err := toolschema.Validate(schema, req.Input); err != nil {
+			respondError(c, err.Info())
+			return
+		}
+
+		response, err := tools.Call(c.Request.Context(), req.Name, req.Input)
+		if err != nil {
+			fail(c, errcode.InvalidArgument, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"result": response})
+	})
+
+	// ============ 健康检查 ============
+	router.GET("/health", func(c *gin.Context) {
+		report := health.Run(c.Request.Context(), health.Options{
+			Database:  db.CheckWritable,
+			OutputDir: manager.OutputDir(),
+			MinFree:   int64(cfg.Quota.MinFreeMB) << 20,
+			Manager:   manager,
+		})
+		c.JSON(report.HTTPStatus(), gin.H{
+			"status":      report.Status,
+			"checks":      report.Checks,
+			"stuck_tasks": report.StuckTasks,
+			"login":       report.Login,
+			"service":     "zhihu-downloader-mcp",
+			"transcribe":  transcriber.CurrentStatus(),
+			"tools":       health.Tools(),
+		})
+	})
+
+	health.LogTools()
+	transcriber.LogStatus()
+	slog.Info("MCP 服务启动", "addr", serve.URL(cfg.Server.MCPListen, cfg.TLSOptions()),
+		"endpoints", "GET /mcp/tools, POST /mcp/call_tool, GET /health")
+
+	if len(cfg.Workspaces) > 0 {
+		if err := serve.CheckAPIKeyRequired(router, "POST", "/mcp/call_tool"); err != nil {
+			slog.Error("服务启动失败", "error", err)
+			os.Exit(1)
+		}
+	}
+	if err := serve.ListenAndServe(cfg.Server.MCPListen, serve.Guard(router, cfg.MaxBody()), cfg.TLSOptions()); err != nil {
+		slog.Error("服务启动失败", "error", err)
+		os.Exit(1)
+	}
+}
+
+// fail 返回 err 对应的错误响应，err 没有错误码且无法归类时使用 fallback
+func fail(c *gin.Context, fallback errcode.Code, err error) {
+	respondError(c, errcode.Describe(err, fallback))
+}
+
+// respondError 返回错误响应并结束请求，HTTP 状态码由错误码决定；error 同 message，兼容之前的版本
+func respondError(c *gin.Context, info errcode.Info) {
+	c.AbortWithStatusJSON(info.Code.HTTPStatus(), struct {
+		Error string `json:"error"`
+		errcode.Info
+	}{info.Message, info})
+}
+
+// legacyOutputPath 之前的版本用 output_path 指定输出目录，没有 output_dir 时改为 output_dir。
+// export_history 的 output_path 是导出的文件，不改
+func legacyOutputPath(name string, input map[string]interface{}) {
+	if name == "export_history" {
+		return
+	}
+	if v, ok := input["output_path"]; ok {
+		if _, ok := input["output_dir"]; !ok {
+			input["output_dir"] = v
+		}
+		delete(input, "output_path")
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/mcptools"
+	"zhihu-downloader/internal/serve"
+)
+
+// requireAPIKey 配置了工作区时检查 /mcp 请求的密钥（与网关相同），工作区通过 context 传给工具：
+// 创建的任务属于这个工作区，非管理员只能访问本工作区的任务和文件。没有配置工作区时不做任何检查
+func requireAPIKey(c *gin.Context) {
+	if len(cfg.Workspaces) == 0 || !strings.HasPrefix(c.Request.URL.Path, "/mcp/") {
+		c.Next()
+		return
+	}
+	ws := cfg.WorkspaceByKey(serve.APIKey(c.Request))
+	if ws == nil {
+		respondError(c, errcode.Info{Code: errcode.Unauthorized, Message: "缺少或无效的 API 密钥"})
+		return
+	}
+	c.Request = c.Request.WithContext(mcptools.WithWorkspace(c.Request.Context(), ws.Name, ws.Admin))
+	c.Next()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// inflight 正在执行的 tools/call 请求，收到 notifications/cancelled 时取消对应的 context。
+// HTTP 传输中不同会话的请求 ID 可能相同，key 包含会话 ID
+var (
+	inflightMu sync.Mutex
+	inflight   = map[string]context.CancelFunc{}
+	// calls 退出前等待仍在执行的请求返回结果
+	calls sync.WaitGroup
+)
+
+// requestKey 请求 ID 可以是数字或字符串，统一转为字符串作为 key
+func requestKey(session string, id interface{}) string {
+	return session + "/" + fmt.Sprint(id)
+}
+
+// startRequest 登记正在执行的请求，返回请求被取消时结束的 context
+func startRequest(req JSONRPCRequest) context.Context {
+	ctx, cancel := context.WithCancel(requestContext(context.Background(), req))
+	calls.Add(1)
+	inflightMu.Lock()
+	inflight[requestKey(req.session, req.ID)] = cancel
+	inflightMu.Unlock()
+	return ctx
+}
+
+// finishRequest 请求执行完毕，释放 context
+func finishRequest(req JSONRPCRequest) {
+	defer calls.Done()
+	inflightMu.Lock()
+	if cancel, ok := inflight[requestKey(req.session, req.ID)]; ok {
+		cancel()
+		delete(inflight, requestKey(req.session, req.ID))
+	}
+	inflightMu.Unlock()
+	if req.finished != nil {
+		req.finished()
+	}
+}
+
+// cancelSession 取消 HTTP 会话中所有仍在执行的请求，会话结束时调用
+func cancelSession(session string) {
+	inflightMu.Lock()
+	defer inflightMu.Unlock()
+	for key, cancel := range inflight {
+		if strings.HasPrefix(key, session+"/") {
+			cancel()
+		}
+	}
+}
+
+// handleCancelled 处理 notifications/cancelled：取消仍在执行的 tools/call 请求。
+// 已经开始的后台任务不受影响，需要用 cancel_task 取消
+func handleCancelled(req JSONRPCRequest) {
+	var params struct {
+		RequestID interface{} `json:"requestId"`
+		Reason    string      `json:"reason"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil || params.RequestID == nil {
+		return
+	}
+
+	inflightMu.Lock()
+	cancel, ok := inflight[requestKey(req.session, params.RequestID)]
+	inflightMu.Unlock()
+	if ok {
+		cancel()
+		slog.Info("客户端取消请求", "request_id", params.RequestID, "reason", params.Reason)
+	}
+}
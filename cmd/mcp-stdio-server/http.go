@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"zhihu-downloader/internal/config"
+	"zhihu-downloader/internal/health"
+	"zhihu-downloader/internal/serve"
+)
+
+// MCP Streamable HTTP 传输（协议版本 2025-03-26 起）：
+//
+//	POST   /mcp  发送一条 JSON-RPC 消息（或批量消息）。只有通知时返回 202；
+//	             包含请求时按 Accept 返回 application/json，或 text/event-stream 逐条推送响应
+//	GET    /mcp  服务端不主动推送消息，返回 405
+//	DELETE /mcp  结束会话，取消会话中仍在执行的工具调用
+//
+// initialize 的响应头 Mcp-Session-Id 为会话 ID，之后的请求都要带上
+const (
+	sessionHeader  = "Mcp-Session-Id"
+	versionHeader  = "Mcp-Protocol-Version"
+	maxMessageSize = 4 << 20
+	// sessionIdle 会话超过这么久没有请求时删除
+	sessionIdle = 24 * time.Hour
+	// sseKeepAlive 工具调用执行期间定期发送注释行，避免代理断开空闲连接
+	sseKeepAlive = 15 * time.Second
+)
+
+// httpSession HTTP 会话：最后一次请求的时间和创建会话的工作区
+type httpSession struct {
+	last      time.Time
+	workspace *config.WorkspaceConfig
+}
+
+var (
+	sessionsMu sync.Mutex
+	sessions   = map[string]*httpSession{}
+)
+
+// serveHTTP 以 Streamable HTTP 传输提供 MCP 服务（cfg 启用 TLS 时为 HTTPS），直到监听失败。
+// 配置了工作区时 /mcp 请求必须带上工作区的 API 密钥（与网关相同），/health 按 healthOpts 检查依赖
+func serveHTTP(addr string, cfg *config.Config, healthOpts health.Options) error {
+	tlsOpts := cfg.TLSOptions()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mcp", func(w http.ResponseWriter, r *http.Request) { handleMCP(w, r, cfg) })
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		report := health.Run(r.Context(), healthOpts)
+		writeJSON(w, report.HTTPStatus(), map[string]interface{}{
+			"status":      report.Status,
+			"checks":      report.Checks,
+			"stuck_tasks": report.StuckTasks,
+			"login":       report.Login,
+			"service":     "zhihu-downloader-mcp",
+			"transport":   "streamable-http",
+		})
+	})
+	handler := serve.Guard(mux, cfg.MaxBody())
+	// 不检查密钥时任何人都能访问所有工作区的任务，不启动
+	if len(cfg.Workspaces) > 0 {
+		if err := serve.CheckAPIKeyRequired(handler, http.MethodPost, "/mcp"); err != nil {
+			return err
+		}
+	}
+	slog.Info("MCP 服务启动", "transport", "streamable-http", "endpoint", serve.URL(addr, tlsOpts)+"/mcp")
+	return serve.ListenAndServe(addr, handler, tlsOpts)
+}
+
+func handleMCP(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
+	// 防止 DNS 重绑定：浏览器发起的请求只接受本机页面或与服务同域名的页面
+	if !allowedOrigin(r) {
+		writeHTTPError(w, http.StatusForbidden, "不允许的 Origin")
+		return
+	}
+	// 配置了工作区时检查密钥，非管理员只能访问本工作区的任务和文件
+	var ws *config.WorkspaceConfig
+	if len(cfg.Workspaces) > 0 {
+		if ws = cfg.WorkspaceByKey(serve.APIKey(r)); ws == nil {
+			writeHTTPError(w, http.StatusUnauthorized, "缺少或无效的 API 密钥")
+			return
+		}
+	}
+	if v := r.Header.Get(versionHeader); v != "" && negotiateVersion(v) != v {
+		writeHTTPError(w, http.StatusBadRequest, fmt.Sprintf("不支持的协议版本: %s", v))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		handleMCPPost(w, r, ws)
+	case http.MethodDelete:
+		id := r.Header.Get(sessionHeader)
+		if !endSession(id, ws) {
+			writeHTTPError(w, http.StatusNotFound, "会话不存在")
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		writeHTTPError(w, http.StatusMethodNotAllowed, "不支持服务端推送，请使用 POST")
+	}
+}
+
+func handleMCPPost(w http.ResponseWriter, r *http.Request, ws *config.WorkspaceConfig) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxMessageSize+1))
+	if err != nil {
+		writeHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(body) > maxMessageSize {
+		writeHTTPError(w, http.StatusRequestEntityTooLarge, "消息过大")
+		return
+	}
+
+	// 批量消息为数组
+	var messages []JSONRPCRequest
+	batch := bytes.HasPrefix(bytes.TrimSpace(body), []byte("["))
+	if batch {
+		err = json.Unmarshal(body, &messages)
+	} else {
+		var msg JSONRPCRequest
+		err = json.Unmarshal(body, &msg)
+		messages = []JSONRPCRequest{msg}
+	}
+	if err != nil || len(messages) == 0 {
+		writeJSON(w, http.StatusBadRequest, JSONRPCResponse{JSONRPC: "2.0", Error: &RPCError{Code: -32700, Message: "解析错误"}})
+		return
+	}
+
+	// initialize 创建会话，其他消息必须属于同一工作区已有的会话
+	session := r.Header.Get(sessionHeader)
+	if messages[0].Method == "initialize" {
+		if len(messages) > 1 {
+			writeHTTPError(w, http.StatusBadRequest, "initialize 不能与其他消息一起发送")
+			return
+		}
+		session = newSession(ws)
+		w.Header().Set(sessionHeader, session)
+	} else if session == "" {
+		writeHTTPError(w, http.StatusBadRequest, "缺少 "+sessionHeader+" 请求头，请先发送 initialize")
+		return
+	} else if !touchSession(session, ws) {
+		writeHTTPError(w, http.StatusNotFound, "会话不存在或已过期，请重新 initialize")
+		return
+	}
+
+	// 每个请求最多一条响应，通道足够大，处理消息时不会阻塞
+	out := make(chan interface{}, len(messages))
+	var pending sync.WaitGroup
+	requests := 0
+	for _, msg := range messages {
+		// 客户端对服务端请求的响应，服务端不发送请求，忽略
+		if msg.Method == "" {
+			continue
+		}
+		msg.session = session
+		msg.workspace = ws
+		msg.reply = func(m interface{}) { out <- m }
+		if msg.ID != nil {
+			requests++
+		}
+		if msg.Method == "tools/call" && msg.ID != nil {
+			pending.Add(1)
+			msg.finished = pending.Done
+		}
+		handleRequest(msg)
+	}
+	// 只有通知和响应
+	if requests == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	go func() {
+		pending.Wait()
+		close(out)
+	}()
+
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		streamResponses(w, r, out)
+		return
+	}
+	var responses []interface{}
+	for m := range out {
+		responses = append(responses, m)
+	}
+	switch {
+	case len(responses) == 0:
+		// 请求已被取消，不返回结果
+		w.WriteHeader(http.StatusAccepted)
+	case batch:
+		writeJSON(w, http.StatusOK, responses)
+	default:
+		writeJSON(w, http.StatusOK, responses[0])
+	}
+}
+
+// streamResponses 以 SSE 逐条推送响应，全部响应发送后结束。
+// 客户端断开连接时不取消工具调用，需要取消时发送 notifications/cancelled
+func streamResponses(w http.ResponseWriter, r *http.Request, out <-chan interface{}) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeHTTPError(w, http.StatusInternalServerError, "不支持流式响应")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case m, ok := <-out:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(m)
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+			flusher.Flush()
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func newSession(ws *config.WorkspaceConfig) string {
+	id := uuid.New().String()
+	now := time.Now()
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	for s, sess := range sessions {
+		if now.Sub(sess.last) > sessionIdle {
+			delete(sessions, s)
+		}
+	}
+	sessions[id] = &httpSession{last: now, workspace: ws}
+	return id
+}
+
+// touchSession 更新会话的最后请求时间，会话不存在、已过期或属于其他工作区时返回 false
+func touchSession(id string, ws *config.WorkspaceConfig) bool {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	sess, ok := sessions[id]
+	if ok && time.Since(sess.last) > sessionIdle {
+		delete(sessions, id)
+		return false
+	}
+	if !ok || sess.workspace != ws {
+		return false
+	}
+	sess.last = time.Now()
+	return true
+}
+
+// endSession 删除会话并取消其中仍在执行的工具调用，已创建的下载和转录任务不受影响。
+// 其他工作区的会话按不存在处理
+func endSession(id string, ws *config.WorkspaceConfig) bool {
+	sessionsMu.Lock()
+	sess, ok := sessions[id]
+	ok = ok && sess.workspace == ws
+	if ok {
+		delete(sessions, id)
+	}
+	sessionsMu.Unlock()
+	if ok {
+		cancelSession(id)
+	}
+	return ok
+}
+
+// allowedOrigin 没有 Origin 的请求（非浏览器客户端）、本机页面和同域名页面允许访问
+func allowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" || net.ParseIP(host).IsLoopback() {
+		return true
+	}
+	reqHost, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		reqHost = r.Host
+	}
+	return strings.EqualFold(host, reqHost)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeHTTPError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
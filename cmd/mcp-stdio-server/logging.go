@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+
+	"zhihu-downloader/internal/tasks"
+)
+
+// 通过 stdio 运行时，任务事件（创建、开始、进度达到 25/50/75%、重试、失败、完成）作为
+// notifications/message 发给客户端，客户端可以用 logging/setLevel 调整级别。HTTP 传输不主动推送消息
+
+// logLevels MCP 日志级别，按严重程度从低到高
+var logLevels = []string{"debug", "info", "notice", "warning", "error", "critical", "alert", "emergency"}
+
+// logLoggerName notifications/message 中的 logger
+const logLoggerName = "zhihu-downloader"
+
+var (
+	// logLevel 发送的最低级别在 logLevels 中的位置，默认 info
+	logLevel atomic.Int32
+	// logReady 客户端已发送 notifications/initialized，之后才发送日志
+	logReady atomic.Bool
+	// logMessages 等待发送的日志，写满时丢弃新的日志，不阻塞任务
+	logMessages = make(chan interface{}, 256)
+)
+
+func init() {
+	logLevel.Store(1)
+}
+
+func levelIndex(level string) int {
+	for i, l := range logLevels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+func handleSetLevel(req JSONRPCRequest) {
+	var params struct {
+		Level string `json:"level"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		sendError(req, -32602, "参数错误")
+		return
+	}
+	i := levelIndex(params.Level)
+	if i < 0 {
+		sendError(req, -32602, fmt.Sprintf("未知的日志级别: %s", params.Level))
+		return
+	}
+	logLevel.Store(int32(i))
+	sendResponse(req, map[string]interface{}{})
+}
+
+// startLogging 开始把任务事件发给 stdio 客户端
+func startLogging() {
+	logReady.Store(true)
+	go func() {
+		for msg := range logMessages {
+			writeMessage(JSONRPCRequest{}, msg)
+		}
+	}()
+}
+
+// logTaskEvent 任务事件的监听函数，在 Manager 持有锁时调用，只放入队列
+func logTaskEvent(e tasks.TaskEvent) {
+	if !logReady.Load() {
+		return
+	}
+	level, text := describeEvent(e)
+	if levelIndex(level) < int(logLevel.Load()) {
+		return
+	}
+	data := map[string]interface{}{
+		"message": text,
+		"task_id": e.TaskID,
+		"event":   e.Type,
+		"status":  e.Status,
+		"time":    e.Time,
+	}
+	if e.From != "" {
+		data["from"] = e.From
+	}
+	if e.Message != "" {
+		data["detail"] = e.Message
+	}
+	msg := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "notifications/message",
+		"params": map[string]interface{}{
+			"level":  level,
+			"logger": logLoggerName,
+			"data":   data,
+		},
+	}
+	select {
+	case logMessages <- msg:
+	default:
+	}
+}
+
+// describeEvent 返回事件的日志级别和说明
+func describeEvent(e tasks.TaskEvent) (level, text string) {
+	switch e.Type {
+	case tasks.EventCreated:
+		return "debug", fmt.Sprintf("任务 %s 已创建", e.TaskID)
+	case tasks.EventProgress:
+		return "info", fmt.Sprintf("任务 %s 进度 %s（%s）", e.TaskID, e.Message, e.Status)
+	case tasks.EventRetry:
+		return "warning", fmt.Sprintf("任务 %s %s", e.TaskID, e.Message)
+	case tasks.EventError:
+		return "warning", fmt.Sprintf("任务 %s 出错: %s", e.TaskID, e.Message)
+	case tasks.EventUpload:
+		return "info", fmt.Sprintf("任务 %s 上传: %s", e.TaskID, e.Message)
+	}
+	switch e.Status {
+	case tasks.StatusCompleted:
+		return "notice", fmt.Sprintf("任务 %s 已完成", e.TaskID)
+	case tasks.StatusFailed:
+		return "error", fmt.Sprintf("任务 %s 失败", e.TaskID)
+	case tasks.StatusCancelled:
+		return "warning", fmt.Sprintf("任务 %s 已取消", e.TaskID)
+	case tasks.StatusInterrupted:
+		return "warning", fmt.Sprintf("任务 %s 被中断", e.TaskID)
+	case tasks.StatusPending, tasks.StatusQueued, tasks.StatusPaused:
+		return "debug", fmt.Sprintf("任务 %s 状态 %s", e.TaskID, e.Status)
+	}
+	if e.From == tasks.StatusPending || e.From == tasks.StatusQueued || e.From == tasks.StatusPaused {
+		return "info", fmt.Sprintf("任务 %s 开始 %s", e.TaskID, e.Status)
+	}
+	return "info", fmt.Sprintf("任务 %s 进入 %s", e.TaskID, e.Status)
+}
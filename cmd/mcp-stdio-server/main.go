@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"zhihu-downloader/internal/auth"
+	"zhihu-downloader/internal/config"
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/health"
+	"zhihu-downloader/internal/jobqueue"
+	"zhihu-downloader/internal/mcptools"
+	"zhihu-downloader/internal/proc"
+	"zhihu-downloader/internal/store"
+	"zhihu-downloader/internal/tasks"
+	"zhihu-downloader/internal/toolschema"
+	"zhihu-downloader/internal/transcriber"
+)
+
+// MCP JSON-RPC 消息结构
+type JSONRPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      interface{}     `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+
+	// reply 发送响应，为空时写标准输出；HTTP 传输写入请求对应的响应
+	reply func(msg interface{})
+	// session HTTP 会话 ID，stdio 为空
+	session string
+	// finished tools/call 执行完毕（无论是否发送了响应）时调用
+	finished func()
+	// workspace HTTP 请求的 API 密钥对应的工作区，stdio 和没有配置工作区时为空
+	workspace *config.WorkspaceConfig
+}
+
+// requestContext 返回带有请求工作区的 context，工具和资源只能访问这个工作区的任务和文件
+func requestContext(ctx context.Context, req JSONRPCRequest) context.Context {
+	if req.workspace == nil {
+		return ctx
+	}
+	return mcptools.WithWorkspace(ctx, req.workspace.Name, req.workspace.Admin)
+}
+
+type JSONRPCResponse struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      interface{} `json:"id"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   *RPCError   `json:"error,omitempty"`
+}
+
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	// Data 工具调用失败时的错误码、说明和细节
+	Data *errcode.Info `json:"data,omitempty"`
+}
+
+var (
+	manager *tasks.Manager
+	vault   *auth.Vault
+	// tools 与 HTTP MCP 服务共用的工具
+	tools *mcptools.Tools
+)
+
+func main() {
+	cfg, err := config.Load(config.AppMCPStdio, os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		os.Exit(1)
+	}
+	cfg.Apply()
+	if cfg.Doctor {
+		if !health.PrintDoctor(os.Stdout, health.Doctor(context.Background(), cfg.DoctorOptions())) {
+			os.Exit(1)
+		}
+		return
+	}
+	health.LogTools()
+	transcriber.LogStatus()
+
+	// 初始化数据库
+	st, err := store.Open(cfg.StoreOptions())
+	if err != nil {
+		slog.Error("数据库初始化失败", "error", err)
+		os.Exit(1)
+	}
+	defer st.Close()
+
+	// 使用网关上传的 cookies（同一个数据库和密钥）
+	vault, err = auth.OpenVault(st, auth.KeyPath(cfg.Storage.DBPath))
+	if err != nil {
+		slog.Error("加载密钥失败", "error", err)
+		os.Exit(1)
+	}
+	// 下载任务附加的请求头和 cookies 用同一个密钥加密后保存
+	st.SetSecrets(vault)
+	downloader.SetCookieSource(func() []auth.Cookie {
+		cookies, err := vault.Load()
+		if err != nil {
+			slog.Warn("读取 cookies 失败", "error", err)
+		}
+		return cookies
+	})
+
+	// 使用 Redis 队列时，下载和转录任务由共用队列的所有进程按各自的并发上限领取执行
+	queue, err := jobqueue.Open(cfg.QueueOptions())
+	if err != nil {
+		slog.Error("连接任务队列失败", "error", err)
+		os.Exit(1)
+	}
+
+	// 与网关共用任务存储和 ID 序列，任务在两边都能查询和控制
+	manager = tasks.NewManager(append(cfg.ManagerOptions(),
+		tasks.WithIDGenerator(st.NextID),
+		tasks.WithPersister(st),
+		tasks.WithSharedStore(st),
+		tasks.WithJobQueue(queue),
+		tasks.WithEventListener(logTaskEvent),
+	)...)
+	tools = mcptools.New(mcptools.Options{Manager: manager, Vault: vault, Quality: cfg.Quality("fhd")})
+	downloads, _ := st.Downloads()
+	transcribes, _ := st.Transcribes()
+	pipelines, _ := st.Pipelines()
+	collections, _ := st.Collections()
+	manager.Restore(downloads, transcribes, pipelines, collections)
+	if n := manager.MarkInterrupted(); n > 0 {
+		slog.Warn("部分任务在上次退出时被中断，可使用 retry_task 继续", "count", n)
+	}
+	go manager.RunRetention(context.Background(), cfg.RetentionPolicy())
+	go manager.RunJanitor(context.Background(), cfg.JanitorOptions())
+	go downloader.KeepSessionAlive(context.Background(), cfg.Auth.CheckInterval)
+	go manager.RunSharedSync(context.Background())
+	go manager.RunJobQueue(context.Background())
+	go manager.IndexEmbeddings(context.Background())
+	proc.ExitOnSignal(func() { st.Close() })
+
+	if addr := cfg.Server.MCPHTTPListen; addr != "" {
+		healthOpts := health.Options{
+			Database:  st.CheckWritable,
+			OutputDir: manager.OutputDir(),
+			MinFree:   int64(cfg.Quota.MinFreeMB) << 20,
+			Manager:   manager,
+		}
+		if err := serveHTTP(addr, cfg, healthOpts); err != nil {
+			slog.Error("服务启动失败", "error", err)
+			st.Close()
+			proc.Shutdown()
+			os.Exit(1)
+		}
+		return
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		var request JSONRPCRequest
+		if err := json.Unmarshal([]byte(line), &request); err != nil {
+			sendError(JSONRPCRequest{}, -32700, "解析错误")
+			continue
+		}
+
+		handleRequest(request)
+	}
+	calls.Wait()
+
+	// 客户端断开后进程退出，先保存任务状态再终止仍在运行的下载和转录
+	st.Close()
+	proc.Shutdown()
+}
+
+func handleRequest(req JSONRPCRequest) {
+	switch req.Method {
+	case "initialize":
+		handleInitialize(req)
+	case "notifications/initialized":
+		if req.reply == nil {
+			startLogging()
+		}
+	case "tools/list":
+		handleToolsList(req)
+	case "tools/call":
+		// 工具调用在后台执行，执行期间仍可以处理取消通知和其他请求
+		ctx := startRequest(req)
+		go func() {
+			defer finishRequest(req)
+			handleToolsCall(ctx, req)
+		}()
+	case "notifications/cancelled":
+		handleCancelled(req)
+	case "resources/list":
+		handleResourcesList(req)
+	case "resources/templates/list":
+		handleResourceTemplatesList(req)
+	case "resources/read":
+		handleResourcesRead(req)
+	case "prompts/list":
+		handlePromptsList(req)
+	case "prompts/get":
+		handlePromptsGet(req)
+	case "logging/setLevel":
+		handleSetLevel(req)
+	case "ping":
+		sendResponse(req, map[string]interface{}{})
+	default:
+		if req.ID == nil {
+			return
+		}
+		sendError(req, -32601, "方法不存在")
+	}
+}
+
+// protocolVersions 支持的 MCP 协议版本，第一个为最新版本
+var protocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// negotiateVersion 客户端请求的版本受支持时使用该版本，否则返回最新版本，由客户端决定是否继续
+func negotiateVersion(requested string) string {
+	for _, v := range protocolVersions {
+		if v == requested {
+			return v
+		}
+	}
+	return protocolVersions[0]
+}
+
+func handleInitialize(req JSONRPCRequest) {
+	var params struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	json.Unmarshal(req.Params, &params)
+	capabilities := map[string]interface{}{
+		"tools":     map[string]bool{},
+		"resources": map[string]bool{},
+		"prompts":   map[string]bool{},
+	}
+	// 只有 stdio 会推送任务日志
+	if req.reply == nil {
+		capabilities["logging"] = map[string]bool{}
+	}
+	result := map[string]interface{}{
+		"protocolVersion": negotiateVersion(params.ProtocolVersion),
+		"capabilities":    capabilities,
+		"serverInfo": map[string]string{
+			"name":    "zhihu-downloader",
+			"version": "1.0.0",
+		},
+	}
+	sendResponse(req, result)
+}
+
+func handleToolsList(req JSONRPCRequest) {
+	sendResponse(req, map[string]interface{}{"tools": tools.List()})
+}
+
+func handleToolsCall(ctx context.Context, req JSONRPCRequest) {
+	var params struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	}
+
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		sendError(req, -32602, "参数无效")
+		return
+	}
+	schema, ok := tools.Schema(params.Name)
+	if !ok {
+		sendError(req, -32602, "未知工具")
+		return
+	}
+	if err := toolschema.Validate(schema, params.Arguments); err != nil {
+		sendInvalidParams(req, err)
+		return
+	}
+
+	result, err := tools.Call(ctx, params.Name, params.Arguments)
+
+	// 客户端已取消请求，不再返回结果
+	if ctx.Err() != nil {
+		slog.Info("请求已被客户端取消", "request_id", req.ID, "tool", params.Name)
+		return
+	}
+	if err != nil {
+		sendToolError(req, err)
+		return
+	}
+
+	// 结果同时作为文本（兼容不支持 structuredContent 的客户端）和结构化内容返回，结构见 outputSchema
+	sendResponse(req, map[string]interface{}{
+		"content": []map[string]interface{}{
+			{
+				"type": "text",
+				"text": formatResult(result),
+			},
+		},
+		"structuredContent": result,
+	})
+}
+
+func formatResult(result interface{}) string {
+	data, _ := json.MarshalIndent(result, "", "  ")
+	return string(data)
+}
+
+func sendResponse(req JSONRPCRequest, result interface{}) {
+	response := JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result:  result,
+	}
+	writeMessage(req, response)
+}
+
+func sendError(req JSONRPCRequest, code int, message string) {
+	if req.ID == nil {
+		return
+	}
+	response := JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Error: &RPCError{
+			Code:    code,
+			Message: message,
+		},
+	}
+	writeMessage(req, response)
+}
+
+// sendInvalidParams 返回参数不符合 inputSchema 的错误，data 中带上出错的参数
+func sendInvalidParams(req JSONRPCRequest, err *toolschema.FieldError) {
+	if req.ID == nil {
+		return
+	}
+	info := err.Info()
+	writeMessage(req, JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Error:   &RPCError{Code: -32602, Message: info.Message, Data: &info},
+	})
+}
+
+// sendToolError 返回工具调用失败的错误，data 中带上错误码
+func sendToolError(req JSONRPCRequest, err error) {
+	if req.ID == nil {
+		return
+	}
+	info := errcode.Describe(err, errcode.Internal)
+	writeMessage(req, JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Error:   &RPCError{Code: -32000, Message: info.Message, Data: &info},
+	})
+}
+
+// stdoutMu 工具调用在各自的 goroutine 中执行，写 stdout 时加锁，避免消息交错
+var stdoutMu sync.Mutex
+
+func writeMessage(req JSONRPCRequest, msg interface{}) {
+	if req.reply != nil {
+		req.reply(msg)
+		return
+	}
+	data, _ := json.Marshal(msg)
+	stdoutMu.Lock()
+	defer stdoutMu.Unlock()
+	fmt.Println(string(data))
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// prompt 预置的工作流提示词：把常用的多步操作（下载 → 转录 → 摘要等）写成带正确工具参数的步骤，
+// 客户端通过 prompts/get 获取后直接发给模型执行
+type prompt struct {
+	name        string
+	title       string
+	description string
+	arguments   []promptArgument
+	// render 根据参数生成提示词，必填参数已检查
+	render func(args map[string]string) string
+}
+
+type promptArgument struct {
+	name        string
+	description string
+	required    bool
+}
+
+// progressHint 轮询任务进度的说明
+const progressHint = "每隔 10–30 秒调用 get_progress（task_type 为 %s）查看进度，直到 status 为 completed、failed 或 cancelled；" +
+	"失败时说明 error 字段中的原因，可以用 retry_task 重试。"
+
+var prompts = []prompt{
+	{
+		name:        "archive_video",
+		title:       "存档视频并总结",
+		description: "下载知乎视频、转录为文本并生成摘要，最后给出摘要和所有文件路径",
+		arguments: []promptArgument{
+			{"url", "知乎视频、回答或文章链接", true},
+			{"language", "视频语言代码（默认自动识别）", false},
+			{"quality", "清晰度 best / uhd / fhd / hd / sd / ld（默认 fhd）", false},
+			{"output_dir", "保存目录（默认 ~/Downloads）", false},
+		},
+		render: func(args map[string]string) string {
+			return steps(
+				"请帮我存档这个知乎视频并总结内容。",
+				"调用 download_and_transcribe，参数为 "+toolArgs(map[string]interface{}{
+					"url": args["url"], "language": args["language"], "quality": args["quality"],
+					"output_dir": args["output_dir"], "summarize": true, "comments": true,
+				})+"，记下返回的 task_id。",
+				fmt.Sprintf(progressHint, "pipeline"),
+				"完成后读取资源 zhihu://tasks/<task_id>/summary 获取摘要；无法读取资源或摘要生成失败时，调用 summarize_transcript 并传入 task_id。",
+				"用中文回复：视频标题、摘要要点，以及视频（file_path）、转录文本（txt_path）、摘要（summary_path）的路径。",
+			)
+		},
+	},
+	{
+		name:        "transcribe_file",
+		title:       "转录本地视频",
+		description: "转录本地的视频或音频文件，带时间戳列出内容并生成摘要",
+		arguments: []promptArgument{
+			{"video_path", "本地视频或音频文件的绝对路径", true},
+			{"language", "语言代码（默认自动识别）", false},
+			{"diarize", "为 true 时区分说话人", false},
+		},
+		render: func(args map[string]string) string {
+			return steps(
+				"请转录这个本地文件并整理内容。",
+				"调用 transcribe_video，参数为 "+toolArgs(map[string]interface{}{
+					"video_path": args["video_path"], "language": args["language"],
+					"diarize": args["diarize"] == "true", "summarize": true,
+				})+"，记下返回的 task_id。",
+				fmt.Sprintf(progressHint, "transcribe"),
+				"完成后读取资源 zhihu://tasks/<task_id>/summary 和 zhihu://tasks/<task_id>/transcript；无法读取资源时调用 summarize_transcript 并传入 task_id。",
+				"用中文回复摘要，并按时间顺序列出主要段落的开始时间和内容。",
+			)
+		},
+	},
+	{
+		name:        "archive_collection",
+		title:       "存档合集",
+		description: "下载专栏、收藏夹、问题或用户主页中的所有视频，完成后汇总结果",
+		arguments: []promptArgument{
+			{"url", "专栏、收藏夹、问题或用户视频页链接", true},
+			{"limit", "最多下载的视频数（默认 200）", false},
+			{"quality", "清晰度（默认 fhd）", false},
+		},
+		render: func(args map[string]string) string {
+			input := map[string]interface{}{"url": args["url"], "quality": args["quality"]}
+			if n, err := strconv.Atoi(args["limit"]); err == nil && n > 0 {
+				input["limit"] = n
+			}
+			return steps(
+				"请帮我存档这个合集中的所有视频。",
+				"调用 download_collection，参数为 "+toolArgs(input)+"，记下返回的 task_id。",
+				fmt.Sprintf(progressHint, "collection")+"合集中的视频会排队下载，可能需要较长时间。",
+				"完成后用中文汇总：合集名称、成功和失败的视频数、保存目录；列出失败的视频及原因。",
+				"如果需要转录其中的视频，对每个已完成的 file_path 调用 transcribe_video。",
+			)
+		},
+	},
+	{
+		name:        "search_archive",
+		title:       "搜索已存档的视频",
+		description: "在所有已转录的视频中搜索关键词，按视频整理出处和时间点",
+		arguments: []promptArgument{
+			{"query", "搜索关键词，多个关键词用空格分隔", true},
+		},
+		render: func(args map[string]string) string {
+			return steps(
+				"请在我存档的视频中查找相关内容。",
+				"调用 search_transcripts，参数为 "+toolArgs(map[string]interface{}{"query": args["query"], "limit": 20})+"。",
+				"没有结果时换用同义词或更少的关键词再搜索一次。",
+				"用中文按视频列出匹配的段落和时间点（换算为 mm:ss），需要上下文时读取资源 zhihu://tasks/<task_id>/transcript。",
+			)
+		},
+	},
+}
+
+// steps 把说明和编号的步骤拼成提示词
+func steps(intro string, list ...string) string {
+	var b strings.Builder
+	b.WriteString(intro)
+	b.WriteString("\n\n")
+	for i, s := range list {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, s)
+	}
+	return b.String()
+}
+
+// toolArgs 把工具参数编码为 JSON，省略空字符串和 false
+func toolArgs(args map[string]interface{}) string {
+	input := map[string]interface{}{}
+	for k, v := range args {
+		if v == "" || v == false {
+			continue
+		}
+		input[k] = v
+	}
+	data, _ := json.Marshal(input)
+	return "`" + string(data) + "`"
+}
+
+func findPrompt(name string) (prompt, bool) {
+	for _, p := range prompts {
+		if p.name == name {
+			return p, true
+		}
+	}
+	return prompt{}, false
+}
+
+func handlePromptsList(req JSONRPCRequest) {
+	list := make([]map[string]interface{}, 0, len(prompts))
+	for _, p := range prompts {
+		args := make([]map[string]interface{}, 0, len(p.arguments))
+		for _, a := range p.arguments {
+			args = append(args, map[string]interface{}{
+				"name":        a.name,
+				"description": a.description,
+				"required":    a.required,
+			})
+		}
+		list = append(list, map[string]interface{}{
+			"name":        p.name,
+			"title":       p.title,
+			"description": p.description,
+			"arguments":   args,
+		})
+	}
+	sendResponse(req, map[string]interface{}{"prompts": list})
+}
+
+func handlePromptsGet(req JSONRPCRequest) {
+	var params struct {
+		Name      string            `json:"name"`
+		Arguments map[string]string `json:"arguments"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil || params.Name == "" {
+		sendError(req, -32602, "参数无效：name 必填")
+		return
+	}
+	p, ok := findPrompt(params.Name)
+	if !ok {
+		sendError(req, -32602, "提示词不存在: "+params.Name)
+		return
+	}
+	args := map[string]string{}
+	for _, a := range p.arguments {
+		v := strings.TrimSpace(params.Arguments[a.name])
+		if v == "" && a.required {
+			sendError(req, -32602, "参数无效："+a.name+" 必填")
+			return
+		}
+		args[a.name] = v
+	}
+
+	sendResponse(req, map[string]interface{}{
+		"description": p.description,
+		"messages": []map[string]interface{}{
+			{
+				"role": "user",
+				"content": map[string]interface{}{
+					"type": "text",
+					"text": p.render(args),
+				},
+			},
+		},
+	})
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"zhihu-downloader/internal/mcptools"
+	"zhihu-downloader/internal/tasks"
+)
+
+// 资源 URI：
+//
+//	zhihu://tasks                      最近的任务（JSON，与不带参数的 list_tasks 相同）
+//	zhihu://tasks/{id}                 任务详情（JSON）
+//	zhihu://tasks/{id}/transcript      转录文本，转录进行中时为已识别的部分
+//	zhihu://tasks/{id}/subtitles       SRT 字幕（区分说话人时生成）
+//	zhihu://tasks/{id}/segments        逐段识别结果（JSON，区分说话人时生成）
+//	zhihu://tasks/{id}/summary         摘要（Markdown）
+const resourcePrefix = "zhihu://tasks"
+
+// errResourceNotFound MCP 约定的资源不存在错误码
+const errResourceNotFound = -32002
+
+// taskFiles 转录输出文件对应的资源名和 MIME 类型
+var taskFiles = []struct {
+	name     string
+	mimeType string
+	title    string
+	path     func(t transcriptPaths) string
+}{
+	{"transcript", "text/plain", "转录文本", func(t transcriptPaths) string { return t.TXTPath }},
+	{"subtitles", "application/x-subrip", "字幕", func(t transcriptPaths) string { return t.SRTPath }},
+	{"segments", "application/json", "逐段识别结果", func(t transcriptPaths) string { return t.JSONPath }},
+	{"summary", "text/markdown", "摘要", func(t transcriptPaths) string { return t.SummaryPath }},
+}
+
+// transcriptPaths 转录和流水线任务共有的输出文件
+type transcriptPaths struct {
+	Status                                  tasks.Status
+	Name                                    string
+	TXTPath, SRTPath, JSONPath, SummaryPath string
+}
+
+func handleResourcesList(req JSONRPCRequest) {
+	resources := []map[string]interface{}{
+		{
+			"uri":         resourcePrefix,
+			"name":        "任务列表",
+			"description": "最近的下载、转录、流水线和合集任务",
+			"mimeType":    "application/json",
+		},
+	}
+
+	// 只列出调用方工作区的任务
+	ctx := requestContext(context.Background(), req)
+	for _, t := range manager.Downloads() {
+		if t.Status == tasks.StatusCompleted && mcptools.Visible(ctx, t.Workspace) {
+			resources = append(resources, map[string]interface{}{
+				"uri":         resourcePrefix + "/" + t.ID,
+				"name":        t.FileName,
+				"description": "下载任务 " + t.ID + "：" + t.FilePath,
+				"mimeType":    "application/json",
+			})
+		}
+	}
+
+	// 流水线的转录子任务与流水线输出相同，只列出流水线
+	children := map[string]bool{}
+	var transcripts []transcriptPaths
+	var ids []string
+	for _, t := range manager.Pipelines() {
+		children[t.TranscribeID] = true
+		if !mcptools.Visible(ctx, t.Workspace) {
+			continue
+		}
+		transcripts = append(transcripts, pipelinePaths(t))
+		ids = append(ids, t.ID)
+	}
+	for _, t := range manager.Transcribes() {
+		if !children[t.ID] && mcptools.Visible(ctx, t.Workspace) {
+			transcripts = append(transcripts, transcribePaths(t))
+			ids = append(ids, t.ID)
+		}
+	}
+	for i, t := range transcripts {
+		if t.Status != tasks.StatusCompleted {
+			continue
+		}
+		for _, f := range taskFiles {
+			if f.path(t) == "" {
+				continue
+			}
+			resources = append(resources, map[string]interface{}{
+				"uri":         resourcePrefix + "/" + ids[i] + "/" + f.name,
+				"name":        t.Name + " " + f.title,
+				"description": f.path(t),
+				"mimeType":    f.mimeType,
+			})
+		}
+	}
+
+	sendResponse(req, map[string]interface{}{"resources": resources})
+}
+
+func handleResourceTemplatesList(req JSONRPCRequest) {
+	templates := []map[string]interface{}{
+		{
+			"uriTemplate": resourcePrefix + "/{task_id}",
+			"name":        "任务详情",
+			"mimeType":    "application/json",
+		},
+	}
+	for _, f := range taskFiles {
+		templates = append(templates, map[string]interface{}{
+			"uriTemplate": resourcePrefix + "/{task_id}/" + f.name,
+			"name":        f.title,
+			"description": "转录或流水线任务的" + f.title,
+			"mimeType":    f.mimeType,
+		})
+	}
+	sendResponse(req, map[string]interface{}{"resourceTemplates": templates})
+}
+
+func handleResourcesRead(req JSONRPCRequest) {
+	var params struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil || params.URI == "" {
+		sendError(req, -32602, "参数无效：uri 必填")
+		return
+	}
+
+	mimeType, text, err := readResource(requestContext(context.Background(), req), params.URI)
+	if err != nil {
+		sendError(req, errResourceNotFound, err.Error())
+		return
+	}
+	sendResponse(req, map[string]interface{}{
+		"contents": []map[string]interface{}{
+			{
+				"uri":      params.URI,
+				"mimeType": mimeType,
+				"text":     text,
+			},
+		},
+	})
+}
+
+// readResource 返回资源的 MIME 类型和内容，其他工作区的任务按不存在处理
+func readResource(ctx context.Context, uri string) (string, string, error) {
+	if uri == resourcePrefix {
+		result, _ := tools.Call(ctx, "list_tasks", nil)
+		return "application/json", formatResult(result), nil
+	}
+	rest, ok := strings.CutPrefix(uri, resourcePrefix+"/")
+	if !ok || rest == "" {
+		return "", "", fmt.Errorf("资源不存在: %s", uri)
+	}
+	id, file, _ := strings.Cut(rest, "/")
+
+	if file == "" {
+		task, err := tools.FindTask(ctx, id)
+		if err != nil {
+			return "", "", fmt.Errorf("资源不存在: %s", uri)
+		}
+		return "application/json", formatResult(task), nil
+	}
+
+	if err := tools.CheckAccess(ctx, id); err != nil {
+		return "", "", fmt.Errorf("资源不存在: %s", uri)
+	}
+	var paths transcriptPaths
+	if t, err := manager.Pipeline(id); err == nil {
+		paths = pipelinePaths(t)
+	} else if t, err := manager.Transcribe(id); err == nil {
+		paths = transcribePaths(t)
+	} else {
+		return "", "", fmt.Errorf("资源不存在: %s（只有转录和流水线任务有转录文件）", uri)
+	}
+	for _, f := range taskFiles {
+		if f.name != file {
+			continue
+		}
+		path := f.path(paths)
+		if path == "" {
+			return "", "", fmt.Errorf("任务 %s 还没有生成%s", id, f.title)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", "", fmt.Errorf("读取%s失败: %v", f.title, err)
+		}
+		return f.mimeType, string(data), nil
+	}
+	return "", "", fmt.Errorf("资源不存在: %s", uri)
+}
+
+func transcribePaths(t *tasks.TranscribeTask) transcriptPaths {
+	return transcriptPaths{
+		Status:      t.Status,
+		Name:        t.OutputFilename,
+		TXTPath:     t.TXTPath,
+		SRTPath:     t.SRTPath,
+		JSONPath:    t.JSONPath,
+		SummaryPath: t.SummaryPath,
+	}
+}
+
+func pipelinePaths(t *tasks.PipelineTask) transcriptPaths {
+	name := t.ID
+	if t.FilePath != "" {
+		name = strings.TrimSuffix(filepath.Base(t.FilePath), filepath.Ext(t.FilePath))
+	}
+	return transcriptPaths{
+		Status:      t.Status,
+		Name:        name,
+		TXTPath:     t.TXTPath,
+		SRTPath:     t.SRTPath,
+		JSONPath:    t.JSONPath,
+		SummaryPath: t.SummaryPath,
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/auth"
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/errcode"
+)
+
+// 上传的 cookies 文件大小上限
+const maxCookieSize = 1 << 20
+
+// loadCookies 供下载流程读取最新的 cookies
+func loadCookies() []auth.Cookie {
+	cookies, err := vault.Load()
+	if err != nil {
+		slog.Warn("读取 cookies 失败", "error", err)
+		return nil
+	}
+	return cookies
+}
+
+// cookiesRequest 以 JSON 上传 cookies 时的请求体
+type cookiesRequest struct {
+	// Cookies Netscape cookies.txt 或 JSON 格式的 cookies
+	Cookies string `json:"cookies" binding:"required"`
+}
+
+// uploadCookies 上传 cookies，支持三种方式：
+//   - multipart 表单的 file 字段（Netscape cookies.txt 或 JSON）
+//   - JSON 请求体 {"cookies": "..."}
+//   - 纯文本请求体
+func uploadCookies(c *gin.Context) {
+	raw, err := readCookieBody(c)
+	if err != nil {
+		fail(c, errcode.InvalidArgument, err)
+		return
+	}
+
+	cookies, err := auth.Parse(raw)
+	if err != nil {
+		fail(c, errcode.InvalidArgument, err)
+		return
+	}
+	if err := vault.Save(cookies); err != nil {
+		fail(c, errcode.Internal, err)
+		return
+	}
+
+	authStatus(c)
+}
+
+func readCookieBody(c *gin.Context) (string, error) {
+	contentType := c.ContentType()
+	switch {
+	case strings.HasPrefix(contentType, "multipart/"):
+		header, err := c.FormFile("file")
+		if err != nil {
+			return "", err
+		}
+		f, err := header.Open()
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		data, err := io.ReadAll(io.LimitReader(f, maxCookieSize))
+		return string(data), err
+	case contentType == "application/json":
+		var req cookiesRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			return "", err
+		}
+		return req.Cookies, nil
+	default:
+		data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxCookieSize))
+		return string(data), err
+	}
+}
+
+// authStatus 登录状态，附带最近一次请求知乎验证登录的结果；refresh=true 时立即重新验证
+func authStatus(c *gin.Context) {
+	status, err := vault.Status()
+	if err != nil {
+		fail(c, errcode.Internal, err)
+		return
+	}
+	maxAge := downloader.SessionMaxAge
+	if c.Query("refresh") == "true" {
+		maxAge = 0
+	}
+	if session, ok := downloader.LoginSession(c.Request.Context(), maxAge); ok {
+		status.Session = &session
+	}
+	c.JSON(200, status)
+}
+
+func deleteCookies(c *gin.Context) {
+	if err := vault.Clear(); err != nil {
+		fail(c, errcode.Internal, err)
+		return
+	}
+	c.JSON(200, auth.Status{})
+}
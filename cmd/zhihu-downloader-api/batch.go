@@ -0,0 +1,93 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/tasks"
+	"zhihu-downloader/internal/transcriber"
+)
+
+// transcribeBatchRequest POST /api/transcribe/batch 的请求体，转录参数与 POST /api/transcribe 相同
+type transcribeBatchRequest struct {
+	// Dir 视频所在目录，配置了工作区时相对路径相对于工作区目录
+	Dir string `json:"dir" binding:"required"`
+	// Pattern 匹配的文件名，例如 *.mp4（默认）、*.mkv，不匹配子目录
+	Pattern string `json:"pattern"`
+	// OutputDir 转录结果的目录，默认与各视频相同
+	OutputDir        string   `json:"output_dir"`
+	Language         string   `json:"language"`
+	Diarize          bool     `json:"diarize"`
+	Summarize        bool     `json:"summarize"`
+	Model            string   `json:"model"`
+	AudioFormat      string   `json:"audio_format"`
+	AudioQuality     string   `json:"audio_quality"`
+	KeepIntermediate *bool    `json:"keep_intermediate"`
+	FilenameTemplate string   `json:"filename_template"`
+	InitialPrompt    string   `json:"initial_prompt"`
+	Vocabulary       []string `json:"vocabulary"`
+	Notify           []string `json:"notify"`
+	Priority         string   `json:"priority"`
+}
+
+// transcribeBatch 为目录中每个匹配且还没有转录的视频创建转录任务，返回批量转录的 ID、子任务和跳过的文件
+func transcribeBatch(c *gin.Context) {
+	var req transcribeBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, errcode.InvalidArgument, err)
+		return
+	}
+	id, err := idempotent(c, tasks.KindBatch, func() (string, error) {
+		batch, err := manager.StartTranscribeBatch(req.Dir, req.Pattern, transcriber.Request{
+			OutputDir: req.OutputDir,
+			Language:  req.Language,
+			Diarize:   req.Diarize,
+			Summarize: req.Summarize,
+			Model:     req.Model,
+			Workspace: workspaceName(c),
+			Notify:    req.Notify,
+			Priority:  req.Priority,
+
+			AudioFormat:      req.AudioFormat,
+			AudioQuality:     req.AudioQuality,
+			KeepIntermediate: req.KeepIntermediate,
+			FilenameTemplate: req.FilenameTemplate,
+			InitialPrompt:    req.InitialPrompt,
+			Vocabulary:       req.Vocabulary,
+		})
+		if err != nil {
+			return "", err
+		}
+		return batch.ID, nil
+	})
+	if err != nil {
+		fail(c, errcode.InvalidArgument, err)
+		return
+	}
+	batch, err := manager.TranscribeBatch(id)
+	if err != nil {
+		fail(c, errcode.NotFound, err)
+		return
+	}
+	c.JSON(200, batch)
+}
+
+// getTranscribeBatch 返回批量转录按子任务汇总的状态和进度
+func getTranscribeBatch(c *gin.Context) {
+	batch, err := manager.TranscribeBatch(c.Param("task_id"))
+	if err != nil {
+		fail(c, errcode.NotFound, err)
+		return
+	}
+	c.JSON(200, batch)
+}
+
+// cancelTranscribeBatch 取消批量转录中还没有结束的子任务
+func cancelTranscribeBatch(c *gin.Context) {
+	batch, err := manager.CancelTranscribeBatch(c.Param("task_id"))
+	if err != nil {
+		fail(c, errcode.NotFound, err)
+		return
+	}
+	c.JSON(200, batch)
+}
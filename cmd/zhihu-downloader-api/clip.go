@@ -0,0 +1,69 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/tasks"
+)
+
+// clipRequest POST /api/clip 的请求体，file_path 和 task_id 指定一个
+type clipRequest struct {
+	// FilePath 本地视频，配置了工作区时相对路径相对于工作区目录；TaskID 已完成的下载或流水线任务
+	FilePath string `json:"file_path"`
+	TaskID   string `json:"task_id"`
+	// Start / End 起止秒数
+	Start float64 `json:"start"`
+	End   float64 `json:"end" binding:"required"`
+	// Format mp4（默认）/ mkv / m4a / mp3，Reencode 重新编码以准确截取
+	Format   string `json:"format"`
+	Reencode bool   `json:"reencode"`
+	// OutputDir 输出目录，默认与源视频相同；Filename 输出文件名（不含扩展名）
+	OutputDir string   `json:"output_dir"`
+	Filename  string   `json:"filename"`
+	Notify    []string `json:"notify"`
+	Priority  string   `json:"priority"`
+}
+
+// clipVideo 创建截取片段的任务，返回任务进度。片段任务和下载任务一起排队，
+// 之后用 /api/progress/:download_id 查询，完成后 file_path 为截取的片段
+func clipVideo(c *gin.Context) {
+	var req clipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, errcode.InvalidArgument, err)
+		return
+	}
+	if req.TaskID != "" && !canAccess(c, req.TaskID) {
+		failWith(c, errcode.NotFound, "任务不存在")
+		return
+	}
+	id, err := idempotent(c, tasks.KindDownload, func() (string, error) {
+		task, err := manager.StartClip(tasks.ClipRequest{
+			FilePath:  req.FilePath,
+			TaskID:    req.TaskID,
+			Start:     req.Start,
+			End:       req.End,
+			Format:    req.Format,
+			Reencode:  req.Reencode,
+			OutputDir: req.OutputDir,
+			Filename:  req.Filename,
+			Workspace: workspaceName(c),
+			Notify:    req.Notify,
+			Priority:  req.Priority,
+		})
+		if err != nil {
+			return "", err
+		}
+		return task.ID, nil
+	})
+	if err != nil {
+		fail(c, errcode.InvalidArgument, err)
+		return
+	}
+	task, err := manager.Download(id)
+	if err != nil {
+		failWith(c, errcode.NotFound, "任务不存在")
+		return
+	}
+	c.JSON(200, newDownloadProgress(task))
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/ratelimit"
+	"zhihu-downloader/internal/tasks"
+)
+
+// collectionRequest POST /api/collection 的请求体
+type collectionRequest struct {
+	URL        string `json:"url" binding:"required"`
+	Quality    string `json:"quality"`
+	OutputPath string `json:"output_path"`
+	Backend    string `json:"backend"`
+	// MaxRate 下载速度上限，例如 2M、500K，为空时只受全局上限限制
+	MaxRate string `json:"max_rate"`
+	// Limit 最多下载的视频数，默认 200
+	Limit int `json:"limit"`
+	// Notify 合集下载结束时的通知目标：配置的通知渠道名称、webhook 地址或 none，为空时发给所有渠道
+	Notify []string `json:"notify"`
+	// Priority 优先级 high / normal / low（默认 normal），排队的下载中优先级高的先开始
+	Priority string `json:"priority"`
+}
+
+// registerCollectionRoutes 下载专栏、收藏夹、问题或用户主页中的所有视频
+func registerCollectionRoutes(router *gin.Engine) {
+	router.POST("/api/collection", func(c *gin.Context) {
+		var req collectionRequest
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			fail(c, errcode.InvalidArgument, err)
+			return
+		}
+
+		if req.Quality == "" {
+			req.Quality = cfg.Quality("hd")
+		}
+		maxRate, err := ratelimit.Parse(req.MaxRate)
+		if err != nil {
+			fail(c, errcode.InvalidArgument, err)
+			return
+		}
+
+		id, err := idempotent(c, tasks.KindCollection, func() (string, error) {
+			task, err := manager.StartCollection(downloader.Request{
+				URL:       req.URL,
+				Quality:   req.Quality,
+				OutputDir: req.OutputPath,
+				Backend:   req.Backend,
+				MaxRate:   maxRate,
+				Workspace: workspaceName(c),
+				Notify:    req.Notify,
+				Priority:  req.Priority,
+			}, req.Limit)
+			if err != nil {
+				return "", err
+			}
+			return task.ID, nil
+		})
+		if err != nil {
+			fail(c, errcode.InvalidArgument, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"task_id": id})
+	})
+
+	router.GET("/api/collection/:task_id", func(c *gin.Context) {
+		task, err := manager.Collection(c.Param("task_id"))
+		if err != nil {
+			failWith(c, errcode.NotFound, "任务不存在")
+			return
+		}
+
+		c.JSON(200, task)
+	})
+
+	router.GET("/api/collection/:task_id/stream", streamProgress)
+
+	router.POST("/api/collection/:task_id/cancel", func(c *gin.Context) {
+		manager.Cancel(c.Param("task_id"))
+		c.JSON(200, gin.H{"status": "cancelled"})
+	})
+
+	router.POST("/api/collection/:task_id/retry", func(c *gin.Context) {
+		id := c.Param("task_id")
+		if _, err := manager.Collection(id); err != nil {
+			failWith(c, errcode.NotFound, "任务不存在")
+			return
+		}
+		if err := manager.Retry(id); err != nil {
+			fail(c, errcode.Conflict, err)
+			return
+		}
+
+		task, _ := manager.Collection(id)
+		c.JSON(200, task)
+	})
+
+	router.DELETE("/api/collection/:task_id", func(c *gin.Context) {
+		id := c.Param("task_id")
+		if _, err := manager.Collection(id); err != nil {
+			failWith(c, errcode.NotFound, "任务不存在")
+			return
+		}
+		deleteTask(c, id)
+	})
+}
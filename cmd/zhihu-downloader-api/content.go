@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/tasks"
+)
+
+// downloadContent 返回下载任务文件的 SHA-256 和下载过同一内容的所有链接（转载的视频链接不同、内容相同）。
+// 只能访问自己工作区的请求只返回本工作区仍存在的任务
+func downloadContent(c *gin.Context) {
+	info, err := manager.Content(c.Param("download_id"))
+	switch {
+	case errors.Is(err, tasks.ErrNoContentHash):
+		failWith(c, errcode.NotFound, "任务没有记录内容哈希（未完成、去重已关闭或在此功能之前下载）")
+		return
+	case err != nil:
+		failWith(c, errcode.NotFound, "任务不存在")
+		return
+	}
+	if restricted(c) {
+		sources := info.Sources[:0]
+		for _, src := range info.Sources {
+			if owner, ok := manager.TaskWorkspace(src.TaskID); ok && owner == workspaceName(c) {
+				sources = append(sources, src)
+			}
+		}
+		info.Sources = sources
+	}
+	c.JSON(200, info)
+}
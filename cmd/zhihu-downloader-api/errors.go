@@ -0,0 +1,21 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/errcode"
+)
+
+// fail 返回 err 对应的错误响应，HTTP 状态码由错误码决定；err 没有错误码且无法归类时使用 fallback
+func fail(c *gin.Context, fallback errcode.Code, err error) {
+	respondError(c, errcode.Describe(err, fallback))
+}
+
+// failWith 返回指定错误码和说明的错误响应
+func failWith(c *gin.Context, code errcode.Code, message string) {
+	respondError(c, errcode.Info{Code: code, Message: message})
+}
+
+func respondError(c *gin.Context, info errcode.Info) {
+	c.AbortWithStatusJSON(info.Code.HTTPStatus(), errorResponse{Error: info.Message, Info: info})
+}
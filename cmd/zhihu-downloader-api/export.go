@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/tasks"
+)
+
+// exportTasks 导出任务历史：?format=csv|json，筛选参数与 /api/tasks 相同（type、status、since、until、search），
+// 不分页，返回所有符合条件的任务
+func exportTasks(c *gin.Context) {
+	format, err := tasks.ParseExportFormat(c.Query("format"))
+	if err != nil {
+		fail(c, errcode.InvalidArgument, err)
+		return
+	}
+	q, err := taskQuery(c)
+	if err != nil {
+		fail(c, errcode.InvalidArgument, err)
+		return
+	}
+	report := manager.Export(q)
+
+	filename := fmt.Sprintf("tasks-%s.%s", report.ExportedAt.Format("20060102-150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	if format == "json" {
+		c.JSON(200, report)
+		return
+	}
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(200)
+	if err := report.WriteCSV(c.Writer); err != nil {
+		c.Error(err)
+	}
+}
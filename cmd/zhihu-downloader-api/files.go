@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/base64"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/media"
+	"zhihu-downloader/internal/tasks"
+)
+
+// fileKinds 列出的文件类型（按扩展名），其他文件不返回
+var fileKinds = map[string]string{
+	".mp4":  "video",
+	".mkv":  "video",
+	".webm": "video",
+	".mov":  "video",
+	".flv":  "video",
+	".mp3":  "audio",
+	".m4a":  "audio",
+	".wav":  "audio",
+	".flac": "audio",
+	".txt":  "text",
+	".srt":  "text",
+	".md":   "text",
+	".json": "text",
+	".jpg":  "image",
+}
+
+// maxListDepth 列出输出目录时最多进入的子目录层数（回答 / 文章保存在单独的子目录中）
+const maxListDepth = 2
+
+// fileEntry 输出目录中的文件
+type fileEntry struct {
+	// ID 相对路径的 base64url 编码，用于 /api/files/:id/download
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Path     string    `json:"path"`
+	Kind     string    `json:"kind"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	// TaskID 生成该文件的任务，找不到时为空
+	TaskID string `json:"task_id,omitempty"`
+	// ThumbnailID / SpriteID 视频的封面和预览图的文件 ID，没有时为空
+	ThumbnailID string `json:"thumbnail_id,omitempty"`
+	SpriteID    string `json:"sprite_id,omitempty"`
+	// Stale 未完成的文件（kind 为 partial）不会再被续传，可以用 DELETE /api/maintenance/partials 清理
+	Stale bool `json:"stale,omitempty"`
+}
+
+// listFiles 列出输出目录（配置了工作区时为当前工作区的目录）中的视频、音频、文本和图片文件（按修改时间倒序），
+// ?kind=video|audio|text|image|partial 只返回对应类型。视频附带封面和预览图的文件 ID，便于显示视频库。
+// 写入中断留下的 .part 等未完成文件的 kind 为 partial，不会再被续传的标记为 stale
+func listFiles(c *gin.Context) {
+	root := filesRoot(c)
+	kind := c.Query("kind")
+	owners := fileOwners()
+	stale := map[string]bool{}
+	for _, f := range manager.PartialFiles() {
+		stale[f.Path] = true
+		if f.TaskID != "" {
+			owners[f.Path] = f.TaskID
+		}
+	}
+
+	files := []fileEntry{}
+	// images 输出目录中所有图片的 ID，?kind=video 时也需要用来查找封面
+	images := map[string]string{}
+	realRoot, _ := filepath.EvalSymlinks(root)
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		name := d.Name()
+		if d.IsDir() {
+			// 跳过隐藏目录、HLS 分片目录和过深的子目录
+			if path != root && (strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".parts") ||
+				strings.Count(rel, string(filepath.Separator)) >= maxListDepth) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		k, ok := fileKinds[strings.ToLower(filepath.Ext(name))]
+		if tasks.IsPartialName(name) {
+			k, ok = "partial", true
+		}
+		if !ok || strings.HasPrefix(name, ".") {
+			return nil
+		}
+		// 符号链接（去重时链接到内容相同的文件）只列出指向输出目录之内的
+		if d.Type()&fs.ModeSymlink != 0 && !linkInRoot(realRoot, path) {
+			return nil
+		}
+		id := base64.RawURLEncoding.EncodeToString([]byte(filepath.ToSlash(rel)))
+		if k == "image" {
+			images[path] = id
+		}
+		if kind != "" && k != kind {
+			return nil
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil
+		}
+		files = append(files, fileEntry{
+			ID:       id,
+			Name:     name,
+			Path:     filepath.ToSlash(rel),
+			Kind:     k,
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+			TaskID:   owners[path],
+			Stale:    stale[path],
+		})
+		return nil
+	})
+
+	for i, f := range files {
+		if f.Kind == "video" {
+			path := filepath.Join(root, filepath.FromSlash(f.Path))
+			files[i].ThumbnailID = images[media.ThumbnailPath(path)]
+			files[i].SpriteID = images[media.SpritePath(path)]
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Modified.After(files[j].Modified) })
+	c.JSON(200, gin.H{"dir": root, "files": files})
+}
+
+// linkInRoot 符号链接 path 解析后是否在 realRoot（已解析符号链接）之内
+func linkInRoot(realRoot, path string) bool {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil || realRoot == "" {
+		return false
+	}
+	r, err := filepath.Rel(realRoot, real)
+	return err == nil && r != ".." && !strings.HasPrefix(r, ".."+string(filepath.Separator))
+}
+
+// fileOwners 返回任务输出文件到任务 ID 的映射
+func fileOwners() map[string]string {
+	owners := map[string]string{}
+	for _, t := range manager.Downloads() {
+		for _, path := range []string{t.FilePath, t.ThumbnailPath, t.SpritePath, t.NFOPath, t.CommentsPath, t.CommentsMarkdownPath} {
+			if path != "" {
+				owners[path] = t.ID
+			}
+		}
+	}
+	for _, t := range manager.Transcribes() {
+		for _, path := range []string{t.MP3Path, t.TXTPath, t.SRTPath, t.JSONPath, t.SummaryPath} {
+			if path != "" {
+				owners[path] = t.ID
+			}
+		}
+	}
+	for _, t := range manager.Pipelines() {
+		if t.SubtitledPath != "" {
+			owners[t.SubtitledPath] = t.ID
+		}
+	}
+	return owners
+}
+
+// downloadFile 下载或在线播放文件，支持 Range 请求。
+// :id 可以是 /api/files 返回的文件 ID，也可以是任务 ID：
+// 任务 ID 时用 ?type=video|thumbnail|sprite|nfo|comments|comments_json|mp3|txt|srt|json|summary|subtitled 选择文件（默认为视频或转录文本）。
+// ?attachment=1 时浏览器保存为文件而不是直接打开
+func downloadFile(c *gin.Context) {
+	id := c.Param("id")
+	path, status, msg := taskFile(id, c.Query("type"))
+	if status == http.StatusNotFound && msg == "" {
+		path, status, msg = outputFile(filesRoot(c), id)
+	}
+	if status != http.StatusOK {
+		code := errcode.NotFound
+		switch status {
+		case http.StatusBadRequest:
+			code = errcode.InvalidArgument
+		case http.StatusForbidden:
+			code = errcode.Forbidden
+		}
+		failWith(c, code, msg)
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		failWith(c, errcode.NotFound, "文件不存在")
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		failWith(c, errcode.NotFound, "文件不存在")
+		return
+	}
+
+	name := filepath.Base(path)
+	disposition := "inline"
+	if c.Query("attachment") != "" {
+		disposition = "attachment"
+	}
+	c.Header("Content-Disposition", disposition+"; filename*=UTF-8''"+url.PathEscape(name))
+	if filepath.Ext(name) == ".srt" {
+		c.Header("Content-Type", "application/x-subrip; charset=utf-8")
+	}
+	// ServeContent 处理 Range / If-Modified-Since，浏览器可以拖动进度条
+	http.ServeContent(c.Writer, c.Request, name, info.ModTime(), f)
+}
+
+// taskFile 按任务 ID 查找输出文件；不是任务 ID 时返回 404 和空消息
+func taskFile(id, typ string) (string, int, string) {
+	var files map[string]string
+	if t, err := manager.Download(id); err == nil {
+		files = map[string]string{"": t.FilePath, "video": t.FilePath,
+			"thumbnail": t.ThumbnailPath, "sprite": t.SpritePath, "nfo": t.NFOPath,
+			"comments": t.CommentsMarkdownPath, "comments_json": t.CommentsPath}
+	} else if t, err := manager.Transcribe(id); err == nil {
+		files = map[string]string{"": t.TXTPath, "mp3": t.MP3Path, "txt": t.TXTPath,
+			"srt": t.SRTPath, "json": t.JSONPath, "summary": t.SummaryPath}
+	} else if t, err := manager.Pipeline(id); err == nil {
+		files = map[string]string{"": t.FilePath, "video": t.FilePath, "mp3": t.MP3Path, "txt": t.TXTPath,
+			"srt": t.SRTPath, "json": t.JSONPath, "summary": t.SummaryPath, "subtitled": t.SubtitledPath}
+		// 封面、预览图和 .nfo 保存在下载子任务中
+		if d, err := manager.Download(t.DownloadID); err == nil {
+			files["thumbnail"], files["sprite"], files["nfo"] = d.ThumbnailPath, d.SpritePath, d.NFOPath
+		}
+	} else {
+		return "", http.StatusNotFound, ""
+	}
+
+	path, ok := files[typ]
+	if !ok {
+		return "", http.StatusBadRequest, "该任务没有 " + typ + " 类型的文件"
+	}
+	if path == "" {
+		return "", http.StatusNotFound, "任务还没有生成该文件"
+	}
+	return path, http.StatusOK, ""
+}
+
+// outputFile 把文件 ID 解码为 root 中的路径，不允许访问 root 之外的文件
+func outputFile(root, id string) (string, int, string) {
+	rel, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil || len(rel) == 0 {
+		return "", http.StatusNotFound, "文件不存在"
+	}
+	path := filepath.Join(root, filepath.FromSlash(string(rel)))
+	if _, ok := fileKinds[strings.ToLower(filepath.Ext(path))]; !ok || strings.HasPrefix(filepath.Base(path), ".") {
+		return "", http.StatusForbidden, "不支持下载该类型的文件"
+	}
+	if r, err := filepath.Rel(root, path); err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return "", http.StatusForbidden, "不允许访问输出目录之外的文件"
+	}
+	// 符号链接也不能指向输出目录之外
+	realRoot, err1 := filepath.EvalSymlinks(root)
+	realPath, err2 := filepath.EvalSymlinks(path)
+	if err1 != nil || err2 != nil {
+		return "", http.StatusNotFound, "文件不存在"
+	}
+	if r, err := filepath.Rel(realRoot, realPath); err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return "", http.StatusForbidden, "不允许访问输出目录之外的文件"
+	}
+	return path, http.StatusOK, ""
+}
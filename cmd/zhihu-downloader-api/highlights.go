@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/tasks"
+)
+
+// highlightsRequest POST /api/highlights 的请求体，keywords 和 llm 至少指定一个
+type highlightsRequest struct {
+	// TaskID 已完成的转录或流水线任务
+	TaskID string `json:"task_id" binding:"required"`
+	// Keywords 关键词，不区分大小写
+	Keywords []string `json:"keywords"`
+	// LLM 请大模型挑选精彩片段，Limit 为最多挑选的片段数（默认 10）
+	LLM   bool `json:"llm"`
+	Limit int  `json:"limit"`
+	// Padding 片段前后多保留的秒数，默认 2
+	Padding *float64 `json:"padding"`
+	// Clip 把每个片段剪成单独的视频，Reencode 重新编码以准确截取
+	Clip     bool `json:"clip"`
+	Reencode bool `json:"reencode"`
+}
+
+// highlights 在转录文稿中查找关键词或请大模型挑选精彩片段，返回带时间的片段，可以同时剪成单独的视频
+func highlights(c *gin.Context) {
+	var req highlightsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, errcode.InvalidArgument, err)
+		return
+	}
+	if !canAccess(c, req.TaskID) {
+		failWith(c, errcode.NotFound, "任务不存在")
+		return
+	}
+	result, err := manager.Highlights(c.Request.Context(), req.TaskID, tasks.HighlightRequest{
+		Keywords: req.Keywords,
+		LLM:      req.LLM,
+		Limit:    req.Limit,
+		Padding:  req.Padding,
+		Clip:     req.Clip,
+		Reencode: req.Reencode,
+	})
+	switch {
+	case errors.Is(err, tasks.ErrNotFound):
+		failWith(c, errcode.NotFound, "任务不存在")
+	case err != nil:
+		fail(c, errcode.Conflict, err)
+	default:
+		c.JSON(200, result)
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/tasks"
+)
+
+// idempotent 按请求头 Idempotency-Key 创建任务：同一个键重复提交时（例如超时后重试）不再创建，
+// 返回第一次创建的任务 ID，并设置响应头 Idempotent-Replayed: true
+func idempotent(c *gin.Context, kind tasks.Kind, create func() (string, error)) (string, error) {
+	id, replayed, err := manager.Idempotent(workspaceName(c), c.GetHeader("Idempotency-Key"), kind, create)
+	if replayed {
+		c.Header("Idempotent-Replayed", "true")
+	}
+	return id, err
+}
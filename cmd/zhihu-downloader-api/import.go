@@ -0,0 +1,38 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/tasks"
+)
+
+// importRequest POST /api/import 的请求体
+type importRequest struct {
+	// FilePath 本地视频文件，配置了工作区时相对路径相对于工作区目录
+	FilePath string `json:"file_path" binding:"required"`
+	// URL 视频的来源链接，Title 视频标题，可选
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+// importVideo 把不是由本服务下载的本地视频登记为已完成的下载任务，返回任务进度。
+// 之后可以转录（video_path 为该文件）、搜索转录文本，并计入统计
+func importVideo(c *gin.Context) {
+	var req importRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, errcode.InvalidArgument, err)
+		return
+	}
+	task, err := manager.ImportVideo(tasks.ImportRequest{
+		FilePath:  req.FilePath,
+		URL:       req.URL,
+		Title:     req.Title,
+		Workspace: workspaceName(c),
+	})
+	if err != nil {
+		fail(c, errcode.InvalidArgument, err)
+		return
+	}
+	c.JSON(200, newDownloadProgress(task))
+}
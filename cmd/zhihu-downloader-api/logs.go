@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/tasks"
+)
+
+// taskLogs 返回任务最近的日志（包括 ffmpeg / Whisper 等外部程序的输出），
+// ?lines=N 限制行数，默认返回保留的全部日志。日志只保存在内存中，服务重启后清空
+func taskLogs(c *gin.Context) {
+	n := 0
+	if v := c.Query("lines"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil || n < 0 {
+			failWith(c, errcode.InvalidArgument, "lines 必须是非负整数")
+			return
+		}
+	}
+
+	id := c.Param("id")
+	lines, err := manager.Logs(id, n)
+	if err != nil {
+		fail(c, errcode.NotFound, err)
+		return
+	}
+	c.JSON(200, gin.H{"task_id": id, "lines": lines})
+}
+
+// taskEvents 返回任务的历史事件：创建、每次状态变化、出错和重试，按时间先后排列。
+// 有数据库时事件随任务持久保存，服务重启后仍然可以查看
+func taskEvents(c *gin.Context) {
+	id := c.Param("id")
+	events, err := manager.Events(id)
+	if errors.Is(err, tasks.ErrNotFound) {
+		fail(c, errcode.NotFound, err)
+		return
+	}
+	if err != nil {
+		fail(c, errcode.Internal, err)
+		return
+	}
+	c.JSON(200, gin.H{"task_id": id, "events": events})
+}
+
+// taskOutput 返回失败任务执行期间外部程序（ffmpeg、yt-dlp、Python、Whisper）的完整输出，纯文本，
+// 每行前面是程序名。有数据库时随任务持久保存，超出上限时只保留最后的部分，响应头 X-Output-Truncated 为 true
+func taskOutput(c *gin.Context) {
+	o, err := manager.Output(c.Param("id"))
+	switch {
+	case errors.Is(err, tasks.ErrNotFound), errors.Is(err, tasks.ErrNoOutput):
+		fail(c, errcode.NotFound, err)
+		return
+	case err != nil:
+		fail(c, errcode.Internal, err)
+		return
+	}
+	c.Header("X-Output-Truncated", strconv.FormatBool(o.Truncated))
+	c.Header("Last-Modified", o.CreatedAt.UTC().Format(http.TimeFormat))
+	c.Data(200, "text/plain; charset=utf-8", o.Output)
+}
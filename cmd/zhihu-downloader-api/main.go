@@ -0,0 +1,572 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/auth"
+	"zhihu-downloader/internal/config"
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/health"
+	"zhihu-downloader/internal/jobqueue"
+	"zhihu-downloader/internal/media"
+	"zhihu-downloader/internal/proc"
+	"zhihu-downloader/internal/ratelimit"
+	"zhihu-downloader/internal/serve"
+	"zhihu-downloader/internal/store"
+	"zhihu-downloader/internal/tasks"
+	"zhihu-downloader/internal/transcriber"
+)
+
+// downloadProgress 兼容桌面端的下载进度格式（download_id + 首字母大写的状态）
+type downloadProgress struct {
+	*tasks.DownloadTask
+	DownloadID string `json:"download_id"`
+	Status     string `json:"status"`
+}
+
+// transcribeProgress 转录进度，额外返回 task_id
+type transcribeProgress struct {
+	*tasks.TranscribeTask
+	TaskID string `json:"task_id"`
+}
+
+// downloadRequest POST /api/download 的请求体
+type downloadRequest struct {
+	URL        string `json:"url" binding:"required"`
+	Quality    string `json:"quality"`
+	OutputPath string `json:"output_path"`
+	Backend    string `json:"backend"`
+	// MaxRate 下载速度上限，例如 2M、500K，为空时只受全局上限限制
+	MaxRate string `json:"max_rate"`
+	// Connections m3u8 同时下载的分片数（1–16），默认使用配置 download.connections
+	Connections int `json:"connections"`
+	// FFmpegArgs 生成 MP4 时追加的 ffmpeg 输出选项（白名单），例如 ["-movflags", "+faststart"]
+	FFmpegArgs []string `json:"ffmpeg_args"`
+	// Headers 下载时附加的请求头，例如 {"Authorization": "Bearer ..."}；Cookies 附加的 cookies，例如 {"token": "..."}。
+	// 只用于本次下载（包括暂停后继续和重试），加密保存，下载完成后清除，不在任务详情中返回
+	Headers map[string]string `json:"headers"`
+	Cookies map[string]string `json:"cookies"`
+	// Transcode 下载完成后转码为 h264 / h265 / av1，none 表示不转码，默认使用配置 transcode.codec；
+	// MaxHeight 为转码的分辨率上限，CRF 为转码画质
+	Transcode string `json:"transcode"`
+	MaxHeight int    `json:"max_height"`
+	CRF       int    `json:"crf"`
+	// HWAccel 转码时的硬件加速方式 none / videotoolbox / nvenc / vaapi，默认使用配置 tools.hwaccel
+	HWAccel string `json:"hwaccel"`
+	// FilenameTemplate 文件名模板，例如 {title}_{quality}_{date}
+	FilenameTemplate string `json:"filename_template"`
+	// Force 已下载过同一视频时仍然重新下载
+	Force bool `json:"force"`
+	// Comments 下载完成后保存知乎评论，最多 CommentsLimit 条根评论（0 表示全部）
+	Comments      bool `json:"comments"`
+	CommentsLimit int  `json:"comments_limit"`
+	// Notify 结束时的通知目标：配置的通知渠道名称、webhook 地址或 none，为空时发给所有渠道
+	Notify []string `json:"notify"`
+	// Priority 优先级 high / normal / low（默认 normal），排队的下载中优先级高的先开始
+	Priority string `json:"priority"`
+}
+
+// transcribeRequest POST /api/transcribe 的请求体
+type transcribeRequest struct {
+	VideoPath string `json:"video_path" binding:"required"`
+	// Language 语言代码，为空或 auto 时自动识别
+	Language string `json:"language"`
+	// Diarize 区分说话人
+	Diarize bool `json:"diarize"`
+	// Summarize 转录后生成摘要
+	Summarize bool `json:"summarize"`
+	// Model Whisper 模型 tiny / base / small / medium / large-v3，默认使用配置的模型
+	Model string `json:"model"`
+	// AudioFormat 提取的音频格式 wav / mp3 / m4a / flac，AudioQuality 为 mp3 / m4a 的码率，默认使用配置
+	AudioFormat  string `json:"audio_format"`
+	AudioQuality string `json:"audio_quality"`
+	// KeepIntermediate 转录成功后保留提取的音频，默认使用配置 transcribe.keep_intermediate
+	KeepIntermediate *bool `json:"keep_intermediate"`
+	// FilenameTemplate 音频、txt、srt 的文件名模板，例如 {title}_{date}，变量取自下载这个视频的任务，默认与视频同名
+	FilenameTemplate string `json:"filename_template"`
+	// InitialPrompt 传给 Whisper 的提示文本，例如视频主题或一段包含术语的文字
+	InitialPrompt string `json:"initial_prompt"`
+	// Vocabulary 视频中的专业词汇（人名、术语等），帮助 Whisper 正确识别，默认使用工作区的词汇表
+	Vocabulary []string `json:"vocabulary"`
+	// Notify 结束时的通知目标：配置的通知渠道名称、webhook 地址或 none，为空时发给所有渠道
+	Notify []string `json:"notify"`
+	// Priority 优先级 high / normal / low（默认 normal）：同时转录的任务数达到 transcribe.max_concurrent 时排队，优先级高的先开始
+	Priority string `json:"priority"`
+}
+
+var (
+	cfg     *config.Config
+	db      store.Store
+	vault   *auth.Vault
+	manager *tasks.Manager
+)
+
+func main() {
+	var err error
+	cfg, err = config.Load(config.AppAPI, os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		os.Exit(1)
+	}
+	cfg.Apply()
+	if cfg.Doctor {
+		if !health.PrintDoctor(os.Stdout, health.Doctor(context.Background(), cfg.DoctorOptions())) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	db, err = store.Open(cfg.StoreOptions())
+	if err != nil {
+		slog.Error("数据库初始化失败", "error", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	vault, err = auth.OpenVault(db, auth.KeyPath(cfg.Storage.DBPath))
+	if err != nil {
+		slog.Error("加载密钥失败", "error", err)
+		os.Exit(1)
+	}
+	// 下载任务附加的请求头和 cookies 用同一个密钥加密后保存
+	db.SetSecrets(vault)
+	downloader.SetCookieSource(loadCookies)
+	health.LogTools()
+	transcriber.LogStatus()
+
+	// 使用 Redis 队列时，下载和转录任务由共用队列的所有进程按各自的并发上限领取执行
+	queue, err := jobqueue.Open(cfg.QueueOptions())
+	if err != nil {
+		slog.Error("连接任务队列失败", "error", err)
+		os.Exit(1)
+	}
+
+	// 载入历史任务，上次未结束的任务标记为 interrupted，可通过 retry 接口继续。
+	// 与 MCP 服务共用任务存储和 ID 序列，任务在两边都能查询和控制
+	manager = tasks.NewManager(append(cfg.ManagerOptions(),
+		tasks.WithIDGenerator(db.NextID),
+		tasks.WithPersister(db),
+		tasks.WithSharedStore(db),
+		tasks.WithJobQueue(queue),
+	)...)
+	downloads, _ := db.Downloads()
+	transcribes, _ := db.Transcribes()
+	pipelines, _ := db.Pipelines()
+	collections, _ := db.Collections()
+	manager.Restore(downloads, transcribes, pipelines, collections)
+	if n := manager.MarkInterrupted(); n > 0 {
+		slog.Warn("部分任务在上次退出时被中断，可调用 retry 接口继续", "count", n)
+	}
+	go manager.RunRetention(context.Background(), cfg.RetentionPolicy())
+	go manager.RunJanitor(context.Background(), cfg.JanitorOptions())
+	go downloader.KeepSessionAlive(context.Background(), cfg.Auth.CheckInterval)
+	go manager.RunSharedSync(context.Background())
+	go manager.RunJobQueue(context.Background())
+	go manager.IndexEmbeddings(context.Background())
+	proc.ExitOnSignal(func() { db.Close() })
+
+	// 计划任务只由网关执行，stdio MCP 服务共用数据库时不会重复执行
+	schedules, _ := db.Schedules()
+	manager.RestoreSchedules(schedules)
+	go manager.RunSchedules(context.Background())
+	// 订阅同样只由网关检查，MCP 等服务创建的订阅保存在数据库中，由网关下次轮询时检查
+	go manager.RunSubscriptions(context.Background())
+
+	// 监视目录同样只由网关执行，避免同一个文件被多个服务重复转录
+	go manager.RunWatcher(context.Background(), cfg.WatchOptions())
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.Default()
+
+	// 跨域支持
+	router.Use(func(c *gin.Context) {
+		c.Header("Access-Control-Allow-Origin", "*")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, Range, Idempotency-Key")
+		c.Header("Access-Control-Expose-Headers", "Content-Range, Accept-Ranges, Content-Length, Content-Disposition, Idempotent-Replayed")
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(204)
+			return
+		}
+		c.Next()
+	})
+
+	// 配置了工作区时按 API 密钥区分工作区
+	router.Use(requireAPIKey)
+
+	// API 路由
+	// 检查 ffmpeg、Whisper、数据库、下载目录、磁盘空间和卡住的任务，必需的依赖缺失时返回 503
+	router.GET("/api/health", func(c *gin.Context) {
+		report := health.Run(c.Request.Context(), health.Options{
+			Database:  db.CheckWritable,
+			OutputDir: manager.OutputDir(),
+			MinFree:   int64(cfg.Quota.MinFreeMB) << 20,
+			Manager:   manager,
+		})
+		running, queued, limit := manager.QueueStats()
+		transcribing, transcribeQueued, transcribeLimit := manager.TranscribeQueueStats()
+		c.JSON(report.HTTPStatus(), gin.H{
+			"status":        report.Status,
+			"checks":        report.Checks,
+			"stuck_tasks":   report.StuckTasks,
+			"login":         report.Login,
+			"authenticated": true,
+			"downloads": gin.H{
+				"running": running,
+				"queued":  queued,
+				"limit":   limit,
+			},
+			"transcriptions": gin.H{
+				"running": transcribing,
+				"queued":  transcribeQueued,
+				"limit":   transcribeLimit,
+			},
+			"transcribe": transcriber.CurrentStatus(),
+			"tools":      health.Tools(),
+		})
+	})
+
+	// 登录 cookies
+	router.POST("/api/auth/cookies", requireAdmin, uploadCookies)
+	router.DELETE("/api/auth/cookies", requireAdmin, deleteCookies)
+	router.GET("/api/auth/status", authStatus)
+
+	// 视频信息与可用清晰度
+	router.GET("/api/video/info", videoInfo)
+
+	// 识别并规范化链接
+	router.GET("/api/resolve", resolveLink)
+
+	router.POST("/api/download", func(c *gin.Context) {
+		var req downloadRequest
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			fail(c, errcode.InvalidArgument, err)
+			return
+		}
+
+		if req.Quality == "" {
+			req.Quality = cfg.Quality("hd")
+		}
+		maxRate, err := ratelimit.Parse(req.MaxRate)
+		if err != nil {
+			fail(c, errcode.InvalidArgument, err)
+			return
+		}
+
+		id, err := idempotent(c, tasks.KindDownload, func() (string, error) {
+			task, err := manager.StartDownload(downloader.Request{
+				URL:       req.URL,
+				Quality:   req.Quality,
+				OutputDir: req.OutputPath,
+				Backend:   req.Backend,
+				MaxRate:   maxRate,
+				Force:     req.Force,
+				Comments:  req.Comments,
+				Workspace: workspaceName(c),
+				Notify:    req.Notify,
+				Priority:  req.Priority,
+
+				Connections:      req.Connections,
+				CommentsLimit:    req.CommentsLimit,
+				FilenameTemplate: req.FilenameTemplate,
+				FFmpegArgs:       req.FFmpegArgs,
+				HWAccel:          req.HWAccel,
+				Headers:          req.Headers,
+				Cookies:          req.Cookies,
+				Transcode:        media.TranscodeOptions{Codec: req.Transcode, MaxHeight: req.MaxHeight, CRF: req.CRF},
+			})
+			if err != nil {
+				return "", err
+			}
+			return task.ID, nil
+		})
+		if err != nil {
+			fail(c, errcode.InvalidArgument, err)
+			return
+		}
+		task, err := manager.Download(id)
+		if err != nil {
+			failWith(c, errcode.NotFound, "任务不存在")
+			return
+		}
+
+		c.JSON(200, gin.H{"download_id": task.ID, "cached": task.Cached})
+	})
+
+	// 导入已有的本地视频
+	router.POST("/api/import", importVideo)
+
+	// 截取视频片段
+	router.POST("/api/clip", clipVideo)
+
+	router.GET("/api/progress/:download_id", func(c *gin.Context) {
+		task, err := manager.Download(c.Param("download_id"))
+		if err != nil {
+			failWith(c, errcode.NotFound, "任务不存在")
+			return
+		}
+
+		c.JSON(200, newDownloadProgress(task))
+	})
+
+	router.GET("/api/progress/:download_id/stream", streamProgress)
+
+	router.POST("/api/download/:download_id/cancel", func(c *gin.Context) {
+		manager.Cancel(c.Param("download_id"))
+		c.JSON(200, gin.H{"status": "cancelled"})
+	})
+
+	router.POST("/api/download/:download_id/retry", func(c *gin.Context) {
+		id := c.Param("download_id")
+		if _, err := manager.Download(id); err != nil {
+			failWith(c, errcode.NotFound, "任务不存在")
+			return
+		}
+		if err := manager.Retry(id); err != nil {
+			fail(c, errcode.Conflict, err)
+			return
+		}
+
+		task, _ := manager.Download(id)
+		c.JSON(200, newDownloadProgress(task))
+	})
+
+	// 文件内容和下载过同一内容的所有链接
+	router.GET("/api/download/:download_id/content", downloadContent)
+
+	// 暂停 / 继续下载，暂停时保留已下载的分片
+	router.POST("/api/download/:download_id/pause", func(c *gin.Context) {
+		pauseOrResume(c, manager.Pause)
+	})
+	router.POST("/api/download/:download_id/resume", func(c *gin.Context) {
+		pauseOrResume(c, manager.Resume)
+	})
+
+	router.DELETE("/api/download/:download_id", func(c *gin.Context) {
+		id := c.Param("download_id")
+		if _, err := manager.Download(id); err != nil {
+			failWith(c, errcode.NotFound, "任务不存在")
+			return
+		}
+		deleteTask(c, id)
+	})
+
+	// 转录相关路由
+	router.POST("/api/transcribe", func(c *gin.Context) {
+		var req transcribeRequest
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			fail(c, errcode.InvalidArgument, err)
+			return
+		}
+
+		id, err := idempotent(c, tasks.KindTranscribe, func() (string, error) {
+			task, err := manager.StartTranscribe(transcriber.Request{
+				VideoPath: req.VideoPath,
+				Language:  req.Language,
+				Diarize:   req.Diarize,
+				Summarize: req.Summarize,
+				Model:     req.Model,
+				Workspace: workspaceName(c),
+				Notify:    req.Notify,
+				Priority:  req.Priority,
+
+				AudioFormat:      req.AudioFormat,
+				AudioQuality:     req.AudioQuality,
+				KeepIntermediate: req.KeepIntermediate,
+				FilenameTemplate: req.FilenameTemplate,
+				InitialPrompt:    req.InitialPrompt,
+				Vocabulary:       req.Vocabulary,
+			})
+			if err != nil {
+				return "", err
+			}
+			return task.ID, nil
+		})
+		if err != nil {
+			fail(c, errcode.InvalidArgument, err)
+			return
+		}
+		task, err := manager.Transcribe(id)
+		if err != nil {
+			failWith(c, errcode.NotFound, "任务不存在")
+			return
+		}
+
+		c.JSON(200, gin.H{"task_id": task.ID, "model": task.Model})
+	})
+
+	// 批量转录目录中的视频
+	router.POST("/api/transcribe/batch", transcribeBatch)
+	router.GET("/api/transcribe/batch/:task_id", getTranscribeBatch)
+	router.POST("/api/transcribe/batch/:task_id/cancel", cancelTranscribeBatch)
+
+	router.POST("/api/summarize", summarize)
+	router.POST("/api/highlights", highlights)
+
+	// 本机可用的 Whisper 后端
+	router.GET("/api/transcribe/backends", func(c *gin.Context) {
+		c.JSON(200, gin.H{"hardware": transcriber.DetectHardware(), "backends": transcriber.Detect()})
+	})
+
+	// 可选的 Whisper 模型和在当前后端的安装情况
+	router.GET("/api/transcribe/models", func(c *gin.Context) {
+		backend, models, err := transcriber.ModelStatuses()
+		if err != nil {
+			fail(c, errcode.Unavailable, err)
+			return
+		}
+		def, _ := transcriber.ResolveModel("")
+		c.JSON(200, gin.H{
+			"backend":       backend,
+			"default":       def,
+			"auto_download": transcriber.AutoDownload(),
+			"models":        models,
+		})
+	})
+
+	router.GET("/api/transcribe/:task_id", func(c *gin.Context) {
+		task, err := manager.Transcribe(c.Param("task_id"))
+		if err != nil {
+			failWith(c, errcode.NotFound, "任务不存在")
+			return
+		}
+
+		c.JSON(200, transcribeProgress{TranscribeTask: task, TaskID: task.ID})
+	})
+
+	// 逐段和逐词时间的转录结果
+	router.GET("/api/transcribe/:task_id/transcript", transcript)
+
+	// 通过 SSE 实时推送识别出的文稿
+	router.GET("/api/transcribe/:task_id/stream", streamTranscript)
+
+	router.DELETE("/api/transcribe/:task_id", func(c *gin.Context) {
+		id := c.Param("task_id")
+		if _, err := manager.Transcribe(id); err != nil {
+			failWith(c, errcode.NotFound, "任务不存在")
+			return
+		}
+		deleteTask(c, id)
+	})
+
+	// 下载 + 转录流水线
+	registerPipelineRoutes(router)
+
+	// 专栏、收藏夹等合集下载
+	registerCollectionRoutes(router)
+
+	// 计划任务
+	registerScheduleRoutes(router)
+
+	// 订阅
+	registerSubscriptionRoutes(router)
+
+	// 网页界面
+	registerUIRoutes(router)
+
+	// 任务列表：?type=&status=&since=&until=&search=&limit=&offset=
+	router.GET("/api/tasks", func(c *gin.Context) {
+		q, err := taskQuery(c)
+		if err != nil {
+			fail(c, errcode.InvalidArgument, err)
+			return
+		}
+
+		c.JSON(200, manager.List(q))
+	})
+
+	// 导出任务历史：?format=csv|json，筛选参数与 /api/tasks 相同
+	router.GET("/api/tasks/export", exportTasks)
+
+	// 修改任务优先级
+	router.PATCH("/api/tasks/:id", patchTask)
+
+	// 任务日志
+	router.GET("/api/tasks/:id/logs", taskLogs)
+
+	// 任务历史事件（状态变化、出错、重试）
+	router.GET("/api/tasks/:id/events", taskEvents)
+
+	// 失败任务的外部程序完整输出
+	router.GET("/api/tasks/:id/output", taskOutput)
+
+	// 下载和转录的汇总统计：?workspace=
+	router.GET("/api/stats", getStats)
+
+	// 搜索转录文本：?q=&limit=
+	router.GET("/api/search", searchTranscripts)
+	router.GET("/api/search/semantic", semanticSearch)
+
+	// 下载 / 在线播放输出文件
+	router.GET("/api/files", listFiles)
+	router.GET("/api/files/:id/download", downloadFile)
+
+	// 失败的转录留下的中间音频：GET 列出，DELETE 删除
+	router.GET("/api/maintenance/intermediates", requireAdmin, listIntermediates)
+	router.DELETE("/api/maintenance/intermediates", requireAdmin, removeIntermediates)
+	router.GET("/api/maintenance/partials", requireAdmin, listPartials)
+	router.DELETE("/api/maintenance/partials", requireAdmin, removePartials)
+
+	// OpenAPI 文档和 Swagger UI，放在最后以便列出所有路由
+	registerDocsRoutes(router)
+
+	slog.Info("服务启动 (Go 网关 + ffmpeg + Whisper)", "addr", serve.URL(cfg.Server.APIListen, cfg.TLSOptions()))
+	if err := serve.ListenAndServe(cfg.Server.APIListen, serve.Guard(router, cfg.MaxBody()), cfg.TLSOptions()); err != nil {
+		slog.Error("服务启动失败", "error", err)
+		os.Exit(1)
+	}
+}
+
+// pauseOrResume 暂停或继续下载任务，返回最新进度
+func pauseOrResume(c *gin.Context, action func(id string) error) {
+	id := c.Param("download_id")
+	if err := action(id); err != nil {
+		if errors.Is(err, tasks.ErrNotFound) {
+			failWith(c, errcode.NotFound, "任务不存在")
+			return
+		}
+		fail(c, errcode.Conflict, err)
+		return
+	}
+
+	task, _ := manager.Download(id)
+	c.JSON(200, newDownloadProgress(task))
+}
+
+// deleteTask 删除任务，?delete_files=true 时同时删除输出文件
+func deleteTask(c *gin.Context, id string) {
+	deleteFiles := c.Query("delete_files") == "true"
+	if err := manager.Delete(id, deleteFiles); err != nil {
+		// 任务不存在、正在执行等错误带有错误码
+		fail(c, errcode.Internal, err)
+		return
+	}
+	c.JSON(200, gin.H{"status": "deleted", "files_deleted": deleteFiles})
+}
+
+func newDownloadProgress(task *tasks.DownloadTask) downloadProgress {
+	return downloadProgress{
+		DownloadTask: task,
+		DownloadID:   task.ID,
+		Status:       legacyStatus(task.Status),
+	}
+}
+
+// legacyStatus 把任务状态转换为桌面端使用的 Starting/Downloading/Completed 等格式
+func legacyStatus(s tasks.Status) string {
+	if s == tasks.StatusPending {
+		return "Starting"
+	}
+	name := string(s)
+	return strings.ToUpper(name[:1]) + name[1:]
+}
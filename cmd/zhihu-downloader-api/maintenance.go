@@ -0,0 +1,66 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/diskspace"
+	"zhihu-downloader/internal/tasks"
+)
+
+// listIntermediates 列出失败的转录留下的中间音频和它们占用的空间，不删除文件
+func listIntermediates(c *gin.Context) {
+	files := manager.Intermediates()
+	c.JSON(200, intermediatesResponse(files, totalSize(files)))
+}
+
+// removeIntermediates 删除失败的转录留下的中间音频，返回删除的文件和释放的空间
+func removeIntermediates(c *gin.Context) {
+	files, freed := manager.RemoveIntermediates()
+	if len(files) > 0 {
+		slog.Info("已清理中间音频", "files", len(files), "freed", diskspace.Format(freed))
+	}
+	c.JSON(200, intermediatesResponse(files, freed))
+}
+
+func intermediatesResponse(files []tasks.Intermediate, size int64) gin.H {
+	if files == nil {
+		files = []tasks.Intermediate{}
+	}
+	return gin.H{"files": files, "count": len(files), "size": size, "size_text": diskspace.Format(size)}
+}
+
+func totalSize(files []tasks.Intermediate) int64 {
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+	return total
+}
+
+// listPartials 列出写入中断留下、不会再被续传的未完成文件（.part、HLS 分片等），不删除文件
+func listPartials(c *gin.Context) {
+	files := manager.PartialFiles()
+	var size int64
+	for _, f := range files {
+		size += f.Size
+	}
+	c.JSON(200, partialsResponse(files, size))
+}
+
+// removePartials 删除不会再被续传的未完成文件，返回删除的文件和释放的空间
+func removePartials(c *gin.Context) {
+	files, freed := manager.RemovePartialFiles()
+	if len(files) > 0 {
+		slog.Info("已清理未完成的文件", "files", len(files), "freed", diskspace.Format(freed))
+	}
+	c.JSON(200, partialsResponse(files, freed))
+}
+
+func partialsResponse(files []tasks.PartialFile, size int64) gin.H {
+	if files == nil {
+		files = []tasks.PartialFile{}
+	}
+	return gin.H{"files": files, "count": len(files), "size": size, "size_text": diskspace.Format(size)}
+}
@@ -0,0 +1,472 @@
+package main
+
+import (
+	_ "embed"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/auth"
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/health"
+	"zhihu-downloader/internal/openapi"
+	"zhihu-downloader/internal/tasks"
+	"zhihu-downloader/internal/transcriber"
+	"zhihu-downloader/internal/zhihu"
+)
+
+// swaggerHTML 浏览 /api/openapi.json 的 Swagger UI 页面（脚本和样式从 CDN 加载）
+//
+//go:embed web/swagger.html
+var swaggerHTML []byte
+
+// param 路径、查询或请求头参数
+type param struct {
+	Name string
+	// In path、query 或 header
+	In          string
+	Type        string
+	Description string
+}
+
+// operation 一个接口的文档。Body 和 Response 为请求体和响应的 Go 类型的零值，
+// Schema 由它们的 json 标签生成，与接口实际使用的类型一致
+type operation struct {
+	Method  string
+	Path    string
+	Tag     string
+	Summary string
+	Params  []param
+	Body    any
+	// Response 为 nil 时响应不是 JSON（例如文件、SSE），由 Produces 说明类型
+	Response any
+	Produces string
+	// Admin 配置了工作区时只允许管理员访问
+	Admin bool
+	// Public 不需要 API 密钥
+	Public bool
+}
+
+// errorResponse 所有接口出错时的响应。error 同 message，兼容之前的版本
+type errorResponse struct {
+	Error string `json:"error"`
+	errcode.Info
+}
+
+// statusResponse 取消、删除等操作的响应
+type statusResponse struct {
+	Status string `json:"status"`
+}
+
+// 常用的参数
+var (
+	taskIDParam      = param{"task_id", "path", "string", "任务 ID"}
+	downloadIDParam  = param{"download_id", "path", "string", "下载任务 ID"}
+	deleteFilesParam = param{"delete_files", "query", "boolean", "为 true 时同时删除输出文件"}
+	// idempotencyKeyParam 创建任务的接口支持的幂等键
+	idempotencyKeyParam = param{"Idempotency-Key", "header", "string", "幂等键：24 小时内用同一个键重复提交时返回第一次创建的任务，响应头 Idempotent-Replayed 为 true"}
+	// filterParams /api/tasks 和 /api/tasks/export 的筛选参数
+	filterParams = []param{
+		{"type", "query", "string", "任务类型 download / transcribe / pipeline / collection，逗号分隔"},
+		{"status", "query", "string", "任务状态，逗号分隔"},
+		{"since", "query", "string", "创建时间下限，RFC 3339 或 2006-01-02"},
+		{"until", "query", "string", "创建时间上限，RFC 3339 或 2006-01-02"},
+		{"search", "query", "string", "在链接、标题和文件路径中搜索"},
+	}
+)
+
+// operations 所有 /api 接口的文档，新增接口时在这里补充
+var operations = []operation{
+	{Method: "GET", Path: "/api/health", Tag: "system", Summary: "检查 ffmpeg、Whisper、数据库、下载目录和磁盘空间，必需的依赖缺失时返回 503", Response: healthResponse{}, Public: true},
+	{Method: "GET", Path: "/api/openapi.json", Tag: "system", Summary: "本文档", Response: map[string]any{}, Public: true},
+	{Method: "GET", Path: "/api/docs", Tag: "system", Summary: "浏览本文档的 Swagger UI", Produces: "text/html", Public: true},
+
+	{Method: "POST", Path: "/api/auth/cookies", Tag: "auth", Summary: "上传知乎登录 cookies（multipart 的 file 字段、JSON 或纯文本的 cookies.txt）", Body: cookiesRequest{}, Response: auth.Status{}, Admin: true},
+	{Method: "DELETE", Path: "/api/auth/cookies", Tag: "auth", Summary: "删除保存的 cookies", Response: auth.Status{}, Admin: true},
+	{Method: "GET", Path: "/api/auth/status", Tag: "auth", Summary: "登录状态、cookies 过期时间和请求知乎验证登录的结果（valid / expiring / expired）", Params: []param{
+		{"refresh", "query", "boolean", "立即重新验证登录，默认使用 10 分钟内的检查结果"},
+	}, Response: auth.Status{}},
+
+	{Method: "GET", Path: "/api/video/info", Tag: "download", Summary: "视频信息、可用清晰度和按 quality 会选择的清晰度", Params: []param{
+		{"url", "query", "string", "视频链接"}, {"quality", "query", "string", "清晰度，默认使用配置"},
+	}, Response: videoInfoResponse{}},
+	{Method: "GET", Path: "/api/resolve", Tag: "download", Summary: "识别链接类型并返回规范化的链接，不能下载的链接返回 400", Params: []param{
+		{"url", "query", "string", "链接或带标题的分享文本"},
+	}, Response: zhihu.Link{}},
+	{Method: "POST", Path: "/api/download", Tag: "download", Summary: "下载视频", Params: []param{idempotencyKeyParam}, Body: downloadRequest{}, Response: downloadStarted{}},
+	{Method: "POST", Path: "/api/import", Tag: "download", Summary: "把已有的本地视频登记为已完成的下载任务，之后可以转录、搜索和统计", Body: importRequest{}, Response: downloadProgress{}},
+	{Method: "POST", Path: "/api/clip", Tag: "download", Summary: "截取视频的一段，作为单独的任务排队执行（先直接复制流，失败时重新编码）", Params: []param{idempotencyKeyParam}, Body: clipRequest{}, Response: downloadProgress{}},
+	{Method: "GET", Path: "/api/progress/{download_id}", Tag: "download", Summary: "下载进度", Params: []param{downloadIDParam}, Response: downloadProgress{}},
+	{Method: "GET", Path: "/api/progress/{download_id}/stream", Tag: "download", Summary: "通过 Server-Sent Events 推送下载进度", Params: []param{downloadIDParam}, Produces: "text/event-stream"},
+	{Method: "POST", Path: "/api/download/{download_id}/cancel", Tag: "download", Summary: "取消下载", Params: []param{downloadIDParam}, Response: statusResponse{}},
+	{Method: "POST", Path: "/api/download/{download_id}/retry", Tag: "download", Summary: "重试失败、取消或被中断的下载", Params: []param{downloadIDParam}, Response: downloadProgress{}},
+	{Method: "GET", Path: "/api/download/{download_id}/content", Tag: "download", Summary: "文件内容的 SHA-256 和下载过同一内容的所有链接（内容相同的下载替换为硬链接或符号链接）", Params: []param{downloadIDParam}, Response: tasks.ContentInfo{}},
+	{Method: "POST", Path: "/api/download/{download_id}/pause", Tag: "download", Summary: "暂停下载，保留已下载的分片", Params: []param{downloadIDParam}, Response: downloadProgress{}},
+	{Method: "POST", Path: "/api/download/{download_id}/resume", Tag: "download", Summary: "继续暂停的下载，m3u8 从已完成的分片继续", Params: []param{downloadIDParam}, Response: downloadProgress{}},
+	{Method: "DELETE", Path: "/api/download/{download_id}", Tag: "download", Summary: "删除下载任务", Params: []param{downloadIDParam, deleteFilesParam}, Response: deleteResponse{}},
+
+	{Method: "POST", Path: "/api/transcribe", Tag: "transcribe", Summary: "转录本地视频", Params: []param{idempotencyKeyParam}, Body: transcribeRequest{}, Response: transcribeStarted{}},
+	{Method: "POST", Path: "/api/transcribe/batch", Tag: "transcribe", Summary: "转录目录中匹配的所有视频，跳过已有 .txt / .srt 或已经转录的文件", Params: []param{idempotencyKeyParam}, Body: transcribeBatchRequest{}, Response: tasks.TranscribeBatch{}},
+	{Method: "GET", Path: "/api/transcribe/batch/{task_id}", Tag: "transcribe", Summary: "批量转录的状态和汇总进度", Params: []param{taskIDParam}, Response: tasks.TranscribeBatch{}},
+	{Method: "POST", Path: "/api/transcribe/batch/{task_id}/cancel", Tag: "transcribe", Summary: "取消批量转录中还没有结束的转录", Params: []param{taskIDParam}, Response: tasks.TranscribeBatch{}},
+	{Method: "POST", Path: "/api/summarize", Tag: "transcribe", Summary: "为转录文本生成摘要，task_id 和 txt_path 至少指定一个", Body: summarizeRequest{}, Response: summarizeResponse{}},
+	{Method: "POST", Path: "/api/highlights", Tag: "transcribe", Summary: "在转录文稿中查找关键词或请大模型挑选精彩片段，可以剪成单独的视频", Body: highlightsRequest{}, Response: tasks.HighlightResult{}},
+	{Method: "GET", Path: "/api/transcribe/backends", Tag: "transcribe", Summary: "本机的硬件和可用的 Whisper 后端", Response: backendsResponse{}},
+	{Method: "GET", Path: "/api/transcribe/models", Tag: "transcribe", Summary: "可选的 Whisper 模型和安装情况", Response: modelsResponse{}},
+	{Method: "GET", Path: "/api/transcribe/{task_id}", Tag: "transcribe", Summary: "转录进度", Params: []param{taskIDParam}, Response: transcribeProgress{}},
+	{Method: "GET", Path: "/api/transcribe/{task_id}/transcript", Tag: "transcribe", Summary: "逐段和逐词时间的转录结果", Params: []param{taskIDParam}, Response: transcriptResponse{}},
+	{Method: "GET", Path: "/api/transcribe/{task_id}/stream", Tag: "transcribe", Summary: "通过 Server-Sent Events 实时推送识别出的每一段文稿，转录结束时发送最终状态", Params: []param{taskIDParam}, Produces: "text/event-stream"},
+	{Method: "DELETE", Path: "/api/transcribe/{task_id}", Tag: "transcribe", Summary: "删除转录任务", Params: []param{taskIDParam, deleteFilesParam}, Response: deleteResponse{}},
+
+	{Method: "POST", Path: "/api/pipeline", Tag: "pipeline", Summary: "下载后自动转录", Params: []param{idempotencyKeyParam}, Body: pipelineRequest{}, Response: pipelineStarted{}},
+	{Method: "GET", Path: "/api/pipeline/{task_id}", Tag: "pipeline", Summary: "流水线进度", Params: []param{taskIDParam}, Response: tasks.PipelineTask{}},
+	{Method: "GET", Path: "/api/pipeline/{task_id}/stream", Tag: "pipeline", Summary: "通过 Server-Sent Events 推送流水线进度", Params: []param{taskIDParam}, Produces: "text/event-stream"},
+	{Method: "GET", Path: "/api/pipeline/{task_id}/transcript", Tag: "pipeline", Summary: "逐段和逐词时间的转录结果", Params: []param{taskIDParam}, Response: transcriptResponse{}},
+	{Method: "POST", Path: "/api/pipeline/{task_id}/cancel", Tag: "pipeline", Summary: "取消流水线", Params: []param{taskIDParam}, Response: statusResponse{}},
+	{Method: "POST", Path: "/api/pipeline/{task_id}/retry", Tag: "pipeline", Summary: "重试流水线", Params: []param{taskIDParam}, Response: tasks.PipelineTask{}},
+	{Method: "DELETE", Path: "/api/pipeline/{task_id}", Tag: "pipeline", Summary: "删除流水线任务", Params: []param{taskIDParam, deleteFilesParam}, Response: deleteResponse{}},
+
+	{Method: "POST", Path: "/api/collection", Tag: "collection", Summary: "下载专栏、收藏夹、问题或用户主页中的所有视频", Params: []param{idempotencyKeyParam}, Body: collectionRequest{}, Response: collectionStarted{}},
+	{Method: "GET", Path: "/api/collection/{task_id}", Tag: "collection", Summary: "合集下载进度", Params: []param{taskIDParam}, Response: tasks.CollectionTask{}},
+	{Method: "GET", Path: "/api/collection/{task_id}/stream", Tag: "collection", Summary: "通过 Server-Sent Events 推送合集下载进度", Params: []param{taskIDParam}, Produces: "text/event-stream"},
+	{Method: "POST", Path: "/api/collection/{task_id}/cancel", Tag: "collection", Summary: "取消合集下载", Params: []param{taskIDParam}, Response: statusResponse{}},
+	{Method: "POST", Path: "/api/collection/{task_id}/retry", Tag: "collection", Summary: "重试合集中失败的视频", Params: []param{taskIDParam}, Response: tasks.CollectionTask{}},
+	{Method: "DELETE", Path: "/api/collection/{task_id}", Tag: "collection", Summary: "删除合集任务", Params: []param{taskIDParam, deleteFilesParam}, Response: deleteResponse{}},
+
+	{Method: "GET", Path: "/api/schedules", Tag: "schedule", Summary: "计划任务列表", Response: schedulesResponse{}},
+	{Method: "POST", Path: "/api/schedules", Tag: "schedule", Summary: "创建计划任务", Body: scheduleRequest{}, Response: tasks.Schedule{}},
+	{Method: "GET", Path: "/api/schedules/{id}", Tag: "schedule", Summary: "计划任务详情", Params: []param{{"id", "path", "string", "计划任务 ID"}}, Response: tasks.Schedule{}},
+	{Method: "PUT", Path: "/api/schedules/{id}", Tag: "schedule", Summary: "修改计划任务，未提供的字段保持不变", Params: []param{{"id", "path", "string", "计划任务 ID"}}, Body: scheduleRequest{}, Response: tasks.Schedule{}},
+	{Method: "DELETE", Path: "/api/schedules/{id}", Tag: "schedule", Summary: "删除计划任务", Params: []param{{"id", "path", "string", "计划任务 ID"}}, Response: statusResponse{}},
+
+	{Method: "GET", Path: "/api/subscriptions", Tag: "subscription", Summary: "订阅列表", Response: subscriptionsResponse{}},
+	{Method: "POST", Path: "/api/subscriptions", Tag: "subscription", Summary: "订阅知乎用户或专栏，定期下载新发布的视频", Body: subscriptionRequest{}, Response: tasks.Subscription{}},
+	{Method: "GET", Path: "/api/subscriptions/{id}", Tag: "subscription", Summary: "订阅详情", Params: []param{{"id", "path", "string", "订阅 ID"}}, Response: tasks.Subscription{}},
+	{Method: "PUT", Path: "/api/subscriptions/{id}", Tag: "subscription", Summary: "修改订阅，未提供的字段保持不变", Params: []param{{"id", "path", "string", "订阅 ID"}}, Body: subscriptionRequest{}, Response: tasks.Subscription{}},
+	{Method: "DELETE", Path: "/api/subscriptions/{id}", Tag: "subscription", Summary: "删除订阅，已创建的任务不受影响", Params: []param{{"id", "path", "string", "订阅 ID"}}, Response: statusResponse{}},
+	{Method: "POST", Path: "/api/subscriptions/{id}/check", Tag: "subscription", Summary: "立即检查订阅，为新视频创建任务", Params: []param{{"id", "path", "string", "订阅 ID"}}, Response: tasks.SubscriptionCheck{}},
+
+	{Method: "GET", Path: "/api/tasks", Tag: "tasks", Summary: "任务列表", Params: withFilters(
+		param{"limit", "query", "integer", "每页的任务数"}, param{"offset", "query", "integer", "跳过的任务数"}), Response: tasks.Page{}},
+	{Method: "GET", Path: "/api/tasks/export", Tag: "tasks", Summary: "导出任务历史（format=csv 时为 CSV 文件）", Params: withFilters(
+		param{"format", "query", "string", "csv 或 json"}), Response: tasks.Report{}},
+	{Method: "PATCH", Path: "/api/tasks/{id}", Tag: "tasks", Summary: "修改未结束任务的优先级，排队中的下载按新的优先级调整位置", Params: []param{
+		{"id", "path", "string", "任务 ID"},
+	}, Body: taskPatchRequest{}, Response: taskPatchResponse{}},
+	{Method: "GET", Path: "/api/tasks/{id}/logs", Tag: "tasks", Summary: "任务最近的日志", Params: []param{
+		{"id", "path", "string", "任务 ID"}, {"lines", "query", "integer", "最多返回的行数"},
+	}, Response: logsResponse{}},
+	{Method: "GET", Path: "/api/tasks/{id}/events", Tag: "tasks", Summary: "任务的历史事件：创建、状态变化、出错和重试", Params: []param{
+		{"id", "path", "string", "任务 ID"},
+	}, Response: eventsResponse{}},
+	{Method: "GET", Path: "/api/tasks/{id}/output", Tag: "tasks", Summary: "失败任务保存的外部程序完整输出（纯文本）", Params: []param{
+		{"id", "path", "string", "任务 ID"},
+	}, Produces: "text/plain"},
+	{Method: "GET", Path: "/api/stats", Tag: "tasks", Summary: "下载和转录的汇总统计：成功率、归档总大小、平均速度、转录时长、下载最多的几天", Params: []param{
+		{"workspace", "query", "string", "只统计这个工作区（只能访问自己工作区时忽略）"},
+	}, Response: tasks.Stats{}},
+	{Method: "GET", Path: "/api/search", Tag: "tasks", Summary: "搜索转录文本", Params: []param{
+		{"q", "query", "string", "关键词，空白分隔"}, {"limit", "query", "integer", "最多返回的任务数（默认 20，最大 100）"},
+	}, Response: searchResponse{}},
+	{Method: "GET", Path: "/api/search/semantic", Tag: "tasks", Summary: "按意思搜索转录（需要配置 embedding）", Params: []param{
+		{"q", "query", "string", "要找的内容，一句话描述即可"}, {"limit", "query", "integer", "最多返回的任务数（默认 20，最大 100）"},
+	}, Response: searchResponse{}},
+
+	{Method: "GET", Path: "/api/files", Tag: "files", Summary: "输出目录中的视频、音频、文本和图片文件", Params: []param{
+		{"kind", "query", "string", "video / audio / text / image / partial"},
+	}, Response: filesResponse{}},
+	{Method: "GET", Path: "/api/files/{id}/download", Tag: "files", Summary: "下载或在线播放文件，支持 Range 请求", Params: []param{
+		{"id", "path", "string", "/api/files 返回的文件 ID，或任务 ID"},
+		{"type", "query", "string", "按任务 ID 下载时的文件类型：video / thumbnail / sprite / nfo / comments / comments_json / mp3 / txt / srt / json / summary / subtitled"},
+		{"attachment", "query", "string", "不为空时浏览器保存为文件"},
+	}, Produces: "application/octet-stream"},
+
+	{Method: "GET", Path: "/api/maintenance/intermediates", Tag: "maintenance", Summary: "失败的转录留下的中间音频", Response: intermediatesList{}, Admin: true},
+	{Method: "DELETE", Path: "/api/maintenance/intermediates", Tag: "maintenance", Summary: "删除失败的转录留下的中间音频", Response: intermediatesList{}, Admin: true},
+	{Method: "GET", Path: "/api/maintenance/partials", Tag: "maintenance", Summary: "写入中断留下的未完成文件", Response: partialsList{}, Admin: true},
+	{Method: "DELETE", Path: "/api/maintenance/partials", Tag: "maintenance", Summary: "删除写入中断留下的未完成文件", Response: partialsList{}, Admin: true},
+}
+
+// withFilters 筛选参数加上 extra
+func withFilters(extra ...param) []param {
+	return append(append([]param{}, filterParams...), extra...)
+}
+
+// 以下类型只用于生成文档，描述用 gin.H 返回的响应
+
+type healthResponse struct {
+	health.Report
+	Authenticated bool               `json:"authenticated"`
+	Downloads     queueStats         `json:"downloads"`
+	Transcribe    transcriber.Status `json:"transcribe"`
+	Tools         []health.Tool      `json:"tools"`
+}
+
+type queueStats struct {
+	Running int `json:"running"`
+	Queued  int `json:"queued"`
+	Limit   int `json:"limit"`
+}
+
+type videoInfoResponse struct {
+	Video    *zhihu.VideoInfo `json:"video"`
+	Selected *zhihu.Rendition `json:"selected"`
+}
+
+type downloadStarted struct {
+	DownloadID string `json:"download_id"`
+	Cached     bool   `json:"cached"`
+}
+
+type transcribeStarted struct {
+	TaskID string `json:"task_id"`
+	Model  string `json:"model"`
+}
+
+type pipelineStarted struct {
+	TaskID     string `json:"task_id"`
+	DownloadID string `json:"download_id"`
+}
+
+type collectionStarted struct {
+	TaskID string `json:"task_id"`
+}
+
+type deleteResponse struct {
+	Status       string `json:"status"`
+	FilesDeleted bool   `json:"files_deleted"`
+}
+
+type summarizeResponse struct {
+	SummaryPath string `json:"summary_path"`
+	Summary     string `json:"summary"`
+}
+
+type backendsResponse struct {
+	Hardware transcriber.Hardware        `json:"hardware"`
+	Backends []transcriber.BackendStatus `json:"backends"`
+}
+
+type modelsResponse struct {
+	Backend      string                    `json:"backend"`
+	Default      string                    `json:"default"`
+	AutoDownload bool                      `json:"auto_download"`
+	Models       []transcriber.ModelStatus `json:"models"`
+}
+
+type schedulesResponse struct {
+	Schedules []*tasks.Schedule `json:"schedules"`
+}
+
+type subscriptionsResponse struct {
+	Subscriptions []*tasks.Subscription `json:"subscriptions"`
+}
+
+type logsResponse struct {
+	TaskID string   `json:"task_id"`
+	Lines  []string `json:"lines"`
+}
+
+type eventsResponse struct {
+	TaskID string            `json:"task_id"`
+	Events []tasks.TaskEvent `json:"events"`
+}
+
+type searchResponse struct {
+	Query   string               `json:"query"`
+	Results []tasks.SearchResult `json:"results"`
+}
+
+type filesResponse struct {
+	Dir   string      `json:"dir"`
+	Files []fileEntry `json:"files"`
+}
+
+type intermediatesList struct {
+	Files    []tasks.Intermediate `json:"files"`
+	Count    int                  `json:"count"`
+	Size     int64                `json:"size"`
+	SizeText string               `json:"size_text"`
+}
+
+type partialsList struct {
+	Files    []tasks.PartialFile `json:"files"`
+	Count    int                 `json:"count"`
+	Size     int64               `json:"size"`
+	SizeText string              `json:"size_text"`
+}
+
+var (
+	specOnce sync.Once
+	spec     map[string]any
+)
+
+// registerDocsRoutes 在 /api/openapi.json 提供 OpenAPI 3 文档，在 /api/docs 提供 Swagger UI。
+// 需要在注册完其他路由之后调用，没有写入 operations 的路由也会列出（只有路径和方法）
+func registerDocsRoutes(router *gin.Engine) {
+	router.GET("/api/openapi.json", func(c *gin.Context) {
+		specOnce.Do(func() { spec = buildSpec(router.Routes()) })
+		c.JSON(200, spec)
+	})
+	router.GET("/api/docs", func(c *gin.Context) {
+		c.Header("Cache-Control", "no-cache")
+		c.Data(200, "text/html; charset=utf-8", swaggerHTML)
+	})
+}
+
+// buildSpec 根据 operations 和实际注册的路由生成 OpenAPI 文档
+func buildSpec(routes gin.RoutesInfo) map[string]any {
+	g := openapi.NewGenerator()
+	g.Enum(tasks.Status(""),
+		string(tasks.StatusPending), string(tasks.StatusQueued), string(tasks.StatusPaused), string(tasks.StatusDownloading),
+		string(tasks.StatusTranscoding), string(tasks.StatusExtractingAudio), string(tasks.StatusTranscribing), string(tasks.StatusCompleted),
+		string(tasks.StatusFailed), string(tasks.StatusCancelled), string(tasks.StatusInterrupted))
+	g.Enum(tasks.Priority(""), string(tasks.PriorityHigh), string(tasks.PriorityNormal), string(tasks.PriorityLow))
+	g.Enum(tasks.Kind(""),
+		string(tasks.KindDownload), string(tasks.KindTranscribe), string(tasks.KindPipeline), string(tasks.KindCollection))
+	errSchema := g.Schema(errorResponse{})
+
+	paths := map[string]map[string]any{}
+	documented := map[string]bool{}
+	for _, op := range operations {
+		documented[op.Method+" "+op.Path] = true
+		if paths[op.Path] == nil {
+			paths[op.Path] = map[string]any{}
+		}
+		paths[op.Path][strings.ToLower(op.Method)] = op.spec(g, errSchema)
+	}
+
+	// 没有写入 operations 的路由只列出路径和方法
+	for _, r := range routes {
+		if !strings.HasPrefix(r.Path, "/api/") {
+			continue
+		}
+		path := ginPathToOpenAPI(r.Path)
+		if documented[r.Method+" "+path] || r.Method == "HEAD" {
+			continue
+		}
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		op := operation{Method: r.Method, Path: path, Tag: "other"}
+		for _, seg := range strings.Split(r.Path, "/") {
+			if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+				op.Params = append(op.Params, param{Name: seg[1:], In: "path", Type: "string"})
+			}
+		}
+		paths[path][strings.ToLower(r.Method)] = op.spec(g, errSchema)
+	}
+
+	return map[string]any{
+		"openapi": openapi.Version,
+		"info": map[string]any{
+			"title":       "知乎视频下载 API",
+			"version":     "1.0.0",
+			"description": "下载知乎视频、转录为文字和管理任务。配置了工作区时需要用 Authorization: Bearer 或 X-API-Key 提供 API 密钥。",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": g.Components(),
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+				"apiKey": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+		// 没有配置工作区时不需要密钥，所以空的要求也满足
+		"security": []any{map[string]any{}, map[string]any{"bearer": []string{}}, map[string]any{"apiKey": []string{}}},
+		"tags":     tagList(),
+	}
+}
+
+// spec 一个接口的 Operation Object
+func (op operation) spec(g *openapi.Generator, errSchema map[string]any) map[string]any {
+	out := map[string]any{
+		"tags":        []string{op.Tag},
+		"operationId": operationID(op.Method, op.Path),
+	}
+	if op.Summary != "" {
+		out["summary"] = op.Summary
+	}
+	if op.Admin {
+		out["description"] = "配置了工作区时只允许管理员工作区访问。"
+	}
+	if op.Public {
+		out["security"] = []any{}
+	}
+
+	var params []any
+	for _, p := range op.Params {
+		params = append(params, map[string]any{
+			"name":        p.Name,
+			"in":          p.In,
+			"required":    p.In == "path",
+			"description": p.Description,
+			"schema":      map[string]any{"type": p.Type},
+		})
+	}
+	if len(params) > 0 {
+		out["parameters"] = params
+	}
+	if op.Body != nil {
+		out["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": g.Schema(op.Body)}},
+		}
+	}
+
+	ok := map[string]any{"description": "成功"}
+	switch {
+	case op.Response != nil:
+		ok["content"] = map[string]any{"application/json": map[string]any{"schema": g.Schema(op.Response)}}
+	case op.Produces != "":
+		ok["content"] = map[string]any{op.Produces: map[string]any{"schema": map[string]any{"type": "string"}}}
+	}
+	errContent := map[string]any{"application/json": map[string]any{"schema": errSchema}}
+	out["responses"] = map[string]any{
+		"200":     ok,
+		"default": map[string]any{"description": "出错，code 为错误码，message 为原因，detail 为外部程序输出等细节", "content": errContent},
+	}
+	return out
+}
+
+// ginPathToOpenAPI 把 gin 的 /api/files/:id/download 转换为 /api/files/{id}/download
+func ginPathToOpenAPI(path string) string {
+	segs := strings.Split(path, "/")
+	for i, seg := range segs {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			segs[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segs, "/")
+}
+
+// operationID 生成代码时使用的方法名，例如 POST /api/download/{download_id}/cancel → postDownloadDownloadIdCancel
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, seg := range strings.Split(strings.TrimPrefix(path, "/api"), "/") {
+		seg = strings.Trim(seg, "{}")
+		for _, part := range strings.FieldsFunc(seg, func(r rune) bool { return r == '_' || r == '.' || r == '-' }) {
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return b.String()
+}
+
+// tagList 按 operations 中出现的顺序列出分组
+func tagList() []any {
+	seen := map[string]bool{}
+	var names []string
+	for _, op := range operations {
+		if !seen[op.Tag] {
+			seen[op.Tag] = true
+			names = append(names, op.Tag)
+		}
+	}
+	tags := make([]any, len(names))
+	for i, name := range names {
+		tags[i] = map[string]any{"name": name}
+	}
+	return tags
+}
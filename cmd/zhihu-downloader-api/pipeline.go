@@ -0,0 +1,176 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/media"
+	"zhihu-downloader/internal/ratelimit"
+	"zhihu-downloader/internal/tasks"
+	"zhihu-downloader/internal/transcriber"
+)
+
+// pipelineRequest POST /api/pipeline 的请求体
+type pipelineRequest struct {
+	URL        string `json:"url" binding:"required"`
+	Quality    string `json:"quality"`
+	OutputPath string `json:"output_path"`
+	Backend    string `json:"backend"`
+	Language   string `json:"language"`
+	Diarize    bool   `json:"diarize"`
+	Summarize  bool   `json:"summarize"`
+	// Model Whisper 模型 tiny / base / small / medium / large-v3
+	Model string `json:"model"`
+	// FilenameTemplate 文件名模板，例如 {title}_{quality}_{date}
+	FilenameTemplate string `json:"filename_template"`
+	// Force 已下载过同一视频时仍然重新下载
+	Force bool `json:"force"`
+	// Comments 下载完成后保存知乎评论，最多 CommentsLimit 条根评论（0 表示全部）
+	Comments      bool `json:"comments"`
+	CommentsLimit int  `json:"comments_limit"`
+	// MaxRate 下载速度上限，例如 2M、500K，为空时只受全局上限限制
+	MaxRate string `json:"max_rate"`
+	// Connections m3u8 同时下载的分片数（1–16），默认使用配置 download.connections
+	Connections int `json:"connections"`
+	// SubtitleMode 转录后把字幕封装（mux）或烧录（burn）进视频，默认 none
+	SubtitleMode string `json:"subtitle_mode"`
+	// Transcode 下载完成后转码为 h264 / h265 / av1，none 表示不转码，默认使用配置 transcode.codec；
+	// MaxHeight 为转码的分辨率上限，CRF 为转码画质
+	Transcode string `json:"transcode"`
+	MaxHeight int    `json:"max_height"`
+	CRF       int    `json:"crf"`
+	// HWAccel 转码和烧录字幕时的硬件加速方式 none / videotoolbox / nvenc / vaapi，默认使用配置 tools.hwaccel
+	HWAccel string `json:"hwaccel"`
+	// FFmpegArgs 生成 MP4 和处理字幕时追加的 ffmpeg 输出选项（白名单），例如 ["-crf", "23"]
+	FFmpegArgs []string `json:"ffmpeg_args"`
+	// Headers 下载时附加的请求头，例如 {"Authorization": "Bearer ..."}；Cookies 附加的 cookies，例如 {"token": "..."}。
+	// 只用于本次下载（包括暂停后继续和重试），加密保存，下载完成后清除，不在任务详情中返回
+	Headers map[string]string `json:"headers"`
+	Cookies map[string]string `json:"cookies"`
+	// AudioFormat 提取的音频格式 wav / mp3 / m4a / flac，AudioQuality 为 mp3 / m4a 的码率
+	AudioFormat  string `json:"audio_format"`
+	AudioQuality string `json:"audio_quality"`
+	// KeepIntermediate 转录成功后保留提取的音频，默认使用配置 transcribe.keep_intermediate
+	KeepIntermediate *bool `json:"keep_intermediate"`
+	// InitialPrompt / Vocabulary 转录时传给 Whisper 的提示文本和专业词汇，见 POST /api/transcribe
+	InitialPrompt string   `json:"initial_prompt"`
+	Vocabulary    []string `json:"vocabulary"`
+	// Notify 结束时的通知目标：配置的通知渠道名称、webhook 地址或 none，为空时发给所有渠道
+	Notify []string `json:"notify"`
+	// Priority 优先级 high / normal / low（默认 normal），排队的下载中优先级高的先开始
+	Priority string `json:"priority"`
+}
+
+// registerPipelineRoutes 下载后自动转录的流水线任务
+func registerPipelineRoutes(router *gin.Engine) {
+	router.POST("/api/pipeline", func(c *gin.Context) {
+		var req pipelineRequest
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			fail(c, errcode.InvalidArgument, err)
+			return
+		}
+
+		if req.Quality == "" {
+			req.Quality = cfg.Quality("hd")
+		}
+		maxRate, err := ratelimit.Parse(req.MaxRate)
+		if err != nil {
+			fail(c, errcode.InvalidArgument, err)
+			return
+		}
+
+		id, err := idempotent(c, tasks.KindPipeline, func() (string, error) {
+			task, err := manager.StartPipeline(downloader.Request{
+				URL:       req.URL,
+				Quality:   req.Quality,
+				OutputDir: req.OutputPath,
+				Backend:   req.Backend,
+				MaxRate:   maxRate,
+				Force:     req.Force,
+				Comments:  req.Comments,
+				Workspace: workspaceName(c),
+				Notify:    req.Notify,
+				Priority:  req.Priority,
+
+				Connections:      req.Connections,
+				CommentsLimit:    req.CommentsLimit,
+				FilenameTemplate: req.FilenameTemplate,
+				FFmpegArgs:       req.FFmpegArgs,
+				HWAccel:          req.HWAccel,
+				Headers:          req.Headers,
+				Cookies:          req.Cookies,
+				Transcode:        media.TranscodeOptions{Codec: req.Transcode, MaxHeight: req.MaxHeight, CRF: req.CRF},
+			}, transcriber.Request{
+				Language:  req.Language,
+				Diarize:   req.Diarize,
+				Summarize: req.Summarize,
+				Model:     req.Model,
+
+				AudioFormat:      req.AudioFormat,
+				AudioQuality:     req.AudioQuality,
+				KeepIntermediate: req.KeepIntermediate,
+				InitialPrompt:    req.InitialPrompt,
+				Vocabulary:       req.Vocabulary,
+			}, req.SubtitleMode)
+			if err != nil {
+				return "", err
+			}
+			return task.ID, nil
+		})
+		if err != nil {
+			fail(c, errcode.InvalidArgument, err)
+			return
+		}
+		task, err := manager.Pipeline(id)
+		if err != nil {
+			failWith(c, errcode.NotFound, "任务不存在")
+			return
+		}
+
+		c.JSON(200, gin.H{"task_id": task.ID, "download_id": task.DownloadID})
+	})
+
+	router.GET("/api/pipeline/:task_id", func(c *gin.Context) {
+		task, err := manager.Pipeline(c.Param("task_id"))
+		if err != nil {
+			failWith(c, errcode.NotFound, "任务不存在")
+			return
+		}
+
+		c.JSON(200, task)
+	})
+
+	router.GET("/api/pipeline/:task_id/stream", streamProgress)
+	router.GET("/api/pipeline/:task_id/transcript", transcript)
+
+	router.POST("/api/pipeline/:task_id/cancel", func(c *gin.Context) {
+		manager.Cancel(c.Param("task_id"))
+		c.JSON(200, gin.H{"status": "cancelled"})
+	})
+
+	router.POST("/api/pipeline/:task_id/retry", func(c *gin.Context) {
+		id := c.Param("task_id")
+		if _, err := manager.Pipeline(id); err != nil {
+			failWith(c, errcode.NotFound, "任务不存在")
+			return
+		}
+		if err := manager.Retry(id); err != nil {
+			fail(c, errcode.Conflict, err)
+			return
+		}
+
+		task, _ := manager.Pipeline(id)
+		c.JSON(200, task)
+	})
+
+	router.DELETE("/api/pipeline/:task_id", func(c *gin.Context) {
+		id := c.Param("task_id")
+		if _, err := manager.Pipeline(id); err != nil {
+			failWith(c, errcode.NotFound, "任务不存在")
+			return
+		}
+		deleteTask(c, id)
+	})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/tasks"
+)
+
+// taskPatchRequest PATCH /api/tasks/:id 的请求体
+type taskPatchRequest struct {
+	// Priority 新的优先级 high / normal / low，排队中的下载按新的优先级调整位置；
+	// 流水线和合集任务同时修改未结束的子任务
+	Priority string `json:"priority" binding:"required"`
+}
+
+type taskPatchResponse struct {
+	ID       string         `json:"id"`
+	Priority tasks.Priority `json:"priority"`
+	// QueuePosition 修改后下载（或流水线的下载子任务）在队列中的位置，未排队时为 0
+	QueuePosition int `json:"queue_position"`
+}
+
+// patchTask 修改未结束任务的优先级
+func patchTask(c *gin.Context) {
+	var req taskPatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, errcode.InvalidArgument, err)
+		return
+	}
+	priority, err := tasks.ParsePriority(req.Priority)
+	if err != nil {
+		fail(c, errcode.InvalidArgument, err)
+		return
+	}
+
+	id := c.Param("id")
+	if err := manager.SetPriority(id, priority); err != nil {
+		fail(c, errcode.Conflict, err)
+		return
+	}
+
+	resp := taskPatchResponse{ID: id, Priority: priority}
+	downloadID := id
+	if p, err := manager.Pipeline(id); err == nil {
+		downloadID = p.DownloadID
+	}
+	if d, err := manager.Download(downloadID); err == nil {
+		resp.QueuePosition = d.QueuePosition
+	}
+	c.JSON(200, resp)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/tasks"
+)
+
+// scheduleRequest 创建和修改计划任务的参数，修改时未提供的字段保持不变
+type scheduleRequest struct {
+	// Type download 或 collection，默认按链接判断
+	Type             *string `json:"type"`
+	URL              *string `json:"url"`
+	Quality          *string `json:"quality"`
+	OutputPath       *string `json:"output_path"`
+	Backend          *string `json:"backend"`
+	FilenameTemplate *string `json:"filename_template"`
+	// Limit 合集最多下载的视频数
+	Limit *int `json:"limit"`
+	// StartAt RFC 3339 或本地时间 2006-01-02 15:04，空字符串表示清除
+	StartAt *string `json:"start_at"`
+	// Cron 5 段 cron 表达式，例如 "0 3 * * *"，空字符串表示清除
+	Cron    *string `json:"cron"`
+	Enabled *bool   `json:"enabled"`
+}
+
+// apply 把请求中提供的字段写入 s
+func (r *scheduleRequest) apply(s *tasks.Schedule) error {
+	if r.Type != nil {
+		s.Type = tasks.Kind(*r.Type)
+	}
+	if r.URL != nil {
+		s.URL = *r.URL
+	}
+	if r.Quality != nil {
+		s.Quality = *r.Quality
+	}
+	if r.OutputPath != nil {
+		s.OutputDir = *r.OutputPath
+	}
+	if r.Backend != nil {
+		s.Backend = *r.Backend
+	}
+	if r.FilenameTemplate != nil {
+		s.FilenameTemplate = *r.FilenameTemplate
+	}
+	if r.Limit != nil {
+		s.Limit = *r.Limit
+	}
+	if r.StartAt != nil {
+		s.StartAt = nil
+		if *r.StartAt != "" {
+			t, err := parseStartAt(*r.StartAt)
+			if err != nil {
+				return err
+			}
+			s.StartAt = &t
+		}
+	}
+	if r.Cron != nil {
+		s.Cron = *r.Cron
+	}
+	if r.Enabled != nil {
+		s.Enabled = *r.Enabled
+	}
+	return nil
+}
+
+// parseStartAt 解析 RFC 3339 时间，或按服务所在时区解析 2006-01-02 15:04[:05]
+func parseStartAt(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02T15:04:05"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("start_at 格式无效（RFC 3339 或 2006-01-02 15:04）: %s", s)
+}
+
+// registerScheduleRoutes 计划任务：定时下载一次，或按 cron 表达式定期下载
+func registerScheduleRoutes(router *gin.Engine) {
+	router.GET("/api/schedules", func(c *gin.Context) {
+		list := manager.Schedules()
+		if restricted(c) {
+			visible := list[:0]
+			for _, s := range list {
+				if s.Workspace == workspaceName(c) {
+					visible = append(visible, s)
+				}
+			}
+			list = visible
+		}
+		c.JSON(200, gin.H{"schedules": list})
+	})
+
+	router.POST("/api/schedules", func(c *gin.Context) {
+		var req scheduleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			fail(c, errcode.InvalidArgument, err)
+			return
+		}
+
+		s := tasks.Schedule{Quality: cfg.Quality("hd"), Enabled: true, Workspace: workspaceName(c)}
+		if err := req.apply(&s); err != nil {
+			fail(c, errcode.InvalidArgument, err)
+			return
+		}
+		schedule, err := manager.CreateSchedule(s)
+		if err != nil {
+			fail(c, errcode.InvalidArgument, err)
+			return
+		}
+
+		c.JSON(200, schedule)
+	})
+
+	router.GET("/api/schedules/:id", func(c *gin.Context) {
+		schedule, err := manager.Schedule(c.Param("id"))
+		if err != nil {
+			failWith(c, errcode.NotFound, "计划任务不存在")
+			return
+		}
+
+		c.JSON(200, schedule)
+	})
+
+	router.PUT("/api/schedules/:id", func(c *gin.Context) {
+		id := c.Param("id")
+		s, err := manager.Schedule(id)
+		if err != nil {
+			failWith(c, errcode.NotFound, "计划任务不存在")
+			return
+		}
+		var req scheduleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			fail(c, errcode.InvalidArgument, err)
+			return
+		}
+		if err := req.apply(s); err != nil {
+			fail(c, errcode.InvalidArgument, err)
+			return
+		}
+
+		schedule, err := manager.UpdateSchedule(id, *s)
+		switch {
+		case errors.Is(err, tasks.ErrNotFound):
+			failWith(c, errcode.NotFound, "计划任务不存在")
+		case err != nil:
+			fail(c, errcode.InvalidArgument, err)
+		default:
+			c.JSON(200, schedule)
+		}
+	})
+
+	router.DELETE("/api/schedules/:id", func(c *gin.Context) {
+		err := manager.DeleteSchedule(c.Param("id"))
+		switch {
+		case errors.Is(err, tasks.ErrNotFound):
+			failWith(c, errcode.NotFound, "计划任务不存在")
+		case err != nil:
+			fail(c, errcode.Internal, err)
+		default:
+			c.JSON(200, gin.H{"status": "deleted"})
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/errcode"
+)
+
+// searchLimitMax 一次搜索最多返回的任务数
+const searchLimitMax = 100
+
+// searchTranscripts 在所有已完成转录的文本中搜索关键词，?q=关键词（空白分隔，需要全部出现在同一段中），
+// ?limit=N 最多返回的任务数（默认 20，最大 100）。结果按任务分组，包含匹配的段落和它们在视频中的时间
+func searchTranscripts(c *gin.Context) {
+	limit, ok := searchLimit(c)
+	if !ok {
+		return
+	}
+	query := c.Query("q")
+	results, err := manager.SearchTranscripts(query, searchWorkspace(c), limit)
+	if err != nil {
+		fail(c, errcode.InvalidArgument, err)
+		return
+	}
+	c.JSON(200, gin.H{"query": query, "results": results})
+}
+
+// semanticSearch 按意思搜索转录，?q=一句话描述要找的内容，不要求包含相同的词，?limit=N 同 /api/search。
+// 结果按任务分组，最相关的在前，每段带有相似度 score。需要配置 embedding.provider
+func semanticSearch(c *gin.Context) {
+	limit, ok := searchLimit(c)
+	if !ok {
+		return
+	}
+	query := c.Query("q")
+	results, err := manager.SemanticSearch(c.Request.Context(), query, searchWorkspace(c), limit)
+	if err != nil {
+		fail(c, errcode.InvalidArgument, err)
+		return
+	}
+	c.JSON(200, gin.H{"query": query, "results": results})
+}
+
+// searchLimit 读取 ?limit=N（默认 20，最大 100），无效时返回错误响应
+func searchLimit(c *gin.Context) (int, bool) {
+	limit := 20
+	if v := c.Query("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			failWith(c, errcode.InvalidArgument, "limit 必须是正整数")
+			return 0, false
+		}
+		limit = min(limit, searchLimitMax)
+	}
+	return limit, true
+}
+
+// searchWorkspace 配置了工作区时只搜索当前工作区的任务
+func searchWorkspace(c *gin.Context) string {
+	if restricted(c) {
+		return workspaceName(c)
+	}
+	return ""
+}
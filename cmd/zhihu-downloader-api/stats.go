@@ -0,0 +1,22 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/errcode"
+)
+
+// getStats 返回下载和转录的汇总统计：任务数、成功率、归档的总大小、平均下载速度、转录总时长和下载最多的几天。
+// 只能访问自己工作区时只统计本工作区，管理员和没有配置工作区时可以用 ?workspace= 指定工作区
+func getStats(c *gin.Context) {
+	workspace := c.Query("workspace")
+	if restricted(c) {
+		workspace = workspaceName(c)
+	}
+	stats, err := manager.Stats(workspace)
+	if err != nil {
+		fail(c, errcode.Internal, err)
+		return
+	}
+	c.JSON(200, stats)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/tasks"
+	"zhihu-downloader/internal/transcriber"
+)
+
+// 长时间没有进度变化时发送心跳，避免代理断开连接
+const streamHeartbeat = 15 * time.Second
+
+// streamProgress 通过 Server-Sent Events 推送任务进度：
+// 每次状态变化发送 progress 事件，任务结束时发送以最终状态命名的事件
+// （completed / failed / cancelled）后关闭连接。下载和流水线任务共用
+func streamProgress(c *gin.Context) {
+	id := c.Param("download_id")
+	if id == "" {
+		id = c.Param("task_id")
+	}
+	if _, ok := manager.Event(id); !ok {
+		failWith(c, errcode.NotFound, "任务不存在")
+		return
+	}
+
+	updates, unsubscribe := manager.Subscribe(id)
+	defer unsubscribe()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	// 连接建立后先推送一次当前状态
+	pending := true
+	c.Stream(func(w io.Writer) bool {
+		if !pending {
+			select {
+			case <-updates:
+			case <-heartbeat.C:
+				c.SSEvent("heartbeat", time.Now().Unix())
+				return true
+			case <-c.Request.Context().Done():
+				return false
+			}
+		}
+		pending = false
+
+		event, ok := manager.Event(id)
+		if !ok {
+			return false
+		}
+		if event.Status.Terminal() {
+			c.SSEvent(string(event.Status), event)
+			return false
+		}
+		c.SSEvent("progress", event)
+		return true
+	})
+}
+
+// liveSegment 实时文稿中的一段，Index 从 0 开始
+type liveSegment struct {
+	Index int `json:"index"`
+	transcriber.Segment
+}
+
+// streamTranscript 通过 Server-Sent Events 实时推送转录文稿：Whisper 每识别出一段发送一个 segment 事件
+// （id 为段序号，断线重连时按 Last-Event-ID 继续），转录结束时发送以最终状态命名的事件后关闭连接。
+// 实时分段只有分段时间；连接时已经转录完成的任务推送最终结果中的分段（包括逐词时间和说话人）
+func streamTranscript(c *gin.Context) {
+	id := c.Param("task_id")
+	if _, err := manager.Transcribe(id); err != nil {
+		failWith(c, errcode.NotFound, "任务不存在")
+		return
+	}
+	next := 0
+	if last, err := strconv.Atoi(c.GetHeader("Last-Event-ID")); err == nil && last >= 0 {
+		next = last + 1
+	}
+
+	updates, unsubscribe := manager.Subscribe(id)
+	defer unsubscribe()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	send := func(segments []transcriber.Segment) {
+		for _, s := range segments {
+			c.Render(-1, sse.Event{Id: strconv.Itoa(next), Event: "segment", Data: liveSegment{Index: next, Segment: s}})
+			next++
+		}
+	}
+	// streamed 本次连接推送过实时分段，转录完成后不再推送最终结果
+	streamed := false
+	c.Stream(func(w io.Writer) bool {
+		segments, changed, live := manager.LiveSegments(id, next)
+		send(segments)
+		streamed = streamed || len(segments) > 0
+		if !live {
+			task, err := manager.Transcribe(id)
+			if err != nil {
+				return false
+			}
+			if task.Status.Terminal() {
+				if task.Status == tasks.StatusCompleted && !streamed {
+					if t, err := manager.Transcript(id); err == nil && next < len(t.Segments) {
+						send(t.Segments[next:])
+					}
+				}
+				c.SSEvent(string(task.Status), transcribeProgress{TranscribeTask: task, TaskID: task.ID})
+				return false
+			}
+		}
+
+		select {
+		case <-changed:
+		case <-updates:
+		case <-heartbeat.C:
+			c.SSEvent("heartbeat", time.Now().Unix())
+		case <-c.Request.Context().Done():
+			return false
+		}
+		return true
+	})
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/tasks"
+)
+
+// subscriptionRequest 创建和修改订阅的参数，修改时未提供的字段保持不变
+type subscriptionRequest struct {
+	// URL 知乎用户主页、用户的视频 / 回答页或专栏链接
+	URL              *string `json:"url"`
+	Quality          *string `json:"quality"`
+	OutputPath       *string `json:"output_path"`
+	Backend          *string `json:"backend"`
+	FilenameTemplate *string `json:"filename_template"`
+	// Limit 每次检查最多列出的最新视频数
+	Limit *int `json:"limit"`
+	// IntervalMinutes 检查间隔（分钟）
+	IntervalMinutes *int    `json:"interval_minutes"`
+	Transcribe      *bool   `json:"transcribe"`
+	Language        *string `json:"language"`
+	Model           *string `json:"model"`
+	SkipExisting    *bool   `json:"skip_existing"`
+	Enabled         *bool   `json:"enabled"`
+}
+
+// apply 把请求中提供的字段写入 s
+func (r *subscriptionRequest) apply(s *tasks.Subscription) {
+	if r.URL != nil {
+		s.URL = *r.URL
+	}
+	if r.Quality != nil {
+		s.Quality = *r.Quality
+	}
+	if r.OutputPath != nil {
+		s.OutputDir = *r.OutputPath
+	}
+	if r.Backend != nil {
+		s.Backend = *r.Backend
+	}
+	if r.FilenameTemplate != nil {
+		s.FilenameTemplate = *r.FilenameTemplate
+	}
+	if r.Limit != nil {
+		s.Limit = *r.Limit
+	}
+	if r.IntervalMinutes != nil {
+		s.IntervalMinutes = *r.IntervalMinutes
+	}
+	if r.Transcribe != nil {
+		s.Transcribe = *r.Transcribe
+	}
+	if r.Language != nil {
+		s.Language = *r.Language
+	}
+	if r.Model != nil {
+		s.Model = *r.Model
+	}
+	if r.SkipExisting != nil {
+		s.SkipExisting = *r.SkipExisting
+	}
+	if r.Enabled != nil {
+		s.Enabled = *r.Enabled
+	}
+}
+
+// registerSubscriptionRoutes 订阅：定期检查知乎用户或专栏，自动下载新发布的视频
+func registerSubscriptionRoutes(router *gin.Engine) {
+	router.GET("/api/subscriptions", func(c *gin.Context) {
+		list, err := manager.Subscriptions()
+		if err != nil {
+			fail(c, errcode.Internal, err)
+			return
+		}
+		if restricted(c) {
+			visible := list[:0]
+			for _, s := range list {
+				if s.Workspace == workspaceName(c) {
+					visible = append(visible, s)
+				}
+			}
+			list = visible
+		}
+		c.JSON(200, gin.H{"subscriptions": list})
+	})
+
+	router.POST("/api/subscriptions", func(c *gin.Context) {
+		var req subscriptionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			fail(c, errcode.InvalidArgument, err)
+			return
+		}
+
+		s := tasks.Subscription{Quality: cfg.Quality("hd"), Enabled: true, Workspace: workspaceName(c)}
+		req.apply(&s)
+		subscription, err := manager.CreateSubscription(s)
+		if err != nil {
+			fail(c, errcode.InvalidArgument, err)
+			return
+		}
+
+		c.JSON(200, subscription)
+	})
+
+	router.GET("/api/subscriptions/:id", func(c *gin.Context) {
+		subscription, err := manager.Subscription(c.Param("id"))
+		if err != nil {
+			fail(c, errcode.Internal, err)
+			return
+		}
+
+		c.JSON(200, subscription)
+	})
+
+	router.PUT("/api/subscriptions/:id", func(c *gin.Context) {
+		id := c.Param("id")
+		s, err := manager.Subscription(id)
+		if err != nil {
+			fail(c, errcode.Internal, err)
+			return
+		}
+		var req subscriptionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			fail(c, errcode.InvalidArgument, err)
+			return
+		}
+		req.apply(s)
+
+		subscription, err := manager.UpdateSubscription(id, *s)
+		if err != nil {
+			fail(c, errcode.InvalidArgument, err)
+			return
+		}
+		c.JSON(200, subscription)
+	})
+
+	router.DELETE("/api/subscriptions/:id", func(c *gin.Context) {
+		if err := manager.DeleteSubscription(c.Param("id")); err != nil {
+			fail(c, errcode.Internal, err)
+			return
+		}
+		c.JSON(200, gin.H{"status": "deleted"})
+	})
+
+	// 立即检查一次，不影响下次定期检查的时间
+	router.POST("/api/subscriptions/:id/check", func(c *gin.Context) {
+		result, err := manager.CheckSubscription(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			fail(c, errcode.Internal, err)
+			return
+		}
+		c.JSON(200, result)
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/summarizer"
+	"zhihu-downloader/internal/tasks"
+)
+
+// summarizeRequest POST /api/summarize 的请求体，task_id 和 txt_path 至少指定一个
+type summarizeRequest struct {
+	TaskID  string `json:"task_id"`
+	TXTPath string `json:"txt_path"`
+}
+
+// summarize 调用大模型为转录文本生成摘要、要点和章节，保存为 <name>.summary.md。
+// 可以指定已完成的转录 / 流水线任务（task_id），也可以直接指定文本文件（txt_path）
+func summarize(c *gin.Context) {
+	var req summarizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, errcode.InvalidArgument, err)
+		return
+	}
+
+	var (
+		path string
+		err  error
+	)
+	switch {
+	case req.TaskID != "":
+		if !canAccess(c, req.TaskID) {
+			failWith(c, errcode.NotFound, "任务不存在")
+			return
+		}
+		path, err = manager.Summarize(c.Request.Context(), req.TaskID)
+	case req.TXTPath != "":
+		txtPath := tasks.ExpandHome(req.TXTPath)
+		if restricted(c) {
+			if err := manager.CheckWorkspacePath(workspaceName(c), txtPath); err != nil {
+				fail(c, errcode.Forbidden, err)
+				return
+			}
+		}
+		if err := manager.CheckPath(txtPath); err != nil {
+			fail(c, errcode.Forbidden, err)
+			return
+		}
+		path, err = summarizer.Summarize(c.Request.Context(), txtPath)
+	default:
+		failWith(c, errcode.InvalidArgument, "task_id 和 txt_path 至少指定一个")
+		return
+	}
+	if errors.Is(err, tasks.ErrNotFound) {
+		fail(c, errcode.NotFound, err)
+		return
+	}
+	if err != nil {
+		fail(c, errcode.InvalidArgument, err)
+		return
+	}
+
+	content, _ := os.ReadFile(path)
+	c.JSON(200, gin.H{"summary_path": path, "summary": string(content)})
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/tasks"
+	"zhihu-downloader/internal/transcriber"
+)
+
+// transcriptResponse 结构化的转录结果
+type transcriptResponse struct {
+	TaskID string `json:"task_id"`
+	*transcriber.Transcript
+}
+
+// transcript 返回已完成的转录或流水线任务的逐段结果（start / end / speaker / text）和逐词时间（words），
+// 客户端可以据此显示点击即跳转到视频对应位置的文稿
+func transcript(c *gin.Context) {
+	id := c.Param("task_id")
+	t, err := manager.Transcript(id)
+	switch {
+	case errors.Is(err, tasks.ErrNotFound):
+		failWith(c, errcode.NotFound, "任务不存在")
+	case err != nil:
+		fail(c, errcode.Conflict, err)
+	default:
+		c.JSON(200, transcriptResponse{TaskID: id, Transcript: t})
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	_ "embed"
+
+	"github.com/gin-gonic/gin"
+)
+
+// indexHTML 内置的网页界面：提交链接、查看下载进度、浏览已下载的文件和转录文本。
+// 页面只调用公开的 /api 接口，没有单独的后端逻辑
+//
+//go:embed web/index.html
+var indexHTML []byte
+
+// registerUIRoutes 在 / 提供网页界面
+func registerUIRoutes(router *gin.Engine) {
+	serve := func(c *gin.Context) {
+		c.Header("Cache-Control", "no-cache")
+		c.Data(200, "text/html; charset=utf-8", indexHTML)
+	}
+	router.GET("/", serve)
+	router.HEAD("/", serve)
+}
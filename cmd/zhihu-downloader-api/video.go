@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/zhihu"
+)
+
+// videoInfo 返回知乎视频的可用清晰度，以及按 ?quality= 下载时会选择的清晰度
+func videoInfo(c *gin.Context) {
+	url := c.Query("url")
+	if url == "" {
+		failWith(c, errcode.URLInvalid, "url 必填")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	video, err := zhihu.FetchVideo(ctx, url)
+	if err != nil {
+		fail(c, errcode.Upstream, err)
+		return
+	}
+	selected, err := video.Select(c.DefaultQuery("quality", cfg.Quality("hd")))
+	if err != nil {
+		fail(c, errcode.InvalidArgument, err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"video":    video,
+		"selected": selected,
+	})
+}
+
+// resolveLink 识别链接类型并返回规范化的链接（去掉分享参数、跟随短链接跳转），
+// url 可以是带标题的分享文本；不能下载的链接（合集、Live、用户主页等）返回 400 和原因
+func resolveLink(c *gin.Context) {
+	url := c.Query("url")
+	if url == "" {
+		failWith(c, errcode.URLInvalid, "url 必填")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	link, err := zhihu.ResolveLink(ctx, url)
+	if err != nil {
+		fail(c, errcode.InvalidArgument, err)
+		return
+	}
+	c.JSON(200, link)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"zhihu-downloader/internal/config"
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/serve"
+	"zhihu-downloader/internal/tasks"
+)
+
+// workspaceKey gin 上下文中保存当前请求工作区的键
+const workspaceKey = "workspace"
+
+// apiKeyCookie 网页界面保存 API 密钥的 cookie，<video>、<img> 等无法设置请求头的请求也能带上密钥
+const apiKeyCookie = "api_key"
+
+// publicPaths 配置了工作区时也不需要密钥的接口
+var publicPaths = map[string]bool{"/api/health": true, "/api/openapi.json": true, "/api/docs": true}
+
+// requireAPIKey 配置了工作区时检查 /api 请求的密钥（publicPaths 除外），
+// 路径中的任务 ID 或计划任务 ID 不属于当前工作区时按不存在处理。没有配置工作区时不做任何检查
+func requireAPIKey(c *gin.Context) {
+	path := c.Request.URL.Path
+	if len(cfg.Workspaces) == 0 || !strings.HasPrefix(path, "/api/") || publicPaths[path] {
+		c.Next()
+		return
+	}
+	ws := cfg.WorkspaceByKey(requestAPIKey(c.Request))
+	if ws == nil {
+		failWith(c, errcode.Unauthorized, "缺少或无效的 API 密钥")
+		return
+	}
+	c.Set(workspaceKey, ws)
+
+	for _, name := range []string{"download_id", "task_id", "id"} {
+		if id := c.Param(name); id != "" && !canAccess(c, id) {
+			msg := "任务不存在"
+			if strings.HasPrefix(c.FullPath(), "/api/schedules/") {
+				msg = "计划任务不存在"
+			}
+			failWith(c, errcode.NotFound, msg)
+			return
+		}
+	}
+	c.Next()
+}
+
+// requestAPIKey 依次从 Authorization: Bearer、X-API-Key 请求头和 api_key cookie 中读取密钥
+func requestAPIKey(r *http.Request) string {
+	if v := serve.APIKey(r); v != "" {
+		return v
+	}
+	if cookie, err := r.Cookie(apiKeyCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// currentWorkspace 返回当前请求的工作区，没有配置工作区时为 nil
+func currentWorkspace(c *gin.Context) *config.WorkspaceConfig {
+	if v, ok := c.Get(workspaceKey); ok {
+		return v.(*config.WorkspaceConfig)
+	}
+	return nil
+}
+
+// workspaceName 新建任务所属的工作区，没有配置工作区时为空
+func workspaceName(c *gin.Context) string {
+	if ws := currentWorkspace(c); ws != nil {
+		return ws.Name
+	}
+	return ""
+}
+
+// restricted 当前请求是否只能访问自己工作区的任务和文件
+func restricted(c *gin.Context) bool {
+	ws := currentWorkspace(c)
+	return ws != nil && !ws.Admin
+}
+
+// canAccess 当前请求能否访问任务或计划任务，ID 不存在时返回 true，由各接口返回 404
+func canAccess(c *gin.Context, id string) bool {
+	owner, ok := manager.TaskWorkspace(id)
+	return !ok || !restricted(c) || owner == workspaceName(c)
+}
+
+// requireAdmin 配置了工作区时只允许管理员访问（例如修改所有工作区共用的知乎登录）
+func requireAdmin(c *gin.Context) {
+	if ws := currentWorkspace(c); ws != nil && !ws.Admin {
+		failWith(c, errcode.Forbidden, "只有管理员工作区可以执行该操作")
+		return
+	}
+	c.Next()
+}
+
+// taskQuery 解析任务列表的筛选参数，只能访问自己工作区时只返回本工作区的任务
+func taskQuery(c *gin.Context) (tasks.Query, error) {
+	q, err := tasks.ParseQuery(c.Query)
+	if err != nil {
+		return q, err
+	}
+	if restricted(c) {
+		q.Workspace = workspaceName(c)
+	}
+	return q, nil
+}
+
+// filesRoot 当前请求可以浏览的输出目录：工作区目录，管理员和没有配置工作区时为默认下载目录
+func filesRoot(c *gin.Context) string {
+	if restricted(c) {
+		return manager.WorkspaceDir(workspaceName(c))
+	}
+	return manager.OutputDir()
+}
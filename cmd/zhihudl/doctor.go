@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"zhihu-downloader/internal/health"
+)
+
+// runDoctor 检查运行环境，结果写到标准输出；必需的检查未通过时返回 exitFailed
+func runDoctor(ctx context.Context, args []string) int {
+	fs := newFlagSet("doctor", "doctor [参数]")
+	var common commonFlags
+	common.register(fs)
+	if _, err := parseArgs(fs, args); err != nil {
+		return usageError(err)
+	}
+	cfg, _, err := common.setup()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailed
+	}
+	if !health.PrintDoctor(os.Stdout, health.Doctor(ctx, cfg.DoctorOptions())) {
+		return exitFailed
+	}
+	return exitOK
+}
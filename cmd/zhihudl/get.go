@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/media"
+	"zhihu-downloader/internal/ratelimit"
+	"zhihu-downloader/internal/tasks"
+	"zhihu-downloader/internal/transcriber"
+)
+
+// runGet 依次下载每个链接，输出视频（--transcribe 时为转录文本）的路径
+func runGet(ctx context.Context, args []string) int {
+	fs := newFlagSet("get", "get <url>... [参数]")
+	var (
+		common     commonFlags
+		quality    string
+		output     string
+		template   string
+		backend    string
+		maxRate    string
+		conns      int
+		force      bool
+		comments   bool
+		transcribe bool
+		model      string
+		language   string
+		subtitles  string
+		transcode  media.TranscodeOptions
+		headers    = pairFlag{sep: ":"}
+		cookies    = pairFlag{sep: "="}
+	)
+	common.register(fs)
+	fs.StringVar(&quality, "q", "", "清晰度 uhd / fhd / hd / sd / ld，默认使用配置（hd）")
+	fs.StringVar(&quality, "quality", "", "同 -q")
+	fs.StringVar(&output, "o", "", "保存目录，默认使用配置的下载目录")
+	fs.StringVar(&output, "output", "", "同 -o")
+	fs.StringVar(&template, "template", "", "文件名模板，例如 {title}_{quality}_{date}")
+	fs.StringVar(&backend, "backend", "", "下载后端 auto / native / yt-dlp")
+	fs.StringVar(&maxRate, "max-rate", "", "下载速度上限，例如 2M、500K")
+	fs.IntVar(&conns, "connections", 0, "m3u8 同时下载的分片数 1–16，默认使用配置")
+	fs.BoolVar(&force, "force", false, "已下载过同一视频时仍然重新下载")
+	fs.BoolVar(&comments, "comments", false, "同时保存知乎评论")
+	fs.Var(&headers, "H", "下载时附加的请求头，例如 -H 'Authorization: Bearer ...'，可以重复")
+	fs.Var(&cookies, "cookie", "下载时附加的 cookie，例如 -cookie token=abc，可以重复")
+	fs.StringVar(&transcode.Codec, "transcode", "", "下载后转码为 h264 / h265 / av1，none 表示不转码，默认使用配置")
+	fs.IntVar(&transcode.MaxHeight, "max-height", 0, "转码的分辨率上限，例如 720")
+	fs.IntVar(&transcode.CRF, "crf", 0, "转码画质，越小越清晰，默认 h264 23、h265 28、av1 35")
+	fs.BoolVar(&transcribe, "t", false, "下载后转录")
+	fs.BoolVar(&transcribe, "transcribe", false, "同 -t")
+	fs.StringVar(&model, "m", "", "转录使用的 Whisper 模型 tiny / base / small / medium / large-v3")
+	fs.StringVar(&model, "model", "", "同 -m")
+	fs.StringVar(&language, "l", "", "转录的语言，默认 auto（自动识别）")
+	fs.StringVar(&language, "language", "", "同 -l")
+	fs.StringVar(&subtitles, "subtitles", "", "转录后把字幕封装（mux）或烧录（burn）进视频")
+
+	urls, err := parseArgs(fs, args)
+	if err != nil {
+		return usageError(err)
+	}
+	if len(urls) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	rate, err := ratelimit.Parse(maxRate)
+	if err != nil {
+		return usageError(err)
+	}
+	cfg, m, err := common.setup()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailed
+	}
+	if quality == "" {
+		quality = cfg.Quality("hd")
+	}
+
+	code := exitOK
+	for i, url := range urls {
+		if ctx.Err() != nil {
+			return exitInterrupt
+		}
+		req := downloader.Request{
+			URL:              url,
+			Quality:          quality,
+			OutputDir:        output,
+			FilenameTemplate: template,
+			Backend:          backend,
+			MaxRate:          rate,
+			Connections:      conns,
+			Force:            force,
+			Comments:         comments,
+			Transcode:        transcode,
+			Headers:          headers.values,
+			Cookies:          cookies.values,
+		}
+		label := fmt.Sprintf("[%d/%d]", i+1, len(urls))
+		if len(urls) == 1 {
+			label = ""
+		}
+
+		var id string
+		if transcribe {
+			task, err := m.StartPipeline(req, transcriber.Request{Language: language, Model: model}, subtitles)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", url, err)
+				code = exitFailed
+				continue
+			}
+			id = task.ID
+		} else {
+			task, err := m.StartDownload(req)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", url, err)
+				code = exitFailed
+				continue
+			}
+			id = task.ID
+		}
+
+		event := wait(ctx, m, id, label)
+		if event.Status != tasks.StatusCompleted {
+			if c := report(ctx, m, event); c == exitInterrupt {
+				return c
+			}
+			code = exitFailed
+			continue
+		}
+		printOutputs(m, id)
+	}
+	return code
+}
+
+// printOutputs 把任务的输出文件写到标准输出：第一行为视频（或转录文本），其余文件写到标准错误
+func printOutputs(m *tasks.Manager, id string) {
+	if t, err := m.Download(id); err == nil {
+		if t.Cached {
+			fmt.Fprintln(os.Stderr, "已下载过，使用已有的文件（--force 重新下载）")
+		}
+		fmt.Println(t.FilePath)
+		listRemote(t.RemoteURLs)
+		return
+	}
+	if t, err := m.Pipeline(id); err == nil {
+		fmt.Println(t.TXTPath)
+		listFiles(t.FilePath, t.SRTPath, t.JSONPath, t.SummaryPath, t.SubtitledPath, t.MP3Path)
+		listRemote(t.RemoteURLs)
+	}
+}
+
+// listFiles 在标准错误中列出其余的输出文件
+func listFiles(paths ...string) {
+	for _, path := range paths {
+		if path != "" {
+			fmt.Fprintf(os.Stderr, "  %s\n", path)
+		}
+	}
+}
+
+// listRemote 在标准错误中列出上传到远程存储的文件
+func listRemote(urls map[string]string) {
+	names := make([]string, 0, len(urls))
+	for name := range urls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  已上传 %s: %s\n", name, urls[name])
+	}
+}
+
+// pairFlag 可以重复的 name<sep>value 参数，例如请求头和 cookie
+type pairFlag struct {
+	sep    string
+	values map[string]string
+}
+
+func (f *pairFlag) String() string {
+	return ""
+}
+
+func (f *pairFlag) Set(s string) error {
+	name, value, ok := strings.Cut(s, f.sep)
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return fmt.Errorf("格式应为 name%svalue: %s", f.sep, s)
+	}
+	if f.values == nil {
+		f.values = map[string]string{}
+	}
+	f.values[name] = strings.TrimSpace(value)
+	return nil
+}
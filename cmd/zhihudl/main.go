@@ -0,0 +1,205 @@
+// zhihudl 命令行工具：不启动服务，直接下载或转录一个视频，在终端显示进度。
+//
+//	zhihudl get <url> [-q fhd] [-o dir] [--transcribe]
+//	zhihudl transcribe <file> [--srt] [-m small]
+//	zhihudl doctor
+//
+// 与网关和 MCP 服务共用 internal/ 下的下载和转录逻辑，读取同一个配置文件，但不使用数据库，
+// 任务只保存在内存中。输出文件的路径写到标准输出，进度和日志写到标准错误，便于在脚本中使用。
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"zhihu-downloader/internal/auth"
+	"zhihu-downloader/internal/config"
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/proc"
+	"zhihu-downloader/internal/tasks"
+)
+
+// 退出码
+const (
+	exitOK        = 0
+	exitFailed    = 1
+	exitUsage     = 2
+	exitInterrupt = 130
+)
+
+// command 一个子命令
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(ctx context.Context, args []string) int
+}
+
+var commands = []command{
+	{"get", "get <url>... [-q fhd] [-o dir] [--transcribe]", "下载视频，--transcribe 时下载后转录", runGet},
+	{"transcribe", "transcribe <file>... [--srt] [-m model] [-l language]", "转录本地视频或音频", runTranscribe},
+	{"doctor", "doctor", "检查 ffmpeg、Whisper、Python、数据库、目录权限和网络，列出解决办法", runDoctor},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitUsage)
+	}
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "--help" {
+		usage()
+		return
+	}
+	for _, cmd := range commands {
+		if cmd.name == name {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			code := cmd.run(ctx, os.Args[2:])
+			stop()
+			proc.Shutdown()
+			os.Exit(code)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "未知命令: %s\n\n", name)
+	usage()
+	os.Exit(exitUsage)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "用法: zhihudl <命令> [参数]")
+	fmt.Fprintln(os.Stderr)
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-58s %s\n", cmd.usage, cmd.summary)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "zhihudl <命令> -h 查看命令的全部参数")
+}
+
+// commonFlags 所有命令共用的参数
+type commonFlags struct {
+	config  string
+	cookies string
+	verbose bool
+}
+
+func (f *commonFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.config, "config", "", "配置文件路径，默认与服务相同的查找顺序")
+	fs.StringVar(&f.cookies, "cookies", "", "知乎登录 cookies 文件（Netscape cookies.txt 或 JSON）")
+	fs.BoolVar(&f.verbose, "v", false, "输出详细日志")
+	fs.BoolVar(&f.verbose, "verbose", false, "输出详细日志")
+}
+
+// setup 加载配置并创建只在内存中保存任务的 Manager
+func (f *commonFlags) setup() (*config.Config, *tasks.Manager, error) {
+	var args []string
+	if f.config != "" {
+		args = []string{"-config", f.config}
+	}
+	cfg, err := config.Load(config.AppCLI, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 默认只输出错误，日志不会打断进度条
+	cfg.Log.Level = "error"
+	if f.verbose {
+		cfg.Log.Level = "debug"
+	}
+	cfg.Apply()
+
+	if f.cookies != "" {
+		raw, err := os.ReadFile(f.cookies)
+		if err != nil {
+			return nil, nil, fmt.Errorf("读取 cookies 失败: %v", err)
+		}
+		cookies, err := auth.Parse(string(raw))
+		if err != nil {
+			return nil, nil, err
+		}
+		downloader.SetCookieSource(func() []auth.Cookie { return cookies })
+	}
+	return cfg, tasks.NewManager(cfg.ManagerOptions()...), nil
+}
+
+// parseArgs 解析参数，允许参数和位置参数交替出现，例如 zhihudl get <url> -q fhd
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// newFlagSet 创建子命令的参数集合，-h 时列出参数
+func newFlagSet(cmd, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: zhihudl %s\n\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// wait 等待任务结束并显示进度。ctx 结束（Ctrl-C）时取消任务，等任务停止后返回
+func wait(ctx context.Context, m *tasks.Manager, id, label string) tasks.ProgressEvent {
+	bar := newProgressBar(os.Stderr, label)
+	updates, unsubscribe := m.Subscribe(id)
+	defer unsubscribe()
+
+	done := ctx.Done()
+	for {
+		event, ok := m.Event(id)
+		if !ok {
+			return tasks.ProgressEvent{ID: id, Status: tasks.StatusFailed, Error: "任务不存在"}
+		}
+		if event.Status.Terminal() {
+			bar.Finish(event)
+			return event
+		}
+		bar.Update(event)
+		select {
+		case <-updates:
+		case <-bar.Tick():
+		case <-done:
+			done = nil
+			m.Cancel(id)
+		}
+	}
+}
+
+// report 打印失败原因和任务最近的日志，返回退出码
+func report(ctx context.Context, m *tasks.Manager, event tasks.ProgressEvent) int {
+	if ctx.Err() != nil || event.Status == tasks.StatusCancelled {
+		fmt.Fprintln(os.Stderr, "已取消")
+		return exitInterrupt
+	}
+	fmt.Fprintf(os.Stderr, "失败: %s\n", event.Error)
+	if lines, err := m.Logs(event.ID, 10); err == nil && len(lines) > 0 {
+		fmt.Fprintln(os.Stderr, "最近的日志:")
+		for _, line := range lines {
+			fmt.Fprintf(os.Stderr, "  %s\n", strings.TrimSpace(line.Message))
+		}
+	}
+	return exitFailed
+}
+
+// usageError 参数错误时打印原因，返回退出码
+func usageError(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	fmt.Fprintln(os.Stderr, err)
+	return exitUsage
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"zhihu-downloader/internal/diskspace"
+	"zhihu-downloader/internal/tasks"
+)
+
+const (
+	// barWidth 进度条的格数
+	barWidth = 30
+	// defaultColumns 无法获取终端宽度时假定的列数
+	defaultColumns = 80
+)
+
+// progressBar 在终端中用一行显示任务进度，每秒刷新已用时间。
+// 输出不是终端（例如重定向到文件）时不使用 \r，只在阶段变化或进度每增加 10% 时输出一行
+type progressBar struct {
+	w      io.Writer
+	label  string
+	tty    bool
+	start  time.Time
+	ticker *time.Ticker
+	last   tasks.ProgressEvent
+	// printed 非终端时上次输出的阶段和进度
+	printedStage string
+	printedPct   int
+}
+
+func newProgressBar(f *os.File, label string) *progressBar {
+	b := &progressBar{w: f, label: label, tty: isTerminal(f), start: time.Now(), printedPct: -1}
+	if b.tty {
+		b.ticker = time.NewTicker(time.Second)
+	}
+	return b
+}
+
+// Tick 需要刷新已用时间时收到通知，不是终端时永远不会收到
+func (b *progressBar) Tick() <-chan time.Time {
+	if b.ticker == nil {
+		return nil
+	}
+	return b.ticker.C
+}
+
+// Update 显示最新的进度
+func (b *progressBar) Update(e tasks.ProgressEvent) {
+	b.last = e
+	if b.tty {
+		b.draw(e)
+		return
+	}
+	if e.Stage != b.printedStage || e.Percentage/10 > b.printedPct/10 {
+		b.printedStage, b.printedPct = e.Stage, e.Percentage
+		fmt.Fprintln(b.w, b.line(e))
+	}
+}
+
+// Finish 显示最终状态并换行
+func (b *progressBar) Finish(e tasks.ProgressEvent) {
+	if b.ticker != nil {
+		b.ticker.Stop()
+	}
+	e.Speed = ""
+	e.ETASeconds = 0
+	if e.Status == tasks.StatusCompleted {
+		e.Percentage = 100
+		e.Stage = "完成"
+	} else {
+		e.Stage = string(e.Status)
+	}
+	if b.tty {
+		b.draw(e)
+		fmt.Fprintln(b.w)
+		return
+	}
+	fmt.Fprintln(b.w, b.line(e))
+}
+
+func (b *progressBar) draw(e tasks.ProgressEvent) {
+	fmt.Fprint(b.w, "\r\033[K"+truncate(b.line(e), columns()-1))
+}
+
+// line 一行进度：[1/3] [#########.....]  45%  20.5 MB / 45.6 MB  2.1 MB/s  01:23  剩余 02:10  正在下载
+func (b *progressBar) line(e tasks.ProgressEvent) string {
+	pct := min(max(e.Percentage, 0), 100)
+	var s strings.Builder
+	if b.label != "" {
+		s.WriteString(b.label + " ")
+	}
+	if b.tty {
+		filled := pct * barWidth / 100
+		s.WriteString("[" + strings.Repeat("#", filled) + strings.Repeat(".", barWidth-filled) + "] ")
+	}
+	fmt.Fprintf(&s, "%3d%%", pct)
+	if e.BytesDownloaded > 0 {
+		s.WriteString("  " + diskspace.Format(e.BytesDownloaded))
+		if e.TotalBytes > e.BytesDownloaded {
+			s.WriteString(" / " + diskspace.Format(e.TotalBytes))
+		}
+	}
+	if e.Speed != "" {
+		s.WriteString("  " + e.Speed)
+	}
+	elapsed := time.Since(b.start).Round(time.Second)
+	fmt.Fprintf(&s, "  %02d:%02d", int(elapsed.Minutes()), int(elapsed.Seconds())%60)
+	if e.ETASeconds > 0 {
+		fmt.Fprintf(&s, "  剩余 %02d:%02d", e.ETASeconds/60, e.ETASeconds%60)
+	}
+	if e.Stage != "" {
+		s.WriteString("  " + stageText(e))
+	}
+	return s.String()
+}
+
+// stageText 下载任务的阶段是状态名，换成中文；转录和流水线任务的阶段本身就是说明，结束时换成最终状态
+func stageText(e tasks.ProgressEvent) string {
+	switch tasks.Status(e.Stage) {
+	case tasks.StatusPending:
+		return "等待开始"
+	case tasks.StatusQueued:
+		return "排队中"
+	case tasks.StatusPaused:
+		return "已暂停"
+	case tasks.StatusDownloading:
+		return "正在下载"
+	case tasks.StatusTranscoding:
+		return "正在转码"
+	case tasks.StatusFailed:
+		return "失败"
+	case tasks.StatusCancelled:
+		return "已取消"
+	}
+	return e.Stage
+}
+
+// truncate 按显示宽度截断（中文等宽字符占两列），避免超出一行后 \r 无法覆盖
+func truncate(s string, width int) string {
+	w := 0
+	for i, r := range s {
+		cw := 1
+		if r >= 0x1100 && utf8.RuneLen(r) > 2 {
+			cw = 2
+		}
+		if w+cw > width {
+			return s[:i]
+		}
+		w += cw
+	}
+	return s
+}
+
+// columns 终端宽度，取自 COLUMNS 环境变量
+func columns() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 20 {
+		return n
+	}
+	return defaultColumns
+}
+
+// isTerminal 判断 f 是否为终端
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
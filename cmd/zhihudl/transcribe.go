@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"zhihu-downloader/internal/tasks"
+	"zhihu-downloader/internal/transcriber"
+)
+
+// runTranscribe 依次转录每个文件，输出转录文本（--srt 时为字幕）的路径
+func runTranscribe(ctx context.Context, args []string) int {
+	fs := newFlagSet("transcribe", "transcribe <file>... [参数]")
+	var (
+		common      commonFlags
+		srt         bool
+		model       string
+		language    string
+		output      string
+		diarize     bool
+		summarize   bool
+		keepAudio   bool
+		audioFormat string
+		prompt      string
+		vocabulary  listFlag
+	)
+	common.register(fs)
+	fs.BoolVar(&srt, "srt", false, "输出字幕文件的路径（默认输出转录文本的路径）")
+	fs.StringVar(&model, "m", "", "Whisper 模型 tiny / base / small / medium / large-v3，默认使用配置")
+	fs.StringVar(&model, "model", "", "同 -m")
+	fs.StringVar(&language, "l", "", "语言，默认 auto（自动识别）")
+	fs.StringVar(&language, "language", "", "同 -l")
+	fs.StringVar(&output, "o", "", "保存目录，默认与视频相同")
+	fs.StringVar(&output, "output", "", "同 -o")
+	fs.BoolVar(&diarize, "diarize", false, "区分说话人")
+	fs.BoolVar(&summarize, "summarize", false, "转录后生成摘要")
+	fs.BoolVar(&keepAudio, "keep-audio", false, "保留提取的音频")
+	fs.StringVar(&audioFormat, "audio-format", "", "提取的音频格式 wav / mp3 / m4a / flac")
+	fs.StringVar(&prompt, "prompt", "", "传给 Whisper 的提示文本，例如视频主题")
+	fs.Var(&vocabulary, "vocab", "专业词汇，逗号分隔，可以重复，例如 --vocab Kubernetes,etcd")
+
+	files, err := parseArgs(fs, args)
+	if err != nil {
+		return usageError(err)
+	}
+	if len(files) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	_, m, err := common.setup()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailed
+	}
+	if _, err := transcriber.ResolveModel(model); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailed
+	}
+
+	code := exitOK
+	for i, file := range files {
+		if ctx.Err() != nil {
+			return exitInterrupt
+		}
+		req := transcriber.Request{
+			VideoPath:   file,
+			OutputDir:   output,
+			Language:    language,
+			Model:       model,
+			Diarize:     diarize,
+			Summarize:   summarize,
+			AudioFormat: audioFormat,
+
+			InitialPrompt: prompt,
+			Vocabulary:    vocabulary,
+		}
+		// 没有指定时使用配置 transcribe.keep_intermediate
+		if keepAudio {
+			req.KeepIntermediate = &keepAudio
+		}
+		task, err := m.StartTranscribe(req)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+			code = exitFailed
+			continue
+		}
+
+		label := fmt.Sprintf("[%d/%d]", i+1, len(files))
+		if len(files) == 1 {
+			label = ""
+		}
+		event := wait(ctx, m, task.ID, label)
+		if event.Status != tasks.StatusCompleted {
+			if c := report(ctx, m, event); c == exitInterrupt {
+				return c
+			}
+			code = exitFailed
+			continue
+		}
+
+		t, _ := m.Transcribe(task.ID)
+		if srt {
+			fmt.Println(t.SRTPath)
+			listFiles(t.TXTPath, t.JSONPath, t.SummaryPath, t.MP3Path)
+		} else {
+			fmt.Println(t.TXTPath)
+			listFiles(t.SRTPath, t.JSONPath, t.SummaryPath, t.MP3Path)
+		}
+		listRemote(t.RemoteURLs)
+	}
+	return code
+}
+
+// listFlag 可以重复的逗号分隔列表参数
+type listFlag []string
+
+func (f *listFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *listFlag) Set(s string) error {
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*f = append(*f, item)
+		}
+	}
+	return nil
+}
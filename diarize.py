@@ -0,0 +1,72 @@
+#!/usr/bin/env python3
+"""
+说话人分离（speaker diarization），供 Go 转录服务调用。
+
+使用 pyannote.audio 识别音频中每段话的说话人，结果以 JSON 输出到 stdout：
+[{"start": 0.5, "end": 3.2, "speaker": "SPEAKER_00"}, ...]
+
+依赖：
+    pip install pyannote.audio
+    需要在 Hugging Face 上同意 pyannote/speaker-diarization-3.1 的使用条款，
+    并通过 --hf-token 或 HF_TOKEN 环境变量提供访问令牌。
+
+用法：
+    python diarize.py audio.mp3 [--num-speakers 2] [--hf-token TOKEN]
+"""
+
+import argparse
+import json
+import os
+import sys
+
+MODEL = "pyannote/speaker-diarization-3.1"
+
+
+def main():
+    parser = argparse.ArgumentParser(description="说话人分离")
+    parser.add_argument("audio", help="音频文件路径")
+    parser.add_argument("--num-speakers", type=int, default=0, help="说话人数量（默认自动判断）")
+    parser.add_argument("--hf-token", default=os.environ.get("HF_TOKEN", ""), help="Hugging Face 访问令牌")
+    parser.add_argument("--model", default=MODEL, help="pyannote 模型")
+    args = parser.parse_args()
+
+    try:
+        from pyannote.audio import Pipeline
+    except ImportError:
+        print("未安装 pyannote.audio（pip install pyannote.audio）", file=sys.stderr)
+        sys.exit(2)
+
+    if not args.hf_token:
+        print("缺少 Hugging Face 访问令牌（--hf-token 或 HF_TOKEN 环境变量）", file=sys.stderr)
+        sys.exit(2)
+
+    pipeline = Pipeline.from_pretrained(args.model, use_auth_token=args.hf_token)
+    if pipeline is None:
+        print(f"加载模型 {args.model} 失败，请确认已在 Hugging Face 同意使用条款", file=sys.stderr)
+        sys.exit(1)
+
+    # Apple Silicon / CUDA 可用时使用 GPU
+    try:
+        import torch
+
+        if torch.cuda.is_available():
+            pipeline.to(torch.device("cuda"))
+        elif torch.backends.mps.is_available():
+            pipeline.to(torch.device("mps"))
+    except Exception as e:  # noqa: BLE001
+        print(f"无法使用 GPU，改用 CPU: {e}", file=sys.stderr)
+
+    options = {}
+    if args.num_speakers > 0:
+        options["num_speakers"] = args.num_speakers
+    diarization = pipeline(args.audio, **options)
+
+    turns = [
+        {"start": round(turn.start, 3), "end": round(turn.end, 3), "speaker": speaker}
+        for turn, _, speaker in diarization.itertracks(yield_label=True)
+    ]
+    json.dump(turns, sys.stdout)
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,63 @@
+// Package atomicfile 先把文件写入同目录的 .part 文件，写完后再重命名为最终的文件名。
+// 进程崩溃或写入失败时只会留下 .part 文件，最终文件名要么不存在，要么是完整的文件。
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Suffix 未写完的文件的后缀，与 yt-dlp 的临时文件相同
+const Suffix = ".part"
+
+// Path 写入 path 过程中使用的文件：<path>.part
+func Path(path string) string {
+	return path + Suffix
+}
+
+// MediaPath ffmpeg 输出 path 过程中使用的文件：<文件名>.part<扩展名>，
+// 保留扩展名以便 ffmpeg 判断封装格式
+func MediaPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + Suffix + ext
+}
+
+// IsPartial 文件名是否是未写完的文件：<path>.part（包括 yt-dlp 的临时文件）或 <文件名>.part<扩展名>
+func IsPartial(name string) bool {
+	if strings.HasSuffix(name, Suffix) {
+		return true
+	}
+	ext := filepath.Ext(name)
+	return ext != "" && strings.HasSuffix(strings.TrimSuffix(name, ext), Suffix)
+}
+
+// Commit 把写完的 part 重命名为 path，失败时删除 part
+func Commit(part, path string) error {
+	if err := os.Rename(part, path); err != nil {
+		os.Remove(part)
+		return err
+	}
+	return nil
+}
+
+// WriteFile 与 os.WriteFile 相同，但先写入 Path(path) 并同步到磁盘，成功后再重命名为 path
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	part := Path(path)
+	f, err := os.OpenFile(part, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(part)
+		return err
+	}
+	return Commit(part, path)
+}
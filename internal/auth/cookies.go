@@ -0,0 +1,187 @@
+// Package auth 管理知乎登录 cookies：解析、加密保存以及登录状态判断。
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultDomain 没有指定域名的 cookie 归属的域
+const DefaultDomain = ".zhihu.com"
+
+// LoginCookie 知乎登录凭证所在的 cookie
+const LoginCookie = "z_c0"
+
+// Cookie 单个 cookie。JSON 格式与 zhihu_downloader.py 的 --cookies 文件一致
+type Cookie struct {
+	Name    string    `json:"name"`
+	Value   string    `json:"value"`
+	Domain  string    `json:"domain"`
+	Path    string    `json:"path,omitempty"`
+	Secure  bool      `json:"secure,omitempty"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// Expired 判断 cookie 是否已过期（会话 cookie 不过期）
+func (c Cookie) Expired() bool {
+	return !c.Expires.IsZero() && c.Expires.Before(time.Now())
+}
+
+// Parse 解析 cookies，支持三种格式：
+//   - 浏览器请求头中的字符串 "a=1; b=2"
+//   - Netscape cookies.txt（curl / yt-dlp / 浏览器插件导出）
+//   - JSON 数组 [{"name": "...", "value": "...", "domain": "..."}]
+//
+// 只保留知乎域名下的 cookie
+func Parse(raw string) ([]Cookie, error) {
+	raw = strings.TrimSpace(strings.TrimPrefix(raw, "\ufeff"))
+	if raw == "" {
+		return nil, fmt.Errorf("cookies 为空")
+	}
+
+	var (
+		cookies []Cookie
+		err     error
+	)
+	switch {
+	case strings.HasPrefix(raw, "["):
+		err = json.Unmarshal([]byte(raw), &cookies)
+		if err != nil {
+			err = fmt.Errorf("解析 JSON cookies 失败: %v", err)
+		}
+	case isNetscape(raw):
+		cookies, err = parseNetscape(raw)
+	default:
+		cookies = parseHeader(strings.TrimPrefix(raw, "Cookie:"))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Cookie, 0, len(cookies))
+	for _, c := range cookies {
+		if c.Name == "" {
+			continue
+		}
+		if c.Domain == "" {
+			c.Domain = DefaultDomain
+		}
+		if !isZhihuDomain(c.Domain) {
+			continue
+		}
+		result = append(result, c)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("未找到知乎域名下的 cookies")
+	}
+	return result, nil
+}
+
+func isZhihuDomain(domain string) bool {
+	domain = strings.TrimPrefix(strings.ToLower(domain), ".")
+	return domain == "zhihu.com" || strings.HasSuffix(domain, ".zhihu.com")
+}
+
+func isNetscape(raw string) bool {
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "# Netscape") || strings.HasPrefix(line, "#HttpOnly_") {
+			return true
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		return len(strings.Split(line, "\t")) >= 7
+	}
+	return false
+}
+
+// parseNetscape 解析 cookies.txt：domain, includeSubdomains, path, secure, expires, name, value
+func parseNetscape(raw string) ([]Cookie, error) {
+	var cookies []Cookie
+	for i, line := range strings.Split(raw, "\n") {
+		line = strings.TrimRight(line, "\r")
+		line = strings.TrimPrefix(line, "#HttpOnly_")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 7 {
+			return nil, fmt.Errorf("cookies.txt 第 %d 行格式错误", i+1)
+		}
+		c := Cookie{
+			Domain: fields[0],
+			Path:   fields[2],
+			Secure: strings.EqualFold(fields[3], "TRUE"),
+			Name:   fields[5],
+			Value:  fields[6],
+		}
+		if ts, err := strconv.ParseInt(fields[4], 10, 64); err == nil && ts > 0 {
+			c.Expires = time.Unix(ts, 0)
+		}
+		cookies = append(cookies, c)
+	}
+	return cookies, nil
+}
+
+func parseHeader(raw string) []Cookie {
+	var cookies []Cookie
+	for _, part := range strings.Split(raw, ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		cookies = append(cookies, Cookie{
+			Name:   strings.TrimSpace(name),
+			Value:  strings.TrimSpace(value),
+			Domain: DefaultDomain,
+		})
+	}
+	return cookies
+}
+
+// Header 拼成 Cookie 请求头，忽略已过期的 cookie
+func Header(cookies []Cookie) string {
+	parts := make([]string, 0, len(cookies))
+	for _, c := range cookies {
+		if c.Expired() {
+			continue
+		}
+		parts = append(parts, c.Name+"="+c.Value)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Status 登录状态，不包含 cookie 内容
+type Status struct {
+	Configured bool       `json:"configured"`
+	LoggedIn   bool       `json:"logged_in"`
+	Count      int        `json:"count"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+	// Session 最近一次用 cookies 请求知乎验证登录的结果，没有检查过时为空
+	Session *Session `json:"session,omitempty"`
+}
+
+// StatusOf 根据 cookies 判断登录状态
+func StatusOf(cookies []Cookie) Status {
+	status := Status{Configured: len(cookies) > 0, Count: len(cookies)}
+	for _, c := range cookies {
+		if c.Name != LoginCookie || c.Value == "" || c.Expired() {
+			continue
+		}
+		status.LoggedIn = true
+		if !c.Expires.IsZero() {
+			expires := c.Expires
+			status.ExpiresAt = &expires
+		}
+	}
+	return status
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"time"
+
+	"zhihu-downloader/internal/errcode"
+)
+
+// 用 cookies 请求知乎验证登录的结果
+const (
+	// SessionValid 登录有效
+	SessionValid = "valid"
+	// SessionExpiring 登录有效，但登录凭证将在 ExpiringWithin 内过期
+	SessionExpiring = "expiring"
+	// SessionExpired 登录已失效，需要重新登录后上传 cookies
+	SessionExpired = "expired"
+	// SessionUnknown 检查请求失败（网络错误或知乎返回其他状态码），无法判断
+	SessionUnknown = "unknown"
+)
+
+// ExpiringWithin 登录凭证在这段时间内过期时状态为 expiring
+const ExpiringWithin = 7 * 24 * time.Hour
+
+// ErrLoginRequired 保存的知乎登录已失效
+var ErrLoginRequired = errcode.New(errcode.AuthRequired, "知乎登录已失效，需要重新登录后上传 cookies")
+
+// Session 最近一次验证登录的结果
+type Session struct {
+	State string `json:"state"`
+	// User 登录的知乎用户名
+	User      string    `json:"user,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// LoginCookieOf 返回 cookies 中的登录凭证 z_c0（可能已过期），没有时返回 false
+func LoginCookieOf(cookies []Cookie) (Cookie, bool) {
+	for _, c := range cookies {
+		if c.Name == LoginCookie && c.Value != "" {
+			return c, true
+		}
+	}
+	return Cookie{}, false
+}
@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SecretEnv 设置后用它派生加密密钥，不再使用密钥文件
+const SecretEnv = "ZHIHU_DOWNLOADER_SECRET"
+
+// Storage 保存加密后的 cookies，由 store.Store 实现
+type Storage interface {
+	SaveCookies(data []byte) error
+	Cookies() (data []byte, updatedAt time.Time, err error)
+	DeleteCookies() error
+}
+
+// Vault 使用 AES-GCM 加密 cookies 后写入 Storage
+type Vault struct {
+	storage Storage
+	aead    cipher.AEAD
+}
+
+// KeyPath 密钥文件与数据库放在同一目录：zhihu_downloader.db -> zhihu_downloader.key
+func KeyPath(dbPath string) string {
+	return strings.TrimSuffix(dbPath, filepath.Ext(dbPath)) + ".key"
+}
+
+// OpenVault 加载（必要时生成）密钥
+func OpenVault(storage Storage, keyPath string) (*Vault, error) {
+	key, err := loadKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Vault{storage: storage, aead: aead}, nil
+}
+
+func loadKey(path string) ([]byte, error) {
+	if secret := os.Getenv(SecretEnv); secret != "" {
+		sum := sha256.Sum256([]byte(secret))
+		return sum[:], nil
+	}
+
+	for {
+		key, err := readKey(path)
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return key, err
+		}
+		// 多个进程同时启动时只有一个能创建密钥文件，其他进程读取它创建的密钥
+		key, err = createKey(path)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		return key, err
+	}
+}
+
+// keyWait 密钥文件刚被其他进程创建、还没有写完时等待的时间
+const keyWait = 2 * time.Second
+
+// readKey 读取密钥文件，文件不完整时等待创建它的进程写完
+func readKey(path string) ([]byte, error) {
+	deadline := time.Now().Add(keyWait)
+	for {
+		key, err := os.ReadFile(path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("读取密钥失败: %w", err)
+		}
+		if len(key) == 32 {
+			return key, nil
+		}
+		if len(key) > 32 || time.Now().After(deadline) {
+			return nil, fmt.Errorf("密钥文件 %s 已损坏", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// createKey 生成密钥并用 O_EXCL 创建密钥文件，文件已存在时返回 fs.ErrExist
+func createKey(path string) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("保存密钥失败: %v", err)
+	}
+	_, err = f.Write(key)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("保存密钥失败: %v", err)
+	}
+	return key, nil
+}
+
+// Seal 用 AES-GCM 加密 plain，随机 nonce 放在密文前面
+func (v *Vault) Seal(plain []byte) ([]byte, error) {
+	nonce := make([]byte, v.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return v.aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+// Open 解密 Seal 的结果
+func (v *Vault) Open(data []byte) ([]byte, error) {
+	size := v.aead.NonceSize()
+	if len(data) < size {
+		return nil, fmt.Errorf("数据已损坏")
+	}
+	plain, err := v.aead.Open(nil, data[:size], data[size:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("解密失败（密钥可能已更换）: %v", err)
+	}
+	return plain, nil
+}
+
+// Save 加密保存 cookies
+func (v *Vault) Save(cookies []Cookie) error {
+	plain, err := json.Marshal(cookies)
+	if err != nil {
+		return err
+	}
+	data, err := v.Seal(plain)
+	if err != nil {
+		return err
+	}
+	return v.storage.SaveCookies(data)
+}
+
+// Load 读取并解密 cookies，未保存时返回 nil
+func (v *Vault) Load() ([]Cookie, error) {
+	cookies, _, err := v.load()
+	return cookies, err
+}
+
+func (v *Vault) load() ([]Cookie, time.Time, error) {
+	data, updatedAt, err := v.storage.Cookies()
+	if err != nil || data == nil {
+		return nil, updatedAt, err
+	}
+
+	plain, err := v.Open(data)
+	if err != nil {
+		return nil, updatedAt, fmt.Errorf("读取 cookies 失败: %v", err)
+	}
+
+	var cookies []Cookie
+	if err := json.Unmarshal(plain, &cookies); err != nil {
+		return nil, updatedAt, err
+	}
+	return cookies, updatedAt, nil
+}
+
+// Clear 删除已保存的 cookies
+func (v *Vault) Clear() error {
+	return v.storage.DeleteCookies()
+}
+
+// Status 返回当前登录状态
+func (v *Vault) Status() (Status, error) {
+	cookies, updatedAt, err := v.load()
+	if err != nil {
+		return Status{}, err
+	}
+	status := StatusOf(cookies)
+	if !updatedAt.IsZero() {
+		status.UpdatedAt = &updatedAt
+	}
+	return status, nil
+}
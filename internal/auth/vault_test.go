@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestLoadKeyConcurrent(t *testing.T) {
+	t.Setenv(SecretEnv, "")
+	path := filepath.Join(t.TempDir(), "test.key")
+
+	const n = 8
+	keys := make([][]byte, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			keys[i], errs[i] = loadKey(path)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatalf("loadKey: %v", errs[i])
+		}
+		if !bytes.Equal(keys[i], keys[0]) {
+			t.Fatalf("进程 %d 得到了不同的密钥", i)
+		}
+	}
+	again, err := loadKey(path)
+	if err != nil || !bytes.Equal(again, keys[0]) {
+		t.Fatalf("重新读取的密钥不一致: %v", err)
+	}
+}
+
+func TestLoadKeyCorrupted(t *testing.T) {
+	t.Setenv(SecretEnv, "")
+	path := filepath.Join(t.TempDir(), "test.key")
+	if err := os.WriteFile(path, make([]byte, 40), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadKey(path); err == nil {
+		t.Fatal("损坏的密钥文件应该返回错误")
+	}
+}
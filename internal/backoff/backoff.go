@@ -0,0 +1,32 @@
+// Package backoff 计算失败重试前的等待时间：指数退避加随机抖动，
+// 避免多个分片或任务在同一时刻一起重试。
+package backoff
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Delay 返回第 attempt 次重试（从 1 开始）前的等待时间：base × 2^(attempt-1)，不超过 max，
+// 再在 [50%, 100%] 之间随机取值
+func Delay(attempt int, base, max time.Duration) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	d = min(d, max)
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// Sleep 等待 d，ctx 先结束时返回 ctx.Err()
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
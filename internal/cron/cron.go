@@ -0,0 +1,174 @@
+// Package cron 解析标准的 5 段 cron 表达式（分 时 日 月 周），用于计划任务。
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 解析后的 cron 表达式，按本地时间计算
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// 日和周都不是 * 时满足其一即可（与 Vixie cron 相同）
+	domAny, dowAny bool
+}
+
+// 预定义的表达式
+var aliases = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+	dowNames = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+)
+
+// Parse 解析 cron 表达式，例如 "0 3 * * *"（每天 3 点）、"*/30 * * * 1-5"、"@daily"。
+// 每段支持 *、数字、范围 a-b、步长 /n 和逗号分隔的列表，月和周可以使用英文缩写，周的 7 等同于 0（周日）
+func Parse(expr string) (*Schedule, error) {
+	spec := strings.TrimSpace(expr)
+	if alias, ok := aliases[strings.ToLower(spec)]; ok {
+		spec = alias
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron 表达式需要 5 段（分 时 日 月 周）: %q", expr)
+	}
+
+	s := &Schedule{domAny: fields[2] == "*" || fields[2] == "?", dowAny: fields[4] == "*" || fields[4] == "?"}
+	var err error
+	if s.minute, err = parseField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("cron 表达式的分钟无效: %v", err)
+	}
+	if s.hour, err = parseField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("cron 表达式的小时无效: %v", err)
+	}
+	if s.dom, err = parseField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("cron 表达式的日期无效: %v", err)
+	}
+	if s.month, err = parseField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("cron 表达式的月份无效: %v", err)
+	}
+	if s.dow, err = parseField(fields[4], 0, 7, dowNames); err != nil {
+		return nil, fmt.Errorf("cron 表达式的星期无效: %v", err)
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+// parseField 把一段表达式解析为位集合
+func parseField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("步长无效: %s", part)
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		switch {
+		case rng == "*" || rng == "?":
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = parseValue(a, names); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(b, names); err != nil {
+				return 0, err
+			}
+		default:
+			n, err := parseValue(rng, names)
+			if err != nil {
+				return 0, err
+			}
+			lo = n
+			// 5/15 表示从 5 开始每 15 个
+			if !hasStep {
+				hi = n
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("超出范围 %d-%d: %s", min, max, part)
+		}
+		for i := lo; i <= hi; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(s string, names map[string]int) (int, error) {
+	if n, ok := names[strings.ToLower(s)]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("无法识别: %s", s)
+	}
+	return n, nil
+}
+
+// Next 返回 t 之后（不含 t 所在的分钟）第一个满足表达式的时间，5 年内没有时返回零值。
+// 按 t 所在时区的墙上时间匹配，夏令时的影响与 Vixie cron 相同：拨快时跳过的时间在跳过之后立即执行
+// （同一段中的多个时间只执行一次），拨慢时重复的一小时中同一个墙上时间只执行第一次
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	// w 为墙上时间，用 UTC 表示，不受夏令时影响
+	w := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC).Add(time.Minute)
+	limit := w.AddDate(5, 0, 0)
+	for w.Before(limit) {
+		switch {
+		case s.month&(1<<uint(w.Month())) == 0:
+			w = time.Date(w.Year(), w.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.dayMatches(w):
+			w = time.Date(w.Year(), w.Month(), w.Day()+1, 0, 0, 0, 0, time.UTC)
+		case s.hour&(1<<uint(w.Hour())) == 0:
+			w = w.Truncate(time.Hour).Add(time.Hour)
+		case s.minute&(1<<uint(w.Minute())) == 0:
+			w = w.Add(time.Minute)
+		default:
+			next := time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), 0, 0, loc)
+			if wall := time.Date(next.Year(), next.Month(), next.Day(), next.Hour(), next.Minute(), 0, 0, time.UTC); !wall.Equal(w) {
+				// 拨快时跳过的时间，改为跳过之后的第一刻。time.Date 可能按之前或之后的时区换算
+				start, end := next.ZoneBounds()
+				if wall.Before(w) {
+					next = end
+				} else {
+					next = start
+				}
+			}
+			// 拨慢时 t 在重复的一小时中，相同的墙上时间已经执行过
+			if next.After(t) {
+				return next
+			}
+			w = w.Add(time.Minute)
+		}
+	}
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,139 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{"0 3 * * *", false},
+		{"*/30 * * * 1-5", false},
+		{"5/15 0-23/2 1,15 jan-mar,dec sun,sat", false},
+		{"0 0 ? * 7", false},
+		{"@daily", false},
+		{" @Weekly ", false},
+		{"", true},
+		{"0 3 * *", true},
+		{"0 3 * * * *", true},
+		{"60 * * * *", true},
+		{"* 24 * * *", true},
+		{"* * 0 * *", true},
+		{"* * 32 * *", true},
+		{"* * * 13 *", true},
+		{"* * * * 8", true},
+		{"5-1 * * * *", true},
+		{"*/0 * * * *", true},
+		{"*/x * * * *", true},
+		{"* * * foo *", true},
+		{"1-x * * * *", true},
+		{"@reboot", true},
+	}
+	for _, tt := range tests {
+		if _, err := Parse(tt.expr); (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) 错误 = %v，wantErr = %v", tt.expr, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	utc := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04:05", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"每天 3 点", "0 3 * * *", utc("2026-10-17 10:00:00"), utc("2026-10-18 03:00:00")},
+		{"不含当前分钟", "0 3 * * *", utc("2026-10-18 03:00:30"), utc("2026-10-19 03:00:00")},
+		{"工作日跳过周末", "*/30 * * * 1-5", utc("2026-10-16 23:45:00"), utc("2026-10-19 00:00:00")},
+		{"从 5 开始每 15 分钟", "5/15 * * * *", utc("2026-10-17 10:21:00"), utc("2026-10-17 10:35:00")},
+		{"周的 7 是周日", "0 0 * * 7", utc("2026-10-17 10:00:00"), utc("2026-10-18 00:00:00")},
+		{"月份缩写", "0 0 1 jan *", utc("2026-10-17 10:00:00"), utc("2027-01-01 00:00:00")},
+		{"跳过没有 31 日的月份", "0 0 31 * *", utc("2026-02-01 00:00:00"), utc("2026-03-31 00:00:00")},
+		{"闰年的 2 月 29 日", "0 0 29 2 *", utc("2026-10-17 10:00:00"), utc("2028-02-29 00:00:00")},
+		{"日和周都指定时满足其一", "0 0 13 * 5", utc("2026-10-01 00:00:00"), utc("2026-10-02 00:00:00")},
+		{"日和周满足其一（日期先到）", "0 0 13 * 5", utc("2026-10-09 00:00:00"), utc("2026-10-13 00:00:00")},
+		{"只指定日", "0 0 13 * *", utc("2026-10-01 00:00:00"), utc("2026-10-13 00:00:00")},
+		{"只指定周", "0 0 ? * fri", utc("2026-10-03 00:00:00"), utc("2026-10-09 00:00:00")},
+		{"不存在的日期", "0 0 31 2 *", utc("2026-10-17 10:00:00"), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s，应为 %s", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextDST(t *testing.T) {
+	local := func(name string) func(string) time.Time {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			t.Skipf("没有时区数据: %v", err)
+		}
+		return func(s string) time.Time {
+			v, err := time.ParseInLocation("2006-01-02 15:04 -0700", s, loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return v
+		}
+	}
+	ny := local("America/New_York")
+	lordHowe := local("Australia/Lord_Howe")
+
+	// 纽约 2026-03-08 02:00 拨快到 03:00，2026-11-01 02:00 拨慢到 01:00；豪勋爵岛 2026-10-04 02:00 拨快半小时
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want []time.Time
+	}{
+		{"跳过的时间在拨快后执行", "30 2 * * *", ny("2026-03-08 00:00 -0500"),
+			[]time.Time{ny("2026-03-08 03:00 -0400"), ny("2026-03-09 02:30 -0400")}},
+		{"跳过的多个时间只执行一次", "15,45 2 * * *", ny("2026-03-08 00:00 -0500"),
+			[]time.Time{ny("2026-03-08 03:00 -0400"), ny("2026-03-09 02:15 -0400")}},
+		{"拨快当天的固定时间", "0 3 * * *", ny("2026-03-07 03:00 -0500"),
+			[]time.Time{ny("2026-03-08 03:00 -0400"), ny("2026-03-09 03:00 -0400")}},
+		{"重复的时间只执行第一次", "30 1 * * *", ny("2026-11-01 00:00 -0400"),
+			[]time.Time{ny("2026-11-01 01:30 -0400"), ny("2026-11-02 01:30 -0500")}},
+		{"每小时在重复的一小时中只执行一次", "0 * * * *", ny("2026-11-01 00:30 -0400"),
+			[]time.Time{ny("2026-11-01 01:00 -0400"), ny("2026-11-01 02:00 -0500"), ny("2026-11-01 03:00 -0500")}},
+		{"从重复的一小时开始", "*/20 * * * *", ny("2026-11-01 01:50 -0500"),
+			[]time.Time{ny("2026-11-01 02:00 -0500")}},
+		{"拨快半小时", "15 2 * * *", lordHowe("2026-10-04 01:00 +1030"),
+			[]time.Time{lordHowe("2026-10-04 02:30 +1100"), lordHowe("2026-10-05 02:15 +1100")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			from := tt.from
+			for _, want := range tt.want {
+				got := s.Next(from)
+				if !got.Equal(want) {
+					t.Fatalf("Next(%s) = %s，应为 %s", from, got, want)
+				}
+				from = got
+			}
+		})
+	}
+}
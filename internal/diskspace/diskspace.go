@@ -0,0 +1,77 @@
+// Package diskspace 查询磁盘剩余空间和目录占用的空间，用于下载前的空间检查和容量限制。
+package diskspace
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ErrUnsupported 当前系统不支持查询磁盘剩余空间
+var ErrUnsupported = errors.New("当前系统不支持查询磁盘剩余空间")
+
+// Free 返回 path 所在磁盘当前用户可用的字节数。path 还不存在时（例如尚未创建的输出目录）
+// 使用最近的已存在的上级目录
+func Free(path string) (int64, error) {
+	dir := filepath.Clean(path)
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return free(dir)
+}
+
+// Usage 返回目录中所有文件（包括子目录）的总大小，目录不存在时为 0。
+// 同一文件的多个硬链接只计算一次，符号链接不计算
+func Usage(dir string) (int64, error) {
+	var total int64
+	seen := make(map[[2]uint64]bool)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 遍历过程中被删除的文件忽略
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				if id, ok := linkedFile(info); ok {
+					if seen[id] {
+						return nil
+					}
+					seen[id] = true
+				}
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total, err
+}
+
+// Linked 文件是否还有其他硬链接，删除它不会释放空间
+func Linked(info fs.FileInfo) bool {
+	_, ok := linkedFile(info)
+	return ok
+}
+
+// Format 把字节数格式化为 KB / MB / GB
+func Format(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	default:
+		return fmt.Sprintf("%.0f KB", float64(n)/(1<<10))
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || freebsd || windows)
+
+package diskspace
+
+import "io/fs"
+
+func free(string) (int64, error) {
+	return 0, ErrUnsupported
+}
+
+func linkedFile(fs.FileInfo) ([2]uint64, bool) {
+	return [2]uint64{}, false
+}
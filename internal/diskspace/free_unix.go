@@ -0,0 +1,25 @@
+//go:build linux || darwin || freebsd
+
+package diskspace
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+func free(dir string) (int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return int64(st.Bavail) * int64(st.Bsize), nil
+}
+
+// linkedFile 有多个硬链接的文件返回所在设备和 inode，用于只计算一次
+func linkedFile(info fs.FileInfo) (id [2]uint64, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st.Nlink < 2 {
+		return id, false
+	}
+	return [2]uint64{uint64(st.Dev), uint64(st.Ino)}, true
+}
@@ -0,0 +1,28 @@
+//go:build windows
+
+package diskspace
+
+import (
+	"io/fs"
+	"syscall"
+	"unsafe"
+)
+
+var getDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+func free(dir string) (int64, error) {
+	path, err := syscall.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+	// 第一个输出参数为当前用户可用的字节数（考虑磁盘配额）
+	var available uint64
+	if ok, _, err := getDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(path)), uintptr(unsafe.Pointer(&available)), 0, 0); ok == 0 {
+		return 0, err
+	}
+	return int64(available), nil
+}
+
+func linkedFile(fs.FileInfo) ([2]uint64, bool) {
+	return [2]uint64{}, false
+}
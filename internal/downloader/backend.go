@@ -0,0 +1,58 @@
+package downloader
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// 下载后端
+const (
+	// BackendAuto 按 URL 自动选择
+	BackendAuto = "auto"
+	// BackendNative 内置下载：m3u8 原生 HLS、知乎页面交给 Python 下载器、MP4 直链分块下载、其余直链交给 ffmpeg
+	BackendNative = "native"
+	// BackendYtDlp 交给 yt-dlp，适用于 B 站、YouTube、抖音等网站
+	BackendYtDlp = "yt-dlp"
+)
+
+// ytDlpHosts 自动选择时交给 yt-dlp 的网站（包含子域名）
+var ytDlpHosts = []string{
+	"bilibili.com", "b23.tv",
+	"youtube.com", "youtu.be",
+	"douyin.com", "iesdouyin.com", "tiktok.com",
+	"ixigua.com", "kuaishou.com", "weibo.com", "weibo.cn",
+	"vimeo.com", "twitter.com", "x.com",
+}
+
+// ResolveBackend 确定下载 rawURL 使用的后端。backend 为空或 auto 时按域名选择：
+// 知乎和 m3u8 使用内置下载，已知的视频网站使用 yt-dlp
+func ResolveBackend(rawURL, backend string) (string, error) {
+	switch backend {
+	case "", BackendAuto:
+		if isZhihuPage(rawURL) || !isYtDlpHost(rawURL) {
+			return BackendNative, nil
+		}
+		if _, err := YtDlpPath(); err != nil {
+			return "", fmt.Errorf("该网站需要 yt-dlp 下载: %v", err)
+		}
+		return BackendYtDlp, nil
+	case BackendNative:
+		return BackendNative, nil
+	case BackendYtDlp:
+		if _, err := YtDlpPath(); err != nil {
+			return "", err
+		}
+		return BackendYtDlp, nil
+	default:
+		return "", fmt.Errorf("未知的下载后端: %s（可选 auto / native / yt-dlp）", backend)
+	}
+}
+
+func isYtDlpHost(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return matchHost(strings.ToLower(u.Hostname()), ytDlpHosts...)
+}
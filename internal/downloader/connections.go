@@ -0,0 +1,42 @@
+package downloader
+
+import (
+	"fmt"
+	"sync"
+
+	"zhihu-downloader/internal/hls"
+)
+
+// MaxConnections 每个下载同时下载的分片数上限
+const MaxConnections = hls.MaxConcurrency
+
+var (
+	connectionsMu sync.RWMutex
+	// connections 配置的默认并发数，0 表示按分片数自动选择
+	connections int
+)
+
+// SetConnections 设置 m3u8 下载默认同时下载的分片数，0 表示按分片数自动选择（4–8）
+func SetConnections(n int) {
+	connectionsMu.Lock()
+	defer connectionsMu.Unlock()
+	connections = min(max(n, 0), MaxConnections)
+}
+
+// ValidateConnections 检查请求中的 connections，0 表示使用默认值
+func ValidateConnections(n int) error {
+	if n < 0 || n > MaxConnections {
+		return fmt.Errorf("connections 必须在 0–%d 之间", MaxConnections)
+	}
+	return nil
+}
+
+// segmentConnections 返回 req 使用的并发数，未指定时使用配置的默认值
+func segmentConnections(req Request) int {
+	if req.Connections > 0 {
+		return req.Connections
+	}
+	connectionsMu.RLock()
+	defer connectionsMu.RUnlock()
+	return connections
+}
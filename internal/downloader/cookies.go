@@ -0,0 +1,61 @@
+package downloader
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"zhihu-downloader/internal/auth"
+)
+
+var (
+	cookieMu     sync.RWMutex
+	cookieSource func() []auth.Cookie
+)
+
+// SetCookieSource 设置获取登录 cookies 的函数。每次请求时调用，
+// 这样通过 API 上传新的 cookies 后无需重启即可生效
+func SetCookieSource(source func() []auth.Cookie) {
+	cookieMu.Lock()
+	defer cookieMu.Unlock()
+	cookieSource = source
+}
+
+func cookies() []auth.Cookie {
+	cookieMu.RLock()
+	source := cookieSource
+	cookieMu.RUnlock()
+	if source == nil {
+		return nil
+	}
+	return source()
+}
+
+// writeCookieFile 把 cookies 写成 zhihu_downloader.py --cookies 使用的 JSON 文件，extra 为本次下载附加的 cookies，
+// 同名时替换保存的 cookie。都没有时返回空路径。调用方负责删除文件
+func writeCookieFile(extra map[string]string) (string, error) {
+	var list []auth.Cookie
+	for _, c := range cookies() {
+		if _, ok := extra[c.Name]; !ok {
+			list = append(list, c)
+		}
+	}
+	for _, name := range sortedKeys(extra) {
+		list = append(list, auth.Cookie{Name: name, Value: extra[name], Domain: auth.DefaultDomain})
+	}
+	if len(list) == 0 {
+		return "", nil
+	}
+
+	f, err := os.CreateTemp("", "zhihu-cookies-*.json")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if err := json.NewEncoder(f).Encode(list); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,44 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"zhihu-downloader/internal/hls"
+	"zhihu-downloader/internal/httpdl"
+	"zhihu-downloader/internal/logging"
+)
+
+// useDirect 判断直链是否可以不经过 ffmpeg 直接下载：指向 MP4 文件，且没有要求用 ffmpeg 处理输出（FFmpegArgs）
+func useDirect(req Request) bool {
+	return httpdl.IsDirectURL(req.URL) && len(req.FFmpegArgs) == 0
+}
+
+// downloadDirect 用 HTTP Range 请求分块并行下载 MP4 直链，进度按 Content-Length 计算。
+// 暂停或失败后再次下载时从已写入的位置继续
+func downloadDirect(ctx context.Context, req Request, onProgress func(Progress)) (string, error) {
+	downloader := httpdl.New(httpdl.Options{
+		Headers:     req.headersFor(req.URL),
+		Client:      guardedClient(0),
+		Retries:     segmentRetries(),
+		Concurrency: segmentConnections(req),
+		Logger:      logging.FromContext(ctx),
+		Limiter:     req.limiter,
+		OnProgress: func(p httpdl.Progress) {
+			onProgress(Progress{
+				Percentage:      min(99, p.Percentage()),
+				Speed:           hls.FormatSpeed(p.BytesPerSecond()),
+				BytesDownloaded: p.BytesDownloaded,
+				TotalBytes:      p.TotalBytes,
+			})
+		},
+	})
+
+	outputFile := filepath.Join(req.OutputDir, req.Filename+".mp4")
+	filePath, err := downloader.Download(ctx, req.URL, outputFile)
+	if err != nil {
+		return "", fmt.Errorf("下载失败: %w", err)
+	}
+	return filePath, nil
+}
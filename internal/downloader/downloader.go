@@ -0,0 +1,283 @@
+// Package downloader 根据 URL 类型选择下载方式：
+// m3u8 播放列表使用原生 HLS 下载，知乎页面交给 Python 下载器（支持 cookies 认证），
+// B 站、YouTube 等视频网站交给 yt-dlp，MP4 直链用 HTTP Range 请求分块下载，其余直链交给 ffmpeg。
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"zhihu-downloader/internal/hls"
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/media"
+	"zhihu-downloader/internal/ratelimit"
+)
+
+// Request 下载请求
+type Request struct {
+	URL       string
+	Quality   string
+	OutputDir string
+	// Filename 输出文件名（不含扩展名），为空时由 Prepare 按 FilenameTemplate 生成
+	Filename string
+	// FilenameTemplate 文件名模板，例如 {title}_{quality}_{date}，默认 {title}
+	FilenameTemplate string
+	// Backend 下载后端（auto / native / yt-dlp），为空时自动选择
+	Backend string
+	// Force 同一视频和清晰度已经下载过时仍然重新下载（由 tasks.Manager 处理）
+	Force bool
+	// MaxRate 下载速度上限（字节/秒），0 表示只受全局上限（SetMaxRate）限制
+	MaxRate int64
+	// Connections m3u8 同时下载的分片数（yt-dlp 为 --concurrent-fragments），0 表示使用默认值（SetConnections）
+	Connections int
+	// FFmpegArgs 用 ffmpeg 生成 MP4（m3u8 封装、ffmpeg 下载）时追加的输出选项，需要先用 media.ValidateFFmpegArgs 检查；
+	// yt-dlp 和 Python 下载器不使用
+	FFmpegArgs []string
+	// HWAccel 重新编码（转码、流水线烧录字幕）时的硬件加速方式，为空时使用全局设置（由 tasks.Manager 处理）
+	HWAccel string
+	// Transcode 下载完成后重新编码视频，Codec 为空时使用默认设置（由 tasks.Manager 处理）
+	Transcode media.TranscodeOptions
+	// Comments 下载完成后把知乎评论保存在视频旁边，最多 CommentsLimit 条根评论，0 表示全部（由 tasks.Manager 处理）
+	Comments      bool
+	CommentsLimit int
+	// Workspace 任务所属的工作区，决定默认输出目录和容量上限（由 tasks.Manager 处理）
+	Workspace string
+	// Notify 任务结束时的通知目标：配置的渠道名称、webhook 地址或 none，为空时使用所有渠道（由 tasks.Manager 处理）
+	Notify []string
+	// Priority 优先级 high / normal / low，为空时为 normal（由 tasks.Manager 处理）
+	Priority string
+	// Headers 本次下载附加的请求头，覆盖配置的请求头；Cookies 附加的 cookies（名称到值），
+	// 与已保存的登录 cookies 合并，同名时优先。需要先用 ValidateRequestHeaders 检查
+	Headers map[string]string
+	Cookies map[string]string
+
+	// Prepare 解析出的视频流和解析错误，下载时不再重复解析
+	stream     *Stream
+	resolveErr error
+	// limiter 由 Download 根据 MaxRate 和全局上限创建
+	limiter *ratelimit.Limiter
+	// remux 由 Download 设置，记录 ffmpeg 写入文件时使用的方式
+	remux *string
+}
+
+func (r Request) remuxed(strategy string) {
+	if r.remux != nil {
+		*r.remux = strategy
+	}
+}
+
+// Progress 下载进度
+type Progress struct {
+	Percentage      int
+	Speed           string
+	BytesDownloaded int64
+	// TotalBytes 预计的文件大小，未知时为 0
+	TotalBytes int64
+}
+
+// Result 下载结果
+type Result struct {
+	FilePath string
+	Size     int64
+	// Quality、Resolution 实际下载的清晰度，Title、Author 视频标题和作者，只有在 Go 中解析知乎页面时才有
+	Quality    string
+	Resolution string
+	Title      string
+	Author     string
+	// Description 视频简介，Published 发布时间，未知时为空
+	Description string
+	Published   *time.Time
+	// Remux ffmpeg 写入文件的方式：copy 直接复制、copy_mkv 复制到 MKV、reencode 重新编码；
+	// 没有经过 ffmpeg（yt-dlp、MP4 直链、fMP4 分片、保留 TS 等）时为空
+	Remux string
+}
+
+// Download 下载 req.URL 到 req.OutputDir，进度通过 onProgress 回调
+func Download(ctx context.Context, req Request, onProgress func(Progress)) (*Result, error) {
+	if onProgress == nil {
+		onProgress = func(Progress) {}
+	}
+	if err := os.MkdirAll(req.OutputDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建输出目录失败: %v", err)
+	}
+
+	startTime := time.Now()
+	req.limiter = ratelimit.New(req.MaxRate, globalLimiter())
+	var (
+		filePath string
+		stream   *Stream
+		remux    string
+	)
+	req.remux = &remux
+
+	backend, err := ResolveBackend(req.URL, req.Backend)
+	if err != nil {
+		return nil, err
+	}
+	zhihuPage := isZhihuPage(req.URL)
+	if zhihuPage {
+		if err := requireLogin(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case backend == BackendYtDlp:
+		filePath, err = downloadYtDlp(ctx, req, startTime, onProgress)
+	case hls.IsPlaylistURL(req.URL):
+		filePath, err = downloadHLS(ctx, req, onProgress)
+	case zhihuPage:
+		filePath, stream, err = downloadZhihu(ctx, req, startTime, onProgress)
+	case useDirect(req):
+		filePath, err = downloadDirect(ctx, req, onProgress)
+	default:
+		filePath, err = downloadFFmpeg(ctx, req, onProgress)
+	}
+	if err != nil && zhihuPage {
+		err = loginFailure(ctx, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil || info.Size() == 0 {
+		return nil, fmt.Errorf("文件为空或不存在")
+	}
+	result := &Result{FilePath: filePath, Size: info.Size(), Remux: remux}
+	if stream != nil {
+		result.Quality = stream.Quality
+		result.Resolution = stream.Resolution
+		result.Title = stream.Title
+		result.Author = stream.Author
+		result.Description = stream.Description
+		result.Published = stream.Published
+	}
+	return result, nil
+}
+
+// downloadZhihu 优先在 Go 中解析知乎页面并按清晰度选择视频流，解析失败时交给 Python 下载器
+func downloadZhihu(ctx context.Context, req Request, startTime time.Time, onProgress func(Progress)) (string, *Stream, error) {
+	stream, resolveErr := req.stream, req.resolveErr
+	if resolve := currentResolver(); stream == nil && resolveErr == nil && resolve != nil {
+		stream, resolveErr = resolve(ctx, req.URL, req.Quality)
+		if resolveErr != nil && ctx.Err() != nil {
+			return "", nil, ctx.Err()
+		}
+	}
+	if stream != nil {
+		streamReq := req
+		streamReq.URL = stream.URL
+		var (
+			filePath string
+			err      error
+		)
+		switch {
+		case hls.IsPlaylistURL(stream.URL):
+			filePath, err = downloadHLS(ctx, streamReq, onProgress)
+		case useDirect(streamReq):
+			filePath, err = downloadDirect(ctx, streamReq, onProgress)
+		default:
+			filePath, err = downloadFFmpeg(ctx, streamReq, onProgress)
+		}
+		return filePath, stream, err
+	}
+
+	if !pythonAvailable() {
+		if resolveErr != nil {
+			return "", nil, resolveErr
+		}
+		filePath, err := downloadFFmpeg(ctx, req, onProgress)
+		return filePath, nil, err
+	}
+	if resolveErr != nil {
+		logging.FromContext(ctx).Warn("解析知乎页面失败，改用 Python 下载器", "error", resolveErr)
+	}
+	filePath, err := downloadPython(ctx, req, startTime, onProgress)
+	return filePath, nil, err
+}
+
+func downloadHLS(ctx context.Context, req Request, onProgress func(Progress)) (string, error) {
+	downloader := hls.New(hls.Options{
+		Headers:     req.headersFor(req.URL),
+		Client:      guardedClient(2 * time.Minute),
+		Retries:     segmentRetries(),
+		Concurrency: segmentConnections(req),
+		FFmpeg:      media.FFmpeg(),
+		Logger:      logging.FromContext(ctx),
+		Limiter:     req.limiter,
+		RemuxArgs:   req.FFmpegArgs,
+		OnRemux:     req.remuxed,
+		OnProgress: func(p hls.Progress) {
+			onProgress(Progress{
+				Percentage:      min(99, p.Percentage()),
+				Speed:           hls.FormatSpeed(p.BytesPerSecond()),
+				BytesDownloaded: p.BytesDownloaded,
+				TotalBytes:      p.TotalBytes,
+			})
+		},
+	})
+
+	outputFile := filepath.Join(req.OutputDir, req.Filename+".mp4")
+	filePath, err := downloader.Download(ctx, req.URL, outputFile)
+	if err != nil {
+		return "", fmt.Errorf("下载失败: %w", err)
+	}
+	return filePath, nil
+}
+
+// EstimateSize 估算下载后的文件大小（字节），无法估算时返回 0：知乎视频使用解析到的文件大小，
+// m3u8 按码率 × 时长估算，直链使用 Content-Length。yt-dlp 和 Python 下载器下载的视频无法估算。
+// 应在 Prepare 之后调用
+func EstimateSize(ctx context.Context, req Request) int64 {
+	backend, err := ResolveBackend(req.URL, req.Backend)
+	if err != nil || backend == BackendYtDlp {
+		return 0
+	}
+	target := req.URL
+	switch {
+	case req.stream != nil && req.stream.Size > 0:
+		return req.stream.Size
+	case req.stream != nil:
+		target = req.stream.URL
+	case isZhihuPage(req.URL):
+		return 0
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	if hls.IsPlaylistURL(target) {
+		size, _ := hls.New(hls.Options{Headers: req.headersFor(target), Client: guardedClient(2 * time.Minute), Retries: -1}).EstimateSize(ctx, target)
+		return size
+	}
+
+	head, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+	if err != nil {
+		return 0
+	}
+	head.Header = req.headersFor(target)
+	resp, err := guardedClient(0).Do(head)
+	if err != nil {
+		return 0
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0
+	}
+	return max(resp.ContentLength, 0)
+}
+
+func isZhihuPage(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	return host == "zhihu.com" || strings.HasSuffix(host, ".zhihu.com")
+}
@@ -0,0 +1,147 @@
+package downloader
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"zhihu-downloader/internal/atomicfile"
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/hls"
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/media"
+	"zhihu-downloader/internal/proc"
+)
+
+// downloadFFmpeg 使用 ffmpeg 下载直链，进度按 out_time 与总时长计算。直接复制音视频流失败时
+// 按 ffmpeg 的输出改为封装 MKV 或重新编码后重试，成功时通过 req.remuxed 记录使用的方式
+func downloadFFmpeg(ctx context.Context, req Request, onProgress func(Progress)) (string, error) {
+	// 探测时长和每次尝试使用同一组请求头（UA 轮换时保持一致）
+	headers := ffmpegHeaders(req.headersFor(req.URL))
+	duration := media.DurationWithHeaders(req.URL, headers)
+
+	input := req.URL
+	if req.limiter.Rate() > 0 {
+		proxyURL, stop, err := limitedProxy(ctx, req.URL, req.limiter)
+		if err != nil {
+			return "", err
+		}
+		defer stop()
+		input = proxyURL
+	}
+
+	strategy := media.RemuxCopy
+	for {
+		outputFile, output, err := runFFmpeg(ctx, req, input, headers, strategy, duration, onProgress)
+		if err == nil {
+			req.remuxed(strategy)
+			return outputFile, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		next := media.NextRemux(strategy, output)
+		if next == "" {
+			// 按错误和 ffmpeg 的输出一起归类（没有找到 ffmpeg、HTTP 错误等）
+			code := errcode.Of(fmt.Errorf("%v\n%s", err, output), errcode.DownloadFailed)
+			return "", &errcode.Error{Code: code, Message: fmt.Sprintf("下载失败: %v", err), Detail: output}
+		}
+		logging.FromContext(ctx).Warn("ffmpeg 封装失败，换一种方式重试", "strategy", strategy, "next", next, "output", output)
+		strategy = next
+	}
+}
+
+// runFFmpeg 按 strategy 执行一次 ffmpeg 下载，失败时删除输出文件，并返回 stderr 的最后几行
+func runFFmpeg(ctx context.Context, req Request, input, headers, strategy string, duration float64, onProgress func(Progress)) (string, string, error) {
+	codec, ext := media.RemuxArgs(strategy)
+	outputFile := filepath.Join(req.OutputDir, req.Filename+ext)
+	// 先写入 .part 文件，完成后再重命名，中断时不会留下看起来完整的视频
+	partFile := atomicfile.MediaPath(outputFile)
+	startTime := time.Now()
+
+	args := append([]string{"-y", "-headers", headers, "-i", input}, codec...)
+	args = append(append(args, "-progress", "pipe:1", "-nostats"), req.FFmpegArgs...)
+	cmd := proc.Command(ctx, media.FFmpeg(), append(args, partFile)...)
+
+	stdout, _ := cmd.StdoutPipe()
+	stderr := logging.Writer(ctx, "ffmpeg")
+	defer stderr.Close()
+	tail := &tailWriter{}
+	cmd.Stderr = io.MultiWriter(stderr, tail)
+	if err := cmd.Start(); err != nil {
+		return "", "", fmt.Errorf("启动 ffmpeg 失败: %v", err)
+	}
+
+	var (
+		outTime   float64
+		totalSize int64
+		pct       int
+	)
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "out_time_us":
+			if us, err := strconv.ParseInt(value, 10, 64); err == nil {
+				outTime = float64(us) / 1e6
+			}
+		case "total_size":
+			totalSize, _ = strconv.ParseInt(value, 10, 64)
+		case "progress":
+			if duration > 0 {
+				pct = int(outTime / duration * 100)
+			} else {
+				// 无法获取时长时只能缓慢递增
+				pct++
+			}
+			elapsed := time.Since(startTime).Seconds()
+			speed := ""
+			if elapsed > 0 {
+				speed = hls.FormatSpeed(float64(totalSize) / elapsed)
+			}
+			// 按已写入的时长估算文件总大小
+			var total int64
+			if duration > 0 && outTime > 0 {
+				total = int64(float64(totalSize) / outTime * duration)
+			}
+			onProgress(Progress{Percentage: min(99, pct), Speed: speed, BytesDownloaded: totalSize, TotalBytes: total})
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		os.Remove(partFile)
+		return "", tail.String(), err
+	}
+	if err := atomicfile.Commit(partFile, outputFile); err != nil {
+		return "", "", err
+	}
+	return outputFile, "", nil
+}
+
+// tailWriter 保留写入内容的最后 tailSize 字节
+type tailWriter struct {
+	buf []byte
+}
+
+const tailSize = 4096
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	if len(w.buf) > tailSize {
+		w.buf = w.buf[len(w.buf)-tailSize:]
+	}
+	return len(p), nil
+}
+
+func (w *tailWriter) String() string {
+	return strings.TrimSpace(string(w.buf))
+}
@@ -0,0 +1,173 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/hls"
+)
+
+// DefaultFilenameTemplate 默认文件名模板：视频标题
+const DefaultFilenameTemplate = "{title}"
+
+// maxFilenameBytes 文件名（不含扩展名）的最大字节数，给 .mp4.parts 等后缀留出余量
+const maxFilenameBytes = 200
+
+// 文件名模板变量
+var templateVars = map[string]bool{
+	"title": true, "author": true, "quality": true, "resolution": true, "date": true, "id": true,
+}
+
+var (
+	templateVarRe   = regexp.MustCompile(`\{([a-z_]+)\}`)
+	illegalFileRe   = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1f]`)
+	repeatedSepRe   = regexp.MustCompile(`\s*_[\s_]*`)
+	windowsReserved = regexp.MustCompile(`(?i)^(con|prn|aux|nul|com[0-9]|lpt[0-9])$`)
+)
+
+// ValidateFilenameTemplate 检查模板中的变量，可用 {title} {author} {quality} {resolution} {date} {id}
+func ValidateFilenameTemplate(tmpl string) error {
+	for _, m := range templateVarRe.FindAllStringSubmatch(tmpl, -1) {
+		if !templateVars[m[1]] {
+			return fmt.Errorf("文件名模板中有未知变量 {%s}（可用 {title} {author} {quality} {resolution} {date} {id}）", m[1])
+		}
+	}
+	if strings.ContainsAny(templateVarRe.ReplaceAllString(tmpl, ""), "{}") {
+		return fmt.Errorf("文件名模板中有无法识别的 { 或 }（变量只能是小写字母）: %s", tmpl)
+	}
+	return nil
+}
+
+// ValidateFilename 检查调用方指定的文件名：不能包含路径分隔符，也不能是 . 或 ..，
+// 避免与输出目录拼接后写到允许的目录之外
+func ValidateFilename(name string) error {
+	if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return errcode.Newf(errcode.InvalidArgument, "无效的文件名: %s", name)
+	}
+	return nil
+}
+
+// FilenameData 文件名模板变量的值
+type FilenameData struct {
+	Title      string
+	Author     string
+	Quality    string
+	Resolution string
+	Date       time.Time
+	// ID 任务短 ID，没有标题时文件名为 video_<ID>
+	ID string
+}
+
+// ExpandFilename 展开文件名模板并清理非法字符，结果不含扩展名
+func ExpandFilename(tmpl string, d FilenameData) string {
+	if tmpl == "" {
+		tmpl = DefaultFilenameTemplate
+	}
+	title := d.Title
+	if strings.TrimSpace(title) == "" {
+		title = "video_" + d.ID
+	}
+	values := map[string]string{
+		"title":      title,
+		"author":     d.Author,
+		"quality":    d.Quality,
+		"resolution": d.Resolution,
+		"date":       d.Date.Format("20060102"),
+		"id":         d.ID,
+	}
+	name := templateVarRe.ReplaceAllStringFunc(tmpl, func(v string) string {
+		return SanitizeFilename(values[v[1:len(v)-1]])
+	})
+	name = SanitizeFilename(name)
+	if name == "" {
+		name = "video_" + d.ID
+	}
+	return name
+}
+
+// SanitizeFilename 替换文件名中的非法字符，去掉首尾的空格和点，并限制长度
+func SanitizeFilename(name string) string {
+	name = illegalFileRe.ReplaceAllString(name, "_")
+	name = strings.Join(strings.Fields(name), " ")
+	name = repeatedSepRe.ReplaceAllString(name, "_")
+	name = strings.Trim(name, " ._-")
+	if windowsReserved.MatchString(name) {
+		name = "_" + name
+	}
+
+	// 按字节截断，避免切断多字节字符
+	if len(name) > maxFilenameBytes {
+		cut := maxFilenameBytes
+		for cut > 0 && !utf8.RuneStart(name[cut]) {
+			cut--
+		}
+		name = strings.TrimRight(name[:cut], " ._-")
+	}
+	return name
+}
+
+// UniqueFilename 输出目录中已有同名文件（或正在下载的临时文件）时追加 _1、_2…
+func UniqueFilename(dir, name, ext string) string {
+	exists := func(base string) bool {
+		for _, suffix := range []string{ext, ext + ".parts", ext + ".tmp"} {
+			if _, err := os.Stat(filepath.Join(dir, base+suffix)); err == nil {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !exists(name) {
+		return name
+	}
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s_%d", name, i)
+		if !exists(candidate) {
+			return candidate
+		}
+	}
+}
+
+// Prepare 在下载前确定输出文件名：req.Filename 为空时解析知乎页面获取标题，
+// 按 req.FilenameTemplate 生成不与已有文件冲突的文件名。id 用于没有标题时的默认文件名
+func Prepare(ctx context.Context, req *Request, id string) error {
+	backend, err := ResolveBackend(req.URL, req.Backend)
+	if err != nil {
+		return err
+	}
+
+	if backend == BackendNative && isZhihuPage(req.URL) && !hls.IsPlaylistURL(req.URL) && req.stream == nil {
+		if resolve := currentResolver(); resolve != nil {
+			stream, err := resolve(ctx, req.URL, req.Quality)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err == nil {
+				req.stream = stream
+			} else {
+				req.resolveErr = err
+			}
+		}
+	}
+	if req.Filename != "" {
+		return nil
+	}
+
+	data := FilenameData{Quality: req.Quality, Date: time.Now(), ID: id}
+	if req.stream != nil {
+		data.Title = req.stream.Title
+		data.Author = req.stream.Author
+		data.Quality = req.stream.Quality
+		data.Resolution = req.stream.Resolution
+	}
+	name := ExpandFilename(req.FilenameTemplate, data)
+	req.Filename = UniqueFilename(req.OutputDir, name, ".mp4")
+	return nil
+}
@@ -0,0 +1,260 @@
+package downloader
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"zhihu-downloader/internal/auth"
+	"zhihu-downloader/internal/errcode"
+)
+
+// UserAgent 访问知乎及其 CDN 时默认使用的浏览器 UA
+const UserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
+// DefaultReferer 访问知乎及其 CDN 时默认的 Referer，CDN 没有它时返回 403
+const DefaultReferer = "https://www.zhihu.com/"
+
+// defaultUserAgents 没有配置 UA 时轮换使用的常见桌面浏览器 UA
+var defaultUserAgents = []string{
+	UserAgent,
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0",
+}
+
+// zhihuHosts 知乎及其图片、视频 CDN 的域名（包含子域名），使用 HeaderPolicy.Referer
+var zhihuHosts = []string{"zhihu.com", "zhimg.com", "vzuu.com"}
+
+// HeaderPolicy 内置下载（m3u8、MP4 直链、ffmpeg、解析知乎页面）发出的请求使用的请求头
+type HeaderPolicy struct {
+	// UserAgents 轮换使用的 UA，每个下载取下一个，为空时使用内置的几个常见浏览器 UA
+	UserAgents []string
+	// Referer 访问知乎及其 CDN 时的 Referer，为空时为 DefaultReferer；其他网站使用该网站的首页
+	Referer string
+	// Extra 附加到所有请求的请求头
+	Extra map[string]string
+	// Hosts 按域名（包含子域名）附加的请求头，优先于 Extra，可以覆盖 User-Agent 和 Referer
+	Hosts map[string]map[string]string
+}
+
+var (
+	headerMu     sync.RWMutex
+	headerPolicy HeaderPolicy
+	// uaIndex 下一个使用的 UA
+	uaIndex atomic.Uint64
+)
+
+// SetHeaderPolicy 设置请求头的规则，需要先用 ValidateHeaderPolicy 检查
+func SetHeaderPolicy(p HeaderPolicy) {
+	headerMu.Lock()
+	defer headerMu.Unlock()
+	hosts := make(map[string]map[string]string, len(p.Hosts))
+	for host, h := range p.Hosts {
+		hosts[strings.ToLower(strings.TrimPrefix(host, "."))] = h
+	}
+	p.Hosts = hosts
+	headerPolicy = p
+}
+
+// ValidateHeaderPolicy 检查请求头的名称和取值，取值中不能有换行（会原样写入 ffmpeg 的 -headers 参数）
+func ValidateHeaderPolicy(p HeaderPolicy) error {
+	for _, ua := range p.UserAgents {
+		if err := validateHeader("User-Agent", ua); err != nil {
+			return err
+		}
+	}
+	if p.Referer != "" {
+		if u, err := url.Parse(p.Referer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("referer 必须是 http(s) 地址: %s", p.Referer)
+		}
+	}
+	if err := ValidateHeaders(p.Extra); err != nil {
+		return err
+	}
+	for host, h := range p.Hosts {
+		if host == "" || strings.ContainsAny(host, "/:") {
+			return fmt.Errorf("hosts 中的域名无效: %q", host)
+		}
+		if err := ValidateHeaders(h); err != nil {
+			return fmt.Errorf("%s: %v", host, err)
+		}
+	}
+	return nil
+}
+
+// ValidateHeaders 检查请求头的名称和取值
+func ValidateHeaders(headers map[string]string) error {
+	for name, value := range headers {
+		if err := validateHeader(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateRequestHeaders 检查下载请求附加的请求头和 cookies。cookie 的名称不能包含空白和分隔符，
+// 取值不能包含分号、逗号和换行
+func ValidateRequestHeaders(headers, cookies map[string]string) error {
+	if err := ValidateHeaders(headers); err != nil {
+		return errcode.Newf(errcode.InvalidArgument, "headers: %v", err)
+	}
+	for name, value := range cookies {
+		if !validToken(name) {
+			return errcode.Newf(errcode.InvalidArgument, "cookies: 名称无效: %q", name)
+		}
+		if strings.ContainsAny(value, ";,\r\n\x00") {
+			return errcode.Newf(errcode.InvalidArgument, "cookies: %s 的值不能包含分号、逗号和换行", name)
+		}
+	}
+	return nil
+}
+
+func validateHeader(name, value string) error {
+	if !validToken(name) {
+		return fmt.Errorf("请求头名称无效: %q", name)
+	}
+	if strings.ContainsAny(value, "\r\n\x00") {
+		return fmt.Errorf("请求头 %s 的值不能包含换行", name)
+	}
+	return nil
+}
+
+// validToken 判断是否是 HTTP 请求头和 cookie 允许的名称
+func validToken(name string) bool {
+	return name != "" && strings.IndexFunc(name, func(r rune) bool {
+		return r <= ' ' || r >= 0x7f || strings.ContainsRune(`()<>@,;:\"/[]?={}`, r)
+	}) < 0
+}
+
+// Headers 返回访问知乎需要的请求头：轮换的 UA、知乎的 Referer 和附加的请求头
+func Headers() http.Header {
+	return HeadersFor(DefaultReferer)
+}
+
+// HeadersFor 返回请求 rawURL 使用的请求头：轮换的 UA，知乎及其 CDN 使用配置的 Referer、其他网站使用该网站的首页，
+// 再加上配置的附加请求头和按域名的请求头。知乎域名会附带已保存的登录 cookies
+func HeadersFor(rawURL string) http.Header {
+	headerMu.RLock()
+	p := headerPolicy
+	headerMu.RUnlock()
+
+	userAgents := p.UserAgents
+	if len(userAgents) == 0 {
+		userAgents = defaultUserAgents
+	}
+	h := http.Header{}
+	h.Set("User-Agent", userAgents[(uaIndex.Add(1)-1)%uint64(len(userAgents))])
+
+	host := ""
+	if u, err := url.Parse(rawURL); err == nil {
+		host = strings.ToLower(u.Hostname())
+		switch {
+		case matchHost(host, zhihuHosts...):
+			h.Set("Referer", p.Referer)
+			if p.Referer == "" {
+				h.Set("Referer", DefaultReferer)
+			}
+		case host != "" && (u.Scheme == "http" || u.Scheme == "https"):
+			h.Set("Referer", u.Scheme+"://"+u.Host+"/")
+		}
+	}
+	for name, value := range p.Extra {
+		h.Set(name, value)
+	}
+	// 短的域名先设置，子域名的规则覆盖上级域名的
+	patterns := make([]string, 0, len(p.Hosts))
+	for pattern := range p.Hosts {
+		if matchHost(host, pattern) {
+			patterns = append(patterns, pattern)
+		}
+	}
+	sort.Slice(patterns, func(i, j int) bool { return len(patterns[i]) < len(patterns[j]) })
+	for _, pattern := range patterns {
+		for name, value := range p.Hosts[pattern] {
+			h.Set(name, value)
+		}
+	}
+
+	if isZhihuPage(rawURL) {
+		if cookie := auth.Header(cookies()); cookie != "" {
+			h.Set("Cookie", cookie)
+		}
+	}
+	return h
+}
+
+// headersFor 返回本次下载请求 rawURL 使用的请求头：HeadersFor 的结果加上 r.Headers 和 r.Cookies
+func (r Request) headersFor(rawURL string) http.Header {
+	h := HeadersFor(rawURL)
+	for name, value := range r.extraHeaders(rawURL) {
+		h.Set(name, value)
+	}
+	return h
+}
+
+// extraHeaders 返回本次下载请求 rawURL 附加的请求头，r.Cookies 与 r.Headers 中的 Cookie 和已保存的登录 cookies
+// （知乎页面）合并为 Cookie 请求头，同名的 cookie 使用 r.Cookies 中的值
+func (r Request) extraHeaders(rawURL string) map[string]string {
+	if len(r.Headers) == 0 && len(r.Cookies) == 0 {
+		return nil
+	}
+	extra := make(map[string]string, len(r.Headers)+1)
+	for name, value := range r.Headers {
+		extra[http.CanonicalHeaderKey(name)] = value
+	}
+	if len(r.Cookies) == 0 {
+		return extra
+	}
+	cookie := extra["Cookie"]
+	if cookie == "" && isZhihuPage(rawURL) {
+		cookie = auth.Header(cookies())
+	}
+	var pairs []string
+	for _, pair := range strings.Split(cookie, ";") {
+		name, _, _ := strings.Cut(strings.TrimSpace(pair), "=")
+		if _, ok := r.Cookies[name]; !ok && name != "" {
+			pairs = append(pairs, strings.TrimSpace(pair))
+		}
+	}
+	for _, name := range sortedKeys(r.Cookies) {
+		pairs = append(pairs, name+"="+r.Cookies[name])
+	}
+	extra["Cookie"] = strings.Join(pairs, "; ")
+	return extra
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// matchHost 判断 host 是否是 domains 之一或其子域名
+func matchHost(host string, domains ...string) bool {
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// ffmpegHeaders 把请求头转换为 ffmpeg -headers 参数的格式
+func ffmpegHeaders(h http.Header) string {
+	var b strings.Builder
+	for key, values := range h {
+		for _, v := range values {
+			fmt.Fprintf(&b, "%s: %s\r\n", key, v)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,192 @@
+package downloader
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"zhihu-downloader/internal/hls"
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/proc"
+	"zhihu-downloader/internal/ratelimit"
+)
+
+// 百分比匹配正则，支持 "下载进度: 77.1%"、"下载中... 77%" 等格式
+var percentRe = regexp.MustCompile(`(\d+\.?\d*)%`)
+
+// scriptDir 返回 zhihu_downloader.py 所在目录（与可执行文件同目录）
+func scriptDir() string {
+	execPath, err := os.Executable()
+	if err != nil {
+		return "."
+	}
+	return filepath.Dir(execPath)
+}
+
+// 配置中指定的 Python 解释器与脚本路径，为空时自动查找
+var (
+	pythonMu         sync.RWMutex
+	pythonPath       string
+	pythonScriptPath string
+)
+
+// SetPython 设置 Python 解释器和 zhihu_downloader.py 的路径，空字符串表示自动查找
+func SetPython(interpreter, script string) {
+	pythonMu.Lock()
+	defer pythonMu.Unlock()
+	pythonPath = interpreter
+	pythonScriptPath = script
+}
+
+// PythonScript 返回 zhihu_downloader.py 路径，默认在可执行文件旁边
+func PythonScript() string {
+	pythonMu.RLock()
+	defer pythonMu.RUnlock()
+	if pythonScriptPath != "" {
+		return pythonScriptPath
+	}
+	return filepath.Join(scriptDir(), "zhihu_downloader.py")
+}
+
+// PythonInterpreter 返回运行 zhihu_downloader.py 的解释器：优先使用配置的解释器，其次是脚本目录下的虚拟环境和 PATH 中的 Python
+func PythonInterpreter() string {
+	pythonMu.RLock()
+	configured := pythonPath
+	pythonMu.RUnlock()
+	if configured != "" {
+		return configured
+	}
+
+	return proc.Python(filepath.Dir(PythonScript()))
+}
+
+func pythonAvailable() bool {
+	_, err := os.Stat(PythonScript())
+	return err == nil
+}
+
+// downloadPython 调用 Python 知乎下载器（支持 cookies 认证），文件名由脚本决定
+func downloadPython(ctx context.Context, req Request, startTime time.Time, onProgress func(Progress)) (string, error) {
+	quality := req.Quality
+	switch quality {
+	case "":
+		quality = QualityFHD
+	case QualityBest:
+		// 脚本找不到指定清晰度时会选择最高清晰度
+		quality = QualityUHD
+	}
+
+	args := []string{PythonScript(), req.URL, "-o", req.OutputDir, "-q", quality}
+	// UA、Referer 和附加的请求头与内置下载一致，登录 cookies 和本次下载附加的 cookies 通过文件传递
+	for key, values := range req.headersFor(req.URL) {
+		if key == "Cookie" {
+			continue
+		}
+		for _, v := range values {
+			args = append(args, "--header", key+": "+v)
+		}
+	}
+	if rate := req.limiter.Rate(); rate > 0 {
+		logging.FromContext(ctx).Warn("Python 下载器不支持限速，本次下载不受速度上限限制", "max_rate", ratelimit.Format(rate))
+	}
+	if len(req.FFmpegArgs) > 0 {
+		logging.FromContext(ctx).Warn("Python 下载器不使用 ffmpeg_args", "ffmpeg_args", req.FFmpegArgs)
+	}
+
+	// 已保存登录 cookies 时交给脚本使用，否则脚本自行从 Chrome 读取
+	cookieFile, err := writeCookieFile(req.Cookies)
+	if err != nil {
+		return "", fmt.Errorf("写入 cookies 失败: %v", err)
+	}
+	if cookieFile != "" {
+		defer os.Remove(cookieFile)
+		args = append(args, "-c", cookieFile)
+	}
+
+	cmd := proc.Command(ctx, PythonInterpreter(), args...)
+
+	// 获取 stdout 管道实时读取进度
+	stdout, _ := cmd.StdoutPipe()
+	cmd.Stderr = cmd.Stdout // 合并 stderr 到 stdout
+
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("启动失败: %v", err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	var lastOutput strings.Builder
+	lastPct := 0
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		lastOutput.WriteString(line + "\n")
+		logging.Output(ctx, "python", line)
+
+		if matches := percentRe.FindStringSubmatch(line); len(matches) > 1 {
+			if pct, err := strconv.ParseFloat(matches[1], 64); err == nil && int(pct) > lastPct {
+				lastPct = int(pct)
+				onProgress(pythonProgress(req.OutputDir, startTime, lastPct))
+			}
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("%v: %s", err, lastOutput.String())
+	}
+
+	// 查找下载的 mp4 文件（Python 脚本会自动命名）
+	return latestFile(req.OutputDir, "*.mp4", startTime)
+}
+
+// latestFile 在 dir 中查找 startTime 之后生成的最新文件
+// pythonProgress 脚本只输出百分比，已下载的字节数取自正在写入的文件，总大小按百分比估算
+func pythonProgress(dir string, startTime time.Time, pct int) Progress {
+	p := Progress{Percentage: min(99, pct)}
+	path, err := latestFile(dir, "*.mp4", startTime)
+	if err != nil {
+		return p
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return p
+	}
+	p.BytesDownloaded = info.Size()
+	if pct > 0 {
+		p.TotalBytes = p.BytesDownloaded * 100 / int64(pct)
+	}
+	if elapsed := time.Since(startTime).Seconds(); elapsed >= 1 {
+		p.Speed = hls.FormatSpeed(float64(p.BytesDownloaded) / elapsed)
+	}
+	return p
+}
+
+func latestFile(dir, pattern string, startTime time.Time) (string, error) {
+	matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+	if len(matches) == 0 {
+		return "", fmt.Errorf("文件为空或不存在")
+	}
+
+	var latest string
+	var latestTime time.Time
+	for _, m := range matches {
+		info, err := os.Stat(m)
+		if err == nil && info.ModTime().After(latestTime) {
+			latestTime = info.ModTime()
+			latest = m
+		}
+	}
+	if latest == "" || !latestTime.After(startTime.Add(-time.Minute)) {
+		return "", fmt.Errorf("未找到新下载的文件")
+	}
+	return latest, nil
+}
@@ -0,0 +1,90 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 清晰度，从高到低
+const (
+	QualityUHD = "uhd"
+	QualityFHD = "fhd"
+	QualityHD  = "hd"
+	QualitySD  = "sd"
+	QualityLD  = "ld"
+	// QualityBest 可用的最高清晰度
+	QualityBest = "best"
+)
+
+// Qualities 从高到低排列的清晰度
+var Qualities = []string{QualityUHD, QualityFHD, QualityHD, QualitySD, QualityLD}
+
+// qualityAliases 接受的清晰度写法
+var qualityAliases = map[string]string{
+	"uhd": QualityUHD, "4k": QualityUHD, "2160p": QualityUHD,
+	"fhd": QualityFHD, "1080p": QualityFHD,
+	"hd": QualityHD, "720p": QualityHD,
+	"sd": QualitySD, "480p": QualitySD,
+	"ld": QualityLD, "360p": QualityLD,
+	"best": QualityBest,
+}
+
+// NormalizeQuality 把 "4k"、"1080p" 等写法转换为 uhd/fhd/hd/sd/ld/best，空字符串原样返回
+func NormalizeQuality(q string) (string, error) {
+	if q == "" {
+		return "", nil
+	}
+	if normalized, ok := qualityAliases[strings.ToLower(strings.TrimSpace(q))]; ok {
+		return normalized, nil
+	}
+	return "", fmt.Errorf("不支持的清晰度: %s（可选 best / uhd(4k) / fhd / hd / sd / ld）", q)
+}
+
+// QualityRank 清晰度排名，越小越清晰，未知清晰度排在最后
+func QualityRank(q string) int {
+	for i, name := range Qualities {
+		if name == q {
+			return i
+		}
+	}
+	return len(Qualities)
+}
+
+// Stream 从知乎页面解析出的视频流
+type Stream struct {
+	URL     string
+	Quality string
+	// Resolution 分辨率，例如 1920x1080
+	Resolution string
+	Title      string
+	Author     string
+	// Description 视频简介，Published 发布时间，未知时为空
+	Description string
+	Published   *time.Time
+	// Size 文件大小（字节），未知时按码率和时长估算，无法估算时为 0
+	Size int64
+}
+
+// Resolver 解析知乎视频页面，按清晰度选择视频流
+type Resolver func(ctx context.Context, pageURL, quality string) (*Stream, error)
+
+var (
+	resolverMu sync.RWMutex
+	resolver   Resolver
+)
+
+// SetResolver 设置知乎页面的解析函数。解析失败时退回 Python 下载器
+func SetResolver(r Resolver) {
+	resolverMu.Lock()
+	defer resolverMu.Unlock()
+	resolver = r
+}
+
+func currentResolver() Resolver {
+	resolverMu.RLock()
+	defer resolverMu.RUnlock()
+	return resolver
+}
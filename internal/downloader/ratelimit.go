@@ -0,0 +1,88 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/ratelimit"
+)
+
+var (
+	rateMu     sync.RWMutex
+	rateGlobal *ratelimit.Limiter
+)
+
+// SetMaxRate 设置所有下载共用的速度上限（字节/秒），0 表示不限速。
+// 内置下载（m3u8 分片、直链）合计不超过该速度；yt-dlp 每个进程单独按该速度限速
+func SetMaxRate(bytesPerSecond int64) {
+	rateMu.Lock()
+	defer rateMu.Unlock()
+	rateGlobal = nil
+	if bytesPerSecond > 0 {
+		rateGlobal = ratelimit.New(bytesPerSecond, nil)
+	}
+}
+
+// MaxRate 返回全局速度上限，0 表示不限速
+func MaxRate() int64 {
+	return globalLimiter().Rate()
+}
+
+func globalLimiter() *ratelimit.Limiter {
+	rateMu.RLock()
+	defer rateMu.RUnlock()
+	return rateGlobal
+}
+
+// limitedProxy 在本机启动一个限速的 HTTP 代理，返回代替 target 交给 ffmpeg 的地址。
+// ffmpeg 没有限制输入速度的参数，通过代理读取时受 limiter 限制；请求头（包括 Range）原样转发。
+// 下载结束后调用 stop 关闭代理
+func limitedProxy(ctx context.Context, target string, limiter *ratelimit.Limiter) (proxyURL string, stop func(), err error) {
+	upstream, err := url.Parse(target)
+	if err != nil {
+		return "", nil, fmt.Errorf("无效的下载地址: %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, fmt.Errorf("启动限速代理失败: %v", err)
+	}
+
+	logger := logging.FromContext(ctx)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := *upstream
+		u.RawQuery = r.URL.RawQuery
+		req, err := http.NewRequestWithContext(r.Context(), r.Method, u.String(), nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		req.Header = r.Header.Clone()
+		resp, err := guardedClient(0).Do(req)
+		if err != nil {
+			logger.Warn("限速代理请求失败", "error", err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+
+		for key, values := range resp.Header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, ratelimit.Reader(r.Context(), resp.Body, limiter))
+	})}
+	go server.Serve(ln)
+
+	// 代理地址保留原来的路径和查询参数，ffmpeg 按扩展名识别格式时不受影响
+	proxy := *upstream
+	proxy.Scheme = "http"
+	proxy.Host = ln.Addr().String()
+	proxy.User = nil
+	return proxy.String(), func() { server.Close() }, nil
+}
@@ -0,0 +1,42 @@
+package downloader
+
+import (
+	"errors"
+	"sync"
+
+	"zhihu-downloader/internal/auth"
+	"zhihu-downloader/internal/hls"
+)
+
+// DefaultMaxRetries 默认的重试次数（m3u8 的每个分片、每个下载任务）
+const DefaultMaxRetries = 3
+
+var (
+	retryMu    sync.RWMutex
+	maxRetries = DefaultMaxRetries
+)
+
+// SetMaxRetries 设置 m3u8 分片下载失败后的重试次数，0 表示不重试
+func SetMaxRetries(n int) {
+	retryMu.Lock()
+	defer retryMu.Unlock()
+	maxRetries = max(n, 0)
+}
+
+// segmentRetries 返回 hls.Options.Retries 的值（hls 中 0 表示默认值，负数表示不重试）
+func segmentRetries() int {
+	retryMu.RLock()
+	defer retryMu.RUnlock()
+	if maxRetries == 0 {
+		return -1
+	}
+	return maxRetries
+}
+
+// Retryable 判断下载失败后重新下载是否可能成功。服务器明确拒绝（403、404 等）的请求和登录失效不再重试
+func Retryable(err error) bool {
+	if errors.Is(err, auth.ErrLoginRequired) {
+		return false
+	}
+	return hls.Temporary(err)
+}
@@ -0,0 +1,164 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"zhihu-downloader/internal/auth"
+	"zhihu-downloader/internal/logging"
+)
+
+// sessionCheckURL 返回当前登录用户的接口，未登录时返回 401
+const sessionCheckURL = "https://www.zhihu.com/api/v4/me"
+
+// DefaultSessionInterval 后台检查登录状态的默认间隔
+const DefaultSessionInterval = 6 * time.Hour
+
+// SessionMaxAge 下载前和健康检查使用的检查结果的有效期，超过后重新检查
+const SessionMaxAge = 10 * time.Minute
+
+var (
+	sessionMu sync.Mutex
+	session   auth.Session
+	// sessionCookie 上次检查使用的 Cookie 请求头，cookies 更换后检查结果作废
+	sessionCookie string
+
+	sessionClient = &http.Client{Timeout: 15 * time.Second}
+)
+
+// CheckSession 用保存的 cookies 请求知乎验证登录状态并记录结果。
+// cookies 中没有登录凭证 z_c0 时不检查，返回 false
+func CheckSession(ctx context.Context) (auth.Session, bool) {
+	list := cookies()
+	login, ok := auth.LoginCookieOf(list)
+	if !ok {
+		return auth.Session{}, false
+	}
+	header := auth.Header(list)
+	s := auth.Session{CheckedAt: time.Now()}
+	if login.Expired() {
+		s.State = auth.SessionExpired
+		s.Error = fmt.Sprintf("登录凭证 %s 已于 %s 过期", auth.LoginCookie, login.Expires.Format("2006-01-02 15:04"))
+	} else {
+		user, err := verifySession(ctx, header)
+		switch {
+		case errors.Is(err, auth.ErrLoginRequired):
+			s.State, s.Error = auth.SessionExpired, "知乎返回 401，cookies 中的登录已失效"
+		case err != nil:
+			s.State, s.Error = auth.SessionUnknown, err.Error()
+		case !login.Expires.IsZero() && time.Until(login.Expires) < auth.ExpiringWithin:
+			s.State, s.User = auth.SessionExpiring, user
+			s.Error = fmt.Sprintf("登录凭证将于 %s 过期，请尽快重新登录", login.Expires.Format("2006-01-02 15:04"))
+		default:
+			s.State, s.User = auth.SessionValid, user
+		}
+	}
+
+	sessionMu.Lock()
+	session, sessionCookie = s, header
+	sessionMu.Unlock()
+	return s, true
+}
+
+// LoginSession 返回最近一次的检查结果，超过 maxAge 没有检查或 cookies 已更换时重新检查。
+// 没有保存登录凭证时返回 false
+func LoginSession(ctx context.Context, maxAge time.Duration) (auth.Session, bool) {
+	list := cookies()
+	if _, ok := auth.LoginCookieOf(list); !ok {
+		return auth.Session{}, false
+	}
+	sessionMu.Lock()
+	s, header := session, sessionCookie
+	sessionMu.Unlock()
+	if header == auth.Header(list) && !s.CheckedAt.IsZero() && time.Since(s.CheckedAt) < maxAge {
+		return s, true
+	}
+	return CheckSession(ctx)
+}
+
+// verifySession 带上 cookies 请求当前用户信息，返回用户名；cookies 已失效时返回 auth.ErrLoginRequired
+func verifySession(ctx context.Context, cookie string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sessionCheckURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header = Headers()
+	req.Header.Set("Cookie", cookie)
+
+	resp, err := sessionClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var me struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&me); err != nil {
+			return "", fmt.Errorf("解析用户信息失败: %v", err)
+		}
+		return me.Name, nil
+	case http.StatusUnauthorized:
+		return "", auth.ErrLoginRequired
+	default:
+		// 403 等可能是反爬限制，不能据此判断登录失效
+		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, sessionCheckURL)
+	}
+}
+
+// KeepSessionAlive 每隔 interval 检查一次登录状态直到 ctx 取消，定期的请求同时让知乎保持会话活跃。
+// 状态变为失效或即将过期时写警告日志，interval 为 0 时不检查
+func KeepSessionAlive(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	logger := logging.FromContext(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last string
+	for {
+		if s, ok := CheckSession(ctx); ok && s.State != last {
+			switch s.State {
+			case auth.SessionValid:
+				logger.Info("知乎登录有效", "user", s.User)
+			case auth.SessionExpiring, auth.SessionExpired:
+				logger.Warn("知乎登录即将过期或已失效，请重新上传 cookies", "state", s.State, "detail", s.Error)
+			default:
+				logger.Warn("检查知乎登录状态失败", "error", s.Error)
+			}
+			last = s.State
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// requireLogin 保存的登录已确认失效时返回 auth.ErrLoginRequired，没有登录或无法判断时不阻止下载
+func requireLogin(ctx context.Context) error {
+	if s, ok := LoginSession(ctx, SessionMaxAge); ok && s.State == auth.SessionExpired {
+		return fmt.Errorf("%w（%s）", auth.ErrLoginRequired, s.Error)
+	}
+	return nil
+}
+
+// loginFailure 下载知乎页面失败后重新检查登录，登录已失效时把 err 换成需要重新登录的错误
+func loginFailure(ctx context.Context, err error) error {
+	if ctx.Err() != nil || errors.Is(err, auth.ErrLoginRequired) {
+		return err
+	}
+	if s, ok := CheckSession(ctx); ok && s.State == auth.SessionExpired {
+		return fmt.Errorf("%w（%s），下载失败: %v", auth.ErrLoginRequired, s.Error, err)
+	}
+	return err
+}
@@ -0,0 +1,182 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"zhihu-downloader/internal/errcode"
+)
+
+// AnyHost 写在 URLPolicy.AllowedHosts 中时允许下载所有公网网站的链接
+const AnyHost = "*"
+
+// URLPolicy 允许下载的链接。下载链接会交给 ffmpeg、yt-dlp 和内置下载访问，
+// 不加限制时可以借服务访问内网地址（SSRF），默认只允许知乎及其 CDN。
+// 只在创建任务时用 CheckURL 检查链接本身，连接时的检查只对内置下载（guardedTransport）生效；
+// yt-dlp 和 ffmpeg 自行请求的地址（跳转、页面中解析出的媒体地址、分片）不经过检查，
+// 因此 B 站、YouTube 等交给 yt-dlp 的网站也需要在 AllowedHosts 中明确允许
+type URLPolicy struct {
+	// AllowedHosts 在知乎之外允许的域名（包含子域名），AnyHost 允许所有公网网站。
+	// 明确列出的域名即使解析到内网地址也允许，例如局域网中的 NAS
+	AllowedHosts []string
+	// AllowPrivate 允许下载内网和本机地址
+	AllowPrivate bool
+}
+
+var (
+	urlPolicyMu sync.RWMutex
+	urlPolicy   URLPolicy
+)
+
+// SetURLPolicy 设置允许下载的链接，需要先用 ValidateURLPolicy 检查
+func SetURLPolicy(p URLPolicy) {
+	hosts := make([]string, 0, len(p.AllowedHosts))
+	for _, h := range p.AllowedHosts {
+		hosts = append(hosts, strings.ToLower(strings.TrimPrefix(strings.TrimSpace(h), ".")))
+	}
+	p.AllowedHosts = hosts
+	urlPolicyMu.Lock()
+	urlPolicy = p
+	urlPolicyMu.Unlock()
+}
+
+func currentURLPolicy() URLPolicy {
+	urlPolicyMu.RLock()
+	defer urlPolicyMu.RUnlock()
+	return urlPolicy
+}
+
+// ValidateURLPolicy 检查允许的域名：不能带协议、端口或路径
+func ValidateURLPolicy(p URLPolicy) error {
+	for _, h := range p.AllowedHosts {
+		h = strings.TrimSpace(h)
+		if h == AnyHost {
+			continue
+		}
+		if h == "" || strings.ContainsAny(h, "/:*@ ") {
+			return fmt.Errorf("allowed_hosts 中的域名无效: %q（只填写域名，例如 example.com）", h)
+		}
+	}
+	return nil
+}
+
+// CheckURL 检查下载链接：只能是 http(s)，域名在允许的范围内，并且不指向内网地址。
+// 域名解析失败时不拒绝，交给下载报错
+func CheckURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return errcode.Newf(errcode.URLInvalid, "只能下载 http(s) 链接: %s", rawURL)
+	}
+	if u.User != nil {
+		return errcode.Newf(errcode.URLInvalid, "链接不能包含用户名和密码: %s", u.Redacted())
+	}
+	p := currentURLPolicy()
+	host := strings.ToLower(u.Hostname())
+	explicit := matchHost(host, p.AllowedHosts...)
+	if !explicit && !slices.Contains(p.AllowedHosts, AnyHost) && !matchHost(host, zhihuHosts...) {
+		return errcode.Newf(errcode.URLInvalid, "不支持下载 %s 的链接，可以在配置的 download.allowed_hosts 中添加", host)
+	}
+	if p.AllowPrivate || explicit {
+		return nil
+	}
+
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		lookupCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		addrs, err := net.DefaultResolver.LookupIPAddr(lookupCtx, host)
+		cancel()
+		if err != nil {
+			return nil
+		}
+		ips = ips[:0]
+		for _, a := range addrs {
+			ips = append(ips, a.IP)
+		}
+	}
+	for _, ip := range ips {
+		if privateIP(ip) {
+			return errcode.Newf(errcode.URLInvalid, "不能下载内网地址 %s（%s）的链接", host, ip)
+		}
+	}
+	return nil
+}
+
+// privateIP 判断是否是本机、内网、链路本地或未指定地址
+func privateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() || cgnat.Contains(ip)
+}
+
+// cgnat 运营商级 NAT 的共享地址段，通常也不应从服务访问
+var cgnat = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// guardedTransport 内置下载使用的 Transport：连接时检查实际连接的 IP，
+// 跳转到内网地址或 DNS 重绑定也无法绕过 CheckURL。连接代理服务器和明确允许的域名时不检查
+var guardedTransport = func() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	// 大文件的单个请求可能需要较长时间，只限制等待响应头的时间
+	t.ResponseHeaderTimeout = time.Minute
+	plain := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	checked := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: checkDial}
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		p := currentURLPolicy()
+		host, _, _ := net.SplitHostPort(addr)
+		if p.AllowPrivate || matchHost(strings.ToLower(host), p.AllowedHosts...) || isProxyAddr(addr) {
+			return plain.DialContext(ctx, network, addr)
+		}
+		return checked.DialContext(ctx, network, addr)
+	}
+	return t
+}()
+
+func checkDial(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip != nil && privateIP(ip) {
+		return errcode.Newf(errcode.URLInvalid, "拒绝连接内网地址 %s", host)
+	}
+	return nil
+}
+
+// isProxyAddr 判断是否是环境变量中配置的代理服务器，代理通常在本机或内网
+func isProxyAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "ALL_PROXY", "all_proxy"} {
+		v := os.Getenv(name)
+		if v == "" {
+			continue
+		}
+		if !strings.Contains(v, "://") {
+			v = "http://" + v
+		}
+		if u, err := url.Parse(v); err == nil && strings.EqualFold(u.Hostname(), host) {
+			return true
+		}
+	}
+	return false
+}
+
+// guardedClient 返回使用 guardedTransport 的 HTTP 客户端，timeout 为 0 时不限制整体时间
+func guardedClient(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: guardedTransport, Timeout: timeout}
+}
+
+// GuardedClient 与内置下载相同的 HTTP 客户端：每次连接（包括跳转）都检查实际连接的 IP，
+// 不能访问内网地址。用于创建任务前就要访问用户提供的链接的场景，例如跟随短链接跳转
+func GuardedClient(timeout time.Duration) *http.Client {
+	return guardedClient(timeout)
+}
@@ -0,0 +1,176 @@
+package downloader
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"zhihu-downloader/internal/hls"
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/media"
+	"zhihu-downloader/internal/proc"
+)
+
+// yt-dlp 进度行，例如 "[download]  45.3% of ~ 10.00MiB at  1.23MiB/s ETA 00:05"
+var ytDlpProgressRe = regexp.MustCompile(`\[download\]\s+(\d+\.?\d*)% of\s+~?\s*(\d+\.?\d*)([KMG]i?B)(?:\s+at\s+(\S+))?`)
+
+// ytDlpSpeedRe 进度行中的速度，例如 "1.23MiB/s"
+var ytDlpSpeedRe = regexp.MustCompile(`^(\d+\.?\d*)([KMG]?i?B)/s$`)
+
+var (
+	ytDlpMu         sync.RWMutex
+	ytDlpConfigured string
+)
+
+// SetYtDlp 设置 yt-dlp 可执行文件路径，空字符串表示自动查找
+func SetYtDlp(path string) {
+	ytDlpMu.Lock()
+	defer ytDlpMu.Unlock()
+	ytDlpConfigured = path
+}
+
+// YtDlpPath 查找 yt-dlp：优先使用配置的路径，其次是 PATH 和 Homebrew、pip 用户目录等常见安装目录
+func YtDlpPath() (string, error) {
+	ytDlpMu.RLock()
+	configured := ytDlpConfigured
+	ytDlpMu.RUnlock()
+	if configured != "" {
+		if _, err := os.Stat(configured); err != nil {
+			return "", fmt.Errorf("yt-dlp 不存在: %s", configured)
+		}
+		return configured, nil
+	}
+
+	if path, err := proc.LookPath("yt-dlp"); err == nil {
+		return path, nil
+	}
+	return "", fmt.Errorf("未安装 yt-dlp（pip install yt-dlp 或 brew install yt-dlp）")
+}
+
+// ytDlpFormat 把清晰度转换为 yt-dlp 的格式选择
+func ytDlpFormat(quality string) string {
+	heights := map[string]int{"uhd": 2160, "fhd": 1080, "hd": 720, "sd": 480, "ld": 360}
+	height, ok := heights[quality]
+	if !ok {
+		return "bv*+ba/b"
+	}
+	return fmt.Sprintf("bv*[height<=%d]+ba/b[height<=%d]/bv*+ba/b", height, height)
+}
+
+// downloadYtDlp 使用 yt-dlp 下载视频网站的页面，输出合并为 mp4
+func downloadYtDlp(ctx context.Context, req Request, startTime time.Time, onProgress func(Progress)) (string, error) {
+	exe, err := YtDlpPath()
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{
+		"--newline", "--no-playlist", "--no-mtime",
+		"-f", ytDlpFormat(req.Quality),
+		"--merge-output-format", "mp4",
+		"-o", filepath.Join(req.OutputDir, req.Filename+".%(ext)s"),
+	}
+	if len(req.FFmpegArgs) > 0 {
+		logging.FromContext(ctx).Warn("yt-dlp 下载不使用 ffmpeg_args", "ffmpeg_args", req.FFmpegArgs)
+	}
+	if ffmpeg := media.FFmpeg(); ffmpeg != "ffmpeg" {
+		args = append(args, "--ffmpeg-location", ffmpeg)
+	}
+	if rate := req.limiter.Rate(); rate > 0 {
+		args = append(args, "--limit-rate", strconv.FormatInt(rate, 10))
+	}
+	if n := segmentConnections(req); n > 0 {
+		args = append(args, "--concurrent-fragments", strconv.Itoa(n))
+	}
+	// 强制用 yt-dlp 下载知乎页面时带上登录 cookies；其他网站只带上本次下载附加的请求头和 cookies
+	if isZhihuPage(req.URL) {
+		for key, values := range req.headersFor(req.URL) {
+			for _, v := range values {
+				args = append(args, "--add-header", key+":"+v)
+			}
+		}
+	} else {
+		for key, v := range req.extraHeaders(req.URL) {
+			args = append(args, "--add-header", key+":"+v)
+		}
+	}
+	args = append(args, req.URL)
+
+	cmd := proc.Command(ctx, exe, args...)
+	stdout, _ := cmd.StdoutPipe()
+	cmd.Stderr = cmd.Stdout
+
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("启动 yt-dlp 失败: %v", err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	var lastOutput strings.Builder
+	lastPct := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		logging.Output(ctx, "yt-dlp", line)
+		if !strings.HasPrefix(line, "[download]") {
+			lastOutput.WriteString(line + "\n")
+		}
+
+		matches := ytDlpProgressRe.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		pct, _ := strconv.ParseFloat(matches[1], 64)
+		// 视频和音频分开下载时各自从 0 开始，只取最大值
+		if int(pct) <= lastPct {
+			continue
+		}
+		lastPct = int(pct)
+		total := parseSize(matches[2], matches[3])
+		onProgress(Progress{
+			Percentage:      min(99, lastPct),
+			Speed:           parseSpeed(matches[4]),
+			BytesDownloaded: int64(float64(total) * pct / 100),
+			TotalBytes:      total,
+		})
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("yt-dlp 下载失败: %v: %s", err, lastOutput.String())
+	}
+	return latestFile(req.OutputDir, req.Filename+".*", startTime)
+}
+
+// parseSpeed 把 yt-dlp 输出的速度（例如 "1.50MiB/s"）统一格式化为 KB/s、MB/s，无法解析（例如 "Unknown B/s"）时返回空字符串
+func parseSpeed(s string) string {
+	m := ytDlpSpeedRe.FindStringSubmatch(s)
+	if m == nil {
+		return ""
+	}
+	return hls.FormatSpeed(float64(parseSize(m[1], m[2])))
+}
+
+// parseSize 解析 yt-dlp 输出的大小，例如 "10.00" "MiB"
+func parseSize(value, unit string) int64 {
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	switch unit[0] {
+	case 'K':
+		n *= 1 << 10
+	case 'M':
+		n *= 1 << 20
+	case 'G':
+		n *= 1 << 30
+	}
+	return int64(n)
+}
@@ -0,0 +1,299 @@
+// Package embedding 为转录文本计算向量（embedding），用于语义搜索。向量可以由 OpenAI 兼容的
+// /embeddings 接口（OpenAI、Ollama、LM Studio、vLLM 等）计算，也可以由本机运行的命令计算
+// （例如加载 sentence-transformers 模型的 Python 脚本），不需要联网。
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"zhihu-downloader/internal/proc"
+)
+
+// 向量的来源
+const (
+	// ProviderOpenAI OpenAI 兼容的 /embeddings 接口
+	ProviderOpenAI = "openai"
+	// ProviderCommand 本机命令：从标准输入读取 {"texts": [...]}，向标准输出写 {"embeddings": [[...], ...]}
+	ProviderCommand = "command"
+)
+
+// 默认配置
+const (
+	DefaultBaseURL    = "https://api.openai.com/v1"
+	DefaultModel      = "text-embedding-3-small"
+	DefaultChunkChars = 400
+)
+
+// batchSize 一次请求最多计算的文本数
+const batchSize = 64
+
+// Config 向量计算配置
+type Config struct {
+	// Provider openai / command，为空时不计算向量，语义搜索不可用
+	Provider string
+	// BaseURL OpenAI 兼容接口地址（不含 /embeddings），默认 https://api.openai.com/v1
+	BaseURL string
+	// APIKey 访问令牌，本地服务（如 Ollama）可以为空
+	APIKey string
+	// Model 模型名称，openai 默认 text-embedding-3-small；command 时只用来区分不同模型计算的向量
+	Model string
+	// Command 本机命令及其参数（provider 为 command 时）
+	Command []string
+	// ChunkChars 相邻的转录段落合并为不超过该字数的片段后再计算向量，默认 400
+	ChunkChars int
+}
+
+var (
+	configMu sync.RWMutex
+	config   Config
+)
+
+var httpClient = &http.Client{Timeout: 5 * time.Minute}
+
+// SetConfig 设置向量计算配置，需要先用 Validate 检查
+func SetConfig(c Config) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	config = c
+}
+
+func currentConfig() Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	c := config
+	if c.BaseURL == "" {
+		c.BaseURL = DefaultBaseURL
+	}
+	if c.Model == "" && c.Provider == ProviderOpenAI {
+		c.Model = DefaultModel
+	}
+	if c.ChunkChars <= 0 {
+		c.ChunkChars = DefaultChunkChars
+	}
+	return c
+}
+
+// Validate 检查配置，Provider 为空时不检查其他项
+func Validate(c Config) error {
+	switch c.Provider {
+	case "":
+		return nil
+	case ProviderOpenAI:
+		if c.BaseURL != "" {
+			u, err := url.Parse(c.BaseURL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("embedding.base_url 不是有效的 http(s) 地址: %s", c.BaseURL)
+			}
+		}
+	case ProviderCommand:
+		if len(c.Command) == 0 || c.Command[0] == "" {
+			return fmt.Errorf("embedding.provider 为 command 时需要设置 embedding.command")
+		}
+	default:
+		return fmt.Errorf("不支持的 embedding.provider: %s（可选 openai、command）", c.Provider)
+	}
+	if c.ChunkChars < 0 {
+		return fmt.Errorf("embedding.chunk_chars 不能为负数")
+	}
+	return nil
+}
+
+// Enabled 是否配置了向量计算
+func Enabled() bool {
+	return currentConfig().Provider != ""
+}
+
+// Model 当前配置计算的向量的标识，例如 openai:text-embedding-3-small。
+// 不同模型的向量不能比较，数据库中按这个标识区分
+func Model() string {
+	c := currentConfig()
+	if c.Provider == "" {
+		return ""
+	}
+	model := c.Model
+	if model == "" {
+		model = c.Command[0]
+	}
+	return c.Provider + ":" + model
+}
+
+// ChunkChars 每个片段最多的字数
+func ChunkChars() int {
+	return currentConfig().ChunkChars
+}
+
+// Embed 计算 texts 的向量，返回的向量已归一化（长度为 1），与 texts 一一对应
+func Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	c := currentConfig()
+	var vectors [][]float32
+	for start := 0; start < len(texts); start += batchSize {
+		batch := texts[start:min(start+batchSize, len(texts))]
+		var (
+			result [][]float32
+			err    error
+		)
+		switch c.Provider {
+		case ProviderOpenAI:
+			result, err = embedOpenAI(ctx, c, batch)
+		case ProviderCommand:
+			result, err = embedCommand(ctx, c, batch)
+		default:
+			return nil, fmt.Errorf("没有配置向量计算（embedding.provider）")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(result) != len(batch) {
+			return nil, fmt.Errorf("向量数量 %d 与文本数量 %d 不一致", len(result), len(batch))
+		}
+		for _, v := range result {
+			if len(v) == 0 {
+				return nil, fmt.Errorf("返回了空向量")
+			}
+			normalize(v)
+		}
+		vectors = append(vectors, result...)
+	}
+	return vectors, nil
+}
+
+// embedOpenAI 调用 /embeddings
+func embedOpenAI(ctx context.Context, c Config, texts []string) ([][]float32, error) {
+	body, _ := json.Marshal(map[string]interface{}{"model": c.Model, "input": texts})
+	endpoint := strings.TrimRight(c.BaseURL, "/") + "/embeddings"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("请求向量接口失败: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取向量接口响应失败: %v", err)
+	}
+
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("向量接口返回 HTTP %d: %s", resp.StatusCode, truncate(string(data), 200))
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("向量接口返回错误: %s", result.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("向量接口返回 HTTP %d", resp.StatusCode)
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("向量接口返回的 index 无效: %d", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
+
+// embedCommand 运行本机命令计算向量
+func embedCommand(ctx context.Context, c Config, texts []string) ([][]float32, error) {
+	input, _ := json.Marshal(map[string]interface{}{"texts": texts})
+	cmd := proc.Command(ctx, c.Command[0], c.Command[1:]...)
+	cmd.Env = proc.Env()
+	cmd.Stdin = bytes.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("向量计算命令失败: %v %s", err, truncate(strings.TrimSpace(stderr.String()), 500))
+	}
+	var result struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, fmt.Errorf("解析向量计算命令的输出失败: %v", err)
+	}
+	return result.Embeddings, nil
+}
+
+// normalize 把向量缩放为长度 1，之后点积即为余弦相似度
+func normalize(v []float32) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+}
+
+// Similarity 两个归一化向量的余弦相似度，长度不同时返回 -1
+func Similarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return -1
+	}
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot
+}
+
+// Encode 把向量编码为小端 float32 的字节序列（与 sqlite-vec 的格式相同）
+func Encode(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, x := range v {
+		bits := math.Float32bits(x)
+		buf[4*i], buf[4*i+1], buf[4*i+2], buf[4*i+3] = byte(bits), byte(bits>>8), byte(bits>>16), byte(bits>>24)
+	}
+	return buf
+}
+
+// Decode 解码 Encode 的结果
+func Decode(data []byte) []float32 {
+	v := make([]float32, len(data)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(uint32(data[4*i]) | uint32(data[4*i+1])<<8 | uint32(data[4*i+2])<<16 | uint32(data[4*i+3])<<24)
+	}
+	return v
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "..."
+	}
+	return s
+}
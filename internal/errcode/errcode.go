@@ -0,0 +1,259 @@
+// Package errcode 定义 REST、MCP 接口和任务记录共用的错误码。
+//
+// 已知原因的错误用 New / Wrap 带上错误码；外部程序（ffmpeg、yt-dlp、Whisper）返回的错误
+// 由 Of 按错误类型和输出内容归类，无法归类时使用调用方给出的默认错误码。
+package errcode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// Code 错误码
+type Code string
+
+// 请求错误
+const (
+	// InvalidArgument 参数无效
+	InvalidArgument Code = "INVALID_ARGUMENT"
+	// URLInvalid 链接为空、格式错误或不是支持的网站
+	URLInvalid Code = "URL_INVALID"
+	// Unauthorized 缺少或无效的 API 密钥
+	Unauthorized Code = "UNAUTHORIZED"
+	// Forbidden 没有权限（非管理员工作区、路径不在允许的目录中）
+	Forbidden Code = "FORBIDDEN"
+	// NotFound 任务、文件或计划任务不存在
+	NotFound Code = "NOT_FOUND"
+	// Conflict 任务当前状态不允许该操作（正在执行、在其他进程中执行等）
+	Conflict Code = "CONFLICT"
+	// PayloadTooLarge 请求体超过上限
+	PayloadTooLarge Code = "PAYLOAD_TOO_LARGE"
+)
+
+// 下载和转录失败的原因
+const (
+	// AuthRequired 需要登录知乎或保存的登录已失效
+	AuthRequired Code = "AUTH_REQUIRED"
+	// GeoBlocked 视频在当前地区不可用
+	GeoBlocked Code = "GEO_BLOCKED"
+	// VideoUnavailable 视频不存在、已删除或无权访问
+	VideoUnavailable Code = "VIDEO_UNAVAILABLE"
+	// Upstream 知乎或其他网站返回错误、网络请求失败
+	Upstream Code = "UPSTREAM_ERROR"
+	// FFmpegMissing 没有找到 ffmpeg / ffprobe
+	FFmpegMissing Code = "FFMPEG_MISSING"
+	// ToolMissing 没有找到 yt-dlp、Python 等其他外部程序
+	ToolMissing Code = "TOOL_MISSING"
+	// TranscribeBackendMissing 没有可用的 Whisper 或模型
+	TranscribeBackendMissing Code = "TRANSCRIBE_BACKEND_MISSING"
+	// DiskFull 磁盘空间不足
+	DiskFull Code = "DISK_FULL"
+	// QuotaExceeded 下载目录超出容量上限
+	QuotaExceeded Code = "QUOTA_EXCEEDED"
+	// Timeout 任务超时
+	Timeout Code = "TIMEOUT"
+	// Stalled 任务长时间没有更新，被后台检查终止
+	Stalled Code = "STALLED"
+	// Interrupted 服务重启，任务被中断
+	Interrupted Code = "INTERRUPTED"
+	// Cancelled 用户取消
+	Cancelled Code = "CANCELLED"
+	// DownloadFailed 下载失败，没有更具体的原因
+	DownloadFailed Code = "DOWNLOAD_FAILED"
+	// TranscribeFailed 转录失败，没有更具体的原因
+	TranscribeFailed Code = "TRANSCRIBE_FAILED"
+	// Unavailable 服务暂时不可用（例如摘要没有配置模型）
+	Unavailable Code = "SERVICE_UNAVAILABLE"
+	// Internal 其他内部错误
+	Internal Code = "INTERNAL"
+)
+
+// HTTPStatus 错误码对应的 HTTP 状态码
+func (c Code) HTTPStatus() int {
+	switch c {
+	case InvalidArgument, URLInvalid:
+		return http.StatusBadRequest
+	case Unauthorized:
+		return http.StatusUnauthorized
+	case Forbidden:
+		return http.StatusForbidden
+	case NotFound:
+		return http.StatusNotFound
+	case Conflict:
+		return http.StatusConflict
+	case PayloadTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case AuthRequired:
+		return http.StatusUnauthorized
+	case GeoBlocked:
+		return http.StatusUnavailableForLegalReasons
+	case VideoUnavailable:
+		return http.StatusNotFound
+	case Upstream:
+		return http.StatusBadGateway
+	case DiskFull, QuotaExceeded:
+		return http.StatusInsufficientStorage
+	case Timeout:
+		return http.StatusGatewayTimeout
+	case FFmpegMissing, ToolMissing, TranscribeBackendMissing, Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// Error 带错误码的错误。Message 是给用户看的说明，Detail 是外部程序的输出等排查用的细节
+type Error struct {
+	Code    Code
+	Message string
+	Detail  string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Message == "" && e.Err != nil:
+		return e.Err.Error()
+	case e.Err != nil:
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New 返回带错误码的错误
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Newf 同 New，按格式生成说明
+func Newf(code Code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Wrap 给 err 加上错误码，message 为空时使用 err 的内容。err 为 nil 时返回 nil
+func Wrap(code Code, err error, message string) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: code, Message: message, Err: err}
+}
+
+// Of 返回 err 的错误码：优先使用错误链中的 *Error，其次按错误类型和内容归类，都不符合时返回 fallback
+func Of(err error, fallback Code) Code {
+	if err == nil {
+		return ""
+	}
+	var e *Error
+	if errors.As(err, &e) && e.Code != "" {
+		return e.Code
+	}
+	if code := classify(err); code != "" {
+		return code
+	}
+	return fallback
+}
+
+// classify 按错误类型和外部程序的输出归类
+func classify(err error) Code {
+	var execErr *exec.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return Cancelled
+	case errors.Is(err, context.DeadlineExceeded):
+		return Timeout
+	case errors.Is(err, syscall.ENOSPC):
+		return DiskFull
+	case errors.As(err, &execErr) && errors.Is(execErr.Err, exec.ErrNotFound):
+		return missingTool(execErr.Name)
+	}
+
+	// yt-dlp 的输出为 "HTTP Error 404"，和其他程序的 "HTTP 404" 一起匹配
+	msg := strings.ReplaceAll(strings.ToLower(err.Error()), "http error ", "http ")
+	contains := func(subs ...string) bool {
+		for _, s := range subs {
+			if strings.Contains(msg, s) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case contains("no space left on device", "磁盘空间不足"):
+		return DiskFull
+	case contains("executable file not found"):
+		return missingTool(msg)
+	case contains("not available in your country", "geo restriction", "geo-restricted", "geoblocked", "当前地区", "所在地区"):
+		return GeoBlocked
+	case contains("http 401", "需要登录", "请先登录", "登录已失效"):
+		return AuthRequired
+	case contains("http 404", "http 410", "video unavailable", "视频不存在", "已被删除", "内容不存在"):
+		return VideoUnavailable
+	case contains("http 403", "http 5", "http 429", "connection refused", "connection reset", "no such host", "i/o timeout", "tls handshake"):
+		return Upstream
+	}
+	return ""
+}
+
+func missingTool(name string) Code {
+	name = strings.ToLower(name)
+	switch {
+	case strings.Contains(name, "ffmpeg"), strings.Contains(name, "ffprobe"):
+		return FFmpegMissing
+	case strings.Contains(name, "whisper"):
+		return TranscribeBackendMissing
+	}
+	return ToolMissing
+}
+
+// maxMessage 说明的最大长度，更长的内容（例如外部程序的输出）放在 Detail 中
+const maxMessage = 300
+
+// Info 接口和任务记录中的错误信息
+type Info struct {
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+	Detail  string `json:"detail,omitempty"`
+	// Field 参数无效时出错的参数名
+	Field string `json:"field,omitempty"`
+}
+
+// Describe 返回 err 的错误码、说明和细节。*Error 使用其中的说明和细节；
+// 其他错误的第一行作为说明，多行或过长时完整内容放在细节中
+func Describe(err error, fallback Code) Info {
+	if err == nil {
+		return Info{}
+	}
+	info := Info{Code: Of(err, fallback)}
+	var e *Error
+	if errors.As(err, &e) && err == error(e) && e.Message != "" {
+		info.Message, info.Detail = e.Message, e.Detail
+		if info.Detail == "" && e.Err != nil {
+			info.Detail = e.Err.Error()
+		}
+		return info
+	}
+	info.Message, info.Detail = Split(err.Error())
+	return info
+}
+
+// Split 把错误内容拆分为一行说明和完整的细节，只有一行且不太长时细节为空
+func Split(text string) (message, detail string) {
+	text = strings.TrimSpace(text)
+	message, _, multiline := strings.Cut(text, "\n")
+	message = strings.TrimSpace(message)
+	if r := []rune(message); len(r) > maxMessage {
+		message = string(r[:maxMessage]) + "…"
+	}
+	if multiline || message != text {
+		detail = text
+	}
+	return message, detail
+}
@@ -0,0 +1,260 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"zhihu-downloader/internal/auth"
+	"zhihu-downloader/internal/diskspace"
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/media"
+	"zhihu-downloader/internal/tasks"
+	"zhihu-downloader/internal/transcriber"
+)
+
+// 健康检查的总体状态
+const (
+	// StatusOK 所有检查都通过
+	StatusOK = "ok"
+	// StatusDegraded 必需的依赖正常，但部分功能不可用（例如没有 Whisper）或有疑似卡住的任务
+	StatusDegraded = "degraded"
+	// StatusUnavailable 必需的依赖缺失，下载无法进行，接口返回 503
+	StatusUnavailable = "unavailable"
+)
+
+// probeTimeout 单项检查（运行 ffmpeg -version、写数据库）的最长时间
+const probeTimeout = 3 * time.Second
+
+// Check 一项依赖检查的结果
+type Check struct {
+	Name string `json:"name"`
+	OK   bool   `json:"ok"`
+	// Required 检查失败时服务无法正常工作
+	Required bool `json:"required"`
+	// Detail 检查通过时的说明，例如版本、剩余空间
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Fix 检查失败时建议的解决办法
+	Fix string `json:"fix,omitempty"`
+}
+
+// Options 健康检查的对象，为空的项跳过
+type Options struct {
+	// Database 检查数据库能否写入
+	Database func(ctx context.Context) error
+	// OutputDir 默认下载目录，检查能否写入和所在磁盘的剩余空间
+	OutputDir string
+	// MinFree 磁盘至少保留的空间，低于时检查失败（0 表示只报告剩余空间）
+	MinFree int64
+	// Manager 检查疑似卡住的任务
+	Manager *tasks.Manager
+	// StuckAfter 任务超过这么久没有更新时判定为疑似卡住，默认 tasks.DefaultStuckAfter
+	StuckAfter time.Duration
+}
+
+// Report 健康检查结果
+type Report struct {
+	Status string  `json:"status"`
+	Checks []Check `json:"checks"`
+	// StuckTasks 疑似卡住的任务，可以取消后重试
+	StuckTasks []tasks.StuckTask `json:"stuck_tasks"`
+	// Login 保存的知乎登录状态，没有保存登录 cookies 时为空
+	Login *auth.Session `json:"login,omitempty"`
+}
+
+// Run 执行所有检查：ffmpeg / ffprobe 及版本、Whisper 后端、数据库和下载目录能否写入、
+// 磁盘剩余空间、外部任务队列、知乎登录状态和疑似卡住的任务
+func Run(ctx context.Context, opts Options) Report {
+	checks := []Check{
+		binaryCheck(ctx, "ffmpeg", media.FFmpeg(), true),
+		binaryCheck(ctx, "ffprobe", media.FFprobe(), false),
+		whisperCheck(),
+	}
+	if opts.Database != nil {
+		checks = append(checks, databaseCheck(ctx, opts.Database))
+	}
+	if opts.OutputDir != "" {
+		checks = append(checks, dirCheck(opts.OutputDir), diskCheck(opts.OutputDir, opts.MinFree))
+	}
+	if opts.Manager != nil && opts.Manager.JobQueue() != nil {
+		checks = append(checks, queueCheck(opts.Manager.JobQueue()))
+	}
+
+	// 检查请求有自己的超时，后台定期检查时通常直接使用上次的结果
+	session, ok := downloader.LoginSession(ctx, downloader.SessionMaxAge)
+	if ok {
+		checks = append(checks, loginCheck(session))
+	}
+
+	report := Report{Status: StatusOK, Checks: checks, StuckTasks: []tasks.StuckTask{}}
+	if ok {
+		report.Login = &session
+	}
+	if opts.Manager != nil {
+		after := opts.StuckAfter
+		if after <= 0 {
+			after = tasks.DefaultStuckAfter
+		}
+		if stuck := opts.Manager.StuckTasks(after); len(stuck) > 0 {
+			report.StuckTasks = stuck
+		}
+	}
+
+	if len(report.StuckTasks) > 0 {
+		report.Status = StatusDegraded
+	}
+	for _, c := range checks {
+		switch {
+		case c.OK:
+		case c.Required:
+			report.Status = StatusUnavailable
+		case report.Status == StatusOK:
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+// HTTPStatus 健康检查接口的状态码：必需的依赖缺失时为 503，否则为 200
+func (r Report) HTTPStatus() int {
+	if r.Status == StatusUnavailable {
+		return 503
+	}
+	return 200
+}
+
+// binaryCheck 查找程序并运行 -version，Detail 为输出的第一行中的版本号
+func binaryCheck(ctx context.Context, name, configured string, required bool) Check {
+	check := Check{Name: name, Required: required}
+	path, err := exec.LookPath(configured)
+	if err != nil {
+		check.Error = fmt.Sprintf("未找到 %s", configured)
+		check.Fix = fmt.Sprintf("安装 ffmpeg（macOS: brew install ffmpeg，Debian/Ubuntu: apt install ffmpeg），或在配置文件的 tools.%s 中指定路径", name)
+		return check
+	}
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "-version").Output()
+	if err != nil {
+		check.Error = fmt.Sprintf("%s -version 执行失败: %v", path, err)
+		check.Fix = fmt.Sprintf("%s 无法运行，重新安装 ffmpeg 或在 tools.%s 中换一个可执行文件", path, name)
+		return check
+	}
+	check.OK, check.Detail = true, path
+	// 例如 "ffmpeg version 6.1.1 Copyright (c) ..."
+	line, _, _ := strings.Cut(string(out), "\n")
+	if fields := strings.Fields(line); len(fields) >= 3 && fields[1] == "version" {
+		check.Detail = fields[2] + " (" + path + ")"
+	}
+	return check
+}
+
+// databaseCheck 检查数据库能否写入
+func databaseCheck(ctx context.Context, writable func(ctx context.Context) error) Check {
+	check := Check{Name: "database", Required: true}
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	if err := writable(ctx); err != nil {
+		check.Error = fmt.Sprintf("数据库无法写入: %v", err)
+		check.Fix = "确认 storage.db_path 所在目录存在且可写、磁盘没有满，并且没有其他进程长时间锁住数据库"
+		return check
+	}
+	check.OK, check.Detail = true, "可写"
+	return check
+}
+
+// queueCheck 外部任务队列能否访问，Detail 为各类任务排队、执行中和死信队列中的数量
+func queueCheck(q tasks.JobQueue) Check {
+	check := Check{Name: "queue", Required: true}
+	download, err := q.Stats(tasks.KindDownload)
+	var transcribe tasks.JobStats
+	if err == nil {
+		transcribe, err = q.Stats(tasks.KindTranscribe)
+	}
+	if err != nil {
+		check.Error = fmt.Sprintf("任务队列无法访问: %v", err)
+		check.Fix = "确认 Redis 已启动并且 queue.redis_url 正确，或把 queue.backend 改回 memory"
+		return check
+	}
+	check.OK = true
+	check.Detail = fmt.Sprintf("下载：排队 %d，执行中 %d，死信 %d；转录：排队 %d，执行中 %d，死信 %d",
+		download.Queued+download.Retrying, download.Active, download.Dead,
+		transcribe.Queued+transcribe.Retrying, transcribe.Active, transcribe.Dead)
+	return check
+}
+
+// whisperCheck 当前配置或自动选择的 Whisper 后端，缺少时只影响转录
+func whisperCheck() Check {
+	status := transcriber.CurrentStatus()
+	check := Check{Name: "whisper", OK: status.Error == "", Error: status.Error}
+	if check.OK {
+		check.Detail = fmt.Sprintf("%s，%s 模型（%s）", status.Backend, status.Model, status.Path)
+	} else {
+		check.Fix = "安装一个 Whisper 后端（Apple 芯片: pip install mlx-whisper，其他: pip install faster-whisper），或在 transcribe.backend / transcribe.path 中指定；只下载不转录时可以忽略"
+	}
+	return check
+}
+
+// loginCheck 保存的知乎登录，失效或即将过期时只影响需要登录的视频
+func loginCheck(s auth.Session) Check {
+	check := Check{Name: "login", OK: s.State == auth.SessionValid}
+	switch s.State {
+	case auth.SessionValid:
+		check.Detail = "已登录 " + s.User
+	case auth.SessionUnknown:
+		check.Error = "无法验证知乎登录: " + s.Error
+		check.Fix = "检查能否访问 www.zhihu.com"
+	default:
+		check.Error = s.Error
+		check.Fix = "重新上传知乎登录 cookies；不需要登录的视频不受影响"
+	}
+	return check
+}
+
+// dirCheck 在目录中创建并删除一个临时文件，检查能否写入；目录不存在时先创建
+func dirCheck(dir string) Check {
+	check := Check{Name: "output_dir", Required: true}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		check.Error = fmt.Sprintf("无法创建下载目录: %v", err)
+		check.Fix = "检查目录权限，或在 storage.output_dir 中换一个可写的目录"
+		return check
+	}
+	f, err := os.CreateTemp(dir, ".health-*")
+	if err != nil {
+		check.Error = fmt.Sprintf("下载目录无法写入: %v", err)
+		check.Fix = fmt.Sprintf("给运行服务的用户 %s 的写权限，或在 storage.output_dir 中换一个可写的目录", dir)
+		return check
+	}
+	f.Close()
+	os.Remove(f.Name())
+	check.OK, check.Detail = true, dir
+	return check
+}
+
+// diskCheck 下载目录所在磁盘的剩余空间，低于 minFree 时失败
+func diskCheck(dir string, minFree int64) Check {
+	check := Check{Name: "disk_space", Required: minFree > 0}
+	free, err := diskspace.Free(dir)
+	if errors.Is(err, diskspace.ErrUnsupported) {
+		check.OK, check.Detail = true, err.Error()
+		return check
+	}
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	check.Detail = "剩余 " + diskspace.Format(free)
+	if minFree > 0 && free < minFree {
+		check.Error = fmt.Sprintf("剩余空间 %s 低于 quota.min_free_mb（%s），新的下载会被拒绝",
+			diskspace.Format(free), diskspace.Format(minFree))
+		check.Fix = "删除不需要的下载（或开启 retention 自动清理），或调低 quota.min_free_mb"
+		return check
+	}
+	check.OK = true
+	return check
+}
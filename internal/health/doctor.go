@@ -0,0 +1,178 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/media"
+)
+
+// networkTimeout 访问知乎的最长时间
+const networkTimeout = 10 * time.Second
+
+// zhihuURL 网络检查访问的地址
+const zhihuURL = "https://www.zhihu.com/"
+
+// pythonModules zhihu_downloader.py 需要的 Python 包，见 requirements.txt
+var pythonModules = []string{"requests", "m3u8"}
+
+// DoctorOptions --doctor 自检的对象，为空的项跳过
+type DoctorOptions struct {
+	Options
+	// DataDir 数据目录
+	DataDir string
+}
+
+// Doctor 逐项检查运行环境：ffmpeg / ffprobe、yt-dlp、Python 及其依赖、Whisper 后端、数据库、
+// 数据和下载目录、磁盘空间、能否访问知乎和保存的登录。失败的检查带有建议的解决办法
+func Doctor(ctx context.Context, opts DoctorOptions) []Check {
+	checks := []Check{
+		binaryCheck(ctx, "ffmpeg", media.FFmpeg(), true),
+		binaryCheck(ctx, "ffprobe", media.FFprobe(), false),
+		ytDlpCheck(),
+		pythonCheck(ctx),
+		whisperCheck(),
+	}
+	if opts.Database != nil {
+		checks = append(checks, databaseCheck(ctx, opts.Database))
+	}
+	if opts.DataDir != "" {
+		check := dirCheck(opts.DataDir)
+		check.Name = "data_dir"
+		if !check.OK {
+			check.Fix = "检查目录权限，或在 storage.data_dir 中换一个可写的目录"
+		}
+		checks = append(checks, check)
+	}
+	if opts.OutputDir != "" {
+		checks = append(checks, dirCheck(opts.OutputDir), diskCheck(opts.OutputDir, opts.MinFree))
+	}
+	checks = append(checks, networkCheck(ctx))
+	if session, ok := downloader.LoginSession(ctx, downloader.SessionMaxAge); ok {
+		checks = append(checks, loginCheck(session))
+	}
+	return checks
+}
+
+// ytDlpCheck yt-dlp 只用于其他网站的视频，缺少时不影响知乎视频
+func ytDlpCheck() Check {
+	check := Check{Name: "yt-dlp"}
+	path, err := downloader.YtDlpPath()
+	if err != nil {
+		check.Error = err.Error()
+		check.Fix = "下载 B 站、YouTube 等网站的视频需要 yt-dlp：pip install yt-dlp 或 brew install yt-dlp，或在 tools.yt_dlp 中指定路径"
+		return check
+	}
+	check.OK, check.Detail = true, path
+	return check
+}
+
+// pythonCheck 运行 zhihu_downloader.py 的解释器能否导入需要的包。只有 Python 后端用到，缺少时不影响内置下载
+func pythonCheck(ctx context.Context) Check {
+	check := Check{Name: "python"}
+	script := downloader.PythonScript()
+	if _, err := os.Stat(script); err != nil {
+		check.Error = fmt.Sprintf("未找到 %s", script)
+		check.Fix = "把 zhihu_downloader.py 放在可执行文件旁边；只使用内置下载时可以忽略"
+		return check
+	}
+	dir := filepath.Dir(script)
+	venvFix := fmt.Sprintf("cd %s && python3 -m venv .venv && .venv/bin/pip install -r requirements.txt，或在 download.python 中指定解释器", dir)
+
+	python := downloader.PythonInterpreter()
+	path, err := exec.LookPath(python)
+	if err != nil {
+		check.Error = fmt.Sprintf("未找到 %s", python)
+		check.Fix = venvFix
+		return check
+	}
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "-c", "import "+strings.Join(pythonModules, ", ")).CombinedOutput()
+	if err != nil {
+		check.Error = fmt.Sprintf("%s 缺少依赖: %s", path, lastLine(out, err))
+		check.Fix = venvFix
+		return check
+	}
+	check.OK, check.Detail = true, path
+	if !strings.Contains(path, ".venv") {
+		check.Detail += "（未使用虚拟环境）"
+	}
+	return check
+}
+
+// networkCheck 能否访问知乎。收到任何 HTTP 响应都算可以访问，403 等状态码只记在 Detail 中
+func networkCheck(ctx context.Context) Check {
+	check := Check{Name: "network", Required: true}
+	ctx, cancel := context.WithTimeout(ctx, networkTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, zhihuURL, nil)
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	req.Header = downloader.HeadersFor(zhihuURL)
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		check.Error = fmt.Sprintf("无法访问 %s: %v", zhihuURL, err)
+		check.Fix = "检查网络和 DNS；需要代理时设置 HTTPS_PROXY 环境变量"
+		return check
+	}
+	resp.Body.Close()
+	check.OK = true
+	check.Detail = fmt.Sprintf("%s 返回 %d，耗时 %s", zhihuURL, resp.StatusCode, time.Since(start).Round(time.Millisecond))
+	return check
+}
+
+// lastLine 返回命令输出的最后一行（Python 的异常信息），没有输出时返回 err
+func lastLine(out []byte, err error) string {
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if line := strings.TrimSpace(lines[len(lines)-1]); line != "" {
+		return line
+	}
+	return err.Error()
+}
+
+// PrintDoctor 把自检结果写到 w，返回必需的检查是否都通过
+func PrintDoctor(w io.Writer, checks []Check) bool {
+	ok, failed := true, 0
+	for _, c := range checks {
+		mark := "✓"
+		switch {
+		case c.OK:
+		case c.Required:
+			mark, ok = "✗", false
+			failed++
+		default:
+			mark = "!"
+			failed++
+		}
+		text := c.Detail
+		if !c.OK {
+			text = c.Error
+		}
+		fmt.Fprintf(w, "%s %-12s %s\n", mark, c.Name, text)
+		if !c.OK && c.Fix != "" {
+			fmt.Fprintf(w, "  %-12s 修复: %s\n", "", c.Fix)
+		}
+	}
+	fmt.Fprintln(w)
+	switch {
+	case failed == 0:
+		fmt.Fprintln(w, "全部检查通过")
+	case ok:
+		fmt.Fprintf(w, "%d 项检查未通过（!），部分功能不可用，下载可以正常进行\n", failed)
+	default:
+		fmt.Fprintf(w, "%d 项检查未通过，其中标记 ✗ 的必须解决，否则下载会失败\n", failed)
+	}
+	return ok
+}
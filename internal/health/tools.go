@@ -0,0 +1,93 @@
+// Package health 检查服务依赖的外部程序（ffmpeg、yt-dlp、Python、Whisper），
+// 供健康检查接口和启动日志使用。在容器中运行时可以据此确认路径配置是否正确。
+package health
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/media"
+	"zhihu-downloader/internal/transcriber"
+)
+
+// Tool 外部程序的查找结果
+type Tool struct {
+	Name string `json:"name"`
+	// Purpose 用途
+	Purpose string `json:"purpose"`
+	// Path 找到的可执行文件路径
+	Path  string `json:"path,omitempty"`
+	Found bool   `json:"found"`
+	// Required 缺少时下载无法进行
+	Required bool   `json:"required"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Tools 按当前配置查找各个外部程序
+func Tools() []Tool {
+	return append(downloadTools(), whisperTool())
+}
+
+// downloadTools 下载使用的外部程序
+func downloadTools() []Tool {
+	tools := []Tool{
+		lookup("ffmpeg", "合并视频流、提取音频、生成封面", media.FFmpeg(), true),
+		lookup("ffprobe", "读取视频时长和分辨率", media.FFprobe(), false),
+	}
+
+	ytDlp := Tool{Name: "yt-dlp", Purpose: "下载 B 站、YouTube 等其他网站的视频"}
+	if path, err := downloader.YtDlpPath(); err != nil {
+		ytDlp.Error = err.Error()
+	} else {
+		ytDlp.Path, ytDlp.Found = path, true
+	}
+
+	python := lookup("python", "运行 zhihu_downloader.py 下载需要登录的视频", downloader.PythonInterpreter(), false)
+	script := Tool{Name: "zhihu_downloader.py", Purpose: "Python 下载后端", Path: downloader.PythonScript()}
+	if _, err := os.Stat(script.Path); err != nil {
+		script.Error = fmt.Sprintf("未找到 %s", script.Path)
+	} else {
+		script.Found = true
+	}
+
+	return append(tools, ytDlp, python, script)
+}
+
+// whisperTool 自动选择或配置的 Whisper 后端
+func whisperTool() Tool {
+	status := transcriber.CurrentStatus()
+	whisper := Tool{Name: "whisper", Purpose: "语音转文字", Path: status.Path, Found: status.Error == "", Error: status.Error}
+	if status.Backend != "" {
+		whisper.Name = status.Backend
+	}
+	return whisper
+}
+
+func lookup(name, purpose, configured string, required bool) Tool {
+	tool := Tool{Name: name, Purpose: purpose, Required: required}
+	path, err := exec.LookPath(configured)
+	if err != nil {
+		tool.Error = fmt.Sprintf("未找到 %s", configured)
+		return tool
+	}
+	tool.Path, tool.Found = path, true
+	return tool
+}
+
+// LogTools 启动时记录外部程序的查找结果，缺少的程序记为警告。
+// Whisper 由 transcriber.LogStatus 单独记录
+func LogTools() {
+	for _, t := range downloadTools() {
+		switch {
+		case t.Found:
+			slog.Debug("外部程序", "name", t.Name, "path", t.Path)
+		case t.Required:
+			slog.Error("缺少外部程序，下载会失败", "name", t.Name, "purpose", t.Purpose, "error", t.Error)
+		default:
+			slog.Warn("缺少外部程序，部分功能不可用", "name", t.Name, "purpose", t.Purpose, "error", t.Error)
+		}
+	}
+}
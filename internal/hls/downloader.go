@@ -0,0 +1,633 @@
+// Package hls 实现不依赖 ffmpeg 的 HLS (m3u8) 下载：
+// 解析播放列表、并行下载分片、失败重试，最后按顺序合并为单个文件。
+package hls
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"zhihu-downloader/internal/atomicfile"
+	"zhihu-downloader/internal/backoff"
+	"zhihu-downloader/internal/media"
+	"zhihu-downloader/internal/proc"
+	"zhihu-downloader/internal/ratelimit"
+)
+
+const (
+	// MaxConcurrency 同时下载的分片数上限，连接过多时 CDN 会限流或拒绝请求
+	MaxConcurrency   = 16
+	defaultRetries   = 3
+	progressInterval = 500 * time.Millisecond
+	// 重试前等待 1s、2s、4s……（加随机抖动），最多 30s
+	retryBaseDelay = time.Second
+	retryMaxDelay  = 30 * time.Second
+)
+
+// Options 下载参数
+type Options struct {
+	// Concurrency 同时下载的分片数（最多 MaxConcurrency），0 时按分片数自动选择，见 AutoConcurrency
+	Concurrency int
+	// Retries 单个分片失败后的重试次数，默认 3
+	Retries int
+	// Headers 附加到每个请求上的 HTTP 头（Referer、User-Agent 等）
+	Headers http.Header
+	Client  *http.Client
+	// OnProgress 在下载过程中周期性回调
+	OnProgress func(Progress)
+	// FFmpeg 用于把 TS 封装为 MP4 的 ffmpeg 路径，默认从 PATH 查找
+	FFmpeg string
+	// Logger 记录重试和封装失败，默认 slog.Default()
+	Logger *slog.Logger
+	// Limiter 限制分片的下载速度，nil 时不限速
+	Limiter *ratelimit.Limiter
+	// RemuxArgs 封装 MP4 时追加在输出文件前的 ffmpeg 参数
+	RemuxArgs []string
+	// OnRemux 封装成功后回调使用的方式（media.RemuxCopy 等），没有封装或封装失败保留 TS 时不回调
+	OnRemux func(strategy string)
+}
+
+// Progress 下载进度（字节数为真实写入的数据量）
+type Progress struct {
+	SegmentsDone    int
+	SegmentsTotal   int
+	BytesDownloaded int64
+	// TotalBytes 根据已完成分片的平均大小估算，未知时为 0
+	TotalBytes int64
+	Elapsed    time.Duration
+}
+
+// Percentage 返回 0-100 的进度
+func (p Progress) Percentage() int {
+	if p.SegmentsTotal == 0 {
+		return 0
+	}
+	if p.TotalBytes > 0 {
+		pct := int(p.BytesDownloaded * 100 / p.TotalBytes)
+		if pct > 100 {
+			pct = 100
+		}
+		return pct
+	}
+	return p.SegmentsDone * 100 / p.SegmentsTotal
+}
+
+// BytesPerSecond 返回平均下载速度
+func (p Progress) BytesPerSecond() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.BytesDownloaded) / p.Elapsed.Seconds()
+}
+
+// FormatSpeed 把字节速度格式化为 KB/s、MB/s
+func FormatSpeed(bytesPerSec float64) string {
+	if bytesPerSec >= 1024*1024 {
+		return fmt.Sprintf("%.1f MB/s", bytesPerSec/1024/1024)
+	}
+	return fmt.Sprintf("%.0f KB/s", bytesPerSec/1024)
+}
+
+// AutoConcurrency 未指定 Concurrency 时的并发数：单个连接的速度受 CDN 限制，
+// 分片少（短视频）时 4 个连接已经能跑满带宽，分片多（长视频、高清晰度）时用 8 个，不超过分片数
+func AutoConcurrency(segments int) int {
+	n := 4
+	if segments > 60 {
+		n = 8
+	}
+	return max(1, min(n, segments))
+}
+
+// IsPlaylistURL 判断 URL 是否指向 m3u8 播放列表
+func IsPlaylistURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return strings.HasSuffix(strings.ToLower(u.Path), ".m3u8")
+}
+
+// Downloader HLS 下载器
+type Downloader struct {
+	opts Options
+
+	keysMu sync.Mutex
+	keys   map[string][]byte
+}
+
+// New 创建下载器，未设置的参数使用默认值
+func New(opts Options) *Downloader {
+	opts.Concurrency = min(max(opts.Concurrency, 0), MaxConcurrency)
+	if opts.Retries < 0 {
+		opts.Retries = 0
+	} else if opts.Retries == 0 {
+		opts.Retries = defaultRetries
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 2 * time.Minute}
+	}
+	if opts.FFmpeg == "" {
+		opts.FFmpeg = "ffmpeg"
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	return &Downloader{opts: opts, keys: make(map[string][]byte)}
+}
+
+// Download 下载 playlistURL 并合并保存到 outputPath。
+// 返回最终文件路径：TS 流在本机有 ffmpeg 时会无损封装为 MP4，
+// 否则保留为同名 .ts 文件。
+func (d *Downloader) Download(ctx context.Context, playlistURL, outputPath string) (string, error) {
+	playlist, err := d.fetchPlaylist(ctx, playlistURL)
+	if err != nil {
+		return "", err
+	}
+
+	if playlist.Master {
+		variant, ok := playlist.BestVariant()
+		if !ok {
+			return "", fmt.Errorf("主播放列表中没有可用的码率")
+		}
+		playlist, err = d.fetchPlaylist(ctx, variant.URI)
+		if err != nil {
+			return "", err
+		}
+		if playlist.Master {
+			return "", fmt.Errorf("播放列表嵌套层级过深")
+		}
+	}
+
+	partsDir := outputPath + ".parts"
+	if err := preparePartsDir(partsDir, len(playlist.Segments)); err != nil {
+		return "", err
+	}
+
+	if err := d.downloadSegments(ctx, playlist, partsDir); err != nil {
+		return "", err
+	}
+
+	mergedPath := outputPath
+	fmp4 := playlist.InitURI != ""
+	if !fmp4 {
+		mergedPath = strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".ts"
+	}
+
+	if err := d.merge(ctx, playlist, partsDir, mergedPath); err != nil {
+		return "", err
+	}
+	os.RemoveAll(partsDir)
+
+	if fmp4 || mergedPath == outputPath {
+		return mergedPath, nil
+	}
+	return d.remux(ctx, mergedPath, outputPath), nil
+}
+
+// EstimateSize 按码率和总时长估算下载大小（字节）。主播放列表选择码率最高的子播放列表，
+// 媒体播放列表没有码率信息，返回 0
+func (d *Downloader) EstimateSize(ctx context.Context, playlistURL string) (int64, error) {
+	playlist, err := d.fetchPlaylist(ctx, playlistURL)
+	if err != nil {
+		return 0, err
+	}
+	if !playlist.Master {
+		return 0, nil
+	}
+	variant, ok := playlist.BestVariant()
+	if !ok || variant.Bandwidth <= 0 {
+		return 0, nil
+	}
+	media, err := d.fetchPlaylist(ctx, variant.URI)
+	if err != nil {
+		return 0, err
+	}
+	return int64(float64(variant.Bandwidth) / 8 * media.Duration()), nil
+}
+
+func (d *Downloader) fetchPlaylist(ctx context.Context, rawURL string) (*Playlist, error) {
+	base, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("无效的播放列表地址: %v", err)
+	}
+
+	var body []byte
+	err = d.retry(ctx, func() error {
+		var err error
+		body, err = d.get(ctx, rawURL, nil)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("获取播放列表失败: %w", err)
+	}
+	return Parse(string(body), base)
+}
+
+func (d *Downloader) downloadSegments(ctx context.Context, playlist *Playlist, partsDir string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	total := len(playlist.Segments)
+	var (
+		done       int64
+		bytesDone  int64
+		doneBytes  int64 // 已完成分片的字节数，用于估算总大小
+		firstErr   error
+		errOnce    sync.Once
+		wg         sync.WaitGroup
+		startTime  = time.Now()
+		jobs       = make(chan Segment)
+		reportDone = make(chan struct{})
+	)
+
+	report := func() {
+		if d.opts.OnProgress == nil {
+			return
+		}
+		p := Progress{
+			SegmentsDone:    int(atomic.LoadInt64(&done)),
+			SegmentsTotal:   total,
+			BytesDownloaded: atomic.LoadInt64(&bytesDone),
+			Elapsed:         time.Since(startTime),
+		}
+		if p.SegmentsDone > 0 {
+			p.TotalBytes = atomic.LoadInt64(&doneBytes) / int64(p.SegmentsDone) * int64(total)
+		}
+		d.opts.OnProgress(p)
+	}
+
+	go func() {
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				report()
+			case <-reportDone:
+				return
+			}
+		}
+	}()
+
+	concurrency := d.opts.Concurrency
+	if concurrency == 0 {
+		concurrency = AutoConcurrency(total)
+	}
+	d.opts.Logger.Debug("开始下载分片", "segments", total, "concurrency", concurrency)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for seg := range jobs {
+				n, err := d.downloadSegment(ctx, seg, partsDir, &bytesDone)
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = fmt.Errorf("分片 %d 下载失败: %w", seg.Index, err)
+						cancel()
+					})
+					continue
+				}
+				atomic.AddInt64(&doneBytes, n)
+				atomic.AddInt64(&done, 1)
+			}
+		}()
+	}
+
+feed:
+	for _, seg := range playlist.Segments {
+		select {
+		case jobs <- seg:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(reportDone)
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	report()
+	return nil
+}
+
+// downloadSegment 下载单个分片到 partsDir，返回写入的字节数
+func (d *Downloader) downloadSegment(ctx context.Context, seg Segment, partsDir string, counter *int64) (int64, error) {
+	partPath := segmentPath(partsDir, seg.Index)
+
+	// 上次下载留下的完整分片直接复用（分片先写 .tmp 再重命名，存在即完整）
+	if info, err := os.Stat(partPath); err == nil && info.Size() > 0 {
+		return info.Size(), nil
+	}
+
+	var data []byte
+	err := d.retry(ctx, func() error {
+		var err error
+		data, err = d.get(ctx, seg.URI, counter)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if seg.Key != nil {
+		key, err := d.key(ctx, seg.Key.URI)
+		if err != nil {
+			return 0, err
+		}
+		data, err = decrypt(data, key, seg)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	tmpPath := partPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmpPath, partPath); err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
+
+func (d *Downloader) key(ctx context.Context, uri string) ([]byte, error) {
+	d.keysMu.Lock()
+	defer d.keysMu.Unlock()
+
+	if key, ok := d.keys[uri]; ok {
+		return key, nil
+	}
+	var key []byte
+	err := d.retry(ctx, func() error {
+		var err error
+		key, err = d.get(ctx, uri, nil)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("获取解密密钥失败: %w", err)
+	}
+	if len(key) != 16 {
+		return nil, fmt.Errorf("解密密钥长度无效: %d", len(key))
+	}
+	d.keys[uri] = key
+	return key, nil
+}
+
+// merge 按顺序把分片拼接到 outputPath
+func (d *Downloader) merge(ctx context.Context, playlist *Playlist, partsDir, outputPath string) error {
+	tmpPath := outputPath + ".tmp"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	if playlist.InitURI != "" {
+		var init []byte
+		err := d.retry(ctx, func() error {
+			var err error
+			init, err = d.get(ctx, playlist.InitURI, nil)
+			return err
+		})
+		if err == nil {
+			_, err = out.Write(init)
+		}
+		if err != nil {
+			out.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("获取初始化分片失败: %w", err)
+		}
+	}
+
+	for _, seg := range playlist.Segments {
+		if err := appendFile(out, segmentPath(partsDir, seg.Index)); err != nil {
+			out.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("合并分片 %d 失败: %v", seg.Index, err)
+		}
+	}
+
+	if err := out.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, outputPath)
+}
+
+// get 发起 GET 请求并读取完整响应体，counter 不为空时实时累加读取的字节数
+func (d *Downloader) get(ctx context.Context, rawURL string, counter *int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, values := range d.opts.Headers {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+
+	resp, err := d.opts.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	var reader io.Reader = resp.Body
+	if counter != nil {
+		reader = &countingReader{r: ratelimit.Reader(ctx, resp.Body, d.opts.Limiter), n: counter}
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil && counter != nil {
+		// 失败的部分不计入进度
+		atomic.AddInt64(counter, -int64(len(data)))
+	}
+	return data, err
+}
+
+// StatusError 服务器返回了 200 以外的状态码
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("HTTP %d", e.StatusCode)
+}
+
+// Temporary 重试是否可能成功：5xx、408 和 429 是暂时的，其他状态码（403、404 等）重试也不会成功
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests
+}
+
+// Temporary 判断下载错误是否是暂时的（网络中断、服务器 5xx 等），重试可能成功
+func Temporary(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.Temporary()
+	}
+	return true
+}
+
+// retry 执行 fn，暂时的错误按指数退避（加随机抖动）重试
+func (d *Downloader) retry(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 0; attempt <= d.opts.Retries; attempt++ {
+		if attempt > 0 {
+			if err := backoff.Sleep(ctx, backoff.Delay(attempt, retryBaseDelay, retryMaxDelay)); err != nil {
+				return err
+			}
+		}
+		if err = fn(); err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !Temporary(err) {
+			return err
+		}
+		d.opts.Logger.Debug("请求失败，准备重试", "attempt", attempt+1, "error", err)
+	}
+	return err
+}
+
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	atomic.AddInt64(c.n, int64(n))
+	return n, err
+}
+
+func decrypt(data, key []byte, seg Segment) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("加密分片长度无效")
+	}
+
+	iv := seg.Key.IV
+	if iv == nil {
+		iv = make([]byte, aes.BlockSize)
+		binary.BigEndian.PutUint64(iv[8:], uint64(seg.Sequence))
+	}
+
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+
+	// 去掉 PKCS#7 填充
+	if n := len(plain); n > 0 {
+		pad := int(plain[n-1])
+		if pad > 0 && pad <= aes.BlockSize && pad <= n {
+			plain = plain[:n-pad]
+		}
+	}
+	return plain, nil
+}
+
+func appendFile(dst io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(dst, f)
+	return err
+}
+
+// preparePartsDir 创建分片目录。目录中记录了分片总数，
+// 与当前播放列表不一致时（例如换了清晰度）清空旧分片
+func preparePartsDir(partsDir string, total int) error {
+	manifest := filepath.Join(partsDir, "segments")
+	if data, err := os.ReadFile(manifest); err == nil && strings.TrimSpace(string(data)) != strconv.Itoa(total) {
+		if err := os.RemoveAll(partsDir); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(partsDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(manifest, []byte(strconv.Itoa(total)), 0644)
+}
+
+func segmentPath(partsDir string, index int) string {
+	return filepath.Join(partsDir, fmt.Sprintf("%05d.ts", index))
+}
+
+// remux 在本机有 ffmpeg 时把 TS 无损封装为 MP4。直接复制失败时按 ffmpeg 的输出改为封装 MKV 或重新编码，
+// 都失败则保留 TS
+func (d *Downloader) remux(ctx context.Context, tsPath, outputPath string) string {
+	ffmpeg, err := exec.LookPath(d.opts.FFmpeg)
+	if err != nil {
+		return tsPath
+	}
+	base := strings.TrimSuffix(outputPath, filepath.Ext(outputPath))
+	for strategy := media.RemuxCopy; strategy != ""; {
+		codec, ext := media.RemuxArgs(strategy)
+		if strategy == media.RemuxCopy {
+			codec = append(codec, "-bsf:a", "aac_adtstoasc")
+		}
+		path := base + ext
+		part := atomicfile.MediaPath(path)
+		args := append(append([]string{"-y", "-i", tsPath}, codec...), d.opts.RemuxArgs...)
+		output, err := proc.Command(ctx, ffmpeg, append(args, part)...).CombinedOutput()
+		if err == nil {
+			err = atomicfile.Commit(part, path)
+		}
+		if err == nil {
+			os.Remove(tsPath)
+			if d.opts.OnRemux != nil {
+				d.opts.OnRemux(strategy)
+			}
+			return path
+		}
+		os.Remove(part)
+		if ctx.Err() != nil {
+			return tsPath
+		}
+		next := media.NextRemux(strategy, string(output))
+		if next == "" {
+			d.opts.Logger.Warn("封装 MP4 失败，保留 TS 文件", "strategy", strategy, "error", err, "output", lastLines(string(output), 5))
+			return tsPath
+		}
+		d.opts.Logger.Warn("封装失败，换一种方式重试", "strategy", strategy, "next", next, "output", lastLines(string(output), 5))
+		strategy = next
+	}
+	return tsPath
+}
+
+// lastLines 返回输出的最后 n 行
+func lastLines(output string, n int) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,215 @@
+package hls
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Variant 主播放列表中的一个码率/清晰度
+type Variant struct {
+	URI        string
+	Bandwidth  int
+	Resolution string
+}
+
+// Key EXT-X-KEY 描述的加密信息（只支持 AES-128）
+type Key struct {
+	Method string
+	URI    string
+	IV     []byte
+}
+
+// Segment 媒体播放列表中的一个分片
+type Segment struct {
+	Index    int
+	URI      string
+	Duration float64
+	Key      *Key
+	// 未指定 IV 时按规范使用媒体序列号作为 IV
+	Sequence int
+}
+
+// Playlist 解析后的 m3u8 内容
+type Playlist struct {
+	// Master 为 true 时只有 Variants 有效
+	Master   bool
+	Variants []Variant
+
+	Segments []Segment
+	// InitURI 为 EXT-X-MAP 指定的初始化分片（fMP4）
+	InitURI string
+}
+
+// Duration 返回所有分片的总时长（秒）
+func (p *Playlist) Duration() float64 {
+	var total float64
+	for _, s := range p.Segments {
+		total += s.Duration
+	}
+	return total
+}
+
+// BestVariant 返回码率最高的子播放列表
+func (p *Playlist) BestVariant() (Variant, bool) {
+	if len(p.Variants) == 0 {
+		return Variant{}, false
+	}
+	best := p.Variants[0]
+	for _, v := range p.Variants[1:] {
+		if v.Bandwidth > best.Bandwidth {
+			best = v
+		}
+	}
+	return best, true
+}
+
+// Parse 解析 m3u8 文本，base 用于把相对地址解析为绝对地址
+func Parse(content string, base *url.URL) (*Playlist, error) {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	if !scanner.Scan() || !strings.HasPrefix(strings.TrimSpace(scanner.Text()), "#EXTM3U") {
+		return nil, fmt.Errorf("不是有效的 m3u8 播放列表")
+	}
+
+	p := &Playlist{}
+	var (
+		key         *Key
+		sequence    int
+		duration    float64
+		pendingInf  bool
+		pendingVar  *Variant
+		segmentsIdx int
+	)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			attrs := parseAttributes(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))
+			bw, _ := strconv.Atoi(attrs["BANDWIDTH"])
+			pendingVar = &Variant{Bandwidth: bw, Resolution: attrs["RESOLUTION"]}
+			p.Master = true
+
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			sequence, _ = strconv.Atoi(strings.TrimPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"))
+
+		case strings.HasPrefix(line, "#EXT-X-KEY:"):
+			attrs := parseAttributes(strings.TrimPrefix(line, "#EXT-X-KEY:"))
+			method := attrs["METHOD"]
+			if method == "" || method == "NONE" {
+				key = nil
+				continue
+			}
+			if method != "AES-128" {
+				return nil, fmt.Errorf("不支持的加密方式: %s", method)
+			}
+			key = &Key{Method: method, URI: resolve(base, attrs["URI"])}
+			if iv := attrs["IV"]; iv != "" {
+				raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(iv, "0x"), "0X"))
+				if err != nil || len(raw) != 16 {
+					return nil, fmt.Errorf("无效的 IV: %s", iv)
+				}
+				key.IV = raw
+			}
+
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			attrs := parseAttributes(strings.TrimPrefix(line, "#EXT-X-MAP:"))
+			p.InitURI = resolve(base, attrs["URI"])
+
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value := strings.TrimPrefix(line, "#EXTINF:")
+			if i := strings.Index(value, ","); i >= 0 {
+				value = value[:i]
+			}
+			duration, _ = strconv.ParseFloat(value, 64)
+			pendingInf = true
+
+		case strings.HasPrefix(line, "#"):
+			// 其他标签忽略
+
+		default:
+			if pendingVar != nil {
+				pendingVar.URI = resolve(base, line)
+				p.Variants = append(p.Variants, *pendingVar)
+				pendingVar = nil
+				continue
+			}
+			if !pendingInf {
+				continue
+			}
+			p.Segments = append(p.Segments, Segment{
+				Index:    segmentsIdx,
+				URI:      resolve(base, line),
+				Duration: duration,
+				Key:      key,
+				Sequence: sequence,
+			})
+			segmentsIdx++
+			sequence++
+			pendingInf = false
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !p.Master && len(p.Segments) == 0 {
+		return nil, fmt.Errorf("播放列表中没有分片")
+	}
+	return p, nil
+}
+
+// parseAttributes 解析 KEY=VALUE,KEY="VALUE" 形式的属性列表
+func parseAttributes(s string) map[string]string {
+	attrs := make(map[string]string)
+	for len(s) > 0 {
+		eq := strings.Index(s, "=")
+		if eq < 0 {
+			break
+		}
+		name := strings.TrimSpace(s[:eq])
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.Index(s[1:], `"`)
+			if end < 0 {
+				value, s = s[1:], ""
+			} else {
+				value, s = s[1:end+1], s[end+2:]
+			}
+			// 引号之后到下一个逗号之间的内容无效，忽略
+			if comma := strings.Index(s, ","); comma >= 0 {
+				s = s[comma+1:]
+			} else {
+				s = ""
+			}
+		} else if comma := strings.Index(s, ","); comma >= 0 {
+			value, s = s[:comma], s[comma+1:]
+		} else {
+			value, s = s, ""
+		}
+		attrs[name] = value
+	}
+	return attrs
+}
+
+func resolve(base *url.URL, ref string) string {
+	if base == nil {
+		return ref
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return base.ResolveReference(u).String()
+}
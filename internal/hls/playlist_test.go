@@ -0,0 +1,130 @@
+package hls
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAttributes(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want map[string]string
+	}{
+		{"普通属性", "BANDWIDTH=1280000,RESOLUTION=1280x720", map[string]string{"BANDWIDTH": "1280000", "RESOLUTION": "1280x720"}},
+		{"引号中的逗号和等号", `METHOD=AES-128,URI="key?a=1,b=2",IV=0x01`, map[string]string{"METHOD": "AES-128", "URI": "key?a=1,b=2", "IV": "0x01"}},
+		{"名称前的空格", "A=1, B=2", map[string]string{"A": "1", "B": "2"}},
+		{"空值", "A=,B=2", map[string]string{"A": "", "B": "2"}},
+		{"没有结束引号", `URI="key.bin`, map[string]string{"URI": "key.bin"}},
+		{"引号后多余的内容", `URI="key.bin"x y,B=2`, map[string]string{"URI": "key.bin", "B": "2"}},
+		{"没有等号", "NOVALUE", map[string]string{}},
+		{"末尾没有等号", "A=1,NOVALUE", map[string]string{"A": "1"}},
+		{"空字符串", "", map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseAttributes(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseAttributes(%q) = %v，应为 %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	base, _ := url.Parse("https://cdn.example.com/video/index.m3u8")
+	iv := strings.Repeat("ab", 16)
+
+	tests := []struct {
+		name    string
+		content string
+		check   func(t *testing.T, p *Playlist)
+		wantErr string
+	}{
+		{
+			name: "主播放列表",
+			content: `#EXTM3U
+#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360
+low/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2400000,RESOLUTION=1280x720,CODECS="avc1.4d401f,mp4a.40.2"
+https://other.example.com/high.m3u8
+`,
+			check: func(t *testing.T, p *Playlist) {
+				best, ok := p.BestVariant()
+				if !p.Master || len(p.Variants) != 2 || !ok {
+					t.Fatalf("Master = %v, Variants = %+v", p.Master, p.Variants)
+				}
+				if p.Variants[0].URI != "https://cdn.example.com/video/low/index.m3u8" {
+					t.Errorf("相对地址 = %s", p.Variants[0].URI)
+				}
+				if best.Bandwidth != 2400000 || best.Resolution != "1280x720" || best.URI != "https://other.example.com/high.m3u8" {
+					t.Errorf("BestVariant = %+v", best)
+				}
+			},
+		},
+		{
+			name: "加密的媒体播放列表",
+			content: `#EXTM3U
+#EXT-X-MEDIA-SEQUENCE:7
+#EXT-X-MAP:URI="init.mp4"
+#EXT-X-KEY:METHOD=AES-128,URI="/keys/1.key"
+#EXTINF:4.5,
+seg0.ts
+#EXT-X-KEY:METHOD=AES-128,URI="key2",IV=0x` + iv + `
+#EXTINF:3,title
+seg1.ts
+#EXT-X-KEY:METHOD=NONE
+#EXTINF:2.5
+seg2.ts
+not-a-segment.ts
+#EXT-X-ENDLIST
+`,
+			check: func(t *testing.T, p *Playlist) {
+				if p.Master || len(p.Segments) != 3 {
+					t.Fatalf("Master = %v, Segments = %+v", p.Master, p.Segments)
+				}
+				if p.InitURI != "https://cdn.example.com/video/init.mp4" {
+					t.Errorf("InitURI = %s", p.InitURI)
+				}
+				if d := p.Duration(); d != 10 {
+					t.Errorf("Duration = %v，应为 10", d)
+				}
+				s0, s1, s2 := p.Segments[0], p.Segments[1], p.Segments[2]
+				if s0.Index != 0 || s0.Sequence != 7 || s0.URI != "https://cdn.example.com/video/seg0.ts" {
+					t.Errorf("第一个分片 = %+v", s0)
+				}
+				if s0.Key == nil || s0.Key.URI != "https://cdn.example.com/keys/1.key" || s0.Key.IV != nil {
+					t.Errorf("第一个分片的密钥 = %+v", s0.Key)
+				}
+				if s1.Sequence != 8 || s1.Key == nil || len(s1.Key.IV) != 16 || s1.Key.IV[0] != 0xab {
+					t.Errorf("第二个分片 = %+v, 密钥 = %+v", s1, s1.Key)
+				}
+				if s2.Key != nil || s2.Duration != 2.5 {
+					t.Errorf("METHOD=NONE 之后的分片 = %+v", s2)
+				}
+			},
+		},
+		{name: "没有 #EXTM3U", content: "#EXTINF:1,\nseg.ts\n", wantErr: "不是有效的 m3u8"},
+		{name: "空内容", content: "", wantErr: "不是有效的 m3u8"},
+		{name: "没有分片", content: "#EXTM3U\n#EXT-X-ENDLIST\nseg.ts\n", wantErr: "没有分片"},
+		{name: "不支持的加密方式", content: "#EXTM3U\n#EXT-X-KEY:METHOD=SAMPLE-AES,URI=\"k\"\n#EXTINF:1,\nseg.ts\n", wantErr: "不支持的加密方式"},
+		{name: "IV 不是十六进制", content: "#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"k\",IV=0xZZ\n#EXTINF:1,\nseg.ts\n", wantErr: "无效的 IV"},
+		{name: "IV 长度不对", content: "#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"k\",IV=0x0102\n#EXTINF:1,\nseg.ts\n", wantErr: "无效的 IV"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse(tt.content, base)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("错误 = %v，应包含 %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, p)
+		})
+	}
+}
@@ -0,0 +1,494 @@
+// Package httpdl 实现不依赖 ffmpeg 的 HTTP 直链下载：服务器支持 Range 请求时按块并行下载，
+// 中断后从已写入的位置继续；不支持时单连接下载。进度按 Content-Length 计算。
+package httpdl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"zhihu-downloader/internal/backoff"
+	"zhihu-downloader/internal/hls"
+	"zhihu-downloader/internal/ratelimit"
+)
+
+const (
+	// MaxConcurrency 同时下载的块数上限，与 m3u8 分片相同
+	MaxConcurrency = hls.MaxConcurrency
+	// ChunkSize 每块的大小，最后一块可能更小
+	ChunkSize          = 8 << 20
+	defaultConcurrency = 4
+	defaultRetries     = 3
+	progressInterval   = 500 * time.Millisecond
+	// 重试前等待 1s、2s、4s……（加随机抖动），最多 30s
+	retryBaseDelay = time.Second
+	retryMaxDelay  = 30 * time.Second
+)
+
+// Options 下载参数
+type Options struct {
+	// Concurrency 同时下载的块数（最多 MaxConcurrency），0 时为 4，不超过块数
+	Concurrency int
+	// Retries 单个块失败后的重试次数，默认 3，负数表示不重试。已写入的部分不会重新下载
+	Retries int
+	// Headers 附加到每个请求上的 HTTP 头（Referer、User-Agent 等）
+	Headers http.Header
+	Client  *http.Client
+	// OnProgress 在下载过程中周期性回调
+	OnProgress func(Progress)
+	// Logger 记录重试和续传，默认 slog.Default()
+	Logger *slog.Logger
+	// Limiter 限制下载速度，nil 时不限速
+	Limiter *ratelimit.Limiter
+}
+
+// Progress 下载进度
+type Progress struct {
+	BytesDownloaded int64
+	// TotalBytes 服务器返回的文件大小，未知时为 0
+	TotalBytes int64
+	// Resumed 续传时上次已下载的字节数，不计入速度
+	Resumed int64
+	Elapsed time.Duration
+}
+
+// Percentage 返回 0-100 的进度，大小未知时为 0
+func (p Progress) Percentage() int {
+	if p.TotalBytes <= 0 {
+		return 0
+	}
+	return int(min(p.BytesDownloaded*100/p.TotalBytes, 100))
+}
+
+// BytesPerSecond 返回本次下载的平均速度
+func (p Progress) BytesPerSecond() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.BytesDownloaded-p.Resumed) / p.Elapsed.Seconds()
+}
+
+// IsDirectURL 判断 URL 是否指向可以直接下载的 MP4 文件
+func IsDirectURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	return strings.HasSuffix(strings.ToLower(u.Path), ".mp4")
+}
+
+// Downloader HTTP 直链下载器
+type Downloader struct {
+	opts Options
+}
+
+// New 创建下载器，未设置的参数使用默认值
+func New(opts Options) *Downloader {
+	opts.Concurrency = min(max(opts.Concurrency, 0), MaxConcurrency)
+	if opts.Concurrency == 0 {
+		opts.Concurrency = defaultConcurrency
+	}
+	if opts.Retries < 0 {
+		opts.Retries = 0
+	} else if opts.Retries == 0 {
+		opts.Retries = defaultRetries
+	}
+	if opts.Client == nil {
+		// 大文件的单个块可能需要较长时间，不设置整体超时，只限制等待响应头的时间
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.ResponseHeaderTimeout = time.Minute
+		opts.Client = &http.Client{Transport: transport}
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	return &Downloader{opts: opts}
+}
+
+// remote 探测到的文件信息
+type remote struct {
+	// Size 文件大小，未知时为 -1
+	Size int64
+	// Ranges 服务器是否支持 Range 请求
+	Ranges       bool
+	ETag         string
+	LastModified string
+}
+
+// state 保存在 .parts 目录中的下载状态，续传时据此判断已写入的部分
+type state struct {
+	Size int64 `json:"size"`
+	// Ranges 按块下载；为 false 时只有一块，中断后从头下载
+	Ranges       bool    `json:"ranges"`
+	ETag         string  `json:"etag,omitempty"`
+	LastModified string  `json:"last_modified,omitempty"`
+	Chunks       []chunk `json:"chunks"`
+}
+
+// chunk 文件中 [Start, End) 的一段，Written 为已写入的字节数。End 为 -1 表示读到响应结束
+type chunk struct {
+	Start   int64 `json:"start"`
+	End     int64 `json:"end"`
+	Written int64 `json:"written"`
+}
+
+func (c *chunk) done() bool {
+	return c.End >= 0 && c.Start+atomic.LoadInt64(&c.Written) >= c.End
+}
+
+// Download 下载 rawURL 保存到 outputPath。未完成的数据和下载状态保存在 outputPath.parts 目录中，
+// 再次下载同一文件（大小、ETag、Last-Modified 未变化）时从中断的位置继续
+func (d *Downloader) Download(ctx context.Context, rawURL, outputPath string) (string, error) {
+	var r *remote
+	err := d.retry(ctx, func() error {
+		var err error
+		r, err = d.probe(ctx, rawURL)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	partsDir := outputPath + ".parts"
+	st, err := d.prepare(partsDir, r)
+	if err != nil {
+		return "", err
+	}
+	dataPath := filepath.Join(partsDir, "data")
+	flag := os.O_RDWR | os.O_CREATE
+	if !r.Ranges {
+		flag |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(dataPath, flag, 0644)
+	if err != nil {
+		return "", err
+	}
+	if r.Size >= 0 {
+		if err := f.Truncate(r.Size); err != nil {
+			f.Close()
+			return "", err
+		}
+	}
+
+	err = d.downloadChunks(ctx, rawURL, f, st, filepath.Join(partsDir, "state.json"))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(dataPath, outputPath); err != nil {
+		return "", err
+	}
+	os.RemoveAll(partsDir)
+	return outputPath, nil
+}
+
+// probe 用 bytes=0-0 的 Range 请求获取文件大小和是否支持续传
+func (d *Downloader) probe(ctx context.Context, rawURL string) (*remote, error) {
+	resp, err := d.get(ctx, rawURL, "bytes=0-0")
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	r := &remote{Size: -1, ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		// Content-Range: bytes 0-0/12345，总大小为 * 时按不支持处理
+		if _, total, ok := strings.Cut(resp.Header.Get("Content-Range"), "/"); ok {
+			if size, err := strconv.ParseInt(total, 10, 64); err == nil && size > 0 {
+				r.Size, r.Ranges = size, true
+			}
+		}
+	case http.StatusOK:
+		if resp.ContentLength >= 0 {
+			r.Size = resp.ContentLength
+		}
+	default:
+		return nil, &hls.StatusError{StatusCode: resp.StatusCode}
+	}
+	if ct := resp.Header.Get("Content-Type"); strings.HasPrefix(ct, "text/") {
+		return nil, fmt.Errorf("链接返回的不是视频文件（%s）", ct)
+	}
+	return r, nil
+}
+
+// prepare 读取上次的下载状态，与服务器上的文件一致时继续使用，否则清空 partsDir 重新分块
+func (d *Downloader) prepare(partsDir string, r *remote) (*state, error) {
+	statePath := filepath.Join(partsDir, "state.json")
+	if r.Ranges {
+		var st state
+		if data, err := os.ReadFile(statePath); err == nil && json.Unmarshal(data, &st) == nil &&
+			st.Ranges && st.Size == r.Size && st.ETag == r.ETag && st.LastModified == r.LastModified && len(st.Chunks) > 0 {
+			d.opts.Logger.Info("继续上次未完成的下载", "downloaded", st.written(), "size", st.Size)
+			return &st, nil
+		}
+	}
+	if err := os.RemoveAll(partsDir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(partsDir, 0755); err != nil {
+		return nil, err
+	}
+
+	st := &state{Size: r.Size, Ranges: r.Ranges, ETag: r.ETag, LastModified: r.LastModified}
+	if !r.Ranges {
+		// 不支持续传时只能单连接从头下载
+		st.Chunks = []chunk{{Start: 0, End: r.Size}}
+		return st, nil
+	}
+	for start := int64(0); start < r.Size; start += ChunkSize {
+		st.Chunks = append(st.Chunks, chunk{Start: start, End: min(start+ChunkSize, r.Size)})
+	}
+	return st, st.save(statePath)
+}
+
+func (st *state) written() int64 {
+	var n int64
+	for i := range st.Chunks {
+		n += atomic.LoadInt64(&st.Chunks[i].Written)
+	}
+	return n
+}
+
+// save 先写临时文件再改名，避免中断时留下不完整的状态。下载过程中各块的 Written 仍在变化，先复制一份
+func (st *state) save(path string) error {
+	snapshot := *st
+	snapshot.Chunks = make([]chunk, len(st.Chunks))
+	for i := range st.Chunks {
+		snapshot.Chunks[i] = chunk{Start: st.Chunks[i].Start, End: st.Chunks[i].End, Written: atomic.LoadInt64(&st.Chunks[i].Written)}
+	}
+	data, err := json.Marshal(&snapshot)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (d *Downloader) downloadChunks(ctx context.Context, rawURL string, f *os.File, st *state, statePath string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		firstErr   error
+		errOnce    sync.Once
+		wg         sync.WaitGroup
+		startTime  = time.Now()
+		resumed    = st.written()
+		jobs       = make(chan *chunk)
+		reportDone = make(chan struct{})
+		// saveMu 保证状态文件只由一个 goroutine 写入
+		saveMu sync.Mutex
+	)
+	save := func() {
+		if !st.Ranges {
+			return
+		}
+		saveMu.Lock()
+		defer saveMu.Unlock()
+		if err := st.save(statePath); err != nil {
+			d.opts.Logger.Warn("保存下载状态失败", "error", err)
+		}
+	}
+	report := func() {
+		if d.opts.OnProgress == nil {
+			return
+		}
+		d.opts.OnProgress(Progress{
+			BytesDownloaded: st.written(),
+			TotalBytes:      max(st.Size, 0),
+			Resumed:         resumed,
+			Elapsed:         time.Since(startTime),
+		})
+	}
+
+	go func() {
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				report()
+				save()
+			case <-reportDone:
+				return
+			}
+		}
+	}()
+
+	concurrency := max(1, min(d.opts.Concurrency, len(st.Chunks)))
+	d.opts.Logger.Debug("开始下载", "size", st.Size, "chunks", len(st.Chunks), "concurrency", concurrency, "resumed", resumed)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range jobs {
+				if err := d.downloadChunk(ctx, rawURL, f, c, st.Ranges); err != nil {
+					errOnce.Do(func() {
+						firstErr = fmt.Errorf("下载 %d-%d 字节失败: %w", c.Start, c.End, err)
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range st.Chunks {
+		if st.Chunks[i].done() {
+			continue
+		}
+		select {
+		case jobs <- &st.Chunks[i]:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(reportDone)
+	// 暂停、取消或失败时保留已写入的部分，下次从这里继续
+	save()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	report()
+	return nil
+}
+
+// downloadChunk 下载一块写入 f 的对应位置。ranges 为 true 时暂时的错误重试时从已写入的位置继续，
+// 否则从头重新下载
+func (d *Downloader) downloadChunk(ctx context.Context, rawURL string, f *os.File, c *chunk, ranges bool) error {
+	return d.retry(ctx, func() error {
+		offset := c.Start + atomic.LoadInt64(&c.Written)
+		rangeHeader := ""
+		if ranges {
+			if c.done() {
+				return nil
+			}
+			rangeHeader = fmt.Sprintf("bytes=%d-%d", offset, c.End-1)
+		} else {
+			atomic.StoreInt64(&c.Written, 0)
+			offset = c.Start
+		}
+		resp, err := d.get(ctx, rawURL, rangeHeader)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		switch {
+		case rangeHeader != "" && resp.StatusCode == http.StatusPartialContent:
+			// 返回的范围与请求的不同时写入的位置会错开
+			if start, ok := rangeStart(resp.Header.Get("Content-Range")); !ok || start != offset {
+				return fmt.Errorf("服务器返回的范围与请求的不一致: %s", resp.Header.Get("Content-Range"))
+			}
+		case resp.StatusCode == http.StatusOK && offset == 0:
+			// 服务器忽略了 Range，从头返回完整文件
+		case resp.StatusCode == http.StatusOK:
+			return fmt.Errorf("服务器不再支持 Range 请求")
+		default:
+			return &hls.StatusError{StatusCode: resp.StatusCode}
+		}
+
+		w := &chunkWriter{w: io.NewOffsetWriter(f, offset), c: c}
+		var body io.Reader = ratelimit.Reader(ctx, resp.Body, d.opts.Limiter)
+		if c.End >= 0 {
+			body = io.LimitReader(body, c.End-offset)
+		}
+		if _, err := io.Copy(w, body); err != nil {
+			return err
+		}
+		if c.End >= 0 && !c.done() {
+			return io.ErrUnexpectedEOF
+		}
+		return nil
+	})
+}
+
+// rangeStart 返回 Content-Range（bytes 100-199/1000）的起始位置
+func rangeStart(contentRange string) (int64, bool) {
+	spec, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return 0, false
+	}
+	first, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, false
+	}
+	start, err := strconv.ParseInt(strings.TrimSpace(first), 10, 64)
+	return start, err == nil
+}
+
+// chunkWriter 写入文件后累加块的已写入字节数，中断时已写入的部分保留
+type chunkWriter struct {
+	w io.Writer
+	c *chunk
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	atomic.AddInt64(&w.c.Written, int64(n))
+	return n, err
+}
+
+// get 发起 GET 请求，rangeHeader 不为空时附加 Range 头。调用方负责关闭响应体
+func (d *Downloader) get(ctx context.Context, rawURL, rangeHeader string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, values := range d.opts.Headers {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+		// 压缩后的响应无法按字节续传
+		req.Header.Set("Accept-Encoding", "identity")
+	}
+	return d.opts.Client.Do(req)
+}
+
+// retry 执行 fn，暂时的错误按指数退避（加随机抖动）重试
+func (d *Downloader) retry(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 0; attempt <= d.opts.Retries; attempt++ {
+		if attempt > 0 {
+			if err := backoff.Sleep(ctx, backoff.Delay(attempt, retryBaseDelay, retryMaxDelay)); err != nil {
+				return err
+			}
+		}
+		if err = fn(); err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !hls.Temporary(err) {
+			return err
+		}
+		d.opts.Logger.Debug("请求失败，准备重试", "attempt", attempt+1, "error", err)
+	}
+	return err
+}
@@ -0,0 +1,227 @@
+package httpdl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testData 一块半，按块下载时有两个 Range 请求
+var testData = func() []byte {
+	data := make([]byte, ChunkSize+2<<20)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	return data
+}()
+
+// serveRange 按 Range 请求返回 testData 的一部分（206），etag 为 ETag 响应头
+func serveRange(etag string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "video/mp4")
+		http.ServeContent(w, r, "video.mp4", time.Time{}, bytes.NewReader(testData))
+	}
+}
+
+// serveFull 忽略 Range，总是返回完整的文件（200）
+func serveFull(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "video/mp4")
+	w.Write(testData)
+}
+
+func TestDownload(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		// wantRanges 除探测外的请求是否带 Range 头
+		wantRanges bool
+		wantErr    string
+	}{
+		{name: "支持 Range 时按块下载", handler: serveRange(`"v1"`), wantRanges: true},
+		{name: "忽略 Range 时单连接下载", handler: serveFull},
+		{
+			name: "大小未知",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "video/mp4")
+				// 先 Flush，响应使用分块编码，没有 Content-Length
+				w.(http.Flusher).Flush()
+				w.Write(testData)
+			},
+		},
+		{
+			name: "只有探测时返回 206，之后的 Range 请求返回 200",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Range") == "bytes=0-0" {
+					serveRange(`"v1"`)(w, r)
+					return
+				}
+				serveFull(w, r)
+			},
+			wantErr: "不再支持 Range",
+		},
+		{
+			name: "返回的范围与请求的不同",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(testData)-1, len(testData)))
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write(testData)
+			},
+			wantErr: "范围与请求的不一致",
+		},
+		{
+			name: "返回网页",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				w.Write([]byte("<html></html>"))
+			},
+			wantErr: "不是视频文件",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu     sync.Mutex
+				ranges []string
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				ranges = append(ranges, r.Header.Get("Range"))
+				mu.Unlock()
+				tt.handler(w, r)
+			}))
+			defer srv.Close()
+
+			out := filepath.Join(t.TempDir(), "video.mp4")
+			_, err := New(Options{Retries: -1, Concurrency: 2}).Download(context.Background(), srv.URL+"/video.mp4", out)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("错误 = %v，应包含 %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := os.ReadFile(out); !bytes.Equal(got, testData) {
+				t.Fatalf("文件内容不同：%d 字节，应为 %d 字节", len(got), len(testData))
+			}
+			if _, err := os.Stat(out + ".parts"); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("下载完成后 .parts 目录仍存在: %v", err)
+			}
+			// 第一个请求为探测
+			want := []string{""}
+			if tt.wantRanges {
+				want = []string{fmt.Sprintf("bytes=0-%d", ChunkSize-1), fmt.Sprintf("bytes=%d-%d", ChunkSize, len(testData)-1)}
+			}
+			mu.Lock()
+			got := slices.Clone(ranges[1:])
+			mu.Unlock()
+			// 并行下载时请求的顺序不固定
+			slices.Sort(got)
+			if !slices.Equal(got, want) {
+				t.Errorf("Range 请求 = %q，应为 %q", got, want)
+			}
+		})
+	}
+}
+
+func TestDownloadResume(t *testing.T) {
+	tests := []struct {
+		name string
+		// etag 第二次下载时服务器返回的 ETag
+		etag string
+		// wantResume 第二次下载是否从上次写入的位置继续
+		wantResume bool
+	}{
+		{"文件没有变化时续传", `"v1"`, true},
+		{"文件变化后重新下载", `"v2"`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const cut = 1 << 20
+			var (
+				mu       sync.Mutex
+				etag     = `"v1"`
+				fail     = true
+				requests []string
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				tag, broken := etag, fail && strings.HasPrefix(r.Header.Get("Range"), fmt.Sprintf("bytes=%d-", ChunkSize))
+				requests = append(requests, r.Header.Get("Range"))
+				mu.Unlock()
+				if broken {
+					// 第二块只返回 cut 字节后断开连接
+					w.Header().Set("ETag", tag)
+					w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", ChunkSize, len(testData)-1, len(testData)))
+					w.Header().Set("Content-Length", fmt.Sprint(len(testData)-ChunkSize))
+					w.WriteHeader(http.StatusPartialContent)
+					w.Write(testData[ChunkSize : ChunkSize+cut])
+					w.(http.Flusher).Flush()
+					panic(http.ErrAbortHandler)
+				}
+				serveRange(tag)(w, r)
+			}))
+			defer srv.Close()
+
+			out := filepath.Join(t.TempDir(), "video.mp4")
+			d := New(Options{Retries: -1, Concurrency: 1})
+			if _, err := d.Download(context.Background(), srv.URL+"/video.mp4", out); err == nil {
+				t.Fatal("连接断开时应返回错误")
+			}
+			if _, err := os.Stat(filepath.Join(out+".parts", "state.json")); err != nil {
+				t.Fatalf("中断后没有保留下载状态: %v", err)
+			}
+
+			mu.Lock()
+			etag, fail, requests = tt.etag, false, nil
+			mu.Unlock()
+			if _, err := d.Download(context.Background(), srv.URL+"/video.mp4", out); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := os.ReadFile(out); !bytes.Equal(got, testData) {
+				t.Fatal("续传后的文件内容不同")
+			}
+
+			want := []string{"bytes=0-0", fmt.Sprintf("bytes=%d-%d", ChunkSize+cut, len(testData)-1)}
+			if !tt.wantResume {
+				want = []string{"bytes=0-0", fmt.Sprintf("bytes=0-%d", ChunkSize-1), fmt.Sprintf("bytes=%d-%d", ChunkSize, len(testData)-1)}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if !slices.Equal(requests, want) {
+				t.Errorf("第二次下载的请求 = %q，应为 %q", requests, want)
+			}
+		})
+	}
+}
+
+func TestRangeStart(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   int64
+		wantOK bool
+	}{
+		{"bytes 100-199/1000", 100, true},
+		{"bytes 0-0/*", 0, true},
+		{"bytes */1000", 0, false},
+		{"items 0-1/2", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := rangeStart(tt.in); got != tt.want || ok != tt.wantOK {
+			t.Errorf("rangeStart(%q) = %d, %v，应为 %d, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+// Package jobqueue 实现 tasks.JobQueue：下载和转录任务保存在 Redis 中排队，
+// 共用同一个 Redis 的多个进程按各自的并发上限领取执行（与 asynq 的做法相同）。
+//
+// 每类任务（download / transcribe）使用四个有序集合：
+//   - pending 等待执行，分数按优先级和加入队列的时间排列
+//   - delayed 失败后等待重试，分数为可以重新执行的时间
+//   - active 已被领取，分数为租约到期的时间。执行任务的进程定期续租，进程退出或卡住后租约过期，任务重新投递
+//   - dead 死信队列：不能重试的失败或重试次数用完，分数为转入的时间。重试任务（retry 接口）时移出
+//
+// 任务的优先级分数、执行次数和最后的错误保存在 <prefix>job:<任务 ID> 哈希中
+package jobqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"zhihu-downloader/internal/backoff"
+	"zhihu-downloader/internal/tasks"
+)
+
+// 排队方式
+const (
+	// BackendMemory 每个进程在内存中排队（默认）
+	BackendMemory = "memory"
+	// BackendRedis 所有进程共用 Redis 中的队列
+	BackendRedis = "redis"
+)
+
+// Backends 支持的排队方式
+var Backends = []string{BackendMemory, BackendRedis}
+
+const (
+	// DefaultPrefix 默认的键名前缀
+	DefaultPrefix = "zhihu:queue:"
+	// DefaultVisibility 默认的租约时长
+	DefaultVisibility = time.Minute
+	// MinVisibility 租约时长的下限，执行任务的进程每 10s 续租一次
+	MinVisibility = 30 * time.Second
+	// DefaultMaxRetries 失败后默认重新执行的次数
+	DefaultMaxRetries = 3
+)
+
+// 失败的任务重新执行前等待 30s、1m、2m……（加随机抖动），最多 30 分钟
+const (
+	retryBase = 30 * time.Second
+	retryMax  = 30 * time.Minute
+)
+
+// opTimeout 单次 Redis 操作的最长时间
+const opTimeout = 5 * time.Second
+
+// Options 任务队列的设置
+type Options struct {
+	// Backend 排队方式 memory / redis，为空时为 memory
+	Backend string
+	// URL Redis 地址，例如 redis://:password@localhost:6379/0
+	URL string
+	// Prefix 键名前缀，默认 DefaultPrefix
+	Prefix string
+	// Visibility 租约时长（visibility timeout），默认 DefaultVisibility，不小于 MinVisibility
+	Visibility time.Duration
+	// MaxRetries 失败后重新执行的次数，用完后转入死信队列
+	MaxRetries int
+}
+
+// Open 按设置连接任务队列，memory 返回 nil（Manager 在进程内排队）
+func Open(o Options) (tasks.JobQueue, error) {
+	switch o.Backend {
+	case "", BackendMemory:
+		return nil, nil
+	case BackendRedis:
+	default:
+		return nil, fmt.Errorf("不支持的排队方式: %s", o.Backend)
+	}
+	if o.URL == "" {
+		return nil, fmt.Errorf("使用 Redis 队列时必须配置 queue.redis_url")
+	}
+	opts, err := redis.ParseURL(o.URL)
+	if err != nil {
+		return nil, fmt.Errorf("Redis 地址无效: %v", err)
+	}
+	q := &Redis{
+		client:     redis.NewClient(opts),
+		prefix:     o.Prefix,
+		visibility: max(o.Visibility, MinVisibility),
+		maxRetries: max(o.MaxRetries, 0),
+	}
+	if q.prefix == "" {
+		q.prefix = DefaultPrefix
+	}
+	if o.Visibility == 0 {
+		q.visibility = DefaultVisibility
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	if err := q.client.Ping(ctx).Err(); err != nil {
+		q.client.Close()
+		return nil, fmt.Errorf("连接 Redis 失败: %v", err)
+	}
+	return q, nil
+}
+
+// Redis 保存在 Redis 中的任务队列
+type Redis struct {
+	client     *redis.Client
+	prefix     string
+	visibility time.Duration
+	maxRetries int
+}
+
+func (q *Redis) key(kind tasks.Kind, set string) string {
+	return q.prefix + string(kind) + ":" + set
+}
+
+func (q *Redis) jobKey(id string) string {
+	return q.prefix + "job:" + id
+}
+
+// score 排队的分数：优先级高的在前，同一优先级按加入队列的先后
+func score(p tasks.Priority, now time.Time) float64 {
+	rank := 1
+	switch p {
+	case tasks.PriorityHigh:
+		rank = 0
+	case tasks.PriorityLow:
+		rank = 2
+	}
+	// 毫秒时间戳小于 1e13，分数不超过 3e13，可以用 float64 精确表示
+	return float64(rank)*1e13 + float64(now.UnixMilli())
+}
+
+// Push 加入队列。任务已经在队列中（包括死信队列）时重新排队，执行次数从头计算
+func (q *Redis) Push(kind tasks.Kind, id string, p tasks.Priority) error {
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	s := score(p, time.Now())
+	_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, q.key(kind, "delayed"), id)
+		pipe.ZRem(ctx, q.key(kind, "active"), id)
+		pipe.ZRem(ctx, q.key(kind, "dead"), id)
+		pipe.Del(ctx, q.jobKey(id))
+		pipe.HSet(ctx, q.jobKey(id), "score", strconv.FormatFloat(s, 'f', -1, 64), "attempts", 0)
+		pipe.ZAdd(ctx, q.key(kind, "pending"), redis.Z{Score: s, Member: id})
+		return nil
+	})
+	return err
+}
+
+// popScript 把到期的重试和租约过期的任务放回 pending，取出分数最小的任务放入 active 并增加执行次数。
+// KEYS: pending、delayed、active；ARGV: 当前时间、租约到期时间（毫秒）、任务哈希的键名前缀
+var popScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+for _, set in ipairs({KEYS[2], KEYS[3]}) do
+	for _, id in ipairs(redis.call('ZRANGEBYSCORE', set, '-inf', now)) do
+		redis.call('ZREM', set, id)
+		local score = redis.call('HGET', ARGV[3] .. id, 'score')
+		if score then
+			redis.call('ZADD', KEYS[1], score, id)
+		end
+	end
+end
+local popped = redis.call('ZPOPMIN', KEYS[1])
+if #popped == 0 then
+	return false
+end
+local id = popped[1]
+redis.call('ZADD', KEYS[3], ARGV[2], id)
+return {id, redis.call('HINCRBY', ARGV[3] .. id, 'attempts', 1)}
+`)
+
+// Pop 领取一个任务，没有可以执行的任务时返回 nil。
+// 租约过期后重新投递的任务执行次数超过上限时直接转入死信队列，Exhausted 为 true
+func (q *Redis) Pop(kind tasks.Kind) (*tasks.Job, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	now := time.Now()
+	res, err := popScript.Run(ctx, q.client,
+		[]string{q.key(kind, "pending"), q.key(kind, "delayed"), q.key(kind, "active")},
+		now.UnixMilli(), now.Add(q.visibility).UnixMilli(), q.prefix+"job:").Slice()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(res) != 2 {
+		return nil, fmt.Errorf("领取任务的结果无效: %v", res)
+	}
+	id, _ := res[0].(string)
+	attempts, _ := res[1].(int64)
+	job := &tasks.Job{ID: id, Attempt: int(attempts)}
+	if job.Attempt > q.maxRetries+1 {
+		job.Exhausted = true
+		return job, q.bury(ctx, kind, id, "多次领取后没有完成，执行任务的进程可能已退出")
+	}
+	return job, nil
+}
+
+// Extend 续租正在执行的任务，任务已不在 active 中时返回错误
+func (q *Redis) Extend(kind tasks.Kind, id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	deadline := float64(time.Now().Add(q.visibility).UnixMilli())
+	n, err := q.client.ZAddArgs(ctx, q.key(kind, "active"), redis.ZAddArgs{
+		XX:      true,
+		Ch:      true,
+		Members: []redis.Z{{Score: deadline, Member: id}},
+	}).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("任务已不在执行队列中")
+	}
+	return nil
+}
+
+// Fail 处理执行失败的任务：retry 为 true 且重试次数没有用完时按指数退避安排重新执行，返回等待时间；
+// 否则转入死信队列，返回 0。任务已经移出队列时什么也不做
+func (q *Redis) Fail(kind tasks.Kind, id, reason string, retry bool) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	attempts, err := q.client.HGet(ctx, q.jobKey(id), "attempts").Int()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if !retry || attempts > q.maxRetries {
+		return 0, q.bury(ctx, kind, id, reason)
+	}
+
+	delay := backoff.Delay(attempts, retryBase, retryMax)
+	_, err = q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, q.key(kind, "active"), id)
+		pipe.ZAdd(ctx, q.key(kind, "delayed"), redis.Z{Score: float64(time.Now().Add(delay).UnixMilli()), Member: id})
+		pipe.HSet(ctx, q.jobKey(id), "error", reason)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return delay, nil
+}
+
+// bury 把任务转入死信队列，保留执行次数和最后的错误
+func (q *Redis) bury(ctx context.Context, kind tasks.Kind, id, reason string) error {
+	_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, q.key(kind, "pending"), id)
+		pipe.ZRem(ctx, q.key(kind, "delayed"), id)
+		pipe.ZRem(ctx, q.key(kind, "active"), id)
+		pipe.ZAdd(ctx, q.key(kind, "dead"), redis.Z{Score: float64(time.Now().UnixMilli()), Member: id})
+		pipe.HSet(ctx, q.jobKey(id), "error", reason, "failed_at", time.Now().Unix())
+		return nil
+	})
+	return err
+}
+
+// Remove 把完成、取消、暂停或删除的任务移出队列（包括死信队列）
+func (q *Redis) Remove(kind tasks.Kind, id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, set := range []string{"pending", "delayed", "active", "dead"} {
+			pipe.ZRem(ctx, q.key(kind, set), id)
+		}
+		pipe.Del(ctx, q.jobKey(id))
+		return nil
+	})
+	return err
+}
+
+// reprioritizeScript 修改仍在 pending 中的任务的分数。KEYS: pending、任务哈希；ARGV: 新的分数、任务 ID
+var reprioritizeScript = redis.NewScript(`
+if redis.call('ZSCORE', KEYS[1], ARGV[2]) then
+	redis.call('ZADD', KEYS[1], ARGV[1], ARGV[2])
+end
+if redis.call('EXISTS', KEYS[2]) == 1 then
+	redis.call('HSET', KEYS[2], 'score', ARGV[1])
+end
+return 0
+`)
+
+// SetPriority 修改排队中的任务的优先级，保持原来加入队列的先后
+func (q *Redis) SetPriority(kind tasks.Kind, id string, p tasks.Priority) error {
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	old, err := q.client.HGet(ctx, q.jobKey(id), "score").Float64()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	enqueued := time.UnixMilli(int64(old) % 1e13)
+	s := strconv.FormatFloat(score(p, enqueued), 'f', -1, 64)
+	return reprioritizeScript.Run(ctx, q.client, []string{q.key(kind, "pending"), q.jobKey(id)}, s, id).Err()
+}
+
+// Stats 返回一类任务在各个集合中的数量
+func (q *Redis) Stats(kind tasks.Kind) (tasks.JobStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	var pending, delayed, active, dead *redis.IntCmd
+	_, err := q.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pending = pipe.ZCard(ctx, q.key(kind, "pending"))
+		delayed = pipe.ZCard(ctx, q.key(kind, "delayed"))
+		active = pipe.ZCard(ctx, q.key(kind, "active"))
+		dead = pipe.ZCard(ctx, q.key(kind, "dead"))
+		return nil
+	})
+	if err != nil {
+		return tasks.JobStats{}, err
+	}
+	return tasks.JobStats{
+		Queued:   int(pending.Val()),
+		Retrying: int(delayed.Val()),
+		Active:   int(active.Val()),
+		Dead:     int(dead.Val()),
+	}, nil
+}
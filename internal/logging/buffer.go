@@ -0,0 +1,102 @@
+package logging
+
+import "sync"
+
+const (
+	// DefaultTaskLines 每个任务默认保留的日志行数
+	DefaultTaskLines = 200
+	// maxTasks 最多保留多少个任务的日志，超出时丢弃最早的任务
+	maxTasks = 1000
+)
+
+// tasks 所有任务的日志缓冲区
+var tasks = newTaskBuffers(DefaultTaskLines)
+
+// taskBuffers 按任务保存最近的日志行
+type taskBuffers struct {
+	mu      sync.Mutex
+	limit   int
+	buffers map[string]*ring
+	// order 任务第一次写日志的顺序，用于淘汰最早的任务
+	order []string
+}
+
+func newTaskBuffers(limit int) *taskBuffers {
+	return &taskBuffers{limit: limit, buffers: make(map[string]*ring)}
+}
+
+func (b *taskBuffers) setLimit(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.limit = n
+}
+
+func (b *taskBuffers) add(line Line) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	r, ok := b.buffers[line.TaskID]
+	if !ok {
+		if len(b.order) >= maxTasks {
+			delete(b.buffers, b.order[0])
+			b.order = b.order[1:]
+		}
+		r = &ring{lines: make([]Line, 0, min(b.limit, 16)), limit: b.limit}
+		b.buffers[line.TaskID] = r
+		b.order = append(b.order, line.TaskID)
+	}
+	r.add(line)
+}
+
+func (b *taskBuffers) lines(taskID string, n int) []Line {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r, ok := b.buffers[taskID]
+	if !ok {
+		return []Line{}
+	}
+	return r.last(n)
+}
+
+func (b *taskBuffers) forget(taskID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.buffers[taskID]; !ok {
+		return
+	}
+	delete(b.buffers, taskID)
+	for i, id := range b.order {
+		if id == taskID {
+			b.order = append(b.order[:i], b.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// ring 固定容量的环形缓冲区
+type ring struct {
+	lines []Line
+	limit int
+	// next 缓冲区写满后下一次覆盖的位置
+	next int
+}
+
+func (r *ring) add(line Line) {
+	if len(r.lines) < r.limit {
+		r.lines = append(r.lines, line)
+		return
+	}
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % r.limit
+}
+
+// last 按时间顺序返回最后 n 行
+func (r *ring) last(n int) []Line {
+	ordered := make([]Line, 0, len(r.lines))
+	ordered = append(ordered, r.lines[r.next:]...)
+	ordered = append(ordered, r.lines[:r.next]...)
+	if n > 0 && n < len(ordered) {
+		ordered = ordered[len(ordered)-n:]
+	}
+	return ordered
+}
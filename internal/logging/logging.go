@@ -0,0 +1,257 @@
+// Package logging 配置三个服务共用的结构化日志（log/slog）。
+// 带 task_id 的日志除了输出到 stderr，还会按任务保存最近的若干行，
+// 包括 ffmpeg / Whisper / yt-dlp 等外部程序的输出，便于排查失败的任务。
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 日志属性名
+const (
+	KeyTaskID = "task_id"
+	KeyStage  = "stage"
+	// KeySource 外部程序名，例如 ffmpeg、whisper
+	KeySource = "source"
+)
+
+// Config 日志配置
+type Config struct {
+	// Level debug / info / warn / error，默认 info
+	Level string
+	// Format text 或 json，默认 text
+	Format string
+	// TaskLines 每个任务保留的日志行数，默认 200
+	TaskLines int
+}
+
+// ParseLevel 解析日志级别
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(strings.ToLower(s))); err != nil {
+		return 0, fmt.Errorf("不支持的日志级别: %s（可选 debug / info / warn / error）", s)
+	}
+	return level, nil
+}
+
+// Validate 检查日志级别和格式
+func (c Config) Validate() error {
+	if _, err := ParseLevel(c.Level); err != nil {
+		return err
+	}
+	switch strings.ToLower(c.Format) {
+	case "", "text", "json":
+		return nil
+	}
+	return fmt.Errorf("不支持的日志格式: %s（可选 text / json）", c.Format)
+}
+
+// Setup 设置默认的 slog 日志，输出到 stderr（stdio MCP 服务的 stdout 用于 JSON-RPC）
+func Setup(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	level, _ := ParseLevel(cfg.Level)
+	if cfg.TaskLines > 0 {
+		tasks.setLimit(cfg.TaskLines)
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var next slog.Handler = slog.NewTextHandler(os.Stderr, opts)
+	if strings.ToLower(cfg.Format) == "json" {
+		next = slog.NewJSONHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(&handler{next: next, buffers: tasks}))
+	return nil
+}
+
+type ctxKey struct{}
+
+// taskInfo 保存在 context 中的任务信息
+type taskInfo struct {
+	id    string
+	stage string
+}
+
+// WithTask 返回带任务 ID 和阶段的 context，之后 FromContext 得到的日志都会带上这两个属性
+func WithTask(ctx context.Context, id, stage string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, taskInfo{id: id, stage: stage})
+}
+
+// WithStage 修改 context 中的任务阶段，没有任务时原样返回
+func WithStage(ctx context.Context, stage string) context.Context {
+	info, ok := ctx.Value(ctxKey{}).(taskInfo)
+	if !ok {
+		return ctx
+	}
+	info.stage = stage
+	return context.WithValue(ctx, ctxKey{}, info)
+}
+
+// FromContext 返回带有 context 中任务信息的日志
+func FromContext(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+	if info, ok := ctx.Value(ctxKey{}).(taskInfo); ok {
+		logger = logger.With(KeyTaskID, info.id, KeyStage, info.stage)
+	}
+	return logger
+}
+
+// Output 记录外部程序输出的一行（debug 级别），总会保存到任务日志中，
+// 同时追加到任务的完整输出（见 TakeOutput）
+func Output(ctx context.Context, source, line string) {
+	line = strings.TrimRight(line, "\r\n ")
+	if line == "" {
+		return
+	}
+	if info, ok := ctx.Value(ctxKey{}).(taskInfo); ok {
+		outputs.add(info.id, source, line)
+	}
+	FromContext(ctx).Debug(line, KeySource, source)
+}
+
+// Lines 返回任务最近的 n 行日志（n <= 0 时返回全部保留的日志）
+func Lines(taskID string, n int) []Line {
+	return tasks.lines(taskID, n)
+}
+
+// Forget 删除任务的日志和没有取走的输出
+func Forget(taskID string) {
+	tasks.forget(taskID)
+	outputs.mu.Lock()
+	outputs.forgetLocked(taskID)
+	outputs.mu.Unlock()
+}
+
+// Line 一行任务日志
+type Line struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	TaskID  string    `json:"task_id"`
+	Stage   string    `json:"stage,omitempty"`
+	Source  string    `json:"source,omitempty"`
+	Message string    `json:"message"`
+	// Attrs 其余属性，例如 error、file_path
+	Attrs map[string]string `json:"attrs,omitempty"`
+}
+
+// handler 把日志交给 next 输出，同时把带 task_id 的日志保存到任务缓冲区。
+// 低于输出级别的任务日志（例如外部程序的输出）只保存不输出
+type handler struct {
+	next    slog.Handler
+	buffers *taskBuffers
+	// 通过 With 绑定的属性
+	attrs []slog.Attr
+	group string
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.taskID() != "" || h.next.Enabled(ctx, level)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	line := Line{Time: r.Time, Level: r.Level.String(), Message: r.Message}
+	collect := func(a slog.Attr) bool {
+		switch a.Key {
+		case KeyTaskID:
+			line.TaskID = a.Value.String()
+		case KeyStage:
+			line.Stage = a.Value.String()
+		case KeySource:
+			line.Source = a.Value.String()
+		default:
+			if line.Attrs == nil {
+				line.Attrs = map[string]string{}
+			}
+			line.Attrs[a.Key] = a.Value.String()
+		}
+		return true
+	}
+	for _, a := range h.attrs {
+		collect(a)
+	}
+	r.Attrs(collect)
+	if line.TaskID != "" {
+		h.buffers.add(line)
+	}
+
+	if !h.next.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.next = h.next.WithAttrs(attrs)
+	if h.group == "" {
+		clone.attrs = append(append([]slog.Attr{}, h.attrs...), attrs...)
+	}
+	return &clone
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.next = h.next.WithGroup(name)
+	clone.group = name
+	return &clone
+}
+
+func (h *handler) taskID() string {
+	for _, a := range h.attrs {
+		if a.Key == KeyTaskID {
+			return a.Value.String()
+		}
+	}
+	return ""
+}
+
+// LineWriter 把写入的内容按行记录为外部程序输出，可以直接作为 exec.Cmd 的 Stderr
+type LineWriter struct {
+	ctx    context.Context
+	source string
+	mu     sync.Mutex
+	buf    []byte
+}
+
+// Writer 返回记录 source 输出的 LineWriter，命令结束后调用 Close 写出最后不完整的一行
+func Writer(ctx context.Context, source string) *LineWriter {
+	return &LineWriter{ctx: ctx, source: source}
+}
+
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		// ffmpeg 的状态行以 \r 结尾
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		Output(w.ctx, w.source, string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Close 写出缓冲区中剩余的内容
+func (w *LineWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		Output(w.ctx, w.source, string(w.buf))
+		w.buf = nil
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"bytes"
+	"sync"
+)
+
+// MaxTaskOutput 每个任务保留的外部程序输出上限（字节），超出时丢弃最早的内容
+const MaxTaskOutput = 1 << 20
+
+// outputs 执行中任务的外部程序完整输出，任务结束后由 TakeOutput 取走
+var outputs = &taskOutputs{buffers: make(map[string]*outputBuffer)}
+
+type taskOutputs struct {
+	mu      sync.Mutex
+	buffers map[string]*outputBuffer
+	// order 任务第一次输出的顺序，没有被取走的任务超过 maxTasks 个时丢弃最早的
+	order []string
+}
+
+type outputBuffer struct {
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (o *taskOutputs) add(taskID, source, line string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	b, ok := o.buffers[taskID]
+	if !ok {
+		if len(o.order) >= maxTasks {
+			delete(o.buffers, o.order[0])
+			o.order = o.order[1:]
+		}
+		b = &outputBuffer{}
+		o.buffers[taskID] = b
+		o.order = append(o.order, taskID)
+	}
+	b.buf.WriteString("[" + source + "] " + line + "\n")
+	// 超出上限两倍时再裁剪，避免每一行都复制整个缓冲区
+	if b.buf.Len() > 2*MaxTaskOutput {
+		b.trim()
+	}
+}
+
+// trim 只保留最后 MaxTaskOutput 字节，从完整的一行开始
+func (b *outputBuffer) trim() {
+	if b.buf.Len() <= MaxTaskOutput {
+		return
+	}
+	data := b.buf.Bytes()[b.buf.Len()-MaxTaskOutput:]
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		data = data[i+1:]
+	}
+	kept := append([]byte(nil), data...)
+	b.buf.Reset()
+	b.buf.Write(kept)
+	b.truncated = true
+}
+
+func (o *taskOutputs) take(taskID string) ([]byte, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	b, ok := o.buffers[taskID]
+	if !ok {
+		return nil, false
+	}
+	o.forgetLocked(taskID)
+	b.trim()
+	return b.buf.Bytes(), b.truncated
+}
+
+func (o *taskOutputs) forgetLocked(taskID string) {
+	delete(o.buffers, taskID)
+	for i, id := range o.order {
+		if id == taskID {
+			o.order = append(o.order[:i], o.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// TakeOutput 取走任务执行期间外部程序的完整输出（每行前面是程序名），truncated 表示超出
+// MaxTaskOutput 丢弃了最早的内容。取走后清空，没有输出时返回 nil
+func TakeOutput(taskID string) (output []byte, truncated bool) {
+	return outputs.take(taskID)
+}
@@ -0,0 +1,74 @@
+package mcptools
+
+import (
+	"context"
+
+	"zhihu-downloader/internal/media"
+	"zhihu-downloader/internal/tasks"
+)
+
+// idempotent 按 idempotency_key 参数创建任务：create 返回任务 ID 和结果。同一个键重复提交时（例如超时后重试）
+// 不再创建，返回第一次创建的任务 ID，replayed 为 true。键按调用方的工作区区分
+func (t *Tools) idempotent(ctx context.Context, args map[string]interface{}, kind tasks.Kind, create func() (string, interface{}, error)) (interface{}, error) {
+	key, _ := args["idempotency_key"].(string)
+	var result interface{}
+	id, replayed, err := t.manager.Idempotent(workspaceName(ctx), key, kind, func() (id string, err error) {
+		id, result, err = create()
+		return id, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if replayed {
+		return map[string]interface{}{
+			"task_id":   id,
+			"task_type": kind,
+			"replayed":  true,
+			"status":    "该幂等键已创建过任务，返回原来的任务，请使用 get_progress 查看进度",
+		}, nil
+	}
+	return result, nil
+}
+
+// optionalBool 读取可选的布尔参数，未提供时返回 nil（使用配置的默认值）
+func optionalBool(args map[string]interface{}, name string) *bool {
+	if v, ok := args[name].(bool); ok {
+		return &v
+	}
+	return nil
+}
+
+// transcodeOptions 读取 transcode、max_height、crf 参数
+func transcodeOptions(args map[string]interface{}) media.TranscodeOptions {
+	codec, _ := args["transcode"].(string)
+	maxHeight, _ := args["max_height"].(float64)
+	crf, _ := args["crf"].(float64)
+	return media.TranscodeOptions{Codec: codec, MaxHeight: int(maxHeight), CRF: int(crf)}
+}
+
+// stringMap 读取值为字符串的对象参数，忽略不是字符串的值
+func stringMap(args map[string]interface{}, name string) map[string]string {
+	items, _ := args[name].(map[string]interface{})
+	var m map[string]string
+	for key, item := range items {
+		if s, ok := item.(string); ok {
+			if m == nil {
+				m = make(map[string]string, len(items))
+			}
+			m[key] = s
+		}
+	}
+	return m
+}
+
+// stringList 读取字符串数组参数，忽略其中不是字符串的元素
+func stringList(args map[string]interface{}, name string) []string {
+	items, _ := args[name].([]interface{})
+	var list []string
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
+}
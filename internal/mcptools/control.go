@@ -0,0 +1,209 @@
+package mcptools
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"zhihu-downloader/internal/tasks"
+)
+
+func (t *Tools) getProgress(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	taskID, _ := args["task_id"].(string)
+	taskType, _ := args["task_type"].(string)
+
+	if taskID == "" || taskType == "" {
+		return nil, fmt.Errorf("task_id 和 task_type 必填")
+	}
+	if err := t.CheckAccess(ctx, taskID); err != nil {
+		return nil, err
+	}
+
+	switch tasks.Kind(taskType) {
+	case tasks.KindDownload:
+		return t.manager.Download(taskID)
+	case tasks.KindTranscribe:
+		return t.manager.Transcribe(taskID)
+	case tasks.KindPipeline:
+		return t.manager.Pipeline(taskID)
+	case tasks.KindCollection:
+		return t.manager.Collection(taskID)
+	case tasks.KindBatch:
+		return t.manager.TranscribeBatch(taskID)
+	}
+
+	return nil, fmt.Errorf("未知任务类型")
+}
+
+func (t *Tools) cancelTask(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	taskID, _ := args["task_id"].(string)
+	taskType, _ := args["task_type"].(string)
+	keepPartial, _ := args["keep_partial"].(bool)
+	if taskID == "" || taskType == "" {
+		return nil, fmt.Errorf("task_id 和 task_type 必填")
+	}
+	if err := t.CheckAccess(ctx, taskID); err != nil {
+		return nil, err
+	}
+
+	var status tasks.Status
+	switch taskType {
+	case "download":
+		t, err := t.manager.Download(taskID)
+		if err != nil {
+			return nil, fmt.Errorf("下载任务不存在: %s", taskID)
+		}
+		status = t.Status
+	case "transcribe":
+		t, err := t.manager.Transcribe(taskID)
+		if err != nil {
+			return nil, fmt.Errorf("转录任务不存在: %s", taskID)
+		}
+		status = t.Status
+	case "pipeline":
+		t, err := t.manager.Pipeline(taskID)
+		if err != nil {
+			return nil, fmt.Errorf("流水线任务不存在: %s", taskID)
+		}
+		status = t.Status
+	case "collection":
+		t, err := t.manager.Collection(taskID)
+		if err != nil {
+			return nil, fmt.Errorf("合集任务不存在: %s", taskID)
+		}
+		status = t.Status
+	default:
+		return nil, fmt.Errorf("task_type 只能是 download、transcribe、pipeline 或 collection")
+	}
+	if status.Terminal() {
+		return nil, fmt.Errorf("任务已结束（%s），无法取消", status)
+	}
+
+	var cancelled bool
+	if keepPartial {
+		cancelled = t.manager.Cancel(taskID)
+	} else {
+		cancelled = t.manager.CancelAndCleanup(taskID)
+	}
+	if !cancelled {
+		return nil, fmt.Errorf("任务已结束，无法取消")
+	}
+
+	task, err := t.FindTask(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	message := "任务已取消，未完成的文件会在任务停止后删除"
+	if keepPartial {
+		message = "任务已取消，已下载的分片已保留，可以用 retry_task 继续"
+	}
+	if ev, ok := t.manager.Event(taskID); ok && !ev.Status.Terminal() {
+		// 共用数据库的其他进程（例如网关）执行的任务
+		message = "任务由其他进程执行，已请求取消，稍后状态变为 cancelled；未完成的文件会保留"
+	}
+	return map[string]interface{}{
+		"message": message,
+		"task":    task,
+	}, nil
+}
+
+func (t *Tools) retryTask(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	taskID, _ := args["task_id"].(string)
+	if taskID == "" {
+		return nil, fmt.Errorf("task_id 必填")
+	}
+	if err := t.CheckAccess(ctx, taskID); err != nil {
+		return nil, err
+	}
+
+	if err := t.manager.Retry(taskID); err != nil {
+		return nil, err
+	}
+	return t.FindTask(ctx, taskID)
+}
+
+func (t *Tools) pauseTask(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	taskID, _ := args["task_id"].(string)
+	if taskID == "" {
+		return nil, fmt.Errorf("task_id 必填")
+	}
+	if err := t.CheckAccess(ctx, taskID); err != nil {
+		return nil, err
+	}
+
+	if err := t.manager.Pause(taskID); err != nil {
+		return nil, err
+	}
+	return t.FindTask(ctx, taskID)
+}
+
+func (t *Tools) resumeTask(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	taskID, _ := args["task_id"].(string)
+	if taskID == "" {
+		return nil, fmt.Errorf("task_id 必填")
+	}
+	if err := t.CheckAccess(ctx, taskID); err != nil {
+		return nil, err
+	}
+
+	if err := t.manager.Resume(taskID); err != nil {
+		return nil, err
+	}
+	return t.FindTask(ctx, taskID)
+}
+
+func (t *Tools) deleteTask(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	taskID, _ := args["task_id"].(string)
+	if taskID == "" {
+		return nil, fmt.Errorf("task_id 必填")
+	}
+	if err := t.CheckAccess(ctx, taskID); err != nil {
+		return nil, err
+	}
+	deleteFiles, _ := args["delete_files"].(bool)
+
+	if err := t.manager.Delete(taskID, deleteFiles); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"task_id":       taskID,
+		"deleted":       true,
+		"files_deleted": deleteFiles,
+	}, nil
+}
+
+func (t *Tools) listTasks(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	q, err := tasks.ParseQuery(func(name string) string {
+		switch v := args[name].(type) {
+		case string:
+			return v
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return ""
+	})
+	if err != nil {
+		return nil, err
+	}
+	if restricted(ctx) {
+		q.Workspace = workspaceName(ctx)
+	}
+	return t.manager.List(q), nil
+}
+
+// FindTask 按 ID 查找任意类型的任务，其他工作区的任务按不存在处理
+func (t *Tools) FindTask(ctx context.Context, id string) (interface{}, error) {
+	if err := t.CheckAccess(ctx, id); err != nil {
+		return nil, err
+	}
+	if task, err := t.manager.Download(id); err == nil {
+		return task, nil
+	}
+	if task, err := t.manager.Pipeline(id); err == nil {
+		return task, nil
+	}
+	if task, err := t.manager.Collection(id); err == nil {
+		return task, nil
+	}
+	return t.manager.Transcribe(id)
+}
@@ -0,0 +1,442 @@
+package mcptools
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/ratelimit"
+	"zhihu-downloader/internal/summarizer"
+	"zhihu-downloader/internal/tasks"
+	"zhihu-downloader/internal/transcriber"
+	"zhihu-downloader/internal/zhihu"
+)
+
+func (t *Tools) downloadVideo(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	url, _ := args["url"].(string)
+	outputDir, _ := args["output_dir"].(string)
+	filename, _ := args["filename"].(string)
+	backend, _ := args["backend"].(string)
+	maxRateArg, _ := args["max_rate"].(string)
+	connections, _ := args["connections"].(float64)
+	comments, _ := args["comments"].(bool)
+	commentsLimit, _ := args["comments_limit"].(float64)
+	filenameTemplate, _ := args["filename_template"].(string)
+	force, _ := args["force"].(bool)
+	hwaccel, _ := args["hwaccel"].(string)
+	videoQuality, _ := args["quality"].(string)
+	if videoQuality == "" {
+		videoQuality = t.quality
+	}
+
+	maxRate, err := ratelimit.Parse(maxRateArg)
+	if err != nil {
+		return nil, err
+	}
+
+	priority, _ := args["priority"].(string)
+
+	return t.idempotent(ctx, args, tasks.KindDownload, func() (string, interface{}, error) {
+		task, err := t.manager.StartDownload(downloader.Request{
+			URL:       url,
+			Quality:   videoQuality,
+			OutputDir: outputDir,
+			Filename:  filename,
+			Backend:   backend,
+			MaxRate:   maxRate,
+			Comments:  comments,
+			Force:     force,
+
+			Connections:      int(connections),
+			CommentsLimit:    int(commentsLimit),
+			FilenameTemplate: filenameTemplate,
+			FFmpegArgs:       stringList(args, "ffmpeg_args"),
+			Headers:          stringMap(args, "headers"),
+			Cookies:          stringMap(args, "cookies"),
+			HWAccel:          hwaccel,
+			Transcode:        transcodeOptions(args),
+			Notify:           stringList(args, "notify"),
+			Priority:         priority,
+			Workspace:        workspaceName(ctx),
+		})
+		if err != nil {
+			return "", nil, err
+		}
+
+		if task.Cached {
+			return task.ID, map[string]interface{}{
+				"task_id":   task.ID,
+				"cached":    true,
+				"file_path": task.FilePath,
+				"file_name": task.FileName,
+				"status":    "该视频已下载过，直接返回已有文件（force=true 可重新下载）",
+			}, nil
+		}
+		result := map[string]interface{}{
+			"task_id":    task.ID,
+			"output_dir": task.OutputDir,
+			"backend":    task.Backend,
+			"status":     "已启动下载任务，请使用 get_progress 查看进度",
+		}
+		// 按标题命名时文件名在下载开始后才确定，完成后见 get_progress 的 file_name
+		if task.Filename != "" {
+			result["filename"] = task.Filename + ".mp4"
+		}
+		return task.ID, result, nil
+	})
+}
+
+func (t *Tools) transcribeVideo(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	videoPath, _ := args["video_path"].(string)
+	language, _ := args["language"].(string)
+	outputDir, _ := args["output_dir"].(string)
+	outputFilename, _ := args["output_filename"].(string)
+	diarize, _ := args["diarize"].(bool)
+	summarize, _ := args["summarize"].(bool)
+	model, _ := args["model"].(string)
+	audioFormat, _ := args["audio_format"].(string)
+	audioQuality, _ := args["audio_quality"].(string)
+	keepIntermediate := optionalBool(args, "keep_intermediate")
+	initialPrompt, _ := args["initial_prompt"].(string)
+	priority, _ := args["priority"].(string)
+	filenameTemplate, _ := args["filename_template"].(string)
+
+	return t.idempotent(ctx, args, tasks.KindTranscribe, func() (string, interface{}, error) {
+		task, err := t.manager.StartTranscribe(transcriber.Request{
+			VideoPath:      videoPath,
+			OutputDir:      outputDir,
+			OutputFilename: outputFilename,
+			Language:       language,
+			Diarize:        diarize,
+			Summarize:      summarize,
+			Model:          model,
+			AudioFormat:    audioFormat,
+			AudioQuality:   audioQuality,
+
+			KeepIntermediate: keepIntermediate,
+			FilenameTemplate: filenameTemplate,
+			InitialPrompt:    initialPrompt,
+			Vocabulary:       stringList(args, "vocabulary"),
+			Notify:           stringList(args, "notify"),
+			Priority:         priority,
+			Workspace:        workspaceName(ctx),
+		})
+		if err != nil {
+			return "", nil, err
+		}
+
+		result := map[string]interface{}{
+			"task_id":         task.ID,
+			"model":           task.Model,
+			"output_dir":      task.OutputDir,
+			"output_filename": task.OutputFilename,
+			"mp3_path":        filepath.Join(task.OutputDir, task.OutputFilename+"."+task.AudioFormat),
+			"txt_path":        filepath.Join(task.OutputDir, task.OutputFilename+".txt"),
+			"status":          "已启动转录任务，请使用 get_progress 查看进度",
+		}
+		if diarize {
+			result["srt_path"] = filepath.Join(task.OutputDir, task.OutputFilename+".srt")
+			result["json_path"] = filepath.Join(task.OutputDir, task.OutputFilename+".json")
+		}
+		if task.Summarize {
+			result["summary_path"] = summarizer.Path(filepath.Join(task.OutputDir, task.OutputFilename+".txt"))
+		}
+		return task.ID, result, nil
+	})
+}
+
+func (t *Tools) transcribeDirectory(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	dir, _ := args["dir"].(string)
+	pattern, _ := args["pattern"].(string)
+	outputDir, _ := args["output_dir"].(string)
+	language, _ := args["language"].(string)
+	diarize, _ := args["diarize"].(bool)
+	summarize, _ := args["summarize"].(bool)
+	model, _ := args["model"].(string)
+	filenameTemplate, _ := args["filename_template"].(string)
+
+	return t.idempotent(ctx, args, tasks.KindBatch, func() (string, interface{}, error) {
+		batch, err := t.manager.StartTranscribeBatch(dir, pattern, transcriber.Request{
+			OutputDir: outputDir,
+			Language:  language,
+			Diarize:   diarize,
+			Summarize: summarize,
+			Model:     model,
+			Notify:    stringList(args, "notify"),
+			Workspace: workspaceName(ctx),
+
+			FilenameTemplate: filenameTemplate,
+		})
+		if err != nil {
+			return "", nil, err
+		}
+		return batch.ID, map[string]interface{}{
+			"task_id":   batch.ID,
+			"task_type": tasks.KindBatch,
+			"task_ids":  batch.TaskIDs,
+			"skipped":   batch.Skipped,
+			"status":    fmt.Sprintf("已创建 %d 个转录任务，跳过 %d 个文件，请使用 get_progress 查看进度", len(batch.TaskIDs), len(batch.Skipped)),
+		}, nil
+	})
+}
+
+func (t *Tools) importVideo(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	filePath, _ := args["file_path"].(string)
+	url, _ := args["url"].(string)
+	title, _ := args["title"].(string)
+
+	task, err := t.manager.ImportVideo(tasks.ImportRequest{FilePath: filePath, URL: url, Title: title, Workspace: workspaceName(ctx)})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"download_id": task.ID,
+		"file_path":   task.FilePath,
+		"cached":      task.Cached,
+		"status":      "已导入，可以使用 transcribe_video 转录",
+	}, nil
+}
+
+func (t *Tools) extractClip(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	filePath, _ := args["file_path"].(string)
+	taskID, _ := args["task_id"].(string)
+	start, _ := args["start"].(float64)
+	end, _ := args["end"].(float64)
+	format, _ := args["format"].(string)
+	reencode, _ := args["reencode"].(bool)
+	outputDir, _ := args["output_dir"].(string)
+	filename, _ := args["filename"].(string)
+	if taskID != "" {
+		if err := t.CheckAccess(ctx, taskID); err != nil {
+			return nil, err
+		}
+	}
+
+	return t.idempotent(ctx, args, tasks.KindDownload, func() (string, interface{}, error) {
+		task, err := t.manager.StartClip(tasks.ClipRequest{
+			FilePath:  filePath,
+			TaskID:    taskID,
+			Start:     start,
+			End:       end,
+			Format:    format,
+			Reencode:  reencode,
+			OutputDir: outputDir,
+			Filename:  filename,
+			Workspace: workspaceName(ctx),
+		})
+		if err != nil {
+			return "", nil, err
+		}
+		return task.ID, map[string]interface{}{
+			"task_id":     task.ID,
+			"task_type":   tasks.KindDownload,
+			"download_id": task.ID,
+			"source":      task.ClipSource,
+			"status":      "片段任务已创建，使用 get_progress 查询进度",
+		}, nil
+	})
+}
+
+func (t *Tools) downloadAndTranscribe(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	url, _ := args["url"].(string)
+	outputDir, _ := args["output_dir"].(string)
+	filename, _ := args["filename"].(string)
+	backend, _ := args["backend"].(string)
+	maxRateArg, _ := args["max_rate"].(string)
+	connections, _ := args["connections"].(float64)
+	comments, _ := args["comments"].(bool)
+	commentsLimit, _ := args["comments_limit"].(float64)
+	filenameTemplate, _ := args["filename_template"].(string)
+	language, _ := args["language"].(string)
+	diarize, _ := args["diarize"].(bool)
+	summarize, _ := args["summarize"].(bool)
+	model, _ := args["model"].(string)
+	audioFormat, _ := args["audio_format"].(string)
+	audioQuality, _ := args["audio_quality"].(string)
+	keepIntermediate := optionalBool(args, "keep_intermediate")
+	initialPrompt, _ := args["initial_prompt"].(string)
+	subtitleMode, _ := args["subtitle_mode"].(string)
+	hwaccel, _ := args["hwaccel"].(string)
+	videoQuality, _ := args["quality"].(string)
+	if videoQuality == "" {
+		videoQuality = t.quality
+	}
+
+	maxRate, err := ratelimit.Parse(maxRateArg)
+	if err != nil {
+		return nil, err
+	}
+
+	priority, _ := args["priority"].(string)
+
+	return t.idempotent(ctx, args, tasks.KindPipeline, func() (string, interface{}, error) {
+		task, err := t.manager.StartPipeline(downloader.Request{
+			URL:       url,
+			Quality:   videoQuality,
+			OutputDir: outputDir,
+			Filename:  filename,
+			Backend:   backend,
+			MaxRate:   maxRate,
+			Comments:  comments,
+
+			Connections:      int(connections),
+			CommentsLimit:    int(commentsLimit),
+			FilenameTemplate: filenameTemplate,
+			FFmpegArgs:       stringList(args, "ffmpeg_args"),
+			Headers:          stringMap(args, "headers"),
+			Cookies:          stringMap(args, "cookies"),
+			HWAccel:          hwaccel,
+			Transcode:        transcodeOptions(args),
+			Notify:           stringList(args, "notify"),
+			Priority:         priority,
+			Workspace:        workspaceName(ctx),
+		}, transcriber.Request{
+			Language: language, Diarize: diarize, Summarize: summarize, Model: model,
+			AudioFormat: audioFormat, AudioQuality: audioQuality, KeepIntermediate: keepIntermediate,
+			InitialPrompt: initialPrompt, Vocabulary: stringList(args, "vocabulary"),
+		}, subtitleMode)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return task.ID, map[string]interface{}{
+			"task_id":     task.ID,
+			"task_type":   tasks.KindPipeline,
+			"download_id": task.DownloadID,
+			"output_dir":  task.OutputDir,
+			"status":      "已启动下载和转录，请使用 get_progress（task_type 为 pipeline）查看进度",
+		}, nil
+	})
+}
+
+func (t *Tools) downloadCollection(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	url, _ := args["url"].(string)
+	outputDir, _ := args["output_dir"].(string)
+	backend, _ := args["backend"].(string)
+	maxRateArg, _ := args["max_rate"].(string)
+	limit, _ := args["limit"].(float64)
+	videoQuality, _ := args["quality"].(string)
+	if videoQuality == "" {
+		videoQuality = t.quality
+	}
+
+	maxRate, err := ratelimit.Parse(maxRateArg)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.idempotent(ctx, args, tasks.KindCollection, func() (string, interface{}, error) {
+		task, err := t.manager.StartCollection(downloader.Request{
+			URL:       url,
+			Quality:   videoQuality,
+			OutputDir: outputDir,
+			Backend:   backend,
+			MaxRate:   maxRate,
+			Workspace: workspaceName(ctx),
+		}, int(limit))
+		if err != nil {
+			return "", nil, err
+		}
+
+		return task.ID, map[string]interface{}{
+			"task_id":   task.ID,
+			"task_type": tasks.KindCollection,
+			"status":    "正在获取视频列表，之后每个视频作为一个下载任务排队，请使用 get_progress（task_type 为 collection）查看进度",
+		}, nil
+	})
+}
+
+func (t *Tools) downloadAnswer(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	url, _ := args["url"].(string)
+	outputDir, _ := args["output_dir"].(string)
+	filename, _ := args["filename"].(string)
+	downloadImages, _ := args["download_images"].(bool)
+	downloadVideos := true
+	if v, ok := args["download_videos"].(bool); ok {
+		downloadVideos = v
+	}
+
+	outputDir, err := t.manager.WorkspaceOutputDir(workspaceName(ctx), outputDir)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	content, err := zhihu.Fetch(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if filename == "" {
+		filename = content.DefaultFilename()
+	}
+	saved, err := zhihu.Save(ctx, content, zhihu.SaveOptions{
+		OutputDir:      outputDir,
+		Filename:       filename,
+		DownloadImages: downloadImages,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 嵌入的视频作为下载任务，与 Markdown 保存在同一目录
+	videos := []map[string]interface{}{}
+	for i, video := range saved.Videos {
+		item := map[string]interface{}{"url": video.URL, "title": video.Title}
+		if downloadVideos {
+			task, err := t.manager.StartDownload(downloader.Request{
+				URL:       video.URL,
+				Quality:   t.quality,
+				OutputDir: outputDir,
+				Filename:  fmt.Sprintf("%s_video_%d", filename, i+1),
+				Workspace: workspaceName(ctx),
+			})
+			if err != nil {
+				item["error"] = err.Error()
+			} else {
+				item["task_id"] = task.ID
+			}
+		}
+		videos = append(videos, item)
+	}
+
+	return map[string]interface{}{
+		"title":         saved.Title,
+		"markdown_path": saved.MarkdownPath,
+		"images":        saved.Images,
+		"videos":        videos,
+	}, nil
+}
+
+func (t *Tools) downloadComments(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	url, _ := args["url"].(string)
+	outputDir, _ := args["output_dir"].(string)
+	filename, _ := args["filename"].(string)
+	limit, _ := args["limit"].(float64)
+	if limit < 0 {
+		return nil, fmt.Errorf("limit 不能为负数")
+	}
+	if err := downloader.ValidateFilename(filename); err != nil {
+		return nil, err
+	}
+
+	outputDir, err := t.manager.WorkspaceOutputDir(workspaceName(ctx), outputDir)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	comments, err := zhihu.FetchComments(ctx, url, int(limit))
+	if err != nil {
+		return nil, err
+	}
+	if filename == "" {
+		filename = comments.DefaultFilename()
+	}
+	return comments.Save(filepath.Join(outputDir, filename))
+}
@@ -0,0 +1,1056 @@
+package mcptools
+
+import (
+	"zhihu-downloader/internal/media"
+	"zhihu-downloader/internal/tasks"
+	"zhihu-downloader/internal/toolschema"
+	"zhihu-downloader/internal/transcriber"
+)
+
+// idempotencyKeyProperty 创建任务的工具共用的幂等键参数
+var idempotencyKeyProperty = map[string]interface{}{
+	"type":        "string",
+	"maxLength":   tasks.MaxIdempotencyKeyLength,
+	"description": "幂等键：超时后重试时传入相同的值，24 小时内不会重复创建任务，直接返回第一次创建的任务",
+}
+
+// List 返回所有工具的定义，调用工具时按其中的 inputSchema 校验参数，结果的结构见 outputSchema
+func (t *Tools) List() []map[string]interface{} {
+	return withOutputSchemas([]map[string]interface{}{
+		{
+			"name":        "download_video",
+			"description": "下载知乎视频为 MP4 格式（可选择清晰度），也支持 yt-dlp 能处理的其他视频网站",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"url": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxURLLength,
+						"description": "视频 URL",
+					},
+					"output_dir": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxPathLength,
+						"description": "输出目录（默认 ~/Downloads）",
+					},
+					"filename": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxPathLength,
+						"description": "输出文件名（不含扩展名，默认使用视频标题）",
+					},
+					"filename_template": map[string]interface{}{
+						"type":        "string",
+						"description": "未指定 filename 时的文件名模板，可用 {title} {author} {quality} {resolution} {date} {id}（默认 {title}）",
+					},
+					"quality": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"best", "uhd", "fhd", "hd", "sd", "ld"},
+						"description": "清晰度（默认 " + t.quality + "；没有对应清晰度时选择不高于它的最高清晰度）",
+					},
+					"backend": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"auto", "native", "yt-dlp"},
+						"description": "下载后端（默认 auto：知乎使用内置下载，B 站、YouTube、抖音等使用 yt-dlp）",
+					},
+					"max_rate": map[string]interface{}{
+						"type":        "string",
+						"description": "下载速度上限，例如 2M、500K（默认只受全局 download.max_rate 限制）",
+					},
+					"ffmpeg_args": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "生成 MP4 时追加的 ffmpeg 输出选项，只允许 -crf、-preset、-movflags、-b:v 等白名单中的选项，例如 [\"-movflags\", \"+faststart\"]",
+					},
+					"headers": map[string]interface{}{
+						"type":                 "object",
+						"additionalProperties": map[string]interface{}{"type": "string"},
+						"description":          "下载时附加的请求头，例如 {\"Authorization\": \"Bearer ...\"}，只用于本次下载（包括暂停后继续和重试），下载完成后清除，不会在任务详情中返回",
+					},
+					"cookies": map[string]interface{}{
+						"type":                 "object",
+						"additionalProperties": map[string]interface{}{"type": "string"},
+						"description":          "下载时附加的 cookies（名称到值），与已保存的知乎登录 cookies 合并，同名时优先",
+					},
+					"transcode": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"none", "h264", "h265", "av1"},
+						"description": "下载完成后转码为 h264 / h265 / av1 并替换原文件（默认使用配置 transcode.codec，none 表示不转码）",
+					},
+					"max_height": map[string]interface{}{
+						"type":        "integer",
+						"description": "转码的分辨率上限（画面高度），例如 720，只缩小不放大；需要同时指定 transcode",
+					},
+					"crf": map[string]interface{}{
+						"type":        "integer",
+						"description": "转码画质，越小越清晰、文件越大（默认 h264 23、h265 28、av1 35）",
+					},
+					"hwaccel": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"none", "videotoolbox", "nvenc", "vaapi"},
+						"description": "转码时的硬件加速：videotoolbox（macOS）、nvenc（NVIDIA）、vaapi（Linux），默认使用配置 tools.hwaccel",
+					},
+					"connections": map[string]interface{}{
+						"type":        "integer",
+						"description": "m3u8 同时下载的分片数 1–16（默认使用配置 download.connections，未配置时按分片数自动选择 4–8）",
+					},
+					"comments": map[string]interface{}{
+						"type":        "boolean",
+						"description": "下载完成后把知乎评论保存为视频旁边的 .comments.json 和 .comments.md（仅知乎视频、回答和文章）",
+					},
+					"comments_limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "最多保存的根评论数，按热度排序（默认 0：全部）",
+					},
+					"force": map[string]interface{}{
+						"type":        "boolean",
+						"description": "同一视频已以相同清晰度下载到同一目录时仍然重新下载（默认 false：直接返回已下载的文件）",
+					},
+					"notify": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "结束时的通知目标：配置的通知渠道名称（notify.channels）或 webhook 地址（与下载链接一样只能是 download.allowed_hosts 允许的公网地址），[\"none\"] 表示不通知（默认发给所有配置的渠道）",
+					},
+					"priority": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"high", "normal", "low"},
+						"description": "优先级：排队的下载中优先级高的先开始（默认 normal）",
+					},
+					"idempotency_key": idempotencyKeyProperty,
+				},
+				"required": []string{"url"},
+			},
+		},
+		{
+			"name":        "transcribe_video",
+			"description": "将视频转录为文本（包括音频提取和 Whisper 转录）",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"video_path": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxPathLength,
+						"description": "MP4 视频文件路径",
+					},
+					"filename_template": map[string]interface{}{
+						"type":        "string",
+						"description": "音频、txt、srt 的文件名模板，可用 {title} {author} {quality} {resolution} {date} {id}，取自下载这个视频的任务（默认与视频同名）",
+					},
+					"output_dir": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxPathLength,
+						"description": "输出目录（默认与视频同目录）",
+					},
+					"output_filename": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxPathLength,
+						"description": "输出文件名（不含扩展名，默认与视频同名）",
+					},
+					"language": map[string]interface{}{
+						"type":        "string",
+						"description": "语言代码，例如 zh、en（默认 auto：根据前 30 秒音频自动识别）",
+					},
+					"diarize": map[string]interface{}{
+						"type":        "boolean",
+						"description": "区分说话人，txt/srt/json 中标注 Speaker 1/2（需要 pyannote.audio 和 Hugging Face 令牌）",
+					},
+					"summarize": map[string]interface{}{
+						"type":        "boolean",
+						"description": "转录后调用大模型生成摘要、要点和章节（保存为 <文件名>.summary.md，需要配置 summary 接口）",
+					},
+					"model": map[string]interface{}{
+						"type":        "string",
+						"enum":        transcriber.Models,
+						"description": "Whisper 模型，越大越准确也越慢（默认使用配置的模型，通常为 base）",
+					},
+					"audio_format": map[string]interface{}{
+						"type":        "string",
+						"enum":        transcriber.AudioFormats,
+						"description": "提取的音频格式：wav 为 16kHz 单声道，最适合转录；mp3 / m4a / flac 保留原始音质（默认使用配置，通常为 wav）",
+					},
+					"audio_quality": map[string]interface{}{
+						"type":        "string",
+						"description": "mp3 / m4a 的码率，例如 128k、320k（默认 192k）",
+					},
+					"keep_intermediate": map[string]interface{}{
+						"type":        "boolean",
+						"description": "转录成功后保留提取的音频（默认使用配置 transcribe.keep_intermediate，通常为 true）；false 时转录完成即删除，节省空间",
+					},
+					"initial_prompt": map[string]interface{}{
+						"type":        "string",
+						"description": "传给 Whisper 的提示文本，例如视频主题或一段包含专业术语的文字，帮助识别术语和标点风格（最多 1000 字）",
+					},
+					"vocabulary": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "视频中可能出现的专业词汇（医学、法律、编程术语、人名等），帮助 Whisper 正确识别；默认使用工作区的词汇表（workspaces[].glossary）",
+					},
+					"notify": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "结束时的通知目标：配置的通知渠道名称（notify.channels）或 webhook 地址（与下载链接一样只能是 download.allowed_hosts 允许的公网地址），[\"none\"] 表示不通知（默认发给所有配置的渠道）",
+					},
+					"priority": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"high", "normal", "low"},
+						"description": "优先级：同时转录的任务数达到上限（transcribe.max_concurrent）时排队，优先级高的先开始（默认 normal）",
+					},
+					"idempotency_key": idempotencyKeyProperty,
+				},
+				"required": []string{"video_path"},
+			},
+		},
+		{
+			"name":        "transcribe_directory",
+			"description": "批量转录目录中匹配的所有视频：已有同名 .txt / .srt、已经转录完成或正在转录的文件跳过，其余每个视频创建一个转录任务。返回批量转录 ID（task_type 为 batch，可用 get_progress 查看汇总进度）、转录任务 ID 和跳过的文件",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"dir": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxPathLength,
+						"description": "视频所在目录（不包括子目录）",
+					},
+					"pattern": map[string]interface{}{
+						"type":        "string",
+						"description": "匹配的文件名，例如 *.mp4、*.mkv、第*.mp4（默认 *.mp4）",
+					},
+					"filename_template": map[string]interface{}{
+						"type":        "string",
+						"description": "音频、txt、srt 的文件名模板，可用 {title} {author} {quality} {resolution} {date} {id}，取自下载这个视频的任务（默认与视频同名）",
+					},
+					"output_dir": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxPathLength,
+						"description": "输出目录（默认与各视频同目录），已有同名 .txt 或 .srt 的视频跳过",
+					},
+					"language": map[string]interface{}{
+						"type":        "string",
+						"description": "语言代码，例如 zh、en（默认 auto：每个视频分别识别）",
+					},
+					"diarize": map[string]interface{}{
+						"type":        "boolean",
+						"description": "区分说话人（需要 pyannote.audio 和 Hugging Face 令牌）",
+					},
+					"summarize": map[string]interface{}{
+						"type":        "boolean",
+						"description": "转录后为每个视频生成摘要（需要配置 summary 接口）",
+					},
+					"model": map[string]interface{}{
+						"type":        "string",
+						"enum":        transcriber.Models,
+						"description": "Whisper 模型（默认使用配置的模型，通常为 base）",
+					},
+					"notify": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "每个转录结束时的通知目标：配置的通知渠道名称或 webhook 地址（只能是 download.allowed_hosts 允许的公网地址），[\"none\"] 表示不通知（默认发给所有配置的渠道）",
+					},
+					"idempotency_key": idempotencyKeyProperty,
+				},
+				"required": []string{"dir"},
+			},
+		},
+		{
+			"name":        "import_video",
+			"description": "把已有的本地视频（不是由本服务下载的）登记为已完成的下载任务，之后可以转录、搜索转录文本并计入统计",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"file_path": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxPathLength,
+						"description": "本地视频文件路径（.mp4 / .mkv / .webm / .mov / .flv）",
+					},
+					"url": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxURLLength,
+						"description": "视频的来源链接（可选）",
+					},
+					"title": map[string]interface{}{
+						"type":        "string",
+						"description": "视频标题（可选）",
+					},
+				},
+				"required": []string{"file_path"},
+			},
+		},
+		{
+			"name":        "extract_clip",
+			"description": "截取视频的一段（file_path 和 task_id 指定一个），作为单独的任务排队执行：先直接复制流，失败时重新编码。用 get_progress 查询进度，完成后 file_path 为截取的片段",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"file_path": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxPathLength,
+						"description": "本地视频文件路径",
+					},
+					"task_id": map[string]interface{}{
+						"type":        "string",
+						"description": "已完成的下载或流水线任务 ID，截取其视频",
+					},
+					"start": map[string]interface{}{
+						"type":        "number",
+						"minimum":     0,
+						"description": "开始时间（秒）",
+					},
+					"end": map[string]interface{}{
+						"type":        "number",
+						"minimum":     0,
+						"description": "结束时间（秒），必须大于 start",
+					},
+					"format": map[string]interface{}{
+						"type":        "string",
+						"enum":        media.ClipFormats,
+						"description": "输出格式（默认 mp4），m4a / mp3 只保留音频",
+					},
+					"reencode": map[string]interface{}{
+						"type":        "boolean",
+						"description": "直接重新编码以准确截取（默认先直接复制流，开头会对齐到关键帧）",
+					},
+					"output_dir": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxPathLength,
+						"description": "输出目录（默认与源视频相同）",
+					},
+					"filename": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxPathLength,
+						"description": "输出文件名，不含扩展名（默认为 <源文件名>_clip_<起>-<止>）",
+					},
+					"idempotency_key": idempotencyKeyProperty,
+				},
+				"required": []string{"start", "end"},
+			},
+		},
+		{
+			"name":        "download_and_transcribe",
+			"description": "下载视频并自动转录为文本，只返回一个任务 ID，进度合并为一个（下载 0–50%，提取音频 50–60%，转录 60–100%）",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"url": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxURLLength,
+						"description": "视频 URL",
+					},
+					"output_dir": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxPathLength,
+						"description": "输出目录，视频、音频和文本都保存在这里（默认 ~/Downloads）",
+					},
+					"filename": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxPathLength,
+						"description": "输出文件名（不含扩展名，默认使用视频标题）",
+					},
+					"filename_template": map[string]interface{}{
+						"type":        "string",
+						"description": "未指定 filename 时的文件名模板，可用 {title} {author} {quality} {resolution} {date} {id}（默认 {title}）",
+					},
+					"quality": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"best", "uhd", "fhd", "hd", "sd", "ld"},
+						"description": "清晰度（默认 " + t.quality + "）",
+					},
+					"backend": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"auto", "native", "yt-dlp"},
+						"description": "下载后端（默认 auto）",
+					},
+					"max_rate": map[string]interface{}{
+						"type":        "string",
+						"description": "下载速度上限，例如 2M、500K（默认只受全局 download.max_rate 限制）",
+					},
+					"ffmpeg_args": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "生成 MP4 时追加的 ffmpeg 输出选项，只允许 -crf、-preset、-movflags、-b:v 等白名单中的选项，例如 [\"-movflags\", \"+faststart\"]",
+					},
+					"headers": map[string]interface{}{
+						"type":                 "object",
+						"additionalProperties": map[string]interface{}{"type": "string"},
+						"description":          "下载时附加的请求头，例如 {\"Authorization\": \"Bearer ...\"}，只用于本次下载（包括暂停后继续和重试），下载完成后清除，不会在任务详情中返回",
+					},
+					"cookies": map[string]interface{}{
+						"type":                 "object",
+						"additionalProperties": map[string]interface{}{"type": "string"},
+						"description":          "下载时附加的 cookies（名称到值），与已保存的知乎登录 cookies 合并，同名时优先",
+					},
+					"transcode": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"none", "h264", "h265", "av1"},
+						"description": "下载完成后转码为 h264 / h265 / av1 并替换原文件（默认使用配置 transcode.codec，none 表示不转码）",
+					},
+					"max_height": map[string]interface{}{
+						"type":        "integer",
+						"description": "转码的分辨率上限（画面高度），例如 720，只缩小不放大；需要同时指定 transcode",
+					},
+					"crf": map[string]interface{}{
+						"type":        "integer",
+						"description": "转码画质，越小越清晰、文件越大（默认 h264 23、h265 28、av1 35）",
+					},
+					"connections": map[string]interface{}{
+						"type":        "integer",
+						"description": "m3u8 同时下载的分片数 1–16（默认使用配置 download.connections，未配置时按分片数自动选择 4–8）",
+					},
+					"comments": map[string]interface{}{
+						"type":        "boolean",
+						"description": "下载完成后把知乎评论保存为视频旁边的 .comments.json 和 .comments.md（仅知乎视频、回答和文章）",
+					},
+					"comments_limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "最多保存的根评论数，按热度排序（默认 0：全部）",
+					},
+					"language": map[string]interface{}{
+						"type":        "string",
+						"description": "语言代码，例如 zh、en（默认 auto：根据前 30 秒音频自动识别）",
+					},
+					"diarize": map[string]interface{}{
+						"type":        "boolean",
+						"description": "区分说话人，txt/srt/json 中标注 Speaker 1/2（需要 pyannote.audio 和 Hugging Face 令牌）",
+					},
+					"summarize": map[string]interface{}{
+						"type":        "boolean",
+						"description": "转录后调用大模型生成摘要、要点和章节（保存为 <文件名>.summary.md，需要配置 summary 接口）",
+					},
+					"model": map[string]interface{}{
+						"type":        "string",
+						"enum":        transcriber.Models,
+						"description": "Whisper 模型，越大越准确也越慢（默认使用配置的模型，通常为 base）",
+					},
+					"audio_format": map[string]interface{}{
+						"type":        "string",
+						"enum":        transcriber.AudioFormats,
+						"description": "提取的音频格式：wav 为 16kHz 单声道，最适合转录；mp3 / m4a / flac 保留原始音质（默认使用配置，通常为 wav）",
+					},
+					"audio_quality": map[string]interface{}{
+						"type":        "string",
+						"description": "mp3 / m4a 的码率，例如 128k、320k（默认 192k）",
+					},
+					"keep_intermediate": map[string]interface{}{
+						"type":        "boolean",
+						"description": "转录成功后保留提取的音频（默认使用配置 transcribe.keep_intermediate，通常为 true）；false 时转录完成即删除，节省空间",
+					},
+					"initial_prompt": map[string]interface{}{
+						"type":        "string",
+						"description": "传给 Whisper 的提示文本，例如视频主题或一段包含专业术语的文字，帮助识别术语和标点风格（最多 1000 字）",
+					},
+					"vocabulary": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "视频中可能出现的专业词汇（医学、法律、编程术语、人名等），帮助 Whisper 正确识别；默认使用工作区的词汇表（workspaces[].glossary）",
+					},
+					"hwaccel": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"none", "videotoolbox", "nvenc", "vaapi"},
+						"description": "转码和烧录字幕时的硬件加速：videotoolbox（macOS）、nvenc（NVIDIA）、vaapi（Linux），默认使用配置 tools.hwaccel",
+					},
+					"subtitle_mode": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"none", "mux", "burn"},
+						"description": "转录后的字幕处理：mux 封装为可开关的软字幕，burn 烧录进画面（重新编码），输出 <文件名>.subtitled.mp4（默认 none）",
+					},
+					"notify": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "结束时的通知目标：配置的通知渠道名称（notify.channels）或 webhook 地址（与下载链接一样只能是 download.allowed_hosts 允许的公网地址），[\"none\"] 表示不通知（默认发给所有配置的渠道）",
+					},
+					"priority": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"high", "normal", "low"},
+						"description": "优先级：排队的下载中优先级高的先开始（默认 normal）",
+					},
+					"idempotency_key": idempotencyKeyProperty,
+				},
+				"required": []string{"url"},
+			},
+		},
+		{
+			"name":        "download_answer",
+			"description": "保存知乎回答或专栏文章为 Markdown，并下载其中嵌入的视频",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"url": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxURLLength,
+						"description": "知乎回答或文章 URL",
+					},
+					"output_dir": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxPathLength,
+						"description": "输出目录（默认 ~/Downloads）",
+					},
+					"filename": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxPathLength,
+						"description": "输出文件名（不含扩展名，默认 zhihu_answer_ID / zhihu_article_ID）",
+					},
+					"download_images": map[string]interface{}{
+						"type":        "boolean",
+						"description": "是否把图片下载到本地（默认 false，引用原图地址）",
+					},
+					"download_videos": map[string]interface{}{
+						"type":        "boolean",
+						"description": "是否下载嵌入的视频（默认 true）",
+					},
+				},
+				"required": []string{"url"},
+			},
+		},
+		{
+			"name":        "download_comments",
+			"description": "保存知乎视频、回答或文章的评论（按热度排序，包括部分回复）为 JSON 和 Markdown",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"url": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxURLLength,
+						"description": "知乎视频（zvideo）、回答或文章 URL",
+					},
+					"output_dir": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxPathLength,
+						"description": "输出目录（默认 ~/Downloads）",
+					},
+					"filename": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxPathLength,
+						"description": "输出文件名（不含扩展名，默认 zhihu_zvideo_ID / zhihu_answer_ID / zhihu_article_ID），保存为 <文件名>.comments.json 和 .comments.md",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "最多保存的根评论数（默认 0：全部）",
+					},
+				},
+				"required": []string{"url"},
+			},
+		},
+		{
+			"name":        "download_collection",
+			"description": "下载知乎专栏、收藏夹、问题或用户主页（视频 / 回答）中的所有视频：翻页列出视频后每个视频创建一个下载任务排队，进度为所有视频的平均进度",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"url": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxURLLength,
+						"description": "专栏、收藏夹、问题或用户视频 / 回答页 URL，例如 https://www.zhihu.com/people/xxx/zvideos",
+					},
+					"output_dir": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxPathLength,
+						"description": "输出目录，视频保存在其中以合集名称命名的子目录（默认 ~/Downloads）",
+					},
+					"quality": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"best", "uhd", "fhd", "hd", "sd", "ld"},
+						"description": "清晰度（默认 " + t.quality + "）",
+					},
+					"backend": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"auto", "native", "yt-dlp"},
+						"description": "下载后端（默认 auto）",
+					},
+					"max_rate": map[string]interface{}{
+						"type":        "string",
+						"description": "每个视频的下载速度上限，例如 2M、500K（默认只受全局 download.max_rate 限制）",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "最多下载的视频数（默认 200）",
+					},
+					"idempotency_key": idempotencyKeyProperty,
+				},
+				"required": []string{"url"},
+			},
+		},
+		{
+			"name":        "create_subscription",
+			"description": "订阅知乎用户或专栏：由网关服务按间隔定期列出最新的视频，为没有下载过的视频自动创建下载任务（transcribe 时下载并转录）",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"url": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxURLLength,
+						"description": "知乎用户主页、用户的视频 / 回答页或专栏链接，用户主页按视频页处理",
+					},
+					"output_dir": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxPathLength,
+						"description": "输出目录，视频保存在其中以用户或专栏名称命名的子目录（默认 ~/Downloads）",
+					},
+					"quality": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"best", "uhd", "fhd", "hd", "sd", "ld"},
+						"description": "清晰度",
+					},
+					"backend": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"auto", "native", "yt-dlp"},
+						"description": "下载后端（默认 auto）",
+					},
+					"filename_template": map[string]interface{}{
+						"type":        "string",
+						"description": "文件名模板，可用 {title} {author} {quality} {resolution} {date} {id}（默认 {title}）",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"minimum":     1,
+						"description": "每次检查最多列出的最新视频数（默认 30）",
+					},
+					"interval_minutes": map[string]interface{}{
+						"type":        "integer",
+						"minimum":     tasks.MinSubscriptionInterval,
+						"description": "检查间隔（分钟，默认 360）",
+					},
+					"transcribe": map[string]interface{}{
+						"type":        "boolean",
+						"description": "下载后自动转录",
+					},
+					"language": map[string]interface{}{
+						"type":        "string",
+						"description": "转录语言（默认自动检测）",
+					},
+					"model": map[string]interface{}{
+						"type":        "string",
+						"description": "转录使用的 Whisper 模型（默认使用配置的模型）",
+					},
+					"skip_existing": map[string]interface{}{
+						"type":        "boolean",
+						"description": "第一次检查时只记录已有的视频，之后只下载新发布的视频",
+					},
+					"enabled": map[string]interface{}{
+						"type":        "boolean",
+						"description": "是否定期检查（默认 true）",
+					},
+				},
+				"required": []string{"url"},
+			},
+		},
+		{
+			"name":        "list_subscriptions",
+			"description": "列出所有订阅及上次检查的时间、结果和累计创建的任务数",
+			"inputSchema": map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		{
+			"name":        "update_subscription",
+			"description": "修改订阅，未提供的字段保持不变",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"subscription_id": map[string]interface{}{
+						"type":        "string",
+						"description": "订阅 ID",
+					},
+					"url": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxURLLength,
+						"description": "知乎用户主页、用户的视频 / 回答页或专栏链接，用户主页按视频页处理",
+					},
+					"output_dir": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxPathLength,
+						"description": "输出目录，视频保存在其中以用户或专栏名称命名的子目录（默认 ~/Downloads）",
+					},
+					"quality": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"best", "uhd", "fhd", "hd", "sd", "ld"},
+						"description": "清晰度",
+					},
+					"backend": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"auto", "native", "yt-dlp"},
+						"description": "下载后端（默认 auto）",
+					},
+					"filename_template": map[string]interface{}{
+						"type":        "string",
+						"description": "文件名模板，可用 {title} {author} {quality} {resolution} {date} {id}（默认 {title}）",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"minimum":     1,
+						"description": "每次检查最多列出的最新视频数（默认 30）",
+					},
+					"interval_minutes": map[string]interface{}{
+						"type":        "integer",
+						"minimum":     tasks.MinSubscriptionInterval,
+						"description": "检查间隔（分钟，默认 360）",
+					},
+					"transcribe": map[string]interface{}{
+						"type":        "boolean",
+						"description": "下载后自动转录",
+					},
+					"language": map[string]interface{}{
+						"type":        "string",
+						"description": "转录语言（默认自动检测）",
+					},
+					"model": map[string]interface{}{
+						"type":        "string",
+						"description": "转录使用的 Whisper 模型（默认使用配置的模型）",
+					},
+					"skip_existing": map[string]interface{}{
+						"type":        "boolean",
+						"description": "第一次检查时只记录已有的视频，之后只下载新发布的视频",
+					},
+					"enabled": map[string]interface{}{
+						"type":        "boolean",
+						"description": "是否定期检查（默认 true）",
+					},
+				},
+				"required": []string{"subscription_id"},
+			},
+		},
+		{
+			"name":        "delete_subscription",
+			"description": "删除订阅，已创建的任务不受影响",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"subscription_id": map[string]interface{}{
+						"type":        "string",
+						"description": "订阅 ID",
+					},
+				},
+				"required": []string{"subscription_id"},
+			},
+		},
+		{
+			"name":        "check_subscription",
+			"description": "立即检查一次订阅，为新视频创建任务并返回任务 ID",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"subscription_id": map[string]interface{}{
+						"type":        "string",
+						"description": "订阅 ID",
+					},
+				},
+				"required": []string{"subscription_id"},
+			},
+		},
+		{
+			"name":        "get_video_info",
+			"description": "获取知乎视频的标题、作者、时长、封面、发布时间和可用清晰度（不下载）",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"url": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxURLLength,
+						"description": "知乎视频 URL",
+					},
+					"quality": map[string]interface{}{
+						"type":        "string",
+						"description": "查看该清晰度下实际会下载的版本（默认 " + t.quality + "）",
+					},
+				},
+				"required": []string{"url"},
+			},
+		},
+		{
+			"name":        "auth_status",
+			"description": "查看保存的知乎登录状态：是否已登录、登录凭证的过期时间，以及请求知乎验证登录的结果（valid 有效 / expiring 即将过期 / expired 已失效，需要重新上传 cookies）",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"refresh": map[string]interface{}{
+						"type":        "boolean",
+						"description": "立即重新验证登录（默认使用 10 分钟内的检查结果）",
+					},
+				},
+			},
+		},
+		{
+			"name":        "summarize_transcript",
+			"description": "调用大模型（OpenAI 兼容接口）为转录文本生成摘要、要点和章节列表，保存为转录文本旁边的 <文件名>.summary.md 并返回内容",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"task_id": map[string]interface{}{
+						"type":        "string",
+						"description": "已完成的转录或流水线任务 ID",
+					},
+					"txt_path": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxPathLength,
+						"description": "转录文本路径（不指定 task_id 时使用）",
+					},
+				},
+			},
+		},
+		{
+			"name":        "extract_highlights",
+			"description": "在已完成的转录文稿中查找关键词，或请大模型挑选精彩片段，返回带时间（秒）的片段；clip 为 true 时用 ffmpeg 把每个片段剪成单独的视频。结果同时保存为转录文本旁边的 <文件名>.highlights.json",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"task_id": map[string]interface{}{
+						"type":        "string",
+						"description": "已完成的转录或流水线任务 ID",
+					},
+					"keywords": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "关键词，不区分大小写，包含任一关键词的分段即为一个片段（与 llm 至少指定一个）",
+					},
+					"llm": map[string]interface{}{
+						"type":        "boolean",
+						"description": "请大模型挑选精彩片段（需要配置 summary 接口）",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"minimum":     1,
+						"maximum":     tasks.MaxHighlights,
+						"description": "大模型最多挑选的片段数（默认 10）",
+					},
+					"padding": map[string]interface{}{
+						"type":        "number",
+						"minimum":     0,
+						"description": "片段前后多保留的秒数（默认 2），重叠的片段会合并",
+					},
+					"clip": map[string]interface{}{
+						"type":        "boolean",
+						"description": "把每个片段剪成单独的视频，保存在 <文件名>.highlights 目录中",
+					},
+					"reencode": map[string]interface{}{
+						"type":        "boolean",
+						"description": "剪辑时重新编码，起止时间准确但较慢（默认直接复制，开头对齐到关键帧）",
+					},
+				},
+				"required": []string{"task_id"},
+			},
+		},
+		{
+			"name":        "search_transcripts",
+			"description": "在所有已完成转录的文本中搜索关键词，按任务返回匹配的段落及其在视频中的时间（秒）",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "搜索关键词，多个关键词用空格分隔，需要全部出现在同一段中",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "最多返回的任务数（默认 20，最大 100）",
+					},
+				},
+				"required": []string{"query"},
+			},
+		},
+		{
+			"name":        "semantic_search",
+			"description": "按意思在所有已转录的视频中查找最相关的片段，不要求包含相同的词，返回片段的文本、在视频中的时间（秒）和相似度。需要服务配置 embedding",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "要找的内容，用一句话描述，例如“讲如何准备面试的部分”",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "最多返回的任务数（默认 20，最大 100）",
+					},
+				},
+				"required": []string{"query"},
+			},
+		},
+		{
+			"name":        "get_progress",
+			"description": "获取下载、转录、流水线、合集任务或批量转录的进度",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"task_id": map[string]interface{}{
+						"type":        "string",
+						"description": "任务 ID",
+					},
+					"task_type": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"download", "transcribe", "pipeline", "collection", "batch"},
+						"description": "任务类型（download_and_transcribe 创建的任务为 pipeline，download_collection 创建的任务为 collection，transcribe_directory 创建的任务为 batch）",
+					},
+				},
+				"required": []string{"task_id", "task_type"},
+			},
+		},
+		{
+			"name":        "cancel_task",
+			"description": "取消正在执行或排队的任务：终止 ffmpeg / Whisper / yt-dlp 等子进程，任务标记为 cancelled，并删除未完成的文件",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"task_id": map[string]interface{}{
+						"type":        "string",
+						"description": "任务 ID",
+					},
+					"task_type": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"download", "transcribe", "pipeline", "collection"},
+						"description": "任务类型",
+					},
+					"keep_partial": map[string]interface{}{
+						"type":        "boolean",
+						"description": "保留已下载的分片，之后可以用 retry_task 从断点继续（默认 false，删除未完成的文件）",
+					},
+				},
+				"required": []string{"task_id", "task_type"},
+			},
+		},
+		{
+			"name":        "retry_task",
+			"description": "重新执行失败、取消或因服务重启被中断的任务（HLS 下载会从已完成的分片继续）",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"task_id": map[string]interface{}{
+						"type":        "string",
+						"description": "任务 ID",
+					},
+				},
+				"required": []string{"task_id"},
+			},
+		},
+		{
+			"name":        "pause_task",
+			"description": "暂停排队中或正在执行的下载任务，保留已下载的分片，之后用 resume_task 继续",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"task_id": map[string]interface{}{
+						"type":        "string",
+						"description": "下载任务 ID",
+					},
+				},
+				"required": []string{"task_id"},
+			},
+		},
+		{
+			"name":        "resume_task",
+			"description": "继续暂停的下载任务（HLS 下载从已完成的分片继续）",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"task_id": map[string]interface{}{
+						"type":        "string",
+						"description": "下载任务 ID",
+					},
+				},
+				"required": []string{"task_id"},
+			},
+		},
+		{
+			"name":        "delete_task",
+			"description": "删除已结束的任务记录，可选同时删除下载的视频或转录生成的音频和文本",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"task_id": map[string]interface{}{
+						"type":        "string",
+						"description": "任务 ID",
+					},
+					"delete_files": map[string]interface{}{
+						"type":        "boolean",
+						"description": "是否同时删除输出文件（默认 false）",
+					},
+				},
+				"required": []string{"task_id"},
+			},
+		},
+		{
+			"name":        "list_tasks",
+			"description": "列出任务（下载、转录、流水线和合集），按创建时间倒序分页，支持按类型、状态、创建时间筛选和搜索",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"type": map[string]interface{}{
+						"type":        "string",
+						"description": "任务类型 download / transcribe / pipeline / collection，多个用逗号分隔",
+					},
+					"status": map[string]interface{}{
+						"type":        "string",
+						"description": "任务状态，例如 completed、failed、downloading，多个用逗号分隔",
+					},
+					"since": map[string]interface{}{
+						"type":        "string",
+						"description": "创建时间不早于，2006-01-02 或 RFC 3339",
+					},
+					"until": map[string]interface{}{
+						"type":        "string",
+						"description": "创建时间早于，2006-01-02（包含当天）或 RFC 3339",
+					},
+					"search": map[string]interface{}{
+						"type":        "string",
+						"description": "在链接、文件路径和任务 ID 中搜索，多个关键词用空格分隔",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "每页数量（默认 50，最多 500）",
+					},
+					"offset": map[string]interface{}{
+						"type":        "integer",
+						"description": "跳过的任务数，使用上一页返回的 next_offset",
+					},
+				},
+			},
+		},
+		{
+			"name":        "export_history",
+			"description": "导出任务历史报告（CSV 或 JSON）：每个任务的类型、状态、链接、输出文件、文件大小、创建和结束时间、耗时和错误，以及各状态的任务数和文件总大小，可用于跟踪大型合集的归档进度",
+			"inputSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"format": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"json", "csv"},
+						"description": "导出格式（默认 json）",
+					},
+					"output_path": map[string]interface{}{
+						"type":        "string",
+						"maxLength":   toolschema.MaxPathLength,
+						"description": "保存报告的文件路径；不填时直接返回报告内容",
+					},
+					"type": map[string]interface{}{
+						"type":        "string",
+						"description": "任务类型 download / transcribe / pipeline / collection，多个用逗号分隔",
+					},
+					"status": map[string]interface{}{
+						"type":        "string",
+						"description": "任务状态，例如 completed、failed，多个用逗号分隔",
+					},
+					"since": map[string]interface{}{
+						"type":        "string",
+						"description": "创建时间不早于，2006-01-02 或 RFC 3339",
+					},
+					"until": map[string]interface{}{
+						"type":        "string",
+						"description": "创建时间早于，2006-01-02（包含当天）或 RFC 3339",
+					},
+					"search": map[string]interface{}{
+						"type":        "string",
+						"description": "在链接、文件路径和任务 ID 中搜索，多个关键词用空格分隔",
+					},
+				},
+			},
+		},
+	})
+}
+
+// Schema 返回工具的 inputSchema，工具不存在时返回 false
+func (t *Tools) Schema(name string) (map[string]interface{}, bool) {
+	for _, tool := range t.List() {
+		if tool["name"] == name {
+			schema, _ := tool["inputSchema"].(map[string]interface{})
+			return schema, true
+		}
+	}
+	return nil, false
+}
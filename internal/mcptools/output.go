@@ -0,0 +1,249 @@
+package mcptools
+
+// 工具结果除了 content 中的 JSON 文本，还作为 structuredContent 返回（MCP 2025-06-18），
+// 结构由 tools/list 中的 outputSchema 声明，客户端不必从文本中提取任务 ID 和路径。
+// outputSchema 只列出稳定的字段，结果中可能还有其他字段
+
+func stringProp(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "string", "description": description}
+}
+
+func integerProp(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "integer", "description": description}
+}
+
+func booleanProp(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "boolean", "description": description}
+}
+
+func arrayProp(description string, items map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"type": "array", "description": description, "items": items}
+}
+
+func objectSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// merge 返回 a 和 b 合并后的属性，b 中的同名属性优先
+func merge(a, b map[string]interface{}) map[string]interface{} {
+	m := make(map[string]interface{}, len(a)+len(b))
+	for k, v := range a {
+		m[k] = v
+	}
+	for k, v := range b {
+		m[k] = v
+	}
+	return m
+}
+
+// createdProperties 创建任务的工具共有的字段；幂等键重复提交时只返回这些字段
+var createdProperties = map[string]interface{}{
+	"task_id":   stringProp("任务 ID，用于 get_progress、cancel_task 等"),
+	"task_type": stringProp("任务类型 download / transcribe / pipeline / collection / batch，get_progress 的 task_type"),
+	"replayed":  booleanProp("幂等键已创建过任务，返回的是原来的任务"),
+	"status":    stringProp("给用户看的说明"),
+}
+
+// taskProperties get_progress 等返回的任务的主要字段，各类任务还有各自的其他字段
+var taskProperties = map[string]interface{}{
+	"id":            stringProp("任务 ID"),
+	"status":        stringProp("任务状态，例如 pending、downloading、completed、failed、cancelled、paused"),
+	"percentage":    integerProp("进度 0–100"),
+	"speed_history": arrayProp("最近约一分钟每秒的下载速度（字节/秒），最早的在前", map[string]interface{}{"type": "integer"}),
+	"avg_speed":     integerProp("下载的平均速度（字节/秒，下载结束后）"),
+	"peak_speed":    integerProp("下载速度的峰值（字节/秒）"),
+	"file_path":     stringProp("下载的视频文件（下载和流水线任务完成后）"),
+	"txt_path":      stringProp("转录文本（转录和流水线任务完成后）"),
+	"download_id":   stringProp("流水线的下载子任务"),
+	"error":         stringProp("失败原因"),
+	"error_code":    stringProp("错误码，例如 VIDEO_UNAVAILABLE"),
+}
+
+var taskSchema = objectSchema(taskProperties, "id", "status", "percentage")
+
+var subscriptionSchema = objectSchema(map[string]interface{}{
+	"id":   stringProp("订阅 ID"),
+	"url":  stringProp("订阅的用户或专栏链接"),
+	"type": stringProp("订阅类型"),
+}, "id", "url")
+
+// outputSchemas 每个工具 structuredContent 的 JSON Schema
+var outputSchemas = map[string]map[string]interface{}{
+	"download_video": objectSchema(merge(createdProperties, map[string]interface{}{
+		"cached":     booleanProp("该视频已下载过，直接返回已有文件"),
+		"file_path":  stringProp("已有文件的路径（cached 为 true 时）"),
+		"file_name":  stringProp("已有文件的文件名（cached 为 true 时）"),
+		"output_dir": stringProp("输出目录"),
+		"backend":    stringProp("使用的下载后端"),
+		"filename":   stringProp("输出文件名，按标题命名时下载开始后才确定，见 get_progress 的 file_name"),
+	}), "task_id"),
+	"transcribe_video": objectSchema(merge(createdProperties, map[string]interface{}{
+		"model":           stringProp("Whisper 模型"),
+		"output_dir":      stringProp("输出目录"),
+		"output_filename": stringProp("输出文件名（不含扩展名）"),
+		"mp3_path":        stringProp("提取的音频"),
+		"txt_path":        stringProp("转录文本"),
+		"srt_path":        stringProp("字幕（diarize 时）"),
+		"json_path":       stringProp("结构化结果（diarize 时）"),
+		"summary_path":    stringProp("摘要（summarize 时）"),
+	}), "task_id"),
+	"transcribe_directory": objectSchema(merge(createdProperties, map[string]interface{}{
+		"task_ids": arrayProp("为每个视频创建的转录任务", stringProp("转录任务 ID")),
+		"skipped":  map[string]interface{}{"description": "跳过的文件及原因"},
+	}), "task_id"),
+	"import_video": objectSchema(map[string]interface{}{
+		"download_id": stringProp("导入后的下载任务 ID"),
+		"file_path":   stringProp("视频文件"),
+		"cached":      booleanProp("该文件已导入过"),
+		"status":      stringProp("给用户看的说明"),
+	}, "download_id", "file_path"),
+	"extract_clip": objectSchema(merge(createdProperties, map[string]interface{}{
+		"download_id": stringProp("片段任务 ID（下载任务），与 task_id 相同"),
+		"source":      stringProp("剪辑的源文件"),
+	}), "task_id"),
+	"download_and_transcribe": objectSchema(merge(createdProperties, map[string]interface{}{
+		"download_id": stringProp("下载子任务 ID"),
+		"output_dir":  stringProp("输出目录"),
+	}), "task_id"),
+	"download_answer": objectSchema(map[string]interface{}{
+		"title":         stringProp("标题"),
+		"markdown_path": stringProp("保存的 Markdown 文件"),
+		"images":        map[string]interface{}{"description": "图片地址，下载到本地时为本地路径"},
+		"videos": arrayProp("嵌入的视频", objectSchema(map[string]interface{}{
+			"url":     stringProp("视频链接"),
+			"title":   stringProp("视频标题"),
+			"task_id": stringProp("为视频创建的下载任务"),
+			"error":   stringProp("创建下载任务失败的原因"),
+		}, "url")),
+	}, "markdown_path", "videos"),
+	"download_comments": objectSchema(map[string]interface{}{
+		"json_path":     stringProp("保存的 JSON 文件"),
+		"markdown_path": stringProp("保存的 Markdown 文件"),
+		"count":         integerProp("保存的评论数"),
+		"total":         integerProp("评论总数"),
+	}, "json_path", "markdown_path", "count"),
+	"download_collection": objectSchema(createdProperties, "task_id"),
+	"create_subscription": objectSchema(map[string]interface{}{
+		"subscription": subscriptionSchema,
+		"status":       stringProp("给用户看的说明"),
+	}, "subscription"),
+	"list_subscriptions": objectSchema(map[string]interface{}{
+		"subscriptions": arrayProp("所有订阅，最新创建的在前", subscriptionSchema),
+	}, "subscriptions"),
+	"update_subscription": subscriptionSchema,
+	"delete_subscription": objectSchema(map[string]interface{}{
+		"subscription_id": stringProp("删除的订阅"),
+		"status":          stringProp("给用户看的说明"),
+	}, "subscription_id"),
+	"check_subscription": objectSchema(map[string]interface{}{
+		"subscription_id": stringProp("订阅 ID"),
+		"found":           integerProp("列出的视频数"),
+		"task_ids":        arrayProp("为新视频创建的下载任务", stringProp("任务 ID")),
+		"errors":          arrayProp("创建任务失败的视频及原因", map[string]interface{}{"type": "string"}),
+	}, "subscription_id", "found", "task_ids"),
+	"get_video_info": objectSchema(map[string]interface{}{
+		"video": objectSchema(map[string]interface{}{
+			"url":   stringProp("视频链接"),
+			"title": stringProp("标题"),
+		}, "url"),
+		"selected": objectSchema(map[string]interface{}{
+			"quality": stringProp("清晰度"),
+			"format":  stringProp("格式 mp4 / m3u8"),
+			"url":     stringProp("视频流地址"),
+		}, "quality", "url"),
+	}, "video", "selected"),
+	"auth_status": objectSchema(map[string]interface{}{
+		"configured": booleanProp("已保存 cookies"),
+		"logged_in":  booleanProp("cookies 中有未过期的 z_c0"),
+		"count":      integerProp("cookies 数量"),
+	}, "configured", "logged_in"),
+	"summarize_transcript": objectSchema(map[string]interface{}{
+		"summary_path": stringProp("摘要文件"),
+		"summary":      stringProp("摘要内容"),
+	}, "summary_path", "summary"),
+	"extract_highlights": objectSchema(map[string]interface{}{
+		"task_id":    stringProp("转录或流水线任务"),
+		"video_path": stringProp("视频文件"),
+		"highlights": arrayProp("精彩片段", objectSchema(map[string]interface{}{
+			"start":     map[string]interface{}{"type": "number", "description": "开始时间（秒）"},
+			"end":       map[string]interface{}{"type": "number", "description": "结束时间（秒）"},
+			"text":      stringProp("片段的文稿"),
+			"clip_path": stringProp("剪出的视频（clip 时）"),
+		}, "start", "end", "text")),
+		"path":     stringProp("保存的 JSON 文件"),
+		"clip_dir": stringProp("剪出的视频所在目录（clip 时）"),
+	}, "task_id", "highlights"),
+	"search_transcripts": objectSchema(map[string]interface{}{
+		"query": stringProp("搜索的关键词"),
+		"results": arrayProp("按转录任务分组的结果，最新的转录在前", objectSchema(map[string]interface{}{
+			"task_id":     stringProp("转录任务"),
+			"pipeline_id": stringProp("所属的流水线任务"),
+			"video_path":  stringProp("视频文件"),
+			"txt_path":    stringProp("转录文本"),
+			"match_count": integerProp("匹配的段落数"),
+		}, "task_id", "match_count")),
+	}, "query", "results"),
+	"semantic_search": objectSchema(map[string]interface{}{
+		"query": stringProp("搜索的内容"),
+		"results": arrayProp("按转录任务分组的结果，最相关的在前", objectSchema(map[string]interface{}{
+			"task_id":     stringProp("转录任务"),
+			"pipeline_id": stringProp("所属的流水线任务"),
+			"video_path":  stringProp("视频文件"),
+			"txt_path":    stringProp("转录文本"),
+			"matches": arrayProp("最相关的片段", objectSchema(map[string]interface{}{
+				"start": map[string]interface{}{"type": "number", "description": "开始时间（秒）"},
+				"end":   map[string]interface{}{"type": "number", "description": "结束时间（秒）"},
+				"text":  stringProp("片段的文稿"),
+				"score": map[string]interface{}{"type": "number", "description": "相似度，越大越相关"},
+			}, "start", "end", "text")),
+			"match_count": integerProp("找到的片段数"),
+		}, "task_id", "match_count")),
+	}, "query", "results"),
+	"get_progress": taskSchema,
+	"cancel_task": objectSchema(map[string]interface{}{
+		"message": stringProp("给用户看的说明"),
+		"task":    taskSchema,
+	}, "message", "task"),
+	"retry_task":  taskSchema,
+	"pause_task":  taskSchema,
+	"resume_task": taskSchema,
+	"delete_task": objectSchema(map[string]interface{}{
+		"task_id":       stringProp("删除的任务"),
+		"deleted":       booleanProp("已删除"),
+		"files_deleted": booleanProp("同时删除了输出文件"),
+	}, "task_id", "deleted"),
+	"list_tasks": objectSchema(map[string]interface{}{
+		"downloads":   arrayProp("下载任务", objectSchema(taskProperties, "id", "status")),
+		"transcribes": arrayProp("转录任务", objectSchema(taskProperties, "id", "status")),
+		"pipelines":   arrayProp("流水线任务", objectSchema(taskProperties, "id", "status")),
+		"collections": arrayProp("合集任务", objectSchema(taskProperties, "id", "status")),
+		"total":       integerProp("符合条件的任务总数（所有页）"),
+		"limit":       integerProp("每页的数量"),
+		"offset":      integerProp("本页的 offset"),
+		"next_offset": integerProp("下一页的 offset，没有下一页时省略"),
+	}, "downloads", "transcribes", "pipelines", "collections", "total"),
+	"export_history": objectSchema(map[string]interface{}{
+		"total":      integerProp("导出的任务数"),
+		"total_size": integerProp("输出文件的总大小（字节）"),
+		"statuses":   map[string]interface{}{"type": "object", "description": "各状态的任务数"},
+		"tasks":      map[string]interface{}{"type": "array", "description": "导出的任务（json 格式且没有 output_path 时）"},
+		"format":     stringProp("导出格式（csv 或写入文件时）"),
+		"content":    stringProp("CSV 内容（csv 格式且没有 output_path 时）"),
+		"path":       stringProp("写入的文件（指定 output_path 时）"),
+	}, "total"),
+}
+
+// withOutputSchemas 给工具定义加上 outputSchema
+func withOutputSchemas(tools []map[string]interface{}) []map[string]interface{} {
+	for _, tool := range tools {
+		name, _ := tool["name"].(string)
+		if schema, ok := outputSchemas[name]; ok {
+			tool["outputSchema"] = schema
+		}
+	}
+	return tools
+}
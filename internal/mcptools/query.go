@@ -0,0 +1,209 @@
+package mcptools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/summarizer"
+	"zhihu-downloader/internal/tasks"
+	"zhihu-downloader/internal/zhihu"
+)
+
+func (t *Tools) getVideoInfo(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	url, _ := args["url"].(string)
+	if url == "" {
+		return nil, fmt.Errorf("url 必填")
+	}
+	videoQuality, _ := args["quality"].(string)
+	if videoQuality == "" {
+		videoQuality = t.quality
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	video, err := zhihu.FetchVideo(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	selected, err := video.Select(videoQuality)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"video":    video,
+		"selected": selected,
+	}, nil
+}
+
+func (t *Tools) authStatus(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	status, err := t.vault.Status()
+	if err != nil {
+		return nil, err
+	}
+	maxAge := downloader.SessionMaxAge
+	if refresh, _ := args["refresh"].(bool); refresh {
+		maxAge = 0
+	}
+	if session, ok := downloader.LoginSession(ctx, maxAge); ok {
+		status.Session = &session
+	}
+	return status, nil
+}
+
+func (t *Tools) summarizeTranscript(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	taskID, _ := args["task_id"].(string)
+	txtPath, _ := args["txt_path"].(string)
+
+	var (
+		path string
+		err  error
+	)
+	switch {
+	case taskID != "":
+		if err := t.CheckAccess(ctx, taskID); err != nil {
+			return nil, err
+		}
+		path, err = t.manager.Summarize(ctx, taskID)
+	case txtPath != "":
+		txtPath = tasks.ExpandHome(txtPath)
+		if err := t.checkPath(ctx, txtPath); err != nil {
+			return nil, err
+		}
+		if err := t.manager.CheckPath(txtPath); err != nil {
+			return nil, err
+		}
+		path, err = summarizer.Summarize(ctx, txtPath)
+	default:
+		return nil, fmt.Errorf("task_id 和 txt_path 至少指定一个")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	content, _ := os.ReadFile(path)
+	return map[string]interface{}{
+		"summary_path": path,
+		"summary":      string(content),
+	}, nil
+}
+
+func (t *Tools) extractHighlights(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	taskID, _ := args["task_id"].(string)
+	llm, _ := args["llm"].(bool)
+	limit, _ := args["limit"].(float64)
+	clip, _ := args["clip"].(bool)
+	reencode, _ := args["reencode"].(bool)
+	var padding *float64
+	if v, ok := args["padding"].(float64); ok {
+		padding = &v
+	}
+	if err := t.CheckAccess(ctx, taskID); err != nil {
+		return nil, err
+	}
+
+	return t.manager.Highlights(ctx, taskID, tasks.HighlightRequest{
+		Keywords: stringList(args, "keywords"),
+		LLM:      llm,
+		Limit:    int(limit),
+		Padding:  padding,
+		Clip:     clip,
+		Reencode: reencode,
+	})
+}
+
+func (t *Tools) searchTranscripts(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	query, _ := args["query"].(string)
+	limit, _ := args["limit"].(float64)
+	if limit < 0 {
+		return nil, fmt.Errorf("limit 不能为负数")
+	}
+	if limit == 0 {
+		limit = 20
+	}
+
+	results, err := t.manager.SearchTranscripts(query, searchWorkspace(ctx), min(int(limit), 100))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"query":   query,
+		"results": results,
+	}, nil
+}
+
+func (t *Tools) semanticSearch(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	query, _ := args["query"].(string)
+	limit, _ := args["limit"].(float64)
+	if limit < 0 {
+		return nil, fmt.Errorf("limit 不能为负数")
+	}
+	if limit == 0 {
+		limit = 20
+	}
+
+	results, err := t.manager.SemanticSearch(ctx, query, searchWorkspace(ctx), min(int(limit), 100))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"query":   query,
+		"results": results,
+	}, nil
+}
+
+func (t *Tools) exportHistory(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	format, _ := args["format"].(string)
+	format, err := tasks.ParseExportFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	q, err := tasks.ParseQuery(func(name string) string {
+		s, _ := args[name].(string)
+		return s
+	})
+	if err != nil {
+		return nil, err
+	}
+	if restricted(ctx) {
+		q.Workspace = workspaceName(ctx)
+	}
+	report := t.manager.Export(q)
+
+	if outputPath, _ := args["output_path"].(string); outputPath != "" {
+		path := tasks.ExpandHome(outputPath)
+		if err := t.checkPath(ctx, path); err != nil {
+			return nil, err
+		}
+		if err := t.manager.CheckPath(path); err != nil {
+			return nil, err
+		}
+		if err := report.WriteFile(path, format); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"path":       path,
+			"format":     format,
+			"total":      report.Total,
+			"statuses":   report.Statuses,
+			"total_size": report.TotalSize,
+		}, nil
+	}
+	if format == "csv" {
+		var buf strings.Builder
+		if err := report.WriteCSV(&buf); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"format":  format,
+			"total":   report.Total,
+			"content": strings.TrimPrefix(buf.String(), "\ufeff"),
+		}, nil
+	}
+	return report, nil
+}
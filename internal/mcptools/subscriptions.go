@@ -0,0 +1,109 @@
+package mcptools
+
+import (
+	"context"
+
+	"zhihu-downloader/internal/tasks"
+)
+
+// applySubscriptionInput 把参数中提供的字段写入 s，未提供的字段保持不变
+func applySubscriptionInput(args map[string]interface{}, s *tasks.Subscription) {
+	if v, ok := args["url"].(string); ok {
+		s.URL = v
+	}
+	if v, ok := args["quality"].(string); ok {
+		s.Quality = v
+	}
+	if v, ok := args["output_dir"].(string); ok {
+		s.OutputDir = v
+	}
+	if v, ok := args["backend"].(string); ok {
+		s.Backend = v
+	}
+	if v, ok := args["filename_template"].(string); ok {
+		s.FilenameTemplate = v
+	}
+	if v, ok := args["limit"].(float64); ok {
+		s.Limit = int(v)
+	}
+	if v, ok := args["interval_minutes"].(float64); ok {
+		s.IntervalMinutes = int(v)
+	}
+	if v, ok := args["transcribe"].(bool); ok {
+		s.Transcribe = v
+	}
+	if v, ok := args["language"].(string); ok {
+		s.Language = v
+	}
+	if v, ok := args["model"].(string); ok {
+		s.Model = v
+	}
+	if v, ok := args["skip_existing"].(bool); ok {
+		s.SkipExisting = v
+	}
+	if v, ok := args["enabled"].(bool); ok {
+		s.Enabled = v
+	}
+}
+
+func (t *Tools) createSubscription(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	s := tasks.Subscription{Quality: t.quality, Enabled: true, Workspace: workspaceName(ctx)}
+	applySubscriptionInput(args, &s)
+	subscription, err := t.manager.CreateSubscription(s)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"subscription": subscription,
+		"status":       "已创建订阅，由网关服务定期检查，也可以使用 check_subscription 立即检查",
+	}, nil
+}
+
+func (t *Tools) listSubscriptions(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	list, err := t.manager.Subscriptions()
+	if err != nil {
+		return nil, err
+	}
+	if restricted(ctx) {
+		visible := list[:0]
+		for _, s := range list {
+			if s.Workspace == workspaceName(ctx) {
+				visible = append(visible, s)
+			}
+		}
+		list = visible
+	}
+	return map[string]interface{}{"subscriptions": list}, nil
+}
+
+func (t *Tools) updateSubscription(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	id, _ := args["subscription_id"].(string)
+	if err := t.CheckAccess(ctx, id); err != nil {
+		return nil, err
+	}
+	s, err := t.manager.Subscription(id)
+	if err != nil {
+		return nil, err
+	}
+	applySubscriptionInput(args, s)
+	return t.manager.UpdateSubscription(id, *s)
+}
+
+func (t *Tools) deleteSubscription(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	id, _ := args["subscription_id"].(string)
+	if err := t.CheckAccess(ctx, id); err != nil {
+		return nil, err
+	}
+	if err := t.manager.DeleteSubscription(id); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"subscription_id": id, "status": "已删除订阅，已创建的任务不受影响"}, nil
+}
+
+func (t *Tools) checkSubscription(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	id, _ := args["subscription_id"].(string)
+	if err := t.CheckAccess(ctx, id); err != nil {
+		return nil, err
+	}
+	return t.manager.CheckSubscription(ctx, id)
+}
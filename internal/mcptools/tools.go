@@ -0,0 +1,95 @@
+// Package mcptools HTTP MCP 服务（cmd/mcp-server）和 stdio MCP 服务（cmd/mcp-stdio-server）共用的工具：
+// 工具定义（inputSchema、outputSchema）和调用。两个服务只负责各自的传输方式和错误格式
+package mcptools
+
+import (
+	"context"
+
+	"zhihu-downloader/internal/auth"
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/tasks"
+)
+
+// Options 创建 Tools 的参数
+type Options struct {
+	Manager *tasks.Manager
+	// Vault 保存网关上传的登录 cookies，auth_status 使用
+	Vault *auth.Vault
+	// Quality 未指定清晰度时的默认清晰度
+	Quality string
+}
+
+// Tools MCP 工具的定义和实现
+type Tools struct {
+	manager *tasks.Manager
+	vault   *auth.Vault
+	quality string
+}
+
+// New 创建 Tools
+func New(opts Options) *Tools {
+	return &Tools{manager: opts.Manager, vault: opts.Vault, quality: opts.Quality}
+}
+
+// Call 调用工具，args 需要先按 Schema 校验。工具不存在时返回 NotFound
+func (t *Tools) Call(ctx context.Context, name string, args map[string]interface{}) (interface{}, error) {
+	switch name {
+	case "download_video":
+		return t.downloadVideo(ctx, args)
+	case "transcribe_video":
+		return t.transcribeVideo(ctx, args)
+	case "transcribe_directory":
+		return t.transcribeDirectory(ctx, args)
+	case "import_video":
+		return t.importVideo(ctx, args)
+	case "extract_clip":
+		return t.extractClip(ctx, args)
+	case "download_and_transcribe":
+		return t.downloadAndTranscribe(ctx, args)
+	case "download_answer":
+		return t.downloadAnswer(ctx, args)
+	case "download_comments":
+		return t.downloadComments(ctx, args)
+	case "download_collection":
+		return t.downloadCollection(ctx, args)
+	case "create_subscription":
+		return t.createSubscription(ctx, args)
+	case "list_subscriptions":
+		return t.listSubscriptions(ctx, args)
+	case "update_subscription":
+		return t.updateSubscription(ctx, args)
+	case "delete_subscription":
+		return t.deleteSubscription(ctx, args)
+	case "check_subscription":
+		return t.checkSubscription(ctx, args)
+	case "get_video_info":
+		return t.getVideoInfo(ctx, args)
+	case "auth_status":
+		return t.authStatus(ctx, args)
+	case "summarize_transcript":
+		return t.summarizeTranscript(ctx, args)
+	case "extract_highlights":
+		return t.extractHighlights(ctx, args)
+	case "search_transcripts":
+		return t.searchTranscripts(ctx, args)
+	case "semantic_search":
+		return t.semanticSearch(ctx, args)
+	case "get_progress":
+		return t.getProgress(ctx, args)
+	case "cancel_task":
+		return t.cancelTask(ctx, args)
+	case "retry_task":
+		return t.retryTask(ctx, args)
+	case "pause_task":
+		return t.pauseTask(ctx, args)
+	case "resume_task":
+		return t.resumeTask(ctx, args)
+	case "delete_task":
+		return t.deleteTask(ctx, args)
+	case "list_tasks":
+		return t.listTasks(ctx, args)
+	case "export_history":
+		return t.exportHistory(ctx, args)
+	}
+	return nil, errcode.Newf(errcode.NotFound, "未知的工具: %s", name)
+}
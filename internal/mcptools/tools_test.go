@@ -0,0 +1,132 @@
+package mcptools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/tasks"
+	"zhihu-downloader/internal/toolschema"
+)
+
+func TestControlTools(t *testing.T) {
+	tools := New(Options{Manager: tasks.NewManager(tasks.WithOutputDir(t.TempDir())), Quality: "hd"})
+
+	// 两个 MCP 服务都通过 List 和 Call 提供控制任务的工具
+	tests := []struct {
+		name    string
+		args    map[string]interface{}
+		wantErr bool
+	}{
+		{"list_tasks", map[string]interface{}{}, false},
+		{"get_progress", map[string]interface{}{"task_id": "dl-1", "task_type": "download"}, true},
+		{"cancel_task", map[string]interface{}{"task_id": "dl-1", "task_type": "download"}, true},
+		{"retry_task", map[string]interface{}{"task_id": "dl-1"}, true},
+		{"pause_task", map[string]interface{}{"task_id": "dl-1"}, true},
+		{"resume_task", map[string]interface{}{"task_id": "dl-1"}, true},
+		{"delete_task", map[string]interface{}{"task_id": "dl-1"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if schema, ok := tools.Schema(tt.name); ok {
+				if err := toolschema.Validate(schema, tt.args); err != nil {
+					t.Fatalf("参数不符合 inputSchema: %v", err)
+				}
+			} else {
+				t.Fatalf("工具列表中没有 %s", tt.name)
+			}
+			// 任务 dl-1 不存在
+			if _, err := tools.Call(context.Background(), tt.name, tt.args); (err != nil) != tt.wantErr {
+				t.Fatalf("错误 = %v，wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := tools.Call(context.Background(), "no_such_tool", nil); errcode.Of(err, errcode.Internal) != errcode.NotFound {
+		t.Fatalf("未知工具的错误 = %v，应为 NOT_FOUND", err)
+	}
+}
+
+func TestListMatchesCall(t *testing.T) {
+	tools := New(Options{Quality: "fhd"})
+	for _, tool := range tools.List() {
+		name, _ := tool["name"].(string)
+		if _, ok := tool["inputSchema"].(map[string]interface{}); !ok {
+			t.Errorf("%s 没有 inputSchema", name)
+		}
+		if _, ok := outputSchemas[name]; !ok {
+			t.Errorf("%s 没有 outputSchema", name)
+		}
+	}
+}
+
+func TestWorkspaceAccess(t *testing.T) {
+	dir := t.TempDir()
+	m := tasks.NewManager(tasks.WithOutputDir(dir), tasks.WithWorkspaces([]tasks.Workspace{{Name: "alice"}, {Name: "bob"}}))
+	tools := New(Options{Manager: m, Quality: "hd"})
+	alice := WithWorkspace(context.Background(), "alice", false)
+	bob := WithWorkspace(context.Background(), "bob", false)
+	admin := WithWorkspace(context.Background(), "admin", true)
+
+	if err := os.MkdirAll(filepath.Join(dir, "alice"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "alice", "a.mp4"), []byte("video"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := tools.Call(alice, "import_video", map[string]interface{}{"file_path": "a.mp4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	taskID := imported.(map[string]interface{})["download_id"].(string)
+	if _, err := tools.Call(alice, "create_subscription", map[string]interface{}{"url": "https://www.zhihu.com/people/alice/zvideos"}); err != nil {
+		t.Fatal(err)
+	}
+	list, _ := tools.Call(alice, "list_subscriptions", nil)
+	subscriptionID := list.(map[string]interface{})["subscriptions"].([]*tasks.Subscription)[0].ID
+
+	// 其他工作区的任务和订阅按不存在处理，管理员和所属工作区可以访问
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		tool   string
+		args   map[string]interface{}
+		wantOK bool
+	}{
+		{"所属工作区查询进度", alice, "get_progress", map[string]interface{}{"task_id": taskID, "task_type": "download"}, true},
+		{"其他工作区查询进度", bob, "get_progress", map[string]interface{}{"task_id": taskID, "task_type": "download"}, false},
+		{"管理员查询进度", admin, "get_progress", map[string]interface{}{"task_id": taskID, "task_type": "download"}, true},
+		{"其他工作区删除任务", bob, "delete_task", map[string]interface{}{"task_id": taskID}, false},
+		{"其他工作区删除订阅", bob, "delete_subscription", map[string]interface{}{"subscription_id": subscriptionID}, false},
+		{"其他工作区的路径", bob, "import_video", map[string]interface{}{"file_path": filepath.Join(dir, "alice", "a.mp4")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tools.Call(tt.ctx, tt.tool, tt.args)
+			if (err == nil) != tt.wantOK {
+				t.Fatalf("错误 = %v，wantOK = %v", err, tt.wantOK)
+			}
+		})
+	}
+
+	// 列表只返回本工作区的内容
+	for _, tt := range []struct {
+		name string
+		ctx  context.Context
+		want int
+	}{{"alice", alice, 1}, {"bob", bob, 0}, {"admin", admin, 1}} {
+		got, err := tools.Call(tt.ctx, "list_tasks", map[string]interface{}{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := got.(tasks.Page).Total; n != tt.want {
+			t.Errorf("%s 的任务数 = %d，应为 %d", tt.name, n, tt.want)
+		}
+		subs, _ := tools.Call(tt.ctx, "list_subscriptions", nil)
+		if n := len(subs.(map[string]interface{})["subscriptions"].([]*tasks.Subscription)); n != tt.want {
+			t.Errorf("%s 的订阅数 = %d，应为 %d", tt.name, n, tt.want)
+		}
+	}
+}
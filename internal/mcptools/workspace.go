@@ -0,0 +1,63 @@
+package mcptools
+
+import (
+	"context"
+
+	"zhihu-downloader/internal/errcode"
+)
+
+// workspaceKey context 中保存调用方工作区的键
+type workspaceKey struct{}
+
+// caller 调用工具的工作区
+type caller struct {
+	name  string
+	admin bool
+}
+
+// WithWorkspace 返回带有调用方工作区的 context：创建的任务属于这个工作区，非管理员只能访问本工作区的任务。
+// 没有调用 WithWorkspace 时（stdio 服务、没有配置工作区）不做任何限制
+func WithWorkspace(ctx context.Context, name string, admin bool) context.Context {
+	return context.WithValue(ctx, workspaceKey{}, caller{name: name, admin: admin})
+}
+
+// workspaceName 新建任务所属的工作区，没有工作区时为空
+func workspaceName(ctx context.Context) string {
+	c, _ := ctx.Value(workspaceKey{}).(caller)
+	return c.name
+}
+
+// restricted 调用方是否只能访问自己工作区的任务和文件
+func restricted(ctx context.Context) bool {
+	c, ok := ctx.Value(workspaceKey{}).(caller)
+	return ok && !c.admin
+}
+
+// searchWorkspace 只能访问自己工作区时只搜索、列出本工作区的任务
+func searchWorkspace(ctx context.Context) string {
+	if restricted(ctx) {
+		return workspaceName(ctx)
+	}
+	return ""
+}
+
+// Visible 调用方能否看到属于 workspace 的任务：不受限制（管理员、没有配置工作区）或是本工作区的任务
+func Visible(ctx context.Context, workspace string) bool {
+	return !restricted(ctx) || workspace == workspaceName(ctx)
+}
+
+// CheckAccess 其他工作区的任务、订阅按不存在处理；ID 不存在时返回 nil，由各工具返回错误
+func (t *Tools) CheckAccess(ctx context.Context, id string) error {
+	if owner, ok := t.manager.TaskWorkspace(id); ok && !Visible(ctx, owner) {
+		return errcode.New(errcode.NotFound, "任务不存在")
+	}
+	return nil
+}
+
+// checkPath 只能访问自己工作区时，读写的文件必须在工作区目录中
+func (t *Tools) checkPath(ctx context.Context, path string) error {
+	if !restricted(ctx) {
+		return nil
+	}
+	return t.manager.CheckWorkspacePath(workspaceName(ctx), path)
+}
@@ -0,0 +1,39 @@
+package media
+
+import (
+	"sync"
+
+	"zhihu-downloader/internal/proc"
+)
+
+var (
+	binMu       sync.RWMutex
+	ffmpegPath  = "ffmpeg"
+	ffprobePath = "ffprobe"
+)
+
+// SetBinaries 设置 ffmpeg / ffprobe 可执行文件路径，空字符串保持默认（从 PATH 查找）
+func SetBinaries(ffmpeg, ffprobe string) {
+	binMu.Lock()
+	defer binMu.Unlock()
+	if ffmpeg != "" {
+		ffmpegPath = ffmpeg
+	}
+	if ffprobe != "" {
+		ffprobePath = ffprobe
+	}
+}
+
+// FFmpeg 返回 ffmpeg 可执行文件路径，未配置路径时在 PATH 和 Homebrew 等常见安装目录中查找
+func FFmpeg() string {
+	binMu.RLock()
+	defer binMu.RUnlock()
+	return proc.Resolve(ffmpegPath)
+}
+
+// FFprobe 返回 ffprobe 可执行文件路径，查找方式与 FFmpeg 相同
+func FFprobe() string {
+	binMu.RLock()
+	defer binMu.RUnlock()
+	return proc.Resolve(ffprobePath)
+}
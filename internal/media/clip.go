@@ -0,0 +1,46 @@
+package media
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ClipFormats 截取片段支持的输出格式，m4a 和 mp3 只保留音频
+var ClipFormats = []string{"mp4", "mkv", "m4a", "mp3"}
+
+// Cut 截取 video 中 start 到 end 秒的片段保存为 out，按 out 的扩展名决定格式（见 ClipFormats）。
+// reencode 为 false 时直接复制音视频流，速度快但开头会对齐到之前的关键帧；为 true 时重新编码为 H.264 / AAC，起止时间准确。
+// mp3 总是重新编码
+func Cut(ctx context.Context, video, out string, start, end float64, reencode bool, onProgress func(int)) error {
+	args := []string{"-y", "-ss", seconds(start), "-i", video, "-t", seconds(end - start)}
+	switch strings.ToLower(filepath.Ext(out)) {
+	case ".mp3":
+		args = append(args, "-vn", "-c:a", "libmp3lame", "-q:a", "2")
+	case ".m4a":
+		args = append(args, "-vn")
+		if reencode {
+			args = append(args, "-c:a", "aac", "-b:a", "192k")
+		} else {
+			args = append(args, "-c:a", "copy", "-avoid_negative_ts", "make_zero")
+		}
+		args = append(args, "-movflags", "+faststart")
+	default:
+		if reencode {
+			codec, _ := RemuxArgs(RemuxReencode)
+			args = append(args, codec...)
+		} else {
+			args = append(args, "-c", "copy", "-avoid_negative_ts", "make_zero")
+		}
+		if !strings.EqualFold(filepath.Ext(out), ".mkv") {
+			args = append(args, "-movflags", "+faststart")
+		}
+	}
+	args = append(args, out)
+	return RunFFmpegProgress(ctx, "截取片段", end-start, onProgress, args...)
+}
+
+func seconds(s float64) string {
+	return strconv.FormatFloat(s, 'f', 3, 64)
+}
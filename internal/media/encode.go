@@ -0,0 +1,176 @@
+package media
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 重新编码视频（例如烧录字幕）时的硬件加速方式
+const (
+	// HWAccelNone 软件编码（libx264）
+	HWAccelNone = "none"
+	// HWAccelVideoToolbox macOS 的 VideoToolbox 解码和编码
+	HWAccelVideoToolbox = "videotoolbox"
+	// HWAccelNVENC NVIDIA 显卡的 CUDA 解码和 NVENC 编码
+	HWAccelNVENC = "nvenc"
+	// HWAccelVAAPI Linux 的 VA-API 编码（Intel / AMD 显卡）
+	HWAccelVAAPI = "vaapi"
+)
+
+// DefaultVAAPIDevice 默认的 VA-API 设备
+const DefaultVAAPIDevice = "/dev/dri/renderD128"
+
+var (
+	encodeMu    sync.RWMutex
+	hwaccel     = HWAccelNone
+	vaapiDevice = DefaultVAAPIDevice
+)
+
+// CheckHWAccel 检查硬件加速方式，空字符串表示使用全局设置
+func CheckHWAccel(mode string) error {
+	switch mode {
+	case "", HWAccelNone, HWAccelVideoToolbox, HWAccelNVENC, HWAccelVAAPI:
+		return nil
+	}
+	return fmt.Errorf("无效的硬件加速方式: %s（可选 none、videotoolbox、nvenc、vaapi）", mode)
+}
+
+// SetHWAccel 设置默认的硬件加速方式和 VA-API 设备，空字符串保持默认
+func SetHWAccel(mode, device string) {
+	encodeMu.Lock()
+	defer encodeMu.Unlock()
+	if mode != "" {
+		hwaccel = mode
+	}
+	if device != "" {
+		vaapiDevice = device
+	}
+}
+
+// EncodeOptions 生成视频文件时的 ffmpeg 选项
+type EncodeOptions struct {
+	// HWAccel 重新编码时的硬件加速方式，为空时使用 SetHWAccel 设置的默认值；只复制流时不使用
+	HWAccel string
+	// Args 追加在输出文件前的 ffmpeg 输出选项，需要先用 ValidateFFmpegArgs 检查
+	Args []string
+}
+
+// 重新编码的目标视频编码
+const (
+	CodecH264 = "h264"
+	CodecH265 = "h265"
+	CodecAV1  = "av1"
+)
+
+// videoEncoder 重新编码的参数：input 放在 -i 前，filter 追加在滤镜链末尾（把画面上传到显卡），output 放在输出文件前。
+// crf 为软件编码的 CRF（硬件编码换算为对应的质量参数），0 时使用各编码的默认值；preset 只用于软件编码，为空时使用编码器默认值
+func videoEncoder(codec, mode string, crf int, preset string) (input []string, filter string, output []string, err error) {
+	encodeMu.RLock()
+	if mode == "" {
+		mode = hwaccel
+	}
+	device := vaapiDevice
+	encodeMu.RUnlock()
+
+	if crf == 0 {
+		crf = defaultCRF[codec]
+	}
+	quality := strconv.Itoa(crf)
+	// Apple 设备只能播放标记为 hvc1 的 H.265
+	var tag []string
+	if codec == CodecH265 {
+		tag = []string{"-tag:v", "hvc1"}
+	}
+
+	switch mode {
+	case HWAccelVideoToolbox:
+		name := map[string]string{CodecH264: "h264_videotoolbox", CodecH265: "hevc_videotoolbox"}[codec]
+		if name == "" {
+			return nil, "", nil, fmt.Errorf("videotoolbox 不支持 %s 编码", codec)
+		}
+		// -q:v 为 1–100，越大质量越高；按 CRF 粗略换算
+		q := strconv.Itoa(max(1, min(100, 100-crf*3/2)))
+		return []string{"-hwaccel", "videotoolbox"}, "", append([]string{"-c:v", name, "-q:v", q}, tag...), nil
+	case HWAccelNVENC:
+		name := map[string]string{CodecH264: "h264_nvenc", CodecH265: "hevc_nvenc", CodecAV1: "av1_nvenc"}[codec]
+		return []string{"-hwaccel", "cuda"}, "", append([]string{"-c:v", name, "-preset", "p4", "-cq", quality}, tag...), nil
+	case HWAccelVAAPI:
+		name := map[string]string{CodecH264: "h264_vaapi", CodecH265: "hevc_vaapi", CodecAV1: "av1_vaapi"}[codec]
+		// 软件解码后在滤镜中上传到显卡，subtitles 等滤镜仍然可以使用
+		return []string{"-vaapi_device", device}, ",format=nv12,hwupload", append([]string{"-c:v", name, "-qp", quality}, tag...), nil
+	}
+
+	output = []string{"-c:v", softwareEncoder[codec]}
+	if preset != "" {
+		output = append(output, "-preset", preset)
+	}
+	return nil, "", append(append(output, "-crf", quality), tag...), nil
+}
+
+var (
+	softwareEncoder = map[string]string{CodecH264: "libx264", CodecH265: "libx265", CodecAV1: "libsvtav1"}
+	defaultCRF      = map[string]int{CodecH264: 23, CodecH265: 28, CodecAV1: 35}
+)
+
+var (
+	ffmpegInt     = regexp.MustCompile(`^\d{1,6}$`)
+	ffmpegBitrate = regexp.MustCompile(`^\d{1,6}(\.\d{1,3})?[kKmM]?$`)
+)
+
+// ffmpegOptions 允许通过 ffmpeg_args 传入的输出选项及其取值格式。
+// -i、-f、-filter_complex、-dump_attachment 等选项可以读写任意文件或执行协议请求，一律不允许
+var ffmpegOptions = map[string]*regexp.Regexp{
+	"-preset":                regexp.MustCompile(`^(ultrafast|superfast|veryfast|faster|fast|medium|slow|slower|veryslow|p[1-7])$`),
+	"-tune":                  regexp.MustCompile(`^(film|animation|grain|stillimage|fastdecode|zerolatency|hq|ll|ull)$`),
+	"-crf":                   regexp.MustCompile(`^\d{1,2}$`),
+	"-cq":                    regexp.MustCompile(`^\d{1,2}$`),
+	"-qp":                    regexp.MustCompile(`^\d{1,2}$`),
+	"-q:v":                   regexp.MustCompile(`^\d{1,3}$`),
+	"-profile:v":             regexp.MustCompile(`^(baseline|main|high|high10|main10)$`),
+	"-level":                 regexp.MustCompile(`^\d(\.\d)?$`),
+	"-pix_fmt":               regexp.MustCompile(`^(yuv420p|yuv420p10le|nv12|p010le)$`),
+	"-b:v":                   ffmpegBitrate,
+	"-maxrate":               ffmpegBitrate,
+	"-bufsize":               ffmpegBitrate,
+	"-b:a":                   ffmpegBitrate,
+	"-g":                     ffmpegInt,
+	"-threads":               ffmpegInt,
+	"-ac":                    ffmpegInt,
+	"-ar":                    ffmpegInt,
+	"-max_muxing_queue_size": ffmpegInt,
+	"-movflags":              regexp.MustCompile(`^[+-]?(faststart|frag_keyframe|empty_moov|default_base_moof)([+-](faststart|frag_keyframe|empty_moov|default_base_moof))*$`),
+	"-avoid_negative_ts":     regexp.MustCompile(`^(auto|make_zero|make_non_negative|disabled)$`),
+	"-map_metadata":          regexp.MustCompile(`^(-1|0)$`),
+}
+
+// ValidateFFmpegArgs 检查用户传入的 ffmpeg 参数：只允许白名单中的输出选项，每个选项后面必须跟一个符合格式的值
+func ValidateFFmpegArgs(args []string) error {
+	for i := 0; i < len(args); i += 2 {
+		opt := args[i]
+		re, ok := ffmpegOptions[opt]
+		if !ok {
+			return fmt.Errorf("不支持的 ffmpeg 参数: %s（可用 %s）", opt, strings.Join(FFmpegOptionNames(), " "))
+		}
+		if i+1 >= len(args) {
+			return fmt.Errorf("ffmpeg 参数 %s 缺少取值", opt)
+		}
+		if !re.MatchString(args[i+1]) {
+			return fmt.Errorf("ffmpeg 参数 %s 的取值无效: %s", opt, args[i+1])
+		}
+	}
+	return nil
+}
+
+// FFmpegOptionNames 返回允许传入的 ffmpeg 选项，按字母排序
+func FFmpegOptionNames() []string {
+	names := make([]string, 0, len(ffmpegOptions))
+	for name := range ffmpegOptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
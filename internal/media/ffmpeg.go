@@ -0,0 +1,52 @@
+package media
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/proc"
+)
+
+// RunFFmpegProgress 执行 ffmpeg（最后一个参数为输出文件），读取 -progress 输出的 out_time，
+// 按与 duration（秒）的比例回调进度（0–99）。失败或没有输出时删除输出文件
+func RunFFmpegProgress(ctx context.Context, action string, duration float64, onProgress func(int), args ...string) error {
+	out := args[len(args)-1]
+	args = append([]string{"-hide_banner", "-loglevel", "error", "-progress", "pipe:1", "-nostats"}, args...)
+	cmd := proc.Command(ctx, FFmpeg(), args...)
+	stdout, _ := cmd.StdoutPipe()
+	stderr := logging.Writer(ctx, "ffmpeg")
+	defer stderr.Close()
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("启动 ffmpeg 失败: %v", err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok || key != "out_time_us" || duration <= 0 || onProgress == nil {
+			continue
+		}
+		if us, err := strconv.ParseInt(value, 10, 64); err == nil && us > 0 {
+			onProgress(min(99, int(float64(us)/1e6/duration*100)))
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		os.Remove(out)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%s失败: %v", action, err)
+	}
+	if info, err := os.Stat(out); err != nil || info.Size() == 0 {
+		os.Remove(out)
+		return fmt.Errorf("%s失败: ffmpeg 没有输出文件", action)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package media
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// WriteMetadata 把 tags 写入媒体文件的元数据（MP4 的 iTunes 元数据原子、MP3 的 ID3v2 标签等），
+// 音视频流直接复制，不重新编码。先写入同目录的临时文件，成功后替换原文件；值为空的标签跳过。
+// MP4 只支持 title、artist、album、date、comment、description、genre 等常用名称，其他名称会被忽略
+func WriteMetadata(ctx context.Context, path string, tags map[string]string) error {
+	keys := make([]string, 0, len(tags))
+	for k, v := range tags {
+		if strings.TrimSpace(v) != "" {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+
+	ext := filepath.Ext(path)
+	tmp := strings.TrimSuffix(path, ext) + ".tagging" + ext
+	args := []string{"-y", "-i", path, "-map", "0", "-c", "copy"}
+	for _, k := range keys {
+		args = append(args, "-metadata", k+"="+tags[k])
+	}
+	if strings.EqualFold(ext, ".mp3") {
+		// ID3v2.3 的兼容性比默认的 2.4 好（Windows 资源管理器等只认 2.3）
+		args = append(args, "-id3v2_version", "3")
+	}
+	args = append(args, tmp)
+	if err := RunFFmpegProgress(ctx, "写入元数据", 0, nil, args...); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
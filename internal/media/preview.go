@@ -0,0 +1,79 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/proc"
+)
+
+// 预览图尺寸（宽度，高度按比例）
+const (
+	thumbnailWidth = 640
+	spriteWidth    = 160
+)
+
+// ThumbnailPath 返回视频封面路径：video.mp4 → video.jpg
+func ThumbnailPath(video string) string {
+	return strings.TrimSuffix(video, filepath.Ext(video)) + ".jpg"
+}
+
+// SpritePath 返回预览图路径：video.mp4 → video.sprite.jpg
+func SpritePath(video string) string {
+	return strings.TrimSuffix(video, filepath.Ext(video)) + ".sprite.jpg"
+}
+
+// Thumbnail 截取视频 10% 处（最多 30 秒处）的一帧作为封面，duration 未知时取第 1 秒
+func Thumbnail(ctx context.Context, video, out string, duration float64) error {
+	at := 1.0
+	if duration > 0 {
+		at = math.Min(duration*0.1, 30)
+	}
+	return runFFmpeg(ctx, "生成封面",
+		"-y", "-ss", fmt.Sprintf("%.2f", at), "-i", video,
+		"-frames:v", "1", "-vf", fmt.Sprintf("scale=%d:-2", thumbnailWidth), "-q:v", "3", out)
+}
+
+// Sprite 在整个视频中均匀截取 frames 帧，按行拼成一张预览图（每行 SpriteColumns(frames) 帧），
+// 播放器拖动进度条时可以显示对应位置的画面
+func Sprite(ctx context.Context, video, out string, duration float64, frames int) error {
+	if duration <= 0 {
+		return fmt.Errorf("无法获取视频时长")
+	}
+	cols := SpriteColumns(frames)
+	rows := (frames + cols - 1) / cols
+	return runFFmpeg(ctx, "生成预览图",
+		"-y", "-i", video,
+		"-vf", fmt.Sprintf("fps=%f,scale=%d:-2,tile=%dx%d", float64(frames)/duration, spriteWidth, cols, rows),
+		"-frames:v", "1", "-q:v", "5", out)
+}
+
+// SpriteColumns 预览图每行的帧数
+func SpriteColumns(frames int) int {
+	return max(int(math.Ceil(math.Sqrt(float64(frames)))), 1)
+}
+
+func runFFmpeg(ctx context.Context, action string, args ...string) error {
+	out := args[len(args)-1]
+	cmd := proc.Command(ctx, FFmpeg(), append([]string{"-hide_banner", "-loglevel", "error"}, args...)...)
+	stderr := logging.Writer(ctx, "ffmpeg")
+	defer stderr.Close()
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(out)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%s失败: %v", action, err)
+	}
+	if info, err := os.Stat(out); err != nil || info.Size() == 0 {
+		os.Remove(out)
+		return fmt.Errorf("%s失败: ffmpeg 没有输出图片", action)
+	}
+	return nil
+}
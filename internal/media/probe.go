@@ -0,0 +1,33 @@
+// Package media 封装 ffmpeg/ffprobe 的通用调用。
+package media
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"zhihu-downloader/internal/proc"
+)
+
+// Duration 用 ffprobe 获取媒体时长（秒），失败返回 0
+func Duration(input string) float64 {
+	return DurationWithHeaders(input, "")
+}
+
+// DurationWithHeaders 同 Duration，input 为网络地址时附带请求头（ffmpeg -headers 的格式，每行以 \r\n 结尾）
+func DurationWithHeaders(input, headers string) float64 {
+	args := []string{"-v", "error", "-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1"}
+	if headers != "" {
+		args = append(args, "-headers", headers)
+	}
+	cmd := proc.Command(context.Background(), FFprobe(), append(args, input)...)
+	output, err := cmd.Output()
+	if err != nil {
+		return 0
+	}
+	duration, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+	if err != nil {
+		return 0
+	}
+	return duration
+}
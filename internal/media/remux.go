@@ -0,0 +1,73 @@
+package media
+
+import "strings"
+
+// 把下载的视频写入输出文件的方式。默认直接复制音视频流，失败时按失败原因换一种方式重试
+const (
+	// RemuxCopy 直接复制到 MP4（-c copy）
+	RemuxCopy = "copy"
+	// RemuxMKV 直接复制到 MKV，用于 MP4 不支持的编码
+	RemuxMKV = "copy_mkv"
+	// RemuxReencode 重新编码为 H.264 + AAC 的 MP4，用于时间戳或码流有问题、无法直接复制的视频
+	RemuxReencode = "reencode"
+)
+
+// ffmpeg 流复制失败的输出（小写）
+var (
+	// 输出容器不支持视频或音频的编码
+	containerFailures = []string{
+		"could not find tag for codec",
+		"not currently supported in container",
+		"incompatible with output codec",
+		"could not write header",
+	}
+	// 时间戳或码流有问题，换容器也无法复制
+	streamFailures = []string{
+		"malformed aac bitstream",
+		"error applying bitstream filter",
+		"non monotonically increasing dts",
+		"timestamps are unset",
+		"pts has no value",
+		"invalid nal unit size",
+		"invalid packet",
+	}
+)
+
+// NextRemux 按 strategy 失败时 ffmpeg 的输出判断下一种方式：编码与容器不匹配时依次尝试
+// MKV 和重新编码，时间戳或码流有问题时重新编码。其他原因（网络错误、输入无效等）返回空字符串，不再重试
+func NextRemux(strategy, output string) string {
+	output = strings.ToLower(output)
+	switch {
+	case strategy == RemuxReencode:
+		return ""
+	case containsAny(output, containerFailures):
+		if strategy == RemuxCopy {
+			return RemuxMKV
+		}
+		return RemuxReencode
+	case containsAny(output, streamFailures):
+		return RemuxReencode
+	}
+	return ""
+}
+
+// RemuxArgs 返回 strategy 放在输入文件之后的编码参数和输出文件的扩展名
+func RemuxArgs(strategy string) (args []string, ext string) {
+	switch strategy {
+	case RemuxMKV:
+		return []string{"-c", "copy"}, ".mkv"
+	case RemuxReencode:
+		return []string{"-c:v", "libx264", "-preset", "veryfast", "-crf", "20", "-pix_fmt", "yuv420p",
+			"-c:a", "aac", "-b:a", "192k"}, ".mp4"
+	}
+	return []string{"-c", "copy"}, ".mp4"
+}
+
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// 字幕处理方式
+const (
+	// SubtitleNone 不处理字幕
+	SubtitleNone = "none"
+	// SubtitleMux 把字幕作为软字幕轨道封装进 MP4，不重新编码，播放器中可以开关
+	SubtitleMux = "mux"
+	// SubtitleBurn 把字幕烧录进画面，需要重新编码视频，任何播放器都能看到
+	SubtitleBurn = "burn"
+)
+
+// CheckSubtitleMode 检查字幕处理方式，空字符串等同于 none
+func CheckSubtitleMode(mode string) error {
+	switch mode {
+	case "", SubtitleNone, SubtitleMux, SubtitleBurn:
+		return nil
+	}
+	return fmt.Errorf("无效的字幕处理方式: %s（可选 none、mux、burn）", mode)
+}
+
+// SubtitledPath 返回带字幕视频的路径：video.mp4 → video.subtitled.mp4
+func SubtitledPath(video string) string {
+	return strings.TrimSuffix(video, filepath.Ext(video)) + ".subtitled.mp4"
+}
+
+// MuxSubtitles 把 SRT 字幕作为 mov_text 字幕轨道封装进视频，音视频流直接复制。
+// language 为 Whisper 语言代码（例如 zh），写入字幕轨道的语言标记；只复制流，不使用 opts.HWAccel
+func MuxSubtitles(ctx context.Context, video, srt, out, language string, opts EncodeOptions, onProgress func(int)) error {
+	args := []string{"-y", "-i", video, "-i", srt,
+		"-map", "0:v", "-map", "0:a?", "-map", "1:s",
+		"-c", "copy", "-c:s", "mov_text",
+		"-metadata:s:s:0", "language=" + subtitleLanguage(language),
+		"-movflags", "+faststart"}
+	args = append(append(args, opts.Args...), out)
+	return RunFFmpegProgress(ctx, "封装字幕", Duration(video), onProgress, args...)
+}
+
+// BurnSubtitles 用 subtitles 滤镜把 SRT 字幕烧录进画面，视频按 opts.HWAccel 重新编码为 H.264，音频直接复制
+func BurnSubtitles(ctx context.Context, video, srt, out string, opts EncodeOptions, onProgress func(int)) error {
+	input, filter, output, err := videoEncoder(CodecH264, opts.HWAccel, 20, "veryfast")
+	if err != nil {
+		return err
+	}
+	args := append([]string{"-y"}, input...)
+	args = append(args, "-i", video, "-vf", "subtitles=filename="+escapeFilterValue(srt)+filter)
+	args = append(args, output...)
+	args = append(args, "-c:a", "copy", "-movflags", "+faststart")
+	args = append(append(args, opts.Args...), out)
+	return RunFFmpegProgress(ctx, "烧录字幕", Duration(video), onProgress, args...)
+}
+
+// escapeFilterValue 转义滤镜参数值：滤镜参数和滤镜图两层都需要转义，
+// 路径中的 \ ' : 以及 [ ] , ; 才不会被当作分隔符
+func escapeFilterValue(s string) string {
+	return escapeChars(escapeChars(s, `\':`), `\'[],;`)
+}
+
+func escapeChars(s, chars string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(chars, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// subtitleLanguage 把 Whisper 语言代码转换为 MP4 使用的 ISO 639-2 代码，未知时为 und
+func subtitleLanguage(language string) string {
+	switch language {
+	case "zh":
+		return "chi"
+	case "en":
+		return "eng"
+	case "ja":
+		return "jpn"
+	case "ko":
+		return "kor"
+	case "fr":
+		return "fre"
+	case "de":
+		return "ger"
+	case "es":
+		return "spa"
+	case "ru":
+		return "rus"
+	}
+	return "und"
+}
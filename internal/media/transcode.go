@@ -0,0 +1,104 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"zhihu-downloader/internal/proc"
+)
+
+// TranscodeOptions 下载后重新编码视频，Codec 为空时不转码
+type TranscodeOptions struct {
+	// Codec 目标编码 h264 / h265 / av1
+	Codec string
+	// MaxHeight 分辨率上限（画面高度），超过时按比例缩小，0 表示保持原分辨率
+	MaxHeight int
+	// CRF 画质，越小越清晰、文件越大，0 表示使用默认值（h264 23、h265 28、av1 35）
+	CRF int
+}
+
+// Enabled 是否需要转码
+func (o TranscodeOptions) Enabled() bool {
+	return o.Codec != ""
+}
+
+// CheckTranscode 检查转码参数
+func CheckTranscode(o TranscodeOptions) error {
+	switch o.Codec {
+	case "":
+		if o.MaxHeight != 0 || o.CRF != 0 {
+			return fmt.Errorf("指定分辨率上限或 CRF 时需要同时指定转码的编码（h264、h265、av1）")
+		}
+		return nil
+	case CodecH264, CodecH265, CodecAV1:
+	default:
+		return fmt.Errorf("无效的转码编码: %s（可选 h264、h265、av1）", o.Codec)
+	}
+	if o.MaxHeight != 0 && (o.MaxHeight < 144 || o.MaxHeight > 4320) {
+		return fmt.Errorf("分辨率上限应在 144–4320 之间: %d", o.MaxHeight)
+	}
+	if o.CRF < 0 || o.CRF > 63 {
+		return fmt.Errorf("CRF 应在 0–63 之间: %d", o.CRF)
+	}
+	return nil
+}
+
+// Transcode 按 o 重新编码视频，音频直接复制。先写入同目录的 <文件名>.transcoding.mp4，成功后替换原文件，
+// 原文件不是 MP4 时删除原文件，返回新文件的路径。opts 为硬件加速方式和追加的 ffmpeg 参数
+func Transcode(ctx context.Context, path string, o TranscodeOptions, opts EncodeOptions, onProgress func(int)) (string, error) {
+	input, filter, output, err := videoEncoder(o.Codec, opts.HWAccel, o.CRF, "medium")
+	if err != nil {
+		return "", err
+	}
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	tmp := base + ".transcoding.mp4"
+
+	args := append([]string{"-y"}, input...)
+	args = append(args, "-i", path, "-map", "0:v:0", "-map", "0:a?", "-map_metadata", "0")
+	var vf string
+	if o.MaxHeight > 0 {
+		// 只缩小不放大，宽度按比例取偶数
+		vf = fmt.Sprintf("scale=-2:'min(ih,%d)'", o.MaxHeight)
+	}
+	if vf += filter; vf != "" {
+		args = append(args, "-vf", strings.TrimPrefix(vf, ","))
+	}
+	args = append(args, output...)
+	args = append(args, "-c:a", "copy", "-movflags", "+faststart")
+	args = append(append(args, opts.Args...), tmp)
+	if err := RunFFmpegProgress(ctx, "转码", Duration(path), onProgress, args...); err != nil {
+		return "", err
+	}
+
+	out := base + ".mp4"
+	if err := os.Rename(tmp, out); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if out != path {
+		os.Remove(path)
+	}
+	return out, nil
+}
+
+// Resolution 用 ffprobe 获取视频的分辨率，例如 1920x1080，失败返回空字符串
+func Resolution(path string) string {
+	cmd := proc.Command(context.Background(), FFprobe(), "-v", "error", "-select_streams", "v:0",
+		"-show_entries", "stream=width,height", "-of", "csv=s=x:p=0", path)
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	w, h, ok := strings.Cut(strings.TrimSpace(string(output)), "x")
+	if _, err := strconv.Atoi(w); !ok || err != nil {
+		return ""
+	}
+	if _, err := strconv.Atoi(h); err != nil {
+		return ""
+	}
+	return w + "x" + h
+}
@@ -0,0 +1,331 @@
+// Package notify 在任务完成或失败时发送通知：Telegram 机器人、Bark、Server酱，
+// 或把任务信息以 JSON POST 到 webhook。
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/errcode"
+)
+
+// 通知渠道类型
+const (
+	TypeTelegram   = "telegram"
+	TypeBark       = "bark"
+	TypeServerChan = "serverchan"
+	TypeWebhook    = "webhook"
+)
+
+// 触发通知的任务状态，与 tasks.Status 的取值相同
+const (
+	EventCompleted = "completed"
+	EventFailed    = "failed"
+	EventCancelled = "cancelled"
+)
+
+// None 请求中指定 notify: ["none"] 时不发送通知
+const None = "none"
+
+// 各渠道的默认服务地址
+const (
+	defaultTelegramURL   = "https://api.telegram.org"
+	defaultBarkURL       = "https://api.day.app"
+	defaultServerChanURL = "https://sctapi.ftqq.com"
+)
+
+// Channel 通知渠道
+type Channel struct {
+	// Name 渠道名称，请求中用名称选择渠道
+	Name string
+	Type string
+	// Events 触发通知的任务状态 completed / failed / cancelled，为空时为 completed 和 failed
+	Events []string
+	// URL webhook 的地址；Telegram、Bark、Server酱为服务地址，为空时使用官方地址（自建服务或代理时设置）
+	URL string
+	// Token Telegram 机器人的令牌，ChatID 接收消息的会话
+	Token  string
+	ChatID string
+	// Key Bark 的设备密钥或 Server酱的 SendKey
+	Key string
+
+	// requested 请求中直接指定的 webhook 地址（不是配置的渠道），
+	// 按下载链接的规则检查，并通过检查连接地址的客户端发送，不能访问内网地址
+	requested bool
+}
+
+// Config 通知配置
+type Config struct {
+	// Channels 默认的通知渠道，请求中没有指定时使用全部渠道
+	Channels []Channel
+	// LinkBase REST 网关的外部地址，例如 https://vps.example.com:5124，
+	// 设置后通知中附上文件链接 <link_base>/api/files/<任务 ID>/download
+	LinkBase string
+}
+
+// Message 一条任务通知，webhook 收到的是它的 JSON
+type Message struct {
+	TaskID string `json:"task_id"`
+	// Kind 任务类型 download / transcribe / pipeline / collection
+	Kind   string `json:"kind"`
+	Status string `json:"status"`
+	// Title 通知标题，例如「下载完成」
+	Title string `json:"title"`
+	// Name 视频文件名、合集名称或链接
+	Name string `json:"name"`
+	// Duration 任务耗时（秒）
+	Duration int `json:"duration"`
+	// Size 输出文件大小（字节），未知时为 0
+	Size int64 `json:"size,omitempty"`
+	// Detail 其他说明，例如合集的完成数量
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Link 获取文件的链接：远程存储地址，或网关的文件下载地址
+	Link string    `json:"link,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+var (
+	configMu sync.RWMutex
+	config   Config
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// SetConfig 设置通知配置
+func SetConfig(c Config) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	config = c
+}
+
+func currentConfig() Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config
+}
+
+// Validate 检查通知配置
+func Validate(c Config) error {
+	if c.LinkBase != "" {
+		if err := checkURL(c.LinkBase); err != nil {
+			return fmt.Errorf("link_base %v", err)
+		}
+	}
+	names := map[string]bool{}
+	for _, ch := range c.Channels {
+		if ch.Name == "" || ch.Name == None || strings.Contains(ch.Name, "://") {
+			return fmt.Errorf("通知渠道名称无效: %q", ch.Name)
+		}
+		if names[ch.Name] {
+			return fmt.Errorf("通知渠道名称重复: %s", ch.Name)
+		}
+		names[ch.Name] = true
+		if err := validateChannel(ch); err != nil {
+			return fmt.Errorf("通知渠道 %s: %v", ch.Name, err)
+		}
+	}
+	return nil
+}
+
+func validateChannel(ch Channel) error {
+	for _, e := range ch.Events {
+		if e != EventCompleted && e != EventFailed && e != EventCancelled {
+			return fmt.Errorf("events 只能包含 completed、failed、cancelled: %s", e)
+		}
+	}
+	if ch.URL != "" {
+		if err := checkURL(ch.URL); err != nil {
+			return fmt.Errorf("url %v", err)
+		}
+	}
+	switch ch.Type {
+	case TypeTelegram:
+		if ch.Token == "" || ch.ChatID == "" {
+			return fmt.Errorf("telegram 需要设置 token 和 chat_id")
+		}
+	case TypeBark, TypeServerChan:
+		if ch.Key == "" {
+			return fmt.Errorf("%s 需要设置 key", ch.Type)
+		}
+	case TypeWebhook:
+		if ch.URL == "" {
+			return fmt.Errorf("webhook 需要设置 url")
+		}
+	default:
+		return fmt.Errorf("不支持的类型: %s（可选 telegram、bark、serverchan、webhook）", ch.Type)
+	}
+	return nil
+}
+
+func checkURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("不是有效的 http(s) 地址: %s", s)
+	}
+	return nil
+}
+
+// CheckTargets 检查请求中指定的通知目标：配置的渠道名称、http(s) webhook 地址或 none
+func CheckTargets(targets []string) error {
+	_, err := Resolve(targets)
+	return err
+}
+
+// Resolve 返回通知目标对应的渠道。targets 为空时返回配置的所有渠道，包含 none 时返回空；
+// http(s) 地址作为 webhook，使用默认的触发状态。webhook 地址由调用方提供，
+// 与下载链接一样只能是 download.allowed_hosts 允许的公网地址（downloader.CheckURL）
+func Resolve(targets []string) ([]Channel, error) {
+	c := currentConfig()
+	if len(targets) == 0 {
+		return c.Channels, nil
+	}
+	if slices.Contains(targets, None) {
+		return nil, nil
+	}
+	var list []Channel
+	for _, target := range targets {
+		if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+			if err := checkURL(target); err != nil {
+				return nil, fmt.Errorf("notify %v", err)
+			}
+			if err := downloader.CheckURL(context.Background(), target); err != nil {
+				return nil, errcode.Newf(errcode.InvalidArgument, "notify 不能发送到 %s：%s（请使用配置的通知渠道）", target, errcode.Describe(err, errcode.URLInvalid).Message)
+			}
+			list = append(list, Channel{Name: target, Type: TypeWebhook, URL: target, requested: true})
+			continue
+		}
+		i := slices.IndexFunc(c.Channels, func(ch Channel) bool { return ch.Name == target })
+		if i < 0 {
+			return nil, fmt.Errorf("通知渠道不存在: %s", target)
+		}
+		list = append(list, c.Channels[i])
+	}
+	return list, nil
+}
+
+// Wants 渠道是否需要在任务变为 status 时通知
+func (ch Channel) Wants(status string) bool {
+	if len(ch.Events) == 0 {
+		return status == EventCompleted || status == EventFailed
+	}
+	return slices.Contains(ch.Events, status)
+}
+
+// FileLink 网关中任务文件的下载地址，没有配置 LinkBase 时返回空
+func FileLink(taskID string) string {
+	base := currentConfig().LinkBase
+	if base == "" {
+		return ""
+	}
+	return strings.TrimSuffix(base, "/") + "/api/files/" + url.PathEscape(taskID) + "/download"
+}
+
+// Text 通知正文，每项一行
+func (m Message) Text() string {
+	lines := []string{m.Name}
+	lines = append(lines, "任务："+m.TaskID)
+	lines = append(lines, "耗时："+(time.Duration(m.Duration)*time.Second).String())
+	if m.Size > 0 {
+		lines = append(lines, fmt.Sprintf("大小：%.1f MB", float64(m.Size)/1024/1024))
+	}
+	if m.Detail != "" {
+		lines = append(lines, m.Detail)
+	}
+	if m.Error != "" {
+		lines = append(lines, "错误："+m.Error)
+	}
+	if m.Link != "" {
+		lines = append(lines, "文件："+m.Link)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Send 通过渠道发送通知
+func Send(ctx context.Context, ch Channel, m Message) error {
+	if m.Time.IsZero() {
+		m.Time = time.Now()
+	}
+	switch ch.Type {
+	case TypeTelegram:
+		return postJSON(ctx, ch.client(), serviceURL(ch, defaultTelegramURL)+"/bot"+ch.Token+"/sendMessage", map[string]interface{}{
+			"chat_id":                  ch.ChatID,
+			"text":                     m.Title + "\n" + m.Text(),
+			"disable_web_page_preview": true,
+		})
+	case TypeBark:
+		body := map[string]interface{}{
+			"device_key": ch.Key,
+			"title":      m.Title,
+			"body":       m.Text(),
+			"group":      "zhihu-downloader",
+		}
+		if m.Link != "" {
+			body["url"] = m.Link
+		}
+		return postJSON(ctx, ch.client(), serviceURL(ch, defaultBarkURL)+"/push", body)
+	case TypeServerChan:
+		// desp 为 Markdown，两个换行才分段
+		form := url.Values{"title": {m.Title}, "desp": {strings.ReplaceAll(m.Text(), "\n", "\n\n")}}
+		return post(ctx, ch.client(), serviceURL(ch, defaultServerChanURL)+"/"+url.PathEscape(ch.Key)+".send",
+			"application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	case TypeWebhook:
+		return postJSON(ctx, ch.client(), ch.URL, m)
+	}
+	return fmt.Errorf("不支持的通知类型: %s", ch.Type)
+}
+
+func serviceURL(ch Channel, fallback string) string {
+	if ch.URL == "" {
+		return fallback
+	}
+	return strings.TrimSuffix(ch.URL, "/")
+}
+
+// client 发送通知使用的客户端：请求中指定的 webhook 不能访问内网地址，配置的渠道可以（例如局域网中的服务）
+func (ch Channel) client() *http.Client {
+	if ch.requested {
+		return downloader.GuardedClient(httpClient.Timeout)
+	}
+	return httpClient
+}
+
+func postJSON(ctx context.Context, client *http.Client, target string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return post(ctx, client, target, "application/json", bytes.NewReader(data))
+}
+
+func post(ctx context.Context, client *http.Client, target, contentType string, body io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := client.Do(req)
+	if err != nil {
+		// 错误信息中的地址可能包含令牌（Telegram 的 bot<token>），只保留原因
+		if uerr, ok := err.(*url.Error); ok {
+			err = uerr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
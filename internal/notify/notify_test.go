@@ -0,0 +1,39 @@
+package notify
+
+import (
+	"testing"
+
+	"zhihu-downloader/internal/downloader"
+)
+
+func TestResolve(t *testing.T) {
+	SetConfig(Config{Channels: []Channel{{Name: "hook", Type: TypeWebhook, URL: "http://192.168.1.2/hook"}}})
+	downloader.SetURLPolicy(downloader.URLPolicy{AllowedHosts: []string{"hooks.example.com"}})
+	defer SetConfig(Config{})
+	defer downloader.SetURLPolicy(downloader.URLPolicy{})
+
+	tests := []struct {
+		name      string
+		targets   []string
+		wantErr   bool
+		requested bool
+	}{
+		{"配置的渠道（内网地址）", []string{"hook"}, false, false},
+		{"不存在的渠道", []string{"other"}, true, false},
+		{"允许的 webhook", []string{"https://hooks.example.com/x"}, false, true},
+		{"没有允许的域名", []string{"https://evil.example.org/x"}, true, false},
+		{"内网地址", []string{"http://127.0.0.1:8080/x"}, true, false},
+		{"云服务元数据地址", []string{"http://169.254.169.254/latest"}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := Resolve(tt.targets)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve(%v) 错误 = %v，应%s出错", tt.targets, err, map[bool]string{true: "", false: "不"}[tt.wantErr])
+			}
+			if err == nil && list[0].requested != tt.requested {
+				t.Fatalf("requested = %v，应为 %v", list[0].requested, tt.requested)
+			}
+		})
+	}
+}
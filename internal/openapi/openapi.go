@@ -0,0 +1,178 @@
+// Package openapi 根据 Go 类型生成 OpenAPI 3 文档中的 JSON Schema，
+// 请求和响应的结构与接口实际序列化的 JSON 一致，字段变化时文档自动更新。
+package openapi
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Version 生成的文档使用的 OpenAPI 版本
+const Version = "3.0.3"
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// Generator 把 Go 类型转换为 JSON Schema。有名字的结构体放入 components/schemas，
+// 其他地方用 $ref 引用；匿名结构体直接展开
+type Generator struct {
+	components map[string]any
+	names      map[reflect.Type]string
+	enums      map[reflect.Type][]string
+}
+
+// NewGenerator 创建一个 Generator
+func NewGenerator() *Generator {
+	return &Generator{
+		components: map[string]any{},
+		names:      map[reflect.Type]string{},
+		enums:      map[reflect.Type][]string{},
+	}
+}
+
+// Enum 声明字符串类型的可选值，例如 tasks.Status，生成的 Schema 带有 enum
+func (g *Generator) Enum(v any, values ...string) {
+	g.enums[reflect.TypeOf(v)] = values
+}
+
+// Schema 返回 v 的类型对应的 Schema，v 为 nil 时返回 nil
+func (g *Generator) Schema(v any) map[string]any {
+	if v == nil {
+		return nil
+	}
+	return g.schema(reflect.TypeOf(v))
+}
+
+// Components 目前为止生成的所有有名字的结构体，用作文档的 components/schemas
+func (g *Generator) Components() map[string]any {
+	return g.components
+}
+
+func (g *Generator) schema(t reflect.Type) map[string]any {
+	if values, ok := g.enums[t]; ok {
+		return map[string]any{"type": "string", "enum": values}
+	}
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]any{"type": "integer", "format": "int64", "description": "纳秒"}
+	case rawMessageType:
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := g.schema(t.Elem())
+		if _, ref := s["$ref"]; ref {
+			// OpenAPI 3.0 中 $ref 旁边的属性会被忽略，可以为空的引用用 allOf 包一层
+			return map[string]any{"allOf": []any{s}, "nullable": true}
+		}
+		s["nullable"] = true
+		return s
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + g.component(t)}
+	}
+	// interface{} 等任意类型
+	return map[string]any{}
+}
+
+// component 把有名字的结构体放入 components，返回它的名字。不同包中的同名类型加上包名区分，
+// 例如 auth.Status 和 transcriber.Status
+func (g *Generator) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	// 生成代码时用作类型名，首字母大写
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	if _, taken := g.components[name]; taken {
+		pkg := path.Base(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	g.names[t] = name
+	// 先占位，自引用的类型不会无限递归
+	g.components[name] = map[string]any{}
+	g.components[name] = g.object(t)
+	return name
+}
+
+// object 按 encoding/json 的规则生成结构体的 Schema：使用 json 标签中的名字，跳过 "-" 和未导出的字段，
+// 展开嵌入的结构体（外层的同名字段优先）。binding:"required" 的字段列为必填
+func (g *Generator) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	g.fields(t, properties, &required)
+	s := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func (g *Generator) fields(t reflect.Type, properties map[string]any, required *[]string) {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if _, ok := properties[name]; ok {
+			continue
+		}
+		s := g.schema(f.Type)
+		if strings.Contains(opts, "string") {
+			s = map[string]any{"type": "string"}
+		}
+		properties[name] = s
+		if strings.Contains(f.Tag.Get("binding"), "required") {
+			*required = append(*required, name)
+		}
+	}
+	for _, ft := range embedded {
+		g.fields(ft, properties, required)
+	}
+}
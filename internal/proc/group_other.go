@@ -0,0 +1,25 @@
+//go:build !(linux || darwin || freebsd || windows)
+
+package proc
+
+import (
+	"os"
+	"os/exec"
+)
+
+func setProcessGroup(*exec.Cmd) {}
+
+// killRemaining 没有进程组，无法找到残留的子进程
+func killRemaining(int) {}
+
+// signalGroup 没有进程组时只能结束进程本身
+func signalGroup(pid int, _ bool) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return nil
+	}
+	if err := p.Kill(); err != nil && err != os.ErrProcessDone {
+		return err
+	}
+	return nil
+}
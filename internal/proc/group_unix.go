@@ -0,0 +1,30 @@
+//go:build linux || darwin || freebsd
+
+package proc
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalGroup 向进程组发送 SIGTERM，kill 为 true 时发送 SIGKILL
+func signalGroup(pid int, kill bool) error {
+	sig := syscall.SIGTERM
+	if kill {
+		sig = syscall.SIGKILL
+	}
+	if err := syscall.Kill(-pid, sig); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return err
+	}
+	return nil
+}
+
+// killRemaining 进程退出后结束进程组中残留的子进程
+func killRemaining(pid int) {
+	signalGroup(pid, true)
+}
@@ -0,0 +1,42 @@
+//go:build windows
+
+package proc
+
+import (
+	"errors"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// generateConsoleCtrlEvent 向进程组发送 Ctrl-Break，ffmpeg 和 Python 收到后会像 SIGTERM 一样正常退出
+var generateConsoleCtrlEvent = syscall.NewLazyDLL("kernel32.dll").NewProc("GenerateConsoleCtrlEvent")
+
+// setProcessGroup 在新的进程组中启动命令，Ctrl-Break 只发给这个进程组
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// signalGroup 向进程组发送 Ctrl-Break；kill 为 true 或服务没有控制台（例如作为 Windows 服务运行）
+// 无法发送时，用 taskkill /T /F 强制结束进程及其子进程
+func signalGroup(pid int, kill bool) error {
+	if !kill {
+		if ok, _, _ := generateConsoleCtrlEvent.Call(syscall.CTRL_BREAK_EVENT, uintptr(pid)); ok != 0 {
+			return nil
+		}
+	}
+	cmd := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(pid))
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	if err := cmd.Run(); err != nil {
+		// 进程已经退出时 taskkill 返回 128
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 128 {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// killRemaining Windows 上进程退出后无法再按进程树找到它启动的子进程，只能在取消时用 taskkill /T 一起结束
+func killRemaining(int) {}
@@ -0,0 +1,119 @@
+package proc
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// SearchDirs 除 PATH 外查找可执行文件的目录，按操作系统区分：macOS 的 Homebrew 和 pip --user，
+// Linux 的 /usr/local/bin、~/.local/bin 和 snap，Windows 的 Python Scripts、Scoop 和 WinGet。
+// 从 Finder 或桌面快捷方式启动时 PATH 往往不包含这些目录
+func SearchDirs() []string {
+	home, _ := os.UserHomeDir()
+	switch runtime.GOOS {
+	case "darwin":
+		dirs := []string{"/opt/homebrew/bin", "/usr/local/bin", filepath.Join(home, ".local", "bin")}
+		pythonBins, _ := filepath.Glob(filepath.Join(home, "Library", "Python", "*", "bin"))
+		return append(dirs, pythonBins...)
+	case "windows":
+		var dirs []string
+		for _, pattern := range []string{
+			filepath.Join(os.Getenv("APPDATA"), "Python", "Python3*", "Scripts"),
+			filepath.Join(os.Getenv("LOCALAPPDATA"), "Programs", "Python", "Python3*", "Scripts"),
+		} {
+			matches, _ := filepath.Glob(pattern)
+			dirs = append(dirs, matches...)
+		}
+		return append(dirs,
+			filepath.Join(home, "scoop", "shims"),
+			filepath.Join(os.Getenv("LOCALAPPDATA"), "Microsoft", "WinGet", "Links"))
+	}
+	return []string{"/usr/local/bin", filepath.Join(home, ".local", "bin"), "/snap/bin"}
+}
+
+// LookPath 依次在 PATH 和 SearchDirs 中查找 names 中的程序，返回第一个找到的路径。
+// Windows 上按 PATHEXT 补全 .exe 等扩展名
+func LookPath(names ...string) (string, error) {
+	for _, name := range names {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	for _, dir := range SearchDirs() {
+		for _, name := range names {
+			for _, path := range candidates(filepath.Join(dir, name)) {
+				if isExecutable(path) {
+					return path, nil
+				}
+			}
+		}
+	}
+	return "", fmt.Errorf("未找到 %s", strings.Join(names, " / "))
+}
+
+// Resolve 把不含目录的程序名（例如默认的 "ffmpeg"）解析为 PATH 或 SearchDirs 中的完整路径，
+// 找不到或已经是路径时原样返回，由执行时报告错误
+func Resolve(name string) string {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return name
+	}
+	if path, err := LookPath(name); err == nil {
+		return path
+	}
+	return name
+}
+
+// Env 把 SearchDirs 加入 PATH 的环境变量，外部程序（例如 Whisper）还会再调用 ffmpeg
+func Env() []string {
+	path := strings.Join(append(SearchDirs(), os.Getenv("PATH")), string(os.PathListSeparator))
+	return append(os.Environ(), "PATH="+path)
+}
+
+// Python 运行 dir 中的 Python 脚本使用的解释器：优先使用 dir/.venv 中的虚拟环境，
+// 其次是 PATH 中的 python3 / python（Windows 上通常只有 python）
+func Python(dir string) string {
+	venv := filepath.Join(dir, ".venv", "bin", "python")
+	names := []string{"python3", "python"}
+	if runtime.GOOS == "windows" {
+		venv = filepath.Join(dir, ".venv", "Scripts", "python.exe")
+		names = []string{"python", "python3"}
+	}
+	if _, err := os.Stat(venv); err == nil {
+		return venv
+	}
+	if path, err := LookPath(names...); err == nil {
+		return path
+	}
+	return names[0]
+}
+
+// candidates Windows 上为没有扩展名的路径补全 PATHEXT 中的扩展名
+func candidates(path string) []string {
+	if runtime.GOOS != "windows" || filepath.Ext(path) != "" {
+		return []string{path}
+	}
+	exts := os.Getenv("PATHEXT")
+	if exts == "" {
+		exts = ".com;.exe;.bat;.cmd"
+	}
+	var list []string
+	for _, ext := range strings.Split(strings.ToLower(exts), ";") {
+		if ext != "" {
+			list = append(list, path+ext)
+		}
+	}
+	return list
+}
+
+// isExecutable Windows 上没有可执行权限位，只检查是否为普通文件
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	return runtime.GOOS == "windows" || info.Mode()&0111 != 0
+}
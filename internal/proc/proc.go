@@ -0,0 +1,174 @@
+// Package proc 启动外部程序（ffmpeg、Whisper、yt-dlp、Python 脚本）。
+//
+// 每个命令在独立的进程组中运行：Whisper 和 Python 脚本会再启动 ffmpeg 等子进程，
+// 只结束父进程时这些子进程会继续运行。任务取消或超时（ctx 结束）时先向整个进程组发送 SIGTERM，
+// KillGrace 后仍未退出则发送 SIGKILL；命令正常结束后也会清理残留的子进程。
+// 服务退出前调用 Shutdown 终止所有仍在运行的进程组。
+package proc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// KillGrace 发送 SIGTERM 后等待进程退出的时间，超时后强制结束
+const KillGrace = 5 * time.Second
+
+// running 已启动且还没有 Wait 返回的命令，键为进程组 ID（即进程 ID）
+var (
+	runningMu sync.Mutex
+	running   = map[int]bool{}
+)
+
+// Cmd 在独立进程组中运行的命令。Start / Wait / Run / Output / CombinedOutput
+// 与 exec.Cmd 相同，额外记录正在运行的进程组
+type Cmd struct {
+	*exec.Cmd
+}
+
+// Command 创建命令，ctx 结束时终止整个进程组
+func Command(ctx context.Context, name string, args ...string) *Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return terminate(cmd.Process.Pid)
+	}
+	// 进程退出后子进程可能仍占用输出管道，超时后关闭管道，避免 Wait 一直阻塞
+	cmd.WaitDelay = KillGrace + time.Second
+	return &Cmd{Cmd: cmd}
+}
+
+// Start 启动命令并记录进程组
+func (c *Cmd) Start() error {
+	if err := c.Cmd.Start(); err != nil {
+		return err
+	}
+	runningMu.Lock()
+	running[c.Process.Pid] = true
+	runningMu.Unlock()
+	return nil
+}
+
+// Wait 等待命令结束，并结束进程组中残留的子进程
+func (c *Cmd) Wait() error {
+	err := c.Cmd.Wait()
+	pid := c.Process.Pid
+	runningMu.Lock()
+	delete(running, pid)
+	runningMu.Unlock()
+	killRemaining(pid)
+	return err
+}
+
+// Run 启动命令并等待结束
+func (c *Cmd) Run() error {
+	if err := c.Start(); err != nil {
+		return err
+	}
+	return c.Wait()
+}
+
+// Output 运行命令并返回标准输出，失败时 *exec.ExitError 的 Stderr 为错误输出
+func (c *Cmd) Output() ([]byte, error) {
+	if c.Stdout != nil {
+		return nil, errors.New("exec: Stdout already set")
+	}
+	var stdout, stderr bytes.Buffer
+	c.Stdout = &stdout
+	captureErr := c.Stderr == nil
+	if captureErr {
+		c.Stderr = &stderr
+	}
+	err := c.Run()
+	var ee *exec.ExitError
+	if captureErr && errors.As(err, &ee) {
+		ee.Stderr = stderr.Bytes()
+	}
+	return stdout.Bytes(), err
+}
+
+// CombinedOutput 运行命令并返回合并的标准输出和错误输出
+func (c *Cmd) CombinedOutput() ([]byte, error) {
+	if c.Stdout != nil || c.Stderr != nil {
+		return nil, errors.New("exec: Stdout or Stderr already set")
+	}
+	var out bytes.Buffer
+	c.Stdout = &out
+	c.Stderr = &out
+	err := c.Run()
+	return out.Bytes(), err
+}
+
+// Running 返回正在运行的命令数
+func Running() int {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+	return len(running)
+}
+
+func isRunning(pid int) bool {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+	return running[pid]
+}
+
+// Shutdown 终止所有正在运行的进程组，最多等待 KillGrace 后强制结束。服务退出前调用
+func Shutdown() {
+	runningMu.Lock()
+	pids := make([]int, 0, len(running))
+	for pid := range running {
+		pids = append(pids, pid)
+	}
+	runningMu.Unlock()
+	if len(pids) == 0 {
+		return
+	}
+
+	for _, pid := range pids {
+		signalGroup(pid, false)
+	}
+	deadline := time.Now().Add(KillGrace)
+	for Running() > 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	for _, pid := range pids {
+		if isRunning(pid) {
+			signalGroup(pid, true)
+		}
+	}
+}
+
+// terminate 向进程组发送 SIGTERM，KillGrace 后仍未退出则发送 SIGKILL
+func terminate(pid int) error {
+	err := signalGroup(pid, false)
+	time.AfterFunc(KillGrace, func() {
+		if isRunning(pid) {
+			signalGroup(pid, true)
+		}
+	})
+	return err
+}
+
+// ExitOnSignal 收到 SIGINT / SIGTERM 时依次执行 before（例如关闭数据库，保证任务状态已写入）、
+// 终止所有进程组，然后退出。未完成的任务在下次启动时标记为 interrupted
+func ExitOnSignal(before ...func()) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		s := <-sig
+		slog.Info("收到退出信号，终止正在运行的外部程序", "signal", s.String(), "running", Running())
+		for _, f := range before {
+			f()
+		}
+		Shutdown()
+		os.Exit(0)
+	}()
+}
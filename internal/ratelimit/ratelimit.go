@@ -0,0 +1,170 @@
+// Package ratelimit 限制下载速度（字节/秒）。
+//
+// Limiter 是令牌桶，可以指定上级：每个任务一个 Limiter，上级是所有下载共用的全局 Limiter，
+// 读取的数据同时受两者限制。速度为 0 的 Limiter（以及 nil）不限速。
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minBurst 令牌桶的最小容量，避免速度很低时一次读取的数据超过桶容量
+const minBurst = 32 << 10
+
+// Limiter 令牌桶限速器，可以被多个 goroutine 同时使用
+type Limiter struct {
+	rate   float64
+	burst  float64
+	parent *Limiter
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// New 创建每秒 bytesPerSecond 字节的限速器，bytesPerSecond 为 0 时只受 parent 限制
+func New(bytesPerSecond int64, parent *Limiter) *Limiter {
+	burst := float64(max(bytesPerSecond, minBurst))
+	return &Limiter{
+		rate:   float64(bytesPerSecond),
+		burst:  burst,
+		parent: parent,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// Rate 返回生效的速度上限（自身和上级中较小的），0 表示不限速
+func (l *Limiter) Rate() int64 {
+	var rate int64
+	for ; l != nil; l = l.parent {
+		if r := int64(l.rate); r > 0 && (rate == 0 || r < rate) {
+			rate = r
+		}
+	}
+	return rate
+}
+
+// Wait 消耗 n 字节的额度，额度不足时等待，ctx 结束时返回 ctx.Err()
+func (l *Limiter) Wait(ctx context.Context, n int) error {
+	for ; l != nil; l = l.parent {
+		if err := l.wait(ctx, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Limiter) wait(ctx context.Context, n int) error {
+	if l.rate <= 0 || n <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	// 先预留额度（可以为负），之后的调用按顺序排在后面
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// chunk 每次读取的最大字节数，不超过限速器（及上级）的桶容量
+func (l *Limiter) chunk() int {
+	size := 0
+	for ; l != nil; l = l.parent {
+		if l.rate > 0 && (size == 0 || int(l.burst) < size) {
+			size = int(l.burst)
+		}
+	}
+	return size
+}
+
+// Reader 返回受 l 限速的 Reader，l 不限速时原样返回 r
+func Reader(ctx context.Context, r io.Reader, l *Limiter) io.Reader {
+	if l.Rate() == 0 {
+		return r
+	}
+	return &reader{ctx: ctx, r: r, l: l, chunk: l.chunk()}
+}
+
+type reader struct {
+	ctx   context.Context
+	r     io.Reader
+	l     *Limiter
+	chunk int
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if len(p) > r.chunk {
+		p = p[:r.chunk]
+	}
+	n, err := r.r.Read(p)
+	if werr := r.l.Wait(r.ctx, n); werr != nil && err == nil {
+		err = werr
+	}
+	return n, err
+}
+
+// Parse 解析速度，例如 "2M"、"500KB"、"1.5MiB/s"、"800k"，单位按 1024 计，
+// 没有单位时为字节/秒。空字符串和 "0" 表示不限速
+func Parse(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	if v == "" {
+		return 0, nil
+	}
+	v = strings.TrimSuffix(v, "/S")
+	v = strings.TrimSuffix(strings.TrimSuffix(v, "B"), "I")
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(v, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(v, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(v, "G"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		v = v[:len(v)-1]
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	// ParseFloat 接受 NaN、Inf 和很大的指数，转换为 int64 前排除
+	if err != nil || !(n >= 0 && n*multiplier < math.MaxInt64) {
+		return 0, fmt.Errorf("无效的速度: %s（例如 2M、500K）", s)
+	}
+	rate := int64(n * multiplier)
+	if rate > 0 && rate < 1<<10 {
+		return 0, fmt.Errorf("速度不能低于 1K: %s", s)
+	}
+	return rate, nil
+}
+
+// Format 把速度格式化为 KB/s、MB/s
+func Format(bytesPerSecond int64) string {
+	if bytesPerSecond >= 1<<20 {
+		return fmt.Sprintf("%.1f MB/s", float64(bytesPerSecond)/(1<<20))
+	}
+	return fmt.Sprintf("%.0f KB/s", float64(bytesPerSecond)/(1<<10))
+}
@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"  ", 0, false},
+		{"0", 0, false},
+		{"0K", 0, false},
+		{"2048", 2048, false},
+		{"800k", 800 << 10, false},
+		{"500KB", 500 << 10, false},
+		{"2M", 2 << 20, false},
+		{"2m/s", 2 << 20, false},
+		{"1.5MiB/s", 3 << 19, false},
+		{" 1 G ", 1 << 30, false},
+		{"1023", 0, true},
+		{"0.5K", 0, true},
+		{"-1M", 0, true},
+		{"M", 0, true},
+		{"2X", 0, true},
+		{"fast", 0, true},
+		{"NaN", 0, true},
+		{"Inf", 0, true},
+		{"1e30G", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Parse(%q) = %d, %v，应为 %d, wantErr = %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want string
+	}{
+		{512 << 10, "512 KB/s"},
+		{1 << 20, "1.0 MB/s"},
+		{3 << 19, "1.5 MB/s"},
+	}
+	for _, tt := range tests {
+		if got := Format(tt.in); got != tt.want {
+			t.Errorf("Format(%d) = %q，应为 %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRate(t *testing.T) {
+	global := New(4<<20, nil)
+	tests := []struct {
+		name      string
+		l         *Limiter
+		wantRate  int64
+		wantChunk int
+	}{
+		{"nil", nil, 0, 0},
+		{"不限速", New(0, nil), 0, 0},
+		{"只有全局上限", New(0, global), 4 << 20, 4 << 20},
+		{"任务上限较低", New(1<<20, global), 1 << 20, 1 << 20},
+		{"全局上限较低", New(8<<20, global), 4 << 20, 4 << 20},
+		{"低于最小桶容量", New(2<<10, nil), 2 << 10, minBurst},
+	}
+	for _, tt := range tests {
+		if got := tt.l.Rate(); got != tt.wantRate {
+			t.Errorf("%s: Rate = %d，应为 %d", tt.name, got, tt.wantRate)
+		}
+		if got := tt.l.chunk(); got != tt.wantChunk {
+			t.Errorf("%s: chunk = %d，应为 %d", tt.name, got, tt.wantChunk)
+		}
+	}
+}
+
+func TestReader(t *testing.T) {
+	// 桶容量等于每秒的速度，读取 1.5 倍时第一秒的额度立即可用，其余需要等待约 0.5 秒
+	const rate = 256 << 10
+	tests := []struct {
+		name string
+		l    *Limiter
+	}{
+		{"任务上限", New(rate, nil)},
+		{"全局上限", New(0, New(rate, nil))},
+		{"任务和全局上限", New(rate, New(4*rate, nil))},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			start := time.Now()
+			n, err := io.Copy(io.Discard, Reader(context.Background(), bytes.NewReader(make([]byte, rate*3/2)), tt.l))
+			elapsed := time.Since(start)
+			if err != nil || n != rate*3/2 {
+				t.Fatalf("io.Copy = %d, %v", n, err)
+			}
+			if elapsed < 400*time.Millisecond || elapsed > 3*time.Second {
+				t.Errorf("耗时 %v，应约为 500ms", elapsed)
+			}
+		})
+	}
+}
+
+func TestReaderUnlimited(t *testing.T) {
+	r := bytes.NewReader(nil)
+	if got := Reader(context.Background(), r, New(0, nil)); got != io.Reader(r) {
+		t.Error("不限速时应原样返回")
+	}
+}
+
+func TestWaitCancelled(t *testing.T) {
+	l := New(1<<10, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// 超过桶容量的额度需要等待很久，ctx 结束时立即返回
+	start := time.Now()
+	if err := l.Wait(ctx, 64<<10); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait = %v，应为 context.Canceled", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("ctx 结束后没有立即返回")
+	}
+}
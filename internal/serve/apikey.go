@@ -0,0 +1,27 @@
+package serve
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+// APIKey 依次从 Authorization: Bearer 和 X-API-Key 请求头中读取密钥
+func APIKey(r *http.Request) string {
+	if v := r.Header.Get("Authorization"); len(v) > 7 && strings.EqualFold(v[:7], "Bearer ") {
+		return strings.TrimSpace(v[7:])
+	}
+	return r.Header.Get("X-API-Key")
+}
+
+// CheckAPIKeyRequired 用不带密钥的请求访问 handler 的 path，没有返回 401 时返回错误。
+// 配置了工作区时启动前调用，避免忘记检查密钥的接口对所有人开放所有工作区的任务
+func CheckAPIKeyRequired(handler http.Handler, method, path string) error {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader("{}")))
+	if w.Code != http.StatusUnauthorized {
+		return fmt.Errorf("配置了工作区，但 %s %s 不检查 API 密钥（返回 %d）", method, path, w.Code)
+	}
+	return nil
+}
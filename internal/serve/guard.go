@@ -0,0 +1,55 @@
+package serve
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"zhihu-downloader/internal/errcode"
+)
+
+// DefaultMaxBody 默认的请求体上限
+const DefaultMaxBody = 4 << 20
+
+// Guard 在交给 next 之前检查请求：请求体超过 maxBody 时返回 413，读取时超出的部分也会报错；
+// 路径中有 ..、反斜杠、编码过的 / 或控制字符时返回 400，任务 ID 等路径参数不会被当作文件路径穿越目录
+func Guard(next http.Handler, maxBody int64) http.Handler {
+	if maxBody <= 0 {
+		maxBody = DefaultMaxBody
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > maxBody {
+			writeError(w, errcode.PayloadTooLarge, fmt.Sprintf("请求体超过上限 %d MB", maxBody>>20))
+			return
+		}
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+		}
+		if !safePath(r.URL.Path) || !safePath(r.URL.RawPath) {
+			writeError(w, errcode.InvalidArgument, "请求路径无效")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// safePath 路径的每一段都不能是 . 或 ..，不能包含反斜杠、控制字符和编码过的 /
+func safePath(p string) bool {
+	if strings.ContainsAny(p, "\\\x00") || strings.Contains(strings.ToLower(p), "%2f") || strings.Contains(strings.ToLower(p), "%5c") {
+		return false
+	}
+	for _, seg := range strings.Split(p, "/") {
+		if seg == "." || seg == ".." || strings.IndexFunc(seg, func(r rune) bool { return r < 0x20 || r == 0x7f }) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// writeError 返回与 REST 网关相同格式的错误
+func writeError(w http.ResponseWriter, code errcode.Code, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code.HTTPStatus())
+	json.NewEncoder(w).Encode(map[string]string{"error": message, "code": string(code), "message": message})
+}
@@ -0,0 +1,137 @@
+// Package serve 启动三个服务共用的 HTTP 监听：TCP 地址或 Unix socket，
+// 可以用指定的证书或通过 Let's Encrypt 自动申请的证书提供 HTTPS，不需要在前面再放反向代理。
+package serve
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// unixPrefix 监听 Unix socket 的地址前缀，例如 unix:/run/zhihu/api.sock
+const unixPrefix = "unix:"
+
+// readHeaderTimeout 读取请求头的最长时间，避免慢速连接长期占用
+const readHeaderTimeout = 30 * time.Second
+
+// TLSOptions HTTPS 设置：CertFile / KeyFile 与 AutocertHosts 二选一，都为空时使用 HTTP
+type TLSOptions struct {
+	// CertFile / KeyFile PEM 格式的证书（可以包含中间证书）和私钥，文件更新后重启服务生效
+	CertFile string
+	KeyFile  string
+	// AutocertHosts 通过 Let's Encrypt 自动申请证书的域名，需要从公网访问这些域名的 443 端口或 AutocertHTTPListen
+	AutocertHosts []string
+	// AutocertEmail 证书即将过期或有问题时 Let's Encrypt 通知的邮箱，可以为空
+	AutocertEmail string
+	// AutocertCacheDir 保存申请到的证书和账号密钥的目录
+	AutocertCacheDir string
+	// AutocertHTTPListen 处理 HTTP-01 验证并把其他 HTTP 请求跳转到 HTTPS 的地址，例如 :80；
+	// 为空时只使用 TLS-ALPN-01 验证（服务需要监听 443 端口）
+	AutocertHTTPListen string
+}
+
+// Enabled 是否使用 HTTPS
+func (o TLSOptions) Enabled() bool {
+	return o.CertFile != "" || o.KeyFile != "" || len(o.AutocertHosts) > 0
+}
+
+// Validate 检查证书配置：证书和私钥需要同时指定且能加载，不能同时配置自动申请
+func (o TLSOptions) Validate() error {
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return errors.New("cert_file 和 key_file 需要同时指定")
+	}
+	if o.CertFile != "" {
+		if len(o.AutocertHosts) > 0 {
+			return errors.New("cert_file 和 autocert 不能同时使用")
+		}
+		if _, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile); err != nil {
+			return fmt.Errorf("加载证书失败: %v", err)
+		}
+	}
+	for _, host := range o.AutocertHosts {
+		if host == "" || strings.ContainsAny(host, "/: ") || net.ParseIP(host) != nil {
+			return fmt.Errorf("autocert.hosts 中的域名无效: %q（不能是 IP 地址或带端口）", host)
+		}
+	}
+	if len(o.AutocertHosts) > 0 && o.AutocertCacheDir == "" {
+		return errors.New("autocert 需要 cache_dir")
+	}
+	return nil
+}
+
+// URL 返回服务在 addr 上的地址，用于日志
+func URL(addr string, o TLSOptions) string {
+	scheme := "http"
+	if o.Enabled() {
+		scheme = "https"
+	}
+	if strings.HasPrefix(addr, unixPrefix) {
+		return scheme + "+" + addr
+	}
+	return scheme + "://" + addr
+}
+
+// ListenAndServe 在 addr 上提供服务直到监听失败。addr 为 host:port 或 unix:<path>；
+// 按 o 使用指定的证书或自动申请的证书提供 HTTPS
+func ListenAndServe(addr string, handler http.Handler, o TLSOptions) error {
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: readHeaderTimeout}
+	ln, err := listen(addr)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	switch {
+	case o.CertFile != "":
+		return server.ServeTLS(ln, o.CertFile, o.KeyFile)
+	case len(o.AutocertHosts) > 0:
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(o.AutocertHosts...),
+			Cache:      autocert.DirCache(o.AutocertCacheDir),
+			Email:      o.AutocertEmail,
+		}
+		server.TLSConfig = m.TLSConfig()
+		if o.AutocertHTTPListen != "" {
+			go serveChallenges(o.AutocertHTTPListen, m)
+		}
+		return server.ServeTLS(ln, "", "")
+	}
+	return server.Serve(ln)
+}
+
+// serveChallenges 处理 ACME HTTP-01 验证，其他请求跳转到 HTTPS。监听失败时只记录日志，仍可以用 TLS-ALPN-01 验证
+func serveChallenges(addr string, m *autocert.Manager) {
+	server := &http.Server{Addr: addr, Handler: m.HTTPHandler(nil), ReadHeaderTimeout: readHeaderTimeout}
+	if err := server.ListenAndServe(); err != nil {
+		slog.Warn("ACME HTTP 验证端口监听失败", "addr", addr, "error", err)
+	}
+}
+
+// listen 监听 TCP 地址或 Unix socket。Unix socket 文件已存在时先删除（上次退出时没有清理）
+func listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, unixPrefix)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	if path == "" {
+		return nil, fmt.Errorf("监听地址缺少 socket 路径: %s", addr)
+	}
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		// 仍有服务在使用时不删除
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s 已被其他进程监听", path)
+		}
+		os.Remove(path)
+	}
+	return net.Listen("unix", path)
+}
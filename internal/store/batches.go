@@ -0,0 +1,61 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"zhihu-downloader/internal/tasks"
+)
+
+// 批量转录：transcribe_batches 每个批量转录一行，子任务 ID 和跳过的文件保存为 JSON。
+// 进度不保存，查询时按子任务汇总
+func (s *sqlStore) migrateBatches() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS transcribe_batches (
+			id TEXT PRIMARY KEY,
+			dir TEXT NOT NULL,
+			pattern TEXT NOT NULL,
+			workspace TEXT,
+			task_ids TEXT,
+			skipped TEXT,
+			created_at DATETIME NOT NULL
+		)
+	`)
+	return err
+}
+
+// SaveBatch 保存批量转录
+func (s *sqlStore) SaveBatch(b *tasks.TranscribeBatch) error {
+	skipped, err := json.Marshal(b.Skipped)
+	if err != nil {
+		return err
+	}
+	return s.writeTx("batch:"+b.ID, func(tx *sql.Tx) error {
+		_, err := tx.Exec(upsert("transcribe_batches", "id", "id, dir, pattern, workspace, task_ids, skipped, created_at"),
+			b.ID, b.Dir, b.Pattern, b.Workspace, encodeList(b.TaskIDs), string(skipped), b.CreatedAt)
+		return err
+	})
+}
+
+// Batch 返回批量转录，不存在时返回 nil
+func (s *sqlStore) Batch(id string) (*tasks.TranscribeBatch, error) {
+	b := &tasks.TranscribeBatch{ID: id}
+	var taskIDs, skipped string
+	err := s.db.QueryRow("SELECT dir, pattern, COALESCE(workspace, ''), COALESCE(task_ids, ''), COALESCE(skipped, ''), created_at FROM transcribe_batches WHERE id = ?", id).
+		Scan(&b.Dir, &b.Pattern, &b.Workspace, &taskIDs, &skipped, &b.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	b.TaskIDs = decodeList(taskIDs)
+	if b.TaskIDs == nil {
+		b.TaskIDs = []string{}
+	}
+	if skipped == "" || json.Unmarshal([]byte(skipped), &b.Skipped) != nil || b.Skipped == nil {
+		b.Skipped = []tasks.SkippedFile{}
+	}
+	return b, nil
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"database/sql"
+
+	"zhihu-downloader/internal/tasks"
+)
+
+// 内容索引：content_index 按范围（工作区和根目录，见 tasks.ContentEntry）和 SHA-256 记录内容第一次下载得到的文件，
+// 同一范围内内容相同的下载链接到它；content_sources 记录下载过各内容的所有链接，删除任务时保留
+func (s *sqlStore) migrateContent() error {
+	// 之前的版本只按 SHA-256 索引，不同工作区的下载会互相链接。旧索引无法确定范围，直接删除，
+	// 之后的下载重新建立索引；已有的链接不受影响
+	legacy, err := s.hasColumn("content_index", "hash")
+	if err != nil {
+		return err
+	}
+	if legacy {
+		if legacy, err = s.hasColumn("content_index", "scope"); err != nil {
+			return err
+		}
+		legacy = !legacy
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if legacy {
+		if _, err := tx.Exec("DROP TABLE content_index"); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS content_index (
+			scope TEXT NOT NULL DEFAULT '',
+			hash TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			file_path TEXT NOT NULL,
+			task_id TEXT,
+			created_at DATETIME NOT NULL,
+			PRIMARY KEY (scope, hash)
+		);
+		CREATE TABLE IF NOT EXISTS content_sources (
+			scope TEXT NOT NULL DEFAULT '',
+			hash TEXT NOT NULL,
+			task_id TEXT NOT NULL,
+			url TEXT NOT NULL,
+			file_path TEXT NOT NULL,
+			linked_to TEXT,
+			created_at DATETIME NOT NULL,
+			PRIMARY KEY (hash, task_id)
+		)
+	`); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	// 之前记录的来源没有范围，不再返回
+	return s.addColumn("content_sources", "scope", "TEXT NOT NULL DEFAULT ''")
+}
+
+// SaveContent 记录范围内内容的原始文件
+func (s *sqlStore) SaveContent(e *tasks.ContentEntry) error {
+	return s.writeTx("content:"+e.Scope+":"+e.Hash, func(tx *sql.Tx) error {
+		_, err := tx.Exec(upsert("content_index", "scope, hash", "scope, hash, size, file_path, task_id, created_at"),
+			e.Scope, e.Hash, e.Size, e.FilePath, e.TaskID, e.CreatedAt)
+		return err
+	})
+}
+
+// LookupContent 查找范围内内容的原始文件，没有记录时返回 nil
+func (s *sqlStore) LookupContent(scope, hash string) (*tasks.ContentEntry, error) {
+	e := &tasks.ContentEntry{Scope: scope, Hash: hash}
+	var taskID sql.NullString
+	err := s.db.QueryRow("SELECT size, file_path, task_id, created_at FROM content_index WHERE scope = ? AND hash = ?", scope, hash).
+		Scan(&e.Size, &e.FilePath, &taskID, &e.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	e.TaskID = taskID.String
+	return e, nil
+}
+
+// SaveContentSource 记录下载过该内容的链接
+func (s *sqlStore) SaveContentSource(src *tasks.ContentSource) error {
+	return s.writeTx("content_source:"+src.Hash+":"+src.TaskID, func(tx *sql.Tx) error {
+		_, err := tx.Exec(upsert("content_sources", "hash, task_id", "scope, hash, task_id, url, file_path, linked_to, created_at"),
+			src.Scope, src.Hash, src.TaskID, src.URL, src.FilePath, src.LinkedTo, src.CreatedAt)
+		return err
+	})
+}
+
+// ContentSources 返回范围内下载过该内容的链接，按时间先后排列
+func (s *sqlStore) ContentSources(scope, hash string) ([]tasks.ContentSource, error) {
+	rows, err := s.db.Query("SELECT task_id, url, file_path, COALESCE(linked_to, ''), created_at FROM content_sources WHERE scope = ? AND hash = ? ORDER BY created_at", scope, hash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sources []tasks.ContentSource
+	for rows.Next() {
+		src := tasks.ContentSource{Scope: scope, Hash: hash}
+		if err := rows.Scan(&src.TaskID, &src.URL, &src.FilePath, &src.LinkedTo, &src.CreatedAt); err != nil {
+			return nil, err
+		}
+		sources = append(sources, src)
+	}
+	return sources, rows.Err()
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"zhihu-downloader/internal/tasks"
+)
+
+func TestContentScope(t *testing.T) {
+	s := openTestStore(t, filepath.Join(t.TempDir(), "test.db"))
+	now := time.Now()
+	for _, e := range []*tasks.ContentEntry{
+		{Scope: "a:/data/a", Hash: "h1", Size: 1, FilePath: "/data/a/1.mp4", TaskID: "dl-1", CreatedAt: now},
+		{Scope: "b:/data/b", Hash: "h1", Size: 1, FilePath: "/data/b/1.mp4", TaskID: "dl-2", CreatedAt: now},
+	} {
+		if err := s.SaveContent(e); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SaveContentSource(&tasks.ContentSource{Scope: e.Scope, Hash: e.Hash, TaskID: e.TaskID, URL: "https://example.com/" + e.TaskID, FilePath: e.FilePath, CreatedAt: now}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		scope    string
+		wantPath string
+	}{
+		{"a:/data/a", "/data/a/1.mp4"},
+		{"b:/data/b", "/data/b/1.mp4"},
+		{":/data", ""},
+	}
+	for _, tt := range tests {
+		e, err := s.LookupContent(tt.scope, "h1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if e != nil {
+			got = e.FilePath
+		}
+		if got != tt.wantPath {
+			t.Errorf("LookupContent(%q) = %q，应为 %q", tt.scope, got, tt.wantPath)
+		}
+		sources, err := s.ContentSources(tt.scope, "h1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := map[bool]int{true: 1, false: 0}[tt.wantPath != ""]; len(sources) != want {
+			t.Errorf("ContentSources(%q) 返回 %d 条，应为 %d 条", tt.scope, len(sources), want)
+		}
+	}
+}
+
+func TestMigrateLegacyContentIndex(t *testing.T) {
+	s := openTestStore(t, filepath.Join(t.TempDir(), "test.db"))
+	for _, stmt := range []string{
+		"DROP TABLE content_index",
+		"CREATE TABLE content_index (hash TEXT PRIMARY KEY, size INTEGER NOT NULL, file_path TEXT NOT NULL, task_id TEXT, created_at DATETIME NOT NULL)",
+		"INSERT INTO content_index (hash, size, file_path, task_id, created_at) VALUES ('h1', 1, '/data/1.mp4', 'dl-1', CURRENT_TIMESTAMP)",
+	} {
+		if _, err := s.db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.migrateContent(); err != nil {
+		t.Fatal(err)
+	}
+	// 旧索引没有范围，迁移后不再用于去重
+	if e, err := s.LookupContent("", "h1"); err != nil || e != nil {
+		t.Fatalf("LookupContent = %+v, %v，旧索引应已删除", e, err)
+	}
+	e := &tasks.ContentEntry{Scope: ":/data", Hash: "h1", Size: 1, FilePath: "/data/2.mp4", CreatedAt: time.Now()}
+	if err := s.SaveContent(e); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.LookupContent(":/data", "h1"); err != nil || got == nil || got.FilePath != e.FilePath {
+		t.Fatalf("LookupContent = %+v, %v，应为 %s", got, err, e.FilePath)
+	}
+}
@@ -0,0 +1,171 @@
+package store
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"zhihu-downloader/internal/embedding"
+	"zhihu-downloader/internal/tasks"
+)
+
+// 语义搜索的向量：transcript_embeddings 每个转录片段一行，向量按小端 float32 保存为 BLOB（sqlite-vec 的格式），
+// transcript_embedded 记录每个转录已经用哪些模型计算过向量。加载了 sqlite-vec（构建时加上 -tags sqlite_vec）时
+// 由 vec_distance_cosine 在数据库中计算距离；否则（包括 Postgres）逐行读出向量在程序中计算，结果相同，转录很多时较慢
+func (s *sqlStore) migrateEmbeddings() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS transcript_embeddings (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id TEXT NOT NULL,
+			model TEXT NOT NULL,
+			start_time REAL,
+			end_time REAL,
+			text TEXT NOT NULL,
+			embedding BLOB NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.Exec("CREATE INDEX IF NOT EXISTS idx_transcript_embeddings_model ON transcript_embeddings(model, task_id)"); err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS transcript_embedded (
+			task_id TEXT NOT NULL,
+			model TEXT NOT NULL,
+			chunks INTEGER NOT NULL,
+			created_at DATETIME NOT NULL,
+			PRIMARY KEY (task_id, model)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	if !s.postgres {
+		var version string
+		s.vec = s.db.QueryRow("SELECT vec_version()").Scan(&version) == nil
+	}
+	return nil
+}
+
+// SaveEmbeddings 用 chunks 替换转录任务在 model 下的向量
+func (s *sqlStore) SaveEmbeddings(taskID, model string, chunks []tasks.EmbeddedChunk) error {
+	return s.writeTx("embedding:"+taskID, func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM transcript_embeddings WHERE task_id = ? AND model = ?", taskID, model); err != nil {
+			return err
+		}
+		stmt, err := tx.Prepare("INSERT INTO transcript_embeddings (task_id, model, start_time, end_time, text, embedding) VALUES (?, ?, ?, ?, ?, ?)")
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, c := range chunks {
+			if _, err := stmt.Exec(taskID, model, c.Start, c.End, c.Text, embedding.Encode(c.Vector)); err != nil {
+				return err
+			}
+		}
+		_, err = tx.Exec(upsert("transcript_embedded", "task_id, model", "task_id, model, chunks, created_at"),
+			taskID, model, len(chunks), time.Now())
+		return err
+	})
+}
+
+// deleteEmbeddings 删除转录任务所有模型的向量
+func (s *sqlStore) deleteEmbeddings(taskID string) error {
+	return s.writeTx("embedding:"+taskID, func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM transcript_embeddings WHERE task_id = ?", taskID); err != nil {
+			return err
+		}
+		_, err := tx.Exec("DELETE FROM transcript_embedded WHERE task_id = ?", taskID)
+		return err
+	})
+}
+
+// UnembeddedTranscripts 返回有转录结果、但还没有 model 下向量的转录任务，最新的在前
+func (s *sqlStore) UnembeddedTranscripts(model string) ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT task_id FROM transcripts
+		WHERE task_id NOT IN (SELECT task_id FROM transcript_embedded WHERE model = ?)
+		ORDER BY created_at DESC`, model)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SearchEmbeddings 返回 model 下与 vector 最相似的 limit 个片段，只比较维数相同的向量；
+// workspace 不为空时在截取之前按工作区筛选
+func (s *sqlStore) SearchEmbeddings(model string, vector []float32, workspace string, limit int) ([]tasks.TranscriptMatch, error) {
+	query := embedding.Encode(vector)
+	where, args := "model = ? AND length(embedding) = ?", []interface{}{model, len(query)}
+	if workspace != "" {
+		where += " AND " + workspaceTranscripts
+		args = append(args, workspace)
+	}
+	if s.vec {
+		return s.searchEmbeddingsVec(query, where, args, limit)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT task_id, COALESCE(start_time, 0), COALESCE(end_time, 0), text, embedding FROM transcript_embeddings
+		WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []tasks.TranscriptMatch
+	for rows.Next() {
+		var m tasks.TranscriptMatch
+		var data []byte
+		if err := rows.Scan(&m.TaskID, &m.Start, &m.End, &m.Text, &data); err != nil {
+			return nil, err
+		}
+		m.Score = embedding.Similarity(vector, embedding.Decode(data))
+		matches = append(matches, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// searchEmbeddingsVec 用 sqlite-vec 计算余弦距离（1 - 相似度），where 和 args 为 SearchEmbeddings 的筛选条件
+func (s *sqlStore) searchEmbeddingsVec(query []byte, where string, args []interface{}, limit int) ([]tasks.TranscriptMatch, error) {
+	args = append(append([]interface{}{query}, args...), limit)
+	rows, err := s.db.Query(`
+		SELECT task_id, COALESCE(start_time, 0), COALESCE(end_time, 0), text, vec_distance_cosine(embedding, ?) AS distance
+		FROM transcript_embeddings
+		WHERE `+where+`
+		ORDER BY distance LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []tasks.TranscriptMatch
+	for rows.Next() {
+		var m tasks.TranscriptMatch
+		var distance float64
+		if err := rows.Scan(&m.TaskID, &m.Start, &m.End, &m.Text, &distance); err != nil {
+			return nil, err
+		}
+		m.Score = 1 - distance
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"database/sql"
+
+	"zhihu-downloader/internal/tasks"
+)
+
+// 任务历史事件：task_events 每个事件一行，只追加，删除任务时一起删除
+func (s *sqlStore) migrateEvents() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS task_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id TEXT NOT NULL,
+			time DATETIME NOT NULL,
+			type TEXT NOT NULL,
+			status TEXT,
+			from_status TEXT,
+			message TEXT
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec("CREATE INDEX IF NOT EXISTS idx_task_events_task ON task_events(task_id)")
+	return err
+}
+
+// SaveEvent 追加任务的历史事件，立即写入
+func (s *sqlStore) SaveEvent(e *tasks.TaskEvent) error {
+	return s.writeTx("event:"+e.TaskID, func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT INTO task_events (task_id, time, type, status, from_status, message) VALUES (?, ?, ?, ?, ?, ?)",
+			e.TaskID, e.Time, e.Type, e.Status, e.From, e.Message)
+		return err
+	})
+}
+
+// deleteEvents 删除任务的全部历史事件
+func (s *sqlStore) deleteEvents(taskID string) error {
+	return s.writeTx("event:"+taskID, func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM task_events WHERE task_id = ?", taskID)
+		return err
+	})
+}
+
+// Events 返回任务的历史事件，按写入顺序排列。
+// 直接查询数据库，共用数据库的其他进程记录的事件也能看到
+func (s *sqlStore) Events(taskID string) ([]tasks.TaskEvent, error) {
+	rows, err := s.db.Query(`
+		SELECT time, type, COALESCE(status, ''), COALESCE(from_status, ''), COALESCE(message, '')
+		FROM task_events WHERE task_id = ? ORDER BY id`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []tasks.TaskEvent{}
+	for rows.Next() {
+		e := tasks.TaskEvent{TaskID: taskID}
+		if err := rows.Scan(&e.Time, &e.Type, &e.Status, &e.From, &e.Message); err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
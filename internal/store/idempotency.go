@@ -0,0 +1,158 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"zhihu-downloader/internal/tasks"
+)
+
+// 幂等键：idempotency_keys 每个工作区的每个键一行，记录它创建的任务。(workspace, key) 为主键，
+// 共用数据库的多个实例同时提交同一个键时只有一个能插入。created_at 为 Unix 秒，方便删除过期的键
+func (s *sqlStore) migrateIdempotency() error {
+	// 之前的版本只有 key 一列作为主键，key 带 "工作区:" 前缀，改名后复制未过期的键
+	legacy, err := s.hasColumn("idempotency_keys", "kind")
+	if err != nil {
+		return err
+	}
+	if legacy {
+		if legacy, err = s.hasColumn("idempotency_keys", "workspace"); err != nil {
+			return err
+		}
+		legacy = !legacy
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if legacy {
+		if _, err := tx.Exec("ALTER TABLE idempotency_keys RENAME TO idempotency_keys_legacy"); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS idempotency_keys (
+			workspace TEXT NOT NULL DEFAULT '',
+			key TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			task_id TEXT NOT NULL,
+			created_at INTEGER NOT NULL,
+			PRIMARY KEY (workspace, key)
+		)
+	`); err != nil {
+		return err
+	}
+	if legacy {
+		if err := copyLegacyIdempotencyKeys(tx); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// copyLegacyIdempotencyKeys 把之前版本的键拆成工作区和键写入新表，然后删除旧表。工作区名称中没有 :
+func copyLegacyIdempotencyKeys(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT key, kind, task_id, created_at FROM idempotency_keys_legacy WHERE created_at >= ?",
+		time.Now().Add(-tasks.IdempotencyTTL).Unix())
+	if err != nil {
+		return err
+	}
+	var keys []tasks.IdempotencyKey
+	for rows.Next() {
+		var k tasks.IdempotencyKey
+		var kind string
+		var created int64
+		if err := rows.Scan(&k.Key, &kind, &k.TaskID, &created); err != nil {
+			rows.Close()
+			return err
+		}
+		k.Workspace, k.Key, _ = strings.Cut(k.Key, ":")
+		k.Kind = tasks.Kind(kind)
+		k.CreatedAt = time.Unix(created, 0)
+		keys = append(keys, k)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if _, err := tx.Exec("INSERT INTO idempotency_keys (workspace, key, kind, task_id, created_at) VALUES (?, ?, ?, ?, ?)",
+			k.Workspace, k.Key, string(k.Kind), k.TaskID, k.CreatedAt.Unix()); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec("DROP TABLE idempotency_keys_legacy")
+	return err
+}
+
+// ClaimIdempotencyKey 删除过期的键后插入 k。插入成功时返回 nil；
+// 同一个工作区的同一个键已经有记录时（包括其他实例刚刚插入的）不修改，返回已有的记录
+func (s *sqlStore) ClaimIdempotencyKey(k *tasks.IdempotencyKey) (*tasks.IdempotencyKey, error) {
+	// 已有的记录可能在查询之前被删除（任务已删除或过期），这时重新插入
+	for i := 0; i < 3; i++ {
+		var claimed bool
+		err := s.writeTx("idempotency:"+k.Workspace+":"+k.Key, func(tx *sql.Tx) error {
+			if _, err := tx.Exec("DELETE FROM idempotency_keys WHERE created_at < ?", time.Now().Add(-tasks.IdempotencyTTL).Unix()); err != nil {
+				return err
+			}
+			res, err := tx.Exec(`INSERT INTO idempotency_keys (workspace, key, kind, task_id, created_at) VALUES (?, ?, ?, ?, ?)
+				ON CONFLICT (workspace, key) DO NOTHING`,
+				k.Workspace, k.Key, string(k.Kind), k.TaskID, k.CreatedAt.Unix())
+			if err != nil {
+				return err
+			}
+			n, err := res.RowsAffected()
+			claimed = n > 0
+			return err
+		})
+		if err != nil || claimed {
+			return nil, err
+		}
+		existing, err := s.IdempotencyKey(k.Workspace, k.Key)
+		if existing != nil || err != nil {
+			return existing, err
+		}
+	}
+	return nil, fmt.Errorf("记录幂等键 %s 失败：记录被同时修改", k.Key)
+}
+
+// SaveIdempotencyKey 记录幂等键创建的任务，覆盖 ClaimIdempotencyKey 插入的记录
+func (s *sqlStore) SaveIdempotencyKey(k *tasks.IdempotencyKey) error {
+	return s.writeTx("idempotency:"+k.Workspace+":"+k.Key, func(tx *sql.Tx) error {
+		_, err := tx.Exec(upsert("idempotency_keys", "workspace, key", "workspace, key, kind, task_id, created_at"),
+			k.Workspace, k.Key, string(k.Kind), k.TaskID, k.CreatedAt.Unix())
+		return err
+	})
+}
+
+// DeleteIdempotencyKey 删除幂等键，只在记录的任务和时间与 k 相同时删除，不会删除其他实例之后插入的记录
+func (s *sqlStore) DeleteIdempotencyKey(k *tasks.IdempotencyKey) error {
+	return s.writeTx("idempotency:"+k.Workspace+":"+k.Key, func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM idempotency_keys WHERE workspace = ? AND key = ? AND task_id = ? AND created_at = ?",
+			k.Workspace, k.Key, k.TaskID, k.CreatedAt.Unix())
+		return err
+	})
+}
+
+// IdempotencyKey 返回工作区的幂等键创建的任务，没有记录时返回 nil
+func (s *sqlStore) IdempotencyKey(workspace, key string) (*tasks.IdempotencyKey, error) {
+	k := &tasks.IdempotencyKey{Workspace: workspace, Key: key}
+	var kind string
+	var created int64
+	err := s.db.QueryRow("SELECT kind, task_id, created_at FROM idempotency_keys WHERE workspace = ? AND key = ?", workspace, key).
+		Scan(&kind, &k.TaskID, &created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	k.Kind = tasks.Kind(kind)
+	k.CreatedAt = time.Unix(created, 0)
+	return k, nil
+}
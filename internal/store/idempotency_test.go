@@ -0,0 +1,127 @@
+package store
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"zhihu-downloader/internal/tasks"
+)
+
+func openTestStore(t *testing.T, path string) *sqlStore {
+	t.Helper()
+	st, err := Open(Options{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	return st.(*sqlStore)
+}
+
+func TestClaimIdempotencyKeyShared(t *testing.T) {
+	// 两个实例共用一个数据库文件，同时提交同一个键时只有一个能插入
+	path := filepath.Join(t.TempDir(), "test.db")
+	stores := []*sqlStore{openTestStore(t, path), openTestStore(t, path)}
+
+	var mu sync.Mutex
+	claimed := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			k := &tasks.IdempotencyKey{Workspace: "team", Key: "k1", Kind: tasks.KindDownload, CreatedAt: time.Now()}
+			existing, err := stores[i%2].ClaimIdempotencyKey(k)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if existing == nil {
+				mu.Lock()
+				claimed++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if claimed != 1 {
+		t.Fatalf("插入成功 %d 次，应为 1 次", claimed)
+	}
+
+	// 其他工作区的同名键互不影响
+	if existing, err := stores[0].ClaimIdempotencyKey(&tasks.IdempotencyKey{Key: "k1", Kind: tasks.KindDownload, CreatedAt: time.Now()}); err != nil || existing != nil {
+		t.Fatalf("默认工作区的同名键: %v %v", existing, err)
+	}
+
+	// 记录任务 ID 后另一个实例能查到；删除时只删除相同的记录
+	k, err := stores[1].IdempotencyKey("team", "k1")
+	if err != nil || k == nil {
+		t.Fatalf("查询幂等键: %v %v", k, err)
+	}
+	k.TaskID = "dl-1"
+	if err := stores[1].SaveIdempotencyKey(k); err != nil {
+		t.Fatal(err)
+	}
+	stale := *k
+	stale.TaskID = ""
+	if err := stores[0].DeleteIdempotencyKey(&stale); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := stores[0].IdempotencyKey("team", "k1"); got == nil || got.TaskID != "dl-1" {
+		t.Fatalf("幂等键 = %+v，应为 dl-1", got)
+	}
+	if err := stores[0].DeleteIdempotencyKey(k); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := stores[0].IdempotencyKey("team", "k1"); got != nil {
+		t.Fatalf("幂等键没有删除: %+v", got)
+	}
+}
+
+func TestMigrateLegacyIdempotencyKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s := openTestStore(t, path)
+	now := time.Now().Unix()
+	for _, stmt := range []string{
+		"DROP TABLE idempotency_keys",
+		"CREATE TABLE idempotency_keys (key TEXT PRIMARY KEY, kind TEXT NOT NULL, task_id TEXT NOT NULL, created_at INTEGER NOT NULL)",
+	} {
+		if _, err := s.db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, row := range []struct {
+		key     string
+		created int64
+	}{{"team:k1", now}, {":k2", now}, {"team:old", now - int64(2*tasks.IdempotencyTTL/time.Second)}} {
+		if _, err := s.db.Exec("INSERT INTO idempotency_keys (key, kind, task_id, created_at) VALUES (?, 'download', 'dl-1', ?)", row.key, row.created); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.migrateIdempotency(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		workspace, key string
+		want           bool
+	}{
+		{"team", "k1", true},
+		{"", "k2", true},
+		{"team", "old", false},
+		{"", "team:k1", false},
+	}
+	for _, tt := range tests {
+		k, err := s.IdempotencyKey(tt.workspace, tt.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (k != nil) != tt.want {
+			t.Errorf("IdempotencyKey(%q, %q) = %+v，应%s记录", tt.workspace, tt.key, k, map[bool]string{true: "有", false: "没有"}[tt.want])
+		}
+	}
+	if ok, _ := s.hasColumn("idempotency_keys_legacy", "key"); ok {
+		t.Error("旧表没有删除")
+	}
+}
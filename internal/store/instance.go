@@ -0,0 +1,133 @@
+package store
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+
+	"zhihu-downloader/internal/tasks"
+)
+
+// 共用同一个数据库的每个进程（网关、MCP 服务）是一个实例，定期更新心跳时间。
+// 任务记录执行它的实例（owner 列），心跳超过 instanceTimeout 没有更新的实例视为已退出
+const (
+	heartbeatInterval = 10 * time.Second
+	instanceTimeout   = 30 * time.Second
+)
+
+// register 登记当前实例并开始定期更新心跳
+func (s *sqlStore) register() error {
+	s.instance = uuid.New().String()
+	hostname, _ := os.Hostname()
+	now := time.Now().Unix()
+	// 顺便删除早已退出的实例
+	if _, err := s.db.Exec("DELETE FROM instances WHERE heartbeat_at < ?", now-int64(instanceTimeout/time.Second)); err != nil {
+		return err
+	}
+	_, err := s.db.Exec("INSERT INTO instances (id, pid, hostname, started_at, heartbeat_at) VALUES (?, ?, ?, ?, ?)",
+		s.instance, os.Getpid(), hostname, now, now)
+	if err != nil {
+		return err
+	}
+	go s.heartbeat()
+	return nil
+}
+
+func (s *sqlStore) heartbeat() {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := s.db.Exec("UPDATE instances SET heartbeat_at = ? WHERE id = ?", time.Now().Unix(), s.instance); err != nil {
+				slog.Warn("更新实例心跳失败", "error", err)
+			}
+		case <-s.stopped:
+			return
+		}
+	}
+}
+
+// unregister 进程正常退出时删除实例，它执行的任务不再视为在其他进程中执行
+func (s *sqlStore) unregister() {
+	s.db.Exec("DELETE FROM instances WHERE id = ?", s.instance)
+}
+
+// ownedTasks 所有任务的 ID 和执行它的实例
+const ownedTasks = `
+	SELECT id, owner FROM download_tasks
+	UNION ALL SELECT id, owner FROM transcribe_tasks
+	UNION ALL SELECT id, owner FROM pipeline_tasks
+	UNION ALL SELECT id, owner FROM collection_tasks`
+
+// RunningElsewhere 判断任务是否由共用数据库的其他仍在运行的进程执行
+func (s *sqlStore) RunningElsewhere(id string) bool {
+	var n int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM (`+ownedTasks+`) t JOIN instances i ON i.id = t.owner
+		WHERE t.id = ? AND t.owner != ? AND i.heartbeat_at >= ?`,
+		id, s.instance, time.Now().Add(-instanceTimeout).Unix()).Scan(&n)
+	if err != nil {
+		slog.Warn("查询任务所属进程失败", "id", id, "error", err)
+		return false
+	}
+	return n > 0
+}
+
+// RequestCancel 请求执行任务的进程取消任务，对方在下次同步时取消
+func (s *sqlStore) RequestCancel(id string) error {
+	_, err := s.db.Exec(upsert("cancel_requests", "task_id", "task_id, created_at"), id, time.Now().Unix())
+	return err
+}
+
+// CancelRequests 取出其他进程请求取消的、由当前实例执行的任务。
+// 超过 instanceTimeout 没有被处理的请求（执行任务的进程已退出）直接删除，之后重试的任务不受影响
+func (s *sqlStore) CancelRequests() ([]string, error) {
+	if _, err := s.db.Exec("DELETE FROM cancel_requests WHERE created_at < ?", time.Now().Add(-instanceTimeout).Unix()); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`
+		DELETE FROM cancel_requests
+		WHERE task_id IN (SELECT id FROM (`+ownedTasks+`) t WHERE owner = ?)
+		RETURNING task_id`, s.instance)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// NextID 从共用的序列中分配 dl-N / tr-N / pl-N / cl-N / tb-N 形式的任务 ID，
+// 多个进程同时创建任务也不会重复。数据库出错时退回 UUID
+func (s *sqlStore) NextID(kind tasks.Kind) string {
+	var n int64
+	err := s.db.QueryRow("UPDATE sequences SET value = value + 1 WHERE name = 'task' RETURNING value").Scan(&n)
+	if err != nil {
+		slog.Warn("分配任务 ID 失败，使用 UUID", "error", err)
+		return tasks.IDPrefix(kind) + "-" + uuid.New().String()
+	}
+	return fmt.Sprintf("%s-%d", tasks.IDPrefix(kind), n)
+}
+
+// seedSequence 第一次创建序列时从已有任务中最大的编号开始
+func (s *sqlStore) seedSequence() error {
+	var exists int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM sequences WHERE name = 'task'").Scan(&exists)
+	if err != nil || exists > 0 {
+		return err
+	}
+	_, err = s.db.Exec("INSERT INTO sequences (name, value) VALUES ('task', ?) ON CONFLICT DO NOTHING", s.MaxSequence())
+	return err
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"errors"
+	"io"
+
+	"zhihu-downloader/internal/tasks"
+)
+
+// 失败任务的外部程序输出：task_outputs 每个任务一行，内容用 gzip 压缩，删除任务时一起删除
+func (s *sqlStore) migrateOutputs() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS task_outputs (
+			task_id TEXT PRIMARY KEY,
+			output BLOB NOT NULL,
+			size INTEGER NOT NULL,
+			truncated INTEGER DEFAULT 0,
+			created_at DATETIME NOT NULL
+		)
+	`)
+	return err
+}
+
+// SaveOutput 压缩后保存任务的外部程序输出，覆盖之前的记录
+func (s *sqlStore) SaveOutput(o *tasks.TaskOutput) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(o.Output); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return s.writeTx("output:"+o.TaskID, func(tx *sql.Tx) error {
+		_, err := tx.Exec(upsert("task_outputs", "task_id", "task_id, output, size, truncated, created_at"),
+			o.TaskID, buf.Bytes(), len(o.Output), o.Truncated, o.CreatedAt)
+		return err
+	})
+}
+
+// Output 返回解压后的外部程序输出，没有记录时返回 nil
+func (s *sqlStore) Output(taskID string) (*tasks.TaskOutput, error) {
+	o := &tasks.TaskOutput{TaskID: taskID}
+	var data []byte
+	err := s.db.QueryRow("SELECT output, COALESCE(truncated, 0), created_at FROM task_outputs WHERE task_id = ?", taskID).
+		Scan(&data, &o.Truncated, &o.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	if o.Output, err = io.ReadAll(zr); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// deleteOutput 删除任务的外部程序输出
+func (s *sqlStore) deleteOutput(taskID string) error {
+	return s.writeTx("output:"+taskID, func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM task_outputs WHERE task_id = ?", taskID)
+		return err
+	})
+}
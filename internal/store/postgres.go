@@ -0,0 +1,162 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Postgres 与 SQLite 共用同一套语句（按 SQLite 的写法），由 pgConn 在执行前改写：
+//   - ? 占位符改为 $1、$2…
+//   - 建表和加列语句中的类型换成 Postgres 的类型（DATETIME -> TIMESTAMPTZ 等）
+//   - bool 参数按 SQLite 的习惯写成 0 / 1，布尔列都是 INTEGER
+//
+// 多个实例共用一个 Postgres 数据库时，实例心跳、跨进程取消和 ID 序列与共用 SQLite 文件时相同，见 instance.go
+
+// migrationLockID 升级表结构时持有的 advisory lock
+const migrationLockID = 0x7a68696875
+
+// openPostgres 连接 Postgres，dsn 为 postgres:// URL 或 key=value 形式的连接串
+func openPostgres(dsn string) (*sql.DB, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("使用 Postgres 时必须配置 storage.dsn")
+	}
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("Postgres 连接串无效: %v", err)
+	}
+	db := sql.OpenDB(pgConnector{connector})
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("连接 Postgres 失败: %v", err)
+	}
+	return db, nil
+}
+
+// lockMigrations 在单独的连接上取得 advisory lock，返回释放锁的函数
+func (s *sqlStore) lockMigrations() (func(), error) {
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock(?)", migrationLockID); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return func() {
+		conn.ExecContext(ctx, "SELECT pg_advisory_unlock(?)", migrationLockID)
+		conn.Close()
+	}, nil
+}
+
+type pgConnector struct {
+	*pq.Connector
+}
+
+func (c pgConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &pgConn{conn}, nil
+}
+
+// pgConn 改写语句后交给 lib/pq 的连接执行
+type pgConn struct {
+	driver.Conn
+}
+
+func (c *pgConn) Prepare(query string) (driver.Stmt, error) {
+	return c.Conn.Prepare(rewrite(query))
+}
+
+func (c *pgConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.Conn.(driver.ConnPrepareContext).PrepareContext(ctx, rewrite(query))
+}
+
+func (c *pgConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (c *pgConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, rewrite(query), args)
+}
+
+func (c *pgConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.Conn.(driver.QueryerContext).QueryContext(ctx, rewrite(query), args)
+}
+
+func (c *pgConn) Ping(ctx context.Context) error {
+	return c.Conn.(driver.Pinger).Ping(ctx)
+}
+
+func (c *pgConn) ResetSession(ctx context.Context) error {
+	return c.Conn.(driver.SessionResetter).ResetSession(ctx)
+}
+
+func (c *pgConn) IsValid() bool {
+	return c.Conn.(driver.Validator).IsValid()
+}
+
+// CheckNamedValue bool 参数转为 0 / 1，其他参数按默认规则转换
+func (c *pgConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if b, ok := nv.Value.(bool); ok {
+		nv.Value = int64(0)
+		if b {
+			nv.Value = int64(1)
+		}
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+// pgTypes 建表和加列语句中 SQLite 类型对应的 Postgres 类型，按顺序替换
+var pgTypes = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`\bINTEGER PRIMARY KEY AUTOINCREMENT\b`), "BIGSERIAL PRIMARY KEY"},
+	{regexp.MustCompile(`\bINTEGER\b`), "BIGINT"},
+	{regexp.MustCompile(`\bREAL\b`), "DOUBLE PRECISION"},
+	{regexp.MustCompile(`\bDATETIME\b`), "TIMESTAMPTZ"},
+	{regexp.MustCompile(`\bBLOB\b`), "BYTEA"},
+}
+
+// rewrite 把 SQLite 写法的语句改写为 Postgres 的写法
+func rewrite(query string) string {
+	head := strings.ToUpper(strings.TrimSpace(query))
+	if strings.HasPrefix(head, "CREATE TABLE") || strings.HasPrefix(head, "ALTER TABLE") {
+		for _, t := range pgTypes {
+			query = t.re.ReplaceAllString(query, t.repl)
+		}
+	}
+	return rebind(query)
+}
+
+// rebind 把字符串常量以外的 ? 改为 $1、$2…
+func rebind(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	n, quoted := 0, false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == '?' && !quoted:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"zhihu-downloader/internal/tasks"
+	"zhihu-downloader/internal/transcriber"
+)
+
+func TestSearchFiltersWorkspaceBeforeLimit(t *testing.T) {
+	s := openTestStore(t, filepath.Join(t.TempDir(), "test.db"))
+
+	// 默认工作区的转录较新、较相似，team 工作区的只有一个，不按工作区筛选时会被 limit 截掉
+	save := func(id, workspace string, vector []float32) {
+		t.Helper()
+		now := time.Now()
+		if err := s.SaveTranscribe(&tasks.TranscribeTask{ID: id, Status: tasks.StatusCompleted, Workspace: workspace, CreatedAt: now, UpdatedAt: now}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SaveTranscript(id, &transcriber.Transcript{Segments: []transcriber.Segment{{Start: 0, End: 1, Text: "hello world"}}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SaveEmbeddings(id, "m", []tasks.EmbeddedChunk{{Start: 0, End: 1, Text: "hello world", Vector: vector}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	save("tr-team", "team", []float32{0, 1})
+	for i := range 3 {
+		save(fmt.Sprintf("tr-%d", i), "", []float32{1, 0})
+	}
+
+	tests := []struct {
+		workspace string
+		want      string
+	}{
+		{"team", "tr-team"},
+		{"other", ""},
+	}
+	for _, tt := range tests {
+		keyword, err := s.SearchTranscripts([]string{"hello"}, tt.workspace, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		semantic, err := s.SearchEmbeddings("m", []float32{1, 0}, tt.workspace, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, matches := range map[string][]tasks.TranscriptMatch{"SearchTranscripts": keyword, "SearchEmbeddings": semantic} {
+			got := ""
+			if len(matches) > 0 {
+				got = matches[0].TaskID
+			}
+			if got != tt.want || len(matches) > 1 {
+				t.Errorf("%s(workspace=%q) = %+v，应只有 %q", name, tt.workspace, matches, tt.want)
+			}
+		}
+	}
+
+	// 不指定工作区时搜索所有任务
+	if matches, _ := s.SearchEmbeddings("m", []float32{1, 0}, "", 10); len(matches) != 4 {
+		t.Errorf("所有工作区的片段 = %d，应为 4", len(matches))
+	}
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"strings"
+)
+
+// Secrets 加密任务中可能包含令牌的字段（下载时附加的请求头和 cookies），由 auth.Vault 实现
+type Secrets interface {
+	Seal(plain []byte) ([]byte, error)
+	Open(data []byte) ([]byte, error)
+}
+
+// SetSecrets 设置加密请求头和 cookies 的密钥，需要在载入任务之前调用。
+// 没有设置时这些字段只保存在内存中，进程退出后不能再用于继续下载。
+// 设置时加密之前的版本以明文保存的值，并清除已结束的任务中残留的值
+func (s *sqlStore) SetSecrets(secrets Secrets) {
+	s.secrets = secrets
+	if err := s.migrateSecrets(); err != nil {
+		slog.Warn("加密任务的请求头和 cookies 失败", "error", err)
+	}
+}
+
+// migrateSecrets 清除已结束的下载的请求头和 cookies，加密其余任务中的明文 JSON
+func (s *sqlStore) migrateSecrets() error {
+	if _, err := s.db.Exec(`UPDATE download_tasks SET request_headers = '', request_cookies = ''
+		WHERE status IN ('completed', 'failed', 'cancelled', 'interrupted')
+		AND (COALESCE(request_headers, '') <> '' OR COALESCE(request_cookies, '') <> '')`); err != nil {
+		return err
+	}
+	rows, err := s.db.Query(`SELECT id, COALESCE(request_headers, ''), COALESCE(request_cookies, '') FROM download_tasks
+		WHERE request_headers LIKE '{%' OR request_cookies LIKE '{%'`)
+	if err != nil {
+		return err
+	}
+	type row struct{ id, headers, cookies string }
+	var plain []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.headers, &r.cookies); err != nil {
+			rows.Close()
+			return err
+		}
+		plain = append(plain, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, r := range plain {
+		headers, cookies := s.encodeSecretMap(s.decodeSecretMap(r.headers)), s.encodeSecretMap(s.decodeSecretMap(r.cookies))
+		if _, err := s.db.Exec("UPDATE download_tasks SET request_headers = ?, request_cookies = ? WHERE id = ?", headers, cookies, r.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeSecretMap 请求头、cookies 等 map 序列化为 JSON 后加密，保存为 base64；
+// 为空或没有设置密钥时保存为空字符串
+func (s *sqlStore) encodeSecretMap(m map[string]string) string {
+	if len(m) == 0 || s.secrets == nil {
+		return ""
+	}
+	plain, _ := json.Marshal(m)
+	data, err := s.secrets.Seal(plain)
+	if err != nil {
+		slog.Warn("加密请求头失败，不保存到数据库", "error", err)
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// decodeSecretMap 解密 encodeSecretMap 的结果。以 { 开头的是之前的版本保存的明文 JSON；
+// 没有设置密钥或解密失败（密钥已更换）时返回 nil
+func (s *sqlStore) decodeSecretMap(text string) map[string]string {
+	if text == "" {
+		return nil
+	}
+	plain := []byte(text)
+	if !strings.HasPrefix(text, "{") {
+		if s.secrets == nil {
+			return nil
+		}
+		data, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return nil
+		}
+		if plain, err = s.secrets.Open(data); err != nil {
+			slog.Warn("解密任务的请求头失败", "error", err)
+			return nil
+		}
+	}
+	var m map[string]string
+	if err := json.Unmarshal(plain, &m); err != nil {
+		return nil
+	}
+	return m
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"zhihu-downloader/internal/auth"
+)
+
+func TestSecretMapEncrypted(t *testing.T) {
+	t.Setenv(auth.SecretEnv, "test-secret")
+	path := filepath.Join(t.TempDir(), "test.db")
+	s := openTestStore(t, path)
+	vault, err := auth.OpenVault(s, filepath.Join(t.TempDir(), "test.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 设置密钥之前以明文保存的值（之前的版本），设置后加密；已结束的任务的值被清除
+	now := time.Now()
+	for _, row := range []struct{ id, status string }{{"dl-1", "paused"}, {"dl-2", "completed"}} {
+		if _, err := s.db.Exec(`INSERT INTO download_tasks (id, status, percentage, elapsed_time, video_url, request_headers, request_cookies, created_at, updated_at)
+			VALUES (?, ?, 0, 0, 'https://example.com/a.m3u8', '{"Authorization":"Bearer t"}', '{"session":"s"}', ?, ?)`, row.id, row.status, now, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.SetSecrets(vault)
+
+	raw := func(id string) (headers, cookies string) {
+		s.db.QueryRow("SELECT COALESCE(request_headers, ''), COALESCE(request_cookies, '') FROM download_tasks WHERE id = ?", id).Scan(&headers, &cookies)
+		return
+	}
+	if h, c := raw("dl-1"); h == "" || strings.Contains(h, "Bearer") || strings.Contains(c, "session") {
+		t.Fatalf("明文没有被加密: %q %q", h, c)
+	}
+	if h, c := raw("dl-2"); h != "" || c != "" {
+		t.Fatalf("已完成的任务仍保存请求头: %q %q", h, c)
+	}
+	task, err := s.Download("dl-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Headers["Authorization"] != "Bearer t" || task.Cookies["session"] != "s" {
+		t.Fatalf("解密结果不正确: %v %v", task.Headers, task.Cookies)
+	}
+
+	// 新保存的值同样加密
+	task.Headers = map[string]string{"X-Token": "secret-value"}
+	if err := s.SaveDownload(task); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	s = openTestStore(t, path)
+	s.SetSecrets(vault)
+	if h, _ := raw("dl-1"); h == "" || strings.Contains(h, "secret-value") {
+		t.Fatalf("请求头没有加密保存: %q", h)
+	}
+	if task, err = s.Download("dl-1"); err != nil || task.Headers["X-Token"] != "secret-value" {
+		t.Fatalf("重新打开后解密失败: %v %v", err, task)
+	}
+}
+
+func TestSecretMapWithoutKey(t *testing.T) {
+	s := openTestStore(t, filepath.Join(t.TempDir(), "test.db"))
+	if got := s.encodeSecretMap(map[string]string{"X-Token": "v"}); got != "" {
+		t.Fatalf("没有密钥时不应该保存: %q", got)
+	}
+	if got := s.decodeSecretMap("AAAA"); got != nil {
+		t.Fatalf("没有密钥时不能解密: %v", got)
+	}
+}
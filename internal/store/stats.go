@@ -0,0 +1,79 @@
+package store
+
+import (
+	"zhihu-downloader/internal/tasks"
+)
+
+// Stats 用 SQL 汇总下载和转录任务。直接查询数据库，共用数据库的其他进程执行的任务也会统计在内；
+// 任务状态批量写入，最近几秒的变化可能还没有计入
+func (s *sqlStore) Stats(workspace string) (*tasks.Stats, error) {
+	stats := &tasks.Stats{}
+
+	// 平均速度只计算有耗时记录的下载
+	d := &stats.Downloads
+	var timedBytes, seconds int64
+	err := s.db.QueryRow(`
+		SELECT COUNT(*),
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 END), 0),
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 END), 0),
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 END), 0),
+			COALESCE(SUM(CASE WHEN status = ? THEN total_bytes END), 0),
+			COALESCE(SUM(CASE WHEN status = ? AND elapsed_time > 0 THEN total_bytes END), 0),
+			COALESCE(SUM(CASE WHEN status = ? AND elapsed_time > 0 THEN elapsed_time END), 0)
+		FROM download_tasks WHERE (? = '' OR workspace = ?)`,
+		tasks.StatusCompleted, tasks.StatusFailed, tasks.StatusCancelled,
+		tasks.StatusCompleted, tasks.StatusCompleted, tasks.StatusCompleted, workspace, workspace).
+		Scan(&d.Total, &d.Completed, &d.Failed, &d.Cancelled, &d.TotalBytes, &timedBytes, &seconds)
+	if err != nil {
+		return nil, err
+	}
+	if seconds > 0 {
+		d.AverageSpeed = float64(timedBytes) / float64(seconds)
+	}
+
+	t := &stats.Transcriptions
+	err = s.db.QueryRow(`
+		SELECT COUNT(*),
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 END), 0),
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 END), 0),
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 END), 0)
+		FROM transcribe_tasks WHERE (? = '' OR workspace = ?)`,
+		tasks.StatusCompleted, tasks.StatusFailed, tasks.StatusCancelled, workspace, workspace).
+		Scan(&t.Total, &t.Completed, &t.Failed, &t.Cancelled)
+	if err != nil {
+		return nil, err
+	}
+	// 每个转录的时长取最后一段的结束时间
+	var audioSeconds float64
+	err = s.db.QueryRow(`
+		SELECT COALESCE(SUM(duration), 0) FROM (
+			SELECT MAX(s.end_time) AS duration
+			FROM transcript_segments s JOIN transcribe_tasks t ON t.id = s.task_id
+			WHERE t.status = ? AND (? = '' OR t.workspace = ?)
+			GROUP BY s.task_id
+		) d`, tasks.StatusCompleted, workspace, workspace).Scan(&audioSeconds)
+	if err != nil {
+		return nil, err
+	}
+	t.TranscriptHours = float64(int(audioSeconds/3600*100+0.5)) / 100
+
+	// SQLite 中时间按写入时的本地时间保存（"2006-01-02 15:04:05..."），前 10 个字符即为本地日期；
+	// Postgres 转为文本时使用连接的时区
+	rows, err := s.db.Query(`
+		SELECT substr(CAST(updated_at AS TEXT), 1, 10) AS day, COUNT(*), COALESCE(SUM(total_bytes), 0)
+		FROM download_tasks WHERE status = ? AND (? = '' OR workspace = ?)
+		GROUP BY day ORDER BY COUNT(*) DESC, day DESC LIMIT ?`,
+		tasks.StatusCompleted, workspace, workspace, tasks.BusiestDaysLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day tasks.DayStats
+		if err := rows.Scan(&day.Date, &day.Downloads, &day.Bytes); err != nil {
+			return nil, err
+		}
+		stats.BusiestDays = append(stats.BusiestDays, day)
+	}
+	return stats, rows.Err()
+}
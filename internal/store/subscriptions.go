@@ -0,0 +1,154 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+
+	"zhihu-downloader/internal/tasks"
+)
+
+// 订阅：subscriptions 每个订阅一行，subscription_items 记录每个订阅已经处理过的视频及创建的任务
+func (s *sqlStore) migrateSubscriptions() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS subscriptions (
+			id TEXT PRIMARY KEY,
+			url TEXT NOT NULL,
+			type TEXT NOT NULL,
+			title TEXT,
+			quality TEXT,
+			backend TEXT,
+			output_dir TEXT,
+			filename_template TEXT,
+			max_items INTEGER DEFAULT 0,
+			interval_minutes INTEGER DEFAULT 0,
+			transcribe INTEGER DEFAULT 0,
+			language TEXT,
+			model TEXT,
+			skip_existing INTEGER DEFAULT 0,
+			enabled INTEGER DEFAULT 1,
+			workspace TEXT,
+			last_check DATETIME,
+			next_check DATETIME,
+			last_error TEXT,
+			last_new INTEGER DEFAULT 0,
+			downloaded INTEGER DEFAULT 0,
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS subscription_items (
+			subscription_id TEXT NOT NULL,
+			url TEXT NOT NULL,
+			task_id TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (subscription_id, url)
+		)
+	`)
+	return err
+}
+
+const subscriptionColumns = `
+	id, url, type, COALESCE(title, ''), COALESCE(quality, ''), COALESCE(backend, ''), COALESCE(output_dir, ''),
+	COALESCE(filename_template, ''), COALESCE(max_items, 0), COALESCE(interval_minutes, 0),
+	COALESCE(transcribe, 0), COALESCE(language, ''), COALESCE(model, ''), COALESCE(skip_existing, 0), COALESCE(enabled, 1),
+	COALESCE(workspace, ''), last_check, next_check, COALESCE(last_error, ''), COALESCE(last_new, 0), COALESCE(downloaded, 0),
+	created_at, updated_at`
+
+// SaveSubscription 保存订阅
+func (s *sqlStore) SaveSubscription(sub *tasks.Subscription) error {
+	return s.writeTx("subscription:"+sub.ID, func(tx *sql.Tx) error {
+		_, err := tx.Exec(upsert("subscriptions", "id", `
+			id, url, type, title, quality, backend, output_dir, filename_template, max_items, interval_minutes,
+			transcribe, language, model, skip_existing, enabled, workspace, last_check, next_check, last_error, last_new, downloaded,
+			created_at, updated_at`),
+			sub.ID, sub.URL, sub.Type, sub.Title, sub.Quality, sub.Backend, sub.OutputDir, sub.FilenameTemplate, sub.Limit, sub.IntervalMinutes,
+			sub.Transcribe, sub.Language, sub.Model, sub.SkipExisting, sub.Enabled, sub.Workspace, sub.LastCheck, sub.NextCheck, sub.LastError,
+			sub.LastNew, sub.Downloaded, sub.CreatedAt, sub.UpdatedAt)
+		return err
+	})
+}
+
+// DeleteSubscription 删除订阅及其视频记录
+func (s *sqlStore) DeleteSubscription(id string) error {
+	return s.writeTx("subscription:"+id, func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM subscription_items WHERE subscription_id = ?", id); err != nil {
+			return err
+		}
+		_, err := tx.Exec("DELETE FROM subscriptions WHERE id = ?", id)
+		return err
+	})
+}
+
+// Subscription 返回订阅，不存在时返回 nil
+func (s *sqlStore) Subscription(id string) (*tasks.Subscription, error) {
+	sub, err := scanSubscription(s.db.QueryRow("SELECT "+subscriptionColumns+" FROM subscriptions WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return sub, err
+}
+
+// Subscriptions 返回所有订阅
+func (s *sqlStore) Subscriptions() ([]*tasks.Subscription, error) {
+	rows, err := s.db.Query("SELECT " + subscriptionColumns + " FROM subscriptions ORDER BY created_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []*tasks.Subscription{}
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			continue
+		}
+		list = append(list, sub)
+	}
+	return list, rows.Err()
+}
+
+func scanSubscription(row scanner) (*tasks.Subscription, error) {
+	sub := &tasks.Subscription{}
+	var lastCheck, nextCheck sql.NullTime
+	err := row.Scan(&sub.ID, &sub.URL, &sub.Type, &sub.Title, &sub.Quality, &sub.Backend, &sub.OutputDir,
+		&sub.FilenameTemplate, &sub.Limit, &sub.IntervalMinutes,
+		&sub.Transcribe, &sub.Language, &sub.Model, &sub.SkipExisting, &sub.Enabled,
+		&sub.Workspace, &lastCheck, &nextCheck, &sub.LastError, &sub.LastNew, &sub.Downloaded,
+		&sub.CreatedAt, &sub.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if lastCheck.Valid {
+		sub.LastCheck = &lastCheck.Time
+	}
+	if nextCheck.Valid {
+		sub.NextCheck = &nextCheck.Time
+	}
+	return sub, nil
+}
+
+// SaveSubscriptionItem 记录订阅已经处理过的视频
+func (s *sqlStore) SaveSubscriptionItem(id, url, taskID string) error {
+	return s.writeTx("subscription_item:"+id+":"+url, func(tx *sql.Tx) error {
+		_, err := tx.Exec(upsert("subscription_items", "subscription_id, url", "subscription_id, url, task_id"), id, url, taskID)
+		return err
+	})
+}
+
+// SubscriptionItems 返回订阅已经处理过的视频链接到任务 ID 的映射
+func (s *sqlStore) SubscriptionItems(id string) (map[string]string, error) {
+	rows, err := s.db.Query("SELECT url, COALESCE(task_id, '') FROM subscription_items WHERE subscription_id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := map[string]string{}
+	for rows.Next() {
+		var url, taskID string
+		if err := rows.Scan(&url, &taskID); err != nil {
+			return nil, err
+		}
+		items[url] = taskID
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,243 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"zhihu-downloader/internal/tasks"
+	"zhihu-downloader/internal/transcriber"
+)
+
+// 转录全文搜索：transcript_segments 每段转录文本一行。启用了 FTS5（构建时加上 -tags sqlite_fts5）时
+// 再建立 trigram 分词的 transcript_fts 索引，由触发器与 transcript_segments 同步，中文也能按子串搜索；
+// 没有 FTS5 时直接逐行匹配 transcript_segments。Postgres 不建全文索引，用 ILIKE 逐行匹配
+func (s *sqlStore) migrateSearch() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS transcript_segments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id TEXT NOT NULL,
+			start_time REAL,
+			end_time REAL,
+			text TEXT NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.Exec("CREATE INDEX IF NOT EXISTS idx_transcript_segments_task ON transcript_segments(task_id)"); err != nil {
+		return err
+	}
+
+	if !s.postgres {
+		if err := s.db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&s.fts); err != nil {
+			return err
+		}
+	}
+	switch {
+	case s.fts:
+		_, err = s.db.Exec(`
+			CREATE VIRTUAL TABLE IF NOT EXISTS transcript_fts USING fts5(
+				text, content = 'transcript_segments', content_rowid = 'id', tokenize = 'trigram'
+			)
+		`)
+		if err != nil {
+			return err
+		}
+		if err := s.createSearchTriggers(); err != nil {
+			return err
+		}
+	case !s.postgres:
+		// 没有 FTS5 时写入 transcript_segments 不能触发同步索引的触发器，启用 FTS5 的程序再次打开时会重建索引
+		slog.Warn("SQLite 没有启用 FTS5，转录搜索使用逐行匹配（构建时加上 -tags sqlite_fts5 启用）")
+		for _, name := range []string{"transcript_segments_ai", "transcript_segments_ad"} {
+			if _, err := s.db.Exec("DROP TRIGGER IF EXISTS " + name); err != nil {
+				return err
+			}
+		}
+	}
+
+	s.indexTranscripts()
+	return nil
+}
+
+// createSearchTriggers 创建同步 FTS5 索引的触发器。触发器不存在（新数据库，或被没有 FTS5 的程序删除）时重建索引
+func (s *sqlStore) createSearchTriggers() error {
+	var n int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name = 'transcript_segments_ai'").Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	_, err := s.db.Exec(`
+		CREATE TRIGGER IF NOT EXISTS transcript_segments_ai AFTER INSERT ON transcript_segments BEGIN
+			INSERT INTO transcript_fts(rowid, text) VALUES (new.id, new.text);
+		END;
+		CREATE TRIGGER IF NOT EXISTS transcript_segments_ad AFTER DELETE ON transcript_segments BEGIN
+			INSERT INTO transcript_fts(transcript_fts, rowid, text) VALUES ('delete', old.id, old.text);
+		END;
+		INSERT INTO transcript_fts(transcript_fts) VALUES ('rebuild');
+	`)
+	return err
+}
+
+// indexTranscripts 为还没有建立搜索索引的转录结果建立索引（例如升级前保存的转录结果）。
+// 失败只记录日志，不影响启动
+func (s *sqlStore) indexTranscripts() {
+	rows, err := s.db.Query(`
+		SELECT task_id, data FROM transcripts
+		WHERE task_id NOT IN (SELECT DISTINCT task_id FROM transcript_segments)`)
+	if err != nil {
+		slog.Warn("建立转录搜索索引失败", "error", err)
+		return
+	}
+	pending := map[string]*transcriber.Transcript{}
+	for rows.Next() {
+		var id, data string
+		var t transcriber.Transcript
+		if rows.Scan(&id, &data) == nil && json.Unmarshal([]byte(data), &t) == nil && len(t.Segments) > 0 {
+			pending[id] = &t
+		}
+	}
+	rows.Close()
+
+	for id, t := range pending {
+		tx, err := s.db.Begin()
+		if err != nil {
+			slog.Warn("建立转录搜索索引失败", "task_id", id, "error", err)
+			return
+		}
+		if err := replaceSegments(tx, id, t.Segments); err != nil {
+			tx.Rollback()
+			slog.Warn("建立转录搜索索引失败", "task_id", id, "error", err)
+			continue
+		}
+		if err := tx.Commit(); err != nil {
+			slog.Warn("建立转录搜索索引失败", "task_id", id, "error", err)
+		}
+	}
+	if len(pending) > 0 {
+		slog.Info("已为转录结果建立搜索索引", "count", len(pending))
+	}
+}
+
+// replaceSegments 用 segments 替换任务在搜索索引中的所有段落
+func replaceSegments(tx *sql.Tx, taskID string, segments []transcriber.Segment) error {
+	if _, err := tx.Exec("DELETE FROM transcript_segments WHERE task_id = ?", taskID); err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare("INSERT INTO transcript_segments (task_id, start_time, end_time, text) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, seg := range segments {
+		if _, err := stmt.Exec(taskID, seg.Start, seg.End, seg.Text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveTranscript 保存转录任务的结构化结果，并更新搜索索引
+func (s *sqlStore) SaveTranscript(taskID string, t *transcriber.Transcript) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return s.writeTx("transcript:"+taskID, func(tx *sql.Tx) error {
+		_, err := tx.Exec(upsert("transcripts", "task_id", "task_id, data, created_at"),
+			taskID, string(data), time.Now())
+		if err != nil {
+			return err
+		}
+		return replaceSegments(tx, taskID, t.Segments)
+	})
+}
+
+// deleteTranscript 删除转录结果和搜索索引
+func (s *sqlStore) deleteTranscript(taskID string) error {
+	return s.writeTx("transcript:"+taskID, func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM transcripts WHERE task_id = ?", taskID); err != nil {
+			return err
+		}
+		_, err := tx.Exec("DELETE FROM transcript_segments WHERE task_id = ?", taskID)
+		return err
+	})
+}
+
+// LookupTranscript 查找转录任务的结构化结果，没有记录时返回 nil。
+// 直接查询数据库，共用数据库的其他进程转录的结果也能找到
+func (s *sqlStore) LookupTranscript(taskID string) (*transcriber.Transcript, error) {
+	var data string
+	err := s.db.QueryRow("SELECT data FROM transcripts WHERE task_id = ?", taskID).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var t transcriber.Transcript
+	if err := json.Unmarshal([]byte(data), &t); err != nil {
+		return nil, fmt.Errorf("解析转录结果失败: %v", err)
+	}
+	return &t, nil
+}
+
+// SearchTranscripts 搜索包含所有关键词（不区分大小写）的转录段落，最新的转录在前，最多返回 limit 段。
+// 使用 FTS5 时长度不少于 3 个字符的关键词走索引，更短的关键词逐行匹配；workspace 不为空时在截取之前按工作区筛选
+func (s *sqlStore) SearchTranscripts(keywords []string, workspace string, limit int) ([]tasks.TranscriptMatch, error) {
+	if len(keywords) == 0 {
+		return nil, nil
+	}
+	// SQLite 的 LIKE 本身不区分（ASCII 字母的）大小写
+	like := "LIKE"
+	if s.postgres {
+		like = "ILIKE"
+	}
+	conds := make([]string, len(keywords))
+	args := make([]interface{}, 0, len(keywords)+2)
+	for i, k := range keywords {
+		conds[i] = "text " + like + ` ? ESCAPE '\'`
+		args = append(args, "%"+escapeLike(k)+"%")
+	}
+	where := strings.Join(conds, " AND ")
+	if s.fts {
+		where = "id IN (SELECT rowid FROM transcript_fts WHERE " + where + ")"
+	}
+	if workspace != "" {
+		where += " AND " + workspaceTranscripts
+		args = append(args, workspace)
+	}
+	args = append(args, limit)
+
+	rows, err := s.db.Query(`
+		SELECT task_id, COALESCE(start_time, 0), COALESCE(end_time, 0), text FROM transcript_segments
+		WHERE `+where+` ORDER BY id DESC LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []tasks.TranscriptMatch
+	for rows.Next() {
+		var m tasks.TranscriptMatch
+		if err := rows.Scan(&m.TaskID, &m.Start, &m.End, &m.Text); err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+// workspaceTranscripts 只保留一个工作区的转录任务的条件，参数为工作区名称
+const workspaceTranscripts = "task_id IN (SELECT id FROM transcribe_tasks WHERE workspace = ?)"
+
+// escapeLike 转义 LIKE 中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
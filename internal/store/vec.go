@@ -0,0 +1,11 @@
+//go:build sqlite_vec
+
+package store
+
+import vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
+
+// 构建时加上 -tags sqlite_vec 时，之后打开的 SQLite 连接都会加载 sqlite-vec，
+// 语义搜索在数据库中计算向量距离，见 embeddings.go
+func init() {
+	vec.Auto()
+}
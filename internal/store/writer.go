@@ -0,0 +1,165 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"zhihu-downloader/internal/tasks"
+)
+
+// flushInterval 进度更新的最长写入延迟。下载和转录每秒会更新多次进度，
+// 同一任务在这段时间内的多次更新只写入最后一次
+const flushInterval = time.Second
+
+// writeOp 一次写操作。done 不为 nil 时写入后立即返回结果，否则等待下次批量写入。
+// exec 不为 nil 时执行 exec（需要多条语句的写入），否则执行 stmt
+type writeOp struct {
+	key  string
+	stmt *sql.Stmt
+	args []interface{}
+	exec func(tx *sql.Tx) error
+	done chan error
+}
+
+// write 提交写操作。任务状态变化、删除和 status 为空的写入立即执行并返回结果；
+// 状态不变的进度更新合并后每 flushInterval 批量写入一次
+func (s *sqlStore) write(key string, status tasks.Status, stmt *sql.Stmt, args ...interface{}) error {
+	op := &writeOp{key: key, stmt: stmt, args: args}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return fmt.Errorf("数据库已关闭")
+	}
+	last, seen := s.lastStatus[key]
+	switch {
+	case status == "":
+		delete(s.lastStatus, key)
+		op.done = make(chan error, 1)
+	case !seen || last != status:
+		s.lastStatus[key] = status
+		op.done = make(chan error, 1)
+	}
+	// 持有锁发送，保证 Close 之后不会再有写操作
+	s.ops <- op
+	s.mu.Unlock()
+
+	if op.done == nil {
+		return nil
+	}
+	return <-op.done
+}
+
+// writeTx 在写入 goroutine 的事务中执行 fn 并等待结果，用于需要多条语句的写入
+func (s *sqlStore) writeTx(key string, fn func(tx *sql.Tx) error) error {
+	op := &writeOp{key: key, exec: fn, done: make(chan error, 1)}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return fmt.Errorf("数据库已关闭")
+	}
+	delete(s.lastStatus, key)
+	s.ops <- op
+	s.mu.Unlock()
+	return <-op.done
+}
+
+// runWriter 唯一的写入 goroutine：所有任务写操作在这里合并成事务执行，
+// 避免多个任务同时写入时出现 database is locked
+func (s *sqlStore) runWriter() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	var pending []*writeOp
+	index := map[string]int{}
+	add := func(op *writeOp) {
+		if i, ok := index[op.key]; ok {
+			// 同一任务只保留最后一次写入
+			pending[i] = op
+			return
+		}
+		index[op.key] = len(pending)
+		pending = append(pending, op)
+	}
+	flush := func() {
+		if len(pending) > 0 {
+			s.flush(pending)
+			pending = pending[:0]
+			clear(index)
+		}
+	}
+
+	for {
+		select {
+		case op, ok := <-s.ops:
+			if !ok {
+				flush()
+				return
+			}
+			add(op)
+			if op.done != nil {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// flush 在一个事务中执行一批写操作
+func (s *sqlStore) flush(ops []*writeOp) {
+	errs := make([]error, len(ops))
+	tx, err := s.db.Begin()
+	if err == nil {
+		for i, op := range ops {
+			switch {
+			case op.exec != nil:
+				errs[i] = execSavepoint(tx, op.exec)
+			case s.postgres:
+				// Postgres 中一条语句失败后整个事务都不能再执行，每条语句都放在保存点中
+				errs[i] = execSavepoint(tx, func(tx *sql.Tx) error {
+					_, err := tx.Stmt(op.stmt).Exec(op.args...)
+					return err
+				})
+			default:
+				_, errs[i] = tx.Stmt(op.stmt).Exec(op.args...)
+			}
+			if errs[i] != nil {
+				slog.Warn("保存任务失败", "key", op.key, "error", errs[i])
+			}
+		}
+		err = tx.Commit()
+	}
+	if err != nil {
+		slog.Warn("写入数据库失败", "count", len(ops), "error", err)
+	}
+
+	for i, op := range ops {
+		if op.done == nil {
+			continue
+		}
+		if err != nil {
+			errs[i] = err
+		}
+		op.done <- errs[i]
+	}
+}
+
+// execSavepoint 在保存点中执行 fn，失败时只撤销 fn 的写入，不影响同一批的其他写操作
+func execSavepoint(tx *sql.Tx, fn func(tx *sql.Tx) error) error {
+	if _, err := tx.Exec("SAVEPOINT write_op"); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Exec("ROLLBACK TO write_op")
+		tx.Exec("RELEASE write_op")
+		return err
+	}
+	_, err := tx.Exec("RELEASE write_op")
+	return err
+}
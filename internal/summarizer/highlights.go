@@ -0,0 +1,62 @@
+package summarizer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"zhihu-downloader/internal/logging"
+)
+
+// Highlight 大模型挑选出的精彩片段，时间为秒
+type Highlight struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	// Title 一句话说明片段的内容
+	Title string `json:"title"`
+}
+
+const highlightPrompt = `你是视频剪辑助手。用户提供视频的转录文本，每行格式为“[开始秒数-结束秒数] 文本”。
+请挑选出最多 %d 个最值得单独剪辑成短视频的精彩片段（核心观点、金句、有趣或信息量大的段落），每个片段 10–120 秒，片段之间不要重叠。
+只输出 JSON 数组，不要输出其他内容，每项为 {"start": 开始秒数, "end": 结束秒数, "title": "一句话说明，使用与转录文本相同的语言"}，按时间先后排列。
+转录文本由语音识别生成，可能有错别字，请根据上下文理解，时间必须取自文本中的时间。`
+
+// Highlights 请大模型从 transcript（每行为 “[start-end] 文本”）中挑选最多 limit 个精彩片段
+func Highlights(ctx context.Context, transcript string, limit int) ([]Highlight, error) {
+	cfg := currentConfig()
+	if cfg.APIKey == "" && cfg.BaseURL == DefaultBaseURL {
+		return nil, fmt.Errorf("未配置大模型接口（summary.api_key / ZHIHU_LLM_API_KEY）")
+	}
+	if strings.TrimSpace(transcript) == "" {
+		return nil, fmt.Errorf("转录文本为空")
+	}
+	if runes := []rune(transcript); len(runes) > cfg.MaxChars {
+		transcript = string(runes[:cfg.MaxChars])
+	}
+	logging.FromContext(ctx).Info("开始提取精彩片段", "model", cfg.Model, "chars", len([]rune(transcript)), "limit", limit)
+
+	content, err := chat(ctx, cfg, fmt.Sprintf(highlightPrompt, limit), transcript)
+	if err != nil {
+		return nil, err
+	}
+	// 模型可能把 JSON 放在代码块中
+	content = strings.TrimSpace(content)
+	if start, end := strings.Index(content, "["), strings.LastIndex(content, "]"); start >= 0 && end > start {
+		content = content[start : end+1]
+	}
+	var highlights []Highlight
+	if err := json.Unmarshal([]byte(content), &highlights); err != nil {
+		return nil, fmt.Errorf("大模型返回的精彩片段不是有效的 JSON: %s", truncate(content, 200))
+	}
+	valid := highlights[:0]
+	for _, h := range highlights {
+		if h.Start >= 0 && h.End > h.Start {
+			valid = append(valid, h)
+		}
+	}
+	if len(valid) > limit {
+		valid = valid[:limit]
+	}
+	return valid, nil
+}
@@ -0,0 +1,234 @@
+// Package summarizer 调用 OpenAI 兼容的大模型接口（OpenAI、DeepSeek、Ollama 等），
+// 根据转录文本生成摘要、要点和章节，保存为转录文本旁边的 Markdown 文件。
+package summarizer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"zhihu-downloader/internal/atomicfile"
+	"zhihu-downloader/internal/logging"
+)
+
+// 默认配置
+const (
+	DefaultBaseURL  = "https://api.openai.com/v1"
+	DefaultModel    = "gpt-4o-mini"
+	DefaultMaxChars = 60000
+)
+
+// Config 大模型接口配置
+type Config struct {
+	// BaseURL OpenAI 兼容接口地址（不含 /chat/completions），默认 https://api.openai.com/v1
+	BaseURL string
+	// APIKey 访问令牌，本地模型（如 Ollama）可以为空
+	APIKey string
+	// Model 模型名称，默认 gpt-4o-mini
+	Model string
+	// MaxChars 发送给模型的最多字符数，超出部分截断，默认 60000
+	MaxChars int
+	// Auto 转录完成后自动生成摘要
+	Auto bool
+}
+
+var (
+	configMu sync.RWMutex
+	config   Config
+)
+
+var httpClient = &http.Client{Timeout: 5 * time.Minute}
+
+// SetConfig 设置大模型接口配置
+func SetConfig(c Config) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	config = c
+}
+
+func currentConfig() Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	c := config
+	if c.BaseURL == "" {
+		c.BaseURL = DefaultBaseURL
+	}
+	if c.Model == "" {
+		c.Model = DefaultModel
+	}
+	if c.MaxChars <= 0 {
+		c.MaxChars = DefaultMaxChars
+	}
+	return c
+}
+
+// Auto 是否在转录完成后自动生成摘要
+func Auto() bool {
+	return currentConfig().Auto
+}
+
+// Path 返回转录文本对应的摘要文件路径：video.txt → video.summary.md
+func Path(txtPath string) string {
+	return strings.TrimSuffix(txtPath, filepath.Ext(txtPath)) + ".summary.md"
+}
+
+const systemPrompt = `你是视频内容整理助手。根据用户提供的视频转录文本，使用与转录文本相同的语言，输出 Markdown，包含且只包含以下三个二级标题：
+## 摘要
+一到两段话概括视频内容。
+## 要点
+5–10 条要点的无序列表。
+## 章节
+按内容先后划分章节的有序列表，每项为“章节标题：一句话说明”；如果文本带有 [mm:ss] 时间戳，在章节标题前注明开始时间。
+转录文本由语音识别生成，可能有错别字，请根据上下文理解，不要编造文本中没有的内容。`
+
+// Summarize 为 txtPath 生成摘要并保存到 Path(txtPath)，返回摘要文件路径
+func Summarize(ctx context.Context, txtPath string) (string, error) {
+	cfg := currentConfig()
+	if cfg.APIKey == "" && cfg.BaseURL == DefaultBaseURL {
+		return "", fmt.Errorf("未配置大模型接口（summary.api_key / ZHIHU_LLM_API_KEY）")
+	}
+
+	transcript, err := readTranscript(txtPath)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(transcript) == "" {
+		return "", fmt.Errorf("转录文本为空: %s", txtPath)
+	}
+	runes := []rune(transcript)
+	truncated := len(runes) > cfg.MaxChars
+	if truncated {
+		transcript = string(runes[:cfg.MaxChars])
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.Info("开始生成摘要", "model", cfg.Model, "chars", len([]rune(transcript)), "truncated", truncated)
+
+	content, err := chat(ctx, cfg, systemPrompt, transcript)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", strings.TrimSuffix(filepath.Base(txtPath), filepath.Ext(txtPath)))
+	b.WriteString(strings.TrimSpace(content))
+	b.WriteString("\n")
+	if truncated {
+		fmt.Fprintf(&b, "\n> 转录文本较长，摘要只基于前 %d 个字符。\n", cfg.MaxChars)
+	}
+
+	path := Path(txtPath)
+	if err := atomicfile.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return "", fmt.Errorf("写入摘要失败: %v", err)
+	}
+	logger.Info("摘要已保存", "summary_path", path)
+	return path, nil
+}
+
+// readTranscript 读取转录文本。区分说话人时生成的同名 .json 带有时间戳，
+// 优先使用它以便模型给出章节的开始时间
+func readTranscript(txtPath string) (string, error) {
+	jsonPath := strings.TrimSuffix(txtPath, filepath.Ext(txtPath)) + ".json"
+	if data, err := os.ReadFile(jsonPath); err == nil {
+		var doc struct {
+			Segments []struct {
+				Start   float64 `json:"start"`
+				Speaker string  `json:"speaker"`
+				Text    string  `json:"text"`
+			} `json:"segments"`
+		}
+		if json.Unmarshal(data, &doc) == nil && len(doc.Segments) > 0 {
+			var b strings.Builder
+			for _, s := range doc.Segments {
+				fmt.Fprintf(&b, "[%02d:%02d] ", int(s.Start)/60, int(s.Start)%60)
+				if s.Speaker != "" {
+					b.WriteString(s.Speaker + ": ")
+				}
+				b.WriteString(s.Text + "\n")
+			}
+			return b.String(), nil
+		}
+	}
+
+	data, err := os.ReadFile(txtPath)
+	if err != nil {
+		return "", fmt.Errorf("读取转录文本失败: %v", err)
+	}
+	return string(data), nil
+}
+
+type message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// chat 调用 /chat/completions，返回模型回复
+func chat(ctx context.Context, cfg Config, system, user string) (string, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"model": cfg.Model,
+		"messages": []message{
+			{Role: "system", Content: system},
+			{Role: "user", Content: user},
+		},
+		"temperature": 0.3,
+	})
+	endpoint := strings.TrimRight(cfg.BaseURL, "/") + "/chat/completions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("请求大模型接口失败: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("读取大模型响应失败: %v", err)
+	}
+
+	var result struct {
+		Choices []struct {
+			Message message `json:"message"`
+		} `json:"choices"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("大模型接口返回 HTTP %d: %s", resp.StatusCode, truncate(string(data), 200))
+	}
+	if result.Error != nil {
+		return "", fmt.Errorf("大模型接口返回错误: %s", result.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("大模型接口返回 HTTP %d", resp.StatusCode)
+	}
+	if len(result.Choices) == 0 || strings.TrimSpace(result.Choices[0].Message.Content) == "" {
+		return "", fmt.Errorf("大模型没有返回内容")
+	}
+	return result.Choices[0].Message.Content, nil
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "..."
+	}
+	return s
+}
@@ -0,0 +1,48 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	"zhihu-downloader/internal/backoff"
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/logging"
+)
+
+// 下载任务失败后自动重试前等待 2s、4s、8s……（加随机抖动），最多 1 分钟
+const (
+	downloadRetryBase = 2 * time.Second
+	downloadRetryMax  = time.Minute
+)
+
+// WithMaxRetries 设置下载任务失败后自动重试的次数（默认 downloader.DefaultMaxRetries），0 表示不重试
+func WithMaxRetries(n int) Option {
+	return func(m *Manager) {
+		if n >= 0 {
+			m.maxRetries = n
+		}
+	}
+}
+
+// downloadWithRetry 执行下载，暂时的错误（网络中断、服务器 5xx、下载程序异常退出等）按指数退避重新下载，
+// 重试次数记录在任务的 Retries 中。m3u8 已下载的分片保留在临时目录，重试时不会重新下载
+func (m *Manager) downloadWithRetry(ctx context.Context, task *DownloadTask, req downloader.Request, onProgress func(downloader.Progress)) (*downloader.Result, error) {
+	logger := logging.FromContext(ctx)
+	for attempt := 1; ; attempt++ {
+		result, err := downloader.Download(ctx, req, onProgress)
+		if err == nil || ctx.Err() != nil || attempt > m.maxRetries || !downloader.Retryable(err) {
+			return result, err
+		}
+
+		delay := backoff.Delay(attempt, downloadRetryBase, downloadRetryMax)
+		logger.Warn("下载失败，稍后重试", "attempt", attempt, "max_retries", m.maxRetries, "delay", delay.Round(time.Millisecond), "error", err)
+		m.updateDownload(task, func(t *DownloadTask) {
+			t.Retries++
+			t.Speed = ""
+			t.ETASeconds = 0
+		})
+		if err := backoff.Sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package tasks
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/transcriber"
+)
+
+const (
+	// DefaultBatchPattern 批量转录默认匹配的文件
+	DefaultBatchPattern = "*.mp4"
+	// MaxBatchFiles 一次批量转录最多匹配的文件数
+	MaxBatchFiles = 1000
+)
+
+// ErrBatchNotFound 批量转录不存在
+var ErrBatchNotFound = errcode.New(errcode.NotFound, "批量转录不存在")
+
+// TranscribeBatch 批量转录：为目录中匹配的每个视频创建一个转录子任务。
+// 子任务和单独创建的转录任务一样排队、通知和查询；批量转录只记录子任务，进度和状态在查询时按子任务汇总
+type TranscribeBatch struct {
+	ID        string `json:"id"`
+	Dir       string `json:"dir"`
+	Pattern   string `json:"pattern"`
+	Workspace string `json:"workspace,omitempty"`
+	// TaskIDs 转录子任务 ID，按文件名排序
+	TaskIDs []string `json:"task_ids"`
+	// Skipped 匹配但没有转录的文件及原因
+	Skipped   []SkippedFile `json:"skipped"`
+	CreatedAt time.Time     `json:"created_at"`
+
+	// 以下按子任务汇总，不保存。已删除的子任务不计入
+	Status     Status `json:"status"`
+	Percentage int    `json:"percentage"`
+	Total      int    `json:"total"`
+	Completed  int    `json:"completed"`
+	Failed     int    `json:"failed"`
+	Cancelled  int    `json:"cancelled"`
+	// Running 正在转录，Queued 排队或等待开始
+	Running int `json:"running"`
+	Queued  int `json:"queued"`
+}
+
+// SkippedFile 批量转录跳过的文件
+type SkippedFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// StartTranscribeBatch 转录 dir 中匹配 pattern（只匹配文件名，默认 *.mp4）的所有视频。
+// 已有同名 .txt 或 .srt、已经转录完成或正在转录的文件跳过，其余每个文件按 req 创建一个转录任务
+// （req.VideoPath 和 OutputFilename 不使用，可以用 FilenameTemplate 指定文件名）。配置了工作区时相对路径相对于工作区目录
+func (m *Manager) StartTranscribeBatch(dir, pattern string, req transcriber.Request) (*TranscribeBatch, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return nil, errcode.New(errcode.InvalidArgument, "dir 必填")
+	}
+	dir = ExpandHome(dir)
+	if req.Workspace != "" && !filepath.IsAbs(dir) {
+		ws, ok := m.Workspace(req.Workspace)
+		if !ok {
+			return nil, fmt.Errorf("工作区不存在: %s", req.Workspace)
+		}
+		dir = filepath.Join(ws.OutputDir, dir)
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.CheckWorkspacePath(req.Workspace, dir); err != nil {
+		return nil, err
+	}
+	if err := m.CheckPath(dir); err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, errcode.Newf(errcode.NotFound, "目录不存在: %s", dir)
+	}
+
+	if pattern = strings.TrimSpace(pattern); pattern == "" {
+		pattern = DefaultBatchPattern
+	}
+	if strings.ContainsAny(pattern, `/\`) {
+		return nil, errcode.New(errcode.InvalidArgument, "pattern 只能匹配文件名，不能包含目录")
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, errcode.Newf(errcode.InvalidArgument, "无效的 pattern: %s", pattern)
+	}
+	if err := downloader.ValidateFilenameTemplate(req.FilenameTemplate); err != nil {
+		return nil, err
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+	var files []string
+	for _, path := range matches {
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			files = append(files, path)
+		}
+	}
+	if len(files) == 0 {
+		return nil, errcode.Newf(errcode.NotFound, "%s 中没有匹配 %s 的文件", dir, pattern)
+	}
+	if len(files) > MaxBatchFiles {
+		return nil, errcode.Newf(errcode.InvalidArgument, "匹配的文件太多（%d 个，最多 %d 个），请缩小 pattern", len(files), MaxBatchFiles)
+	}
+	sort.Strings(files)
+
+	batch := &TranscribeBatch{
+		ID:        m.newID(KindBatch),
+		Dir:       dir,
+		Pattern:   pattern,
+		Workspace: req.Workspace,
+		TaskIDs:   []string{},
+		Skipped:   []SkippedFile{},
+		CreatedAt: time.Now(),
+	}
+	transcribed := m.transcribedVideos()
+	for _, path := range files {
+		child := req
+		child.VideoPath = path
+		child.OutputFilename = ""
+		if reason := m.skipReason(child, transcribed); reason != "" {
+			batch.Skipped = append(batch.Skipped, SkippedFile{Path: path, Reason: reason})
+			continue
+		}
+		task, err := m.StartTranscribe(child)
+		if err != nil {
+			batch.Skipped = append(batch.Skipped, SkippedFile{Path: path, Reason: err.Error()})
+			continue
+		}
+		batch.TaskIDs = append(batch.TaskIDs, task.ID)
+	}
+
+	if err := m.saveBatch(batch); err != nil {
+		return nil, fmt.Errorf("保存批量转录失败: %v", err)
+	}
+	slog.Info("已创建批量转录", "batch_id", batch.ID, "dir", dir, "pattern", pattern,
+		"tasks", len(batch.TaskIDs), "skipped", len(batch.Skipped))
+	m.fillBatch(batch)
+	return batch, nil
+}
+
+// transcribedVideos 返回已经转录完成或正在转录的视频路径和对应的说明
+func (m *Manager) transcribedVideos() map[string]string {
+	done := map[string]string{}
+	for _, t := range m.Transcribes() {
+		switch {
+		case t.Status == StatusCompleted:
+			done[t.VideoPath] = "已经转录完成（" + t.ID + "）"
+		case !t.Status.Terminal():
+			done[t.VideoPath] = "正在转录（" + t.ID + "）"
+		}
+	}
+	return done
+}
+
+// skipReason 返回不需要转录的原因：输出目录中已有同名（或按文件名模板生成的）.txt / .srt，或已有转录任务
+func (m *Manager) skipReason(req transcriber.Request, transcribed map[string]string) string {
+	outputDir := req.OutputDir
+	if outputDir == "" {
+		outputDir = filepath.Dir(req.VideoPath)
+	}
+	base := filepath.Join(ExpandHome(outputDir), m.transcribeFilename(req, ""))
+	for _, ext := range []string{".txt", ".srt"} {
+		if fileExists(base + ext) {
+			return "已有 " + filepath.Base(base+ext)
+		}
+	}
+	return transcribed[req.VideoPath]
+}
+
+// saveBatch 有持久化存储时写入数据库，否则保存在内存中
+func (m *Manager) saveBatch(b *TranscribeBatch) error {
+	if m.persister != nil {
+		return m.persister.SaveBatch(b)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.batches[b.ID] = b
+	return nil
+}
+
+// TranscribeBatch 返回批量转录及按子任务汇总的进度
+func (m *Manager) TranscribeBatch(id string) (*TranscribeBatch, error) {
+	batch, err := m.batch(id)
+	if err != nil {
+		return nil, err
+	}
+	if batch == nil {
+		return nil, ErrBatchNotFound
+	}
+	m.fillBatch(batch)
+	return batch, nil
+}
+
+// batch 返回保存的批量转录（副本），不存在时返回 nil
+func (m *Manager) batch(id string) (*TranscribeBatch, error) {
+	if m.persister != nil {
+		return m.persister.Batch(id)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, ok := m.batches[id]
+	if !ok {
+		return nil, nil
+	}
+	snapshot := *b
+	return &snapshot, nil
+}
+
+// CancelTranscribeBatch 取消批量转录中还没有结束的子任务，返回取消后的进度
+func (m *Manager) CancelTranscribeBatch(id string) (*TranscribeBatch, error) {
+	batch, err := m.TranscribeBatch(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, taskID := range batch.TaskIDs {
+		if t, err := m.Transcribe(taskID); err == nil && !t.Status.Terminal() {
+			m.Cancel(taskID)
+		}
+	}
+	return m.TranscribeBatch(id)
+}
+
+// fillBatch 按子任务汇总进度和状态：有未结束的子任务时为 transcribing（都在排队时为 queued），
+// 都结束后有失败的为 failed，有完成的为 completed，否则为 cancelled
+func (m *Manager) fillBatch(b *TranscribeBatch) {
+	b.Total, b.Completed, b.Failed, b.Cancelled, b.Running, b.Queued = 0, 0, 0, 0, 0, 0
+	percentage := 0
+	for _, id := range b.TaskIDs {
+		t, err := m.Transcribe(id)
+		if err != nil {
+			continue
+		}
+		b.Total++
+		switch t.Status {
+		case StatusCompleted:
+			b.Completed++
+			percentage += 100
+			continue
+		case StatusFailed, StatusInterrupted:
+			b.Failed++
+		case StatusCancelled:
+			b.Cancelled++
+		case StatusQueued, StatusPending:
+			b.Queued++
+		default:
+			b.Running++
+		}
+		percentage += t.Percentage
+	}
+
+	switch {
+	case b.Running > 0:
+		b.Status = StatusTranscribing
+	case b.Queued > 0:
+		b.Status = StatusQueued
+	case b.Failed > 0:
+		b.Status = StatusFailed
+	case b.Completed > 0 || b.Total == 0:
+		b.Status = StatusCompleted
+	default:
+		b.Status = StatusCancelled
+	}
+	b.Percentage = 100
+	if b.Total > 0 {
+		b.Percentage = percentage / b.Total
+	}
+	if !b.Status.Terminal() {
+		b.Percentage = min(b.Percentage, 99)
+	}
+}
@@ -0,0 +1,350 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"zhihu-downloader/internal/atomicfile"
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/logging"
+)
+
+var (
+	// ErrNotFound 任务不存在
+	ErrNotFound = errcode.New(errcode.NotFound, "任务不存在")
+	// ErrRunning 任务仍在执行或排队，不能删除
+	ErrRunning = errcode.New(errcode.Conflict, "任务正在执行，请先取消")
+	// ErrRunningElsewhere 任务正在共用数据库的其他进程中执行
+	ErrRunningElsewhere = errcode.New(errcode.Conflict, "任务正在其他进程中执行")
+)
+
+// RetentionPolicy 历史任务保留策略
+type RetentionPolicy struct {
+	// MaxAge 已结束的任务超过该时长后删除，0 表示不清理
+	MaxAge time.Duration
+	// DeleteFiles 删除任务时是否同时删除输出文件
+	DeleteFiles bool
+	// Interval 清理间隔，默认 1 小时
+	Interval time.Duration
+}
+
+// Delete 删除已结束的任务。未完成下载留下的分片等临时文件总是会删除，
+// deleteFiles 为 true 时同时删除视频 / 音频 / 文本等输出文件
+func (m *Manager) Delete(id string, deleteFiles bool) error {
+	m.refreshAny(id)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.localLocked(id) {
+		return ErrRunning
+	}
+	if status, ok := m.statusLocked(id); ok && m.elsewhereLocked(id, status) {
+		return fmt.Errorf("%w，请先取消", ErrRunningElsewhere)
+	}
+	if t, ok := m.downloads[id]; ok {
+		return m.deleteDownloadLocked(t, deleteFiles)
+	}
+	if t, ok := m.transcribes[id]; ok {
+		return m.deleteTranscribeLocked(t, deleteFiles)
+	}
+	if t, ok := m.pipelines[id]; ok {
+		return m.deletePipelineLocked(t, deleteFiles)
+	}
+	if t, ok := m.collections[id]; ok {
+		return m.deleteCollectionLocked(t, deleteFiles)
+	}
+	return ErrNotFound
+}
+
+func (m *Manager) deleteDownloadLocked(t *DownloadTask, deleteFiles bool) error {
+	if m.persister != nil {
+		if err := m.persister.DeleteDownload(t.ID); err != nil {
+			return err
+		}
+	}
+	delete(m.downloads, t.ID)
+	if m.jobs != nil {
+		// 死信队列中的记录随任务一起删除
+		m.removeJobLocked(KindDownload, t.ID)
+	}
+	m.notifyLocked(t.ID)
+	logging.Forget(t.ID)
+	m.forgetEventsLocked(t.ID)
+
+	for _, path := range partialFiles(t) {
+		removeFile(path)
+	}
+	if deleteFiles {
+		// 其他下载链接到这个文件时由它们接管，接管失败时保留文件
+		files := []string{t.ThumbnailPath, t.SpritePath, t.NFOPath, t.CommentsPath, t.CommentsMarkdownPath}
+		if m.promoteDuplicateLocked(t) {
+			files = append(files, t.FilePath)
+		}
+		for _, path := range files {
+			if path != "" {
+				removeFile(path)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Manager) deleteTranscribeLocked(t *TranscribeTask, deleteFiles bool) error {
+	if m.persister != nil {
+		if err := m.persister.DeleteTranscribe(t.ID); err != nil {
+			return err
+		}
+	}
+	delete(m.transcribes, t.ID)
+	if m.jobs != nil {
+		// 死信队列中的记录随任务一起删除
+		m.removeJobLocked(KindTranscribe, t.ID)
+	}
+	m.notifyLocked(t.ID)
+	logging.Forget(t.ID)
+	m.forgetEventsLocked(t.ID)
+
+	if deleteFiles {
+		for _, path := range []string{t.MP3Path, t.TXTPath, t.SRTPath, t.JSONPath, t.SummaryPath} {
+			if path != "" {
+				removeFile(path)
+			}
+		}
+	}
+	return nil
+}
+
+// deletePipelineLocked 删除流水线任务及其子任务
+func (m *Manager) deletePipelineLocked(t *PipelineTask, deleteFiles bool) error {
+	if m.persister != nil {
+		if err := m.persister.DeletePipeline(t.ID); err != nil {
+			return err
+		}
+	}
+	delete(m.pipelines, t.ID)
+	m.notifyLocked(t.ID)
+	logging.Forget(t.ID)
+	m.forgetEventsLocked(t.ID)
+
+	if deleteFiles && t.SubtitledPath != "" {
+		removeFile(t.SubtitledPath)
+	}
+	if d, ok := m.downloads[t.DownloadID]; ok && !m.active[d.ID] {
+		m.deleteDownloadLocked(d, deleteFiles)
+	}
+	if tr, ok := m.transcribes[t.TranscribeID]; ok && !m.active[tr.ID] {
+		m.deleteTranscribeLocked(tr, deleteFiles)
+	}
+	return nil
+}
+
+// deleteCollectionLocked 删除合集任务及其已结束的下载子任务
+func (m *Manager) deleteCollectionLocked(t *CollectionTask, deleteFiles bool) error {
+	if m.persister != nil {
+		if err := m.persister.DeleteCollection(t.ID); err != nil {
+			return err
+		}
+	}
+	delete(m.collections, t.ID)
+	m.notifyLocked(t.ID)
+	logging.Forget(t.ID)
+	m.forgetEventsLocked(t.ID)
+
+	for _, id := range t.DownloadIDs {
+		if d, ok := m.downloads[id]; ok && !m.active[id] && m.cancels[id] == nil {
+			m.deleteDownloadLocked(d, deleteFiles)
+		}
+	}
+	if deleteFiles && t.Title != "" {
+		// 合集子目录为空时一并删除
+		os.Remove(t.OutputDir)
+	}
+	return nil
+}
+
+// partialFiles 下载中途留下的临时文件：HLS 分片目录、合并中的 .tmp 文件，
+// 以及 ffmpeg 和 yt-dlp 写入中的 .part 文件
+func partialFiles(t *DownloadTask) []string {
+	if t.OutputDir == "" || t.Filename == "" {
+		return nil
+	}
+	base := filepath.Join(t.OutputDir, t.Filename)
+	paths := []string{base + ".mp4.parts", base + ".mp4.tmp", base + ".ts.tmp",
+		atomicfile.MediaPath(base + ".mp4"), atomicfile.MediaPath(base + ".mkv")}
+	for _, pattern := range []string{atomicfile.Suffix, ".*" + atomicfile.Suffix} {
+		matches, _ := filepath.Glob(base + pattern)
+		paths = append(paths, matches...)
+	}
+	return paths
+}
+
+// CancelAndCleanup 取消任务，并在任务停止后删除未完成的文件：下载的分片和临时文件、
+// 转录到一半的音频和文本。清理后任务只能从头重试。已经下载完成的视频不会删除。
+// 共用数据库的其他进程执行的任务只请求取消，不删除文件
+func (m *Manager) CancelAndCleanup(id string) bool {
+	ids := []string{id}
+	m.mu.Lock()
+	if !m.localLocked(id) && !m.pausedLocked(id) {
+		m.mu.Unlock()
+		return m.Cancel(id)
+	}
+	if t, ok := m.pipelines[id]; ok {
+		ids = append(ids, t.DownloadID)
+		if t.TranscribeID != "" {
+			ids = append(ids, t.TranscribeID)
+		}
+	}
+	if t, ok := m.collections[id]; ok {
+		ids = append(ids, t.DownloadIDs...)
+	}
+	for _, id := range ids {
+		m.cleanup[id] = true
+	}
+	m.mu.Unlock()
+
+	if !m.Cancel(id) {
+		m.mu.Lock()
+		for _, id := range ids {
+			delete(m.cleanup, id)
+		}
+		m.mu.Unlock()
+		return false
+	}
+	// 流水线 goroutine 退出前也会取消子任务，这里直接取消，避免子任务在此之前结束
+	for _, child := range ids[1:] {
+		m.Cancel(child)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, id := range ids {
+		// 排队中或已结束的任务没有执行中的 goroutine，直接清理；
+		// 其余的在 goroutine 退出时（deactivate）清理
+		if m.cleanup[id] && !m.active[id] {
+			delete(m.cleanup, id)
+			m.removeUnfinishedLocked(id)
+		}
+	}
+	return true
+}
+
+// removeUnfinishedLocked 删除已取消任务留下的未完成文件
+func (m *Manager) removeUnfinishedLocked(id string) {
+	var paths []string
+	if t, ok := m.downloads[id]; ok && t.Status != StatusCompleted {
+		paths = partialFiles(t)
+		if t.OutputDir != "" && t.Filename != "" {
+			// yt-dlp 分离的音视频流和断点信息
+			base := filepath.Join(t.OutputDir, t.Filename)
+			for _, pattern := range []string{".f[0-9]*.*", ".*.ytdl"} {
+				matches, _ := filepath.Glob(base + pattern)
+				paths = append(paths, matches...)
+			}
+		}
+	}
+	if t, ok := m.transcribes[id]; ok && t.Status != StatusCompleted {
+		paths = append(paths, t.MP3Path, t.TXTPath, t.SRTPath, t.JSONPath)
+		if t.TXTPath != "" {
+			paths = append(paths, atomicfile.Path(t.TXTPath))
+		}
+		t.MP3Path, t.TXTPath, t.SRTPath, t.JSONPath = "", "", "", ""
+		m.saveTranscribeLocked(t)
+		for _, p := range m.pipelines {
+			if p.TranscribeID == id {
+				p.MP3Path, p.TXTPath, p.SRTPath, p.JSONPath = "", "", "", ""
+				m.savePipelineLocked(p)
+			}
+		}
+	}
+
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			removeFile(path)
+			logging.FromContext(logging.WithTask(context.Background(), id, "cleanup")).Info("已删除未完成的文件", "path", path)
+		}
+	}
+}
+
+func removeFile(path string) {
+	if err := os.RemoveAll(path); err != nil {
+		slog.Warn("删除文件失败", "path", path, "error", err)
+	}
+}
+
+// Prune 删除结束时间早于 maxAge 的任务，并清理没有任务引用的临时文件。
+// 返回删除的任务数和临时文件数
+func (m *Manager) Prune(maxAge time.Duration, deleteFiles bool) (tasks, orphans int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cutoff := time.Now().Add(-maxAge)
+	for id, t := range m.downloads {
+		if t.Status.Terminal() && !m.active[id] && t.UpdatedAt.Before(cutoff) {
+			if m.deleteDownloadLocked(t, deleteFiles) == nil {
+				tasks++
+			}
+		}
+	}
+	for id, t := range m.transcribes {
+		if t.Status.Terminal() && !m.active[id] && t.UpdatedAt.Before(cutoff) {
+			if m.deleteTranscribeLocked(t, deleteFiles) == nil {
+				tasks++
+			}
+		}
+	}
+	for id, t := range m.pipelines {
+		if t.Status.Terminal() && !m.active[id] && t.UpdatedAt.Before(cutoff) {
+			if m.deletePipelineLocked(t, deleteFiles) == nil {
+				tasks++
+			}
+		}
+	}
+	for id, t := range m.collections {
+		if t.Status.Terminal() && !m.active[id] && t.UpdatedAt.Before(cutoff) {
+			if m.deleteCollectionLocked(t, deleteFiles) == nil {
+				tasks++
+			}
+		}
+	}
+	return tasks, m.removeOrphansLocked(cutoff)
+}
+
+// removeOrphansLocked 删除输出目录中早于 cutoff、且不属于任何仍可继续的任务的临时文件
+func (m *Manager) removeOrphansLocked(cutoff time.Time) int {
+	removed := 0
+	for _, f := range m.partialFilesLocked(cutoff) {
+		removeFile(f.Path)
+		removed++
+	}
+	return removed
+}
+
+// RunRetention 按策略定期清理历史任务，直到 ctx 结束
+func (m *Manager) RunRetention(ctx context.Context, policy RetentionPolicy) {
+	if policy.MaxAge <= 0 {
+		return
+	}
+	if policy.Interval <= 0 {
+		policy.Interval = time.Hour
+	}
+
+	ticker := time.NewTicker(policy.Interval)
+	defer ticker.Stop()
+	for {
+		if tasks, orphans := m.Prune(policy.MaxAge, policy.DeleteFiles); tasks > 0 || orphans > 0 {
+			slog.Info("已清理历史任务", "tasks", tasks, "temp_files", orphans)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
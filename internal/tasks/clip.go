@@ -0,0 +1,286 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/media"
+	"zhihu-downloader/internal/notify"
+)
+
+// DefaultClipFormat 截取片段默认的输出格式
+const DefaultClipFormat = "mp4"
+
+// ClipRequest 截取视频的一段，FilePath 和 TaskID 指定一个
+type ClipRequest struct {
+	// FilePath 源视频，配置了工作区时相对路径相对于工作区目录
+	FilePath string
+	// TaskID 已完成的下载或流水线任务，截取其视频
+	TaskID string
+	// Start / End 起止秒数
+	Start, End float64
+	// Format 输出格式（见 media.ClipFormats），默认 DefaultClipFormat
+	Format string
+	// Reencode 直接重新编码以准确截取，默认先直接复制流，失败时再重新编码
+	Reencode bool
+	// OutputDir 输出目录，默认与源视频相同；Filename 输出文件名（不含扩展名），默认为 <源文件名>_clip_<起>-<止>
+	OutputDir string
+	Filename  string
+	Workspace string
+	Notify    []string
+	Priority  string
+}
+
+// StartClip 创建截取片段的任务，和下载任务一起排队执行。片段任务是 ClipSource 不为空的下载任务，
+// 完成后可以像下载的视频一样查询、转录和删除
+func (m *Manager) StartClip(req ClipRequest) (*DownloadTask, error) {
+	source, from, err := m.clipSource(req)
+	if err != nil {
+		return nil, err
+	}
+	format := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(req.Format)), ".")
+	if format == "" {
+		format = DefaultClipFormat
+	}
+	if !slices.Contains(media.ClipFormats, format) {
+		return nil, errcode.Newf(errcode.InvalidArgument, "不支持的格式: %s（可选 %s）", req.Format, strings.Join(media.ClipFormats, " / "))
+	}
+	if req.Start < 0 || req.End <= req.Start {
+		return nil, errcode.New(errcode.InvalidArgument, "start 不能小于 0，end 必须大于 start")
+	}
+	if duration := media.Duration(source); duration > 0 && req.Start >= duration {
+		return nil, errcode.Newf(errcode.InvalidArgument, "start 超出视频时长（%.1f 秒）", duration)
+	}
+	if err := notify.CheckTargets(req.Notify); err != nil {
+		return nil, err
+	}
+	priority, err := ParsePriority(req.Priority)
+	if err != nil {
+		return nil, err
+	}
+
+	outputDir := req.OutputDir
+	if outputDir == "" {
+		outputDir = filepath.Dir(source)
+	}
+	outputDir, err = m.WorkspaceOutputDir(req.Workspace, outputDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.CheckPath(outputDir); err != nil {
+		return nil, err
+	}
+	filename := strings.TrimSpace(req.Filename)
+	if filename == "" {
+		base := strings.TrimSuffix(filepath.Base(source), filepath.Ext(source))
+		filename = fmt.Sprintf("%s_clip_%s-%s", base, clipStamp(req.Start), clipStamp(req.End))
+	}
+	if err := downloader.ValidateFilename(filename); err != nil {
+		return nil, err
+	}
+	if filepath.Join(outputDir, filename+"."+format) == source {
+		return nil, errcode.New(errcode.InvalidArgument, "输出文件不能覆盖源视频")
+	}
+	if err := m.checkSpace(outputDir); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	task := &DownloadTask{
+		ID:         m.newID(KindDownload),
+		Status:     StatusPending,
+		OutputDir:  outputDir,
+		Filename:   filename,
+		Workspace:  req.Workspace,
+		Notify:     req.Notify,
+		Priority:   priority,
+		ClipSource: source,
+		ClipStart:  req.Start,
+		ClipEnd:    req.End,
+		ClipFormat: format,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		StartTime:  now,
+	}
+	if req.Reencode {
+		task.Remux = media.RemuxReencode
+	}
+	if from != nil {
+		task.VideoURL, task.Title, task.Author = from.VideoURL, from.Title, from.Author
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.mu.Lock()
+	if err := m.saveDownloadLocked(task); err != nil {
+		m.mu.Unlock()
+		cancel()
+		return nil, fmt.Errorf("保存任务失败: %v", err)
+	}
+	m.downloads[task.ID] = task
+	m.cancels[task.ID] = cancel
+	m.enqueueLocked(ctx, task, downloadRequest(task))
+	m.mu.Unlock()
+	slog.Info("已创建片段任务", "task_id", task.ID, "source", source, "start", req.Start, "end", req.End, "format", format)
+	return m.Download(task.ID)
+}
+
+// clipSource 返回源视频的路径，按任务指定时同时返回视频所属的下载任务（流水线没有对应的下载任务时为 nil）
+func (m *Manager) clipSource(req ClipRequest) (string, *DownloadTask, error) {
+	path, taskID := strings.TrimSpace(req.FilePath), strings.TrimSpace(req.TaskID)
+	switch {
+	case path == "" && taskID == "":
+		return "", nil, errcode.New(errcode.InvalidArgument, "file_path 和 task_id 必须指定一个")
+	case path != "" && taskID != "":
+		return "", nil, errcode.New(errcode.InvalidArgument, "file_path 和 task_id 只能指定一个")
+	}
+
+	var from *DownloadTask
+	if taskID != "" {
+		if workspace, ok := m.TaskWorkspace(taskID); !ok || (req.Workspace != "" && workspace != req.Workspace) {
+			return "", nil, ErrNotFound
+		}
+		if t, err := m.Download(taskID); err == nil {
+			if t.Status != StatusCompleted || t.FilePath == "" {
+				return "", nil, errcode.Newf(errcode.Conflict, "下载任务状态为 %s，没有可以截取的视频", t.Status)
+			}
+			path, from = t.FilePath, t
+		} else if p, err := m.Pipeline(taskID); err == nil {
+			if p.FilePath == "" {
+				return "", nil, errcode.Newf(errcode.Conflict, "流水线任务状态为 %s，视频还没有下载完成", p.Status)
+			}
+			path = p.FilePath
+			from, _ = m.Download(p.DownloadID)
+		} else {
+			return "", nil, errcode.Newf(errcode.InvalidArgument, "任务 %s 不是下载或流水线任务", taskID)
+		}
+	} else {
+		path = ExpandHome(path)
+		if req.Workspace != "" && !filepath.IsAbs(path) {
+			ws, ok := m.Workspace(req.Workspace)
+			if !ok {
+				return "", nil, fmt.Errorf("工作区不存在: %s", req.Workspace)
+			}
+			path = filepath.Join(ws.OutputDir, path)
+		}
+		var err error
+		if path, err = filepath.Abs(path); err != nil {
+			return "", nil, err
+		}
+	}
+	if err := m.CheckWorkspacePath(req.Workspace, path); err != nil {
+		return "", nil, err
+	}
+	if err := m.CheckPath(path); err != nil {
+		return "", nil, err
+	}
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return "", nil, errcode.Newf(errcode.NotFound, "视频文件不存在: %s", path)
+	}
+	return path, from, nil
+}
+
+// runClip 执行片段任务：先直接复制流，失败时重新编码（mp3 和要求重新编码的任务直接重新编码）
+func (m *Manager) runClip(ctx context.Context, task *DownloadTask) {
+	ctx = logging.WithTask(ctx, task.ID, "clip")
+	logger := logging.FromContext(ctx)
+	m.updateDownload(task, func(t *DownloadTask) {
+		t.Status = StatusDownloading
+		t.StartTime = time.Now()
+	})
+	logger.Info("开始截取片段", "source", task.ClipSource, "start", task.ClipStart, "end", task.ClipEnd, "format", task.ClipFormat)
+	ctx, watch := newWatchdog(ctx, "截取片段", m.timeouts.DownloadMax, m.timeouts.DownloadStall)
+
+	out := filepath.Join(task.OutputDir, task.Filename+"."+task.ClipFormat)
+	onProgress := func(p int) {
+		watch.progress()
+		m.updateDownload(task, func(t *DownloadTask) {
+			t.Percentage = min(p, 99)
+		})
+	}
+	remux := media.RemuxCopy
+	if task.Remux == media.RemuxReencode || task.ClipFormat == "mp3" {
+		remux = media.RemuxReencode
+	}
+	err := os.MkdirAll(task.OutputDir, 0755)
+	if err == nil {
+		err = media.Cut(ctx, task.ClipSource, out, task.ClipStart, task.ClipEnd, remux == media.RemuxReencode, onProgress)
+	}
+	if err != nil && remux == media.RemuxCopy && ctx.Err() == nil {
+		logger.Warn("直接复制失败，重新编码", "error", err)
+		remux = media.RemuxReencode
+		err = media.Cut(ctx, task.ClipSource, out, task.ClipStart, task.ClipEnd, true, onProgress)
+	}
+	var info os.FileInfo
+	if err == nil {
+		if info, err = os.Stat(out); err == nil && info.Size() == 0 {
+			removeFile(out)
+			err = errcode.New(errcode.InvalidArgument, "截取的片段为空，请检查起止时间")
+		}
+	}
+	err = m.stallCause(task.ID, watch.stop(ctx, err))
+
+	m.finish(task.ID)
+	m.mu.Lock()
+	m.running--
+	m.dispatchLocked()
+	m.mu.Unlock()
+	if m.requeueDownload(ctx, task, err, errcode.Internal) {
+		m.saveOutput(task.ID, err)
+		m.deactivate(task.ID)
+		return
+	}
+
+	m.updateDownload(task, func(t *DownloadTask) {
+		t.ETASeconds = 0
+		switch {
+		case errors.Is(err, context.Canceled):
+			t.Status = StatusCancelled
+			t.Error, t.ErrorCode = "用户取消", errcode.Cancelled
+		case err != nil:
+			t.Status = StatusFailed
+			t.Error, t.ErrorCode, t.ErrorDetail = failure(err, errcode.Internal)
+		default:
+			t.Status = StatusCompleted
+			t.Percentage = 100
+			t.BytesDownloaded, t.TotalBytes = info.Size(), info.Size()
+			t.FilePath = out
+			t.FileName = filepath.Base(out)
+			t.Remux = remux
+			if t.ClipFormat != "m4a" && t.ClipFormat != "mp3" {
+				t.Resolution = media.Resolution(out)
+			}
+		}
+	})
+	switch {
+	case errors.Is(err, context.Canceled):
+		logger.Info("截取片段已取消")
+	case err != nil:
+		logger.Error("截取片段失败", "error", err)
+	default:
+		logger.Info("截取片段完成", "file_path", out, "remux", remux)
+	}
+	m.saveOutput(task.ID, err)
+	m.sendNotifications(task.ID)
+	m.deactivate(task.ID)
+}
+
+// clipStamp 把秒数写成文件名中的时间，例如 75.5 为 1m15.5s
+func clipStamp(s float64) string {
+	minutes := int(s) / 60
+	rest := strconv.FormatFloat(s-float64(minutes*60), 'f', -1, 64)
+	if minutes == 0 {
+		return rest + "s"
+	}
+	return fmt.Sprintf("%dm%ss", minutes, rest)
+}
@@ -0,0 +1,387 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/notify"
+	"zhihu-downloader/internal/zhihu"
+)
+
+// StartCollection 创建合集下载任务：后台翻页列出专栏、收藏夹、问题或用户主页中的视频（最多 limit 个，
+// 0 为 zhihu.DefaultCollectionLimit），每个视频创建一个下载子任务，按下载并发上限排队。
+// req 中只使用 URL、Quality、OutputDir、Backend、MaxRate、Workspace、Notify 和 Priority
+func (m *Manager) StartCollection(req downloader.Request, limit int) (*CollectionTask, error) {
+	if req.URL == "" {
+		return nil, errcode.New(errcode.URLInvalid, "URL 必填")
+	}
+	if _, _, err := zhihu.ParseCollectionURL(req.URL); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = zhihu.DefaultCollectionLimit
+	}
+	quality, err := downloader.NormalizeQuality(req.Quality)
+	if err != nil {
+		return nil, err
+	}
+	// 后端按每个视频的链接分别选择，这里只检查参数
+	if _, err := downloader.ResolveBackend(req.URL, req.Backend); err != nil {
+		return nil, err
+	}
+	outputDir, err := m.WorkspaceOutputDir(req.Workspace, req.OutputDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := notify.CheckTargets(req.Notify); err != nil {
+		return nil, err
+	}
+	priority, err := ParsePriority(req.Priority)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	task := &CollectionTask{
+		ID:          m.newID(KindCollection),
+		Status:      StatusPending,
+		Stage:       "等待开始",
+		URL:         req.URL,
+		Quality:     quality,
+		Backend:     req.Backend,
+		OutputDir:   outputDir,
+		Workspace:   req.Workspace,
+		Limit:       limit,
+		MaxRate:     req.MaxRate,
+		DownloadIDs: []string{},
+		Notify:      req.Notify,
+		Priority:    priority,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		StartTime:   now,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.mu.Lock()
+	if err := m.saveCollectionLocked(task); err != nil {
+		m.mu.Unlock()
+		cancel()
+		return nil, fmt.Errorf("保存任务失败: %v", err)
+	}
+	m.collections[task.ID] = task
+	m.cancels[task.ID] = cancel
+	m.active[task.ID] = true
+	m.mu.Unlock()
+
+	go m.runCollection(ctx, task)
+	return m.Collection(task.ID)
+}
+
+// Collection 返回合集任务的快照
+func (m *Manager) Collection(id string) (*CollectionTask, error) {
+	m.refreshCollection(id)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	task, ok := m.collections[id]
+	if !ok {
+		return nil, fmt.Errorf("合集任务不存在")
+	}
+	return task.snapshot(), nil
+}
+
+// Collections 返回所有合集任务（按创建时间倒序）
+func (m *Manager) Collections() []*CollectionTask {
+	m.refreshCollections()
+	m.mu.RLock()
+	list := make([]*CollectionTask, 0, len(m.collections))
+	for _, t := range m.collections {
+		list = append(list, t.snapshot())
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list
+}
+
+func (t *CollectionTask) snapshot() *CollectionTask {
+	snapshot := *t
+	snapshot.DownloadIDs = append([]string{}, t.DownloadIDs...)
+	return &snapshot
+}
+
+// retryCollectionLocked 重新执行失败、取消或中断的子任务；还没有列出视频时重新获取列表
+func (m *Manager) retryCollectionLocked(t *CollectionTask) error {
+	if !t.Status.Retryable() {
+		return fmt.Errorf("任务状态为 %s，无法重试", t.Status)
+	}
+	now := time.Now()
+	t.Status = StatusPending
+	t.Stage = "等待开始"
+	t.Error, t.ErrorCode, t.ErrorDetail = "", "", ""
+	t.StartTime = now
+	t.UpdatedAt = now
+	m.saveCollectionLocked(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancels[t.ID] = cancel
+	m.active[t.ID] = true
+	go m.runCollection(ctx, t)
+	return nil
+}
+
+func (m *Manager) runCollection(ctx context.Context, task *CollectionTask) {
+	ctx = logging.WithTask(ctx, task.ID, "collection")
+	logger := logging.FromContext(ctx)
+	m.updateCollection(task, func(t *CollectionTask) {
+		t.StartTime = time.Now()
+	})
+	logger.Info("开始下载合集", "url", task.URL, "limit", task.Limit)
+
+	err := m.collectionList(ctx, task)
+	if err == nil {
+		err = m.collectionWait(ctx, task)
+	}
+
+	m.finish(task.ID)
+	m.updateCollection(task, func(t *CollectionTask) {
+		switch {
+		case errors.Is(err, context.Canceled):
+			t.Status = StatusCancelled
+			t.Error, t.ErrorCode = "用户取消", errcode.Cancelled
+		case err != nil:
+			t.Status = StatusFailed
+			t.Error, t.ErrorCode, t.ErrorDetail = failure(err, errcode.Internal)
+		default:
+			t.Status = StatusCompleted
+			t.Percentage = 100
+			t.Stage = fmt.Sprintf("已下载 %d 个视频", t.Completed)
+		}
+	})
+	switch {
+	case errors.Is(err, context.Canceled):
+		logger.Info("合集下载已取消")
+	case err != nil:
+		logger.Error("合集下载失败", "error", err)
+	default:
+		logger.Info("合集下载完成", "videos", task.Completed, "output_dir", task.OutputDir)
+	}
+	m.sendNotifications(task.ID)
+	m.deactivate(task.ID)
+}
+
+// collectionList 列出合集中的视频并创建下载子任务，视频保存在以合集名称命名的子目录中。
+// 重试时子任务已经存在，只重新执行失败的子任务
+func (m *Manager) collectionList(ctx context.Context, task *CollectionTask) error {
+	logger := logging.FromContext(ctx)
+	if len(task.DownloadIDs) > 0 {
+		for _, id := range task.DownloadIDs {
+			if d, err := m.Download(id); err == nil && d.Status.Retryable() {
+				if err := m.Retry(id); err != nil {
+					logger.Warn("重试下载子任务失败", "download_id", id, "error", err)
+				}
+			}
+		}
+		return nil
+	}
+
+	m.updateCollection(task, func(t *CollectionTask) {
+		t.Stage = "正在获取视频列表"
+	})
+	c, err := zhihu.FetchCollection(ctx, task.URL, task.Limit, func(found int) {
+		m.updateCollection(task, func(t *CollectionTask) {
+			t.Stage = fmt.Sprintf("正在获取视频列表（已找到 %d 个）", found)
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	name := downloader.SanitizeFilename(c.Title)
+	if name == "" {
+		name = string(c.Type) + "_" + c.ID
+	}
+	dir := filepath.Join(task.OutputDir, name)
+	logger.Info("已获取视频列表", "title", c.Title, "videos", len(c.Items), "output_dir", dir)
+
+	ids := []string{}
+	used := map[string]bool{}
+	for _, item := range c.Items {
+		if ctx.Err() != nil {
+			break
+		}
+		// 优先级可能在列出视频期间被修改
+		m.mu.RLock()
+		priority := task.Priority
+		m.mu.RUnlock()
+		req := downloader.Request{URL: item.URL, Quality: task.Quality, OutputDir: dir, Backend: task.Backend,
+			MaxRate: task.MaxRate, Workspace: task.Workspace, Priority: string(priority)}
+		// 回答和文章中嵌入的视频通常没有标题，用回答 / 文章的标题命名
+		if item.Source != "" && item.Title != "" {
+			req.Filename = collectionFilename(dir, item.Title, used)
+		}
+		d, err := m.StartDownload(req)
+		if err != nil {
+			logger.Warn("创建下载子任务失败", "url", item.URL, "error", err)
+			continue
+		}
+		ids = append(ids, d.ID)
+	}
+	m.updateCollection(task, func(t *CollectionTask) {
+		t.Title = c.Title
+		t.OutputDir = dir
+		t.DownloadIDs = ids
+		t.Total = len(ids)
+	})
+	if len(ids) == 0 && ctx.Err() == nil {
+		return fmt.Errorf("合集中的 %d 个视频都无法下载", len(c.Items))
+	}
+	return nil
+}
+
+// collectionFilename 返回合集内不重复的文件名，同一问题下的多个回答标题相同
+func collectionFilename(dir, title string, used map[string]bool) string {
+	base := downloader.SanitizeFilename(title)
+	name := base
+	for i := 2; used[name]; i++ {
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+	used[name] = true
+	return downloader.UniqueFilename(dir, name, ".mp4")
+}
+
+// collectionWait 等待所有下载子任务结束，期间同步合并后的进度。ctx 取消时一并取消子任务
+func (m *Manager) collectionWait(ctx context.Context, task *CollectionTask) error {
+	updates, unsubscribe := m.subscribeAll(task.DownloadIDs)
+	defer unsubscribe()
+
+	done := ctx.Done()
+	for !m.syncCollection(task) {
+		select {
+		case <-updates:
+		case <-done:
+			for _, id := range task.DownloadIDs {
+				m.Cancel(id)
+			}
+			done = nil
+		}
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	m.mu.RLock()
+	failed, total := task.Failed, task.Total
+	m.mu.RUnlock()
+	if failed > 0 {
+		return fmt.Errorf("%d / %d 个视频下载失败，重试会重新下载失败的视频", failed, total)
+	}
+	return nil
+}
+
+// syncCollection 汇总子任务的状态和进度（已结束的子任务按 100% 计），所有子任务都结束时返回 true。
+// 已被删除的子任务按失败计
+func (m *Manager) syncCollection(task *CollectionTask) bool {
+	var completed, failed, running, percentage int
+	for _, id := range task.DownloadIDs {
+		d, err := m.Download(id)
+		switch {
+		case err != nil:
+			failed++
+			percentage += 100
+		case d.Status == StatusCompleted:
+			completed++
+			percentage += 100
+		case d.Status.Terminal():
+			failed++
+			percentage += 100
+		default:
+			if d.Status == StatusDownloading || d.Status == StatusTranscoding {
+				running++
+			}
+			percentage += d.Percentage
+		}
+	}
+
+	total := len(task.DownloadIDs)
+	finished := completed+failed == total
+	m.updateCollection(task, func(t *CollectionTask) {
+		t.Completed = completed
+		t.Failed = failed
+		if total > 0 {
+			t.Percentage = percentage / total
+		}
+		t.Stage = fmt.Sprintf("已完成 %d / %d", completed, total)
+		if !finished {
+			t.Status = StatusQueued
+			if running > 0 {
+				t.Status = StatusDownloading
+			}
+			t.Stage += fmt.Sprintf("，正在下载 %d 个", running)
+		}
+		if failed > 0 {
+			t.Stage += fmt.Sprintf("，失败 %d 个", failed)
+		}
+	})
+	return finished
+}
+
+// subscribeAll 用同一个通道订阅多个任务的状态变化
+func (m *Manager) subscribeAll(ids []string) (updates <-chan struct{}, unsubscribe func()) {
+	ch := make(chan struct{}, 1)
+
+	m.mu.Lock()
+	for _, id := range ids {
+		m.watchers[id] = append(m.watchers[id], ch)
+	}
+	m.mu.Unlock()
+
+	return ch, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for _, id := range ids {
+			list := m.watchers[id]
+			for i, w := range list {
+				if w == ch {
+					m.watchers[id] = append(list[:i], list[i+1:]...)
+					break
+				}
+			}
+			if len(m.watchers[id]) == 0 {
+				delete(m.watchers, id)
+			}
+		}
+	}
+}
+
+func (m *Manager) updateCollection(task *CollectionTask, fn func(t *CollectionTask)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if task.Status == StatusCancelled {
+		return
+	}
+	fn(task)
+	m.touchCollection(task)
+	m.saveCollectionLocked(task)
+	m.notifyLocked(task.ID)
+}
+
+func (m *Manager) touchCollection(t *CollectionTask) {
+	t.UpdatedAt = time.Now()
+	t.ElapsedTime = int(t.UpdatedAt.Sub(t.StartTime).Seconds())
+}
+
+func (m *Manager) saveCollectionLocked(t *CollectionTask) error {
+	_, known := m.collections[t.ID]
+	m.recordLocked(t.ID, !known, t.Status, t.Error, 0, t.Percentage)
+	if m.persister == nil {
+		return nil
+	}
+	return m.persister.SaveCollection(t)
+}
@@ -0,0 +1,49 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/zhihu"
+)
+
+// checkComments 检查评论参数：只有知乎视频（zvideo）、回答和文章可以获取评论
+func checkComments(req downloader.Request) error {
+	if !req.Comments {
+		return nil
+	}
+	if req.CommentsLimit < 0 {
+		return fmt.Errorf("comments_limit 不能为负数")
+	}
+	if !zhihu.SupportsComments(req.URL) {
+		return fmt.Errorf("只能获取知乎视频（zvideo）、回答和文章的评论")
+	}
+	return nil
+}
+
+// saveComments 下载完成后把评论保存为视频旁边的 <文件名>.comments.json 和 .comments.md。
+// 获取评论失败时只记录日志，不影响下载结果
+func (m *Manager) saveComments(ctx context.Context, req downloader.Request, video string) *zhihu.SavedComments {
+	if !req.Comments || ctx.Err() != nil {
+		return nil
+	}
+	ctx = logging.WithStage(ctx, "comments")
+	logger := logging.FromContext(ctx)
+
+	comments, err := zhihu.FetchComments(ctx, req.URL, req.CommentsLimit)
+	if err != nil {
+		logger.Warn("获取评论失败", "error", err)
+		return nil
+	}
+	saved, err := comments.Save(strings.TrimSuffix(video, filepath.Ext(video)))
+	if err != nil {
+		logger.Warn("保存评论失败", "error", err)
+		return nil
+	}
+	logger.Info("评论已保存", "count", saved.Count, "total", saved.Total, "path", saved.MarkdownPath)
+	return saved
+}
@@ -0,0 +1,365 @@
+package tasks
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"zhihu-downloader/internal/atomicfile"
+	"zhihu-downloader/internal/logging"
+)
+
+// DedupMode 下载完成后发现与已下载的文件内容相同时的处理方式
+type DedupMode string
+
+const (
+	// DedupHardlink 把新文件换成指向已有文件的硬链接，不在同一文件系统时改用符号链接
+	DedupHardlink DedupMode = "hardlink"
+	// DedupSymlink 把新文件换成指向已有文件的符号链接
+	DedupSymlink DedupMode = "symlink"
+	// DedupOff 不计算内容哈希，保留两份文件
+	DedupOff DedupMode = "off"
+)
+
+// ParseDedupMode 校验去重方式，空字符串为 DedupHardlink
+func ParseDedupMode(s string) (DedupMode, error) {
+	switch m := DedupMode(s); m {
+	case "":
+		return DedupHardlink, nil
+	case DedupHardlink, DedupSymlink, DedupOff:
+		return m, nil
+	}
+	return "", fmt.Errorf("未知的去重方式: %s（可选 hardlink / symlink / off）", s)
+}
+
+// WithDedup 设置按内容去重的方式（默认 DedupHardlink）
+func WithDedup(mode DedupMode) Option {
+	return func(m *Manager) {
+		if mode == "" {
+			mode = DedupHardlink
+		}
+		m.dedup = mode
+	}
+}
+
+// ContentEntry 内容索引中的一条记录：内容（SHA-256）在一个范围内第一次下载得到的文件，
+// 之后同一范围内内容相同的下载链接到它
+type ContentEntry struct {
+	// Scope 索引的范围，见 contentScope
+	Scope    string
+	Hash     string
+	Size     int64
+	FilePath string
+	// TaskID 下载该文件的任务，任务删除后仍保留索引
+	TaskID    string
+	CreatedAt time.Time
+}
+
+// ContentSource 下载过某一内容的链接。转载的视频链接不同、内容相同，每个链接都记录一条
+type ContentSource struct {
+	Scope    string `json:"-"`
+	Hash     string `json:"-"`
+	URL      string `json:"url"`
+	TaskID   string `json:"task_id"`
+	FilePath string `json:"file_path"`
+	// LinkedTo 文件是指向该路径的链接，为空时是内容的原始文件
+	LinkedTo  string    `json:"linked_to,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ContentInfo 下载任务的文件内容和下载过同一内容的所有链接
+type ContentInfo struct {
+	Hash     string          `json:"content_hash"`
+	FilePath string          `json:"file_path"`
+	Sources  []ContentSource `json:"sources"`
+}
+
+// ErrNoContentHash 下载任务没有记录内容哈希（未完成、去重已关闭或在此功能之前下载的任务）
+var ErrNoContentHash = errors.New("任务没有记录内容哈希")
+
+// dedupContent 计算下载文件的 SHA-256。同一范围内已有内容相同的文件时把 path 换成指向它的链接，
+// 返回链接的目标和方式；否则把 path 记为该内容的原始文件
+func (m *Manager) dedupContent(ctx context.Context, task *DownloadTask, path string) (hash, linkedTo string, mode DedupMode) {
+	if m.dedup == DedupOff {
+		return "", "", ""
+	}
+	logger := logging.FromContext(logging.WithStage(ctx, "dedup"))
+	hash, size, err := hashFile(path)
+	if err != nil {
+		logger.Warn("计算文件哈希失败", "error", err)
+		return "", "", ""
+	}
+	scope := m.contentScope(task.Workspace, path)
+	if e := m.lookupContent(scope, hash); e != nil && e.Size == size && filepath.Clean(e.FilePath) != filepath.Clean(path) {
+		// 确认原始文件的内容没有变化，避免链接到被修改过的文件
+		if current, _, err := hashFile(e.FilePath); err == nil && current == hash {
+			mode, err := linkFile(e.FilePath, path, m.dedup)
+			if err == nil {
+				logger.Info("内容与已下载的文件相同，已替换为链接", "target", e.FilePath, "mode", mode, "task_id", e.TaskID)
+				return hash, e.FilePath, mode
+			}
+			logger.Warn("替换为链接失败，保留两份文件", "target", e.FilePath, "error", err)
+			return hash, "", ""
+		}
+	}
+	m.saveContent(&ContentEntry{Scope: scope, Hash: hash, Size: size, FilePath: path, TaskID: task.ID, CreatedAt: time.Now()})
+	return hash, "", ""
+}
+
+// contentScope 内容索引的范围：同一工作区、同一根目录（工作区目录、默认下载目录或允许访问的目录，
+// 都不是时为文件所在目录）中的文件才会互相链接，链接不会指向其他工作区或其他根目录中的文件
+func (m *Manager) contentScope(workspace, path string) string {
+	var roots []string
+	if ws, ok := m.Workspace(workspace); ok {
+		roots = append(roots, ws.OutputDir)
+	}
+	roots = append(roots, m.outputDir)
+	roots = append(roots, m.allowedRoots...)
+	abs, _ := filepath.Abs(path)
+	root := filepath.Dir(abs)
+	for _, r := range roots {
+		if r, err := filepath.Abs(ExpandHome(r)); err == nil && inDir(r, abs) {
+			root = r
+			break
+		}
+	}
+	return workspace + ":" + root
+}
+
+// hashFile 返回文件内容的 SHA-256 和大小
+func hashFile(path string) (hash string, size int64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if size, err = io.Copy(h, f); err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// linkFile 把 path 换成指向 target 的链接：先在旁边创建链接再改名覆盖，失败时 path 保持不变。
+// hardlink 方式在不能创建硬链接时（不在同一文件系统等）改用符号链接
+func linkFile(target, path string, mode DedupMode) (DedupMode, error) {
+	tmp := path + ".link"
+	os.Remove(tmp)
+	if mode == DedupHardlink {
+		if err := os.Link(target, tmp); err == nil {
+			return DedupHardlink, replaceWith(tmp, path)
+		}
+	}
+	abs, err := filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Symlink(abs, tmp); err != nil {
+		return "", err
+	}
+	return DedupSymlink, replaceWith(tmp, path)
+}
+
+func replaceWith(tmp, path string) error {
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// recordContent 下载完成后记录下载过该内容的链接
+func (m *Manager) recordContent(task *DownloadTask) {
+	if task.ContentHash == "" {
+		return
+	}
+	s := &ContentSource{
+		Scope:     m.contentScope(task.Workspace, task.FilePath),
+		Hash:      task.ContentHash,
+		URL:       task.VideoURL,
+		TaskID:    task.ID,
+		FilePath:  task.FilePath,
+		LinkedTo:  task.LinkedTo,
+		CreatedAt: time.Now(),
+	}
+	if m.persister != nil {
+		if err := m.persister.SaveContentSource(s); err != nil {
+			slog.Warn("保存内容来源失败", "task_id", task.ID, "error", err)
+		}
+		return
+	}
+	m.mu.Lock()
+	key := s.Scope + "|" + s.Hash
+	m.contentSources[key] = append(m.contentSources[key], *s)
+	m.mu.Unlock()
+}
+
+// Content 返回下载任务的文件内容和同一范围内下载过同一内容的所有链接（包括已删除的任务）
+func (m *Manager) Content(id string) (*ContentInfo, error) {
+	task, err := m.Download(id)
+	if err != nil {
+		return nil, err
+	}
+	if task.ContentHash == "" {
+		return nil, ErrNoContentHash
+	}
+	info := &ContentInfo{Hash: task.ContentHash, FilePath: task.FilePath, Sources: []ContentSource{}}
+	if task.LinkedTo != "" {
+		info.FilePath = task.LinkedTo
+	}
+	scope := m.contentScope(task.Workspace, task.FilePath)
+	if m.persister != nil {
+		sources, err := m.persister.ContentSources(scope, task.ContentHash)
+		if err != nil {
+			return nil, err
+		}
+		info.Sources = append(info.Sources, sources...)
+		return info, nil
+	}
+	m.mu.RLock()
+	info.Sources = append(info.Sources, m.contentSources[scope+"|"+task.ContentHash]...)
+	m.mu.RUnlock()
+	return info, nil
+}
+
+// lookupContent 查找范围内内容的原始文件，文件已被删除时返回 nil
+func (m *Manager) lookupContent(scope, hash string) *ContentEntry {
+	var e *ContentEntry
+	if m.persister != nil {
+		var err error
+		if e, err = m.persister.LookupContent(scope, hash); err != nil {
+			slog.Warn("查询内容索引失败", "hash", hash, "error", err)
+			return nil
+		}
+	} else {
+		m.mu.RLock()
+		e = m.contents[scope+"|"+hash]
+		m.mu.RUnlock()
+	}
+	if e == nil {
+		return nil
+	}
+	// 原始文件本身是符号链接时不再链接到它
+	if info, err := os.Lstat(e.FilePath); err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	return e
+}
+
+func (m *Manager) saveContent(e *ContentEntry) {
+	// 写数据库不需要持有锁
+	if m.persister != nil {
+		m.saveContentLocked(e)
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.saveContentLocked(e)
+}
+
+func (m *Manager) saveContentLocked(e *ContentEntry) {
+	if m.persister != nil {
+		if err := m.persister.SaveContent(e); err != nil {
+			slog.Warn("保存内容索引失败", "hash", e.Hash, "error", err)
+		}
+		return
+	}
+	m.contents[e.Scope+"|"+e.Hash] = e
+}
+
+// promoteDuplicateLocked 删除内容的原始文件之前，把链接到它的最早的下载换成原始文件，
+// 其余链接改为指向它，内容索引也改为记录它，避免删除后链接失效。
+// 硬链接本身就是完整的文件；符号链接用原始文件替换（不在同一文件系统时复制）。
+// 替换失败时返回 false，这时保留原始文件
+func (m *Manager) promoteDuplicateLocked(t *DownloadTask) bool {
+	if t.ContentHash == "" || t.LinkedTo != "" || t.FilePath == "" {
+		return true
+	}
+	dups := m.duplicatesLocked(t)
+	if len(dups) == 0 {
+		return true
+	}
+	first := dups[0]
+	if first.LinkMode == DedupSymlink {
+		if err := moveFile(t.FilePath, first.FilePath); err != nil {
+			slog.Warn("用原始文件替换符号链接失败，保留原始文件", "file_path", first.FilePath, "target", t.FilePath, "error", err)
+			return false
+		}
+	}
+	first.LinkedTo, first.LinkMode = "", ""
+	m.saveDownloadLocked(first)
+	for _, d := range dups[1:] {
+		if d.LinkMode == DedupSymlink {
+			if _, err := linkFile(first.FilePath, d.FilePath, DedupSymlink); err != nil {
+				slog.Warn("更新符号链接失败", "file_path", d.FilePath, "target", first.FilePath, "error", err)
+				continue
+			}
+		}
+		d.LinkedTo = first.FilePath
+		m.saveDownloadLocked(d)
+	}
+	var size int64
+	if info, err := os.Stat(first.FilePath); err == nil {
+		size = info.Size()
+	}
+	m.saveContentLocked(&ContentEntry{
+		Scope:     m.contentScope(first.Workspace, first.FilePath),
+		Hash:      t.ContentHash,
+		Size:      size,
+		FilePath:  first.FilePath,
+		TaskID:    first.ID,
+		CreatedAt: time.Now(),
+	})
+	slog.Info("原始文件已删除，改由内容相同的下载保存", "download_id", first.ID, "file_path", first.FilePath, "deleted_id", t.ID)
+	return true
+}
+
+// duplicatesLocked 返回链接到 t 的文件的已完成下载，按创建时间从早到晚排列
+func (m *Manager) duplicatesLocked(t *DownloadTask) []*DownloadTask {
+	var dups []*DownloadTask
+	for _, d := range m.downloads {
+		if d.ID != t.ID && d.Status == StatusCompleted && d.LinkedTo != "" &&
+			filepath.Clean(d.LinkedTo) == filepath.Clean(t.FilePath) {
+			dups = append(dups, d)
+		}
+	}
+	sort.Slice(dups, func(i, j int) bool { return dups[i].CreatedAt.Before(dups[j].CreatedAt) })
+	return dups
+}
+
+// moveFile 把 src 移动到 dst（覆盖 dst）。不在同一文件系统时先复制到 dst 旁的临时文件，再改名并删除 src
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	part := atomicfile.Path(dst)
+	out, err := os.Create(part)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(part)
+		return err
+	}
+	if err := atomicfile.Commit(part, dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
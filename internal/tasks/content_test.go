@@ -0,0 +1,105 @@
+package tasks
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// addDownload 把内容为 data 的文件写入 path，登记为已完成的下载任务并去重
+func addDownload(t *testing.T, m *Manager, id, workspace, path, data string) *DownloadTask {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	task := &DownloadTask{ID: id, Status: StatusCompleted, Workspace: workspace, FilePath: path, CreatedAt: time.Now()}
+	task.ContentHash, task.LinkedTo, task.LinkMode = m.dedupContent(context.Background(), task, path)
+	m.mu.Lock()
+	m.downloads[id] = task
+	m.mu.Unlock()
+	return task
+}
+
+func TestDedupScope(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(WithOutputDir(dir), WithWorkspaces([]Workspace{{Name: "a"}, {Name: "b"}}))
+
+	addDownload(t, m, "dl-1", "a", filepath.Join(dir, "a", "1.mp4"), "video")
+	tests := []struct {
+		name      string
+		workspace string
+		path      string
+		linkedTo  string
+	}{
+		{"其他工作区", "b", filepath.Join(dir, "b", "1.mp4"), ""},
+		{"不属于工作区", "", filepath.Join(dir, "1.mp4"), ""},
+		{"同一工作区", "a", filepath.Join(dir, "a", "2.mp4"), filepath.Join(dir, "a", "1.mp4")},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := addDownload(t, m, "dl-"+string(rune('2'+i)), tt.workspace, tt.path, "video")
+			if task.LinkedTo != tt.linkedTo {
+				t.Fatalf("LinkedTo = %q，应为 %q", task.LinkedTo, tt.linkedTo)
+			}
+		})
+	}
+}
+
+func TestDeleteLinkedOriginal(t *testing.T) {
+	for _, mode := range []DedupMode{DedupHardlink, DedupSymlink} {
+		t.Run(string(mode), func(t *testing.T) {
+			dir := t.TempDir()
+			m := NewManager(WithOutputDir(dir), WithDedup(mode))
+			original := addDownload(t, m, "dl-1", "", filepath.Join(dir, "1.mp4"), "video")
+			second := addDownload(t, m, "dl-2", "", filepath.Join(dir, "2.mp4"), "video")
+			third := addDownload(t, m, "dl-3", "", filepath.Join(dir, "3.mp4"), "video")
+			if second.LinkedTo != original.FilePath || third.LinkedTo != original.FilePath {
+				t.Fatalf("没有链接到原始文件: %q %q", second.LinkedTo, third.LinkedTo)
+			}
+
+			if err := m.Delete(original.ID, true); err != nil {
+				t.Fatal(err)
+			}
+			if info, err := os.Lstat(second.FilePath); err != nil || !info.Mode().IsRegular() {
+				t.Fatalf("最早的链接没有替换为原始文件: %v", err)
+			}
+			if second.LinkedTo != "" || third.LinkedTo != second.FilePath {
+				t.Fatalf("LinkedTo = %q, %q，应为空和 %q", second.LinkedTo, third.LinkedTo, second.FilePath)
+			}
+			for _, path := range []string{second.FilePath, third.FilePath} {
+				if data, err := os.ReadFile(path); err != nil || string(data) != "video" {
+					t.Fatalf("删除原始文件后 %s 无法读取: %v", path, err)
+				}
+			}
+			// 之后内容相同的下载链接到接管的文件
+			if fourth := addDownload(t, m, "dl-4", "", filepath.Join(dir, "4.mp4"), "video"); fourth.LinkedTo != second.FilePath {
+				t.Fatalf("LinkedTo = %q，应为 %q", fourth.LinkedTo, second.FilePath)
+			}
+		})
+	}
+}
+
+func TestEvictLinkedFiles(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(WithOutputDir(dir))
+	original := addDownload(t, m, "dl-1", "", filepath.Join(dir, "1.mp4"), "video")
+	linked := addDownload(t, m, "dl-2", "", filepath.Join(dir, "2.mp4"), "video")
+	original.UpdatedAt = time.Now().Add(-time.Hour)
+	linked.UpdatedAt = time.Now()
+
+	// 删除原始文件不释放空间，还要删除接管它的下载
+	m.mu.Lock()
+	freed := m.evictLocked(context.Background(), dir, 1)
+	m.mu.Unlock()
+	if freed != int64(len("video")) {
+		t.Fatalf("释放 %d 字节，应为 %d", freed, len("video"))
+	}
+	if len(m.Downloads()) != 0 {
+		t.Fatalf("剩余 %d 个下载，应全部删除", len(m.Downloads()))
+	}
+}
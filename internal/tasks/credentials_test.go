@@ -0,0 +1,37 @@
+package tasks
+
+import "testing"
+
+func TestSaveDownloadCredentials(t *testing.T) {
+	m := NewManager(WithOutputDir(t.TempDir()))
+	tests := []struct {
+		status Status
+		keep   bool
+	}{
+		{StatusDownloading, true},
+		{StatusFailed, true},
+		{StatusCancelled, true},
+		{StatusInterrupted, true},
+		{StatusCompleted, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			task := &DownloadTask{
+				ID:      "dl-" + string(tt.status),
+				Status:  tt.status,
+				Headers: map[string]string{"Authorization": "Bearer x"},
+				Cookies: map[string]string{"session": "abc"},
+			}
+			m.mu.Lock()
+			m.saveDownloadLocked(task)
+			m.mu.Unlock()
+			if kept := task.Headers != nil && task.Cookies != nil; kept != tt.keep {
+				t.Fatalf("状态为 %s 时保留请求头和 cookies = %v，应为 %v", tt.status, kept, tt.keep)
+			}
+			// 重试时使用保留的请求头和 cookies
+			if req := downloadRequest(task); tt.keep && req.Headers["Authorization"] != "Bearer x" {
+				t.Fatalf("重试请求没有附带保存的请求头: %v", req.Headers)
+			}
+		})
+	}
+}
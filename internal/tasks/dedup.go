@@ -0,0 +1,182 @@
+package tasks
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/media"
+	"zhihu-downloader/internal/zhihu"
+)
+
+// IndexEntry 下载索引中的一条记录：同一视频、清晰度和输出目录已下载的文件
+type IndexEntry struct {
+	Key      string
+	URL      string
+	Quality  string
+	FilePath string
+	// TaskID 下载该文件的任务，任务删除后仍保留索引
+	TaskID    string
+	CreatedAt time.Time
+}
+
+// downloadKey 下载索引的键。知乎链接按视频、回答或文章 ID 识别，同一内容的不同链接得到相同的键；
+// 其他网站的链接去掉 fragment 和 utm_* 等跟踪参数后比较
+func downloadKey(rawURL, quality, dir string) string {
+	key := zhihu.VideoKey(rawURL)
+	if key == "" {
+		key = normalizeURL(rawURL)
+	}
+	return key + "|" + quality + "|" + filepath.Clean(dir)
+}
+
+// normalizeURL 协议和域名转为小写，去掉 fragment 和跟踪参数，查询参数按名称排序
+func normalizeURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return strings.TrimSpace(raw)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	q := u.Query()
+	for name := range q {
+		if strings.HasPrefix(name, "utm_") || name == "spm" || name == "share_source" {
+			q.Del(name)
+		}
+	}
+	// Encode 按名称排序
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// lookupDownloaded 查找已下载的文件，文件已被删除时同时删除索引记录
+func (m *Manager) lookupDownloaded(key string) *IndexEntry {
+	var e *IndexEntry
+	if m.persister != nil {
+		var err error
+		if e, err = m.persister.LookupIndex(key); err != nil {
+			slog.Warn("查询下载索引失败", "key", key, "error", err)
+			return nil
+		}
+	} else {
+		m.mu.RLock()
+		e = m.index[key]
+		m.mu.RUnlock()
+	}
+	if e == nil {
+		return nil
+	}
+	if info, err := os.Stat(e.FilePath); err != nil || info.IsDir() {
+		m.deleteIndex(key)
+		return nil
+	}
+	return e
+}
+
+// recordDownloaded 下载完成后记录到索引
+func (m *Manager) recordDownloaded(task *DownloadTask) {
+	e := &IndexEntry{
+		Key:       downloadKey(task.VideoURL, task.Quality, task.OutputDir),
+		URL:       task.VideoURL,
+		Quality:   task.Quality,
+		FilePath:  task.FilePath,
+		TaskID:    task.ID,
+		CreatedAt: time.Now(),
+	}
+	if m.persister != nil {
+		if err := m.persister.SaveIndex(e); err != nil {
+			slog.Warn("保存下载索引失败", "key", e.Key, "error", err)
+		}
+		return
+	}
+	m.mu.Lock()
+	m.index[e.Key] = e
+	m.mu.Unlock()
+}
+
+func (m *Manager) deleteIndex(key string) {
+	if m.persister != nil {
+		if err := m.persister.DeleteIndex(key); err != nil {
+			slog.Warn("删除下载索引失败", "key", key, "error", err)
+		}
+		return
+	}
+	m.mu.Lock()
+	delete(m.index, key)
+	m.mu.Unlock()
+}
+
+// reuseDownload 返回已下载文件对应的任务：原任务仍存在时返回原任务，
+// 否则（原任务已删除，或由共用数据库的其他进程下载）创建一个已完成的任务
+func (m *Manager) reuseDownload(e *IndexEntry, req downloader.Request) (*DownloadTask, error) {
+	m.mu.RLock()
+	t, ok := m.downloads[e.TaskID]
+	if ok && t.Status == StatusCompleted && t.FilePath == e.FilePath && t.Workspace == req.Workspace {
+		snapshot := *t
+		m.mu.RUnlock()
+		snapshot.Cached = true
+		return &snapshot, nil
+	}
+	m.mu.RUnlock()
+
+	now := time.Now()
+	task := &DownloadTask{
+		ID:         m.newID(KindDownload),
+		Status:     StatusCompleted,
+		VideoURL:   req.URL,
+		Quality:    req.Quality,
+		Backend:    req.Backend,
+		OutputDir:  req.OutputDir,
+		Workspace:  req.Workspace,
+		Filename:   strings.TrimSuffix(filepath.Base(e.FilePath), filepath.Ext(e.FilePath)),
+		Percentage: 100,
+		FilePath:   e.FilePath,
+		FileName:   filepath.Base(e.FilePath),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		StartTime:  now,
+	}
+	fillSidecars(task)
+
+	m.mu.Lock()
+	if err := m.saveDownloadLocked(task); err != nil {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("保存任务失败: %v", err)
+	}
+	m.downloads[task.ID] = task
+	m.mu.Unlock()
+	m.recordDownloaded(task)
+
+	snapshot := *task
+	snapshot.Cached = true
+	return &snapshot, nil
+}
+
+// fillSidecars 填入视频旁边已有的封面、预览图、.nfo 和之前下载时保存的评论
+func fillSidecars(task *DownloadTask) {
+	if p := media.ThumbnailPath(task.FilePath); fileExists(p) {
+		task.ThumbnailPath = p
+	}
+	if p := media.SpritePath(task.FilePath); fileExists(p) {
+		task.SpritePath = p
+	}
+	if p := nfoPath(task.FilePath); fileExists(p) {
+		task.NFOPath = p
+	}
+	base := strings.TrimSuffix(task.FilePath, filepath.Ext(task.FilePath))
+	if fileExists(base + ".comments.json") {
+		task.CommentsPath = base + ".comments.json"
+		task.CommentsMarkdownPath = base + ".comments.md"
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
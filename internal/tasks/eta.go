@@ -0,0 +1,49 @@
+package tasks
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// etaSampleInterval 两次采样的最短间隔，进度回调过于频繁时速度波动太大
+	etaSampleInterval = time.Second
+	// etaSmoothing 新样本在平滑速度中的权重（指数移动平均）
+	etaSmoothing = 0.3
+)
+
+// etaEstimator 根据进度的实际变化速度估算剩余时间。速度取指数移动平均，
+// 网速或 Whisper 处理速度波动时剩余时间不会忽长忽短。只在任务自己的 goroutine 中使用
+type etaEstimator struct {
+	// unit 进度的单位（例如 bytes、percent），单位变化时重新开始估算
+	unit string
+	at   time.Time
+	done float64
+	// rate 平滑后的速度（unit/秒），0 表示还没有足够的样本
+	rate float64
+	eta  int
+}
+
+// update 记录当前进度 done / total，返回预计剩余秒数，无法估算时返回 0
+func (e *etaEstimator) update(unit string, done, total float64) int {
+	now := time.Now()
+	if unit != e.unit || done < e.done || e.at.IsZero() {
+		// 第一次采样、单位变化或进度回退（例如自动重试后重新开始）
+		*e = etaEstimator{unit: unit, at: now, done: done}
+		return 0
+	}
+	if dt := now.Sub(e.at); dt >= etaSampleInterval {
+		rate := (done - e.done) / dt.Seconds()
+		if e.rate == 0 {
+			e.rate = rate
+		} else {
+			e.rate = etaSmoothing*rate + (1-etaSmoothing)*e.rate
+		}
+		e.at, e.done = now, done
+		e.eta = 0
+		if e.rate > 0 && total > done {
+			e.eta = int(math.Ceil((total - done) / e.rate))
+		}
+	}
+	return e.eta
+}
@@ -0,0 +1,148 @@
+package tasks
+
+import (
+	"sort"
+
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/logging"
+)
+
+// ProgressEvent 推送给客户端的进度事件，下载和转录任务共用
+type ProgressEvent struct {
+	ID          string `json:"id"`
+	Type        Kind   `json:"type"`
+	Status      Status `json:"status"`
+	Stage       string `json:"stage,omitempty"`
+	Percentage  int    `json:"percentage"`
+	Speed       string `json:"speed,omitempty"`
+	ElapsedTime int    `json:"elapsed_time"`
+	ETASeconds  int    `json:"eta_seconds,omitempty"`
+	FilePath    string `json:"file_path,omitempty"`
+	Error       string `json:"error,omitempty"`
+	// ErrorCode 失败原因的错误码
+	ErrorCode errcode.Code `json:"error_code,omitempty"`
+	// BytesDownloaded / TotalBytes 下载的字节数，只有下载和流水线任务有
+	BytesDownloaded int64 `json:"bytes_downloaded,omitempty"`
+	TotalBytes      int64 `json:"total_bytes,omitempty"`
+	// SpeedHistory 最近的下载速度（字节/秒），最早的在前，只有下载和流水线任务有
+	SpeedHistory []int64 `json:"speed_history,omitempty"`
+}
+
+// Event 返回下载任务的进度事件
+func (t *DownloadTask) Event() ProgressEvent {
+	return ProgressEvent{
+		ID:              t.ID,
+		Type:            KindDownload,
+		Status:          t.Status,
+		Stage:           string(t.Status),
+		Percentage:      t.Percentage,
+		Speed:           t.Speed,
+		ElapsedTime:     t.ElapsedTime,
+		ETASeconds:      t.ETASeconds,
+		FilePath:        t.FilePath,
+		Error:           t.Error,
+		ErrorCode:       t.ErrorCode,
+		BytesDownloaded: t.BytesDownloaded,
+		TotalBytes:      t.TotalBytes,
+		SpeedHistory:    t.SpeedHistory,
+	}
+}
+
+// Event 返回转录任务的进度事件
+func (t *TranscribeTask) Event() ProgressEvent {
+	return ProgressEvent{
+		ID:          t.ID,
+		Type:        KindTranscribe,
+		Status:      t.Status,
+		Stage:       t.Stage,
+		Percentage:  t.Percentage,
+		ElapsedTime: t.ElapsedTime,
+		ETASeconds:  t.ETASeconds,
+		FilePath:    t.TXTPath,
+		Error:       t.Error,
+		ErrorCode:   t.ErrorCode,
+	}
+}
+
+// Event 返回流水线任务的进度事件，结束后 FilePath 为转录文本
+func (t *PipelineTask) Event() ProgressEvent {
+	path := t.TXTPath
+	if path == "" {
+		path = t.FilePath
+	}
+	return ProgressEvent{
+		ID:              t.ID,
+		Type:            KindPipeline,
+		Status:          t.Status,
+		Stage:           t.Stage,
+		Percentage:      t.Percentage,
+		Speed:           t.Speed,
+		ElapsedTime:     t.ElapsedTime,
+		ETASeconds:      t.ETASeconds,
+		FilePath:        path,
+		Error:           t.Error,
+		ErrorCode:       t.ErrorCode,
+		BytesDownloaded: t.BytesDownloaded,
+		TotalBytes:      t.TotalBytes,
+		SpeedHistory:    t.SpeedHistory,
+	}
+}
+
+// Event 返回合集任务的进度事件，FilePath 为保存视频的目录
+func (t *CollectionTask) Event() ProgressEvent {
+	return ProgressEvent{
+		ID:          t.ID,
+		Type:        KindCollection,
+		Status:      t.Status,
+		Stage:       t.Stage,
+		Percentage:  t.Percentage,
+		ElapsedTime: t.ElapsedTime,
+		FilePath:    t.OutputDir,
+		Error:       t.Error,
+		ErrorCode:   t.ErrorCode,
+	}
+}
+
+// Event 返回任意任务的最新进度事件
+func (m *Manager) Event(id string) (ProgressEvent, bool) {
+	if t, err := m.Download(id); err == nil {
+		return t.Event(), true
+	}
+	if t, err := m.Transcribe(id); err == nil {
+		return t.Event(), true
+	}
+	if t, err := m.Pipeline(id); err == nil {
+		return t.Event(), true
+	}
+	if t, err := m.Collection(id); err == nil {
+		return t.Event(), true
+	}
+	return ProgressEvent{}, false
+}
+
+// Logs 返回任务最近的 n 行日志，流水线和合集任务包含子任务的日志
+func (m *Manager) Logs(id string, n int) ([]logging.Line, error) {
+	if _, ok := m.Event(id); !ok {
+		return nil, ErrNotFound
+	}
+	var children []string
+	if p, err := m.Pipeline(id); err == nil {
+		children = []string{p.DownloadID, p.TranscribeID}
+	} else if c, err := m.Collection(id); err == nil {
+		children = c.DownloadIDs
+	} else {
+		return logging.Lines(id, n), nil
+	}
+
+	lines := logging.Lines(id, 0)
+	for _, child := range children {
+		if child != "" {
+			lines = append(lines, logging.Lines(child, 0)...)
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].Time.Before(lines[j].Time) })
+	if n > 0 && n < len(lines) {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, nil
+}
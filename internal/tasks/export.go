@@ -0,0 +1,199 @@
+package tasks
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"zhihu-downloader/internal/atomicfile"
+)
+
+// ExportRecord 导出的一条任务记录，各类任务使用相同的列
+type ExportRecord struct {
+	Type   Kind   `json:"type"`
+	ID     string `json:"id"`
+	Status Status `json:"status"`
+	// Source 下载的链接、转录的视频路径或合集链接
+	Source string `json:"source"`
+	// Title 合集名称
+	Title string `json:"title,omitempty"`
+	// Output 主要输出：下载的视频、转录文本或合集目录
+	Output string `json:"output,omitempty"`
+	// FileSize 输出文件当前在磁盘上的总大小（字节），合集为所有子任务视频的大小
+	FileSize int64  `json:"file_size"`
+	Quality  string `json:"quality,omitempty"`
+	Model    string `json:"model,omitempty"`
+	// Total / Completed / Failed 合集的视频数
+	Total     int       `json:"total,omitempty"`
+	Completed int       `json:"completed,omitempty"`
+	Failed    int       `json:"failed,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// FinishedAt 结束时间，未结束的任务为空
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Duration 已执行的秒数
+	Duration int    `json:"duration_seconds"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Report 任务历史报告
+type Report struct {
+	ExportedAt time.Time `json:"exported_at"`
+	Total      int       `json:"total"`
+	// Statuses 各状态的任务数
+	Statuses map[Status]int `json:"statuses"`
+	// TotalSize 所有输出文件的总大小，多个任务共用的文件只计一次
+	TotalSize int64          `json:"total_size"`
+	Tasks     []ExportRecord `json:"tasks"`
+}
+
+// Export 按条件（不分页）导出任务，按创建时间倒序
+func (q Query) Export(downloads []*DownloadTask, transcribes []*TranscribeTask, pipelines []*PipelineTask, collections []*CollectionTask) Report {
+	report := Report{ExportedAt: time.Now(), Statuses: map[Status]int{}, Tasks: []ExportRecord{}}
+	sizes := map[string]int64{}
+	size := func(paths ...string) int64 {
+		var total int64
+		for _, path := range paths {
+			if path == "" {
+				continue
+			}
+			n, ok := sizes[path]
+			if !ok {
+				if info, err := os.Stat(path); err == nil && !info.IsDir() {
+					n = info.Size()
+				}
+				sizes[path] = n
+			}
+			total += n
+		}
+		return total
+	}
+	byID := make(map[string]*DownloadTask, len(downloads))
+	for _, t := range downloads {
+		byID[t.ID] = t
+	}
+
+	for _, e := range q.filter(downloads, transcribes, pipelines, collections) {
+		var r ExportRecord
+		var updated time.Time
+		switch t := e.task.(type) {
+		case *DownloadTask:
+			r = ExportRecord{Source: t.VideoURL, Output: t.FilePath, Quality: t.Quality,
+				FileSize: size(t.FilePath), Duration: t.ElapsedTime, Error: t.Error}
+			updated = t.UpdatedAt
+		case *TranscribeTask:
+			r = ExportRecord{Source: t.VideoPath, Output: t.TXTPath, Model: t.Model,
+				FileSize: size(t.MP3Path, t.TXTPath, t.SRTPath, t.JSONPath, t.SummaryPath), Duration: t.ElapsedTime, Error: t.Error}
+			updated = t.UpdatedAt
+		case *PipelineTask:
+			r = ExportRecord{Source: t.VideoURL, Output: t.FilePath, Model: t.Model,
+				FileSize: size(t.FilePath, t.MP3Path, t.TXTPath, t.SRTPath, t.JSONPath, t.SummaryPath), Duration: t.ElapsedTime, Error: t.Error}
+			updated = t.UpdatedAt
+		case *CollectionTask:
+			r = ExportRecord{Source: t.URL, Title: t.Title, Output: t.OutputDir, Quality: t.Quality,
+				Total: t.Total, Completed: t.Completed, Failed: t.Failed, Duration: t.ElapsedTime, Error: t.Error}
+			for _, id := range t.DownloadIDs {
+				if d, ok := byID[id]; ok {
+					r.FileSize += size(d.FilePath)
+				}
+			}
+			updated = t.UpdatedAt
+		}
+		r.Type, r.ID, r.Status, r.CreatedAt = e.kind, e.id, e.status, e.created
+		if e.status.Terminal() {
+			r.FinishedAt = &updated
+		}
+		report.Tasks = append(report.Tasks, r)
+		report.Statuses[e.status]++
+	}
+	report.Total = len(report.Tasks)
+	for _, n := range sizes {
+		report.TotalSize += n
+	}
+	return report
+}
+
+// Export 按条件导出管理器中的任务
+func (m *Manager) Export(q Query) Report {
+	return q.Export(m.Downloads(), m.Transcribes(), m.Pipelines(), m.Collections())
+}
+
+// csvHeader CSV 的列，与 ExportRecord 的 JSON 字段名相同
+var csvHeader = []string{
+	"type", "id", "status", "source", "title", "output", "file_size", "quality", "model",
+	"total", "completed", "failed", "created_at", "finished_at", "duration_seconds", "error",
+}
+
+// WriteCSV 把任务记录写成 CSV。开头写入 UTF-8 BOM，Excel 打开时中文不会乱码
+func (r Report) WriteCSV(w io.Writer) error {
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, t := range r.Tasks {
+		finished := ""
+		if t.FinishedAt != nil {
+			finished = t.FinishedAt.Format(time.RFC3339)
+		}
+		row := []string{
+			string(t.Type), t.ID, string(t.Status), t.Source, t.Title, t.Output,
+			strconv.FormatInt(t.FileSize, 10), t.Quality, t.Model,
+			strconv.Itoa(t.Total), strconv.Itoa(t.Completed), strconv.Itoa(t.Failed),
+			t.CreatedAt.Format(time.RFC3339), finished, strconv.Itoa(t.Duration), t.Error,
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteFile 按 format（csv / json）把报告写入文件，自动创建目录
+func (r Report) WriteFile(path, format string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %v", err)
+	}
+	part := atomicfile.Path(path)
+	f, err := os.Create(part)
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %v", err)
+	}
+	if format == "csv" {
+		err = r.WriteCSV(f)
+	} else {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(r)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = atomicfile.Commit(part, path)
+	} else {
+		os.Remove(part)
+	}
+	if err != nil {
+		return fmt.Errorf("写入文件失败: %v", err)
+	}
+	return nil
+}
+
+// ParseExportFormat 校验导出格式，空字符串为 json
+func ParseExportFormat(s string) (string, error) {
+	switch s {
+	case "", "json":
+		return "json", nil
+	case "csv":
+		return "csv", nil
+	}
+	return "", fmt.Errorf("未知的导出格式: %s（可选 csv / json）", s)
+}
@@ -0,0 +1,52 @@
+package tasks
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/transcriber"
+)
+
+// transcribeFilename 返回转录输出（音频、txt、srt、json）共用的文件名（不含扩展名）。
+// 指定了 OutputFilename 时直接使用；指定了 FilenameTemplate 时按模板生成，变量取自下载这个视频的任务
+// （不是由本服务下载的视频标题为文件名，日期为今天，{id} 为 id）；否则与视频文件同名
+func (m *Manager) transcribeFilename(req transcriber.Request, id string) string {
+	if req.OutputFilename != "" {
+		return req.OutputFilename
+	}
+	base := strings.TrimSuffix(filepath.Base(req.VideoPath), filepath.Ext(req.VideoPath))
+	if req.FilenameTemplate == "" {
+		return base
+	}
+	data := downloader.FilenameData{Title: base, Date: time.Now(), ID: shortID(id)}
+	if t := m.videoDownload(req.VideoPath); t != nil {
+		if t.Title != "" {
+			data.Title = t.Title
+		}
+		data.Author = t.Author
+		data.Quality = t.Quality
+		data.Resolution = t.Resolution
+		data.Date = t.CreatedAt
+		data.ID = shortID(t.ID)
+	}
+	return downloader.ExpandFilename(req.FilenameTemplate, data)
+}
+
+// videoDownload 返回最近一次下载（或导入）到 path 的已完成任务，没有时返回 nil
+func (m *Manager) videoDownload(path string) *DownloadTask {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var found *DownloadTask
+	for _, t := range m.downloads {
+		if t.Status == StatusCompleted && t.FilePath == path && (found == nil || t.UpdatedAt.After(found.UpdatedAt)) {
+			found = t
+		}
+	}
+	if found == nil {
+		return nil
+	}
+	snapshot := *found
+	return &snapshot
+}
@@ -0,0 +1,31 @@
+package tasks
+
+import (
+	"path/filepath"
+	"testing"
+
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/transcriber"
+)
+
+func TestFilenameTraversal(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(WithOutputDir(filepath.Join(dir, "output")))
+
+	for _, name := range []string{"../x", "../../etc/x", "a/b", `a\b`, ".", ".."} {
+		t.Run(name, func(t *testing.T) {
+			_, err := m.StartDownload(downloader.Request{URL: "https://www.zhihu.com/zvideo/1", Filename: name})
+			if errcode.Of(err, errcode.Internal) != errcode.InvalidArgument {
+				t.Errorf("StartDownload 错误 = %v，应为 %s", err, errcode.InvalidArgument)
+			}
+			_, err = m.StartTranscribe(transcriber.Request{VideoPath: filepath.Join(dir, "output", "v.mp4"), OutputFilename: name})
+			if errcode.Of(err, errcode.Internal) != errcode.InvalidArgument {
+				t.Errorf("StartTranscribe 错误 = %v，应为 %s", err, errcode.InvalidArgument)
+			}
+		})
+	}
+	if len(m.Downloads()) != 0 || len(m.Transcribes()) != 0 {
+		t.Fatal("文件名无效时不应创建任务")
+	}
+}
@@ -0,0 +1,220 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"zhihu-downloader/internal/atomicfile"
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/media"
+	"zhihu-downloader/internal/summarizer"
+	"zhihu-downloader/internal/transcriber"
+)
+
+const (
+	// DefaultHighlightPadding 片段前后多保留的秒数
+	DefaultHighlightPadding = 2.0
+	// DefaultHighlightLimit 大模型挑选的片段数，MaxHighlights 一次最多返回的片段数
+	DefaultHighlightLimit = 10
+	MaxHighlights         = 100
+)
+
+// HighlightRequest 从已完成的转录中提取片段：按关键词匹配，或请大模型挑选，两者可以同时使用
+type HighlightRequest struct {
+	// Keywords 关键词，不区分大小写，包含任一关键词的分段即为一个片段
+	Keywords []string
+	// LLM 请大模型挑选精彩片段（需要配置 summary 接口），Limit 为最多挑选的片段数，默认 DefaultHighlightLimit
+	LLM   bool
+	Limit int
+	// Padding 片段前后多保留的秒数，默认 DefaultHighlightPadding，小于 0 时不保留
+	Padding *float64
+	// Clip 用 ffmpeg 把每个片段剪成单独的视频，Reencode 重新编码以准确截取（默认直接复制流，开头对齐到关键帧）
+	Clip     bool
+	Reencode bool
+}
+
+// Highlight 一个片段，时间为秒（已包括前后保留的时间）
+type Highlight struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	// Text 片段中的转录文本
+	Text string `json:"text"`
+	// Keywords 匹配的关键词，Title 大模型给出的说明
+	Keywords []string `json:"keywords,omitempty"`
+	Title    string   `json:"title,omitempty"`
+	// ClipPath 剪出的视频，没有剪辑或剪辑失败时为空，失败原因见 ClipError
+	ClipPath  string `json:"clip_path,omitempty"`
+	ClipError string `json:"clip_error,omitempty"`
+
+	// hitStart / hitEnd 匹配的分段的时间（不包括前后保留的时间）
+	hitStart, hitEnd float64
+}
+
+// HighlightResult 提取结果，同时保存为转录文本旁边的 <文件名>.highlights.json
+type HighlightResult struct {
+	TaskID     string      `json:"task_id"`
+	VideoPath  string      `json:"video_path"`
+	Highlights []Highlight `json:"highlights"`
+	// Path 保存的 JSON 文件，ClipDir 剪出的视频所在目录
+	Path      string    `json:"path"`
+	ClipDir   string    `json:"clip_dir,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Highlights 在已完成的转录或流水线任务的文稿中查找关键词、请大模型挑选精彩片段，
+// 返回带时间的片段，Clip 为 true 时把每个片段剪成单独的视频
+func (m *Manager) Highlights(ctx context.Context, id string, req HighlightRequest) (*HighlightResult, error) {
+	var keywords []string
+	for _, k := range req.Keywords {
+		if k = strings.TrimSpace(k); k != "" && !slices.Contains(keywords, k) {
+			keywords = append(keywords, k)
+		}
+	}
+	if len(keywords) == 0 && !req.LLM {
+		return nil, errcode.New(errcode.InvalidArgument, "keywords 和 llm 至少指定一个")
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = DefaultHighlightLimit
+	}
+	limit = min(limit, MaxHighlights)
+	padding := DefaultHighlightPadding
+	if req.Padding != nil {
+		padding = max(*req.Padding, 0)
+	}
+
+	var videoPath, txtPath string
+	if t, err := m.Transcribe(id); err == nil {
+		videoPath, txtPath = t.VideoPath, t.TXTPath
+	} else if p, err := m.Pipeline(id); err == nil {
+		videoPath, txtPath = p.FilePath, p.TXTPath
+	}
+	transcript, err := m.Transcript(id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Clip {
+		if _, err := os.Stat(videoPath); err != nil {
+			return nil, errcode.Newf(errcode.NotFound, "视频文件不存在，无法剪辑: %s", videoPath)
+		}
+	}
+	ctx = logging.WithTask(ctx, id, "highlights")
+
+	var highlights []Highlight
+	for _, s := range transcript.Segments {
+		text := strings.ToLower(s.Text)
+		var matched []string
+		for _, k := range keywords {
+			if strings.Contains(text, strings.ToLower(k)) {
+				matched = append(matched, k)
+			}
+		}
+		if len(matched) > 0 {
+			highlights = append(highlights, Highlight{Keywords: matched, hitStart: s.Start, hitEnd: s.End})
+		}
+	}
+	if req.LLM {
+		var b strings.Builder
+		for _, s := range transcript.Segments {
+			fmt.Fprintf(&b, "[%.1f-%.1f] %s\n", s.Start, s.End, s.Text)
+		}
+		picked, err := summarizer.Highlights(ctx, b.String(), limit)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range picked {
+			highlights = append(highlights, Highlight{Title: h.Title, hitStart: h.Start, hitEnd: h.End})
+		}
+	}
+	highlights = mergeHighlights(highlights, padding)
+	if len(highlights) > MaxHighlights {
+		highlights = highlights[:MaxHighlights]
+	}
+	for i := range highlights {
+		h := &highlights[i]
+		h.Text = segmentText(transcript.Segments, h.hitStart, h.hitEnd)
+	}
+
+	base := strings.TrimSuffix(txtPath, filepath.Ext(txtPath))
+	result := &HighlightResult{
+		TaskID:     id,
+		VideoPath:  videoPath,
+		Highlights: highlights,
+		Path:       base + ".highlights.json",
+		CreatedAt:  time.Now(),
+	}
+	if result.Highlights == nil {
+		result.Highlights = []Highlight{}
+	}
+	if req.Clip && len(highlights) > 0 {
+		result.ClipDir = base + ".highlights"
+		if err := os.MkdirAll(result.ClipDir, 0755); err != nil {
+			return nil, fmt.Errorf("创建片段目录失败: %v", err)
+		}
+		logger := logging.FromContext(ctx)
+		for i := range highlights {
+			h := &highlights[i]
+			out := filepath.Join(result.ClipDir, fmt.Sprintf("highlight_%02d.mp4", i+1))
+			if err := media.Cut(ctx, videoPath, out, h.Start, h.End, req.Reencode, nil); err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				h.ClipError = err.Error()
+				logger.Warn("剪辑片段失败", "start", h.Start, "end", h.End, "error", err)
+				continue
+			}
+			h.ClipPath = out
+		}
+	}
+
+	data, _ := json.MarshalIndent(result, "", "  ")
+	if err := atomicfile.WriteFile(result.Path, data, 0644); err != nil {
+		return nil, fmt.Errorf("保存片段失败: %v", err)
+	}
+	logging.FromContext(ctx).Info("已提取片段", "highlights", len(highlights), "path", result.Path)
+	return result, nil
+}
+
+// mergeHighlights 前后各加 padding 秒后按时间排序，合并重叠的片段
+func mergeHighlights(highlights []Highlight, padding float64) []Highlight {
+	sort.Slice(highlights, func(i, j int) bool { return highlights[i].hitStart < highlights[j].hitStart })
+	var merged []Highlight
+	for _, h := range highlights {
+		h.Start, h.End = max(h.hitStart-padding, 0), h.hitEnd+padding
+		if n := len(merged); n > 0 && h.Start <= merged[n-1].End {
+			last := &merged[n-1]
+			last.End = max(last.End, h.End)
+			last.hitEnd = max(last.hitEnd, h.hitEnd)
+			for _, k := range h.Keywords {
+				if !slices.Contains(last.Keywords, k) {
+					last.Keywords = append(last.Keywords, k)
+				}
+			}
+			if last.Title == "" {
+				last.Title = h.Title
+			}
+			continue
+		}
+		merged = append(merged, h)
+	}
+	return merged
+}
+
+// segmentText 拼接与 start 到 end 秒有重叠的分段文本
+func segmentText(segments []transcriber.Segment, start, end float64) string {
+	var texts []string
+	for _, s := range segments {
+		if s.End > start && s.Start < end {
+			texts = append(texts, strings.TrimSpace(s.Text))
+		}
+	}
+	return strings.Join(texts, " ")
+}
@@ -0,0 +1,148 @@
+package tasks
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// EventType 任务历史事件的类型
+type EventType string
+
+const (
+	// EventCreated 任务创建
+	EventCreated EventType = "created"
+	// EventStatus 状态变化，From 为变化前的状态
+	EventStatus EventType = "status"
+	// EventError 任务出错，自动重试前的失败也会记录
+	EventError EventType = "error"
+	// EventRetry 自动重试，或结束后通过 Retry 重新执行
+	EventRetry EventType = "retry"
+	// EventUpload 上传到远程存储，Message 为远程地址或失败原因
+	EventUpload EventType = "upload"
+	// EventProgress 进度达到 25%、50%、75%，Message 为进度。只通知 WithEventListener 设置的监听函数，不保存到历史
+	EventProgress EventType = "progress"
+)
+
+// progressStep 进度每增加这么多通知一次 EventProgress
+const progressStep = 25
+
+// WithEventListener 设置任务事件的监听函数，历史事件和 EventProgress 都会通知。
+// 监听函数在持有 Manager 的锁时调用，不能阻塞，也不能调用 Manager 的方法
+func WithEventListener(fn func(TaskEvent)) Option {
+	return func(m *Manager) { m.listener = fn }
+}
+
+// TaskEvent 任务历史中的一条记录，状态每次变化时追加，随任务一起删除
+type TaskEvent struct {
+	TaskID  string    `json:"task_id"`
+	Time    time.Time `json:"time"`
+	Type    EventType `json:"type"`
+	Status  Status    `json:"status"`
+	From    Status    `json:"from,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+// taskState 上次记录事件时任务的状态，用于判断发生了哪些变化
+type taskState struct {
+	status  Status
+	err     string
+	retries int
+	// milestone 已经通知过的进度，progressStep 的整数倍
+	milestone int
+}
+
+// recordLocked 比较任务与上次保存时的状态，为变化追加事件。created 表示任务第一次保存；
+// 从数据库恢复或由其他进程创建的任务第一次保存时只记下状态，不产生事件。进度每跨过 progressStep 通知一次监听函数
+func (m *Manager) recordLocked(id string, created bool, status Status, errMsg string, retries, percentage int) {
+	cur := taskState{status: status, err: errMsg, retries: retries, milestone: min(percentage/progressStep*progressStep, 100-progressStep)}
+	prev, seen := m.states[id]
+	m.states[id] = cur
+	if !seen {
+		if created {
+			m.appendEventLocked(TaskEvent{TaskID: id, Type: EventCreated, Status: status})
+		}
+		return
+	}
+
+	if retries > prev.retries {
+		m.appendEventLocked(TaskEvent{TaskID: id, Type: EventRetry, Status: status, Message: fmt.Sprintf("第 %d 次自动重试", retries)})
+	}
+	if prev.status.Terminal() && !status.Terminal() {
+		m.appendEventLocked(TaskEvent{TaskID: id, Type: EventRetry, Status: status, Message: "重新执行"})
+	}
+	if errMsg != "" && errMsg != prev.err {
+		m.appendEventLocked(TaskEvent{TaskID: id, Type: EventError, Status: status, Message: errMsg})
+	}
+	if status != prev.status {
+		m.appendEventLocked(TaskEvent{TaskID: id, Type: EventStatus, Status: status, From: prev.status})
+	}
+	if cur.milestone > prev.milestone && !status.Terminal() {
+		m.notifyListenerLocked(TaskEvent{TaskID: id, Time: time.Now(), Type: EventProgress, Status: status, Message: fmt.Sprintf("%d%%", cur.milestone)})
+	}
+}
+
+// appendEventLocked 保存事件：有持久化存储时写入数据库，否则保存在内存中。
+// 事件写入失败不影响任务本身
+func (m *Manager) appendEventLocked(e TaskEvent) {
+	e.Time = time.Now()
+	m.notifyListenerLocked(e)
+	if m.persister == nil {
+		m.events[e.TaskID] = append(m.events[e.TaskID], e)
+		return
+	}
+	if err := m.persister.SaveEvent(&e); err != nil {
+		slog.Warn("保存任务事件失败", "task_id", e.TaskID, "type", e.Type, "error", err)
+	}
+}
+
+func (m *Manager) notifyListenerLocked(e TaskEvent) {
+	if m.listener != nil {
+		m.listener(e)
+	}
+}
+
+// forgetEventsLocked 删除任务后清除内存中的状态、事件和外部程序输出，数据库中的记录由 Delete* 一起删除
+func (m *Manager) forgetEventsLocked(id string) {
+	delete(m.states, id)
+	delete(m.events, id)
+	delete(m.outputs, id)
+}
+
+// Events 返回任务的历史事件，按时间先后排列。流水线和合集任务会合并子任务的事件
+func (m *Manager) Events(id string) ([]TaskEvent, error) {
+	if _, ok := m.Event(id); !ok {
+		return nil, ErrNotFound
+	}
+	ids := []string{id}
+	if p, err := m.Pipeline(id); err == nil {
+		ids = append(ids, p.DownloadID, p.TranscribeID)
+	} else if c, err := m.Collection(id); err == nil {
+		ids = append(ids, c.DownloadIDs...)
+	}
+
+	events := []TaskEvent{}
+	for _, taskID := range ids {
+		if taskID == "" {
+			continue
+		}
+		list, err := m.taskEvents(taskID)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, list...)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	return events, nil
+}
+
+func (m *Manager) taskEvents(id string) ([]TaskEvent, error) {
+	if m.persister != nil {
+		// 直接查询数据库，共用数据库的其他进程执行的任务也能看到
+		return m.persister.Events(id)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]TaskEvent(nil), m.events[id]...), nil
+}
@@ -0,0 +1,141 @@
+package tasks
+
+import (
+	"time"
+
+	"zhihu-downloader/internal/errcode"
+)
+
+// IdempotencyTTL 幂等键的有效期，过期后同一个键会创建新任务
+const IdempotencyTTL = 24 * time.Hour
+
+// MaxIdempotencyKeyLength 幂等键的最大长度
+const MaxIdempotencyKeyLength = 255
+
+// idempotencyClaimTimeout 记录了幂等键但还没有记录任务 ID 的最长时间。超过时认为创建任务的实例已经退出，
+// 同一个键按新请求处理
+const idempotencyClaimTimeout = time.Minute
+
+// IdempotencyKey 幂等键及它创建的任务。同一个工作区的同一个键只有一条记录，不同工作区的同名键互不影响；
+// TaskID 为空表示正在创建任务
+type IdempotencyKey struct {
+	Workspace string    `json:"workspace,omitempty"`
+	Key       string    `json:"key"`
+	Kind      Kind      `json:"kind"`
+	TaskID    string    `json:"task_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Idempotent 按幂等键创建任务：同一个键在有效期内重复提交时不调用 create，直接返回第一次创建的任务 ID，
+// replayed 为 true。key 为空时总是创建；同一个键用于不同类型的任务，或第一次提交的任务还在创建时返回 Conflict；
+// create 失败时不记录。先在存储中插入没有任务 ID 的记录再创建任务，共用数据库的多个实例同时提交也只创建一次
+func (m *Manager) Idempotent(workspace, key string, kind Kind, create func() (string, error)) (id string, replayed bool, err error) {
+	if key == "" {
+		id, err = create()
+		return id, false, err
+	}
+	if len(key) > MaxIdempotencyKeyLength {
+		return "", false, errcode.Newf(errcode.InvalidArgument, "幂等键不能超过 %d 个字符", MaxIdempotencyKeyLength)
+	}
+	unlock := m.lockIdempotency(workspace + ":" + key)
+	defer unlock()
+
+	claim := &IdempotencyKey{Workspace: workspace, Key: key, Kind: kind, CreatedAt: time.Now()}
+	for claimed := false; !claimed; {
+		k, err := m.claimIdempotencyKey(claim)
+		if err != nil {
+			return "", false, err
+		}
+		if k == nil {
+			claimed = true
+			continue
+		}
+		if k.TaskID == "" {
+			if time.Since(k.CreatedAt) < idempotencyClaimTimeout {
+				return "", false, errcode.New(errcode.Conflict, "使用同一个幂等键的请求正在处理，请稍后重试")
+			}
+		} else if _, ok := m.TaskWorkspace(k.TaskID); ok {
+			if k.Kind != kind {
+				return "", false, errcode.Newf(errcode.Conflict, "幂等键已用于创建 %s 任务 %s", k.Kind, k.TaskID)
+			}
+			return k.TaskID, true, nil
+		}
+		// 任务已经删除，或创建任务的实例没有完成就退出了，删除后按新请求处理
+		if err := m.deleteIdempotencyKey(k); err != nil {
+			return "", false, err
+		}
+	}
+	if id, err = create(); err != nil {
+		m.deleteIdempotencyKey(claim)
+		return "", false, err
+	}
+	claim.TaskID = id
+	err = m.saveIdempotencyKey(claim)
+	return id, false, err
+}
+
+// lockIdempotency 让同一个键的请求依次执行，避免并发的重复提交各自创建任务
+func (m *Manager) lockIdempotency(key string) (unlock func()) {
+	for {
+		m.mu.Lock()
+		wait, busy := m.idempotencyBusy[key]
+		if !busy {
+			done := make(chan struct{})
+			m.idempotencyBusy[key] = done
+			m.mu.Unlock()
+			return func() {
+				m.mu.Lock()
+				delete(m.idempotencyBusy, key)
+				m.mu.Unlock()
+				close(done)
+			}
+		}
+		m.mu.Unlock()
+		<-wait
+	}
+}
+
+// claimIdempotencyKey 有持久化存储时在数据库中插入，否则保存在内存中。插入成功时返回 nil，否则返回已有的记录
+func (m *Manager) claimIdempotencyKey(k *IdempotencyKey) (*IdempotencyKey, error) {
+	if m.persister != nil {
+		return m.persister.ClaimIdempotencyKey(k)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, old := range m.idempotency {
+		if time.Since(old.CreatedAt) >= IdempotencyTTL {
+			delete(m.idempotency, key)
+		}
+	}
+	if old, ok := m.idempotency[k.Workspace+":"+k.Key]; ok {
+		return old, nil
+	}
+	saved := *k
+	m.idempotency[k.Workspace+":"+k.Key] = &saved
+	return nil, nil
+}
+
+// saveIdempotencyKey 记录幂等键创建的任务
+func (m *Manager) saveIdempotencyKey(k *IdempotencyKey) error {
+	if m.persister != nil {
+		return m.persister.SaveIdempotencyKey(k)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	saved := *k
+	m.idempotency[k.Workspace+":"+k.Key] = &saved
+	return nil
+}
+
+// deleteIdempotencyKey 删除与 k 相同的记录
+func (m *Manager) deleteIdempotencyKey(k *IdempotencyKey) error {
+	if m.persister != nil {
+		return m.persister.DeleteIdempotencyKey(k)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if old, ok := m.idempotency[k.Workspace+":"+k.Key]; ok && old.TaskID == k.TaskID && old.CreatedAt.Equal(k.CreatedAt) {
+		delete(m.idempotency, k.Workspace+":"+k.Key)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package tasks
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"zhihu-downloader/internal/errcode"
+)
+
+func TestIdempotent(t *testing.T) {
+	m := NewManager(WithOutputDir(t.TempDir()))
+	created := 0
+	create := func() (string, error) {
+		created++
+		id := "dl-" + string(rune('0'+created))
+		m.mu.Lock()
+		m.downloads[id] = &DownloadTask{ID: id, Status: StatusPending}
+		m.mu.Unlock()
+		return id, nil
+	}
+
+	tests := []struct {
+		name         string
+		workspace    string
+		key          string
+		kind         Kind
+		wantID       string
+		wantReplayed bool
+		wantCode     errcode.Code
+	}{
+		{"第一次提交", "", "k1", KindDownload, "dl-1", false, ""},
+		{"重复提交", "", "k1", KindDownload, "dl-1", true, ""},
+		{"其他工作区的同名键", "team", "k1", KindDownload, "dl-2", false, ""},
+		{"用于不同类型的任务", "", "k1", KindTranscribe, "", false, errcode.Conflict},
+		{"没有幂等键", "", "", KindDownload, "dl-3", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, replayed, err := m.Idempotent(tt.workspace, tt.key, tt.kind, create)
+			if tt.wantCode != "" {
+				if errcode.Of(err, errcode.Internal) != tt.wantCode {
+					t.Fatalf("错误 = %v，应为 %s", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil || id != tt.wantID || replayed != tt.wantReplayed {
+				t.Fatalf("Idempotent = %q, %v, %v，应为 %q, %v", id, replayed, err, tt.wantID, tt.wantReplayed)
+			}
+		})
+	}
+}
+
+func TestIdempotentPendingClaim(t *testing.T) {
+	m := NewManager(WithOutputDir(t.TempDir()))
+	create := func() (string, error) { return "dl-1", nil }
+
+	// 另一个实例刚记录了幂等键、还在创建任务
+	m.idempotency[":k1"] = &IdempotencyKey{Key: "k1", Kind: KindDownload, CreatedAt: time.Now()}
+	if _, _, err := m.Idempotent("", "k1", KindDownload, create); errcode.Of(err, errcode.Internal) != errcode.Conflict {
+		t.Fatalf("正在创建时错误 = %v，应为 CONFLICT", err)
+	}
+
+	// 超过 idempotencyClaimTimeout 仍没有任务 ID，按新请求处理
+	m.idempotency[":k1"].CreatedAt = time.Now().Add(-2 * idempotencyClaimTimeout)
+	if id, replayed, err := m.Idempotent("", "k1", KindDownload, create); err != nil || id != "dl-1" || replayed {
+		t.Fatalf("Idempotent = %q, %v, %v", id, replayed, err)
+	}
+
+	// create 失败时不保留记录
+	failed := errors.New("failed")
+	if _, _, err := m.Idempotent("", "k2", KindDownload, func() (string, error) { return "", failed }); !errors.Is(err, failed) {
+		t.Fatalf("错误 = %v", err)
+	}
+	if _, ok := m.idempotency[":k2"]; ok {
+		t.Fatal("create 失败后仍保留幂等键")
+	}
+}
@@ -0,0 +1,114 @@
+package tasks
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/media"
+)
+
+// ImportRequest 把已有的本地视频登记为下载任务
+type ImportRequest struct {
+	// FilePath 视频文件，配置了工作区时相对路径相对于工作区目录
+	FilePath string
+	// URL 视频的来源链接，Title 视频标题，都可以为空
+	URL   string
+	Title string
+	// Workspace 任务所属的工作区，文件必须在工作区目录中
+	Workspace string
+}
+
+// ImportVideo 把不是由本服务下载的视频登记为已完成的下载任务，之后可以像下载的视频一样转录、搜索和统计。
+// 同一工作区中已经登记过（或下载过）同一文件时返回已有的任务，Cached 为 true
+func (m *Manager) ImportVideo(req ImportRequest) (*DownloadTask, error) {
+	path := strings.TrimSpace(req.FilePath)
+	if path == "" {
+		return nil, errcode.New(errcode.InvalidArgument, "file_path 必填")
+	}
+	path = ExpandHome(path)
+	if req.Workspace != "" && !filepath.IsAbs(path) {
+		ws, ok := m.Workspace(req.Workspace)
+		if !ok {
+			return nil, fmt.Errorf("工作区不存在: %s", req.Workspace)
+		}
+		path = filepath.Join(ws.OutputDir, path)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.CheckWorkspacePath(req.Workspace, path); err != nil {
+		return nil, err
+	}
+	if err := m.CheckPath(path); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		return nil, errcode.Newf(errcode.NotFound, "视频文件不存在: %s", path)
+	case info.IsDir():
+		return nil, errcode.Newf(errcode.InvalidArgument, "不是文件: %s", path)
+	case info.Size() == 0:
+		return nil, errcode.Newf(errcode.InvalidArgument, "文件为空: %s", path)
+	case !slices.Contains(videoExts, strings.ToLower(filepath.Ext(path))):
+		return nil, errcode.Newf(errcode.InvalidArgument, "不支持的视频格式: %s（可选 %s）", filepath.Ext(path), strings.Join(videoExts, " "))
+	}
+	source := strings.TrimSpace(req.URL)
+	if source != "" {
+		if u, err := url.Parse(source); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errcode.Newf(errcode.URLInvalid, "无效的链接: %s", source)
+		}
+	}
+
+	m.mu.RLock()
+	for _, t := range m.downloads {
+		if t.Status == StatusCompleted && t.FilePath == path && t.Workspace == req.Workspace {
+			snapshot := *t
+			m.mu.RUnlock()
+			snapshot.Cached = true
+			return &snapshot, nil
+		}
+	}
+	m.mu.RUnlock()
+
+	now := time.Now()
+	task := &DownloadTask{
+		ID:              m.newID(KindDownload),
+		Status:          StatusCompleted,
+		Imported:        true,
+		VideoURL:        source,
+		Title:           strings.TrimSpace(req.Title),
+		OutputDir:       filepath.Dir(path),
+		Workspace:       req.Workspace,
+		Filename:        strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		Percentage:      100,
+		FilePath:        path,
+		FileName:        filepath.Base(path),
+		BytesDownloaded: info.Size(),
+		TotalBytes:      info.Size(),
+		Resolution:      media.Resolution(path),
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		StartTime:       now,
+	}
+	fillSidecars(task)
+
+	m.mu.Lock()
+	if err := m.saveDownloadLocked(task); err != nil {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("保存任务失败: %v", err)
+	}
+	m.downloads[task.ID] = task
+	snapshot := *task
+	m.mu.Unlock()
+	slog.Info("已导入本地视频", "task_id", task.ID, "file_path", path, "url", source)
+	return &snapshot, nil
+}
@@ -0,0 +1,116 @@
+package tasks
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"zhihu-downloader/internal/transcriber"
+)
+
+// videoExts 判断中间音频是否从同名视频提取时检查的视频扩展名
+var videoExts = []string{".mp4", ".mkv", ".webm", ".mov", ".flv"}
+
+// Intermediate 失败的转录留下的中间音频
+type Intermediate struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// TaskID 提取这个音频的转录任务，任务已删除时为空
+	TaskID string `json:"task_id,omitempty"`
+}
+
+// Intermediates 扫描所有输出目录，返回没有用处的中间音频：失败、取消或被中断的转录提取的音频，
+// 以及旁边有同名视频、但没有任何任务引用的音频（例如删除任务时保留了文件）。
+// 正在执行和已完成的转录的音频不会列出
+func (m *Manager) Intermediates() []Intermediate {
+	m.refreshTranscribes()
+	m.refreshPipelines()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.intermediatesLocked()
+}
+
+// RemoveIntermediates 删除 Intermediates 列出的中间音频，返回删除的文件和释放的字节数
+func (m *Manager) RemoveIntermediates() (removed []Intermediate, freed int64) {
+	m.refreshTranscribes()
+	m.refreshPipelines()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, f := range m.intermediatesLocked() {
+		if err := os.Remove(f.Path); err != nil {
+			continue
+		}
+		removed = append(removed, f)
+		freed += f.Size
+		if t, ok := m.transcribes[f.TaskID]; ok {
+			t.MP3Path = ""
+			m.saveTranscribeLocked(t)
+		}
+	}
+	return removed, freed
+}
+
+func (m *Manager) intermediatesLocked() []Intermediate {
+	dirs := map[string]bool{m.outputDir: true}
+	for _, ws := range m.Workspaces() {
+		dirs[ws.OutputDir] = true
+	}
+	for _, t := range m.downloads {
+		if t.OutputDir != "" {
+			dirs[t.OutputDir] = true
+		}
+	}
+
+	inUse := map[string]bool{}
+	failed := map[string]string{}
+	for _, t := range m.transcribes {
+		if t.OutputDir != "" {
+			dirs[t.OutputDir] = true
+		}
+		if t.MP3Path == "" {
+			continue
+		}
+		if t.Status.Retryable() && !m.active[t.ID] {
+			failed[t.MP3Path] = t.ID
+		} else {
+			inUse[t.MP3Path] = true
+		}
+	}
+	for _, t := range m.pipelines {
+		if t.MP3Path != "" && (!t.Status.Terminal() || m.active[t.ID]) {
+			inUse[t.MP3Path] = true
+		}
+	}
+
+	var list []Intermediate
+	for dir := range dirs {
+		for _, format := range transcriber.AudioFormats {
+			matches, _ := filepath.Glob(filepath.Join(dir, "*."+format))
+			for _, path := range matches {
+				id, ok := failed[path]
+				if inUse[path] || (!ok && !hasVideo(path)) {
+					continue
+				}
+				info, err := os.Stat(path)
+				if err != nil || !info.Mode().IsRegular() {
+					continue
+				}
+				list = append(list, Intermediate{Path: path, Size: info.Size(), TaskID: id})
+			}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list
+}
+
+// hasVideo 判断音频旁边是否有同名的视频
+func hasVideo(audioPath string) bool {
+	base := strings.TrimSuffix(audioPath, filepath.Ext(audioPath))
+	for _, ext := range videoExts {
+		if _, err := os.Stat(base + ext); err == nil {
+			return true
+		}
+	}
+	return false
+}
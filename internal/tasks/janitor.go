@@ -0,0 +1,197 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"zhihu-downloader/internal/errcode"
+)
+
+// janitorInterval 检查卡住任务的间隔
+const janitorInterval = time.Minute
+
+// ErrStalled 任务长时间没有任何更新，被后台检查终止
+var ErrStalled = errcode.New(errcode.Stalled, "stalled")
+
+// JanitorOptions 后台检查卡住的任务
+type JanitorOptions struct {
+	// StalledAfter 未结束的任务超过这么久没有任何更新时判定为卡住，0 表示不检查
+	StalledAfter time.Duration
+	// Requeue 卡住的任务标记为失败后自动重新排队；流水线的子任务重试整个流水线
+	Requeue bool
+}
+
+// RunJanitor 定期检查卡住的任务，直到 ctx 结束：
+// 本进程执行的下载和转录超过 StalledAfter 没有更新时终止（连同 ffmpeg / Whisper 等外部程序）并标记为失败；
+// 执行任务的进程已经退出（例如崩溃）、共享数据库中仍处于执行状态的任务直接标记为失败。
+// 失败原因以 "stalled" 开头，Requeue 时之后自动重试
+func (m *Manager) RunJanitor(ctx context.Context, o JanitorOptions) {
+	if o.StalledAfter <= 0 {
+		return
+	}
+	ticker := time.NewTicker(janitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		m.collectStalled(o)
+	}
+}
+
+// collectStalled 执行一次检查：先重新排队上次终止、现在已经结束的任务，再查找新的卡住的任务
+func (m *Manager) collectStalled(o JanitorOptions) {
+	if o.Requeue {
+		m.requeueStalled()
+	}
+	for _, t := range m.StuckTasks(o.StalledAfter) {
+		if m.killStalled(t.ID, time.Duration(t.IdleSeconds)*time.Second, o.Requeue) {
+			slog.Warn("任务长时间没有进度，已终止", "task_id", t.ID, "status", t.Status, "idle_seconds", t.IdleSeconds)
+		}
+	}
+	for _, id := range m.markOrphansStalled(o.StalledAfter, o.Requeue) {
+		slog.Warn("执行任务的进程已退出，任务标记为失败", "task_id", id)
+	}
+	if o.Requeue {
+		m.requeueStalled()
+	}
+}
+
+// killStalled 终止本进程中卡住的任务，任务在 goroutine 退出时按 stallCause 标记为失败
+func (m *Manager) killStalled(id string, idle time.Duration, requeue bool) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cancel, ok := m.cancels[id]
+	if !ok {
+		return false
+	}
+	m.stalled[id] = idle
+	if requeue {
+		m.requeue[m.stalledRootLocked(id)] = true
+	}
+	cancel()
+	return true
+}
+
+// stallCause 任务被 killStalled 终止时返回 ErrStalled，否则原样返回 err
+func (m *Manager) stallCause(id string, err error) error {
+	m.mu.Lock()
+	idle, ok := m.stalled[id]
+	delete(m.stalled, id)
+	m.mu.Unlock()
+	if !ok || err == nil {
+		return err
+	}
+	return fmt.Errorf("%w: %s没有进度，已终止", ErrStalled, formatTimeout(idle.Truncate(time.Minute)))
+}
+
+// markOrphansStalled 把执行进程已经退出、超过 after 没有更新的任务标记为失败，返回标记的任务 ID。
+// 暂停的下载和在外部队列中排队的任务不在执行，保持不变
+func (m *Manager) markOrphansStalled(after time.Duration, requeue bool) []string {
+	if m.shared == nil {
+		return nil
+	}
+	m.refreshDownloads()
+	m.refreshTranscribes()
+	m.refreshPipelines()
+	m.refreshCollections()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	reason := fmt.Sprintf("%v: 执行任务的进程已退出，%s没有进度", ErrStalled, formatTimeout(after))
+	orphan := func(id string, status Status, updated time.Time) bool {
+		return !status.Terminal() && status != StatusPaused && !m.queuedJobLocked(status) && now.Sub(updated) >= after &&
+			!m.localLocked(id) && !m.elsewhereLocked(id, status)
+	}
+
+	var marked []string
+	for _, t := range m.downloads {
+		if orphan(t.ID, t.Status, t.UpdatedAt) {
+			t.Status, t.Error, t.ErrorCode, t.Speed, t.ETASeconds = StatusFailed, reason, errcode.Stalled, "", 0
+			m.touchDownload(t)
+			m.saveDownloadLocked(t)
+			marked = append(marked, t.ID)
+		}
+	}
+	for _, t := range m.transcribes {
+		if orphan(t.ID, t.Status, t.UpdatedAt) {
+			t.Status, t.Error, t.ErrorCode, t.ETASeconds = StatusFailed, reason, errcode.Stalled, 0
+			m.touchTranscribe(t)
+			m.saveTranscribeLocked(t)
+			marked = append(marked, t.ID)
+		}
+	}
+	for _, t := range m.pipelines {
+		if orphan(t.ID, t.Status, t.UpdatedAt) {
+			t.Status, t.Error, t.ErrorCode, t.Speed, t.ETASeconds = StatusFailed, reason, errcode.Stalled, "", 0
+			m.touchPipeline(t)
+			m.savePipelineLocked(t)
+			marked = append(marked, t.ID)
+		}
+	}
+	for _, t := range m.collections {
+		if orphan(t.ID, t.Status, t.UpdatedAt) {
+			t.Status, t.Error, t.ErrorCode = StatusFailed, reason, errcode.Stalled
+			m.touchCollection(t)
+			m.saveCollectionLocked(t)
+			marked = append(marked, t.ID)
+		}
+	}
+	// 合集重试时会重试失败的子任务，子任务不再单独重新排队
+	skip := map[string]bool{}
+	for _, id := range marked {
+		if c, ok := m.collections[id]; ok {
+			for _, child := range c.DownloadIDs {
+				skip[child] = true
+			}
+		}
+	}
+	for _, id := range marked {
+		m.notifyLocked(id)
+		if requeue && !skip[id] {
+			m.requeue[m.stalledRootLocked(id)] = true
+		}
+	}
+	return marked
+}
+
+// stalledRootLocked 卡住的任务重新排队时重试的任务：流水线的子任务重试整个流水线，其他任务重试自身。
+// 合集的子任务单独重试，合集仍在等待时继续汇总
+func (m *Manager) stalledRootLocked(id string) string {
+	for _, p := range m.pipelines {
+		if p.DownloadID == id || p.TranscribeID == id {
+			return p.ID
+		}
+	}
+	return id
+}
+
+// requeueStalled 重试已经结束的卡住任务；仍在退出中的任务留到下次检查
+func (m *Manager) requeueStalled() {
+	m.mu.Lock()
+	var ids []string
+	for id := range m.requeue {
+		status, ok := m.statusLocked(id)
+		switch {
+		case !ok:
+			delete(m.requeue, id)
+		case status.Terminal() && !m.active[id]:
+			ids = append(ids, id)
+			delete(m.requeue, id)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, id := range ids {
+		if err := m.Retry(id); err != nil {
+			slog.Warn("卡住的任务重新排队失败", "task_id", id, "error", err)
+			continue
+		}
+		slog.Info("卡住的任务已重新排队", "task_id", id)
+	}
+}
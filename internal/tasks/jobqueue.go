@@ -0,0 +1,396 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/transcriber"
+)
+
+// JobQueue 外部任务队列（例如 Redis），实现见 jobqueue 包。
+// 设置后下载（包括片段）和转录任务不再在进程内排队，而是加入外部队列，
+// 由共用队列的各个进程在 RunJobQueue 中按自己的并发上限领取执行。
+// 领取的任务有租约（visibility timeout），执行任务的进程退出后租约过期，任务重新投递给其他进程；
+// 失败的任务由队列按退避重新执行，不能重试的失败和重试次数用完的任务转入死信队列
+type JobQueue interface {
+	// Push 加入队列，任务已经在队列中（包括死信队列）时重新排队
+	Push(kind Kind, id string, p Priority) error
+	// Pop 领取一个任务，没有可以执行的任务时返回 nil
+	Pop(kind Kind) (*Job, error)
+	// Extend 续租正在执行的任务
+	Extend(kind Kind, id string) error
+	// Fail 处理执行失败的任务，retry 为 false 或重试次数用完时转入死信队列并返回 0，否则返回重新执行前的等待时间
+	Fail(kind Kind, id, reason string, retry bool) (time.Duration, error)
+	// Remove 把完成、取消、暂停或删除的任务移出队列
+	Remove(kind Kind, id string) error
+	// SetPriority 修改排队中的任务的优先级
+	SetPriority(kind Kind, id string, p Priority) error
+	// Stats 返回一类任务在队列中的数量
+	Stats(kind Kind) (JobStats, error)
+}
+
+// Job 从外部队列领取的任务
+type Job struct {
+	ID string
+	// Attempt 第几次执行，从 1 开始
+	Attempt int
+	// Exhausted 之前几次领取后都没有完成（执行的进程退出或卡住），执行次数已经用完，任务已转入死信队列
+	Exhausted bool
+}
+
+// JobStats 外部队列中一类任务的数量
+type JobStats struct {
+	// Queued 等待执行
+	Queued int `json:"queued"`
+	// Retrying 失败后等待重新执行
+	Retrying int `json:"retrying"`
+	// Active 已被领取，正在执行
+	Active int `json:"active"`
+	// Dead 死信队列中的任务
+	Dead int `json:"dead"`
+}
+
+const (
+	// jobPollInterval 没有空闲名额或队列为空时，重新领取任务的间隔
+	jobPollInterval = time.Second
+	// jobLeaseInterval 正在执行的任务续租的间隔，外部队列的租约时长不小于 30s
+	jobLeaseInterval = 10 * time.Second
+)
+
+// WithJobQueue 设置外部任务队列，为 nil 时在进程内排队
+func WithJobQueue(q JobQueue) Option {
+	return func(m *Manager) { m.jobs = q }
+}
+
+// JobQueue 返回外部任务队列，没有设置时为 nil
+func (m *Manager) JobQueue() JobQueue {
+	return m.jobs
+}
+
+// RunJobQueue 从外部队列领取下载和转录任务执行，直到 ctx 结束。没有设置外部队列时立即返回
+func (m *Manager) RunJobQueue(ctx context.Context) {
+	if m.jobs == nil {
+		return
+	}
+	go m.pollJobs(ctx, KindTranscribe)
+	m.pollJobs(ctx, KindDownload)
+}
+
+// pollJobs 有空闲名额时领取并执行一类任务，名额用完或队列为空时等待
+func (m *Manager) pollJobs(ctx context.Context, kind Kind) {
+	failing := false
+	for {
+		for m.jobSlotFree(kind) {
+			job, err := m.jobs.Pop(kind)
+			if err != nil {
+				// Redis 暂时不可用时只在开始和恢复时记录
+				if !failing {
+					slog.Warn("从任务队列领取任务失败", "kind", kind, "error", err)
+				}
+				failing = true
+				break
+			}
+			if failing {
+				slog.Info("任务队列已恢复", "kind", kind)
+				failing = false
+			}
+			if job == nil {
+				break
+			}
+			m.startJob(kind, job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.jobWake[kind]:
+		case <-time.After(jobPollInterval):
+		}
+	}
+}
+
+// jobSlotFree 本进程是否还能开始一个这类任务
+func (m *Manager) jobSlotFree(kind Kind) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if kind == KindDownload {
+		return m.running < m.maxDownloads
+	}
+	return m.transcribing < m.maxTranscribes
+}
+
+// wakeJobs 本进程加入任务或空出名额后立即领取，不等下一次轮询
+func (m *Manager) wakeJobs(kind Kind) {
+	if m.jobs == nil {
+		return
+	}
+	select {
+	case m.jobWake[kind] <- struct{}{}:
+	default:
+	}
+}
+
+// startJob 执行领取到的任务。其他进程创建的任务从共享存储读取；
+// 已经结束、暂停或删除的任务直接移出队列
+func (m *Manager) startJob(kind Kind, job *Job) {
+	if kind == KindDownload {
+		m.refreshDownload(job.ID)
+	} else {
+		m.refreshTranscribe(job.ID)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status, ok := m.statusLocked(job.ID)
+	switch {
+	case !ok:
+		// 已删除，或者是没有共用数据库的进程创建的任务
+		slog.Warn("队列中的任务不存在，已移出队列", "task_id", job.ID)
+		m.removeJobLocked(kind, job.ID)
+		return
+	case m.localLocked(job.ID):
+		return
+	case status.Terminal() || status == StatusPaused:
+		m.removeJobLocked(kind, job.ID)
+		return
+	case status != StatusQueued && m.elsewhereLocked(job.ID, status):
+		// 执行任务的进程仍在运行，只是没能续租（例如暂时连不上 Redis），由它继续执行
+		slog.Warn("任务的租约已过期，但执行任务的进程仍在运行", "task_id", job.ID)
+		m.removeJobLocked(kind, job.ID)
+		return
+	case job.Exhausted:
+		m.failExhaustedLocked(job)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancels[job.ID] = cancel
+	m.active[job.ID] = true
+	var run func()
+	if kind == KindDownload {
+		t := m.downloads[job.ID]
+		m.running++
+		run = func() {
+			if t.ClipSource != "" {
+				m.runClip(ctx, t)
+				return
+			}
+			m.runDownload(ctx, t, downloadRequest(t))
+		}
+	} else {
+		t := m.transcribes[job.ID]
+		m.transcribing++
+		run = func() { m.runTranscribe(ctx, t, transcribeRequest(t)) }
+	}
+	if job.Attempt > 1 {
+		slog.Info("重新执行队列中的任务", "task_id", job.ID, "attempt", job.Attempt)
+	}
+
+	go func() {
+		stop := m.keepLease(kind, job.ID)
+		run()
+		stop()
+		m.settleJob(kind, job.ID)
+	}()
+}
+
+// failExhaustedLocked 把执行次数已经用完的任务标记为失败
+func (m *Manager) failExhaustedLocked(job *Job) {
+	msg := fmt.Sprintf("任务执行了 %d 次都没有完成，执行任务的进程可能已退出，已转入死信队列", job.Attempt-1)
+	if t, ok := m.downloads[job.ID]; ok {
+		t.Status, t.Error, t.ErrorCode, t.Speed, t.ETASeconds = StatusFailed, msg, errcode.Interrupted, "", 0
+		m.touchDownload(t)
+		m.saveDownloadLocked(t)
+	}
+	if t, ok := m.transcribes[job.ID]; ok {
+		t.Status, t.Error, t.ErrorCode, t.ETASeconds = StatusFailed, msg, errcode.Interrupted, 0
+		m.touchTranscribe(t)
+		m.saveTranscribeLocked(t)
+	}
+	m.notifyLocked(job.ID)
+	slog.Warn("任务多次执行都没有完成，已转入死信队列", "task_id", job.ID, "attempts", job.Attempt-1)
+}
+
+// keepLease 任务执行期间定期续租，返回停止续租的函数
+func (m *Manager) keepLease(kind Kind, id string) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(jobLeaseInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := m.jobs.Extend(kind, id); err != nil {
+					slog.Warn("任务续租失败", "task_id", id, "error", err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// settleJob 任务执行结束后更新队列：失败的任务已经由 retryJob 安排重新执行或转入死信队列，
+// 其他任务（完成、取消、暂停）移出队列
+func (m *Manager) settleJob(kind Kind, id string) {
+	m.mu.RLock()
+	status, ok := m.statusLocked(id)
+	m.mu.RUnlock()
+	if ok && (status == StatusFailed || status == StatusQueued) {
+		return
+	}
+	if err := m.jobs.Remove(kind, id); err != nil {
+		slog.Warn("移出任务队列失败", "task_id", id, "error", err)
+	}
+}
+
+// pushJobLocked 把任务加入外部队列。进程内的 context 不再需要，领取到任务的进程重新创建；
+// 加入失败时任务标记为失败
+func (m *Manager) pushJobLocked(kind Kind, id string, p Priority) {
+	if cancel, ok := m.cancels[id]; ok {
+		cancel()
+		delete(m.cancels, id)
+	}
+	err := m.jobs.Push(kind, id, p)
+	if err == nil {
+		m.wakeJobs(kind)
+		return
+	}
+	slog.Error("加入任务队列失败", "task_id", id, "error", err)
+	msg := "加入任务队列失败: " + err.Error()
+	if t, ok := m.downloads[id]; ok {
+		t.Status, t.Error, t.ErrorCode = StatusFailed, msg, errcode.Unavailable
+		m.touchDownload(t)
+		m.saveDownloadLocked(t)
+	}
+	if t, ok := m.transcribes[id]; ok {
+		t.Status, t.Error, t.ErrorCode = StatusFailed, msg, errcode.Unavailable
+		m.touchTranscribe(t)
+		m.saveTranscribeLocked(t)
+	}
+}
+
+// unqueueJobLocked 把在外部队列中排队（不在本进程中）的下载或转录任务移出队列，
+// 用于取消和暂停。不是这样的任务时返回 false
+func (m *Manager) unqueueJobLocked(id string) bool {
+	if m.jobs == nil || m.localLocked(id) {
+		return false
+	}
+	kind := KindDownload
+	if t, ok := m.transcribes[id]; ok {
+		if t.Status != StatusQueued {
+			return false
+		}
+		kind = KindTranscribe
+	} else if t, ok := m.downloads[id]; !ok || t.Status != StatusQueued {
+		return false
+	}
+	m.removeJobLocked(kind, id)
+	return true
+}
+
+// removeJobLocked 移出外部队列，失败时只记录日志：之后领取到任务的进程会按任务状态再次移出
+func (m *Manager) removeJobLocked(kind Kind, id string) {
+	if err := m.jobs.Remove(kind, id); err != nil {
+		slog.Warn("移出任务队列失败", "task_id", id, "error", err)
+	}
+}
+
+// reprioritizeJobLocked 排队中的任务修改优先级后同步到外部队列
+func (m *Manager) reprioritizeJobLocked(kind Kind, id string, p Priority) {
+	if m.jobs == nil {
+		return
+	}
+	if err := m.jobs.SetPriority(kind, id, p); err != nil {
+		slog.Warn("修改队列中任务的优先级失败", "task_id", id, "error", err)
+	}
+}
+
+// queuedJobLocked 任务是否在外部队列中排队，这样的任务不在任何进程中执行，但也不是被中断的任务
+func (m *Manager) queuedJobLocked(status Status) bool {
+	return m.jobs != nil && status == StatusQueued
+}
+
+// retryJob 外部队列中的任务失败后交给队列处理：暂时性的错误等待一段时间后重新执行，
+// 其他错误和重试次数用完的任务转入死信队列。返回重新执行前的等待时间，不重新执行时为 0
+func (m *Manager) retryJob(ctx context.Context, kind Kind, id string, err error) time.Duration {
+	if m.jobs == nil || err == nil || errors.Is(err, context.Canceled) {
+		return 0
+	}
+	delay, qerr := m.jobs.Fail(kind, id, err.Error(), transient(err))
+	if qerr != nil {
+		logging.FromContext(ctx).Warn("更新任务队列失败", "error", qerr)
+		return 0
+	}
+	return delay
+}
+
+// transient 错误是否可能在重新执行后消失（网络、超时、卡住、外部程序异常退出等）
+func transient(err error) bool {
+	switch errcode.Of(err, errcode.Internal) {
+	case errcode.Upstream, errcode.Timeout, errcode.Stalled, errcode.Interrupted,
+		errcode.DownloadFailed, errcode.TranscribeFailed, errcode.Internal:
+		return true
+	}
+	return false
+}
+
+// requeueDownload 外部队列安排了重新执行时把下载任务改回排队状态并返回 true，错误保留在任务中
+func (m *Manager) requeueDownload(ctx context.Context, task *DownloadTask, err error, fallback errcode.Code) bool {
+	delay := m.retryJob(ctx, KindDownload, task.ID, err)
+	if delay <= 0 {
+		return false
+	}
+	m.updateDownload(task, func(t *DownloadTask) {
+		t.Status = StatusQueued
+		t.Speed, t.ETASeconds = "", 0
+		t.Error, t.ErrorCode, t.ErrorDetail = failure(err, fallback)
+		t.Error += fmt.Sprintf("（%s后重新执行）", formatTimeout(delay.Round(time.Second)))
+	})
+	logging.FromContext(ctx).Warn("任务失败，稍后由队列重新执行", "delay", delay.Round(time.Second), "error", err)
+	return true
+}
+
+// requeueTranscribe 同 requeueDownload，用于转录任务
+func (m *Manager) requeueTranscribe(ctx context.Context, task *TranscribeTask, err error) bool {
+	delay := m.retryJob(ctx, KindTranscribe, task.ID, err)
+	if delay <= 0 {
+		return false
+	}
+	m.updateTranscribe(task, func(t *TranscribeTask) {
+		t.Status = StatusQueued
+		t.Stage = "等待重新执行"
+		t.ETASeconds = 0
+		t.Error, t.ErrorCode, t.ErrorDetail = failure(err, errcode.TranscribeFailed)
+		t.Error += fmt.Sprintf("（%s后重新执行）", formatTimeout(delay.Round(time.Second)))
+	})
+	logging.FromContext(ctx).Warn("转录失败，稍后由队列重新执行", "delay", delay.Round(time.Second), "error", err)
+	return true
+}
+
+// transcribeRequest 按任务保存的参数重新生成转录请求，用于重试和从外部队列领取的任务
+func transcribeRequest(t *TranscribeTask) transcriber.Request {
+	keep := t.KeepIntermediate
+	return transcriber.Request{
+		VideoPath:      t.VideoPath,
+		OutputDir:      t.OutputDir,
+		OutputFilename: t.OutputFilename,
+		Language:       t.Language,
+		Diarize:        t.Diarize,
+		Summarize:      t.Summarize,
+		Model:          t.Model,
+		AudioFormat:    t.AudioFormat,
+		AudioQuality:   t.AudioQuality,
+		InitialPrompt:  t.InitialPrompt,
+		Vocabulary:     t.Vocabulary,
+		Workspace:      t.Workspace,
+
+		KeepIntermediate: &keep,
+	}
+}
@@ -0,0 +1,53 @@
+package tasks
+
+import (
+	"zhihu-downloader/internal/transcriber"
+)
+
+// liveTranscript 正在执行的转录任务已经识别出的分段，任务结束（状态已更新）后删除
+type liveTranscript struct {
+	segments []transcriber.Segment
+	// changed 有新的分段或转录结束时关闭，并换成新的通道
+	changed chan struct{}
+}
+
+// startLive 开始记录转录任务实时识别出的分段，返回传给 transcriber.Request.OnSegment 的回调
+func (m *Manager) startLive(id string) func(transcriber.Segment) {
+	m.mu.Lock()
+	m.live[id] = &liveTranscript{changed: make(chan struct{})}
+	m.mu.Unlock()
+	return func(s transcriber.Segment) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if l, ok := m.live[id]; ok {
+			l.segments = append(l.segments, s)
+			close(l.changed)
+			l.changed = make(chan struct{})
+		}
+	}
+}
+
+// stopLive 转录任务结束后删除实时分段，唤醒等待中的客户端
+func (m *Manager) stopLive(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l, ok := m.live[id]; ok {
+		close(l.changed)
+		delete(m.live, id)
+	}
+}
+
+// LiveSegments 返回正在转录的任务从第 from 段（从 0 开始）起实时识别出的分段，
+// changed 在有新的分段或转录结束时关闭。任务没有在本进程中执行转录时 ok 为 false
+func (m *Manager) LiveSegments(id string, from int) (segments []transcriber.Segment, changed <-chan struct{}, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	l, ok := m.live[id]
+	if !ok {
+		return nil, nil, false
+	}
+	if from < len(l.segments) {
+		segments = append(segments, l.segments[max(from, 0):]...)
+	}
+	return segments, l.changed, true
+}
@@ -0,0 +1,1302 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/hls"
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/media"
+	"zhihu-downloader/internal/notify"
+	"zhihu-downloader/internal/summarizer"
+	"zhihu-downloader/internal/transcriber"
+	"zhihu-downloader/internal/zhihu"
+)
+
+// Persister 把任务状态写入持久化存储，每次状态变化都会调用
+type Persister interface {
+	SaveDownload(task *DownloadTask) error
+	SaveTranscribe(task *TranscribeTask) error
+	DeleteDownload(id string) error
+	DeleteTranscribe(id string) error
+	SavePipeline(task *PipelineTask) error
+	DeletePipeline(id string) error
+	SaveCollection(task *CollectionTask) error
+	DeleteCollection(id string) error
+	SaveSchedule(s *Schedule) error
+	DeleteSchedule(id string) error
+	SaveIndex(e *IndexEntry) error
+	DeleteIndex(key string) error
+	LookupIndex(key string) (*IndexEntry, error)
+	// SaveTranscript 保存转录任务的结构化结果，删除转录任务时一起删除
+	SaveTranscript(taskID string, t *transcriber.Transcript) error
+	// LookupTranscript 查找转录任务的结构化结果，没有记录时返回 nil
+	LookupTranscript(taskID string) (*transcriber.Transcript, error)
+	// SearchTranscripts 搜索包含所有关键词的转录段落，最新的转录在前，最多返回 limit 段；
+	// workspace 不为空时只搜索该工作区的转录任务
+	SearchTranscripts(keywords []string, workspace string, limit int) ([]TranscriptMatch, error)
+	// SaveEmbeddings 用 chunks 替换转录任务在 model 下的向量，删除转录任务时一起删除
+	SaveEmbeddings(taskID, model string, chunks []EmbeddedChunk) error
+	// SearchEmbeddings 返回 model 下与 vector 最相似的 limit 个片段，最相似的在前，Score 为余弦相似度；
+	// workspace 不为空时只搜索该工作区的转录任务
+	SearchEmbeddings(model string, vector []float32, workspace string, limit int) ([]TranscriptMatch, error)
+	// UnembeddedTranscripts 返回还没有 model 下向量的转录任务，最新的在前
+	UnembeddedTranscripts(model string) ([]string, error)
+	// SaveEvent 追加任务的历史事件，删除任务时一起删除
+	SaveEvent(e *TaskEvent) error
+	// Events 返回任务的历史事件，按时间先后排列
+	Events(taskID string) ([]TaskEvent, error)
+	// SaveOutput 保存失败任务的外部程序输出（覆盖之前的记录），删除任务时一起删除
+	SaveOutput(o *TaskOutput) error
+	// Output 返回任务保存的外部程序输出，没有记录时返回 nil
+	Output(taskID string) (*TaskOutput, error)
+	// Stats 查询下载和转录的各项计数、总大小、转录时长和完成下载最多的几天，workspace 为空时统计所有任务
+	Stats(workspace string) (*Stats, error)
+	// SaveBatch 保存批量转录，Batch 返回批量转录，不存在时返回 nil
+	SaveBatch(b *TranscribeBatch) error
+	Batch(id string) (*TranscribeBatch, error)
+	// SaveSubscription 保存订阅，DeleteSubscription 删除订阅及其视频记录；Subscription 不存在时返回 nil
+	SaveSubscription(s *Subscription) error
+	DeleteSubscription(id string) error
+	Subscription(id string) (*Subscription, error)
+	Subscriptions() ([]*Subscription, error)
+	// SaveSubscriptionItem 记录订阅已经处理过的视频，SubscriptionItems 返回视频链接到任务 ID 的映射
+	SaveSubscriptionItem(id, url, taskID string) error
+	SubscriptionItems(id string) (map[string]string, error)
+	// ClaimIdempotencyKey 删除过期的键后插入 k，工作区和键相同的记录已存在时不修改，返回已有的记录；
+	// 共用数据库的多个实例同时插入时只有一个成功。SaveIdempotencyKey 记录幂等键创建的任务（覆盖之前的记录），
+	// DeleteIdempotencyKey 在记录与 k 相同时删除，IdempotencyKey 没有记录时返回 nil
+	ClaimIdempotencyKey(k *IdempotencyKey) (*IdempotencyKey, error)
+	SaveIdempotencyKey(k *IdempotencyKey) error
+	DeleteIdempotencyKey(k *IdempotencyKey) error
+	IdempotencyKey(workspace, key string) (*IdempotencyKey, error)
+	// SaveContent 记录范围内内容的原始文件（覆盖之前的记录），LookupContent 没有记录时返回 nil
+	SaveContent(e *ContentEntry) error
+	LookupContent(scope, hash string) (*ContentEntry, error)
+	// SaveContentSource 记录下载过该内容的链接，ContentSources 按时间先后返回范围内的链接，删除任务时保留
+	SaveContentSource(s *ContentSource) error
+	ContentSources(scope, hash string) ([]ContentSource, error)
+}
+
+// Option 配置 Manager
+type Option func(*Manager)
+
+// WithIDGenerator 自定义任务 ID 生成方式（默认 UUID）
+func WithIDGenerator(fn func(kind Kind) string) Option {
+	return func(m *Manager) { m.newID = fn }
+}
+
+// WithPersister 设置持久化存储
+func WithPersister(p Persister) Option {
+	return func(m *Manager) { m.persister = p }
+}
+
+// WithMaxConcurrentDownloads 设置同时执行的下载任务数（默认 3），超出的任务排队等待
+func WithMaxConcurrentDownloads(n int) Option {
+	return func(m *Manager) {
+		if n > 0 {
+			m.maxDownloads = n
+		}
+	}
+}
+
+// WithMaxConcurrentTranscribes 设置同时执行的转录任务数（默认 1），与下载分开限制，超出的任务排队等待
+func WithMaxConcurrentTranscribes(n int) Option {
+	return func(m *Manager) {
+		if n > 0 {
+			m.maxTranscribes = n
+		}
+	}
+}
+
+// WithOutputDir 设置默认下载目录（默认 ~/Downloads）
+func WithOutputDir(dir string) Option {
+	return func(m *Manager) {
+		if dir != "" {
+			m.outputDir = ExpandHome(dir)
+		}
+	}
+}
+
+// WithFilenameTemplate 设置未指定文件名时的默认文件名模板（默认 {title}）
+func WithFilenameTemplate(tmpl string) Option {
+	return func(m *Manager) { m.filenameTemplate = tmpl }
+}
+
+// DefaultMaxConcurrentDownloads 默认同时执行的下载任务数
+const DefaultMaxConcurrentDownloads = 3
+
+// DefaultMaxConcurrentTranscribes 默认同时执行的转录任务数。Whisper 会占满 CPU / GPU，默认一次只转录一个
+const DefaultMaxConcurrentTranscribes = 1
+
+// linkResolveTimeout 创建下载任务时跟随短链接跳转的最长时间
+const linkResolveTimeout = 15 * time.Second
+
+// queuedDownload 排队中的下载任务
+type queuedDownload struct {
+	ctx  context.Context
+	task *DownloadTask
+	req  downloader.Request
+}
+
+// queuedTranscribe 排队中的转录任务
+type queuedTranscribe struct {
+	ctx  context.Context
+	task *TranscribeTask
+	req  transcriber.Request
+}
+
+// Manager 创建并执行任务，保存任务的最新状态
+type Manager struct {
+	mu          sync.RWMutex
+	downloads   map[string]*DownloadTask
+	transcribes map[string]*TranscribeTask
+	pipelines   map[string]*PipelineTask
+	collections map[string]*CollectionTask
+	cancels     map[string]context.CancelFunc
+	watchers    map[string][]chan struct{}
+	// active 后台 goroutine 仍在执行的任务（取消后到 goroutine 退出之前也算）
+	active map[string]bool
+	// cleanup 取消后需要删除未完成文件的任务，在 goroutine 退出时清理
+	cleanup map[string]bool
+	// stalled 被后台检查终止的任务及终止前没有更新的时长，requeue 之后需要自动重试的任务，见 janitor.go
+	stalled map[string]time.Duration
+	requeue map[string]bool
+	// reserved 正在执行的下载预留的空间（估算的文件大小）
+	reserved map[string]int64
+	// schedules 计划任务，变化后通过 scheduleWake 通知 RunSchedules
+	schedules    map[string]*Schedule
+	scheduleWake chan struct{}
+	// index 没有持久化存储时的下载索引，有持久化存储时直接查询数据库
+	index map[string]*IndexEntry
+	// contents / contentSources 没有持久化存储时的内容索引和下载过各内容的链接，dedup 去重方式，见 content.go
+	contents       map[string]*ContentEntry
+	contentSources map[string][]ContentSource
+	dedup          DedupMode
+	// states 上次保存时各任务的状态，events 没有持久化存储时的任务历史事件，见 history.go
+	states map[string]taskState
+	events map[string][]TaskEvent
+	// listener 任务事件的监听函数，见 WithEventListener
+	listener func(TaskEvent)
+	// outputs 没有持久化存储时失败任务的外部程序输出，见 output.go
+	outputs map[string]*TaskOutput
+	// batches 没有持久化存储时的批量转录，见 batch.go
+	batches map[string]*TranscribeBatch
+	// live 正在转录的任务实时识别出的分段，见 live.go
+	live map[string]*liveTranscript
+	// subscriptions / subscriptionItems 没有持久化存储时的订阅和已经处理过的视频，
+	// checking 正在检查的订阅，subscriptionWake 订阅变化后通知 RunSubscriptions，见 subscription.go
+	subscriptions     map[string]*Subscription
+	subscriptionItems map[string]map[string]string
+	checking          map[string]bool
+	subscriptionWake  chan struct{}
+	// idempotency 没有持久化存储时的幂等键，idempotencyBusy 正在处理的幂等键，见 idempotency.go
+	idempotency     map[string]*IdempotencyKey
+	idempotencyBusy map[string]chan struct{}
+
+	// 下载队列：running 为正在执行的任务数
+	queue        []queuedDownload
+	running      int
+	maxDownloads int
+	// 转录队列，与下载队列分开限制并发：transcribing 为正在执行的转录任务数
+	transcribeQueue []queuedTranscribe
+	transcribing    int
+	maxTranscribes  int
+	// maxRetries 下载任务失败后自动重试的次数
+	maxRetries int
+	// jobs 外部任务队列，设置后下载和转录任务不使用上面的进程内队列，jobWake 通知 RunJobQueue 领取，见 jobqueue.go
+	jobs    JobQueue
+	jobWake map[Kind]chan struct{}
+
+	newID            func(kind Kind) string
+	persister        Persister
+	shared           SharedStore
+	outputDir        string
+	workspaces       map[string]Workspace
+	filenameTemplate string
+	// allowedRoots 允许读写的目录，为空时不限制，见 sandbox.go
+	allowedRoots []string
+	preview      PreviewOptions
+	metadata     MetadataOptions
+	transcode    media.TranscodeOptions
+	quota        QuotaOptions
+	timeouts     TimeoutOptions
+}
+
+// NewManager 创建任务管理器
+func NewManager(opts ...Option) *Manager {
+	m := &Manager{
+		downloads:         make(map[string]*DownloadTask),
+		transcribes:       make(map[string]*TranscribeTask),
+		pipelines:         make(map[string]*PipelineTask),
+		collections:       make(map[string]*CollectionTask),
+		cancels:           make(map[string]context.CancelFunc),
+		watchers:          make(map[string][]chan struct{}),
+		active:            make(map[string]bool),
+		cleanup:           make(map[string]bool),
+		stalled:           make(map[string]time.Duration),
+		requeue:           make(map[string]bool),
+		reserved:          make(map[string]int64),
+		schedules:         make(map[string]*Schedule),
+		scheduleWake:      make(chan struct{}, 1),
+		index:             make(map[string]*IndexEntry),
+		contents:          make(map[string]*ContentEntry),
+		contentSources:    make(map[string][]ContentSource),
+		dedup:             DedupHardlink,
+		states:            make(map[string]taskState),
+		events:            make(map[string][]TaskEvent),
+		outputs:           make(map[string]*TaskOutput),
+		batches:           make(map[string]*TranscribeBatch),
+		idempotency:       make(map[string]*IdempotencyKey),
+		idempotencyBusy:   make(map[string]chan struct{}),
+		live:              make(map[string]*liveTranscript),
+		subscriptions:     make(map[string]*Subscription),
+		subscriptionItems: make(map[string]map[string]string),
+		checking:          make(map[string]bool),
+		subscriptionWake:  make(chan struct{}, 1),
+		jobWake:           map[Kind]chan struct{}{KindDownload: make(chan struct{}, 1), KindTranscribe: make(chan struct{}, 1)},
+		maxDownloads:      DefaultMaxConcurrentDownloads,
+		maxTranscribes:    DefaultMaxConcurrentTranscribes,
+		maxRetries:        downloader.DefaultMaxRetries,
+		outputDir:         DefaultOutputDir(),
+		newID:             func(Kind) string { return uuid.New().String() },
+		preview:           PreviewOptions{Thumbnail: true, SpriteFrames: DefaultSpriteFrames},
+		quota:             QuotaOptions{MinFree: DefaultMinFree, Policy: QuotaReject},
+		timeouts:          TimeoutOptions{DownloadStall: DefaultDownloadStall},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Restore 载入之前保存的任务，未结束的任务需要调用 MarkInterrupted 标记
+func (m *Manager) Restore(downloads []*DownloadTask, transcribes []*TranscribeTask, pipelines []*PipelineTask, collections []*CollectionTask) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// 记下恢复时的状态，之后（例如 MarkInterrupted）的变化才会记录为事件
+	for _, t := range downloads {
+		m.downloads[t.ID] = t
+		m.states[t.ID] = taskState{status: t.Status, err: t.Error, retries: t.Retries}
+	}
+	for _, t := range transcribes {
+		m.transcribes[t.ID] = t
+		m.states[t.ID] = taskState{status: t.Status, err: t.Error}
+	}
+	for _, t := range pipelines {
+		m.pipelines[t.ID] = t
+		m.states[t.ID] = taskState{status: t.Status, err: t.Error}
+	}
+	for _, t := range collections {
+		m.collections[t.ID] = t
+		m.states[t.ID] = taskState{status: t.Status, err: t.Error}
+	}
+}
+
+// MarkInterrupted 把上次进程退出时仍未结束的任务标记为 interrupted，
+// 之后可以通过 Retry 重新执行。共用数据库的其他进程正在执行的任务不受影响；
+// 使用外部队列时下载和转录任务在租约过期后由队列重新投递，也不标记。返回标记的任务数
+func (m *Manager) MarkInterrupted() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	marked := 0
+	for _, t := range m.downloads {
+		// 暂停的下载保持暂停，之后仍然可以继续
+		if t.Status.Terminal() || t.Status == StatusPaused || m.active[t.ID] || m.elsewhereLocked(t.ID, t.Status) || m.jobs != nil {
+			continue
+		}
+		t.Status = StatusInterrupted
+		t.Speed = ""
+		t.Error, t.ErrorCode = "服务重启，任务被中断", errcode.Interrupted
+		t.UpdatedAt = time.Now()
+		m.saveDownloadLocked(t)
+		marked++
+	}
+	for _, t := range m.transcribes {
+		if t.Status.Terminal() || m.active[t.ID] || m.elsewhereLocked(t.ID, t.Status) || m.jobs != nil {
+			continue
+		}
+		t.Status = StatusInterrupted
+		t.Error, t.ErrorCode = "服务重启，任务被中断", errcode.Interrupted
+		t.UpdatedAt = time.Now()
+		m.saveTranscribeLocked(t)
+		marked++
+	}
+	for _, t := range m.pipelines {
+		if t.Status.Terminal() || m.active[t.ID] || m.elsewhereLocked(t.ID, t.Status) {
+			continue
+		}
+		t.Status = StatusInterrupted
+		t.Speed = ""
+		t.Error, t.ErrorCode = "服务重启，任务被中断", errcode.Interrupted
+		t.UpdatedAt = time.Now()
+		m.savePipelineLocked(t)
+		marked++
+	}
+	for _, t := range m.collections {
+		if t.Status.Terminal() || m.active[t.ID] || m.elsewhereLocked(t.ID, t.Status) {
+			continue
+		}
+		t.Status = StatusInterrupted
+		t.Error, t.ErrorCode = "服务重启，任务被中断", errcode.Interrupted
+		t.UpdatedAt = time.Now()
+		m.saveCollectionLocked(t)
+		marked++
+	}
+	return marked
+}
+
+// Retry 重新执行失败、取消或被中断的任务。
+// HLS 下载会复用上次已完成的分片，流水线任务从失败的阶段继续，合集任务只重新下载失败的视频
+func (m *Manager) Retry(id string) error {
+	m.refreshAny(id)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.active[id] {
+		return fmt.Errorf("任务仍在执行")
+	}
+
+	if t, ok := m.downloads[id]; ok {
+		if !t.Status.Retryable() {
+			return fmt.Errorf("任务状态为 %s，无法重试", t.Status)
+		}
+		if t.OutputDir == "" {
+			return fmt.Errorf("任务缺少原始参数，无法重试")
+		}
+		now := time.Now()
+		t.Percentage = 0
+		t.Speed = ""
+		t.BytesDownloaded, t.TotalBytes = 0, 0
+		t.AvgSpeed, t.PeakSpeed, t.SpeedHistory = 0, 0, nil
+		t.Error, t.ErrorCode, t.ErrorDetail = "", "", ""
+		t.Retries = 0
+		t.StartTime = now
+		t.UpdatedAt = now
+
+		ctx, cancel := context.WithCancel(context.Background())
+		m.cancels[id] = cancel
+		m.enqueueLocked(ctx, t, downloadRequest(t))
+		m.notifyLocked(id)
+		return nil
+	}
+
+	if t, ok := m.transcribes[id]; ok {
+		if !t.Status.Retryable() {
+			return fmt.Errorf("任务状态为 %s，无法重试", t.Status)
+		}
+		if t.OutputDir == "" || t.OutputFilename == "" {
+			return fmt.Errorf("任务缺少原始参数，无法重试")
+		}
+		now := time.Now()
+		t.Percentage = 0
+		t.Error, t.ErrorCode, t.ErrorDetail = "", "", ""
+		t.StartTime = now
+		t.UpdatedAt = now
+
+		ctx, cancel := context.WithCancel(context.Background())
+		m.cancels[id] = cancel
+		m.enqueueTranscribeLocked(ctx, t, transcribeRequest(t))
+		m.notifyLocked(id)
+		return nil
+	}
+
+	if t, ok := m.pipelines[id]; ok {
+		if err := m.retryPipelineLocked(t); err != nil {
+			return err
+		}
+		m.notifyLocked(id)
+		return nil
+	}
+
+	if t, ok := m.collections[id]; ok {
+		if err := m.retryCollectionLocked(t); err != nil {
+			return err
+		}
+		m.notifyLocked(id)
+		return nil
+	}
+
+	return fmt.Errorf("任务不存在")
+}
+
+// StartDownload 创建下载任务并在后台执行。
+// 同一视频以相同清晰度下载到同一目录且文件仍存在时，直接返回已完成的任务（Cached 为 true），
+// req.Force 为 true 时重新下载
+func (m *Manager) StartDownload(req downloader.Request) (*DownloadTask, error) {
+	if req.URL == "" {
+		return nil, errcode.New(errcode.URLInvalid, "URL 必填")
+	}
+	if err := downloader.ValidateConnections(req.Connections); err != nil {
+		return nil, err
+	}
+	if err := media.ValidateFFmpegArgs(req.FFmpegArgs); err != nil {
+		return nil, err
+	}
+	if err := downloader.ValidateFilename(req.Filename); err != nil {
+		return nil, err
+	}
+	if err := downloader.ValidateRequestHeaders(req.Headers, req.Cookies); err != nil {
+		return nil, err
+	}
+	if err := media.CheckHWAccel(req.HWAccel); err != nil {
+		return nil, err
+	}
+	transcode, err := m.resolveTranscode(req.Transcode)
+	if err != nil {
+		return nil, err
+	}
+	req.Transcode = transcode
+	if err := notify.CheckTargets(req.Notify); err != nil {
+		return nil, err
+	}
+	priority, err := ParsePriority(req.Priority)
+	if err != nil {
+		return nil, err
+	}
+	// 提取分享文本中的链接并规范化，不能下载的链接类型直接报错
+	resolveCtx, cancelResolve := context.WithTimeout(context.Background(), linkResolveTimeout)
+	link, err := zhihu.ResolveLink(resolveCtx, req.URL)
+	if err == nil {
+		// 检查跟随短链接跳转之后的地址，不能下载的网站和内网地址直接报错
+		err = downloader.CheckURL(resolveCtx, link.URL)
+	}
+	cancelResolve()
+	if err != nil {
+		return nil, err
+	}
+	req.URL = link.URL
+
+	outputDir, err := m.WorkspaceOutputDir(req.Workspace, req.OutputDir)
+	if err != nil {
+		return nil, err
+	}
+	req.OutputDir = outputDir
+
+	quality, err := downloader.NormalizeQuality(req.Quality)
+	if err != nil {
+		return nil, err
+	}
+	req.Quality = quality
+
+	backend, err := downloader.ResolveBackend(req.URL, req.Backend)
+	if err != nil {
+		return nil, err
+	}
+	req.Backend = backend
+	if err := checkComments(req); err != nil {
+		return nil, err
+	}
+
+	if !req.Force {
+		if e := m.lookupDownloaded(downloadKey(req.URL, req.Quality, req.OutputDir)); e != nil {
+			slog.Info("视频已下载过，返回已有文件", "url", req.URL, "file_path", e.FilePath, "task_id", e.TaskID)
+			return m.reuseDownload(e, req)
+		}
+	}
+	if err := m.checkSpace(req.OutputDir); err != nil {
+		return nil, err
+	}
+
+	if req.Filename == "" && req.FilenameTemplate == "" {
+		req.FilenameTemplate = m.filenameTemplate
+	}
+	if err := downloader.ValidateFilenameTemplate(req.FilenameTemplate); err != nil {
+		return nil, err
+	}
+
+	id := m.newID(KindDownload)
+
+	now := time.Now()
+	task := &DownloadTask{
+		ID:        id,
+		Status:    StatusPending,
+		VideoURL:  req.URL,
+		Quality:   req.Quality,
+		Backend:   req.Backend,
+		OutputDir: req.OutputDir,
+		Workspace: req.Workspace,
+		Filename:  req.Filename,
+		MaxRate:   req.MaxRate,
+		Comments:  req.Comments,
+		Notify:    req.Notify,
+		Priority:  priority,
+		CreatedAt: now,
+		UpdatedAt: now,
+		StartTime: now,
+
+		Connections:      req.Connections,
+		CommentsLimit:    req.CommentsLimit,
+		FilenameTemplate: req.FilenameTemplate,
+		FFmpegArgs:       req.FFmpegArgs,
+		HWAccel:          req.HWAccel,
+		Headers:          req.Headers,
+		Cookies:          req.Cookies,
+
+		TranscodeCodec:     req.Transcode.Codec,
+		TranscodeMaxHeight: req.Transcode.MaxHeight,
+		TranscodeCRF:       req.Transcode.CRF,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.mu.Lock()
+	if err := m.saveDownloadLocked(task); err != nil {
+		m.mu.Unlock()
+		cancel()
+		return nil, fmt.Errorf("保存任务失败: %v", err)
+	}
+	m.downloads[id] = task
+	m.cancels[id] = cancel
+	m.enqueueLocked(ctx, task, req)
+	m.mu.Unlock()
+
+	return m.Download(id)
+}
+
+// StartTranscribe 创建转录任务并在后台执行
+func (m *Manager) StartTranscribe(req transcriber.Request) (*TranscribeTask, error) {
+	if req.VideoPath == "" {
+		return nil, fmt.Errorf("video_path 必填")
+	}
+	if err := downloader.ValidateFilename(req.OutputFilename); err != nil {
+		return nil, err
+	}
+	req.VideoPath = ExpandHome(req.VideoPath)
+	if err := m.CheckWorkspacePath(req.Workspace, req.VideoPath); err != nil {
+		return nil, err
+	}
+	if err := m.CheckPath(req.VideoPath); err != nil {
+		return nil, err
+	}
+	if err := notify.CheckTargets(req.Notify); err != nil {
+		return nil, err
+	}
+	priority, err := ParsePriority(req.Priority)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(req.VideoPath); err != nil {
+		return nil, fmt.Errorf("视频文件不存在: %v", err)
+	}
+	if req.Language == "" {
+		req.Language = transcriber.LanguageAuto
+	}
+	if summarizer.Auto() {
+		req.Summarize = true
+	}
+	model, err := transcriber.ResolveModel(req.Model)
+	if err != nil {
+		return nil, err
+	}
+	req.Model = model
+	if req.AudioFormat, req.AudioQuality, err = transcriber.ResolveAudio(req.AudioFormat, req.AudioQuality); err != nil {
+		return nil, err
+	}
+	if req.InitialPrompt, req.Vocabulary, err = m.transcribePrompt(req.Workspace, req.InitialPrompt, req.Vocabulary); err != nil {
+		return nil, err
+	}
+	keep := transcriber.KeepIntermediate(req.KeepIntermediate)
+	req.KeepIntermediate = &keep
+	if req.OutputDir == "" {
+		req.OutputDir = filepath.Dir(req.VideoPath)
+	}
+	req.OutputDir = ExpandHome(req.OutputDir)
+	if err := m.CheckWorkspacePath(req.Workspace, req.OutputDir); err != nil {
+		return nil, err
+	}
+	if err := m.CheckPath(req.OutputDir); err != nil {
+		return nil, err
+	}
+	if err := downloader.ValidateFilenameTemplate(req.FilenameTemplate); err != nil {
+		return nil, err
+	}
+	id := m.newID(KindTranscribe)
+	req.OutputFilename = m.transcribeFilename(req, id)
+
+	now := time.Now()
+	task := &TranscribeTask{
+		ID:             id,
+		Status:         StatusPending,
+		Stage:          "等待开始",
+		VideoPath:      req.VideoPath,
+		Workspace:      req.Workspace,
+		Language:       req.Language,
+		Diarize:        req.Diarize,
+		Summarize:      req.Summarize,
+		Model:          req.Model,
+		AudioFormat:    req.AudioFormat,
+		AudioQuality:   req.AudioQuality,
+		InitialPrompt:  req.InitialPrompt,
+		Vocabulary:     req.Vocabulary,
+		OutputDir:      req.OutputDir,
+		OutputFilename: req.OutputFilename,
+		Notify:         req.Notify,
+		Priority:       priority,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		StartTime:      now,
+
+		KeepIntermediate: keep,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.mu.Lock()
+	if err := m.saveTranscribeLocked(task); err != nil {
+		m.mu.Unlock()
+		cancel()
+		return nil, fmt.Errorf("保存任务失败: %v", err)
+	}
+	m.transcribes[task.ID] = task
+	m.cancels[task.ID] = cancel
+	m.enqueueTranscribeLocked(ctx, task, req)
+	m.mu.Unlock()
+
+	return m.Transcribe(task.ID)
+}
+
+// Download 返回下载任务的快照
+func (m *Manager) Download(id string) (*DownloadTask, error) {
+	m.refreshDownload(id)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	task, ok := m.downloads[id]
+	if !ok {
+		return nil, fmt.Errorf("下载任务不存在")
+	}
+	snapshot := *task
+	snapshot.QueuePosition = m.queuePositionsLocked()[id]
+	return &snapshot, nil
+}
+
+// Transcribe 返回转录任务的快照
+func (m *Manager) Transcribe(id string) (*TranscribeTask, error) {
+	m.refreshTranscribe(id)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	task, ok := m.transcribes[id]
+	if !ok {
+		return nil, fmt.Errorf("转录任务不存在")
+	}
+	snapshot := *task
+	snapshot.QueuePosition = m.queuePositionsLocked()[id]
+	return &snapshot, nil
+}
+
+// Downloads 返回所有下载任务（按创建时间倒序）
+func (m *Manager) Downloads() []*DownloadTask {
+	m.refreshDownloads()
+	m.mu.RLock()
+	positions := m.queuePositionsLocked()
+	list := make([]*DownloadTask, 0, len(m.downloads))
+	for _, t := range m.downloads {
+		snapshot := *t
+		snapshot.QueuePosition = positions[t.ID]
+		list = append(list, &snapshot)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list
+}
+
+// Transcribes 返回所有转录任务（按创建时间倒序）
+func (m *Manager) Transcribes() []*TranscribeTask {
+	m.refreshTranscribes()
+	m.mu.RLock()
+	positions := m.queuePositionsLocked()
+	list := make([]*TranscribeTask, 0, len(m.transcribes))
+	for _, t := range m.transcribes {
+		snapshot := *t
+		snapshot.QueuePosition = positions[t.ID]
+		list = append(list, &snapshot)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list
+}
+
+// Cancel 取消正在执行的任务，任务不存在或已结束时返回 false。
+// 其他进程正在执行的任务通过共享存储请求对方取消，稍后才会变为 cancelled
+func (m *Manager) Cancel(id string) bool {
+	m.refreshAny(id)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cancel, ok := m.cancels[id]
+	if !ok {
+		// 暂停的下载没有在执行，直接标记为取消
+		if t, ok := m.downloads[id]; ok && t.Status == StatusPaused {
+			t.Status = StatusCancelled
+			t.Error, t.ErrorCode = "用户取消", errcode.Cancelled
+			m.touchDownload(t)
+			m.saveDownloadLocked(t)
+			m.notifyLocked(id)
+			return true
+		}
+		if !m.unqueueJobLocked(id) {
+			return m.cancelElsewhereLocked(id)
+		}
+	} else {
+		cancel()
+		delete(m.cancels, id)
+		m.dequeueLocked(id)
+	}
+
+	if t, ok := m.downloads[id]; ok && !t.Status.Terminal() {
+		t.Status = StatusCancelled
+		t.Error, t.ErrorCode = "用户取消", errcode.Cancelled
+		m.touchDownload(t)
+		m.saveDownloadLocked(t)
+	}
+	if t, ok := m.transcribes[id]; ok && !t.Status.Terminal() {
+		t.Status = StatusCancelled
+		t.Error, t.ErrorCode = "用户取消", errcode.Cancelled
+		m.touchTranscribe(t)
+		m.saveTranscribeLocked(t)
+	}
+	if t, ok := m.pipelines[id]; ok && !t.Status.Terminal() {
+		t.Status = StatusCancelled
+		t.Speed = ""
+		t.Error, t.ErrorCode = "用户取消", errcode.Cancelled
+		m.touchPipeline(t)
+		m.savePipelineLocked(t)
+	}
+	if t, ok := m.collections[id]; ok && !t.Status.Terminal() {
+		t.Status = StatusCancelled
+		t.Error, t.ErrorCode = "用户取消", errcode.Cancelled
+		m.touchCollection(t)
+		m.saveCollectionLocked(t)
+	}
+	m.notifyLocked(id)
+	return true
+}
+
+// enqueueLocked 把下载任务加入队列，有空闲名额时立即开始
+func (m *Manager) enqueueLocked(ctx context.Context, task *DownloadTask, req downloader.Request) {
+	task.Status = StatusQueued
+	m.saveDownloadLocked(task)
+	if m.jobs != nil {
+		m.pushJobLocked(KindDownload, task.ID, task.Priority)
+		return
+	}
+	m.insertQueuedLocked(queuedDownload{ctx: ctx, task: task, req: req})
+	m.dispatchLocked()
+}
+
+// dispatchLocked 按优先级和先后顺序启动排队的任务，直到达到并发上限。使用外部队列时通知 RunJobQueue 领取
+func (m *Manager) dispatchLocked() {
+	m.wakeJobs(KindDownload)
+	for m.running < m.maxDownloads && len(m.queue) > 0 {
+		next := m.queue[0]
+		m.queue = m.queue[1:]
+		if next.ctx.Err() != nil {
+			continue
+		}
+		m.running++
+		m.active[next.task.ID] = true
+		if next.task.ClipSource != "" {
+			go m.runClip(next.ctx, next.task)
+			continue
+		}
+		go m.runDownload(next.ctx, next.task, next.req)
+	}
+}
+
+// enqueueTranscribeLocked 把转录任务加入转录队列，有空闲名额时立即开始
+func (m *Manager) enqueueTranscribeLocked(ctx context.Context, task *TranscribeTask, req transcriber.Request) {
+	task.Status = StatusQueued
+	task.Stage = "排队中"
+	m.saveTranscribeLocked(task)
+	if m.jobs != nil {
+		m.pushJobLocked(KindTranscribe, task.ID, task.Priority)
+		return
+	}
+	m.transcribeQueue = insertByPriority(m.transcribeQueue, queuedTranscribe{ctx: ctx, task: task, req: req},
+		func(q queuedTranscribe) Priority { return q.task.Priority })
+	m.dispatchTranscribesLocked()
+}
+
+// dispatchTranscribesLocked 按优先级和先后顺序启动排队的转录任务，直到达到转录的并发上限
+func (m *Manager) dispatchTranscribesLocked() {
+	m.wakeJobs(KindTranscribe)
+	for m.transcribing < m.maxTranscribes && len(m.transcribeQueue) > 0 {
+		next := m.transcribeQueue[0]
+		m.transcribeQueue = m.transcribeQueue[1:]
+		if next.ctx.Err() != nil {
+			continue
+		}
+		m.transcribing++
+		m.active[next.task.ID] = true
+		go m.runTranscribe(next.ctx, next.task, next.req)
+	}
+}
+
+// dequeueLocked 从下载或转录队列中移除尚未开始的任务
+func (m *Manager) dequeueLocked(id string) {
+	for i, q := range m.queue {
+		if q.task.ID == id {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			return
+		}
+	}
+	for i, q := range m.transcribeQueue {
+		if q.task.ID == id {
+			m.transcribeQueue = append(m.transcribeQueue[:i], m.transcribeQueue[i+1:]...)
+			return
+		}
+	}
+}
+
+// queuePositionsLocked 返回排队任务在各自队列中的位置（从 1 开始）
+func (m *Manager) queuePositionsLocked() map[string]int {
+	positions := make(map[string]int, len(m.queue)+len(m.transcribeQueue))
+	for i, q := range m.queue {
+		positions[q.task.ID] = i + 1
+	}
+	for i, q := range m.transcribeQueue {
+		positions[q.task.ID] = i + 1
+	}
+	return positions
+}
+
+// OutputDir 返回默认下载目录
+func (m *Manager) OutputDir() string {
+	return m.outputDir
+}
+
+// QueueStats 返回本进程正在执行、排队中的下载任务数以及并发上限，使用外部队列时排队数为队列中所有进程的任务
+func (m *Manager) QueueStats() (running, queued, limit int) {
+	m.mu.RLock()
+	running, queued, limit = m.running, len(m.queue), m.maxDownloads
+	m.mu.RUnlock()
+	return running, m.externalQueued(KindDownload, queued), limit
+}
+
+// TranscribeQueueStats 返回本进程正在执行、排队中的转录任务数以及并发上限，排队数同 QueueStats
+func (m *Manager) TranscribeQueueStats() (running, queued, limit int) {
+	m.mu.RLock()
+	running, queued, limit = m.transcribing, len(m.transcribeQueue), m.maxTranscribes
+	m.mu.RUnlock()
+	return running, m.externalQueued(KindTranscribe, queued), limit
+}
+
+// externalQueued 外部队列中等待执行（包括等待重新执行）的任务数，没有外部队列或读取失败时返回 local
+func (m *Manager) externalQueued(kind Kind, local int) int {
+	if m.jobs == nil {
+		return local
+	}
+	s, err := m.jobs.Stats(kind)
+	if err != nil {
+		return local
+	}
+	return s.Queued + s.Retrying
+}
+
+func (m *Manager) runDownload(ctx context.Context, task *DownloadTask, req downloader.Request) {
+	ctx = logging.WithTask(ctx, task.ID, "download")
+	logger := logging.FromContext(ctx)
+	m.updateDownload(task, func(t *DownloadTask) {
+		t.Status = StatusDownloading
+		t.StartTime = time.Now()
+	})
+	logger.Info("开始下载", "url", req.URL, "quality", req.Quality, "backend", req.Backend)
+	ctx, watch := newWatchdog(ctx, "下载", m.timeouts.DownloadMax, m.timeouts.DownloadStall)
+
+	// 未指定文件名时先获取视频标题，按模板生成文件名
+	var result *downloader.Result
+	err := downloader.Prepare(ctx, &req, shortID(task.ID))
+	if err == nil {
+		m.updateDownload(task, func(t *DownloadTask) {
+			t.Filename = req.Filename
+		})
+		logger.Debug("输出文件名已确定", "filename", req.Filename)
+		err = m.reserveSpace(ctx, task, req)
+	}
+	if err == nil {
+		var (
+			last       downloader.Progress
+			eta, speed etaEstimator
+			history    speedTracker
+		)
+		m.mu.RLock()
+		history.seed(task.AvgSpeed, task.PeakSpeed, task.BytesDownloaded)
+		m.mu.RUnlock()
+		result, err = m.downloadWithRetry(ctx, task, req, func(p downloader.Progress) {
+			if p.Percentage != last.Percentage || p.BytesDownloaded != last.BytesDownloaded {
+				last = p
+				watch.progress()
+			}
+			// 知道文件大小时按字节估算，否则按百分比
+			remaining := eta.update("percent", float64(p.Percentage), 100)
+			if p.TotalBytes > 0 {
+				remaining = eta.update("bytes", float64(p.BytesDownloaded), float64(p.TotalBytes))
+			}
+			// 知道已下载的字节数时显示最近的平均速度，刚开始没有足够的样本时使用下载器给出的速度
+			current := p.Speed
+			if p.BytesDownloaded > 0 {
+				if speed.update("bytes", float64(p.BytesDownloaded), 0); speed.rate > 0 {
+					current = hls.FormatSpeed(speed.rate)
+				}
+			}
+			sampled := p.BytesDownloaded > 0 && history.update(p.BytesDownloaded)
+			m.updateDownload(task, func(t *DownloadTask) {
+				t.Percentage = p.Percentage
+				t.Speed = current
+				t.BytesDownloaded = p.BytesDownloaded
+				t.TotalBytes = p.TotalBytes
+				t.ETASeconds = remaining
+				if sampled {
+					t.SpeedHistory = history.history()
+					t.PeakSpeed = history.peak
+				}
+			})
+		})
+		m.recordSpeed(task, &history)
+	}
+	watch.stopStall()
+	if err == nil {
+		err = m.transcodeVideo(ctx, task, req, result)
+	}
+	var (
+		thumbnail, sprite string
+		nfo               string
+		contentHash       string
+		linkedTo          string
+		linkMode          DedupMode
+		comments          *zhihu.SavedComments
+		remote            map[string]string
+	)
+	if err == nil {
+		m.writeMetadata(ctx, task, req, result)
+		// 在写入元数据之后去重：替换为链接之后再修改文件会同时改变另一份
+		contentHash, linkedTo, linkMode = m.dedupContent(ctx, task, result.FilePath)
+		nfo = m.writeNFO(ctx, result)
+		thumbnail, sprite = m.generatePreview(ctx, result.FilePath)
+		comments = m.saveComments(ctx, req, result.FilePath)
+		// 流水线的下载在转录完成后统一上传，转录还需要本地的视频
+		if files := pendingUploads(downloadUploads(result.FilePath)); len(files) > 0 && !m.inPipeline(task.ID) {
+			remote = m.uploadOutputs(ctx, task.ID, StatusDownloading, files)
+		}
+		if errors.Is(ctx.Err(), context.Canceled) {
+			err = ctx.Err()
+		}
+	}
+	err = m.stallCause(task.ID, watch.stop(ctx, err))
+
+	m.finish(task.ID)
+	m.mu.Lock()
+	m.running--
+	delete(m.reserved, task.ID)
+	m.dispatchLocked()
+	m.mu.Unlock()
+	if m.requeueDownload(ctx, task, err, errcode.DownloadFailed) {
+		m.saveOutput(task.ID, err)
+		m.deactivate(task.ID)
+		return
+	}
+
+	m.updateDownload(task, func(t *DownloadTask) {
+		t.ETASeconds = 0
+		switch {
+		case errors.Is(err, context.Canceled):
+			t.Status = StatusCancelled
+			t.Error, t.ErrorCode = "用户取消", errcode.Cancelled
+		case err != nil:
+			t.Status = StatusFailed
+			t.Error, t.ErrorCode, t.ErrorDetail = failure(err, errcode.DownloadFailed)
+		default:
+			t.Status = StatusCompleted
+			t.Percentage = 100
+			t.BytesDownloaded, t.TotalBytes = result.Size, result.Size
+			t.FilePath = result.FilePath
+			t.FileName = filepath.Base(result.FilePath)
+			t.Resolution = result.Resolution
+			t.Remux = result.Remux
+			t.ContentHash, t.LinkedTo, t.LinkMode = contentHash, linkedTo, linkMode
+			if result.Title != "" {
+				t.Title = result.Title
+			}
+			if result.Author != "" {
+				t.Author = result.Author
+			}
+			t.ThumbnailPath = thumbnail
+			t.SpritePath = sprite
+			t.NFOPath = nfo
+			t.RemoteURLs = mergeRemoteURLs(t.RemoteURLs, remote)
+			if comments != nil {
+				t.CommentsPath = comments.JSONPath
+				t.CommentsMarkdownPath = comments.MarkdownPath
+			}
+		}
+	})
+	snapshot, _ := m.Download(task.ID)
+	if err == nil && snapshot != nil {
+		m.recordDownloaded(snapshot)
+		m.recordContent(snapshot)
+	}
+	switch {
+	case snapshot != nil && snapshot.Status == StatusPaused:
+		logger.Info("下载已暂停")
+	case errors.Is(err, context.Canceled):
+		logger.Info("下载已取消")
+	case err != nil:
+		logger.Error("下载失败", "error", err)
+	default:
+		logger.Info("下载完成", "file_path", result.FilePath, "size_mb", fmt.Sprintf("%.1f", float64(result.Size)/1024/1024))
+	}
+	m.saveOutput(task.ID, err)
+	m.sendNotifications(task.ID)
+	m.deactivate(task.ID)
+}
+
+func (m *Manager) runTranscribe(ctx context.Context, task *TranscribeTask, req transcriber.Request) {
+	ctx = logging.WithTask(ctx, task.ID, "transcribe")
+	logger := logging.FromContext(ctx)
+	m.updateTranscribe(task, func(t *TranscribeTask) {
+		t.Status = StatusPending
+		t.Stage = "等待开始"
+		t.StartTime = time.Now()
+	})
+	logger.Info("开始转录", "video_path", req.VideoPath, "language", req.Language, "model", req.Model, "diarize", req.Diarize)
+	ctx, watch := newWatchdog(ctx, "转录", m.timeouts.TranscribeMax, 0)
+	req.OnSegment = m.startLive(task.ID)
+	defer m.stopLive(task.ID)
+
+	var eta etaEstimator
+	result, err := transcriber.Transcribe(ctx, req, func(p transcriber.Progress) {
+		remaining := 0
+		if p.Duration > 0 {
+			remaining = eta.update("audio", p.Processed, p.Duration)
+		}
+		m.updateTranscribe(task, func(t *TranscribeTask) {
+			t.Status = Status(p.Phase)
+			t.Stage = p.Stage
+			t.Percentage = p.Percentage
+			t.ETASeconds = remaining
+			if p.MP3Path != "" {
+				t.MP3Path = p.MP3Path
+			}
+			if p.TXTPath != "" {
+				t.TXTPath = p.TXTPath
+			}
+		})
+	})
+	err = m.stallCause(task.ID, watch.stop(ctx, err))
+	if err == nil && m.persister != nil {
+		if err := m.persister.SaveTranscript(task.ID, result.Transcript); err != nil {
+			logger.Warn("保存转录结果失败", "error", err)
+		} else {
+			m.embedTranscriptAsync(task.ID, result.Transcript)
+		}
+	}
+	if err == nil {
+		m.fillNFOPlot(ctx, req.VideoPath, result.Transcript)
+	}
+	var remote map[string]string
+	if err == nil {
+		files := pendingUploads(transcribeUploads(result.MP3Path, result.TXTPath, result.SRTPath, result.JSONPath, result.SummaryPath))
+		if len(files) > 0 && !m.inPipeline(task.ID) {
+			m.updateTranscribe(task, func(t *TranscribeTask) {
+				t.Stage = "上传中"
+			})
+			remote = m.uploadOutputs(ctx, task.ID, StatusTranscribing, files)
+			if errors.Is(ctx.Err(), context.Canceled) {
+				err = ctx.Err()
+			}
+		}
+	}
+
+	m.finish(task.ID)
+	m.mu.Lock()
+	m.transcribing--
+	m.dispatchTranscribesLocked()
+	m.mu.Unlock()
+	if m.requeueTranscribe(ctx, task, err) {
+		m.saveOutput(task.ID, err)
+		m.deactivate(task.ID)
+		return
+	}
+	m.updateTranscribe(task, func(t *TranscribeTask) {
+		t.ETASeconds = 0
+		switch {
+		case errors.Is(err, context.Canceled):
+			t.Status = StatusCancelled
+			t.Error, t.ErrorCode = "用户取消", errcode.Cancelled
+		case err != nil:
+			t.Status = StatusFailed
+			t.Error, t.ErrorCode, t.ErrorDetail = failure(err, errcode.TranscribeFailed)
+		default:
+			t.Status = StatusCompleted
+			t.Percentage = 100
+			t.Stage = "转录完成"
+			t.MP3Path = result.MP3Path
+			t.TXTPath = result.TXTPath
+			t.SRTPath = result.SRTPath
+			t.JSONPath = result.JSONPath
+			t.SummaryPath = result.SummaryPath
+			t.RemoteURLs = mergeRemoteURLs(t.RemoteURLs, remote)
+			if t.Language == transcriber.LanguageAuto && result.Transcript != nil {
+				t.DetectedLanguage = result.Transcript.Language
+				t.LanguageProbability = result.Transcript.LanguageProbability
+			}
+			if result.SummaryError != "" {
+				t.Stage = "转录完成（摘要生成失败: " + result.SummaryError + "）"
+			}
+		}
+	})
+	switch {
+	case errors.Is(err, context.Canceled):
+		logger.Info("转录已取消")
+	case err != nil:
+		logger.Error("转录失败", "error", err)
+	default:
+		logger.Info("转录完成", "txt_path", result.TXTPath)
+	}
+	m.saveOutput(task.ID, err)
+	m.sendNotifications(task.ID)
+	m.deactivate(task.ID)
+}
+
+// updateDownload 在锁内修改任务并持久化；已取消或暂停的任务不再更新
+func (m *Manager) updateDownload(task *DownloadTask, fn func(t *DownloadTask)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if task.Status == StatusCancelled || task.Status == StatusPaused {
+		return
+	}
+	fn(task)
+	m.touchDownload(task)
+	m.saveDownloadLocked(task)
+	m.notifyLocked(task.ID)
+}
+
+// recordSpeed 保存下载的平均速度和峰值。与 updateDownload 不同，暂停的任务也保存，继续时从这里接着统计
+func (m *Manager) recordSpeed(task *DownloadTask, history *speedTracker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.downloads[task.ID]; !ok || task.Status == StatusCancelled {
+		return
+	}
+	task.AvgSpeed, task.PeakSpeed = history.average(), history.peakSpeed()
+	m.saveDownloadLocked(task)
+}
+
+func (m *Manager) updateTranscribe(task *TranscribeTask, fn func(t *TranscribeTask)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if task.Status == StatusCancelled {
+		return
+	}
+	fn(task)
+	m.touchTranscribe(task)
+	m.saveTranscribeLocked(task)
+	m.notifyLocked(task.ID)
+}
+
+// Subscribe 订阅任务的状态变化，返回的通道在每次更新后收到通知（合并连续通知），
+// 使用完毕后调用 unsubscribe 释放
+func (m *Manager) Subscribe(id string) (updates <-chan struct{}, unsubscribe func()) {
+	ch := make(chan struct{}, 1)
+
+	m.mu.Lock()
+	m.watchers[id] = append(m.watchers[id], ch)
+	m.mu.Unlock()
+
+	return ch, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		list := m.watchers[id]
+		for i, w := range list {
+			if w == ch {
+				m.watchers[id] = append(list[:i], list[i+1:]...)
+				break
+			}
+		}
+		if len(m.watchers[id]) == 0 {
+			delete(m.watchers, id)
+		}
+	}
+}
+
+func (m *Manager) notifyLocked(id string) {
+	for _, ch := range m.watchers[id] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (m *Manager) touchDownload(t *DownloadTask) {
+	t.UpdatedAt = time.Now()
+	t.ElapsedTime = int(t.UpdatedAt.Sub(t.StartTime).Seconds())
+}
+
+func (m *Manager) touchTranscribe(t *TranscribeTask) {
+	t.UpdatedAt = time.Now()
+	t.ElapsedTime = int(t.UpdatedAt.Sub(t.StartTime).Seconds())
+}
+
+func (m *Manager) saveDownloadLocked(t *DownloadTask) error {
+	// 附加的请求头和 cookies 可能包含令牌，下载完成后不再保留。
+	// 失败、取消和中断的任务还可能重试或被重新排队，保留到任务删除时一起删除
+	if t.Status == StatusCompleted {
+		t.Headers, t.Cookies = nil, nil
+	}
+	_, known := m.downloads[t.ID]
+	m.recordLocked(t.ID, !known, t.Status, t.Error, t.Retries, t.Percentage)
+	if m.persister == nil {
+		return nil
+	}
+	return m.persister.SaveDownload(t)
+}
+
+func (m *Manager) saveTranscribeLocked(t *TranscribeTask) error {
+	_, known := m.transcribes[t.ID]
+	m.recordLocked(t.ID, !known, t.Status, t.Error, 0, t.Percentage)
+	if m.persister == nil {
+		return nil
+	}
+	return m.persister.SaveTranscribe(t)
+}
+
+// finish 任务执行结束后释放取消函数
+func (m *Manager) finish(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cancel, ok := m.cancels[id]; ok {
+		cancel()
+		delete(m.cancels, id)
+	}
+}
+
+// deactivate 后台 goroutine 退出，之后任务可以重试
+func (m *Manager) deactivate(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.active, id)
+	if m.cleanup[id] {
+		delete(m.cleanup, id)
+		m.removeUnfinishedLocked(id)
+	}
+}
+
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
@@ -0,0 +1,98 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/media"
+)
+
+// DefaultMetadataTags 默认写入的元数据，Plex、Jellyfin 等媒体库按这些字段显示标题和作者
+var DefaultMetadataTags = map[string]string{
+	"title":   "{title}",
+	"artist":  "{author}",
+	"date":    "{date}",
+	"comment": "{url}",
+}
+
+// MetadataOptions 下载完成后写入视频文件的元数据
+type MetadataOptions struct {
+	Enabled bool
+	// Tags 元数据名称到模板的映射，为空时使用 DefaultMetadataTags
+	Tags map[string]string
+	// NFO 在视频旁边生成媒体库使用的 <name>.nfo 文件，与 Enabled 无关
+	NFO bool
+}
+
+// WithMetadata 设置下载完成后写入的元数据和 .nfo 文件（默认都不生成）
+func WithMetadata(o MetadataOptions) Option {
+	return func(m *Manager) {
+		if len(o.Tags) == 0 {
+			o.Tags = DefaultMetadataTags
+		}
+		m.metadata = o
+	}
+}
+
+// 元数据模板变量
+var metadataVars = map[string]bool{
+	"title": true, "author": true, "url": true, "date": true, "quality": true, "resolution": true, "id": true,
+}
+
+var metadataVarRe = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// ValidateMetadataTags 检查元数据模板中的变量，可用 {title} {author} {url} {date} {quality} {resolution} {id}
+func ValidateMetadataTags(tags map[string]string) error {
+	for name, tmpl := range tags {
+		if strings.TrimSpace(name) == "" || strings.ContainsAny(name, "= ") {
+			return fmt.Errorf("元数据名称不合法: %q", name)
+		}
+		for _, v := range metadataVarRe.FindAllStringSubmatch(tmpl, -1) {
+			if !metadataVars[v[1]] {
+				return fmt.Errorf("元数据 %s 的模板中有未知变量 {%s}（可用 {title} {author} {url} {date} {quality} {resolution} {id}）", name, v[1])
+			}
+		}
+	}
+	return nil
+}
+
+// writeMetadata 把标题、作者、来源链接等写入下载完成的视频。
+// 不在 Go 中解析知乎页面时没有标题，使用文件名；写入失败不影响下载结果，只记录日志
+func (m *Manager) writeMetadata(ctx context.Context, task *DownloadTask, req downloader.Request, result *downloader.Result) {
+	if !m.metadata.Enabled {
+		return
+	}
+	ctx = logging.WithStage(ctx, "metadata")
+	logger := logging.FromContext(ctx)
+
+	title := result.Title
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(result.FilePath), filepath.Ext(result.FilePath))
+	}
+	values := map[string]string{
+		"title":      title,
+		"author":     result.Author,
+		"url":        req.URL,
+		"date":       time.Now().Format("2006-01-02"),
+		"quality":    result.Quality,
+		"resolution": result.Resolution,
+		"id":         task.ID,
+	}
+	tags := make(map[string]string, len(m.metadata.Tags))
+	for name, tmpl := range m.metadata.Tags {
+		tags[name] = strings.TrimSpace(metadataVarRe.ReplaceAllStringFunc(tmpl, func(s string) string {
+			return values[s[1:len(s)-1]]
+		}))
+	}
+	if err := media.WriteMetadata(ctx, result.FilePath, tags); err != nil {
+		logger.Warn("写入元数据失败", "error", err)
+		return
+	}
+	logger.Debug("元数据已写入", "tags", tags)
+}
@@ -0,0 +1,126 @@
+package tasks
+
+import (
+	"context"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"zhihu-downloader/internal/atomicfile"
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/transcriber"
+)
+
+// nfoPlotChars 没有视频简介时，从转录文本开头截取作为简介的最多字符数
+const nfoPlotChars = 300
+
+// nfoMovie Jellyfin / Plex / Kodi 识别的电影 .nfo 文件
+type nfoMovie struct {
+	XMLName   xml.Name `xml:"movie"`
+	Title     string   `xml:"title"`
+	Plot      string   `xml:"plot,omitempty"`
+	Studio    string   `xml:"studio,omitempty"`
+	Premiered string   `xml:"premiered,omitempty"`
+	Aired     string   `xml:"aired,omitempty"`
+	Year      int      `xml:"year,omitempty"`
+	DateAdded string   `xml:"dateadded"`
+}
+
+// nfoPath 返回视频的 .nfo 路径：video.mp4 → video.nfo
+func nfoPath(video string) string {
+	return strings.TrimSuffix(video, filepath.Ext(video)) + ".nfo"
+}
+
+// writeNFO 在视频旁边生成 .nfo 文件：标题、简介、作者（studio）和发布日期，返回文件路径。
+// 没有视频简介时留空，转录完成后用转录文本的开头补上；生成失败只记录日志
+func (m *Manager) writeNFO(ctx context.Context, result *downloader.Result) string {
+	if !m.metadata.NFO {
+		return ""
+	}
+	logger := logging.FromContext(logging.WithStage(ctx, "metadata"))
+
+	now := time.Now()
+	nfo := nfoMovie{
+		Title:     result.Title,
+		Plot:      result.Description,
+		Studio:    result.Author,
+		DateAdded: now.Format(time.DateTime),
+	}
+	if nfo.Title == "" {
+		nfo.Title = strings.TrimSuffix(filepath.Base(result.FilePath), filepath.Ext(result.FilePath))
+	}
+	if result.Published != nil {
+		nfo.Premiered = result.Published.Format(time.DateOnly)
+		nfo.Aired = nfo.Premiered
+		nfo.Year = result.Published.Year()
+	}
+	path := nfoPath(result.FilePath)
+	if err := saveNFO(path, nfo); err != nil {
+		logger.Warn("生成 .nfo 文件失败", "error", err)
+		return ""
+	}
+	logger.Debug(".nfo 文件已生成", "path", path)
+	return path
+}
+
+// fillNFOPlot 转录完成后，视频的 .nfo 没有简介时用转录文本的开头作为简介
+func (m *Manager) fillNFOPlot(ctx context.Context, video string, transcript *transcriber.Transcript) {
+	if !m.metadata.NFO || transcript == nil {
+		return
+	}
+	path := nfoPath(video)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	logger := logging.FromContext(ctx)
+	var nfo nfoMovie
+	if err := xml.Unmarshal(data, &nfo); err != nil {
+		logger.Warn("读取 .nfo 文件失败", "path", path, "error", err)
+		return
+	}
+	if nfo.Plot != "" {
+		return
+	}
+	if nfo.Plot = transcriptExcerpt(transcript, nfoPlotChars); nfo.Plot == "" {
+		return
+	}
+	if err := saveNFO(path, nfo); err != nil {
+		logger.Warn("更新 .nfo 文件失败", "path", path, "error", err)
+	}
+}
+
+// transcriptExcerpt 按段拼接转录文本，超过 limit 个字符时在段落边界截断（第一段过长时截断到 limit 并加省略号）
+func transcriptExcerpt(t *transcriber.Transcript, limit int) string {
+	var b strings.Builder
+	for _, seg := range t.Segments {
+		text := strings.TrimSpace(seg.Text)
+		if text == "" {
+			continue
+		}
+		if n := utf8.RuneCountInString(b.String()) + utf8.RuneCountInString(text); n > limit {
+			if b.Len() == 0 {
+				b.WriteString(string([]rune(text)[:limit]) + "…")
+			}
+			break
+		}
+		if b.Len() > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+func saveNFO(path string, nfo nfoMovie) error {
+	data, err := xml.MarshalIndent(nfo, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`+"\n"), data...)
+	return atomicfile.WriteFile(path, append(data, '\n'), 0o644)
+}
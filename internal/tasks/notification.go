@@ -0,0 +1,141 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/notify"
+)
+
+// notifyTimeout 发送一个任务的所有通知的最长时间
+const notifyTimeout = time.Minute
+
+// sendNotifications 任务结束后在后台发送通知：请求中指定了 notify 时发给指定的渠道，
+// 否则发给配置的所有渠道。流水线和合集的子任务不单独通知，发送失败只记录到任务日志
+func (m *Manager) sendNotifications(id string) {
+	if m.inPipeline(id) || m.inCollection(id) {
+		return
+	}
+	msg, targets, ok := m.notification(id)
+	if !ok {
+		return
+	}
+	logger := logging.FromContext(logging.WithTask(context.Background(), id, "notify"))
+	channels, err := notify.Resolve(targets)
+	if err != nil {
+		logger.Warn("发送通知失败", "error", err)
+		return
+	}
+	var list []notify.Channel
+	for _, ch := range channels {
+		if ch.Wants(msg.Status) {
+			list = append(list, ch)
+		}
+	}
+	if len(list) == 0 {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		for _, ch := range list {
+			if err := notify.Send(ctx, ch, msg); err != nil {
+				logger.Warn("发送通知失败", "channel", ch.Name, "error", err)
+				continue
+			}
+			logger.Debug("已发送通知", "channel", ch.Name)
+		}
+	}()
+}
+
+// notification 根据任务的最终状态生成通知，返回请求中指定的通知目标
+func (m *Manager) notification(id string) (notify.Message, []string, bool) {
+	msg := notify.Message{TaskID: id, Link: notify.FileLink(id)}
+	var targets []string
+	if t, err := m.Download(id); err == nil {
+		msg.Kind, msg.Status, msg.Duration, msg.Error = string(KindDownload), string(t.Status), t.ElapsedTime, t.Error
+		msg.Title = "下载" + statusText(t.Status)
+		msg.Name = firstNonEmpty(t.FileName, t.VideoURL)
+		msg.Size = fileSize(t.FilePath)
+		msg.Link = firstNonEmpty(t.RemoteURLs["video"], msg.Link)
+		targets = t.Notify
+	} else if t, err := m.Transcribe(id); err == nil {
+		msg.Kind, msg.Status, msg.Duration, msg.Error = string(KindTranscribe), string(t.Status), t.ElapsedTime, t.Error
+		msg.Title = "转录" + statusText(t.Status)
+		msg.Name = filepath.Base(t.VideoPath)
+		msg.Link = firstNonEmpty(t.RemoteURLs["txt"], msg.Link)
+		targets = t.Notify
+	} else if t, err := m.Pipeline(id); err == nil {
+		msg.Kind, msg.Status, msg.Duration, msg.Error = string(KindPipeline), string(t.Status), t.ElapsedTime, t.Error
+		msg.Title = "下载并转录" + statusText(t.Status)
+		msg.Name = t.VideoURL
+		if t.FilePath != "" {
+			msg.Name = filepath.Base(t.FilePath)
+		}
+		msg.Size = fileSize(t.FilePath)
+		msg.Link = firstNonEmpty(t.RemoteURLs["video"], msg.Link)
+		targets = t.Notify
+	} else if t, err := m.Collection(id); err == nil {
+		msg.Kind, msg.Status, msg.Duration, msg.Error = string(KindCollection), string(t.Status), t.ElapsedTime, t.Error
+		msg.Title = "合集下载" + statusText(t.Status)
+		msg.Name = firstNonEmpty(t.Title, t.URL)
+		msg.Detail = fmt.Sprintf("完成 %d / %d 个视频，失败 %d 个", t.Completed, t.Total, t.Failed)
+		// 合集没有单个文件可以下载
+		msg.Link = ""
+		targets = t.Notify
+	} else {
+		return msg, nil, false
+	}
+	return msg, targets, true
+}
+
+// inCollection 下载任务是否由合集任务创建
+func (m *Manager) inCollection(id string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, c := range m.collections {
+		if slices.Contains(c.DownloadIDs, id) {
+			return true
+		}
+	}
+	return false
+}
+
+func statusText(s Status) string {
+	switch s {
+	case StatusCompleted:
+		return "完成"
+	case StatusFailed:
+		return "失败"
+	case StatusCancelled:
+		return "已取消"
+	}
+	return "结束"
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// fileSize 文件大小，文件不存在（例如上传后已删除）时为 0
+func fileSize(path string) int64 {
+	if path == "" {
+		return 0
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
@@ -0,0 +1,98 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"zhihu-downloader/internal/logging"
+)
+
+// ErrNoOutput 任务没有保存的外部程序输出（没有失败，或失败前没有执行外部程序）
+var ErrNoOutput = errors.New("任务没有保存的外部程序输出")
+
+// TaskOutput 失败任务执行期间 ffmpeg / yt-dlp / Python / Whisper 等外部程序的完整输出，
+// 每行前面是程序名。任务成功或取消时不保存
+type TaskOutput struct {
+	TaskID string
+	Output []byte
+	// Truncated 超出 logging.MaxTaskOutput，丢弃了最早的内容
+	Truncated bool
+	CreatedAt time.Time
+}
+
+// saveOutput 任务结束后取走外部程序的输出，失败时保存，供之后排查
+func (m *Manager) saveOutput(id string, err error) {
+	data, truncated := logging.TakeOutput(id)
+	if err == nil || errors.Is(err, context.Canceled) || len(data) == 0 {
+		return
+	}
+	o := &TaskOutput{TaskID: id, Output: data, Truncated: truncated, CreatedAt: time.Now()}
+	if m.persister == nil {
+		m.mu.Lock()
+		m.outputs[id] = o
+		m.mu.Unlock()
+		return
+	}
+	if err := m.persister.SaveOutput(o); err != nil {
+		slog.Warn("保存外部程序输出失败", "task_id", id, "error", err)
+	}
+}
+
+// Output 返回失败任务保存的外部程序输出。流水线和合集任务合并子任务的输出，
+// 每个子任务前加一行标题；都没有输出时返回 ErrNoOutput
+func (m *Manager) Output(id string) (*TaskOutput, error) {
+	if _, ok := m.Event(id); !ok {
+		return nil, ErrNotFound
+	}
+	var children []string
+	if p, err := m.Pipeline(id); err == nil {
+		children = []string{p.DownloadID, p.TranscribeID}
+	} else if c, err := m.Collection(id); err == nil {
+		children = c.DownloadIDs
+	} else {
+		o, err := m.taskOutput(id)
+		if err == nil && o == nil {
+			err = ErrNoOutput
+		}
+		return o, err
+	}
+
+	merged := &TaskOutput{TaskID: id}
+	var buf bytes.Buffer
+	for _, child := range children {
+		if child == "" {
+			continue
+		}
+		o, err := m.taskOutput(child)
+		if err != nil {
+			return nil, err
+		}
+		if o == nil {
+			continue
+		}
+		fmt.Fprintf(&buf, "==== %s ====\n", child)
+		buf.Write(o.Output)
+		merged.Truncated = merged.Truncated || o.Truncated
+		if o.CreatedAt.After(merged.CreatedAt) {
+			merged.CreatedAt = o.CreatedAt
+		}
+	}
+	if buf.Len() == 0 {
+		return nil, ErrNoOutput
+	}
+	merged.Output = buf.Bytes()
+	return merged, nil
+}
+
+func (m *Manager) taskOutput(id string) (*TaskOutput, error) {
+	if m.persister != nil {
+		return m.persister.Output(id)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.outputs[id], nil
+}
@@ -0,0 +1,145 @@
+package tasks
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"zhihu-downloader/internal/atomicfile"
+)
+
+// partialPatterns 输出目录中未写完的文件：.part（本程序和 yt-dlp 写入中的文件）、
+// ffmpeg 输出中的 .part.<扩展名>、HLS 分片目录和合并中的 .tmp 文件
+var partialPatterns = []string{"*" + atomicfile.Suffix, "*" + atomicfile.Suffix + ".*", "*.mp4.parts", "*.mp4.tmp", "*.ts.tmp"}
+
+// IsPartialName 文件名是否是未写完的文件（见 partialPatterns）
+func IsPartialName(name string) bool {
+	for _, pattern := range partialPatterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// partialGrace 最近修改过的文件可能仍在写入（例如正在生成的摘要），不当作未完成文件
+const partialGrace = 10 * time.Minute
+
+// PartialFile 写入中断留下的未完成文件
+type PartialFile struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	// TaskID 写入这个文件的任务，任务已删除或无法判断时为空
+	TaskID string `json:"task_id,omitempty"`
+}
+
+// PartialFiles 扫描所有输出目录，返回不会再被续传的未完成文件：进程崩溃、取消或失败留下的 .part 文件，
+// 以及已删除、已完成或已取消的下载留下的分片。正在执行、暂停和可以重试的任务的文件不会列出，
+// 这样的任务还不知道文件名时它的目录中的未完成文件都不列出
+func (m *Manager) PartialFiles() []PartialFile {
+	m.refreshDownloads()
+	m.refreshTranscribes()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.partialFilesLocked(time.Now().Add(-partialGrace))
+}
+
+// RemovePartialFiles 删除 PartialFiles 列出的文件，返回删除的文件和释放的字节数
+func (m *Manager) RemovePartialFiles() (removed []PartialFile, freed int64) {
+	m.refreshDownloads()
+	m.refreshTranscribes()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, f := range m.partialFilesLocked(time.Now().Add(-partialGrace)) {
+		if err := os.RemoveAll(f.Path); err != nil {
+			continue
+		}
+		removed = append(removed, f)
+		freed += f.Size
+	}
+	return removed, freed
+}
+
+// partialFilesLocked 返回修改时间早于 cutoff、且不属于仍可继续的任务的未完成文件
+func (m *Manager) partialFilesLocked(cutoff time.Time) []PartialFile {
+	dirs := map[string]bool{m.outputDir: true}
+	for _, ws := range m.Workspaces() {
+		dirs[ws.OutputDir] = true
+	}
+	inUse := map[string]bool{}
+	owners := map[string]string{}
+	// keepDirs 有仍可继续、但还不知道文件名的下载的目录（例如按模板命名、暂停时还没有确定文件名），
+	// 无法判断其中的未完成文件属于哪个任务，全部保留
+	keepDirs := map[string]bool{}
+	for _, t := range m.downloads {
+		if t.OutputDir != "" {
+			dirs[t.OutputDir] = true
+		}
+		// 失败 / 中断的任务还可以重试，保留它们的分片
+		keep := !t.Status.Terminal() || t.Status.Retryable() || m.active[t.ID]
+		if keep && t.Filename == "" {
+			dir := t.OutputDir
+			if dir == "" {
+				dir = m.outputDir
+			}
+			keepDirs[filepath.Clean(dir)] = true
+			continue
+		}
+		for _, path := range partialFiles(t) {
+			owners[path] = t.ID
+			inUse[path] = inUse[path] || keep
+		}
+	}
+	for _, t := range m.transcribes {
+		if t.OutputDir != "" {
+			dirs[t.OutputDir] = true
+		}
+		if t.TXTPath == "" {
+			continue
+		}
+		path := atomicfile.Path(t.TXTPath)
+		owners[path] = t.ID
+		inUse[path] = inUse[path] || m.active[t.ID]
+	}
+
+	var list []PartialFile
+	seen := map[string]bool{}
+	for dir := range dirs {
+		for _, pattern := range partialPatterns {
+			matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+			for _, path := range matches {
+				if seen[path] || inUse[path] || keepDirs[filepath.Dir(path)] {
+					continue
+				}
+				seen[path] = true
+				info, err := os.Stat(path)
+				if err != nil || info.ModTime().After(cutoff) {
+					continue
+				}
+				list = append(list, PartialFile{Path: path, Size: pathSize(path, info), Modified: info.ModTime(), TaskID: owners[path]})
+			}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list
+}
+
+// pathSize 文件的大小，目录（HLS 分片目录）为其中所有文件的大小之和
+func pathSize(path string, info os.FileInfo) int64 {
+	if !info.IsDir() {
+		return info.Size()
+	}
+	var total int64
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
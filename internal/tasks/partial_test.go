@@ -0,0 +1,55 @@
+package tasks
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPartialFilesKeepsResumableDownloads(t *testing.T) {
+	dir := t.TempDir()
+	other := t.TempDir()
+	m := NewManager(WithOutputDir(dir))
+
+	old := time.Now().Add(-time.Hour)
+	touch := func(path string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, old, old)
+	}
+	// 按模板命名、暂停时还没有确定文件名的下载：目录中的未完成文件都不能删除
+	touch(filepath.Join(dir, "unknown.mp4.part"))
+	m.downloads["dl-1"] = &DownloadTask{ID: "dl-1", Status: StatusPaused, OutputDir: dir}
+	// 已完成的下载留下的分片可以删除
+	touch(filepath.Join(other, "done.mp4.part"))
+	touch(filepath.Join(other, "paused.mp4.part"))
+	m.downloads["dl-2"] = &DownloadTask{ID: "dl-2", Status: StatusCompleted, OutputDir: other, Filename: "done"}
+	m.downloads["dl-3"] = &DownloadTask{ID: "dl-3", Status: StatusFailed, OutputDir: other, Filename: "paused"}
+
+	got := map[string]string{}
+	for _, f := range m.PartialFiles() {
+		got[filepath.Base(f.Path)] = f.TaskID
+	}
+	if _, ok := got["unknown.mp4.part"]; ok {
+		t.Error("还不知道文件名的暂停任务的未完成文件被列出")
+	}
+	if _, ok := got["paused.mp4.part"]; ok {
+		t.Error("可以重试的任务的未完成文件被列出")
+	}
+	if id, ok := got["done.mp4.part"]; !ok || id != "dl-2" {
+		t.Errorf("已完成任务的未完成文件没有列出: %v", got)
+	}
+
+	// 任务完成后不会再继续，目录中的文件不再保留
+	m.downloads["dl-1"].Status = StatusCompleted
+	found := false
+	for _, f := range m.PartialFiles() {
+		found = found || filepath.Base(f.Path) == "unknown.mp4.part"
+	}
+	if !found {
+		t.Error("任务结束后未完成文件仍被保留")
+	}
+}
@@ -0,0 +1,28 @@
+package tasks
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultOutputDir 默认下载目录 ~/Downloads（Windows 上为 %USERPROFILE%\Downloads）
+func DefaultOutputDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "Downloads"
+	}
+	return filepath.Join(home, "Downloads")
+}
+
+// ExpandHome 展开路径开头的 ~、~/ 和 ~\，其他以 ~ 开头的路径（例如 ~user）原样返回
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, `~\`) {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
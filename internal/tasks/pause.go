@@ -0,0 +1,100 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/media"
+)
+
+// Pause 暂停排队中或正在执行的下载任务：停止下载，保留已下载的分片等未完成的文件，任务状态为 paused。
+// 之后用 Resume 继续：m3u8 复用已完成的分片，yt-dlp 续传未完成的文件，其他方式的下载从头开始。
+// 暂停的任务在服务重启后仍然可以继续
+func (m *Manager) Pause(id string) error {
+	m.refreshDownload(id)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.downloads[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if t.Status != StatusQueued && t.Status != StatusDownloading {
+		return fmt.Errorf("任务状态为 %s，无法暂停", t.Status)
+	}
+	if cancel, ok := m.cancels[id]; ok {
+		cancel()
+		delete(m.cancels, id)
+		m.dequeueLocked(id)
+	} else if !m.unqueueJobLocked(id) {
+		return ErrRunningElsewhere
+	}
+
+	t.Status = StatusPaused
+	t.Speed = ""
+	t.ETASeconds = 0
+	m.touchDownload(t)
+	m.saveDownloadLocked(t)
+	m.notifyLocked(id)
+	return nil
+}
+
+// Resume 继续暂停的下载任务，任务重新排队
+func (m *Manager) Resume(id string) error {
+	m.refreshDownload(id)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.downloads[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if t.Status != StatusPaused {
+		return fmt.Errorf("任务状态为 %s，无法继续", t.Status)
+	}
+	if m.active[id] {
+		return fmt.Errorf("任务正在暂停，请稍后再试")
+	}
+	t.Error, t.ErrorCode, t.ErrorDetail = "", "", ""
+	t.Retries = 0
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancels[id] = cancel
+	m.enqueueLocked(ctx, t, downloadRequest(t))
+	m.notifyLocked(id)
+	return nil
+}
+
+// pausedLocked 任务是否为暂停的下载
+func (m *Manager) pausedLocked(id string) bool {
+	t, ok := m.downloads[id]
+	return ok && t.Status == StatusPaused
+}
+
+// downloadRequest 按任务保存的参数重新生成下载请求，用于重试和继续
+func downloadRequest(t *DownloadTask) downloader.Request {
+	return downloader.Request{
+		URL:       t.VideoURL,
+		Quality:   t.Quality,
+		OutputDir: t.OutputDir,
+		Filename:  t.Filename,
+		Backend:   t.Backend,
+		MaxRate:   t.MaxRate,
+		Comments:  t.Comments,
+		Workspace: t.Workspace,
+
+		Connections:      t.Connections,
+		CommentsLimit:    t.CommentsLimit,
+		FilenameTemplate: t.FilenameTemplate,
+		FFmpegArgs:       t.FFmpegArgs,
+		HWAccel:          t.HWAccel,
+		Headers:          t.Headers,
+		Cookies:          t.Cookies,
+		Transcode: media.TranscodeOptions{
+			Codec:     t.TranscodeCodec,
+			MaxHeight: t.TranscodeMaxHeight,
+			CRF:       t.TranscodeCRF,
+		},
+	}
+}
@@ -0,0 +1,408 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/media"
+	"zhihu-downloader/internal/summarizer"
+	"zhihu-downloader/internal/transcriber"
+)
+
+// StartPipeline 创建“下载后自动转录”的流水线任务：下载作为普通下载任务排队，
+// 完成后用下载的视频创建转录任务，转录文件保存在视频旁边。
+// tr 中只使用 Language、Diarize、Summarize、Model、AudioFormat、AudioQuality、KeepIntermediate、
+// InitialPrompt 和 Vocabulary；
+// subtitleMode 为 mux / burn 时转录后把字幕封装或烧录进视频（见 media.SubtitleMux）
+func (m *Manager) StartPipeline(req downloader.Request, tr transcriber.Request, subtitleMode string) (*PipelineTask, error) {
+	if tr.Language == "" {
+		tr.Language = transcriber.LanguageAuto
+	}
+	if err := media.CheckSubtitleMode(subtitleMode); err != nil {
+		return nil, err
+	}
+	if subtitleMode == "" {
+		subtitleMode = media.SubtitleNone
+	}
+	model, err := transcriber.ResolveModel(tr.Model)
+	if err != nil {
+		return nil, err
+	}
+	audioFormat, audioQuality, err := transcriber.ResolveAudio(tr.AudioFormat, tr.AudioQuality)
+	if err != nil {
+		return nil, err
+	}
+	prompt, vocabulary, err := m.transcribePrompt(req.Workspace, tr.InitialPrompt, tr.Vocabulary)
+	if err != nil {
+		return nil, err
+	}
+	// 先创建下载子任务，参数错误时直接返回
+	download, err := m.StartDownload(req)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	task := &PipelineTask{
+		ID:         m.newID(KindPipeline),
+		Status:     download.Status,
+		Stage:      "等待下载",
+		DownloadID: download.ID,
+		VideoURL:   download.VideoURL,
+		Language:   tr.Language,
+		Diarize:    tr.Diarize,
+		Summarize:  tr.Summarize || summarizer.Auto(),
+		Model:      model,
+		OutputDir:  download.OutputDir,
+		Workspace:  download.Workspace,
+		Notify:     req.Notify,
+		Priority:   download.Priority,
+
+		SubtitleMode:  subtitleMode,
+		AudioFormat:   audioFormat,
+		AudioQuality:  audioQuality,
+		InitialPrompt: prompt,
+		Vocabulary:    vocabulary,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		StartTime:     now,
+
+		KeepIntermediate: transcriber.KeepIntermediate(tr.KeepIntermediate),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.mu.Lock()
+	if err := m.savePipelineLocked(task); err != nil {
+		m.mu.Unlock()
+		cancel()
+		m.Cancel(download.ID)
+		return nil, fmt.Errorf("保存任务失败: %v", err)
+	}
+	m.pipelines[task.ID] = task
+	m.cancels[task.ID] = cancel
+	m.active[task.ID] = true
+	m.mu.Unlock()
+
+	go m.runPipeline(ctx, task)
+	return m.Pipeline(task.ID)
+}
+
+// Pipeline 返回流水线任务的快照
+func (m *Manager) Pipeline(id string) (*PipelineTask, error) {
+	m.refreshPipeline(id)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	task, ok := m.pipelines[id]
+	if !ok {
+		return nil, fmt.Errorf("流水线任务不存在")
+	}
+	snapshot := *task
+	return &snapshot, nil
+}
+
+// Pipelines 返回所有流水线任务（按创建时间倒序）
+func (m *Manager) Pipelines() []*PipelineTask {
+	m.refreshPipelines()
+	m.mu.RLock()
+	list := make([]*PipelineTask, 0, len(m.pipelines))
+	for _, t := range m.pipelines {
+		snapshot := *t
+		list = append(list, &snapshot)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list
+}
+
+// retryPipelineLocked 从失败的阶段继续：已完成的下载不会重新执行
+func (m *Manager) retryPipelineLocked(t *PipelineTask) error {
+	if !t.Status.Retryable() {
+		return fmt.Errorf("任务状态为 %s，无法重试", t.Status)
+	}
+	now := time.Now()
+	t.Status = StatusPending
+	t.Stage = "等待开始"
+	t.Speed = ""
+	t.BytesDownloaded, t.TotalBytes = 0, 0
+	t.Error, t.ErrorCode, t.ErrorDetail = "", "", ""
+	t.StartTime = now
+	t.UpdatedAt = now
+	m.savePipelineLocked(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancels[t.ID] = cancel
+	m.active[t.ID] = true
+	go m.runPipeline(ctx, t)
+	return nil
+}
+
+func (m *Manager) runPipeline(ctx context.Context, task *PipelineTask) {
+	ctx = logging.WithTask(ctx, task.ID, "pipeline")
+	logger := logging.FromContext(ctx)
+	m.updatePipeline(task, func(t *PipelineTask) {
+		t.StartTime = time.Now()
+	})
+	logger.Info("开始流水线", "url", task.VideoURL, "download_id", task.DownloadID)
+
+	err := m.pipelineDownload(ctx, task)
+	if err == nil {
+		err = m.pipelineTranscribe(ctx, task)
+	}
+	if err == nil {
+		err = m.pipelineSubtitles(ctx, task)
+	}
+	if err == nil {
+		err = m.pipelineUpload(ctx, task)
+	}
+
+	m.finish(task.ID)
+	m.updatePipeline(task, func(t *PipelineTask) {
+		switch {
+		case errors.Is(err, context.Canceled):
+			t.Status = StatusCancelled
+			t.Error, t.ErrorCode = "用户取消", errcode.Cancelled
+		case err != nil:
+			t.Status = StatusFailed
+			t.Error, t.ErrorCode, t.ErrorDetail = failure(err, errcode.Internal)
+		default:
+			t.Status = StatusCompleted
+			t.Percentage = 100
+			t.Stage = "转录完成"
+			switch {
+			case t.Summarize && t.SummaryPath == "":
+				t.Stage = "转录完成（摘要生成失败，详见任务日志）"
+			case t.SubtitleMode == media.SubtitleMux:
+				t.Stage = "转录完成，已封装字幕"
+			case t.SubtitleMode == media.SubtitleBurn:
+				t.Stage = "转录完成，已烧录字幕"
+			}
+		}
+		t.Speed = ""
+		t.ETASeconds = 0
+	})
+	switch {
+	case errors.Is(err, context.Canceled):
+		logger.Info("流水线已取消")
+	case err != nil:
+		logger.Error("流水线失败", "error", err)
+	default:
+		logger.Info("流水线完成", "txt_path", task.TXTPath)
+	}
+	m.sendNotifications(task.ID)
+	m.deactivate(task.ID)
+}
+
+// pipelineDownload 下载阶段，对应总进度 0–50%
+func (m *Manager) pipelineDownload(ctx context.Context, task *PipelineTask) error {
+	d, err := m.Download(task.DownloadID)
+	if err != nil {
+		return fmt.Errorf("下载子任务 %s 已被删除", task.DownloadID)
+	}
+	if d.Status != StatusCompleted {
+		if d.Status.Retryable() {
+			if err := m.Retry(d.ID); err != nil {
+				return err
+			}
+		}
+		m.waitStage(ctx, d.ID, func() Status {
+			d, err := m.Download(task.DownloadID)
+			if err != nil {
+				return StatusFailed
+			}
+			if !d.Status.Terminal() {
+				m.updatePipeline(task, func(t *PipelineTask) {
+					t.Status = d.Status
+					t.Percentage = d.Percentage / 2
+					if d.Status == StatusTranscoding {
+						// 转码的进度只显示在阶段说明中，流水线进度停在下载阶段末尾
+						t.Percentage = 49
+					}
+					t.Speed = d.Speed
+					t.BytesDownloaded, t.TotalBytes = d.BytesDownloaded, d.TotalBytes
+					t.SpeedHistory = d.SpeedHistory
+					t.PeakSpeed = d.PeakSpeed
+					t.ETASeconds = d.ETASeconds
+					t.Stage = downloadStage(d)
+				})
+			}
+			return d.Status
+		})
+		if d, err = m.Download(task.DownloadID); err != nil {
+			return fmt.Errorf("下载子任务 %s 已被删除", task.DownloadID)
+		}
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case d.Status != StatusCompleted:
+		return &errcode.Error{Code: d.ErrorCode, Message: "下载失败: " + d.Error, Detail: d.ErrorDetail}
+	}
+	m.updatePipeline(task, func(t *PipelineTask) {
+		t.Percentage = 50
+		t.Speed = ""
+		t.BytesDownloaded, t.TotalBytes = d.BytesDownloaded, d.TotalBytes
+		t.AvgSpeed, t.PeakSpeed = d.AvgSpeed, d.PeakSpeed
+		t.ETASeconds = 0
+		t.FilePath = d.FilePath
+	})
+	return nil
+}
+
+// pipelineTranscribe 转录阶段：提取音频对应 50–60%，转录对应 60–100%
+func (m *Manager) pipelineTranscribe(ctx context.Context, task *PipelineTask) error {
+	m.mu.RLock()
+	child, ok := m.transcribes[task.TranscribeID]
+	m.mu.RUnlock()
+
+	switch {
+	case ok && child.Status == StatusCompleted:
+	case ok && child.Status.Retryable():
+		if err := m.Retry(child.ID); err != nil {
+			return err
+		}
+	default:
+		keep := task.KeepIntermediate
+		m.mu.RLock()
+		priority := task.Priority
+		m.mu.RUnlock()
+		tr, err := m.StartTranscribe(transcriber.Request{
+			VideoPath:     task.FilePath,
+			Language:      task.Language,
+			Diarize:       task.Diarize,
+			Summarize:     task.Summarize,
+			Model:         task.Model,
+			AudioFormat:   task.AudioFormat,
+			AudioQuality:  task.AudioQuality,
+			InitialPrompt: task.InitialPrompt,
+			Vocabulary:    task.Vocabulary,
+			Workspace:     task.Workspace,
+			Priority:      string(priority),
+
+			KeepIntermediate: &keep,
+		})
+		if err != nil {
+			return err
+		}
+		m.updatePipeline(task, func(t *PipelineTask) {
+			t.TranscribeID = tr.ID
+		})
+		logging.FromContext(ctx).Info("下载完成，开始转录", "transcribe_id", tr.ID)
+	}
+
+	m.waitStage(ctx, task.TranscribeID, func() Status {
+		tr, err := m.Transcribe(task.TranscribeID)
+		if err != nil {
+			return StatusFailed
+		}
+		if !tr.Status.Terminal() {
+			m.updatePipeline(task, func(t *PipelineTask) {
+				t.Status = tr.Status
+				t.Stage = tr.Stage
+				if tr.Status == StatusQueued && tr.QueuePosition > 0 {
+					t.Stage = fmt.Sprintf("排队等待转录（第 %d 位）", tr.QueuePosition)
+				}
+				t.Percentage = pipelinePercentage(t, transcribePercentage(tr))
+				t.ETASeconds = tr.ETASeconds
+				t.MP3Path = tr.MP3Path
+				t.TXTPath = tr.TXTPath
+			})
+		}
+		return tr.Status
+	})
+
+	tr, err := m.Transcribe(task.TranscribeID)
+	switch {
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case err != nil:
+		return fmt.Errorf("转录子任务 %s 已被删除", task.TranscribeID)
+	case tr.Status != StatusCompleted:
+		return &errcode.Error{Code: tr.ErrorCode, Message: "转录失败: " + tr.Error, Detail: tr.ErrorDetail}
+	}
+	m.updatePipeline(task, func(t *PipelineTask) {
+		t.MP3Path = tr.MP3Path
+		t.TXTPath = tr.TXTPath
+		t.SRTPath = tr.SRTPath
+		t.JSONPath = tr.JSONPath
+		t.SummaryPath = tr.SummaryPath
+		t.DetectedLanguage = tr.DetectedLanguage
+		t.LanguageProbability = tr.LanguageProbability
+	})
+	return nil
+}
+
+// waitStage 等待子任务结束，每次子任务更新时调用 sync 同步进度并返回子任务状态。
+// ctx 取消时一并取消子任务
+func (m *Manager) waitStage(ctx context.Context, childID string, sync func() Status) {
+	updates, unsubscribe := m.Subscribe(childID)
+	defer unsubscribe()
+
+	done := ctx.Done()
+	for !sync().Terminal() {
+		select {
+		case <-updates:
+		case <-done:
+			m.Cancel(childID)
+			done = nil
+		}
+	}
+}
+
+func downloadStage(d *DownloadTask) string {
+	switch d.Status {
+	case StatusQueued:
+		if d.QueuePosition > 0 {
+			return fmt.Sprintf("排队中（第 %d 位）", d.QueuePosition)
+		}
+		return "排队中"
+	case StatusDownloading:
+		return "下载中"
+	case StatusTranscoding:
+		return fmt.Sprintf("转码中 %d%%", d.Percentage)
+	case StatusPaused:
+		return "下载已暂停"
+	}
+	return "等待下载"
+}
+
+// transcribePercentage 把转录任务的进度（提取音频 0–15%，转录 16–98%）换算为流水线的 50–99%
+func transcribePercentage(tr *TranscribeTask) int {
+	if tr.Status == StatusTranscribing {
+		return min(99, 60+max(0, tr.Percentage-16)*40/82)
+	}
+	return 50 + min(15, tr.Percentage)*10/15
+}
+
+func (m *Manager) updatePipeline(task *PipelineTask, fn func(t *PipelineTask)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if task.Status == StatusCancelled {
+		return
+	}
+	fn(task)
+	m.touchPipeline(task)
+	m.savePipelineLocked(task)
+	m.notifyLocked(task.ID)
+}
+
+func (m *Manager) touchPipeline(t *PipelineTask) {
+	t.UpdatedAt = time.Now()
+	t.ElapsedTime = int(t.UpdatedAt.Sub(t.StartTime).Seconds())
+}
+
+func (m *Manager) savePipelineLocked(t *PipelineTask) error {
+	_, known := m.pipelines[t.ID]
+	m.recordLocked(t.ID, !known, t.Status, t.Error, 0, t.Percentage)
+	if m.persister == nil {
+		return nil
+	}
+	return m.persister.SavePipeline(t)
+}
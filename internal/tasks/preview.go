@@ -0,0 +1,61 @@
+package tasks
+
+import (
+	"context"
+
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/media"
+)
+
+// DefaultSpriteFrames 预览图默认帧数（5×5）
+const DefaultSpriteFrames = 25
+
+// PreviewOptions 下载完成后生成的预览图片
+type PreviewOptions struct {
+	// Thumbnail 生成封面 <name>.jpg
+	Thumbnail bool
+	// Sprite 生成由 SpriteFrames 帧拼成的预览图 <name>.sprite.jpg
+	Sprite       bool
+	SpriteFrames int
+}
+
+// WithPreview 设置下载完成后生成的预览图片（默认只生成封面）
+func WithPreview(p PreviewOptions) Option {
+	return func(m *Manager) {
+		if p.SpriteFrames <= 0 {
+			p.SpriteFrames = DefaultSpriteFrames
+		}
+		m.preview = p
+	}
+}
+
+// generatePreview 为下载完成的视频生成封面和预览图，返回生成的文件路径。
+// 生成失败不影响下载结果，只记录日志
+func (m *Manager) generatePreview(ctx context.Context, video string) (thumbnail, sprite string) {
+	p := m.preview
+	if !p.Thumbnail && !p.Sprite {
+		return "", ""
+	}
+	ctx = logging.WithStage(ctx, "preview")
+	logger := logging.FromContext(ctx)
+	duration := media.Duration(video)
+
+	if p.Thumbnail {
+		path := media.ThumbnailPath(video)
+		if err := media.Thumbnail(ctx, video, path, duration); err != nil {
+			logger.Warn("生成封面失败", "error", err)
+		} else {
+			thumbnail = path
+		}
+	}
+	if p.Sprite && ctx.Err() == nil {
+		path := media.SpritePath(video)
+		if err := media.Sprite(ctx, video, path, duration, p.SpriteFrames); err != nil {
+			logger.Warn("生成预览图失败", "error", err)
+		} else {
+			sprite = path
+		}
+	}
+	logger.Debug("预览图片已生成", "thumbnail", thumbnail, "sprite", sprite)
+	return thumbnail, sprite
+}
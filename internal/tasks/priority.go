@@ -0,0 +1,128 @@
+package tasks
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Priority 任务优先级，排队的下载和转录任务按优先级从高到低开始，同一优先级按加入队列的先后
+type Priority string
+
+const (
+	PriorityHigh   Priority = "high"
+	PriorityNormal Priority = "normal"
+	PriorityLow    Priority = "low"
+)
+
+// ParsePriority 解析优先级，空字符串为 normal
+func ParsePriority(s string) (Priority, error) {
+	switch p := Priority(s); p {
+	case "":
+		return PriorityNormal, nil
+	case PriorityHigh, PriorityNormal, PriorityLow:
+		return p, nil
+	}
+	return "", fmt.Errorf("未知的优先级: %s（可选 high / normal / low）", s)
+}
+
+// rank 优先级的排序值，越大越先执行。旧任务没有优先级，按 normal 处理
+func (p Priority) rank() int {
+	switch p {
+	case PriorityHigh:
+		return 2
+	case PriorityLow:
+		return 0
+	}
+	return 1
+}
+
+// insertQueuedLocked 把任务插入下载队列中同一优先级的最后
+func (m *Manager) insertQueuedLocked(q queuedDownload) {
+	m.queue = insertByPriority(m.queue, q, func(q queuedDownload) Priority { return q.task.Priority })
+}
+
+// insertByPriority 把 item 插入 queue 中同一优先级的最后，下载和转录队列共用
+func insertByPriority[T any](queue []T, item T, priority func(T) Priority) []T {
+	rank := priority(item).rank()
+	i := slices.IndexFunc(queue, func(other T) bool { return priority(other).rank() < rank })
+	if i < 0 {
+		i = len(queue)
+	}
+	return slices.Insert(queue, i, item)
+}
+
+// SetPriority 修改未结束任务的优先级，排队中的下载任务按新的优先级调整位置。
+// 流水线任务同时修改下载和转录子任务，合集任务同时修改所有未结束的下载子任务
+func (m *Manager) SetPriority(id string, p Priority) error {
+	if _, err := ParsePriority(string(p)); err != nil {
+		return err
+	}
+	m.refreshAny(id)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status, ok := m.statusLocked(id)
+	if !ok {
+		return ErrNotFound
+	}
+	if status.Terminal() {
+		return fmt.Errorf("任务状态为 %s，无法修改优先级", status)
+	}
+	if m.elsewhereLocked(id, status) && !m.queuedJobLocked(status) {
+		return ErrRunningElsewhere
+	}
+
+	if t, ok := m.pipelines[id]; ok {
+		t.Priority = p
+		m.touchPipeline(t)
+		m.savePipelineLocked(t)
+		m.notifyLocked(id)
+		m.setChildPriorityLocked(t.DownloadID, p)
+		m.setChildPriorityLocked(t.TranscribeID, p)
+		return nil
+	}
+	if t, ok := m.collections[id]; ok {
+		t.Priority = p
+		m.touchCollection(t)
+		m.saveCollectionLocked(t)
+		m.notifyLocked(id)
+		for _, child := range t.DownloadIDs {
+			m.setChildPriorityLocked(child, p)
+		}
+		return nil
+	}
+	m.setChildPriorityLocked(id, p)
+	return nil
+}
+
+// setChildPriorityLocked 修改下载或转录任务的优先级，任务不存在或已结束时忽略
+func (m *Manager) setChildPriorityLocked(id string, p Priority) {
+	if t, ok := m.downloads[id]; ok && !t.Status.Terminal() && t.Priority != p {
+		t.Priority = p
+		m.touchDownload(t)
+		m.saveDownloadLocked(t)
+		if i := slices.IndexFunc(m.queue, func(q queuedDownload) bool { return q.task.ID == id }); i >= 0 {
+			q := m.queue[i]
+			m.queue = slices.Delete(m.queue, i, i+1)
+			m.insertQueuedLocked(q)
+		}
+		if t.Status == StatusQueued {
+			m.reprioritizeJobLocked(KindDownload, id, p)
+		}
+		m.notifyLocked(id)
+	}
+	if t, ok := m.transcribes[id]; ok && !t.Status.Terminal() && t.Priority != p {
+		t.Priority = p
+		m.touchTranscribe(t)
+		m.saveTranscribeLocked(t)
+		if i := slices.IndexFunc(m.transcribeQueue, func(q queuedTranscribe) bool { return q.task.ID == id }); i >= 0 {
+			q := m.transcribeQueue[i]
+			m.transcribeQueue = slices.Delete(m.transcribeQueue, i, i+1)
+			m.transcribeQueue = insertByPriority(m.transcribeQueue, q, func(q queuedTranscribe) Priority { return q.task.Priority })
+		}
+		if t.Status == StatusQueued {
+			m.reprioritizeJobLocked(KindTranscribe, id, p)
+		}
+		m.notifyLocked(id)
+	}
+}
@@ -0,0 +1,267 @@
+package tasks
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 任务列表分页
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+)
+
+// Query 任务列表的筛选和分页条件，零值字段表示不限制
+type Query struct {
+	// Kinds 只返回这些类型的任务
+	Kinds []Kind
+	// Statuses 只返回这些状态的任务
+	Statuses []Status
+	// Since / Until 创建时间范围，Until 不包含在内
+	Since, Until time.Time
+	// Search 在链接、文件路径和任务 ID 中搜索（不区分大小写），多个关键词用空格分隔，需要全部匹配
+	Search string
+	// Workspace 只返回这个工作区的任务
+	Workspace string
+	// Limit 每页数量，默认 DefaultListLimit，最大 MaxListLimit
+	Limit  int
+	Offset int
+}
+
+// Page 一页任务，各类任务合并按创建时间倒序分页后再分别列出
+type Page struct {
+	Downloads   []*DownloadTask   `json:"downloads"`
+	Transcribes []*TranscribeTask `json:"transcribes"`
+	Pipelines   []*PipelineTask   `json:"pipelines"`
+	Collections []*CollectionTask `json:"collections"`
+	// Total 符合条件的任务总数（所有页）
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+	// NextOffset 下一页的 offset，没有下一页时为 0
+	NextOffset int `json:"next_offset,omitempty"`
+}
+
+// ParseQuery 解析任务列表参数，get 返回参数的字符串值：
+// type / status（逗号分隔）、since / until（2006-01-02 或 RFC 3339，until 为日期时包含当天）、
+// search、workspace、limit、offset
+func ParseQuery(get func(name string) string) (Query, error) {
+	var q Query
+	var err error
+	if q.Kinds, err = parseKinds(get("type")); err != nil {
+		return q, err
+	}
+	if q.Statuses, err = parseStatuses(get("status")); err != nil {
+		return q, err
+	}
+	if q.Since, _, err = parseDate(get("since")); err != nil {
+		return q, err
+	}
+	var dateOnly bool
+	if q.Until, dateOnly, err = parseDate(get("until")); err != nil {
+		return q, err
+	}
+	if dateOnly {
+		q.Until = q.Until.AddDate(0, 0, 1)
+	}
+	q.Search = strings.TrimSpace(get("search"))
+	q.Workspace = strings.TrimSpace(get("workspace"))
+	for _, p := range []struct {
+		name string
+		v    *int
+	}{{"limit", &q.Limit}, {"offset", &q.Offset}} {
+		if s := get(p.name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				return q, fmt.Errorf("%s 必须是非负整数", p.name)
+			}
+			*p.v = n
+		}
+	}
+	return q, nil
+}
+
+// parseKinds 解析逗号分隔的任务类型
+func parseKinds(s string) ([]Kind, error) {
+	var kinds []Kind
+	for _, v := range splitList(s) {
+		switch k := Kind(v); k {
+		case KindDownload, KindTranscribe, KindPipeline, KindCollection:
+			kinds = append(kinds, k)
+		default:
+			return nil, fmt.Errorf("未知的任务类型: %s（可选 download / transcribe / pipeline / collection）", v)
+		}
+	}
+	return kinds, nil
+}
+
+// parseStatuses 解析逗号分隔的任务状态
+func parseStatuses(s string) ([]Status, error) {
+	var statuses []Status
+	for _, v := range splitList(s) {
+		switch st := Status(v); st {
+		case StatusPending, StatusQueued, StatusPaused, StatusDownloading, StatusTranscoding, StatusExtractingAudio, StatusTranscribing,
+			StatusCompleted, StatusFailed, StatusCancelled, StatusInterrupted:
+			statuses = append(statuses, st)
+		default:
+			return nil, fmt.Errorf("未知的任务状态: %s", v)
+		}
+	}
+	return statuses, nil
+}
+
+// parseDate 解析 RFC 3339 时间或 2006-01-02 格式的日期（本地时间零点），空字符串返回零值
+func parseDate(s string) (t time.Time, dateOnly bool, err error) {
+	if s == "" {
+		return time.Time{}, false, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+	t, err = time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("无法解析时间 %q，请使用 2006-01-02 或 RFC 3339 格式", s)
+	}
+	return t, true, nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// listEntry 合并分页时的一项
+type listEntry struct {
+	kind      Kind
+	id        string
+	status    Status
+	created   time.Time
+	workspace string
+	fields    []string
+	task      interface{}
+}
+
+// Apply 按条件筛选任务并分页
+func (q Query) Apply(downloads []*DownloadTask, transcribes []*TranscribeTask, pipelines []*PipelineTask, collections []*CollectionTask) Page {
+	matched := q.filter(downloads, transcribes, pipelines, collections)
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	limit = min(limit, MaxListLimit)
+	offset := max(q.Offset, 0)
+	page := Page{
+		Downloads:   []*DownloadTask{},
+		Transcribes: []*TranscribeTask{},
+		Pipelines:   []*PipelineTask{},
+		Collections: []*CollectionTask{},
+		Total:       len(matched),
+		Limit:       limit,
+		Offset:      offset,
+	}
+	if end := offset + limit; end < len(matched) {
+		page.NextOffset = end
+		matched = matched[offset:end]
+	} else if offset < len(matched) {
+		matched = matched[offset:]
+	} else {
+		matched = nil
+	}
+
+	for _, e := range matched {
+		switch t := e.task.(type) {
+		case *DownloadTask:
+			page.Downloads = append(page.Downloads, t)
+		case *TranscribeTask:
+			page.Transcribes = append(page.Transcribes, t)
+		case *PipelineTask:
+			page.Pipelines = append(page.Pipelines, t)
+		case *CollectionTask:
+			page.Collections = append(page.Collections, t)
+		}
+	}
+	return page
+}
+
+// filter 按条件筛选任务（不分页），按创建时间倒序排列
+func (q Query) filter(downloads []*DownloadTask, transcribes []*TranscribeTask, pipelines []*PipelineTask, collections []*CollectionTask) []listEntry {
+	var entries []listEntry
+	for _, t := range downloads {
+		entries = append(entries, listEntry{KindDownload, t.ID, t.Status, t.CreatedAt, t.Workspace,
+			[]string{t.ID, t.VideoURL, t.FilePath}, t})
+	}
+	for _, t := range transcribes {
+		entries = append(entries, listEntry{KindTranscribe, t.ID, t.Status, t.CreatedAt, t.Workspace,
+			[]string{t.ID, t.VideoPath, t.MP3Path, t.TXTPath}, t})
+	}
+	for _, t := range pipelines {
+		entries = append(entries, listEntry{KindPipeline, t.ID, t.Status, t.CreatedAt, t.Workspace,
+			[]string{t.ID, t.VideoURL, t.FilePath, t.MP3Path, t.TXTPath}, t})
+	}
+	for _, t := range collections {
+		entries = append(entries, listEntry{KindCollection, t.ID, t.Status, t.CreatedAt, t.Workspace,
+			[]string{t.ID, t.URL, t.Title, t.OutputDir}, t})
+	}
+
+	matched := entries[:0]
+	for _, e := range entries {
+		if q.match(e) {
+			matched = append(matched, e)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if !matched[i].created.Equal(matched[j].created) {
+			return matched[i].created.After(matched[j].created)
+		}
+		return matched[i].id > matched[j].id
+	})
+	return matched
+}
+
+func (q Query) match(e listEntry) bool {
+	if len(q.Kinds) > 0 && !contains(q.Kinds, e.kind) {
+		return false
+	}
+	if len(q.Statuses) > 0 && !contains(q.Statuses, e.status) {
+		return false
+	}
+	if !q.Since.IsZero() && e.created.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !e.created.Before(q.Until) {
+		return false
+	}
+	if q.Workspace != "" && e.workspace != q.Workspace {
+		return false
+	}
+	text := strings.ToLower(strings.Join(e.fields, "\n"))
+	for _, word := range strings.Fields(strings.ToLower(q.Search)) {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains[T comparable](list []T, v T) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// List 按条件列出管理器中的任务
+func (m *Manager) List(q Query) Page {
+	return q.Apply(m.Downloads(), m.Transcribes(), m.Pipelines(), m.Collections())
+}
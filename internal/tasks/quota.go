@@ -0,0 +1,208 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"zhihu-downloader/internal/diskspace"
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/logging"
+)
+
+// QuotaPolicy 默认下载目录超出容量上限时的处理方式
+type QuotaPolicy string
+
+const (
+	// QuotaReject 拒绝新的下载
+	QuotaReject QuotaPolicy = "reject"
+	// QuotaEvict 按完成时间从早到晚删除已完成的下载任务及其文件，直到空间足够
+	QuotaEvict QuotaPolicy = "evict"
+)
+
+// DefaultMinFree 下载后磁盘至少保留的空间
+const DefaultMinFree = 1 << 30
+
+var (
+	// ErrNoSpace 输出目录所在磁盘剩余空间不足
+	ErrNoSpace = errcode.New(errcode.DiskFull, "磁盘空间不足")
+	// ErrQuotaExceeded 默认下载目录超出容量上限
+	ErrQuotaExceeded = errcode.New(errcode.QuotaExceeded, "下载目录超出容量上限")
+)
+
+// QuotaOptions 下载前的空间检查和默认下载目录的容量限制
+type QuotaOptions struct {
+	// MinFree 下载后输出目录所在磁盘至少保留的字节数，0 表示不检查
+	MinFree int64
+	// MaxSize 默认下载目录（包括子目录）的总容量上限（字节），0 表示不限制。
+	// 输出到其他目录的下载不受限制，工作区另有自己的上限（Workspace.MaxSize）
+	MaxSize int64
+	// Policy 超出上限时的处理方式，默认 QuotaReject
+	Policy QuotaPolicy
+}
+
+// WithQuota 设置空间检查和容量限制（默认只检查磁盘至少保留 DefaultMinFree）
+func WithQuota(q QuotaOptions) Option {
+	return func(m *Manager) {
+		if q.Policy == "" {
+			q.Policy = QuotaReject
+		}
+		m.quota = q
+	}
+}
+
+// ParseQuotaPolicy 校验容量策略，空字符串为 QuotaReject
+func ParseQuotaPolicy(s string) (QuotaPolicy, error) {
+	switch p := QuotaPolicy(s); p {
+	case "":
+		return QuotaReject, nil
+	case QuotaReject, QuotaEvict:
+		return p, nil
+	}
+	return "", fmt.Errorf("未知的容量策略: %s（可选 reject / evict）", s)
+}
+
+// checkSpace 创建下载任务前的快速检查：磁盘剩余空间已低于 MinFree，
+// 或 reject 策略下默认下载目录已达到上限时直接返回错误
+func (m *Manager) checkSpace(dir string) error {
+	if m.quota.MinFree > 0 {
+		if free, err := diskspace.Free(dir); err == nil && free < m.quota.MinFree {
+			return fmt.Errorf("%w：%s 所在磁盘只剩 %s，至少需要保留 %s",
+				ErrNoSpace, dir, diskspace.Format(free), diskspace.Format(m.quota.MinFree))
+		}
+	}
+	if m.quota.Policy == QuotaReject {
+		for _, l := range m.quotaLimits(dir) {
+			if used, err := diskspace.Usage(l.dir); err == nil && used >= l.max {
+				return fmt.Errorf("%w（%s）：%s 已使用 %s，请删除旧的下载或调整 %s",
+					ErrQuotaExceeded, diskspace.Format(l.max), l.dir, diskspace.Format(used), l.setting)
+			}
+		}
+	}
+	return nil
+}
+
+// quotaLimit 一个有容量上限的目录
+type quotaLimit struct {
+	dir string
+	max int64
+	// setting 调整上限的配置项，用于错误信息
+	setting string
+}
+
+// quotaLimits 返回 dir 受到的容量上限：默认下载目录的上限，以及 dir 所在工作区的上限
+func (m *Manager) quotaLimits(dir string) []quotaLimit {
+	var limits []quotaLimit
+	if m.quota.MaxSize > 0 && inDir(m.outputDir, dir) {
+		limits = append(limits, quotaLimit{m.outputDir, m.quota.MaxSize, "quota.max_size_mb"})
+	}
+	for _, ws := range m.Workspaces() {
+		if ws.MaxSize > 0 && inDir(ws.OutputDir, dir) {
+			limits = append(limits, quotaLimit{ws.OutputDir, ws.MaxSize, "工作区 " + ws.Name + " 的 max_size_mb"})
+		}
+	}
+	return limits
+}
+
+// reserveSpace 开始下载前按估算的文件大小检查空间，通过后为任务预留空间，
+// 同时执行的下载不会一起超出限制。预留的空间在下载结束时（runDownload）释放
+func (m *Manager) reserveSpace(ctx context.Context, task *DownloadTask, req downloader.Request) error {
+	limits := m.quotaLimits(req.OutputDir)
+	if m.quota.MinFree <= 0 && len(limits) == 0 {
+		return nil
+	}
+	logger := logging.FromContext(ctx)
+	estimate := downloader.EstimateSize(ctx, req)
+	if estimate > 0 {
+		logger.Debug("预计文件大小", "size", diskspace.Format(estimate))
+	}
+
+	// 统计目录大小可能较慢，在锁外执行
+	free, freeErr := diskspace.Free(req.OutputDir)
+	used := make([]int64, len(limits))
+	for i, l := range limits {
+		var err error
+		if used[i], err = diskspace.Usage(l.dir); err != nil {
+			logger.Warn("统计下载目录大小失败", "dir", l.dir, "error", err)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var reserved int64
+	for id, n := range m.reserved {
+		if id != task.ID {
+			reserved += n
+		}
+	}
+	need := estimate + reserved
+	size := "大小未知"
+	if estimate > 0 {
+		size = "约 " + diskspace.Format(estimate)
+	}
+
+	if m.quota.MinFree > 0 && freeErr == nil && free-need < m.quota.MinFree {
+		return fmt.Errorf("%w：%s 所在磁盘只剩 %s，这个视频%s，下载后至少需要保留 %s",
+			ErrNoSpace, req.OutputDir, diskspace.Format(free), size, diskspace.Format(m.quota.MinFree))
+	}
+	for i, l := range limits {
+		if over := used[i] + need - l.max; over > 0 {
+			if m.quota.Policy == QuotaEvict {
+				over -= m.evictLocked(ctx, l.dir, over)
+			}
+			if over > 0 {
+				return fmt.Errorf("%w（%s）：%s 已使用 %s，这个视频%s",
+					ErrQuotaExceeded, diskspace.Format(l.max), l.dir, diskspace.Format(used[i]), size)
+			}
+		}
+	}
+	m.reserved[task.ID] = estimate
+	return nil
+}
+
+// evictLocked 按完成时间从早到晚删除 dir 中已完成的下载任务及其文件，
+// 直到释放 need 字节。仍在转录的视频不会删除。返回释放的字节数
+func (m *Manager) evictLocked(ctx context.Context, dir string, need int64) int64 {
+	inUse := map[string]bool{}
+	for _, t := range m.transcribes {
+		if !t.Status.Terminal() || m.active[t.ID] {
+			inUse[t.VideoPath] = true
+		}
+	}
+	var candidates []*DownloadTask
+	for _, t := range m.downloads {
+		if t.Status == StatusCompleted && t.FilePath != "" && !m.active[t.ID] &&
+			!inUse[t.FilePath] && inDir(dir, t.FilePath) {
+			candidates = append(candidates, t)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].UpdatedAt.Before(candidates[j].UpdatedAt) })
+
+	logger := logging.FromContext(ctx)
+	var freed int64
+	for _, t := range candidates {
+		if freed >= need {
+			break
+		}
+		// 只计算删除后真正释放的空间：符号链接、还有其他硬链接的文件和会由其他下载接管的原始文件不计算
+		shared := len(m.duplicatesLocked(t)) > 0
+		var size int64
+		for _, path := range []string{t.FilePath, t.ThumbnailPath, t.SpritePath, t.NFOPath, t.CommentsPath, t.CommentsMarkdownPath} {
+			if path == "" || (path == t.FilePath && shared) {
+				continue
+			}
+			if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() && !diskspace.Linked(info) {
+				size += info.Size()
+			}
+		}
+		if err := m.deleteDownloadLocked(t, true); err != nil {
+			continue
+		}
+		freed += size
+		logger.Warn("下载目录超出容量上限，已删除最早完成的下载",
+			"download_id", t.ID, "file_path", t.FilePath, "size", diskspace.Format(size))
+	}
+	return freed
+}
@@ -0,0 +1,76 @@
+package tasks
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"zhihu-downloader/internal/errcode"
+)
+
+// ErrOutsideSandbox 路径不在允许访问的目录中
+var ErrOutsideSandbox = errcode.New(errcode.Forbidden, "路径不在允许访问的目录中")
+
+// WithAllowedRoots 限制任务读写的目录：下载和转录的输出目录、转录的视频都必须在其中某个目录中，
+// 默认下载目录和工作区目录总是允许。为空时不限制（默认）
+func WithAllowedRoots(roots []string) Option {
+	return func(m *Manager) {
+		m.allowedRoots = nil
+		for _, root := range roots {
+			if root = strings.TrimSpace(root); root != "" {
+				m.allowedRoots = append(m.allowedRoots, ExpandHome(root))
+			}
+		}
+	}
+}
+
+// AllowedRoots 返回允许访问的目录（包括默认下载目录和工作区目录），没有限制时返回 nil
+func (m *Manager) AllowedRoots() []string {
+	if len(m.allowedRoots) == 0 {
+		return nil
+	}
+	roots := append([]string{}, m.allowedRoots...)
+	roots = append(roots, m.outputDir)
+	for _, ws := range m.Workspaces() {
+		roots = append(roots, ws.OutputDir)
+	}
+	return roots
+}
+
+// CheckPath 检查路径是否在允许访问的目录中，没有配置 allowed_roots 时不限制。
+// 比较前先解析符号链接，目录中指向外部的符号链接不能用来绕过限制
+func (m *Manager) CheckPath(path string) error {
+	roots := m.AllowedRoots()
+	if roots == nil {
+		return nil
+	}
+	real, err := realPath(path)
+	if err != nil {
+		return fmt.Errorf("%w：%s（%v）", ErrOutsideSandbox, path, err)
+	}
+	for _, root := range roots {
+		if realRoot, err := realPath(root); err == nil && inDir(realRoot, real) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w：%s", ErrOutsideSandbox, path)
+}
+
+// realPath 返回解析符号链接后的绝对路径。路径还不存在时（例如要创建的输出目录）
+// 解析最近的已存在的上级目录，再接上其余部分
+func realPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	var rest []string
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(append([]string{real}, rest...)...), nil
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			return abs, nil
+		}
+		rest = append([]string{filepath.Base(dir)}, rest...)
+	}
+}
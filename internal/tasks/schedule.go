@@ -0,0 +1,350 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"zhihu-downloader/internal/cron"
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/zhihu"
+)
+
+// Schedule 计划任务：在 StartAt 指定的时间创建一次下载，或按 Cron 表达式定期创建
+// （例如每天抓取一次作者主页中的视频）。时间按服务所在时区计算
+type Schedule struct {
+	ID string `json:"id"`
+	// Type 创建的任务类型：download 或 collection，默认按链接判断
+	Type             Kind   `json:"type"`
+	URL              string `json:"url"`
+	Quality          string `json:"quality,omitempty"`
+	Backend          string `json:"backend,omitempty"`
+	OutputDir        string `json:"output_dir,omitempty"`
+	FilenameTemplate string `json:"filename_template,omitempty"`
+	// Limit 合集最多下载的视频数
+	Limit int `json:"limit,omitempty"`
+	// Workspace 计划任务所属的工作区，创建的任务也属于这个工作区
+	Workspace string `json:"workspace,omitempty"`
+	// StartAt 只设置 StartAt 时在这个时间执行一次；同时设置 Cron 时表示从这个时间之后开始
+	StartAt *time.Time `json:"start_at,omitempty"`
+	// Cron 5 段 cron 表达式（分 时 日 月 周），为空时只执行一次
+	Cron    string `json:"cron,omitempty"`
+	Enabled bool   `json:"enabled"`
+	// NextRun 下次执行时间，一次性计划执行后或停用时为空
+	NextRun *time.Time `json:"next_run,omitempty"`
+	LastRun *time.Time `json:"last_run,omitempty"`
+	// LastTaskID 上次创建的任务，LastError 上次创建任务失败的原因
+	LastTaskID string    `json:"last_task_id,omitempty"`
+	LastError  string    `json:"last_error,omitempty"`
+	RunCount   int       `json:"run_count"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// CreateSchedule 校验并保存计划任务，返回保存后的快照
+func (m *Manager) CreateSchedule(s Schedule) (*Schedule, error) {
+	now := time.Now()
+	if err := m.prepareSchedule(&s, now); err != nil {
+		return nil, err
+	}
+	s.ID = uuid.New().String()
+	s.CreatedAt = now
+	s.UpdatedAt = now
+
+	m.mu.Lock()
+	if err := m.saveScheduleLocked(&s); err != nil {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("保存计划任务失败: %v", err)
+	}
+	m.schedules[s.ID] = &s
+	m.mu.Unlock()
+
+	m.wakeScheduler()
+	return s.snapshot(), nil
+}
+
+// UpdateSchedule 用 s 替换计划任务的设置并重新计算下次执行时间，执行记录保留
+func (m *Manager) UpdateSchedule(id string, s Schedule) (*Schedule, error) {
+	now := time.Now()
+	if err := m.prepareSchedule(&s, now); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	cur, ok := m.schedules[id]
+	if !ok {
+		m.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	s.ID = id
+	s.LastRun, s.LastTaskID, s.LastError, s.RunCount = cur.LastRun, cur.LastTaskID, cur.LastError, cur.RunCount
+	s.CreatedAt = cur.CreatedAt
+	s.UpdatedAt = now
+	if err := m.saveScheduleLocked(&s); err != nil {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("保存计划任务失败: %v", err)
+	}
+	*cur = s
+	m.mu.Unlock()
+
+	m.wakeScheduler()
+	return s.snapshot(), nil
+}
+
+// DeleteSchedule 删除计划任务，已创建的任务不受影响
+func (m *Manager) DeleteSchedule(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.schedules[id]; !ok {
+		return ErrNotFound
+	}
+	if m.persister != nil {
+		if err := m.persister.DeleteSchedule(id); err != nil {
+			return fmt.Errorf("删除计划任务失败: %v", err)
+		}
+	}
+	delete(m.schedules, id)
+	return nil
+}
+
+// Schedule 返回计划任务的快照
+func (m *Manager) Schedule(id string) (*Schedule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.schedules[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.snapshot(), nil
+}
+
+// Schedules 返回所有计划任务（按创建时间倒序）
+func (m *Manager) Schedules() []*Schedule {
+	m.mu.RLock()
+	list := make([]*Schedule, 0, len(m.schedules))
+	for _, s := range m.schedules {
+		list = append(list, s.snapshot())
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list
+}
+
+// RestoreSchedules 载入之前保存的计划任务。服务停止期间错过的执行时间在 RunSchedules 启动后补执行一次
+func (m *Manager) RestoreSchedules(list []*Schedule) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range list {
+		m.schedules[s.ID] = s
+	}
+}
+
+// RunSchedules 到时间后为计划任务创建下载或合集任务，直到 ctx 结束
+func (m *Manager) RunSchedules(ctx context.Context) {
+	for {
+		m.runDueSchedules(time.Now())
+
+		timer := time.NewTimer(m.untilNextSchedule(time.Now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-m.scheduleWake:
+			timer.Stop()
+		}
+	}
+}
+
+// prepareSchedule 校验参数、补全类型并计算下次执行时间
+func (m *Manager) prepareSchedule(s *Schedule, now time.Time) error {
+	if s.URL == "" {
+		return errcode.New(errcode.URLInvalid, "URL 必填")
+	}
+	if s.Type == "" {
+		s.Type = KindDownload
+		if zhihu.IsCollectionURL(s.URL) {
+			s.Type = KindCollection
+		}
+	}
+	switch s.Type {
+	case KindDownload:
+		if err := downloader.ValidateFilenameTemplate(s.FilenameTemplate); err != nil {
+			return err
+		}
+	case KindCollection:
+		if _, _, err := zhihu.ParseCollectionURL(s.URL); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("计划任务类型只能是 download 或 collection: %s", s.Type)
+	}
+	quality, err := downloader.NormalizeQuality(s.Quality)
+	if err != nil {
+		return err
+	}
+	s.Quality = quality
+	if _, err := downloader.ResolveBackend(s.URL, s.Backend); err != nil {
+		return err
+	}
+	if s.OutputDir != "" {
+		dir, err := m.WorkspaceOutputDir(s.Workspace, s.OutputDir)
+		if err != nil {
+			return err
+		}
+		s.OutputDir = dir
+	} else if s.Workspace != "" {
+		if _, ok := m.Workspace(s.Workspace); !ok {
+			return fmt.Errorf("工作区不存在: %s", s.Workspace)
+		}
+	}
+
+	s.NextRun = nil
+	switch {
+	case s.Cron != "":
+		c, err := cron.Parse(s.Cron)
+		if err != nil {
+			return err
+		}
+		if s.Enabled {
+			next := c.Next(scheduleFrom(s, now))
+			if next.IsZero() {
+				return fmt.Errorf("cron 表达式没有可执行的时间: %s", s.Cron)
+			}
+			s.NextRun = &next
+		}
+	case s.StartAt != nil:
+		if s.Enabled {
+			if !s.StartAt.After(now) {
+				return fmt.Errorf("start_at 已经过去: %s", s.StartAt.Format(time.RFC3339))
+			}
+			next := *s.StartAt
+			s.NextRun = &next
+		}
+	default:
+		return fmt.Errorf("start_at 和 cron 至少需要一个")
+	}
+	return nil
+}
+
+// scheduleFrom 计算 cron 下次执行时间的起点：now 和 StartAt 中较晚的一个
+func scheduleFrom(s *Schedule, now time.Time) time.Time {
+	if s.StartAt != nil && s.StartAt.After(now) {
+		// Next 不包含起点所在的分钟
+		return s.StartAt.Add(-time.Minute)
+	}
+	return now
+}
+
+// runDueSchedules 执行所有到期的计划任务。先推进下次执行时间再创建任务，同一时间点只执行一次
+func (m *Manager) runDueSchedules(now time.Time) {
+	var due []Schedule
+	m.mu.Lock()
+	for _, s := range m.schedules {
+		if !s.Enabled || s.NextRun == nil || s.NextRun.After(now) {
+			continue
+		}
+		due = append(due, *s)
+		s.NextRun = nil
+		if s.Cron != "" {
+			if c, err := cron.Parse(s.Cron); err == nil {
+				if next := c.Next(now); !next.IsZero() {
+					s.NextRun = &next
+				}
+			}
+		}
+		// 一次性计划执行后停用
+		if s.NextRun == nil {
+			s.Enabled = false
+		}
+		s.LastRun = &now
+		s.RunCount++
+		s.UpdatedAt = now
+		m.saveScheduleLocked(s)
+	}
+	m.mu.Unlock()
+
+	for _, s := range due {
+		id, err := m.startScheduled(s)
+		m.mu.Lock()
+		if cur, ok := m.schedules[s.ID]; ok {
+			cur.LastTaskID = id
+			cur.LastError = ""
+			if err != nil {
+				cur.LastError = err.Error()
+			}
+			cur.UpdatedAt = time.Now()
+			m.saveScheduleLocked(cur)
+		}
+		m.mu.Unlock()
+
+		if err != nil {
+			slog.Warn("计划任务创建任务失败", "schedule_id", s.ID, "url", s.URL, "error", err)
+		} else {
+			slog.Info("计划任务已创建任务", "schedule_id", s.ID, "task_id", id, "type", s.Type, "url", s.URL)
+		}
+	}
+}
+
+// startScheduled 按计划任务的设置创建下载或合集任务，返回任务 ID
+func (m *Manager) startScheduled(s Schedule) (string, error) {
+	req := downloader.Request{
+		URL:              s.URL,
+		Quality:          s.Quality,
+		OutputDir:        s.OutputDir,
+		Backend:          s.Backend,
+		FilenameTemplate: s.FilenameTemplate,
+		Workspace:        s.Workspace,
+	}
+	if s.Type == KindCollection {
+		task, err := m.StartCollection(req, s.Limit)
+		if err != nil {
+			return "", err
+		}
+		return task.ID, nil
+	}
+	task, err := m.StartDownload(req)
+	if err != nil {
+		return "", err
+	}
+	return task.ID, nil
+}
+
+// untilNextSchedule 距离最近一次执行的时间，最长一分钟（系统休眠或修改时间后及时校正）
+func (m *Manager) untilNextSchedule(now time.Time) time.Duration {
+	wait := time.Minute
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, s := range m.schedules {
+		if s.Enabled && s.NextRun != nil {
+			wait = min(wait, max(s.NextRun.Sub(now), 0))
+		}
+	}
+	return wait
+}
+
+// wakeScheduler 计划任务变化后让 RunSchedules 重新计算等待时间
+func (m *Manager) wakeScheduler() {
+	select {
+	case m.scheduleWake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Schedule) snapshot() *Schedule {
+	snapshot := *s
+	return &snapshot
+}
+
+func (m *Manager) saveScheduleLocked(s *Schedule) error {
+	if m.persister == nil {
+		return nil
+	}
+	return m.persister.SaveSchedule(s)
+}
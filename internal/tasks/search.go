@@ -0,0 +1,106 @@
+package tasks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// searchScanLimit 一次搜索最多读取的匹配段落数，按任务分组后再截取结果
+const searchScanLimit = 500
+
+// searchMatchesPerTask 每个任务最多返回的匹配段落数，MatchCount 仍然是全部匹配的段数
+const searchMatchesPerTask = 5
+
+// TranscriptMatch 转录中匹配搜索关键词的一段文本和它在视频中的时间（秒）
+type TranscriptMatch struct {
+	TaskID string  `json:"-"`
+	Start  float64 `json:"start"`
+	End    float64 `json:"end"`
+	Text   string  `json:"text"`
+	// Score 语义搜索时与搜索内容的相似度（-1–1，越大越相关），关键词搜索时为 0
+	Score float64 `json:"score,omitempty"`
+}
+
+// SearchResult 一个转录任务的搜索结果
+type SearchResult struct {
+	TaskID     string            `json:"task_id"`
+	PipelineID string            `json:"pipeline_id,omitempty"`
+	VideoPath  string            `json:"video_path"`
+	TXTPath    string            `json:"txt_path,omitempty"`
+	Matches    []TranscriptMatch `json:"matches"`
+	MatchCount int               `json:"match_count"`
+}
+
+// SearchTranscripts 在所有已完成转录的结构化结果中搜索关键词（空白分隔，需要全部出现在同一段中），
+// 按转录任务分组返回最多 limit 个任务，最新的转录在前。workspace 不为空时只搜索该工作区的任务
+func (m *Manager) SearchTranscripts(query, workspace string, limit int) ([]SearchResult, error) {
+	keywords := strings.Fields(query)
+	if len(keywords) == 0 {
+		return nil, fmt.Errorf("搜索关键词不能为空")
+	}
+	if m.persister == nil {
+		return nil, fmt.Errorf("没有配置数据库，无法搜索转录")
+	}
+	matches, err := m.persister.SearchTranscripts(keywords, workspace, searchScanLimit)
+	if err != nil {
+		return nil, fmt.Errorf("搜索转录失败: %v", err)
+	}
+
+	results := m.groupMatches(matches, workspace, limit)
+	// 同一任务的段落按时间顺序返回
+	for _, r := range results {
+		sort.Slice(r.Matches, func(a, b int) bool { return r.Matches[a].Start < r.Matches[b].Start })
+	}
+	return results, nil
+}
+
+// groupMatches 按转录任务分组搜索到的段落，保持 matches 中任务第一次出现的顺序，最多返回 limit 个任务，
+// 每个任务最多 searchMatchesPerTask 段。已经删除或不属于 workspace 的任务跳过
+func (m *Manager) groupMatches(matches []TranscriptMatch, workspace string, limit int) []SearchResult {
+	results := []SearchResult{}
+	index := map[string]int{}
+	skipped := map[string]bool{}
+	for _, match := range matches {
+		if skipped[match.TaskID] {
+			continue
+		}
+		i, ok := index[match.TaskID]
+		if !ok {
+			if len(results) >= limit {
+				continue
+			}
+			task, err := m.Transcribe(match.TaskID)
+			if err != nil || (workspace != "" && task.Workspace != workspace) {
+				skipped[match.TaskID] = true
+				continue
+			}
+			i = len(results)
+			index[match.TaskID] = i
+			results = append(results, SearchResult{
+				TaskID:     task.ID,
+				PipelineID: m.pipelineOf(task.ID),
+				VideoPath:  task.VideoPath,
+				TXTPath:    task.TXTPath,
+			})
+		}
+		r := &results[i]
+		r.MatchCount++
+		if len(r.Matches) < searchMatchesPerTask {
+			r.Matches = append(r.Matches, match)
+		}
+	}
+	return results
+}
+
+// pipelineOf 返回创建了转录任务的流水线任务 ID，不是流水线创建的转录任务返回空
+func (m *Manager) pipelineOf(transcribeID string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, p := range m.pipelines {
+		if p.TranscribeID == transcribeID {
+			return p.ID
+		}
+	}
+	return ""
+}
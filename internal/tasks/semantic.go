@@ -0,0 +1,149 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"zhihu-downloader/internal/embedding"
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/transcriber"
+)
+
+// semanticScanLimit 语义搜索一次最多读取的片段数，按任务分组后再截取结果
+const semanticScanLimit = 200
+
+// EmbeddedChunk 转录中相邻几段合并成的片段和它的向量
+type EmbeddedChunk struct {
+	Start  float64
+	End    float64
+	Text   string
+	Vector []float32
+}
+
+// SemanticSearch 在所有已计算向量的转录中查找与 query 意思最接近的片段，不要求包含相同的词。
+// 按转录任务分组返回最多 limit 个任务，最相关的任务在前，每个任务的片段按相关度排列。
+// workspace 不为空时只搜索该工作区的任务
+func (m *Manager) SemanticSearch(ctx context.Context, query, workspace string, limit int) ([]SearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, errcode.New(errcode.InvalidArgument, "搜索内容不能为空")
+	}
+	if !embedding.Enabled() {
+		return nil, errcode.New(errcode.Unavailable, "没有配置向量计算（embedding.provider），无法语义搜索")
+	}
+	if m.persister == nil {
+		return nil, errcode.New(errcode.Unavailable, "没有配置数据库，无法搜索转录")
+	}
+	vectors, err := embedding.Embed(ctx, []string{query})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, errcode.Newf(errcode.Upstream, "计算搜索内容的向量失败: %v", err)
+	}
+	matches, err := m.persister.SearchEmbeddings(embedding.Model(), vectors[0], workspace, semanticScanLimit)
+	if err != nil {
+		return nil, fmt.Errorf("搜索转录失败: %v", err)
+	}
+
+	return m.groupMatches(matches, workspace, limit), nil
+}
+
+// IndexEmbeddings 为还没有当前模型向量的已完成转录计算向量（例如启用语义搜索或更换模型之前的转录），
+// 直到全部完成或 ctx 结束。没有配置向量计算或数据库时立即返回
+func (m *Manager) IndexEmbeddings(ctx context.Context) {
+	if !embedding.Enabled() || m.persister == nil {
+		return
+	}
+	model := embedding.Model()
+	ids, err := m.persister.UnembeddedTranscripts(model)
+	if err != nil {
+		slog.Warn("查找需要计算向量的转录失败", "error", err)
+		return
+	}
+	indexed := 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return
+		}
+		t, err := m.persister.LookupTranscript(id)
+		if err != nil || t == nil {
+			continue
+		}
+		if err := m.embedTranscript(ctx, id, t); err != nil {
+			slog.Warn("计算转录的向量失败，稍后重启时再试", "task_id", id, "error", err)
+			return
+		}
+		indexed++
+	}
+	if indexed > 0 {
+		slog.Info("已为转录计算向量", "count", indexed, "model", model)
+	}
+}
+
+// embedTranscriptAsync 转录完成后在后台计算向量，失败只记录在任务日志中
+func (m *Manager) embedTranscriptAsync(taskID string, t *transcriber.Transcript) {
+	if !embedding.Enabled() || m.persister == nil || t == nil {
+		return
+	}
+	go func() {
+		ctx := logging.WithTask(context.Background(), taskID, "embedding")
+		if err := m.embedTranscript(ctx, taskID, t); err != nil {
+			logging.FromContext(ctx).Warn("计算转录的向量失败，语义搜索暂时找不到该转录", "error", err)
+		}
+	}()
+}
+
+// embedTranscript 把转录分成片段计算向量并保存
+func (m *Manager) embedTranscript(ctx context.Context, taskID string, t *transcriber.Transcript) error {
+	chunks := chunkSegments(t.Segments, embedding.ChunkChars())
+	if len(chunks) > 0 {
+		texts := make([]string, len(chunks))
+		for i, c := range chunks {
+			texts[i] = c.Text
+		}
+		vectors, err := embedding.Embed(ctx, texts)
+		if err != nil {
+			return err
+		}
+		for i := range chunks {
+			chunks[i].Vector = vectors[i]
+		}
+	}
+	// 没有文本的转录也保存（空的）记录，避免每次启动都重新检查
+	return m.persister.SaveEmbeddings(taskID, embedding.Model(), chunks)
+}
+
+// chunkSegments 按时间顺序把相邻的段落合并为不超过 maxChars 个字的片段，单独超过的段落自成一个片段
+func chunkSegments(segments []transcriber.Segment, maxChars int) []EmbeddedChunk {
+	var chunks []EmbeddedChunk
+	var cur *EmbeddedChunk
+	for _, s := range segments {
+		text := strings.TrimSpace(s.Text)
+		if text == "" {
+			continue
+		}
+		if cur != nil && utf8.RuneCountInString(cur.Text)+utf8.RuneCountInString(text) <= maxChars {
+			cur.Text = joinText(cur.Text, text)
+			cur.End = s.End
+			continue
+		}
+		chunks = append(chunks, EmbeddedChunk{Start: s.Start, End: s.End, Text: text})
+		cur = &chunks[len(chunks)-1]
+	}
+	return chunks
+}
+
+// joinText 拼接两段文本，英文等用空格分隔的语言之间加空格，中文直接连接
+func joinText(a, b string) string {
+	last, _ := utf8.DecodeLastRuneInString(a)
+	first, _ := utf8.DecodeRuneInString(b)
+	if last < utf8.RuneSelf && first < utf8.RuneSelf {
+		return a + " " + b
+	}
+	return a + b
+}
@@ -0,0 +1,263 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"zhihu-downloader/internal/errcode"
+)
+
+// SharedStore 多个进程（HTTP 网关、MCP 服务）共用的任务存储。
+// Manager 内存中的任务只有本进程正在执行的是最新的，其他任务在读取时从共享存储同步，
+// 因此一个进程创建的任务可以在另一个进程中查询、取消、重试和删除
+type SharedStore interface {
+	Download(id string) (*DownloadTask, error)
+	Transcribe(id string) (*TranscribeTask, error)
+	Pipeline(id string) (*PipelineTask, error)
+	Collection(id string) (*CollectionTask, error)
+	Downloads() ([]*DownloadTask, error)
+	Transcribes() ([]*TranscribeTask, error)
+	Pipelines() ([]*PipelineTask, error)
+	Collections() ([]*CollectionTask, error)
+	// RunningElsewhere 任务是否由其他仍在运行的进程执行
+	RunningElsewhere(id string) bool
+	// RequestCancel 请求执行任务的进程取消任务
+	RequestCancel(id string) error
+	// CancelRequests 取出其他进程请求取消的本进程任务
+	CancelRequests() ([]string, error)
+}
+
+// sharedSyncInterval 处理取消请求、通知订阅了其他进程任务的客户端的间隔
+const sharedSyncInterval = time.Second
+
+// WithSharedStore 设置共享任务存储，通常与 WithPersister 使用同一个数据库
+func WithSharedStore(s SharedStore) Option {
+	return func(m *Manager) { m.shared = s }
+}
+
+// IDPrefix 任务 ID 的前缀：dl / tr / pl / cl / tb
+func IDPrefix(kind Kind) string {
+	switch kind {
+	case KindDownload:
+		return "dl"
+	case KindPipeline:
+		return "pl"
+	case KindCollection:
+		return "cl"
+	case KindBatch:
+		return "tb"
+	}
+	return "tr"
+}
+
+// RunSharedSync 定期取消其他进程请求取消的任务，并通知订阅了其他进程任务的客户端重新读取进度，
+// 直到 ctx 结束。没有设置共享存储时立即返回
+func (m *Manager) RunSharedSync(ctx context.Context) {
+	if m.shared == nil {
+		return
+	}
+	ticker := time.NewTicker(sharedSyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ids, err := m.shared.CancelRequests()
+		if err != nil {
+			slog.Warn("读取取消请求失败", "error", err)
+		}
+		for _, id := range ids {
+			if m.Cancel(id) {
+				slog.Info("其他进程请求取消任务", "task_id", id)
+			}
+		}
+
+		m.mu.Lock()
+		for id := range m.watchers {
+			if !m.localLocked(id) {
+				m.notifyLocked(id)
+			}
+		}
+		m.mu.Unlock()
+	}
+}
+
+// localLocked 任务是否在本进程中执行或排队
+func (m *Manager) localLocked(id string) bool {
+	return m.active[id] || m.cancels[id] != nil
+}
+
+// elsewhereLocked 任务是否正在其他进程中执行
+func (m *Manager) elsewhereLocked(id string, status Status) bool {
+	return m.shared != nil && !status.Terminal() && !m.localLocked(id) && m.shared.RunningElsewhere(id)
+}
+
+// statusLocked 返回任意类型任务的状态
+func (m *Manager) statusLocked(id string) (Status, bool) {
+	if t, ok := m.downloads[id]; ok {
+		return t.Status, true
+	}
+	if t, ok := m.transcribes[id]; ok {
+		return t.Status, true
+	}
+	if t, ok := m.pipelines[id]; ok {
+		return t.Status, true
+	}
+	if t, ok := m.collections[id]; ok {
+		return t.Status, true
+	}
+	return "", false
+}
+
+// cancelElsewhereLocked 请求正在执行任务的其他进程取消任务
+func (m *Manager) cancelElsewhereLocked(id string) bool {
+	status, ok := m.statusLocked(id)
+	if !ok || !m.elsewhereLocked(id, status) {
+		return false
+	}
+	if err := m.shared.RequestCancel(id); err != nil {
+		slog.Warn("请求其他进程取消任务失败", "task_id", id, "error", err)
+		return false
+	}
+	return true
+}
+
+// refreshTask 用共享存储中的记录更新不在本进程执行的任务，其他进程删除的任务同时从内存中删除
+func refreshTask[T any](m *Manager, list map[string]*T, id string, load func(string) (*T, error), updated func(*T) time.Time) {
+	m.mu.RLock()
+	local := m.localLocked(id)
+	m.mu.RUnlock()
+	if local {
+		return
+	}
+
+	start := time.Now()
+	stored, err := load(id)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		slog.Warn("读取任务失败", "task_id", id, "error", err)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.localLocked(id) {
+		return
+	}
+	current, ok := list[id]
+	switch {
+	case stored == nil:
+		if ok && updated(current).Before(start) {
+			delete(list, id)
+		}
+	case !ok || !updated(stored).Before(updated(current)):
+		list[id] = stored
+	}
+}
+
+// refreshList 用共享存储中的记录更新所有不在本进程执行的任务
+func refreshList[T any](m *Manager, list map[string]*T, load func() ([]*T, error), key func(*T) (string, time.Time)) {
+	start := time.Now()
+	stored, err := load()
+	if err != nil {
+		slog.Warn("读取任务失败", "error", err)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seen := make(map[string]bool, len(stored))
+	for _, t := range stored {
+		id, updated := key(t)
+		seen[id] = true
+		if m.localLocked(id) {
+			continue
+		}
+		if current, ok := list[id]; ok {
+			if _, u := key(current); updated.Before(u) {
+				continue
+			}
+		}
+		list[id] = t
+	}
+	for id, t := range list {
+		// 读取之后才创建或更新的任务还不在读取结果中
+		if _, updated := key(t); !seen[id] && !m.localLocked(id) && updated.Before(start) {
+			delete(list, id)
+		}
+	}
+}
+
+func (m *Manager) refreshDownload(id string) {
+	if m.shared == nil {
+		return
+	}
+	refreshTask(m, m.downloads, id, m.shared.Download, func(t *DownloadTask) time.Time { return t.UpdatedAt })
+}
+
+func (m *Manager) refreshTranscribe(id string) {
+	if m.shared == nil {
+		return
+	}
+	refreshTask(m, m.transcribes, id, m.shared.Transcribe, func(t *TranscribeTask) time.Time { return t.UpdatedAt })
+}
+
+func (m *Manager) refreshPipeline(id string) {
+	if m.shared == nil {
+		return
+	}
+	refreshTask(m, m.pipelines, id, m.shared.Pipeline, func(t *PipelineTask) time.Time { return t.UpdatedAt })
+}
+
+func (m *Manager) refreshCollection(id string) {
+	if m.shared == nil {
+		return
+	}
+	refreshTask(m, m.collections, id, m.shared.Collection, func(t *CollectionTask) time.Time { return t.UpdatedAt })
+}
+
+// refreshAny 同步任意类型的任务，用于只知道 ID 的操作（重试、删除）
+func (m *Manager) refreshAny(id string) {
+	m.refreshDownload(id)
+	m.refreshTranscribe(id)
+	m.refreshPipeline(id)
+	m.refreshCollection(id)
+}
+
+func (m *Manager) refreshDownloads() {
+	if m.shared == nil {
+		return
+	}
+	refreshList(m, m.downloads, m.shared.Downloads, func(t *DownloadTask) (string, time.Time) { return t.ID, t.UpdatedAt })
+}
+
+func (m *Manager) refreshTranscribes() {
+	if m.shared == nil {
+		return
+	}
+	refreshList(m, m.transcribes, m.shared.Transcribes, func(t *TranscribeTask) (string, time.Time) { return t.ID, t.UpdatedAt })
+}
+
+func (m *Manager) refreshPipelines() {
+	if m.shared == nil {
+		return
+	}
+	refreshList(m, m.pipelines, m.shared.Pipelines, func(t *PipelineTask) (string, time.Time) { return t.ID, t.UpdatedAt })
+}
+
+func (m *Manager) refreshCollections() {
+	if m.shared == nil {
+		return
+	}
+	refreshList(m, m.collections, m.shared.Collections, func(t *CollectionTask) (string, time.Time) { return t.ID, t.UpdatedAt })
+}
+
+// failure 返回任务记录中的错误说明、错误码和细节，无法归类的错误使用 fallback
+func failure(err error, fallback errcode.Code) (string, errcode.Code, string) {
+	info := errcode.Describe(err, fallback)
+	return info.Message, info.Code, info.Detail
+}
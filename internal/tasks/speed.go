@@ -0,0 +1,86 @@
+package tasks
+
+import "time"
+
+const (
+	// speedSampleInterval 速度采样的最短间隔
+	speedSampleInterval = time.Second
+	// speedHistorySize SpeedHistory 保留的样本数，按采样间隔约为最近一分钟
+	speedHistorySize = 60
+)
+
+// speedTracker 按已下载的字节数记录每个采样间隔内的速度（字节/秒），用于进度中的 speed_history，
+// 并计算整个下载的平均速度和峰值。只在任务自己的 goroutine 中使用
+type speedTracker struct {
+	at    time.Time
+	bytes int64
+	// samples 最近的样本，最早的在前
+	samples []int64
+	peak    int64
+	// elapsed / total 已采样的时间和这段时间内下载的字节数，用于平均速度
+	elapsed time.Duration
+	total   int64
+	// lastAt / lastBytes 最近一次 update，平均速度包括最后不足一个采样间隔的部分
+	lastAt    time.Time
+	lastBytes int64
+}
+
+// update 记录已下载的字节数，距上次采样超过 speedSampleInterval 时添加一个样本并返回 true
+func (s *speedTracker) update(bytes int64) bool {
+	now := time.Now()
+	if s.at.IsZero() || bytes < s.bytes {
+		// 第一次采样，或自动重试后从头下载：之前不足一个采样间隔的部分计入平均速度
+		s.elapsed += s.lastAt.Sub(s.at)
+		s.total += s.lastBytes - s.bytes
+		s.at, s.bytes = now, bytes
+		s.lastAt, s.lastBytes = now, bytes
+		return false
+	}
+	s.lastAt, s.lastBytes = now, bytes
+	dt := now.Sub(s.at)
+	if dt < speedSampleInterval {
+		return false
+	}
+	rate := int64(float64(bytes-s.bytes) / dt.Seconds())
+	s.samples = append(s.samples, rate)
+	if len(s.samples) > speedHistorySize {
+		s.samples = s.samples[len(s.samples)-speedHistorySize:]
+	}
+	s.peak = max(s.peak, rate)
+	s.elapsed += dt
+	s.total += bytes - s.bytes
+	s.at, s.bytes = now, bytes
+	return true
+}
+
+// seed 暂停后继续时接着之前的统计：之前下载的 bytes 字节按记录的平均速度折算为已用时间，
+// 之后的平均速度和峰值包括暂停之前的部分
+func (s *speedTracker) seed(avg, peak, bytes int64) {
+	s.peak = peak
+	if avg > 0 && bytes > 0 {
+		s.total = bytes
+		s.elapsed = time.Duration(float64(bytes) / float64(avg) * float64(time.Second))
+	}
+}
+
+// history 返回样本的副本，任务中保存的切片不会被之后的采样修改
+func (s *speedTracker) history() []int64 {
+	if len(s.samples) == 0 {
+		return nil
+	}
+	return append([]int64(nil), s.samples...)
+}
+
+// average 返回平均速度（字节/秒），无法计算时为 0
+func (s *speedTracker) average() int64 {
+	elapsed := s.elapsed + s.lastAt.Sub(s.at)
+	if elapsed <= 0 {
+		return 0
+	}
+	return int64(float64(s.total+s.lastBytes-s.bytes) / elapsed.Seconds())
+}
+
+// peakSpeed 返回最高的速度（字节/秒），下载太快没有样本时为平均速度
+func (s *speedTracker) peakSpeed() int64 {
+	return max(s.peak, s.average())
+}
@@ -0,0 +1,37 @@
+package tasks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSpeedTrackerSeed(t *testing.T) {
+	var s speedTracker
+	s.seed(100, 500, 1000)
+	if got := s.average(); got != 100 {
+		t.Fatalf("average = %d, want 100", got)
+	}
+	if got := s.peakSpeed(); got != 500 {
+		t.Fatalf("peakSpeed = %d, want 500", got)
+	}
+
+	// 继续后第一次采样只确定起点，之后的字节计入平均速度：1000 字节 10 秒 + 1000 字节约 1 秒
+	s.update(1000)
+	s.at = s.at.Add(-time.Second)
+	s.lastAt = s.at
+	s.update(2000)
+	if got := s.average(); got < 150 || got > 200 {
+		t.Fatalf("average = %d, want about 180", got)
+	}
+	if got := s.peakSpeed(); got < 900 {
+		t.Fatalf("peakSpeed = %d, want about 1000", got)
+	}
+}
+
+func TestSpeedTrackerSeedEmpty(t *testing.T) {
+	var s speedTracker
+	s.seed(0, 0, 0)
+	if s.average() != 0 || s.peakSpeed() != 0 {
+		t.Fatalf("没有之前的统计时应该为 0: %d %d", s.average(), s.peakSpeed())
+	}
+}
@@ -0,0 +1,92 @@
+package tasks
+
+import (
+	"fmt"
+	"time"
+
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/hls"
+)
+
+// BusiestDaysLimit 统计中列出的下载最多的天数
+const BusiestDaysLimit = 10
+
+// Stats 长期归档的汇总统计，由数据库查询得到。包括流水线和合集中的下载和转录
+type Stats struct {
+	// Workspace 只统计这个工作区的任务，为空时统计所有任务
+	Workspace      string          `json:"workspace,omitempty"`
+	Downloads      DownloadStats   `json:"downloads"`
+	Transcriptions TranscribeStats `json:"transcriptions"`
+	// BusiestDays 完成下载最多的几天，按下载数从多到少排列
+	BusiestDays []DayStats `json:"busiest_days"`
+	GeneratedAt time.Time  `json:"generated_at"`
+}
+
+// DownloadStats 下载任务的统计
+type DownloadStats struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+	Cancelled int `json:"cancelled"`
+	// SuccessRate 完成数 / (完成数 + 失败数)，没有结束的任务时为 0
+	SuccessRate float64 `json:"success_rate"`
+	// TotalBytes 已完成下载的文件大小之和（字节）
+	TotalBytes int64 `json:"total_bytes"`
+	// AverageSpeed 已完成下载的平均速度（字节/秒）：总大小 / 总耗时，AverageSpeedText 例如 "2.3 MB/s"
+	AverageSpeed     float64 `json:"average_speed"`
+	AverageSpeedText string  `json:"average_speed_text,omitempty"`
+}
+
+// TranscribeStats 转录任务的统计
+type TranscribeStats struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+	Cancelled int `json:"cancelled"`
+	// SuccessRate 完成数 / (完成数 + 失败数)，没有结束的任务时为 0
+	SuccessRate float64 `json:"success_rate"`
+	// TranscriptHours 已完成转录的音频总时长（小时），按每个转录最后一段的结束时间计算
+	TranscriptHours float64 `json:"transcript_hours"`
+}
+
+// DayStats 一天内完成的下载（按服务所在时区的日期）
+type DayStats struct {
+	Date      string `json:"date"`
+	Downloads int    `json:"downloads"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// successRate 完成数占已结束（完成或失败）任务的比例，保留四位小数
+func successRate(completed, failed int) float64 {
+	if completed+failed == 0 {
+		return 0
+	}
+	return float64(completed*10000/(completed+failed)) / 10000
+}
+
+// fill 按持久化存储查询到的计数计算成功率和平均速度的文字说明
+func (s *Stats) fill() {
+	s.Downloads.SuccessRate = successRate(s.Downloads.Completed, s.Downloads.Failed)
+	s.Transcriptions.SuccessRate = successRate(s.Transcriptions.Completed, s.Transcriptions.Failed)
+	if s.Downloads.AverageSpeed > 0 {
+		s.Downloads.AverageSpeedText = hls.FormatSpeed(s.Downloads.AverageSpeed)
+	}
+	if s.BusiestDays == nil {
+		s.BusiestDays = []DayStats{}
+	}
+	s.GeneratedAt = time.Now()
+}
+
+// Stats 返回 workspace 的汇总统计（为空时统计所有任务），需要数据库
+func (m *Manager) Stats(workspace string) (*Stats, error) {
+	if m.persister == nil {
+		return nil, errcode.New(errcode.Unavailable, "没有配置数据库，无法统计")
+	}
+	stats, err := m.persister.Stats(workspace)
+	if err != nil {
+		return nil, fmt.Errorf("统计失败: %v", err)
+	}
+	stats.Workspace = workspace
+	stats.fill()
+	return stats, nil
+}
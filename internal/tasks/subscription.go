@@ -0,0 +1,464 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/transcriber"
+	"zhihu-downloader/internal/zhihu"
+)
+
+const (
+	// DefaultSubscriptionInterval 订阅默认的检查间隔（分钟），MinSubscriptionInterval 最短的检查间隔
+	DefaultSubscriptionInterval = 360
+	MinSubscriptionInterval     = 10
+	// DefaultSubscriptionLimit 每次检查最多列出的最新视频数
+	DefaultSubscriptionLimit = 30
+	// subscriptionPollInterval RunSubscriptions 查找到期订阅的间隔
+	subscriptionPollInterval = time.Minute
+)
+
+// ErrSubscriptionNotFound 订阅不存在
+var ErrSubscriptionNotFound = errcode.New(errcode.NotFound, "订阅不存在")
+
+// peopleURLRe 知乎用户主页，订阅时按用户发布的视频处理
+var peopleURLRe = regexp.MustCompile(`zhihu\.com/(people|org)/([\w-]+)/?(?:[?#].*)?$`)
+
+// Subscription 订阅知乎用户或专栏：定期列出最新的视频，为还没有下载过的视频创建下载任务
+// （Transcribe 时创建下载并转录的流水线）。检查由 RunSubscriptions 执行
+type Subscription struct {
+	ID string `json:"id"`
+	// URL 用户的视频页 / 回答页或专栏链接，用户主页按视频页处理
+	URL  string               `json:"url"`
+	Type zhihu.CollectionType `json:"type"`
+	// Title 用户或专栏的名称，第一次检查后才有
+	Title            string `json:"title,omitempty"`
+	Quality          string `json:"quality,omitempty"`
+	Backend          string `json:"backend,omitempty"`
+	OutputDir        string `json:"output_dir,omitempty"`
+	FilenameTemplate string `json:"filename_template,omitempty"`
+	// Limit 每次检查最多列出的最新视频数，默认 DefaultSubscriptionLimit
+	Limit int `json:"limit,omitempty"`
+	// IntervalMinutes 检查间隔（分钟），默认 DefaultSubscriptionInterval，最短 MinSubscriptionInterval
+	IntervalMinutes int `json:"interval_minutes"`
+	// Transcribe 下载后自动转录，Language / Model 转录使用的语言和模型
+	Transcribe bool   `json:"transcribe,omitempty"`
+	Language   string `json:"language,omitempty"`
+	Model      string `json:"model,omitempty"`
+	// SkipExisting 第一次检查时只记录已有的视频，不下载，之后只下载新发布的视频
+	SkipExisting bool `json:"skip_existing,omitempty"`
+	Enabled      bool `json:"enabled"`
+	// Workspace 订阅所属的工作区，创建的任务也属于这个工作区
+	Workspace string `json:"workspace,omitempty"`
+	// LastCheck 上次检查的时间，NextCheck 下次检查的时间（停用时为空）
+	LastCheck *time.Time `json:"last_check,omitempty"`
+	NextCheck *time.Time `json:"next_check,omitempty"`
+	// LastError 上次检查失败的原因，LastNew 上次检查创建的任务数，Downloaded 累计创建的任务数
+	LastError  string    `json:"last_error,omitempty"`
+	LastNew    int       `json:"last_new"`
+	Downloaded int       `json:"downloaded"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// SubscriptionCheck 一次检查的结果
+type SubscriptionCheck struct {
+	SubscriptionID string `json:"subscription_id"`
+	// Found 列出的视频数，TaskIDs 为新视频创建的任务
+	Found   int      `json:"found"`
+	TaskIDs []string `json:"task_ids"`
+	// Errors 创建任务失败的视频及原因，下次检查时重试
+	Errors    []string  `json:"errors,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// CreateSubscription 校验并保存订阅，下次 RunSubscriptions 轮询时立即检查一次
+func (m *Manager) CreateSubscription(s Subscription) (*Subscription, error) {
+	now := time.Now()
+	if err := m.prepareSubscription(&s); err != nil {
+		return nil, err
+	}
+	s.ID = uuid.New().String()
+	s.LastCheck, s.LastError, s.LastNew, s.Downloaded = nil, "", 0, 0
+	s.NextCheck = nil
+	if s.Enabled {
+		s.NextCheck = &now
+	}
+	s.CreatedAt = now
+	s.UpdatedAt = now
+	if err := m.saveSubscription(&s); err != nil {
+		return nil, fmt.Errorf("保存订阅失败: %v", err)
+	}
+	m.wakeSubscriptions()
+	slog.Info("已创建订阅", "subscription_id", s.ID, "url", s.URL, "interval_minutes", s.IntervalMinutes)
+	return &s, nil
+}
+
+// UpdateSubscription 用 s 替换订阅的设置，检查记录保留。重新启用或修改间隔后按新的间隔计算下次检查时间
+func (m *Manager) UpdateSubscription(id string, s Subscription) (*Subscription, error) {
+	cur, err := m.Subscription(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.prepareSubscription(&s); err != nil {
+		return nil, err
+	}
+	s.ID = id
+	s.Title, s.LastCheck, s.LastError, s.LastNew, s.Downloaded = cur.Title, cur.LastCheck, cur.LastError, cur.LastNew, cur.Downloaded
+	if s.URL != cur.URL {
+		s.Title = ""
+	}
+	s.NextCheck = s.nextCheck(time.Now())
+	s.CreatedAt = cur.CreatedAt
+	s.UpdatedAt = time.Now()
+	if err := m.saveSubscription(&s); err != nil {
+		return nil, fmt.Errorf("保存订阅失败: %v", err)
+	}
+	m.wakeSubscriptions()
+	return &s, nil
+}
+
+// DeleteSubscription 删除订阅及其视频记录，已创建的任务不受影响
+func (m *Manager) DeleteSubscription(id string) error {
+	if _, err := m.Subscription(id); err != nil {
+		return err
+	}
+	if m.persister != nil {
+		if err := m.persister.DeleteSubscription(id); err != nil {
+			return fmt.Errorf("删除订阅失败: %v", err)
+		}
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.subscriptions, id)
+	delete(m.subscriptionItems, id)
+	return nil
+}
+
+// Subscription 返回订阅的快照
+func (m *Manager) Subscription(id string) (*Subscription, error) {
+	var s *Subscription
+	if m.persister != nil {
+		var err error
+		if s, err = m.persister.Subscription(id); err != nil {
+			return nil, err
+		}
+	} else {
+		m.mu.RLock()
+		if cur, ok := m.subscriptions[id]; ok {
+			snapshot := *cur
+			s = &snapshot
+		}
+		m.mu.RUnlock()
+	}
+	if s == nil {
+		return nil, ErrSubscriptionNotFound
+	}
+	return s, nil
+}
+
+// Subscriptions 返回所有订阅（按创建时间倒序）
+func (m *Manager) Subscriptions() ([]*Subscription, error) {
+	var list []*Subscription
+	if m.persister != nil {
+		var err error
+		if list, err = m.persister.Subscriptions(); err != nil {
+			return nil, err
+		}
+	} else {
+		m.mu.RLock()
+		for _, s := range m.subscriptions {
+			snapshot := *s
+			list = append(list, &snapshot)
+		}
+		m.mu.RUnlock()
+	}
+	if list == nil {
+		list = []*Subscription{}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list, nil
+}
+
+// prepareSubscription 校验链接和参数，补全默认值
+func (m *Manager) prepareSubscription(s *Subscription) error {
+	if s.URL == "" {
+		return errcode.New(errcode.URLInvalid, "URL 必填")
+	}
+	if p := peopleURLRe.FindStringSubmatch(s.URL); p != nil {
+		s.URL = fmt.Sprintf("https://www.zhihu.com/%s/%s/zvideos", p[1], p[2])
+	}
+	typ, _, err := zhihu.ParseCollectionURL(s.URL)
+	if err != nil {
+		return errcode.Newf(errcode.URLInvalid, "订阅只支持知乎用户主页、用户的视频 / 回答页或专栏链接: %s", s.URL)
+	}
+	switch typ {
+	case zhihu.TypeUserVideos, zhihu.TypeUserAnswers, zhihu.TypeColumn:
+	default:
+		return errcode.Newf(errcode.URLInvalid, "订阅只支持知乎用户主页、用户的视频 / 回答页或专栏链接: %s", s.URL)
+	}
+	s.Type = typ
+
+	quality, err := downloader.NormalizeQuality(s.Quality)
+	if err != nil {
+		return err
+	}
+	s.Quality = quality
+	if _, err := downloader.ResolveBackend(s.URL, s.Backend); err != nil {
+		return err
+	}
+	if err := downloader.ValidateFilenameTemplate(s.FilenameTemplate); err != nil {
+		return err
+	}
+	dir, err := m.WorkspaceOutputDir(s.Workspace, s.OutputDir)
+	if err != nil {
+		return err
+	}
+	s.OutputDir = dir
+	if s.Limit <= 0 {
+		s.Limit = DefaultSubscriptionLimit
+	}
+	s.Limit = min(s.Limit, zhihu.DefaultCollectionLimit)
+	switch {
+	case s.IntervalMinutes == 0:
+		s.IntervalMinutes = DefaultSubscriptionInterval
+	case s.IntervalMinutes < MinSubscriptionInterval:
+		return errcode.Newf(errcode.InvalidArgument, "interval_minutes 不能小于 %d", MinSubscriptionInterval)
+	}
+	if s.Transcribe {
+		model, err := transcriber.ResolveModel(s.Model)
+		if err != nil {
+			return err
+		}
+		s.Model = model
+	}
+	return nil
+}
+
+// nextCheck 按上次检查的时间和间隔计算下次检查时间，没有检查过时立即检查，停用时为空
+func (s *Subscription) nextCheck(now time.Time) *time.Time {
+	if !s.Enabled {
+		return nil
+	}
+	next := now
+	if s.LastCheck != nil {
+		next = s.LastCheck.Add(time.Duration(s.IntervalMinutes) * time.Minute)
+	}
+	return &next
+}
+
+// RunSubscriptions 定期检查到期的订阅，直到 ctx 结束。订阅保存在数据库中时，
+// 其他服务（例如 MCP）创建的订阅也会在下次轮询时检查
+func (m *Manager) RunSubscriptions(ctx context.Context) {
+	ticker := time.NewTicker(subscriptionPollInterval)
+	defer ticker.Stop()
+	for {
+		list, err := m.Subscriptions()
+		if err != nil {
+			slog.Warn("读取订阅失败", "error", err)
+		}
+		now := time.Now()
+		for _, s := range list {
+			if ctx.Err() != nil {
+				return
+			}
+			if !s.Enabled || s.NextCheck == nil || s.NextCheck.After(now) {
+				continue
+			}
+			result, err := m.CheckSubscription(ctx, s.ID)
+			switch {
+			case err != nil:
+				slog.Warn("检查订阅失败", "subscription_id", s.ID, "url", s.URL, "error", err)
+			case len(result.TaskIDs) > 0:
+				slog.Info("订阅有新视频，已创建任务", "subscription_id", s.ID, "url", s.URL, "tasks", len(result.TaskIDs))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-m.subscriptionWake:
+		}
+	}
+}
+
+// CheckSubscription 立即检查一次订阅：列出最新的视频，为没有处理过的视频创建下载（或流水线）任务。
+// 同一订阅同时只能有一次检查
+func (m *Manager) CheckSubscription(ctx context.Context, id string) (*SubscriptionCheck, error) {
+	s, err := m.Subscription(id)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	if m.checking[id] {
+		m.mu.Unlock()
+		return nil, errcode.New(errcode.Conflict, "订阅正在检查中")
+	}
+	m.checking[id] = true
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.checking, id)
+		m.mu.Unlock()
+	}()
+
+	result := &SubscriptionCheck{SubscriptionID: id, TaskIDs: []string{}, CheckedAt: time.Now()}
+	c, err := zhihu.FetchCollection(ctx, s.URL, s.Limit, nil)
+	if err == nil {
+		err = m.checkItems(s, c, result)
+	}
+
+	// 检查期间订阅可能被修改或删除，只更新检查记录
+	cur, curErr := m.Subscription(id)
+	if curErr != nil {
+		return nil, curErr
+	}
+	cur.LastCheck = &result.CheckedAt
+	cur.LastError, cur.LastNew = "", len(result.TaskIDs)
+	if err != nil {
+		cur.LastError = err.Error()
+	}
+	cur.Downloaded += len(result.TaskIDs)
+	if c != nil && c.Title != "" {
+		cur.Title = c.Title
+	}
+	cur.NextCheck = cur.nextCheck(result.CheckedAt)
+	cur.UpdatedAt = time.Now()
+	if err := m.saveSubscription(cur); err != nil {
+		slog.Warn("保存订阅失败", "subscription_id", id, "error", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// checkItems 按发布时间从早到晚为没有处理过的视频创建任务，视频保存在以用户或专栏名称命名的子目录中。
+// 创建失败的视频不记录，下次检查时重试
+func (m *Manager) checkItems(s *Subscription, c *zhihu.Collection, result *SubscriptionCheck) error {
+	seen, err := m.seenItems(s.ID)
+	if err != nil {
+		return err
+	}
+	name := downloader.SanitizeFilename(c.Title)
+	if name == "" {
+		name = string(c.Type) + "_" + c.ID
+	}
+	dir := filepath.Join(s.OutputDir, name)
+	first := s.LastCheck == nil
+	result.Found = len(c.Items)
+
+	for i := len(c.Items) - 1; i >= 0; i-- {
+		item := c.Items[i]
+		if _, ok := seen[item.URL]; ok {
+			continue
+		}
+		seen[item.URL] = ""
+		taskID := ""
+		if !(first && s.SkipExisting) {
+			if taskID, err = m.startSubscribed(s, dir, item); err != nil {
+				result.Errors = append(result.Errors, item.URL+": "+err.Error())
+				continue
+			}
+			if taskID != "" {
+				result.TaskIDs = append(result.TaskIDs, taskID)
+			}
+		}
+		if err := m.saveSubscriptionItem(s.ID, item.URL, taskID); err != nil {
+			return fmt.Errorf("保存订阅记录失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// startSubscribed 为订阅中的一个视频创建下载或流水线任务，返回任务 ID；已经下载过时返回空
+func (m *Manager) startSubscribed(s *Subscription, dir string, item zhihu.CollectionItem) (string, error) {
+	req := downloader.Request{
+		URL:              item.URL,
+		Quality:          s.Quality,
+		OutputDir:        dir,
+		Backend:          s.Backend,
+		FilenameTemplate: s.FilenameTemplate,
+		Workspace:        s.Workspace,
+	}
+	// 回答和文章中嵌入的视频通常没有标题，用回答 / 文章的标题命名
+	if item.Source != "" && item.Title != "" && s.FilenameTemplate == "" {
+		req.Filename = downloader.UniqueFilename(dir, downloader.SanitizeFilename(item.Title), ".mp4")
+	}
+	if s.Transcribe {
+		if e := m.lookupDownloaded(downloadKey(item.URL, s.Quality, dir)); e != nil {
+			return "", nil
+		}
+		p, err := m.StartPipeline(req, transcriber.Request{Language: s.Language, Model: s.Model, Workspace: s.Workspace}, "")
+		if err != nil {
+			return "", err
+		}
+		return p.ID, nil
+	}
+	d, err := m.StartDownload(req)
+	if err != nil {
+		return "", err
+	}
+	if d.Cached {
+		return "", nil
+	}
+	return d.ID, nil
+}
+
+// saveSubscription 有持久化存储时写入数据库，否则保存在内存中
+func (m *Manager) saveSubscription(s *Subscription) error {
+	if m.persister != nil {
+		return m.persister.SaveSubscription(s)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	snapshot := *s
+	m.subscriptions[s.ID] = &snapshot
+	return nil
+}
+
+// seenItems 返回订阅已经处理过的视频链接到任务 ID 的映射（可以修改）
+func (m *Manager) seenItems(id string) (map[string]string, error) {
+	if m.persister != nil {
+		return m.persister.SubscriptionItems(id)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	items := make(map[string]string, len(m.subscriptionItems[id]))
+	for url, taskID := range m.subscriptionItems[id] {
+		items[url] = taskID
+	}
+	return items, nil
+}
+
+func (m *Manager) saveSubscriptionItem(id, url, taskID string) error {
+	if m.persister != nil {
+		return m.persister.SaveSubscriptionItem(id, url, taskID)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.subscriptionItems[id] == nil {
+		m.subscriptionItems[id] = map[string]string{}
+	}
+	m.subscriptionItems[id][url] = taskID
+	return nil
+}
+
+// wakeSubscriptions 订阅变化后让 RunSubscriptions 立即查找到期的订阅
+func (m *Manager) wakeSubscriptions() {
+	select {
+	case m.subscriptionWake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,74 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/media"
+)
+
+// hasSubtitleStage 流水线转录后是否需要把字幕封装或烧录进视频
+func hasSubtitleStage(t *PipelineTask) bool {
+	return t.SubtitleMode == media.SubtitleMux || t.SubtitleMode == media.SubtitleBurn
+}
+
+// pipelinePercentage 把转录阶段的进度（50–99%）换算为流水线进度：
+// 需要处理字幕时转录阶段对应 50–90%，字幕阶段对应 90–99%
+func pipelinePercentage(t *PipelineTask, pct int) int {
+	if hasSubtitleStage(t) {
+		return 50 + (pct-50)*40/49
+	}
+	return pct
+}
+
+// pipelineSubtitles 字幕阶段：把转录生成的 SRT 封装（mux）或烧录（burn）进视频，
+// 输出为视频旁边的 <文件名>.subtitled.mp4，原视频保持不变。重试时已生成的文件不会重新生成
+func (m *Manager) pipelineSubtitles(ctx context.Context, task *PipelineTask) error {
+	if !hasSubtitleStage(task) || (task.SubtitledPath != "" && fileExists(task.SubtitledPath)) {
+		return nil
+	}
+	if task.SRTPath == "" {
+		return fmt.Errorf("转录没有生成 SRT 字幕，无法处理字幕")
+	}
+
+	ctx = logging.WithStage(ctx, "subtitles")
+	logger := logging.FromContext(ctx)
+	out := media.SubtitledPath(task.FilePath)
+	stage, run := "封装字幕中", media.MuxSubtitles
+	if task.SubtitleMode == media.SubtitleBurn {
+		stage = "烧录字幕中"
+		run = func(ctx context.Context, video, srt, out, _ string, opts media.EncodeOptions, onProgress func(int)) error {
+			return media.BurnSubtitles(ctx, video, srt, out, opts, onProgress)
+		}
+	}
+	// ffmpeg 参数和硬件加速方式与下载子任务相同
+	var opts media.EncodeOptions
+	if d, err := m.Download(task.DownloadID); err == nil {
+		opts = media.EncodeOptions{HWAccel: d.HWAccel, Args: d.FFmpegArgs}
+	}
+	m.updatePipeline(task, func(t *PipelineTask) {
+		t.Stage = stage
+		t.Percentage = 90
+	})
+	logger.Info("开始处理字幕", "mode", task.SubtitleMode, "srt_path", task.SRTPath)
+
+	language := task.Language
+	if task.DetectedLanguage != "" {
+		language = task.DetectedLanguage
+	}
+	err := run(ctx, task.FilePath, task.SRTPath, out, language, opts, func(pct int) {
+		m.updatePipeline(task, func(t *PipelineTask) {
+			t.Stage = fmt.Sprintf("%s %d%%", stage, pct)
+			t.Percentage = 90 + pct*9/100
+		})
+	})
+	if err != nil {
+		return err
+	}
+	m.updatePipeline(task, func(t *PipelineTask) {
+		t.SubtitledPath = out
+	})
+	logger.Info("字幕处理完成", "path", out)
+	return nil
+}
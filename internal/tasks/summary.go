@@ -0,0 +1,56 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/summarizer"
+)
+
+// Summarize 为已完成的转录或流水线任务生成摘要，并记录到任务的 summary_path
+func (m *Manager) Summarize(ctx context.Context, id string) (string, error) {
+	m.mu.RLock()
+	var txtPath, transcribeID string
+	pipeline, isPipeline := m.pipelines[id]
+	transcribe, isTranscribe := m.transcribes[id]
+	switch {
+	case isTranscribe:
+		txtPath, transcribeID = transcribe.TXTPath, transcribe.ID
+		if transcribe.Status != StatusCompleted {
+			txtPath = ""
+		}
+	case isPipeline:
+		txtPath, transcribeID = pipeline.TXTPath, pipeline.TranscribeID
+		if pipeline.Status != StatusCompleted {
+			txtPath = ""
+		}
+	}
+	m.mu.RUnlock()
+
+	if !isTranscribe && !isPipeline {
+		return "", ErrNotFound
+	}
+	if txtPath == "" {
+		return "", fmt.Errorf("任务 %s 还没有完成转录", id)
+	}
+
+	path, err := summarizer.Summarize(logging.WithTask(ctx, id, "summarize"), txtPath)
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if t, ok := m.transcribes[transcribeID]; ok {
+		t.SummaryPath = path
+		m.saveTranscribeLocked(t)
+		m.notifyLocked(t.ID)
+	}
+	if isPipeline {
+		pipeline.SummaryPath = path
+		m.savePipelineLocked(pipeline)
+		m.notifyLocked(pipeline.ID)
+	}
+	return path, nil
+}
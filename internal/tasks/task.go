@@ -0,0 +1,305 @@
+// Package tasks 维护下载/转录任务的状态，供 HTTP 网关和 MCP 服务共用。
+package tasks
+
+import (
+	"time"
+
+	"zhihu-downloader/internal/errcode"
+)
+
+// Status 任务状态
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusQueued  Status = "queued"
+	// StatusPaused 下载已暂停，保留已下载的部分，可以继续
+	StatusPaused          Status = "paused"
+	StatusDownloading     Status = "downloading"
+	StatusTranscoding     Status = "transcoding"
+	StatusExtractingAudio Status = "extracting_audio"
+	StatusTranscribing    Status = "transcribing"
+	StatusCompleted       Status = "completed"
+	StatusFailed          Status = "failed"
+	StatusCancelled       Status = "cancelled"
+	// StatusInterrupted 进程退出时任务仍在执行，可以重试
+	StatusInterrupted Status = "interrupted"
+)
+
+// Terminal 判断任务是否已结束
+func (s Status) Terminal() bool {
+	return s == StatusCompleted || s == StatusFailed || s == StatusCancelled || s == StatusInterrupted
+}
+
+// Retryable 判断任务结束后是否可以重试
+func (s Status) Retryable() bool {
+	return s == StatusFailed || s == StatusCancelled || s == StatusInterrupted
+}
+
+// Kind 任务类型
+type Kind string
+
+const (
+	KindDownload   Kind = "download"
+	KindTranscribe Kind = "transcribe"
+	// KindPipeline 下载完成后自动转录的流水线任务
+	KindPipeline Kind = "pipeline"
+	// KindCollection 下载专栏、收藏夹等页面中所有视频的合集任务
+	KindCollection Kind = "collection"
+	// KindBatch 批量转录，只用于生成 ID，子任务是普通的转录任务
+	KindBatch Kind = "batch"
+)
+
+// DownloadTask 下载任务
+type DownloadTask struct {
+	ID          string `json:"id"`
+	Status      Status `json:"status"`
+	Percentage  int    `json:"percentage"`
+	Speed       string `json:"speed,omitempty"`
+	ElapsedTime int    `json:"elapsed_time"`
+	// BytesDownloaded 已下载的字节数；TotalBytes 文件的总字节数，未知时为 0（部分下载方式为估算值）
+	BytesDownloaded int64 `json:"bytes_downloaded,omitempty"`
+	TotalBytes      int64 `json:"total_bytes,omitempty"`
+	// SpeedHistory 最近约一分钟每秒的下载速度（字节/秒），最早的在前，用于绘制速度曲线；只在本次运行中记录
+	SpeedHistory []int64 `json:"speed_history,omitempty"`
+	// AvgSpeed / PeakSpeed 下载的平均速度和峰值（字节/秒），下载结束时记录；不知道已下载的字节数时为 0
+	AvgSpeed  int64 `json:"avg_speed,omitempty"`
+	PeakSpeed int64 `json:"peak_speed,omitempty"`
+	// ETASeconds 按最近的下载速度估算的剩余秒数，无法估算时为 0
+	ETASeconds int    `json:"eta_seconds,omitempty"`
+	FilePath   string `json:"file_path,omitempty"`
+	FileName   string `json:"file_name,omitempty"`
+	Error      string `json:"error,omitempty"`
+	// ErrorCode / ErrorDetail 失败原因的错误码和外部程序输出等细节，见 errcode 包
+	ErrorCode   errcode.Code `json:"error_code,omitempty"`
+	ErrorDetail string       `json:"error_detail,omitempty"`
+	VideoURL    string       `json:"video_url"`
+	Quality     string       `json:"quality,omitempty"`
+	Backend     string       `json:"backend,omitempty"`
+	OutputDir   string       `json:"output_dir,omitempty"`
+	Filename    string       `json:"-"`
+	// FilenameTemplate 未指定文件名时使用的模板
+	FilenameTemplate string `json:"filename_template,omitempty"`
+	// Resolution 实际下载的分辨率，例如 1920x1080
+	Resolution string `json:"resolution,omitempty"`
+	// Title 视频标题，在 Go 中解析知乎页面下载或导入时指定了标题才有；Author 作者，只有在 Go 中解析知乎页面时才有
+	Title  string `json:"title,omitempty"`
+	Author string `json:"author,omitempty"`
+	// Imported 通过导入登记的本地视频，不是由本服务下载的
+	Imported bool `json:"imported,omitempty"`
+	// ClipSource 截取片段的任务截取的源视频，ClipStart / ClipEnd 起止秒数，ClipFormat 输出格式；不是片段任务时为空
+	ClipSource string  `json:"clip_source,omitempty"`
+	ClipStart  float64 `json:"clip_start,omitempty"`
+	ClipEnd    float64 `json:"clip_end,omitempty"`
+	ClipFormat string  `json:"clip_format,omitempty"`
+	// Remux ffmpeg 写入文件的方式：copy 直接复制、copy_mkv 编码与 MP4 不兼容时复制到 MKV、
+	// reencode 无法直接复制时重新编码；没有经过 ffmpeg 时为空
+	Remux string `json:"remux,omitempty"`
+	// ContentHash 文件内容的 SHA-256；LinkedTo 内容相同的已下载文件，FilePath 是指向它的链接，
+	// LinkMode 为 hardlink 或 symlink。没有去重时 LinkedTo 和 LinkMode 为空
+	ContentHash string    `json:"content_hash,omitempty"`
+	LinkedTo    string    `json:"linked_to,omitempty"`
+	LinkMode    DedupMode `json:"link_mode,omitempty"`
+	// MaxRate 下载速度上限（字节/秒），0 表示只受全局上限限制
+	MaxRate int64 `json:"max_rate,omitempty"`
+	// Connections m3u8 同时下载的分片数，0 表示使用默认值
+	Connections int `json:"connections,omitempty"`
+	// FFmpegArgs 生成 MP4 时追加的 ffmpeg 输出选项，HWAccel 重新编码时的硬件加速方式（为空时使用全局设置）
+	FFmpegArgs []string `json:"ffmpeg_args,omitempty"`
+	HWAccel    string   `json:"hwaccel,omitempty"`
+	// TranscodeCodec / TranscodeMaxHeight / TranscodeCRF 下载后转码的编码、分辨率上限和 CRF，TranscodeCodec 为空时不转码
+	TranscodeCodec     string `json:"transcode_codec,omitempty"`
+	TranscodeMaxHeight int    `json:"transcode_max_height,omitempty"`
+	TranscodeCRF       int    `json:"transcode_crf,omitempty"`
+	// Headers / Cookies 创建任务时附加的请求头和 cookies，暂停后继续、重试和重新排队时仍然使用；可能包含令牌，
+	// 加密保存（见 store.Secrets），不在 API 中返回，下载完成后清除
+	Headers map[string]string `json:"-"`
+	Cookies map[string]string `json:"-"`
+	// Retries 本次执行中失败后自动重试的次数
+	Retries int `json:"retries"`
+	// Workspace 任务所属的工作区，为空时不属于任何工作区
+	Workspace string `json:"workspace,omitempty"`
+	// Comments 下载完成后保存知乎评论，最多 CommentsLimit 条根评论（0 表示全部）
+	Comments      bool `json:"comments,omitempty"`
+	CommentsLimit int  `json:"comments_limit,omitempty"`
+	// CommentsPath / CommentsMarkdownPath 保存的评论（JSON / Markdown），获取失败时为空
+	CommentsPath         string `json:"comments_path,omitempty"`
+	CommentsMarkdownPath string `json:"comments_markdown_path,omitempty"`
+	// ThumbnailPath 封面，SpritePath 预览图（均匀截取的多帧按行拼接），未生成时为空
+	ThumbnailPath string `json:"thumbnail_path,omitempty"`
+	SpritePath    string `json:"sprite_path,omitempty"`
+	// NFOPath 媒体库使用的 .nfo 文件，未生成时为空
+	NFOPath string `json:"nfo_path,omitempty"`
+	// RemoteURLs 上传到远程存储的文件地址（键为 video），没有配置远程存储或不是单独的下载任务时为空
+	RemoteURLs map[string]string `json:"remote_urls,omitempty"`
+	// Notify 任务结束时的通知目标（渠道名称或 webhook 地址），为空时使用配置的所有渠道
+	Notify []string `json:"notify,omitempty"`
+	// Priority 优先级 high / normal / low，排队的任务中优先级高的先开始
+	Priority Priority `json:"priority,omitempty"`
+	// 排队中的位置（从 1 开始），未排队时为 0
+	QueuePosition int `json:"queue_position,omitempty"`
+	// Cached 只在创建任务的返回值中设置：同一视频和清晰度已经下载过，没有重新下载
+	Cached    bool      `json:"cached,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	StartTime time.Time `json:"-"`
+}
+
+// TranscribeTask 转录任务
+type TranscribeTask struct {
+	ID          string `json:"id"`
+	Status      Status `json:"status"`
+	Percentage  int    `json:"percentage"`
+	Stage       string `json:"stage,omitempty"`
+	ElapsedTime int    `json:"elapsed_time"`
+	// ETASeconds 按 Whisper 每秒转录的音频时长估算的剩余秒数，提取音频阶段和无法估算时为 0
+	ETASeconds  int    `json:"eta_seconds,omitempty"`
+	MP3Path     string `json:"mp3_path,omitempty"`
+	TXTPath     string `json:"txt_path,omitempty"`
+	SRTPath     string `json:"srt_path,omitempty"`
+	JSONPath    string `json:"json_path,omitempty"`
+	SummaryPath string `json:"summary_path,omitempty"`
+	Error       string `json:"error,omitempty"`
+	// ErrorCode / ErrorDetail 失败原因的错误码和外部程序输出等细节，见 errcode 包
+	ErrorCode   errcode.Code `json:"error_code,omitempty"`
+	ErrorDetail string       `json:"error_detail,omitempty"`
+	VideoPath   string       `json:"video_path"`
+	Workspace   string       `json:"workspace,omitempty"`
+	Language    string       `json:"language,omitempty"`
+	Diarize     bool         `json:"diarize,omitempty"`
+	Summarize   bool         `json:"summarize,omitempty"`
+	// Model 转录使用的 Whisper 模型
+	Model string `json:"model,omitempty"`
+	// AudioFormat / AudioQuality 提取的音频格式和码率，MP3Path 为提取的音频（字段名沿用早期只输出 mp3 的版本）
+	AudioFormat  string `json:"audio_format,omitempty"`
+	AudioQuality string `json:"audio_quality,omitempty"`
+	// KeepIntermediate 转录成功后保留提取的音频，为 false 时完成后删除，MP3Path 为空
+	KeepIntermediate bool `json:"keep_intermediate"`
+	// InitialPrompt / Vocabulary 传给 Whisper 的提示文本和专业词汇，见 transcriber.Request
+	InitialPrompt  string    `json:"initial_prompt,omitempty"`
+	Vocabulary     []string  `json:"vocabulary,omitempty"`
+	OutputDir      string    `json:"output_dir,omitempty"`
+	OutputFilename string    `json:"output_filename,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	StartTime      time.Time `json:"-"`
+
+	// RemoteURLs 上传到远程存储的文件地址，键为 audio / txt / srt / json / summary
+	RemoteURLs map[string]string `json:"remote_urls,omitempty"`
+	// Notify 任务结束时的通知目标，见 DownloadTask
+	Notify []string `json:"notify,omitempty"`
+	// Priority 优先级，见 DownloadTask
+	Priority Priority `json:"priority,omitempty"`
+	// QueuePosition 在转录队列中的位置（从 1 开始），未排队时为 0
+	QueuePosition int `json:"queue_position,omitempty"`
+
+	// DetectedLanguage / LanguageProbability Language 为 auto 时识别出的语言和置信度（0–1），
+	// 转录完成后设置；后端没有输出置信度时为 0
+	DetectedLanguage    string  `json:"detected_language,omitempty"`
+	LanguageProbability float64 `json:"language_probability,omitempty"`
+}
+
+// PipelineTask 下载 + 转录流水线任务。两个阶段分别作为子任务执行，
+// 进度合并为一个：下载 0–50%，提取音频 50–60%，转录 60–100%
+type PipelineTask struct {
+	ID          string `json:"id"`
+	Status      Status `json:"status"`
+	Percentage  int    `json:"percentage"`
+	Stage       string `json:"stage,omitempty"`
+	Speed       string `json:"speed,omitempty"`
+	ElapsedTime int    `json:"elapsed_time"`
+	// BytesDownloaded / TotalBytes 下载阶段的字节数，取自下载子任务
+	BytesDownloaded int64 `json:"bytes_downloaded,omitempty"`
+	TotalBytes      int64 `json:"total_bytes,omitempty"`
+	// SpeedHistory 下载阶段最近的速度（字节/秒），取自下载子任务
+	SpeedHistory []int64 `json:"speed_history,omitempty"`
+	// AvgSpeed / PeakSpeed 下载阶段的平均速度和峰值（字节/秒），见 DownloadTask
+	AvgSpeed  int64 `json:"avg_speed,omitempty"`
+	PeakSpeed int64 `json:"peak_speed,omitempty"`
+	// ETASeconds 当前阶段（下载或转录）的剩余秒数，取自子任务
+	ETASeconds int `json:"eta_seconds,omitempty"`
+	// DownloadID / TranscribeID 子任务 ID，转录子任务在下载完成后才创建
+	DownloadID   string `json:"download_id,omitempty"`
+	TranscribeID string `json:"transcribe_id,omitempty"`
+	FilePath     string `json:"file_path,omitempty"`
+	MP3Path      string `json:"mp3_path,omitempty"`
+	TXTPath      string `json:"txt_path,omitempty"`
+	SRTPath      string `json:"srt_path,omitempty"`
+	JSONPath     string `json:"json_path,omitempty"`
+	SummaryPath  string `json:"summary_path,omitempty"`
+	Error        string `json:"error,omitempty"`
+	// ErrorCode / ErrorDetail 失败原因的错误码和外部程序输出等细节，见 errcode 包
+	ErrorCode   errcode.Code `json:"error_code,omitempty"`
+	ErrorDetail string       `json:"error_detail,omitempty"`
+	VideoURL    string       `json:"video_url"`
+	Language    string       `json:"language,omitempty"`
+	Diarize     bool         `json:"diarize,omitempty"`
+	Summarize   bool         `json:"summarize,omitempty"`
+	Model       string       `json:"model,omitempty"`
+	OutputDir   string       `json:"output_dir,omitempty"`
+	Workspace   string       `json:"workspace,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	StartTime   time.Time    `json:"-"`
+
+	// SubtitleMode 转录后的字幕处理方式 none / mux / burn，SubtitledPath 为带字幕的视频
+	SubtitleMode  string `json:"subtitle_mode,omitempty"`
+	SubtitledPath string `json:"subtitled_path,omitempty"`
+
+	// AudioFormat / AudioQuality 提取的音频格式和码率，KeepIntermediate 是否保留音频，见 TranscribeTask
+	AudioFormat      string `json:"audio_format,omitempty"`
+	AudioQuality     string `json:"audio_quality,omitempty"`
+	KeepIntermediate bool   `json:"keep_intermediate"`
+	// InitialPrompt / Vocabulary 转录的提示文本和专业词汇，见 TranscribeTask
+	InitialPrompt string   `json:"initial_prompt,omitempty"`
+	Vocabulary    []string `json:"vocabulary,omitempty"`
+
+	// RemoteURLs 上传到远程存储的文件地址，键为 video / subtitled 以及转录文件的 audio / txt / srt / json / summary
+	RemoteURLs map[string]string `json:"remote_urls,omitempty"`
+	// Notify 任务结束时的通知目标，见 DownloadTask
+	Notify []string `json:"notify,omitempty"`
+	// Priority 优先级，见 DownloadTask
+	Priority Priority `json:"priority,omitempty"`
+
+	// DetectedLanguage / LanguageProbability 自动识别出的语言，见 TranscribeTask
+	DetectedLanguage    string  `json:"detected_language,omitempty"`
+	LanguageProbability float64 `json:"language_probability,omitempty"`
+}
+
+// CollectionTask 合集下载任务：列出专栏、收藏夹、问题或用户主页中的所有视频，
+// 每个视频作为一个下载子任务排队，进度为所有子任务的平均进度
+type CollectionTask struct {
+	ID          string `json:"id"`
+	Status      Status `json:"status"`
+	Percentage  int    `json:"percentage"`
+	Stage       string `json:"stage,omitempty"`
+	ElapsedTime int    `json:"elapsed_time"`
+	URL         string `json:"url"`
+	// Title 合集名称，视频保存在输出目录下以合集名称命名的子目录中
+	Title     string `json:"title,omitempty"`
+	Quality   string `json:"quality,omitempty"`
+	Backend   string `json:"backend,omitempty"`
+	OutputDir string `json:"output_dir,omitempty"`
+	Workspace string `json:"workspace,omitempty"`
+	// Limit 最多下载的视频数
+	Limit int `json:"limit,omitempty"`
+	// MaxRate 每个视频的下载速度上限（字节/秒）
+	MaxRate int64 `json:"max_rate,omitempty"`
+	// DownloadIDs 下载子任务 ID，列出视频后创建
+	DownloadIDs []string `json:"download_ids"`
+	Total       int      `json:"total"`
+	Completed   int      `json:"completed"`
+	Failed      int      `json:"failed"`
+	Error       string   `json:"error,omitempty"`
+	// ErrorCode / ErrorDetail 失败原因的错误码和外部程序输出等细节，见 errcode 包
+	ErrorCode   errcode.Code `json:"error_code,omitempty"`
+	ErrorDetail string       `json:"error_detail,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	StartTime   time.Time    `json:"-"`
+
+	// Notify 合集下载结束时的通知目标，见 DownloadTask。每个视频不单独通知
+	Notify []string `json:"notify,omitempty"`
+	// Priority 下载子任务的优先级，见 DownloadTask
+	Priority Priority `json:"priority,omitempty"`
+}
@@ -0,0 +1,136 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"zhihu-downloader/internal/errcode"
+)
+
+// DefaultDownloadStall 下载进度持续没有变化多久判定为卡住
+const DefaultDownloadStall = 10 * time.Minute
+
+// DefaultStuckAfter 正在执行的任务超过这么久没有任何更新时，健康检查报告为疑似卡住
+const DefaultStuckAfter = 30 * time.Minute
+
+// ErrTimeout 任务超过配置的时间限制，由 Manager 自动终止
+var ErrTimeout = errcode.New(errcode.Timeout, "任务超时")
+
+// TimeoutOptions 各阶段的超时时间，0 表示不限制
+type TimeoutOptions struct {
+	// DownloadStall 下载进度（百分比、已下载字节数）持续这么久没有变化时终止下载，默认 DefaultDownloadStall
+	DownloadStall time.Duration
+	// DownloadMax 单个下载任务的最长时间，包括获取标题、转码和生成预览图
+	DownloadMax time.Duration
+	// TranscribeMax 单个转录任务的最长时间，包括提取音频、转录和生成摘要
+	TranscribeMax time.Duration
+}
+
+// WithTimeouts 设置任务超时。超时的任务终止外部程序并标记为 failed，可以重试
+func WithTimeouts(t TimeoutOptions) Option {
+	return func(m *Manager) {
+		m.timeouts = t
+	}
+}
+
+// watchdog 任务的超时控制：总时长超过 max，或 stall 内没有调用 progress 时以 ErrTimeout 取消 ctx
+type watchdog struct {
+	cancel context.CancelCauseFunc
+	max    *time.Timer
+	stall  *time.Timer
+	idle   time.Duration
+	what   string
+}
+
+// newWatchdog 返回受超时控制的 ctx。what 用于错误信息，例如 "下载"
+func newWatchdog(ctx context.Context, what string, max, stall time.Duration) (context.Context, *watchdog) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	w := &watchdog{cancel: cancel, idle: stall, what: what}
+	if max > 0 {
+		w.max = time.AfterFunc(max, func() {
+			cancel(fmt.Errorf("%w: %s超过 %s", ErrTimeout, what, formatTimeout(max)))
+		})
+	}
+	if stall > 0 {
+		w.stall = time.AfterFunc(stall, func() {
+			cancel(fmt.Errorf("%w: %s %s没有进度", ErrTimeout, what, formatTimeout(stall)))
+		})
+	}
+	return ctx, w
+}
+
+// progress 有新的进度，重新计算卡住的时间
+func (w *watchdog) progress() {
+	if w.stall != nil {
+		w.stall.Reset(w.idle)
+	}
+}
+
+// stopStall 不再检查进度，例如下载完成后生成预览图
+func (w *watchdog) stopStall() {
+	if w.stall != nil {
+		w.stall.Stop()
+	}
+}
+
+// stop 释放计时器。ctx 因超时被取消时返回超时原因，否则原样返回 err
+func (w *watchdog) stop(ctx context.Context, err error) error {
+	if w.max != nil {
+		w.max.Stop()
+	}
+	w.stopStall()
+	if cause := context.Cause(ctx); err != nil && errors.Is(cause, ErrTimeout) {
+		err = cause
+	}
+	w.cancel(nil)
+	return err
+}
+
+// formatTimeout 整小时、整分钟、整秒显示为 "N 小时" 等，其他按 time.Duration 格式显示
+func formatTimeout(d time.Duration) string {
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		return fmt.Sprintf("%d 小时", int(d/time.Hour))
+	case d >= time.Minute && d%time.Minute == 0:
+		return fmt.Sprintf("%d 分钟", int(d/time.Minute))
+	case d >= time.Second && d%time.Second == 0:
+		return fmt.Sprintf("%d 秒", int(d/time.Second))
+	}
+	return d.String()
+}
+
+// StuckTask 疑似卡住的任务
+type StuckTask struct {
+	ID     string `json:"id"`
+	Status Status `json:"status"`
+	// IdleSeconds 距离上次更新的秒数
+	IdleSeconds int `json:"idle_seconds"`
+}
+
+// StuckTasks 返回本进程正在执行、但超过 after 没有更新的下载和转录任务（按没有更新的时间从长到短）。
+// 流水线和合集任务随子任务更新，只检查子任务
+func (m *Manager) StuckTasks(after time.Duration) []StuckTask {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	var list []StuckTask
+	check := func(id string, status Status, updated time.Time) {
+		if idle := now.Sub(updated); !status.Terminal() && idle >= after {
+			list = append(list, StuckTask{ID: id, Status: status, IdleSeconds: int(idle.Seconds())})
+		}
+	}
+	for id := range m.active {
+		if t, ok := m.downloads[id]; ok {
+			check(id, t.Status, t.UpdatedAt)
+		}
+		if t, ok := m.transcribes[id]; ok {
+			check(id, t.Status, t.UpdatedAt)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].IdleSeconds > list[j].IdleSeconds })
+	return list
+}
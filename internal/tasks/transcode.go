@@ -0,0 +1,74 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/media"
+)
+
+// TranscodeNone 请求中指定时不转码，即使配置了默认的转码设置
+const TranscodeNone = "none"
+
+// WithTranscode 设置下载后默认的转码设置，请求中没有指定转码时使用（默认不转码）
+func WithTranscode(o media.TranscodeOptions) Option {
+	return func(m *Manager) { m.transcode = o }
+}
+
+// resolveTranscode 检查请求的转码设置，没有指定时使用默认设置
+func (m *Manager) resolveTranscode(o media.TranscodeOptions) (media.TranscodeOptions, error) {
+	switch o.Codec {
+	case "":
+		if o.MaxHeight == 0 && o.CRF == 0 {
+			return m.transcode, nil
+		}
+	case TranscodeNone:
+		return media.TranscodeOptions{}, nil
+	}
+	return o, media.CheckTranscode(o)
+}
+
+// transcodeVideo 下载完成后按任务的设置重新编码视频并替换原文件，更新 result 中的路径、大小和分辨率。
+// 转码期间任务状态为 transcoding，percentage 为转码的进度
+func (m *Manager) transcodeVideo(ctx context.Context, task *DownloadTask, req downloader.Request, result *downloader.Result) error {
+	if !req.Transcode.Enabled() {
+		return nil
+	}
+	ctx = logging.WithStage(ctx, "transcode")
+	logger := logging.FromContext(ctx)
+	m.updateDownload(task, func(t *DownloadTask) {
+		t.Status = StatusTranscoding
+		t.Percentage = 0
+		t.Speed = ""
+		t.ETASeconds = 0
+	})
+	logger.Info("开始转码", "codec", req.Transcode.Codec, "max_height", req.Transcode.MaxHeight, "crf", req.Transcode.CRF)
+
+	var eta etaEstimator
+	opts := media.EncodeOptions{HWAccel: req.HWAccel, Args: req.FFmpegArgs}
+	path, err := media.Transcode(ctx, result.FilePath, req.Transcode, opts, func(pct int) {
+		remaining := eta.update("percent", float64(pct), 100)
+		m.updateDownload(task, func(t *DownloadTask) {
+			t.Percentage = pct
+			t.ETASeconds = remaining
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("%w（下载的原视频保留在 %s）", err, result.FilePath)
+	}
+
+	before := result.Size
+	result.FilePath = path
+	if info, err := os.Stat(path); err == nil {
+		result.Size = info.Size()
+	}
+	if resolution := media.Resolution(path); resolution != "" {
+		result.Resolution = resolution
+	}
+	logger.Info("转码完成", "file_path", path, "resolution", result.Resolution,
+		"size_mb_before", fmt.Sprintf("%.1f", float64(before)/1024/1024), "size_mb", fmt.Sprintf("%.1f", float64(result.Size)/1024/1024))
+	return nil
+}
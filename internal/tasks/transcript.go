@@ -0,0 +1,50 @@
+package tasks
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"zhihu-downloader/internal/transcriber"
+)
+
+// Transcript 返回已完成的转录或流水线任务的结构化转录结果（逐段和逐词时间），
+// 优先从数据库读取，没有记录时读取任务的 JSON 文件
+func (m *Manager) Transcript(id string) (*transcriber.Transcript, error) {
+	var transcribeID, jsonPath string
+	if t, err := m.Transcribe(id); err == nil {
+		if t.Status != StatusCompleted {
+			return nil, fmt.Errorf("任务 %s 还没有完成转录", id)
+		}
+		transcribeID, jsonPath = t.ID, t.JSONPath
+	} else if p, err := m.Pipeline(id); err == nil {
+		if p.Status != StatusCompleted {
+			return nil, fmt.Errorf("任务 %s 还没有完成转录", id)
+		}
+		transcribeID, jsonPath = p.TranscribeID, p.JSONPath
+	} else {
+		return nil, ErrNotFound
+	}
+
+	if m.persister != nil {
+		t, err := m.persister.LookupTranscript(transcribeID)
+		if err != nil {
+			return nil, err
+		}
+		if t != nil {
+			return t, nil
+		}
+	}
+	if jsonPath == "" {
+		return nil, fmt.Errorf("任务 %s 没有保存逐段的转录结果（早期转录的任务需要重新转录）", id)
+	}
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取转录结果失败: %v", err)
+	}
+	var t transcriber.Transcript
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("解析转录结果失败: %v", err)
+	}
+	return &t, nil
+}
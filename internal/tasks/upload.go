@@ -0,0 +1,152 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/upload"
+)
+
+// uploadFile 任务完成后要上传的文件，name 为 RemoteURLs 中的键
+type uploadFile struct {
+	name string
+	kind string
+	path string
+}
+
+// downloadUploads 下载任务上传的文件
+func downloadUploads(filePath string) []uploadFile {
+	return []uploadFile{{"video", upload.KindVideo, filePath}}
+}
+
+// transcribeUploads 转录任务上传的文件
+func transcribeUploads(audio, txt, srt, json, summary string) []uploadFile {
+	return []uploadFile{
+		{"audio", upload.KindAudio, audio},
+		{"txt", upload.KindTranscript, txt},
+		{"srt", upload.KindTranscript, srt},
+		{"json", upload.KindTranscript, json},
+		{"summary", upload.KindTranscript, summary},
+	}
+}
+
+// pipelineUploads 流水线任务上传的文件：视频、带字幕的视频和转录文件
+func pipelineUploads(t *PipelineTask) []uploadFile {
+	files := append(downloadUploads(t.FilePath), uploadFile{"subtitled", upload.KindVideo, t.SubtitledPath})
+	return append(files, transcribeUploads(t.MP3Path, t.TXTPath, t.SRTPath, t.JSONPath, t.SummaryPath)...)
+}
+
+// pendingUploads 过滤出配置为上传且本地存在的文件，没有配置远程存储时返回空
+func pendingUploads(files []uploadFile) []uploadFile {
+	var list []uploadFile
+	for _, f := range files {
+		if f.path != "" && upload.Includes(f.kind) && fileExists(f.path) {
+			list = append(list, f)
+		}
+	}
+	return list
+}
+
+// uploadOutputs 把任务的输出文件上传到远程存储，返回文件名到远程地址的映射，配置了 delete_local 时
+// 上传成功后删除本地文件。上传失败不影响任务结果，只记录到任务日志和历史中
+func (m *Manager) uploadOutputs(ctx context.Context, id string, status Status, files []uploadFile) map[string]string {
+	ctx = logging.WithStage(ctx, "upload")
+	logger := logging.FromContext(ctx)
+	remote := map[string]string{}
+	for _, f := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		url, err := upload.Upload(ctx, f.path, m.remoteKey(f.path))
+		if err != nil {
+			logger.Warn("上传失败", "file", f.path, "error", err)
+			m.uploadEvent(id, status, fmt.Sprintf("上传 %s 失败: %v", filepath.Base(f.path), err))
+			continue
+		}
+		remote[f.name] = url
+		logger.Info("已上传", "file", f.path, "url", url)
+		m.uploadEvent(id, status, "已上传 "+url)
+		if upload.DeleteLocal() {
+			if err := os.Remove(f.path); err != nil {
+				logger.Warn("删除本地文件失败", "file", f.path, "error", err)
+			}
+		}
+	}
+	if len(remote) == 0 {
+		return nil
+	}
+	return remote
+}
+
+func (m *Manager) uploadEvent(id string, status Status, message string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.appendEventLocked(TaskEvent{TaskID: id, Type: EventUpload, Status: status, Message: message})
+}
+
+// remoteKey 文件的远程路径：下载目录（包括其中的工作区和合集子目录）中的文件保留相对路径，
+// 其他目录中的文件只用文件名
+func (m *Manager) remoteKey(path string) string {
+	roots := []string{m.outputDir}
+	for _, ws := range m.Workspaces() {
+		roots = append(roots, ws.OutputDir)
+	}
+	for _, root := range roots {
+		if inDir(root, path) {
+			if rel, err := filepath.Rel(root, path); err == nil {
+				return filepath.ToSlash(rel)
+			}
+		}
+	}
+	return filepath.Base(path)
+}
+
+// inPipeline 下载或转录任务是否由流水线创建，流水线任务结束时统一上传
+func (m *Manager) inPipeline(id string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, p := range m.pipelines {
+		if p.DownloadID == id || p.TranscribeID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeRemoteURLs 合并本次上传的地址，重试时之前上传（且本地已删除）的文件地址保留。
+// 任务快照共用同一个 map，总是返回新的 map
+func mergeRemoteURLs(old, added map[string]string) map[string]string {
+	if len(added) == 0 {
+		return old
+	}
+	merged := make(map[string]string, len(old)+len(added))
+	for k, v := range old {
+		merged[k] = v
+	}
+	for k, v := range added {
+		merged[k] = v
+	}
+	return merged
+}
+
+// pipelineUpload 上传阶段：转录（和字幕处理）完成后上传流水线的所有输出文件
+func (m *Manager) pipelineUpload(ctx context.Context, task *PipelineTask) error {
+	m.mu.RLock()
+	files, status := pendingUploads(pipelineUploads(task)), task.Status
+	m.mu.RUnlock()
+	if len(files) == 0 {
+		return nil
+	}
+	m.updatePipeline(task, func(t *PipelineTask) {
+		t.Stage = "上传中"
+		t.Percentage = 99
+	})
+	remote := m.uploadOutputs(ctx, task.ID, status, files)
+	m.updatePipeline(task, func(t *PipelineTask) {
+		t.RemoteURLs = mergeRemoteURLs(t.RemoteURLs, remote)
+	})
+	return ctx.Err()
+}
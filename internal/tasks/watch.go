@@ -0,0 +1,168 @@
+package tasks
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"zhihu-downloader/internal/transcriber"
+)
+
+const (
+	// DefaultWatchDebounce 新文件的大小持续这么久没有变化才开始转录，避免转录还在写入的文件
+	DefaultWatchDebounce = 10 * time.Second
+	// watchCheckInterval 检查等待中的文件的间隔
+	watchCheckInterval = time.Second
+)
+
+// watchExts 监视目录时自动转录的视频扩展名
+var watchExts = []string{".mp4", ".mkv"}
+
+// WatchFolder 监视的目录，放入其中的新视频自动转录
+type WatchFolder struct {
+	Dir string
+	// Language / Model 这个目录中的视频转录时使用的语言和模型，为空时使用默认值
+	Language string
+	Model    string
+	// OutputDir 转录文件的输出目录，默认与视频相同
+	OutputDir string
+	// Workspace 转录任务所属的工作区，目录必须在工作区目录中
+	Workspace string
+}
+
+// WatchOptions 监视目录的设置
+type WatchOptions struct {
+	Folders []WatchFolder
+	// Debounce 新文件的大小持续这么久没有变化才开始转录，默认 DefaultWatchDebounce
+	Debounce time.Duration
+}
+
+// pendingFile 等待写入完成的文件
+type pendingFile struct {
+	folder WatchFolder
+	// size 最后一次检查时的文件大小
+	size int64
+	// since 最后一次收到事件或大小变化的时间
+	since time.Time
+}
+
+// RunWatcher 监视 o.Folders 中的目录（不包括子目录），直到 ctx 结束。
+// 新放入（创建或移入）的 .mp4 / .mkv 在大小持续 Debounce 没有变化后按目录的设置创建转录任务；
+// 已有同名 .txt / .srt、已经转录完成或正在转录的视频跳过。启动前已经存在的文件不处理
+func (m *Manager) RunWatcher(ctx context.Context, o WatchOptions) {
+	if len(o.Folders) == 0 {
+		return
+	}
+	if o.Debounce <= 0 {
+		o.Debounce = DefaultWatchDebounce
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		slog.Error("创建目录监视失败", "error", err)
+		return
+	}
+	defer watcher.Close()
+
+	folders := map[string]WatchFolder{}
+	for _, f := range o.Folders {
+		dir, err := filepath.Abs(ExpandHome(f.Dir))
+		if err == nil {
+			err = m.CheckWorkspacePath(f.Workspace, dir)
+		}
+		if err == nil {
+			err = m.CheckPath(dir)
+		}
+		if err == nil {
+			err = watcher.Add(dir)
+		}
+		if err != nil {
+			slog.Error("无法监视目录", "dir", f.Dir, "error", err)
+			continue
+		}
+		f.Dir = dir
+		folders[dir] = f
+		slog.Info("开始监视目录", "dir", dir, "language", f.Language, "model", f.Model)
+	}
+	if len(folders) == 0 {
+		return
+	}
+
+	pending := map[string]*pendingFile{}
+	ticker := time.NewTicker(watchCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Warn("目录监视出错", "error", err)
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			folder, watched := folders[filepath.Dir(ev.Name)]
+			if !watched || !slices.Contains(watchExts, strings.ToLower(filepath.Ext(ev.Name))) {
+				continue
+			}
+			switch {
+			case ev.Has(fsnotify.Remove) || ev.Has(fsnotify.Rename):
+				// 移出目录或重命名，新名称另有 Create 事件
+				delete(pending, ev.Name)
+			case ev.Has(fsnotify.Create) || ev.Has(fsnotify.Write):
+				var size int64
+				if info, err := os.Stat(ev.Name); err == nil {
+					size = info.Size()
+				}
+				pending[ev.Name] = &pendingFile{folder: folder, size: size, since: time.Now()}
+			}
+		case now := <-ticker.C:
+			for path, p := range pending {
+				if now.Sub(p.since) < o.Debounce {
+					continue
+				}
+				info, err := os.Stat(path)
+				if err != nil || !info.Mode().IsRegular() {
+					delete(pending, path)
+					continue
+				}
+				// 大小还在变化（没有 Write 事件的文件系统，例如网络存储）时继续等待
+				if info.Size() != p.size || info.Size() == 0 {
+					p.size, p.since = info.Size(), now
+					continue
+				}
+				delete(pending, path)
+				m.transcribeWatched(path, p.folder)
+			}
+		}
+	}
+}
+
+// transcribeWatched 为监视目录中的新视频创建转录任务
+func (m *Manager) transcribeWatched(path string, folder WatchFolder) {
+	req := transcriber.Request{
+		VideoPath: path,
+		Language:  folder.Language,
+		Model:     folder.Model,
+		OutputDir: folder.OutputDir,
+		Workspace: folder.Workspace,
+	}
+	if reason := m.skipReason(req, m.transcribedVideos()); reason != "" {
+		slog.Info("监视目录中的视频不需要转录", "file_path", path, "reason", reason)
+		return
+	}
+	task, err := m.StartTranscribe(req)
+	if err != nil {
+		slog.Warn("自动转录失败", "file_path", path, "error", err)
+		return
+	}
+	slog.Info("监视目录中有新视频，已创建转录任务", "file_path", path, "task_id", task.ID)
+}
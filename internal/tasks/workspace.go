@@ -0,0 +1,167 @@
+package tasks
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/transcriber"
+)
+
+// ErrOutsideWorkspace 路径不在工作区的下载目录中
+var ErrOutsideWorkspace = errcode.New(errcode.Forbidden, "路径不在工作区目录中")
+
+// Workspace 工作区：多人共用一个服务时每人一个工作区，有自己的下载目录和容量上限，
+// 只能看到自己创建的任务。任务的 Workspace 为空时不属于任何工作区（单用户模式或 MCP 服务创建的任务）
+type Workspace struct {
+	Name string
+	// OutputDir 工作区的下载目录，为空时为默认下载目录下以工作区名称命名的子目录
+	OutputDir string
+	// MaxSize 下载目录的容量上限（字节），0 表示只受全局上限（quota.max_size_mb）限制
+	MaxSize int64
+	// Glossary 工作区的默认词汇表，转录请求没有指定词汇时使用
+	Glossary []string
+}
+
+// WithWorkspaces 设置工作区。请求指定工作区时，未指定输出目录的下载保存在工作区目录中，
+// 指定的输出目录、转录的视频都必须在工作区目录中
+func WithWorkspaces(list []Workspace) Option {
+	return func(m *Manager) {
+		m.workspaces = make(map[string]Workspace, len(list))
+		for _, ws := range list {
+			m.workspaces[ws.Name] = ws
+		}
+	}
+}
+
+// Workspaces 返回所有工作区（下载目录已展开）
+func (m *Manager) Workspaces() []Workspace {
+	list := make([]Workspace, 0, len(m.workspaces))
+	for name := range m.workspaces {
+		ws, _ := m.Workspace(name)
+		list = append(list, ws)
+	}
+	return list
+}
+
+// Workspace 返回工作区，OutputDir 为实际使用的下载目录
+func (m *Manager) Workspace(name string) (Workspace, bool) {
+	ws, ok := m.workspaces[name]
+	if !ok {
+		return Workspace{}, false
+	}
+	if ws.OutputDir == "" {
+		ws.OutputDir = filepath.Join(m.outputDir, name)
+	}
+	ws.OutputDir = ExpandHome(ws.OutputDir)
+	return ws, true
+}
+
+// WorkspaceDir 返回工作区的下载目录，name 为空时返回默认下载目录
+func (m *Manager) WorkspaceDir(name string) string {
+	if ws, ok := m.Workspace(name); ok {
+		return ws.OutputDir
+	}
+	return m.outputDir
+}
+
+// WorkspaceOutputDir 确定下载的输出目录：dir 为空时使用工作区目录（没有工作区时为默认下载目录）；
+// 指定工作区时相对路径在工作区目录下，绝对路径必须在工作区目录中。指定的目录还必须在允许访问的目录中
+func (m *Manager) WorkspaceOutputDir(workspace, dir string) (string, error) {
+	if workspace == "" {
+		if dir == "" {
+			return m.outputDir, nil
+		}
+		dir = ExpandHome(dir)
+		if err := m.CheckPath(dir); err != nil {
+			return "", err
+		}
+		return dir, nil
+	}
+	ws, ok := m.Workspace(workspace)
+	if !ok {
+		return "", fmt.Errorf("工作区不存在: %s", workspace)
+	}
+	if dir == "" {
+		return ws.OutputDir, nil
+	}
+	dir = ExpandHome(dir)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(ws.OutputDir, dir)
+	}
+	if err := m.CheckWorkspacePath(workspace, dir); err != nil {
+		return "", err
+	}
+	if err := m.CheckPath(dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// transcribePrompt 检查转录的提示文本和词汇，没有指定词汇时使用工作区的词汇表
+func (m *Manager) transcribePrompt(workspace, prompt string, vocabulary []string) (string, []string, error) {
+	if len(vocabulary) == 0 {
+		if ws, ok := m.workspaces[workspace]; ok {
+			vocabulary = ws.Glossary
+		}
+	}
+	return transcriber.ResolvePrompt(prompt, vocabulary)
+}
+
+// CheckWorkspacePath 检查路径是否在工作区的下载目录中，workspace 为空时不限制
+func (m *Manager) CheckWorkspacePath(workspace, path string) error {
+	if workspace == "" {
+		return nil
+	}
+	ws, ok := m.Workspace(workspace)
+	if !ok {
+		return fmt.Errorf("工作区不存在: %s", workspace)
+	}
+	if !inDir(ws.OutputDir, filepath.Clean(path)) {
+		return fmt.Errorf("%w：%s 不在 %s 中", ErrOutsideWorkspace, path, ws.OutputDir)
+	}
+	return nil
+}
+
+// TaskWorkspace 返回任务、批量转录、计划任务或订阅所属的工作区，ok 为 false 表示 ID 不存在
+func (m *Manager) TaskWorkspace(id string) (workspace string, ok bool) {
+	m.refreshAny(id)
+	if workspace, ok := m.taskWorkspace(id); ok {
+		return workspace, true
+	}
+	if b, _ := m.batch(id); b != nil {
+		return b.Workspace, true
+	}
+	if s, err := m.Subscription(id); err == nil {
+		return s.Workspace, true
+	}
+	return "", false
+}
+
+func (m *Manager) taskWorkspace(id string) (workspace string, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if t, ok := m.downloads[id]; ok {
+		return t.Workspace, true
+	}
+	if t, ok := m.transcribes[id]; ok {
+		return t.Workspace, true
+	}
+	if t, ok := m.pipelines[id]; ok {
+		return t.Workspace, true
+	}
+	if t, ok := m.collections[id]; ok {
+		return t.Workspace, true
+	}
+	if s, ok := m.schedules[id]; ok {
+		return s.Workspace, true
+	}
+	return "", false
+}
+
+// inDir 判断 path 是否在 dir 中（包括 dir 本身）
+func inDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,166 @@
+// Package toolschema 按 MCP 工具声明的 inputSchema 校验调用参数。
+//
+// 只支持工具定义中用到的 JSON Schema 关键字：type、required、enum、items、maxLength、
+// minimum / maximum。参数值为 JSON 解码后的 interface{}（数字为 float64）。
+package toolschema
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"zhihu-downloader/internal/errcode"
+)
+
+// 链接和路径参数的长度上限，在工具定义中用 maxLength 声明
+const (
+	MaxURLLength  = 2048
+	MaxPathLength = 4096
+)
+
+// FieldError 参数不符合 inputSchema
+type FieldError struct {
+	// Field 出错的参数，数组元素为 name[i]
+	Field  string
+	Reason string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("参数 %s %s", e.Field, e.Reason)
+}
+
+// Info 返回接口中的错误信息
+func (e *FieldError) Info() errcode.Info {
+	return errcode.Info{Code: errcode.InvalidArgument, Message: e.Error(), Field: e.Field}
+}
+
+// Validate 按 schema 校验 args：必填参数、类型、枚举值和长度。schema 中没有声明的参数不检查
+func Validate(schema map[string]interface{}, args map[string]interface{}) *FieldError {
+	props, _ := schema["properties"].(map[string]interface{})
+	for _, name := range stringList(schema["required"]) {
+		if v, ok := args[name]; !ok || v == nil {
+			return &FieldError{Field: name, Reason: "必填"}
+		}
+	}
+	// 按名称排序，出错时总是报告同一个参数
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		prop, ok := props[name].(map[string]interface{})
+		if !ok || args[name] == nil {
+			continue
+		}
+		if err := check(name, prop, args[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func check(field string, prop map[string]interface{}, v interface{}) *FieldError {
+	typ, _ := prop["type"].(string)
+	switch typ {
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return typeError(field, typ, v)
+		}
+		if max, ok := number(prop["maxLength"]); ok && utf8.RuneCountInString(s) > int(max) {
+			return &FieldError{Field: field, Reason: fmt.Sprintf("超过最大长度 %d", int(max))}
+		}
+	case "integer", "number":
+		n, ok := v.(float64)
+		if !ok || (typ == "integer" && n != math.Trunc(n)) {
+			return typeError(field, typ, v)
+		}
+		if min, ok := number(prop["minimum"]); ok && n < min {
+			return &FieldError{Field: field, Reason: fmt.Sprintf("不能小于 %v", min)}
+		}
+		if max, ok := number(prop["maximum"]); ok && n > max {
+			return &FieldError{Field: field, Reason: fmt.Sprintf("不能大于 %v", max)}
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return typeError(field, typ, v)
+		}
+	case "array":
+		list, ok := v.([]interface{})
+		if !ok {
+			return typeError(field, typ, v)
+		}
+		if items, ok := prop["items"].(map[string]interface{}); ok {
+			for i, item := range list {
+				if err := check(fmt.Sprintf("%s[%d]", field, i), items, item); err != nil {
+					return err
+				}
+			}
+		}
+	case "object":
+		if _, ok := v.(map[string]interface{}); !ok {
+			return typeError(field, typ, v)
+		}
+	}
+
+	if enum := stringList(prop["enum"]); len(enum) > 0 {
+		if s, ok := v.(string); ok && !slices.Contains(enum, s) {
+			return &FieldError{Field: field, Reason: fmt.Sprintf("的值 %q 无效（可选 %s）", s, strings.Join(enum, " / "))}
+		}
+	}
+	return nil
+}
+
+func typeError(field, want string, v interface{}) *FieldError {
+	return &FieldError{Field: field, Reason: fmt.Sprintf("应为 %s，实际为 %s", want, jsonType(v))}
+}
+
+// jsonType 返回 JSON 值的类型名
+func jsonType(v interface{}) string {
+	switch n := v.(type) {
+	case string:
+		return "string"
+	case float64:
+		if n == math.Trunc(n) {
+			return "integer"
+		}
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "null"
+}
+
+// stringList 读取 required、enum 等字符串列表，工具定义中为 []string，经过 JSON 编解码后为 []interface{}
+func stringList(v interface{}) []string {
+	switch list := v.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		out := make([]string, 0, len(list))
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
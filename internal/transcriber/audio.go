@@ -0,0 +1,96 @@
+package transcriber
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"zhihu-downloader/internal/media"
+)
+
+// AudioFormats 提取音频可以选择的格式
+var AudioFormats = []string{"wav", "mp3", "m4a", "flac"}
+
+// DefaultAudioFormat 默认提取 16kHz 单声道 WAV：Whisper 内部按这个采样率处理音频，转录质量不受压缩影响
+const DefaultAudioFormat = "wav"
+
+// DefaultAudioBitrate mp3 / m4a 未指定码率时使用的码率
+const DefaultAudioBitrate = "192k"
+
+var bitrateRe = regexp.MustCompile(`^[1-9]\d*[kK]?$`)
+
+// ParseAudio 检查音频格式和码率，格式为空时使用 wav。码率（例如 128k、192k、320k）只用于 mp3 / m4a，
+// 为空时使用 192k；wav / flac 为无损格式，返回的码率为空
+func ParseAudio(format, quality string) (string, string, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" {
+		format = DefaultAudioFormat
+	}
+	if !slices.Contains(AudioFormats, format) {
+		return "", "", fmt.Errorf("不支持的音频格式: %s（可选 %s）", format, strings.Join(AudioFormats, " / "))
+	}
+	if format == "wav" || format == "flac" {
+		return format, "", nil
+	}
+	quality = strings.ToLower(strings.TrimSpace(quality))
+	if quality == "" {
+		quality = DefaultAudioBitrate
+	}
+	if !bitrateRe.MatchString(quality) {
+		return "", "", fmt.Errorf("无效的音频码率: %s（例如 128k、192k、320k）", quality)
+	}
+	return format, quality, nil
+}
+
+// ResolveAudio 返回请求使用的音频格式和码率，为空时使用配置的格式和码率
+func ResolveAudio(format, quality string) (string, string, error) {
+	cfg := currentConfig()
+	if format == "" {
+		format = cfg.AudioFormat
+	}
+	if quality == "" {
+		quality = cfg.AudioQuality
+	}
+	return ParseAudio(format, quality)
+}
+
+// audioCodecArgs 提取音频的 ffmpeg 编码参数。wav 转为 16kHz 单声道供 Whisper 直接使用，
+// 其他格式保留原始的采样率和声道，作为高质量的音频副本
+func audioCodecArgs(format, quality string) []string {
+	switch format {
+	case "mp3":
+		return []string{"-c:a", "libmp3lame", "-b:a", quality}
+	case "m4a":
+		return []string{"-c:a", "aac", "-b:a", quality}
+	case "flac":
+		return []string{"-c:a", "flac"}
+	default:
+		return []string{"-ac", "1", "-ar", "16000", "-c:a", "pcm_s16le"}
+	}
+}
+
+// whisperCppAudio whisper.cpp 只能读取 wav / mp3 / flac / ogg，其他格式先转为临时的 16kHz WAV。
+// 临时文件与音频同名、放在临时目录中，后端输出的 JSON 路径不变；返回的 cleanup 删除临时目录
+func whisperCppAudio(ctx context.Context, audioPath string) (string, func(), error) {
+	switch strings.ToLower(filepath.Ext(audioPath)) {
+	case ".wav", ".mp3", ".flac", ".ogg":
+		return audioPath, func() {}, nil
+	}
+	dir, err := os.MkdirTemp("", "zhihu-whisper-")
+	if err != nil {
+		return "", nil, fmt.Errorf("创建临时目录失败: %v", err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	name := filepath.Base(audioPath)
+	wavPath := filepath.Join(dir, strings.TrimSuffix(name, filepath.Ext(name))+".wav")
+	args := append([]string{"-y", "-i", audioPath}, audioCodecArgs("wav", "")...)
+	if err := media.RunFFmpegProgress(ctx, "音频转换", 0, nil, append(args, wavPath)...); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return wavPath, cleanup, nil
+}
@@ -0,0 +1,351 @@
+package transcriber
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"zhihu-downloader/internal/errcode"
+	"zhihu-downloader/internal/proc"
+)
+
+// Transcriber 一种 Whisper 命令行实现。所有实现都以
+// "[开始 --> 结束] 文本" 的格式逐段输出识别结果，结束后写出带逐词时间的 JSON
+type Transcriber interface {
+	// Name 后端名称，用于配置选择
+	Name() string
+	// Detect 检查后端能否在本机使用，返回可执行文件路径
+	Detect(model string) (path string, err error)
+	// Command 构造转录命令
+	Command(ctx context.Context, exe string, opts Options) *proc.Cmd
+	// Installed 检查模型是否已下载到本机，返回模型文件或缓存目录
+	Installed(model string) (path string, ok bool)
+	// ReadSegments 读取转录命令写出的 JSON，返回带逐词时间的分段和识别出的语言
+	ReadSegments(opts Options) (segments []Segment, language string, err error)
+}
+
+// Options 传给后端的转录参数
+type Options struct {
+	AudioPath string
+	OutputDir string
+	// Language 语言代码，为空时由 Whisper 根据前 30 秒音频自动识别
+	Language string
+	// Model 模型名称，为空时使用 base
+	Model string
+	// Device 推理设备 cuda / cpu，由 DetectHardware 决定
+	Device string
+	// InitialPrompt / Vocabulary 提示文本和专业词汇，见 Request
+	InitialPrompt string
+	Vocabulary    []string
+}
+
+// Config 转录后端配置
+type Config struct {
+	// Backend 后端名称（mlx-whisper / faster-whisper / whisper.cpp / openai-whisper），为空时自动选择
+	Backend string
+	// Model 模型名称（tiny、base、small…），whisper.cpp 也可以是 ggml 模型文件路径
+	Model string
+	// Path 后端可执行文件路径，为空时自动查找；未指定 Backend 时按 openai-whisper 参数调用
+	Path string
+	// Python 运行 diarize.py 的解释器，为空时优先使用脚本目录下的 .venv
+	Python string
+	// DiarizeScript 说话人分离脚本路径，默认是可执行文件旁边的 diarize.py
+	DiarizeScript string
+	// HFToken 下载 pyannote 模型用的 Hugging Face 访问令牌
+	HFToken string
+	// AutoDownload 模型未安装时自动下载，关闭时转录直接失败
+	AutoDownload bool
+	// AudioFormat / AudioQuality 提取音频的默认格式和码率，见 ParseAudio
+	AudioFormat  string
+	AudioQuality string
+	// KeepIntermediate 转录成功后保留提取的音频，关闭时删除（见 Request.KeepIntermediate）
+	KeepIntermediate bool
+}
+
+// DefaultModel 未配置模型时使用的模型
+const DefaultModel = "base"
+
+var (
+	configMu sync.RWMutex
+	config   Config
+)
+
+// SetConfig 设置转录后端配置
+func SetConfig(c Config) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	config = c
+}
+
+func currentConfig() Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config
+}
+
+// BackendStatus 后端检测结果
+type BackendStatus struct {
+	Name      string `json:"name"`
+	Available bool   `json:"available"`
+	Path      string `json:"path,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// Detect 按自动选择的顺序检测所有后端在本机的可用情况
+func Detect() []BackendStatus {
+	model := currentConfig().Model
+	backends := preferredBackends(DetectHardware())
+	list := make([]BackendStatus, 0, len(backends))
+	for _, b := range backends {
+		status := BackendStatus{Name: b.Name()}
+		if path, err := b.Detect(model); err != nil {
+			status.Reason = err.Error()
+		} else {
+			status.Available = true
+			status.Path = path
+		}
+		list = append(list, status)
+	}
+	return list
+}
+
+// selectBackend 按配置选择后端；未指定时按本机加速环境选择最快的可用后端。model 为空时使用配置的模型
+func selectBackend(model string) (Transcriber, string, error) {
+	backends := preferredBackends(DetectHardware())
+	cfg := currentConfig()
+	if model != "" {
+		cfg.Model = model
+	}
+	if cfg.Path != "" && cfg.Backend == "" {
+		cfg.Backend = openaiWhisper{}.Name()
+	}
+	if cfg.Backend != "" {
+		for _, b := range backends {
+			if b.Name() != cfg.Backend {
+				continue
+			}
+			if cfg.Path != "" {
+				if _, err := os.Stat(cfg.Path); err != nil {
+					return nil, "", fmt.Errorf("转录程序不存在: %s", cfg.Path)
+				}
+				return b, cfg.Path, nil
+			}
+			path, err := b.Detect(cfg.Model)
+			if err != nil {
+				return nil, "", fmt.Errorf("转录后端 %s 不可用: %v", b.Name(), err)
+			}
+			return b, path, nil
+		}
+		return nil, "", fmt.Errorf("未知的转录后端: %s", cfg.Backend)
+	}
+
+	var reasons []string
+	for _, b := range backends {
+		path, err := b.Detect(cfg.Model)
+		if err == nil {
+			return b, path, nil
+		}
+		reasons = append(reasons, fmt.Sprintf("%s: %v", b.Name(), err))
+	}
+	return nil, "", errcode.Newf(errcode.TranscribeBackendMissing, "没有可用的 Whisper（%s）", strings.Join(reasons, "; "))
+}
+
+// languageArgs 指定语言时的命令行参数；Python 实现的后端不传 --language 时自动识别语言
+func languageArgs(flag, language string) []string {
+	if language == "" {
+		return nil
+	}
+	return []string{flag, language}
+}
+
+func modelOrDefault(model string) string {
+	if model == "" {
+		return DefaultModel
+	}
+	return model
+}
+
+// openaiWhisper openai-whisper 命令行（pip install openai-whisper）
+type openaiWhisper struct{}
+
+func (openaiWhisper) Name() string { return "openai-whisper" }
+
+func (openaiWhisper) Detect(string) (string, error) {
+	return proc.LookPath("whisper")
+}
+
+func (openaiWhisper) Command(ctx context.Context, exe string, opts Options) *proc.Cmd {
+	args := []string{opts.AudioPath,
+		"--output_format", "json", "--output_dir", opts.OutputDir, "--word_timestamps", "True",
+		"--model", modelOrDefault(opts.Model), "--verbose", "True"}
+	args = append(args, languageArgs("--language", opts.Language)...)
+	args = append(args, promptArgs("--initial_prompt", opts.prompt())...)
+	if opts.Device != "" {
+		args = append(args, "--device", opts.Device)
+	}
+	return proc.Command(ctx, exe, args...)
+}
+
+func (openaiWhisper) ReadSegments(opts Options) ([]Segment, string, error) {
+	return readWhisperJSON(opts)
+}
+
+// Installed 模型保存在 ~/.cache/whisper/<名称>.pt（XDG_CACHE_HOME 优先）
+func (openaiWhisper) Installed(model string) (string, bool) {
+	path := model
+	if !strings.HasSuffix(model, ".pt") {
+		dir := os.Getenv("XDG_CACHE_HOME")
+		if dir == "" {
+			home, _ := os.UserHomeDir()
+			dir = filepath.Join(home, ".cache")
+		}
+		path = filepath.Join(dir, "whisper", modelOrDefault(model)+".pt")
+	}
+	_, err := os.Stat(path)
+	return path, err == nil
+}
+
+// mlxWhisper mlx-whisper，在 Apple Silicon 上使用 GPU 加速
+type mlxWhisper struct{}
+
+func (mlxWhisper) Name() string { return "mlx-whisper" }
+
+func (mlxWhisper) Detect(string) (string, error) {
+	if runtime.GOOS != "darwin" || runtime.GOARCH != "arm64" {
+		return "", fmt.Errorf("仅支持 Apple Silicon")
+	}
+	return proc.LookPath("mlx_whisper")
+}
+
+func (mlxWhisper) Command(ctx context.Context, exe string, opts Options) *proc.Cmd {
+	args := []string{opts.AudioPath,
+		"--output-format", "json", "--output-dir", opts.OutputDir, "--word-timestamps", "True",
+		"--model", mlxRepo(opts.Model), "--verbose", "True"}
+	args = append(args, languageArgs("--language", opts.Language)...)
+	return proc.Command(ctx, exe, append(args, promptArgs("--initial-prompt", opts.prompt())...)...)
+}
+
+func (mlxWhisper) ReadSegments(opts Options) ([]Segment, string, error) {
+	return readWhisperJSON(opts)
+}
+
+func (mlxWhisper) Installed(model string) (string, bool) {
+	return huggingFaceModel(mlxRepo(model))
+}
+
+// mlxRepo 模型名称转换为 mlx-community 上的仓库名
+func mlxRepo(model string) string {
+	model = modelOrDefault(model)
+	if strings.Contains(model, "/") {
+		return model
+	}
+	return "mlx-community/whisper-" + model + "-mlx"
+}
+
+// fasterWhisper 基于 CTranslate2 的 faster-whisper（whisper-ctranslate2 / faster-whisper-xxl 命令行）
+type fasterWhisper struct{}
+
+func (fasterWhisper) Name() string { return "faster-whisper" }
+
+func (fasterWhisper) Detect(string) (string, error) {
+	return proc.LookPath("whisper-ctranslate2", "faster-whisper-xxl", "faster-whisper")
+}
+
+func (fasterWhisper) Command(ctx context.Context, exe string, opts Options) *proc.Cmd {
+	args := []string{opts.AudioPath,
+		"--output_format", "json", "--output_dir", opts.OutputDir, "--word_timestamps", "True",
+		"--model", modelOrDefault(opts.Model), "--verbose", "True"}
+	args = append(args, languageArgs("--language", opts.Language)...)
+	// 词汇表作为热词传入（需要 whisper-ctranslate2 0.4.3 以上），不占用提示文本的长度
+	args = append(args, promptArgs("--initial_prompt", opts.InitialPrompt)...)
+	args = append(args, promptArgs("--hotwords", strings.Join(opts.Vocabulary, ", "))...)
+	// GPU 上使用 float16，CPU 上使用 int8 量化
+	switch opts.Device {
+	case "cuda":
+		args = append(args, "--device", "cuda", "--compute_type", "float16")
+	case "cpu":
+		args = append(args, "--device", "cpu", "--compute_type", "int8")
+	}
+	return proc.Command(ctx, exe, args...)
+}
+
+func (fasterWhisper) ReadSegments(opts Options) ([]Segment, string, error) {
+	return readWhisperJSON(opts)
+}
+
+// Installed 模型从 Hugging Face 的 Systran/faster-whisper-<名称> 下载
+func (fasterWhisper) Installed(model string) (string, bool) {
+	model = modelOrDefault(model)
+	if !strings.Contains(model, "/") {
+		model = "Systran/faster-whisper-" + model
+	}
+	return huggingFaceModel(model)
+}
+
+// whisperCpp whisper.cpp 命令行，需要本地的 ggml 模型文件
+type whisperCpp struct{}
+
+func (whisperCpp) Name() string { return "whisper.cpp" }
+
+func (whisperCpp) Detect(model string) (string, error) {
+	exe, err := proc.LookPath("whisper-cli", "whisper-cpp")
+	if err != nil {
+		return "", err
+	}
+	// 开启自动下载时转录前再下载模型
+	if _, err := whisperCppModel(model); err != nil && !AutoDownload() {
+		return "", err
+	}
+	return exe, nil
+}
+
+func (whisperCpp) Installed(model string) (string, bool) {
+	path, err := whisperCppModel(model)
+	return path, err == nil
+}
+
+func (whisperCpp) Command(ctx context.Context, exe string, opts Options) *proc.Cmd {
+	model, _ := whisperCppModel(opts.Model)
+	language := opts.Language
+	if language == "" {
+		language = LanguageAuto
+	}
+	args := []string{"-m", model, "-l", language, "-f", opts.AudioPath, "-ojf", "-of", whisperOutputBase(opts)}
+	return proc.Command(ctx, exe, append(args, promptArgs("--prompt", opts.prompt())...)...)
+}
+
+func (whisperCpp) ReadSegments(opts Options) ([]Segment, string, error) {
+	return readWhisperCppJSON(opts)
+}
+
+// whisperCppModel 查找 ggml 模型文件：model 可以是文件路径或模型名称（在常见目录中查找 ggml-<名称>.bin）
+func whisperCppModel(model string) (string, error) {
+	if model == "" {
+		model = os.Getenv("WHISPER_CPP_MODEL")
+	}
+	if strings.ContainsRune(model, os.PathSeparator) || strings.HasSuffix(model, ".bin") {
+		if _, err := os.Stat(model); err != nil {
+			return "", fmt.Errorf("模型文件不存在: %s", model)
+		}
+		return model, nil
+	}
+
+	name := "ggml-" + modelOrDefault(model) + ".bin"
+	dirs := []string{"/opt/homebrew/share/whisper-cpp", "/usr/local/share/whisper-cpp"}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs,
+			filepath.Join(home, ".cache", "whisper.cpp"),
+			filepath.Join(home, "whisper.cpp", "models"))
+	}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("未找到模型文件 %s", name)
+}
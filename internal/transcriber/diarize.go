@@ -0,0 +1,122 @@
+package transcriber
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/proc"
+)
+
+// speakerTurn diarize.py 输出的一段说话时间
+type speakerTurn struct {
+	Start   float64 `json:"start"`
+	End     float64 `json:"end"`
+	Speaker string  `json:"speaker"`
+}
+
+// diarizeScript 返回 diarize.py 路径，默认在可执行文件旁边
+func diarizeScript() string {
+	if script := currentConfig().DiarizeScript; script != "" {
+		return script
+	}
+	if exe, err := os.Executable(); err == nil {
+		return filepath.Join(filepath.Dir(exe), "diarize.py")
+	}
+	return "diarize.py"
+}
+
+// diarizePython 优先使用配置的解释器，其次是脚本目录下的虚拟环境和 PATH 中的 Python
+func diarizePython(script string) string {
+	if python := currentConfig().Python; python != "" {
+		return python
+	}
+	return proc.Python(filepath.Dir(script))
+}
+
+// diarize 调用 diarize.py（pyannote.audio）识别说话人
+func diarize(ctx context.Context, audioPath string) ([]speakerTurn, error) {
+	script := diarizeScript()
+	if _, err := os.Stat(script); err != nil {
+		return nil, fmt.Errorf("未找到说话人分离脚本 %s", script)
+	}
+
+	cmd := proc.Command(ctx, diarizePython(script), script, audioPath)
+	cmd.Env = proc.Env()
+	if token := currentConfig().HFToken; token != "" {
+		cmd.Env = append(cmd.Env, "HF_TOKEN="+token)
+	}
+	var stdout bytes.Buffer
+	stderr := logging.Writer(ctx, "pyannote")
+	defer stderr.Close()
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("说话人分离失败: %v", err)
+	}
+
+	var turns []speakerTurn
+	if err := json.Unmarshal(stdout.Bytes(), &turns); err != nil {
+		return nil, fmt.Errorf("解析说话人分离结果失败: %v", err)
+	}
+	return turns, nil
+}
+
+// assignSpeakers 按时间重叠为每段文字选择说话人，说话人按出现顺序编号为 Speaker 1、Speaker 2…
+// 返回说话人数量
+func assignSpeakers(segments []Segment, turns []speakerTurn) int {
+	labels := map[string]string{}
+	for i := range segments {
+		s := &segments[i]
+		best, bestOverlap, bestDistance := "", 0.0, math.Inf(1)
+		overlaps := map[string]float64{}
+		for _, t := range turns {
+			if overlap := math.Min(s.End, t.End) - math.Max(s.Start, t.Start); overlap > 0 {
+				overlaps[t.Speaker] += overlap
+				if overlaps[t.Speaker] > bestOverlap {
+					best, bestOverlap = t.Speaker, overlaps[t.Speaker]
+				}
+			}
+			// 没有重叠时取距离最近的一段
+			if bestOverlap == 0 {
+				mid := (s.Start + s.End) / 2
+				if d := math.Min(math.Abs(mid-t.Start), math.Abs(mid-t.End)); d < bestDistance {
+					best, bestDistance = t.Speaker, d
+				}
+			}
+		}
+		if best == "" {
+			continue
+		}
+		if _, ok := labels[best]; !ok {
+			labels[best] = "Speaker " + strconv.Itoa(len(labels)+1)
+		}
+		s.Speaker = labels[best]
+	}
+	return len(labels)
+}
+
+// writeDiarized 识别说话人，为每段文字标注说话人并改写 txt，返回说话人数量
+func writeDiarized(ctx context.Context, req Request, mp3Path, txtPath string, segments []Segment) (int, error) {
+	turns, err := diarize(ctx, mp3Path)
+	if err != nil {
+		return 0, err
+	}
+	speakers := assignSpeakers(segments, turns)
+	logging.FromContext(ctx).Info("说话人分离完成", "speakers", speakers, "turns", len(turns))
+
+	if err := writeLabeledTXT(txtPath, segments, req.Language); err != nil {
+		return 0, fmt.Errorf("写入文本失败: %v", err)
+	}
+	return speakers, nil
+}
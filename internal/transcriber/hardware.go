@@ -0,0 +1,119 @@
+package transcriber
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"zhihu-downloader/internal/proc"
+)
+
+// Accelerator 转录可以使用的硬件加速
+type Accelerator string
+
+const (
+	// AccelAppleSilicon Apple Silicon（Metal / MLX）
+	AccelAppleSilicon Accelerator = "apple-silicon"
+	// AccelCUDA NVIDIA 显卡
+	AccelCUDA Accelerator = "cuda"
+	// AccelCPU 没有可用的 GPU
+	AccelCPU Accelerator = "cpu"
+)
+
+// Hardware 本机的转录加速环境
+type Hardware struct {
+	Accelerator Accelerator `json:"accelerator"`
+	// GPU 显卡名称，检测不到时为空
+	GPU string `json:"gpu,omitempty"`
+}
+
+var (
+	hardwareOnce sync.Once
+	hardware     Hardware
+)
+
+// DetectHardware 检测本机的加速环境，结果在进程内缓存。
+// CUDA_VISIBLE_DEVICES 为空字符串或 -1 时视为不使用 GPU
+func DetectHardware() Hardware {
+	hardwareOnce.Do(func() {
+		hardware = detectHardware()
+	})
+	return hardware
+}
+
+func detectHardware() Hardware {
+	if runtime.GOOS == "darwin" && runtime.GOARCH == "arm64" {
+		return Hardware{Accelerator: AccelAppleSilicon, GPU: "Apple Silicon"}
+	}
+	if v, ok := os.LookupEnv("CUDA_VISIBLE_DEVICES"); ok && (v == "" || v == "-1") {
+		return Hardware{Accelerator: AccelCPU}
+	}
+	if smi, err := proc.LookPath("nvidia-smi"); err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		out, err := proc.Command(ctx, smi, "--query-gpu=name", "--format=csv,noheader").Output()
+		if name, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n"); err == nil && name != "" {
+			return Hardware{Accelerator: AccelCUDA, GPU: strings.TrimSpace(name)}
+		}
+	}
+	if _, err := os.Stat("/proc/driver/nvidia/version"); err == nil {
+		return Hardware{Accelerator: AccelCUDA, GPU: "NVIDIA"}
+	}
+	return Hardware{Accelerator: AccelCPU}
+}
+
+// preferredBackends 按加速环境排列自动选择的顺序：
+// Apple Silicon 优先 mlx-whisper，其次使用 Metal 的 whisper.cpp；
+// CUDA 和纯 CPU 优先 faster-whisper（CTranslate2 在 CPU 上使用 int8 也比 PyTorch 快），
+// openai-whisper 最慢，放在最后
+func preferredBackends(hw Hardware) []Transcriber {
+	if hw.Accelerator == AccelAppleSilicon {
+		return []Transcriber{mlxWhisper{}, whisperCpp{}, fasterWhisper{}, openaiWhisper{}}
+	}
+	return []Transcriber{fasterWhisper{}, whisperCpp{}, openaiWhisper{}, mlxWhisper{}}
+}
+
+// device 传给 faster-whisper 和 openai-whisper 的 --device 参数
+func (hw Hardware) device() string {
+	if hw.Accelerator == AccelCUDA {
+		return "cuda"
+	}
+	return "cpu"
+}
+
+// Status 转录环境：加速方式和自动选择的后端，供健康检查使用
+type Status struct {
+	Hardware
+	Backend string `json:"backend,omitempty"`
+	// Path 后端可执行文件或脚本路径
+	Path  string `json:"path,omitempty"`
+	Model string `json:"model"`
+	// Error 没有可用的后端时的原因
+	Error string `json:"error,omitempty"`
+}
+
+// CurrentStatus 返回加速环境和按配置选择的后端
+func CurrentStatus() Status {
+	status := Status{Hardware: DetectHardware(), Model: modelOrDefault(currentConfig().Model)}
+	b, path, err := selectBackend("")
+	if err != nil {
+		status.Error = err.Error()
+	} else {
+		status.Backend, status.Path = b.Name(), path
+	}
+	return status
+}
+
+// LogStatus 启动时检测并记录转录环境
+func LogStatus() {
+	status := CurrentStatus()
+	if status.Error != "" {
+		slog.Warn("没有可用的 Whisper，转录任务会失败", "accelerator", status.Accelerator, "error", status.Error)
+		return
+	}
+	slog.Info("转录环境", "accelerator", status.Accelerator, "gpu", status.GPU, "backend", status.Backend, "model", status.Model)
+}
@@ -0,0 +1,178 @@
+package transcriber
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"zhihu-downloader/internal/logging"
+)
+
+// Models 可以在请求中选择的 Whisper 模型，从小到大排列，越大越准确也越慢
+var Models = []string{"tiny", "base", "small", "medium", "large-v3"}
+
+// ModelStatus 模型在当前后端的安装情况
+type ModelStatus struct {
+	Name      string `json:"name"`
+	Installed bool   `json:"installed"`
+	// Path 已安装的模型文件或缓存目录
+	Path string `json:"path,omitempty"`
+}
+
+// ResolveModel 返回请求使用的模型：为空时使用配置的模型（默认 base），否则必须是 Models 之一或配置的模型
+func ResolveModel(model string) (string, error) {
+	configured := modelOrDefault(currentConfig().Model)
+	if model == "" || model == configured {
+		return configured, nil
+	}
+	if !slices.Contains(Models, model) {
+		return "", fmt.Errorf("不支持的 Whisper 模型: %s（可选 %s）", model, strings.Join(Models, " / "))
+	}
+	return model, nil
+}
+
+// ModelStatuses 返回当前选择的后端和各模型的安装情况；没有可用后端时返回错误
+func ModelStatuses() (backend string, models []ModelStatus, err error) {
+	b, _, err := selectBackend("")
+	if err != nil {
+		return "", nil, err
+	}
+	names := Models
+	if m := currentConfig().Model; m != "" && !slices.Contains(names, m) {
+		names = append(slices.Clone(names), m)
+	}
+	for _, name := range names {
+		status := ModelStatus{Name: name}
+		if path, ok := b.Installed(name); ok {
+			status.Installed, status.Path = true, path
+		}
+		models = append(models, status)
+	}
+	return b.Name(), models, nil
+}
+
+// AutoDownload 缺少模型时是否自动下载
+func AutoDownload() bool {
+	return currentConfig().AutoDownload
+}
+
+// ensureModel 检查模型是否已安装。未安装时：关闭自动下载则返回错误；
+// whisper.cpp 由这里下载 ggml 模型，其他后端在首次使用时自行下载
+func ensureModel(ctx context.Context, b Transcriber, model string, onStage func(string)) error {
+	if _, ok := b.Installed(model); ok {
+		return nil
+	}
+	if !AutoDownload() {
+		return fmt.Errorf("%s 的 %s 模型未安装，可以开启 transcribe.auto_download 自动下载", b.Name(), model)
+	}
+	logger := logging.FromContext(ctx)
+	if _, ok := b.(whisperCpp); !ok {
+		logger.Info("模型未安装，首次转录时自动下载", "backend", b.Name(), "model", model)
+		onStage(fmt.Sprintf("正在下载 %s 模型（只在首次使用时下载）...", model))
+		return nil
+	}
+	return downloadGGML(ctx, model, onStage)
+}
+
+// ggmlBaseURL whisper.cpp 官方模型仓库
+const ggmlBaseURL = "https://huggingface.co/ggerganov/whisper.cpp/resolve/main/"
+
+// downloadGGML 下载 whisper.cpp 的 ggml 模型到 ~/.cache/whisper.cpp
+func downloadGGML(ctx context.Context, model string, onStage func(string)) error {
+	if !slices.Contains(Models, model) {
+		return fmt.Errorf("无法自动下载模型 %s", model)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("下载模型失败: %v", err)
+	}
+	dir := filepath.Join(home, ".cache", "whisper.cpp")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("下载模型失败: %v", err)
+	}
+	name := "ggml-" + model + ".bin"
+	path := filepath.Join(dir, name)
+
+	logger := logging.FromContext(ctx)
+	logger.Info("开始下载 whisper.cpp 模型", "model", model, "path", path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ggmlBaseURL+name, nil)
+	if err != nil {
+		return fmt.Errorf("下载模型失败: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("下载模型失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("下载模型失败: HTTP %d", resp.StatusCode)
+	}
+
+	part := path + ".part"
+	f, err := os.Create(part)
+	if err != nil {
+		return fmt.Errorf("下载模型失败: %v", err)
+	}
+	defer os.Remove(part)
+
+	var written int64
+	last := time.Now()
+	buf := make([]byte, 256<<10)
+	for {
+		n, readErr := resp.Body.Read(buf)
+		if n > 0 {
+			if _, err := f.Write(buf[:n]); err != nil {
+				f.Close()
+				return fmt.Errorf("下载模型失败: %v", err)
+			}
+			written += int64(n)
+			if time.Since(last) >= time.Second {
+				last = time.Now()
+				stage := fmt.Sprintf("正在下载 %s 模型（%d MB", model, written>>20)
+				if resp.ContentLength > 0 {
+					stage += fmt.Sprintf(" / %d MB", resp.ContentLength>>20)
+				}
+				onStage(stage + "）...")
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			f.Close()
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("下载模型失败: %v", readErr)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("下载模型失败: %v", err)
+	}
+	if err := os.Rename(part, path); err != nil {
+		return fmt.Errorf("下载模型失败: %v", err)
+	}
+	logger.Info("whisper.cpp 模型下载完成", "model", model, "size_mb", written>>20)
+	return nil
+}
+
+// huggingFaceModel 在 Hugging Face 缓存中查找已下载的模型仓库（HF_HUB_CACHE、HF_HOME 或 ~/.cache/huggingface/hub）
+func huggingFaceModel(repo string) (string, bool) {
+	dir := os.Getenv("HF_HUB_CACHE")
+	if dir == "" {
+		if home := os.Getenv("HF_HOME"); home != "" {
+			dir = filepath.Join(home, "hub")
+		} else if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".cache", "huggingface", "hub")
+		}
+	}
+	path := filepath.Join(dir, "models--"+strings.ReplaceAll(repo, "/", "--"))
+	snapshots, _ := filepath.Glob(filepath.Join(path, "snapshots", "*"))
+	return path, len(snapshots) > 0
+}
@@ -0,0 +1,61 @@
+package transcriber
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// 提示文本和词汇表的上限。Whisper 只使用提示的最后 224 个 token，更长的内容没有作用
+const (
+	MaxPromptChars     = 1000
+	MaxVocabulary      = 200
+	MaxVocabularyChars = 100
+)
+
+// ResolvePrompt 检查提示文本和词汇表，返回去掉首尾空白、空词和重复词之后的结果
+func ResolvePrompt(prompt string, vocabulary []string) (string, []string, error) {
+	prompt = strings.TrimSpace(prompt)
+	if n := utf8.RuneCountInString(prompt); n > MaxPromptChars {
+		return "", nil, fmt.Errorf("initial_prompt 太长（%d 个字，最多 %d 个）", n, MaxPromptChars)
+	}
+	var list []string
+	seen := map[string]bool{}
+	for _, word := range vocabulary {
+		word = strings.TrimSpace(word)
+		if word == "" || seen[word] {
+			continue
+		}
+		if utf8.RuneCountInString(word) > MaxVocabularyChars {
+			return "", nil, fmt.Errorf("词汇太长（最多 %d 个字）: %s", MaxVocabularyChars, word)
+		}
+		seen[word] = true
+		list = append(list, word)
+	}
+	if len(list) > MaxVocabulary {
+		return "", nil, fmt.Errorf("词汇太多（%d 个，最多 %d 个）", len(list), MaxVocabulary)
+	}
+	return prompt, list, nil
+}
+
+// prompt 不支持热词的后端使用的提示：词汇表放在前面，提示文本放在最后，
+// 超出 Whisper 长度限制时先截掉的是词汇表
+func (opts Options) prompt() string {
+	words := strings.Join(opts.Vocabulary, ", ")
+	switch {
+	case words == "":
+		return opts.InitialPrompt
+	case opts.InitialPrompt == "":
+		return words
+	default:
+		return words + "\n" + opts.InitialPrompt
+	}
+}
+
+// promptArgs 提示为空时不传参数
+func promptArgs(flag, prompt string) []string {
+	if prompt == "" {
+		return nil
+	}
+	return []string{flag, prompt}
+}
@@ -0,0 +1,123 @@
+package transcriber
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"zhihu-downloader/internal/atomicfile"
+)
+
+// Segment Whisper 输出的一段识别结果（时间为秒）
+type Segment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	// Speaker 说话人，例如 "Speaker 1"，未区分说话人时为空
+	Speaker string `json:"speaker,omitempty"`
+	Text    string `json:"text"`
+	// Words 逐词的时间，后端没有输出词级时间时为空
+	Words []Word `json:"words,omitempty"`
+}
+
+// Word 一个词（中日韩文本通常是一个或几个字）的时间，单位为秒。
+// Text 保留 Whisper 输出的前导空格，按顺序拼接即为整段文本
+type Word struct {
+	Start       float64 `json:"start"`
+	End         float64 `json:"end"`
+	Text        string  `json:"word"`
+	Probability float64 `json:"probability,omitempty"`
+}
+
+// Transcript 结构化的转录结果，保存为 <文件名>.json，也保存在数据库中
+type Transcript struct {
+	Language string `json:"language"`
+	// LanguageProbability 自动识别语言的置信度（0–1），指定了语言或后端没有输出时为 0
+	LanguageProbability float64 `json:"language_probability,omitempty"`
+	// Speakers 说话人数量，未区分说话人时为 0
+	Speakers int       `json:"speakers"`
+	Segments []Segment `json:"segments"`
+}
+
+// parseTimestamp 解析 Whisper 时间戳：mm:ss.mmm 或 hh:mm:ss.mmm（毫秒分隔符可以是逗号）
+func parseTimestamp(s string) float64 {
+	parts := strings.Split(strings.ReplaceAll(s, ",", "."), ":")
+	var sec float64
+	for _, p := range parts {
+		var v float64
+		fmt.Sscanf(p, "%g", &v)
+		sec = sec*60 + v
+	}
+	return sec
+}
+
+// writeLabeledTXT 写出带说话人标签的文本，同一说话人连续的句子合并为一段
+func writeLabeledTXT(path string, segments []Segment, language string) error {
+	sep := " "
+	if isCJK(language) {
+		sep = ""
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(segments); {
+		speaker := segments[i].Speaker
+		var texts []string
+		for ; i < len(segments) && segments[i].Speaker == speaker; i++ {
+			texts = append(texts, segments[i].Text)
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s: %s\n", speaker, strings.Join(texts, sep))
+	}
+	return atomicfile.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// writeTranscript 把转录结果写为 <文件名>.srt 和 <文件名>.json（与 txtPath 同名）
+func writeTranscript(txtPath string, t *Transcript) (srtPath, jsonPath string, err error) {
+	base := strings.TrimSuffix(txtPath, ".txt")
+	srtPath, jsonPath = base+".srt", base+".json"
+	if err := writeSRT(srtPath, t.Segments); err != nil {
+		return "", "", fmt.Errorf("写入字幕失败: %v", err)
+	}
+	if err := writeJSON(jsonPath, t); err != nil {
+		return "", "", fmt.Errorf("写入 JSON 失败: %v", err)
+	}
+	return srtPath, jsonPath, nil
+}
+
+// writeSRT 写出 SRT 字幕，有说话人时加上 [Speaker N] 前缀
+func writeSRT(path string, segments []Segment) error {
+	var b strings.Builder
+	for i, s := range segments {
+		text := s.Text
+		if s.Speaker != "" {
+			text = "[" + s.Speaker + "] " + text
+		}
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1, srtTime(s.Start), srtTime(s.End), text)
+	}
+	return atomicfile.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// writeJSON 写出逐段（包括逐词时间）的识别结果
+func writeJSON(path string, t *Transcript) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, data, 0644)
+}
+
+// srtTime 把秒转换为 SRT 时间格式 00:01:02,345
+func srtTime(sec float64) string {
+	ms := int64(sec*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// isCJK 中日韩文本合并句子时不加空格
+func isCJK(language string) bool {
+	switch strings.ToLower(language) {
+	case "zh", "ja", "ko", "chinese", "japanese", "korean":
+		return true
+	}
+	return false
+}
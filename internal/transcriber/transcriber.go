@@ -0,0 +1,387 @@
+// Package transcriber 把视频转录为文本：先用 ffmpeg 提取音频，再调用 Whisper 转录，
+// 转录结果按行实时写入 txt 文件。
+package transcriber
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"zhihu-downloader/internal/atomicfile"
+	"zhihu-downloader/internal/logging"
+	"zhihu-downloader/internal/media"
+	"zhihu-downloader/internal/proc"
+	"zhihu-downloader/internal/summarizer"
+)
+
+// Phase 转录所处阶段
+type Phase string
+
+const (
+	PhaseExtractingAudio Phase = "extracting_audio"
+	PhaseTranscribing    Phase = "transcribing"
+)
+
+// Request 转录请求
+type Request struct {
+	VideoPath string
+	OutputDir string
+	// OutputFilename 输出文件名（不含扩展名）
+	OutputFilename string
+	// FilenameTemplate 未指定 OutputFilename 时的文件名模板，变量与下载的文件名模板相同，
+	// 取自下载这个视频的任务（由 tasks.Manager 处理）；为空时与视频文件同名
+	FilenameTemplate string
+	// Language 语言代码，为空或 auto 时由 Whisper 根据前 30 秒音频自动识别（见 LanguageAuto）
+	Language string
+	// Diarize 转录后区分说话人，并额外输出带 Speaker 标签的 srt 和 json
+	Diarize bool
+	// Summarize 转录后调用大模型生成摘要（见 summarizer 包）
+	Summarize bool
+	// Model Whisper 模型（见 Models），为空时使用配置的模型
+	Model string
+	// AudioFormat / AudioQuality 提取的音频格式和码率（见 ParseAudio），为空时使用配置
+	AudioFormat  string
+	AudioQuality string
+	// KeepIntermediate 转录成功后是否保留提取的音频，为空时使用配置（见 KeepIntermediate）
+	KeepIntermediate *bool
+	// InitialPrompt 传给 Whisper 的提示文本，例如视频的主题或一段风格相同的文字，帮助识别专业术语和标点风格
+	InitialPrompt string
+	// Vocabulary 视频中可能出现的专业词汇（人名、术语、产品名等），faster-whisper 作为热词，
+	// 其他后端加入提示文本；为空时使用工作区的词汇表（由 tasks.Manager 处理，见 ResolvePrompt）
+	Vocabulary []string
+	// Workspace 任务所属的工作区，视频必须在工作区目录中（由 tasks.Manager 处理）
+	Workspace string
+	// Notify 任务结束时的通知目标，见 downloader.Request.Notify（由 tasks.Manager 处理）
+	Notify []string
+	// Priority 优先级，见 downloader.Request.Priority（由 tasks.Manager 处理）
+	Priority string
+	// OnSegment Whisper 每识别出一段时回调（只有分段时间，没有逐词时间和说话人），用于实时显示文稿
+	OnSegment func(Segment)
+}
+
+// Progress 转录进度
+type Progress struct {
+	Phase      Phase
+	Stage      string
+	Percentage int
+	MP3Path    string
+	TXTPath    string
+	SRTPath    string
+	JSONPath   string
+	// Processed / Duration Whisper 已转录的音频时长和总时长（秒），只在转录阶段设置
+	Processed float64
+	Duration  float64
+}
+
+// Result 转录结果
+type Result struct {
+	// MP3Path 提取的音频，格式由 Request.AudioFormat 决定（字段名沿用早期只输出 mp3 的版本），
+	// 不保留中间音频时为空
+	MP3Path string
+	TXTPath string
+	// SRTPath 字幕，JSONPath 逐段（包括逐词时间）的识别结果，区分说话人时标注说话人
+	SRTPath  string
+	JSONPath string
+	// Transcript 与 JSONPath 内容相同的结构化结果
+	Transcript *Transcript
+	// SummaryPath 摘要文件；摘要生成失败不影响转录结果，原因见 SummaryError
+	SummaryPath  string
+	SummaryError string
+}
+
+// 时间戳正则：匹配 [开始时间 --> 结束时间] 并提取后面的文本，
+// 时间可以是 mm:ss.mmm（openai-whisper 等）或 hh:mm:ss.mmm（whisper.cpp）
+var timeRe = regexp.MustCompile(`\[((?:\d{2}:)?\d{2}:\d{2}[.,]\d{3})\s*-->\s*((?:\d{2}:)?\d{2}:\d{2}[.,]\d{3})\]\s*(.*)`)
+
+// LanguageAuto 自动识别语言
+const LanguageAuto = "auto"
+
+// detectedRe Whisper 自动识别语言时输出的结果：
+// faster-whisper 为 "Detected language 'zh' with probability 0.97"，whisper.cpp 为 "auto-detected language: zh (p = 0.97)"，
+// openai-whisper 和 mlx-whisper 为 "Detected language: Chinese"（没有置信度）
+var detectedRe = regexp.MustCompile(`(?i)detected language:?\s+'?([a-z-]+)'?(?:\s+(?:with probability|\(p =)\s*([0-9.]+))?`)
+
+// Transcribe 执行转录，进度通过 onProgress 回调（音频提取占 0-15%，转录占 16-98%）
+func Transcribe(ctx context.Context, req Request, onProgress func(Progress)) (*Result, error) {
+	if onProgress == nil {
+		onProgress = func(Progress) {}
+	}
+
+	// 先获取视频时长（秒）
+	videoDuration := media.Duration(req.VideoPath)
+	if videoDuration <= 0 {
+		videoDuration = 3600 // 默认假设 1 小时
+	}
+
+	onProgress(Progress{
+		Phase:      PhaseExtractingAudio,
+		Stage:      fmt.Sprintf("正在提取音频（视频时长 %.0f 分钟）...", videoDuration/60),
+		Percentage: 1,
+	})
+
+	format, quality, err := ResolveAudio(req.AudioFormat, req.AudioQuality)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(req.OutputDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建输出目录失败: %v", err)
+	}
+	mp3Path := filepath.Join(req.OutputDir, req.OutputFilename+"."+format)
+
+	extractCtx := logging.WithStage(ctx, "extract_audio")
+	if err := extractAudio(extractCtx, req.VideoPath, mp3Path, audioCodecArgs(format, quality), videoDuration, onProgress); err != nil {
+		return nil, err
+	}
+
+	onProgress(Progress{
+		Phase:      PhaseExtractingAudio,
+		Stage:      "音频提取完成，开始转录...",
+		Percentage: 15,
+		MP3Path:    mp3Path,
+	})
+
+	txtPath := filepath.Join(req.OutputDir, req.OutputFilename+".txt")
+	transcript, err := runWhisper(logging.WithStage(ctx, "whisper"), req, mp3Path, txtPath, videoDuration, onProgress)
+	if err != nil {
+		return nil, err
+	}
+	// 自动识别时之后的处理（合并句子、标注说话人）使用识别出的语言
+	req.Language = transcript.Language
+	result := &Result{MP3Path: mp3Path, TXTPath: txtPath, Transcript: transcript}
+	if result.SRTPath, result.JSONPath, err = writeTranscript(txtPath, transcript); err != nil {
+		return nil, err
+	}
+
+	if req.Diarize {
+		onProgress(Progress{
+			Phase:      PhaseTranscribing,
+			Stage:      "正在区分说话人...",
+			Percentage: 98,
+			MP3Path:    mp3Path,
+			TXTPath:    txtPath,
+		})
+		transcript.Speakers, err = writeDiarized(logging.WithStage(ctx, "diarize"), req, mp3Path, txtPath, transcript.Segments)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("%v（未标注说话人的文本已保存到 %s，详细输出见任务日志）", err, txtPath)
+		}
+		if _, _, err := writeTranscript(txtPath, transcript); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Summarize {
+		onProgress(Progress{
+			Phase:      PhaseTranscribing,
+			Stage:      "正在生成摘要...",
+			Percentage: 99,
+			MP3Path:    mp3Path,
+			TXTPath:    txtPath,
+		})
+		summaryCtx := logging.WithStage(ctx, "summarize")
+		result.SummaryPath, err = summarizer.Summarize(summaryCtx, txtPath)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			result.SummaryError = err.Error()
+			logging.FromContext(summaryCtx).Warn("生成摘要失败", "error", err)
+		}
+	}
+
+	if !KeepIntermediate(req.KeepIntermediate) {
+		if err := os.Remove(mp3Path); err != nil && !os.IsNotExist(err) {
+			logging.FromContext(ctx).Warn("删除中间音频失败", "path", mp3Path, "error", err)
+		} else {
+			logging.FromContext(ctx).Debug("已删除中间音频", "path", mp3Path)
+			result.MP3Path = ""
+		}
+	}
+	return result, nil
+}
+
+// KeepIntermediate 返回转录成功后是否保留提取的音频，keep 为空时使用配置的 transcribe.keep_intermediate
+func KeepIntermediate(keep *bool) bool {
+	if keep != nil {
+		return *keep
+	}
+	return currentConfig().KeepIntermediate
+}
+
+// extractAudio 用 ffmpeg 按 codecArgs 提取音频，按 ffmpeg -progress 输出的已处理时长与视频时长的比例回调进度（1–15%）
+func extractAudio(ctx context.Context, videoPath, mp3Path string, codecArgs []string, videoDuration float64, onProgress func(Progress)) error {
+	last := 1
+	return media.RunFFmpegProgress(ctx, "音频提取", videoDuration, func(done int) {
+		if pct := done * 15 / 100; pct > last {
+			last = pct
+			onProgress(Progress{Phase: PhaseExtractingAudio, Stage: fmt.Sprintf("正在提取音频 %d%%...", done), Percentage: pct})
+		}
+	}, append(append([]string{"-y", "-i", videoPath, "-vn"}, codecArgs...), mp3Path)...)
+}
+
+// runWhisper 调用 Whisper 转录 mp3Path，并把识别出的文本实时写入 txtPath，返回逐段（包括逐词时间）的识别结果
+func runWhisper(ctx context.Context, req Request, mp3Path, txtPath string, videoDuration float64, onProgress func(Progress)) (*Transcript, error) {
+	model, err := ResolveModel(req.Model)
+	if err != nil {
+		return nil, err
+	}
+	backend, exe, err := selectBackend(model)
+	if err != nil {
+		return nil, err
+	}
+	err = ensureModel(ctx, backend, model, func(stage string) {
+		onProgress(Progress{Phase: PhaseTranscribing, Stage: stage, Percentage: 16, MP3Path: mp3Path})
+	})
+	if err != nil {
+		return nil, err
+	}
+	hw := DetectHardware()
+	logging.FromContext(ctx).Info("开始 Whisper 转录", "backend", backend.Name(), "model", model,
+		"accelerator", hw.Accelerator, "exe", exe)
+
+	onProgress(Progress{
+		Phase:      PhaseTranscribing,
+		Stage:      fmt.Sprintf("正在转录（%s %s 模型）...", backend.Name(), model),
+		Percentage: 16,
+		MP3Path:    mp3Path,
+		TXTPath:    txtPath,
+	})
+
+	// 实时输出先写入 <文件名>.txt.part，转录成功后再重命名，中断时不会留下不完整的 txt
+	partPath := atomicfile.Path(txtPath)
+	txtFile, err := os.Create(partPath)
+	if err != nil {
+		return nil, fmt.Errorf("创建输出文件失败: %v", err)
+	}
+	committed := false
+	defer func() {
+		txtFile.Close()
+		if !committed {
+			os.Remove(partPath)
+		}
+	}()
+
+	// whisper.cpp 不能读取 m4a，转为临时的 WAV
+	audioPath := mp3Path
+	if _, ok := backend.(whisperCpp); ok {
+		var cleanup func()
+		if audioPath, cleanup, err = whisperCppAudio(ctx, mp3Path); err != nil {
+			return nil, err
+		}
+		defer cleanup()
+	}
+
+	opts := Options{
+		AudioPath: audioPath,
+		OutputDir: req.OutputDir,
+		Language:  req.Language,
+		Model:     model,
+		Device:    hw.device(),
+
+		InitialPrompt: req.InitialPrompt,
+		Vocabulary:    req.Vocabulary,
+	}
+	auto := opts.Language == "" || opts.Language == LanguageAuto
+	if auto {
+		opts.Language = ""
+	}
+	whisperCmd := backend.Command(ctx, exe, opts)
+	whisperCmd.Env = proc.Env()
+	whisperStdout, _ := whisperCmd.StdoutPipe()
+	whisperCmd.Stderr = whisperCmd.Stdout
+
+	if err := whisperCmd.Start(); err != nil {
+		return nil, fmt.Errorf("转录启动失败: %v", err)
+	}
+
+	// 解析 Whisper 进度：[00:00.000 --> 00:30.000] 文本内容 格式
+	scanner := bufio.NewScanner(whisperStdout)
+	var lastOutput strings.Builder
+	var segments []Segment
+	var detected string
+	var probability float64
+	lastPct := 16
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		logging.Output(ctx, backend.Name(), line)
+		if m := detectedRe.FindStringSubmatch(line); auto && m != nil && detected == "" {
+			detected = strings.ToLower(m[1])
+			probability, _ = strconv.ParseFloat(m[2], 64)
+			logging.FromContext(ctx).Info("识别出语言", "language", detected, "probability", probability)
+		}
+		matches := timeRe.FindStringSubmatch(line)
+		if len(matches) < 4 {
+			lastOutput.WriteString(line + "\n")
+			continue
+		}
+
+		currentSec := parseTimestamp(matches[2])
+		endMin, endSec := int(currentSec)/60, int(currentSec)%60
+
+		// 实时写入 txt 文件（只写文本，不写时间戳）
+		if text := strings.TrimSpace(matches[3]); text != "" {
+			txtFile.WriteString(text + "\n")
+			txtFile.Sync()
+			segment := Segment{Start: parseTimestamp(matches[1]), End: currentSec, Text: text}
+			segments = append(segments, segment)
+			if req.OnSegment != nil {
+				req.OnSegment(segment)
+			}
+		}
+
+		pct := min(98, 16+int(currentSec/videoDuration*82))
+		if pct > lastPct {
+			lastPct = pct
+			onProgress(Progress{
+				Phase:      PhaseTranscribing,
+				Stage:      fmt.Sprintf("转录中: %02d:%02d / %02d:%02d", endMin, endSec, int(videoDuration)/60, int(videoDuration)%60),
+				Percentage: pct,
+				MP3Path:    mp3Path,
+				TXTPath:    txtPath,
+				Processed:  currentSec,
+				Duration:   videoDuration,
+			})
+		}
+	}
+
+	if err := whisperCmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("转录失败: %v\n%s", err, lastOutput.String())
+	}
+	if err := txtFile.Close(); err != nil {
+		return nil, fmt.Errorf("写入输出文件失败: %v", err)
+	}
+	if err := atomicfile.Commit(partPath, txtPath); err != nil {
+		return nil, fmt.Errorf("写入输出文件失败: %v", err)
+	}
+	committed = true
+
+	// 后端写出的 JSON 带逐词时间；读取失败时使用从输出中解析的分段（没有逐词时间）
+	transcript := &Transcript{Language: opts.Language, Segments: segments}
+	if auto {
+		transcript.Language, transcript.LanguageProbability = detected, probability
+	}
+	if detailed, language, err := backend.ReadSegments(opts); err != nil {
+		logging.FromContext(ctx).Warn("读取逐词时间失败，只保存分段时间", "error", err)
+	} else if len(detailed) > 0 {
+		transcript.Segments = detailed
+		// JSON 中是语言代码，openai-whisper 的输出中只有语言名称
+		if auto && language != "" {
+			transcript.Language = language
+		}
+	}
+	if transcript.Segments == nil {
+		transcript.Segments = []Segment{}
+	}
+	return transcript, nil
+}
@@ -0,0 +1,105 @@
+package transcriber
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// whisperOutputBase 后端输出文件的路径（不含扩展名）：<输出目录>/<音频文件名>
+func whisperOutputBase(opts Options) string {
+	name := filepath.Base(opts.AudioPath)
+	return filepath.Join(opts.OutputDir, strings.TrimSuffix(name, filepath.Ext(name)))
+}
+
+// whisperJSON openai-whisper、faster-whisper 和 mlx-whisper 的 --output_format json 输出
+type whisperJSON struct {
+	Language string `json:"language"`
+	Segments []struct {
+		Start float64 `json:"start"`
+		End   float64 `json:"end"`
+		Text  string  `json:"text"`
+		Words []Word  `json:"words"`
+	} `json:"segments"`
+}
+
+// readWhisperJSON 读取 Python 实现的后端写出的 <音频文件名>.json，返回带逐词时间的分段和识别出的语言
+func readWhisperJSON(opts Options) ([]Segment, string, error) {
+	data, err := os.ReadFile(whisperOutputBase(opts) + ".json")
+	if err != nil {
+		return nil, "", err
+	}
+	var out whisperJSON
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, "", fmt.Errorf("解析 Whisper JSON 输出失败: %v", err)
+	}
+	var segments []Segment
+	for _, s := range out.Segments {
+		text := strings.TrimSpace(s.Text)
+		if text == "" {
+			continue
+		}
+		segments = append(segments, Segment{Start: s.Start, End: s.End, Text: text, Words: s.Words})
+	}
+	return segments, out.Language, nil
+}
+
+// whisperCppJSON whisper.cpp -ojf 的输出，时间（offsets）单位为毫秒
+type whisperCppJSON struct {
+	Result struct {
+		Language string `json:"language"`
+	} `json:"result"`
+	Transcription []struct {
+		Offsets whisperCppOffsets `json:"offsets"`
+		Text    string            `json:"text"`
+		Tokens  []struct {
+			Text    string            `json:"text"`
+			Offsets whisperCppOffsets `json:"offsets"`
+			P       float64           `json:"p"`
+		} `json:"tokens"`
+	} `json:"transcription"`
+}
+
+type whisperCppOffsets struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// readWhisperCppJSON 读取 whisper.cpp 写出的 JSON。whisper.cpp 只有 token 级的时间，
+// 以空格开头的 token 作为新词的开始，其余 token 合并到前一个词中；跳过 [_BEG_] 等特殊 token
+func readWhisperCppJSON(opts Options) ([]Segment, string, error) {
+	data, err := os.ReadFile(whisperOutputBase(opts) + ".json")
+	if err != nil {
+		return nil, "", err
+	}
+	var out whisperCppJSON
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, "", fmt.Errorf("解析 whisper.cpp JSON 输出失败: %v", err)
+	}
+	var segments []Segment
+	for _, s := range out.Transcription {
+		text := strings.TrimSpace(s.Text)
+		if text == "" {
+			continue
+		}
+		seg := Segment{Start: float64(s.Offsets.From) / 1000, End: float64(s.Offsets.To) / 1000, Text: text}
+		for _, t := range s.Tokens {
+			if t.Text == "" || strings.HasPrefix(t.Text, "[_") {
+				continue
+			}
+			start, end := float64(t.Offsets.From)/1000, float64(t.Offsets.To)/1000
+			if n := len(seg.Words); n > 0 && !strings.HasPrefix(t.Text, " ") {
+				w := &seg.Words[n-1]
+				w.Text += t.Text
+				w.End = end
+				w.Probability = min(w.Probability, t.P)
+				continue
+			}
+			seg.Words = append(seg.Words, Word{Start: start, End: end, Text: t.Text, Probability: t.P})
+		}
+		segments = append(segments, seg)
+	}
+	return segments, out.Result.Language, nil
+}
@@ -0,0 +1,139 @@
+package upload
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultS3Region = "us-east-1"
+	// unsignedPayload 不计算文件内容的哈希（需要先完整读一遍大文件），HTTPS 已经保证内容完整
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// uploadS3 用 PUT Object 上传文件（AWS Signature V4 签名），返回对象地址
+func uploadS3(ctx context.Context, c S3Config, localPath, key string) (string, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	u, err := s3ObjectURL(c, key)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), f)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", contentType(localPath))
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+	signS3(req, c, time.Now().UTC())
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("上传到 S3 失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("上传到 S3 失败: %s %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return u.String(), nil
+}
+
+// s3ObjectURL 返回对象地址：PathStyle 时为 <endpoint>/<bucket>/<key>，否则为 <bucket>.<endpoint>/<key>
+func s3ObjectURL(c S3Config, key string) (*url.URL, error) {
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + s3Region(c) + ".amazonaws.com"
+	}
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("s3.endpoint 无效: %v", err)
+	}
+	objectPath := "/" + key
+	if c.PathStyle {
+		objectPath = u.Path + "/" + c.Bucket + "/" + key
+	} else {
+		u.Host = c.Bucket + "." + u.Host
+		objectPath = u.Path + objectPath
+	}
+	// 签名时的路径必须与实际请求一致，按签名的规则编码
+	u.Path, u.RawPath = objectPath, escapePath(objectPath)
+	return u, nil
+}
+
+func s3Region(c S3Config) string {
+	if c.Region == "" {
+		return defaultS3Region
+	}
+	return c.Region
+}
+
+// signS3 按 AWS Signature V4 为请求签名，签名 Host 和所有 X-Amz-* 请求头
+func signS3(req *http.Request, c S3Config, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		if name = strings.ToLower(name); strings.HasPrefix(name, "x-amz-") || name == "range" {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	payload := req.Header.Get("X-Amz-Content-Sha256")
+	canonicalRequest := strings.Join([]string{
+		req.Method, req.URL.EscapedPath(), req.URL.RawQuery,
+		canonicalHeaders.String(), signedHeaders, payload,
+	}, "\n")
+	scope := date + "/" + s3Region(c) + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hexSHA256(canonicalRequest)}, "\n")
+
+	key := []byte("AWS4" + c.SecretKey)
+	for _, part := range []string{date, s3Region(c), "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
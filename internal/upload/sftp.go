@@ -0,0 +1,68 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"zhihu-downloader/internal/proc"
+)
+
+// uploadSFTP 用系统的 sftp 命令（OpenSSH）上传文件，以批处理模式运行，不会提示输入密码，
+// 需要事先配置好密钥和 known_hosts。返回 sftp://<user>@<host>/<路径>
+func uploadSFTP(ctx context.Context, c SFTPConfig, localPath, key string) (string, error) {
+	exe, err := proc.LookPath("sftp")
+	if err != nil {
+		return "", fmt.Errorf("SFTP 上传需要 OpenSSH 的 sftp 命令: %v", err)
+	}
+	remote := key
+	if c.Dir != "" {
+		remote = path.Join(c.Dir, key)
+	}
+
+	// 逐级创建目录，- 前缀表示忽略错误（目录已存在）
+	var batch strings.Builder
+	var dirs []string
+	for dir := path.Dir(remote); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		dirs = append([]string{dir}, dirs...)
+	}
+	for _, dir := range dirs {
+		fmt.Fprintf(&batch, "-mkdir %s\n", sftpQuote(dir))
+	}
+	fmt.Fprintf(&batch, "put %s %s\n", sftpQuote(localPath), sftpQuote(remote))
+
+	args := []string{"-b", "-", "-o", "BatchMode=yes"}
+	if c.Port > 0 {
+		args = append(args, "-P", strconv.Itoa(c.Port))
+	}
+	if c.KeyFile != "" {
+		args = append(args, "-i", c.KeyFile)
+	}
+	host := c.Host
+	if c.User != "" {
+		host = c.User + "@" + host
+	}
+	args = append(args, host)
+
+	cmd := proc.Command(ctx, exe, args...)
+	cmd.Stdin = strings.NewReader(batch.String())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("SFTP 上传失败: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	u := "sftp://" + host
+	if c.Port > 0 {
+		u += ":" + strconv.Itoa(c.Port)
+	}
+	return u + "/" + strings.TrimPrefix(escapePath(remote), "/"), nil
+}
+
+// sftpQuote 批处理命令中的参数加上双引号，转义其中的双引号和反斜杠
+func sftpQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,249 @@
+// Package upload 把下载和转录完成的文件上传到远程存储：S3 兼容的对象存储（AWS S3、
+// Cloudflare R2、MinIO、阿里云 OSS 等）、WebDAV 或 SFTP。在硬盘较小的 VPS 上运行时
+// 可以在上传后删除本地文件。
+package upload
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// 远程存储类型
+const (
+	BackendS3     = "s3"
+	BackendWebDAV = "webdav"
+	BackendSFTP   = "sftp"
+)
+
+// 上传的文件种类
+const (
+	// KindVideo 下载的视频和带字幕的视频
+	KindVideo = "video"
+	// KindAudio 转录时提取的音频（保留时）
+	KindAudio = "audio"
+	// KindTranscript 转录文本、字幕、JSON 和摘要
+	KindTranscript = "transcript"
+)
+
+// Config 远程存储配置
+type Config struct {
+	// Backend 远程存储类型 s3 / webdav / sftp，为空时不上传
+	Backend string
+	// Include 上传的文件种类 video / audio / transcript，为空时上传视频和转录文件
+	Include []string
+	// Prefix 远程路径前缀，文件按相对下载目录的路径保存在其中
+	Prefix string
+	// PublicURL 设置后记录的远程地址为 <public_url>/<远程路径>，例如 CDN 或存储桶的公开域名
+	PublicURL string
+	// DeleteLocal 上传成功后删除本地文件
+	DeleteLocal bool
+
+	S3     S3Config
+	WebDAV WebDAVConfig
+	SFTP   SFTPConfig
+}
+
+// S3Config S3 兼容对象存储的配置
+type S3Config struct {
+	// Endpoint 服务地址，例如 https://<account>.r2.cloudflarestorage.com，默认 https://s3.<region>.amazonaws.com
+	Endpoint string
+	// Region 区域，默认 us-east-1（R2 使用 auto）
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle 使用 <endpoint>/<bucket>/<key> 形式的地址（MinIO 等自建服务通常需要），
+	// 默认使用 <bucket>.<endpoint>/<key>
+	PathStyle bool
+}
+
+// WebDAVConfig WebDAV 的配置
+type WebDAVConfig struct {
+	// URL 上传目录的地址，例如 https://dav.example.com/remote.php/dav/files/me/zhihu
+	URL      string
+	Username string
+	Password string
+}
+
+// SFTPConfig SFTP 的配置，通过系统的 sftp 命令上传，只支持密钥认证
+type SFTPConfig struct {
+	Host string
+	// Port 端口，0 表示使用 ssh 配置中的端口（默认 22）
+	Port int
+	User string
+	// Dir 远程目录，相对路径相对于用户主目录
+	Dir string
+	// KeyFile 私钥文件，为空时使用 ssh 的默认密钥和 ssh-agent
+	KeyFile string
+}
+
+var (
+	configMu sync.RWMutex
+	config   Config
+)
+
+// httpClient 上传大文件可能需要很长时间，不设置超时，由任务的 ctx 控制
+var httpClient = &http.Client{}
+
+// SetConfig 设置远程存储配置
+func SetConfig(c Config) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	config = c
+}
+
+func currentConfig() Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config
+}
+
+// Validate 检查配置，Backend 为空时不检查其他项
+func Validate(c Config) error {
+	for _, kind := range c.Include {
+		if kind != KindVideo && kind != KindAudio && kind != KindTranscript {
+			return fmt.Errorf("include 只能包含 video、audio、transcript: %s", kind)
+		}
+	}
+	if c.PublicURL != "" {
+		if err := checkURL(c.PublicURL); err != nil {
+			return fmt.Errorf("public_url %v", err)
+		}
+	}
+	switch c.Backend {
+	case "":
+		return nil
+	case BackendS3:
+		if c.S3.Bucket == "" || c.S3.AccessKey == "" || c.S3.SecretKey == "" {
+			return fmt.Errorf("s3 需要设置 bucket、access_key 和 secret_key")
+		}
+		if c.S3.Endpoint != "" {
+			if err := checkURL(c.S3.Endpoint); err != nil {
+				return fmt.Errorf("s3.endpoint %v", err)
+			}
+		}
+	case BackendWebDAV:
+		if err := checkURL(c.WebDAV.URL); err != nil {
+			return fmt.Errorf("webdav.url %v", err)
+		}
+	case BackendSFTP:
+		if c.SFTP.Host == "" {
+			return fmt.Errorf("sftp 需要设置 host")
+		}
+		if c.SFTP.Port < 0 || c.SFTP.Port > 65535 {
+			return fmt.Errorf("sftp.port 无效: %d", c.SFTP.Port)
+		}
+	default:
+		return fmt.Errorf("不支持的远程存储类型: %s（可选 s3、webdav、sftp）", c.Backend)
+	}
+	return nil
+}
+
+func checkURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("不是有效的 http(s) 地址: %s", s)
+	}
+	return nil
+}
+
+// Enabled 是否配置了远程存储
+func Enabled() bool {
+	return currentConfig().Backend != ""
+}
+
+// Includes 是否上传 kind 种类的文件
+func Includes(kind string) bool {
+	c := currentConfig()
+	if c.Backend == "" {
+		return false
+	}
+	if len(c.Include) == 0 {
+		return kind == KindVideo || kind == KindTranscript
+	}
+	return slices.Contains(c.Include, kind)
+}
+
+// DeleteLocal 上传成功后是否删除本地文件
+func DeleteLocal() bool {
+	c := currentConfig()
+	return c.Backend != "" && c.DeleteLocal
+}
+
+// Upload 上传本地文件，key 为相对于 Prefix 的远程路径（用 / 分隔），返回文件的远程地址
+func Upload(ctx context.Context, localPath, key string) (string, error) {
+	c := currentConfig()
+	key = path.Join(strings.Trim(c.Prefix, "/"), filepath.ToSlash(key))
+	key = strings.TrimPrefix(path.Clean("/"+key), "/")
+
+	var (
+		remote string
+		err    error
+	)
+	switch c.Backend {
+	case BackendS3:
+		remote, err = uploadS3(ctx, c.S3, localPath, key)
+	case BackendWebDAV:
+		remote, err = uploadWebDAV(ctx, c.WebDAV, localPath, key)
+	case BackendSFTP:
+		remote, err = uploadSFTP(ctx, c.SFTP, localPath, key)
+	default:
+		return "", fmt.Errorf("没有配置远程存储")
+	}
+	if err != nil {
+		return "", err
+	}
+	if c.PublicURL != "" {
+		remote = strings.TrimSuffix(c.PublicURL, "/") + "/" + escapePath(key)
+	}
+	return remote, nil
+}
+
+// escapePath 按 S3 签名的规则编码路径：除字母、数字、-_.~ 和 / 外都编码为 %XX
+func escapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// contentType 按扩展名返回上传时的 Content-Type
+func contentType(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".mp4":
+		return "video/mp4"
+	case ".mkv":
+		return "video/x-matroska"
+	case ".mp3":
+		return "audio/mpeg"
+	case ".m4a":
+		return "audio/mp4"
+	case ".wav":
+		return "audio/wav"
+	case ".flac":
+		return "audio/flac"
+	case ".txt", ".srt":
+		return "text/plain; charset=utf-8"
+	case ".md":
+		return "text/markdown; charset=utf-8"
+	case ".json":
+		return "application/json"
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,68 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// uploadWebDAV 逐级创建目录（MKCOL）后用 PUT 上传文件，返回文件地址
+func uploadWebDAV(ctx context.Context, c WebDAVConfig, localPath, key string) (string, error) {
+	base := strings.TrimSuffix(c.URL, "/")
+	parts := strings.Split(key, "/")
+	for i := 1; i < len(parts); i++ {
+		dir := base + "/" + escapePath(strings.Join(parts[:i], "/")) + "/"
+		// 目录已存在时返回 405 Method Not Allowed
+		if err := webdavDo(ctx, c, "MKCOL", dir, nil, 0, http.StatusMethodNotAllowed); err != nil {
+			return "", err
+		}
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	remote := base + "/" + escapePath(key)
+	if err := webdavDo(ctx, c, http.MethodPut, remote, f, info.Size()); err != nil {
+		return "", err
+	}
+	return remote, nil
+}
+
+// webdavDo 发送请求，2xx 和 okStatus 中的状态码视为成功
+func webdavDo(ctx context.Context, c WebDAVConfig, method, target string, body io.Reader, size int64, okStatus ...int) error {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.ContentLength = size
+		req.Header.Set("Content-Type", contentType(target))
+	}
+	if c.Username != "" || c.Password != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("WebDAV %s 失败: %v", method, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	for _, status := range okStatus {
+		if resp.StatusCode == status {
+			return nil
+		}
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("WebDAV %s %s 失败: %s %s", method, target, resp.Status, strings.TrimSpace(string(msg)))
+}
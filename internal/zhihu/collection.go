@@ -0,0 +1,276 @@
+package zhihu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CollectionType 合集类型
+type CollectionType string
+
+const (
+	// TypeColumn 专栏
+	TypeColumn CollectionType = "column"
+	// TypeFavorites 收藏夹
+	TypeFavorites CollectionType = "collection"
+	// TypeUserVideos 用户发布的视频
+	TypeUserVideos CollectionType = "user_videos"
+	// TypeUserAnswers 用户的回答
+	TypeUserAnswers CollectionType = "user_answers"
+	// TypeQuestion 问题下的所有回答
+	TypeQuestion CollectionType = "question"
+)
+
+// DefaultCollectionLimit 合集最多下载的视频数
+const DefaultCollectionLimit = 200
+
+// CollectionItem 合集中的一个视频
+type CollectionItem struct {
+	URL   string `json:"url"`
+	Title string `json:"title,omitempty"`
+	// Source 视频嵌入在回答或文章中时为其链接
+	Source string `json:"source,omitempty"`
+}
+
+// Collection 专栏、收藏夹、用户视频页等包含多个视频的页面
+type Collection struct {
+	Type  CollectionType   `json:"type"`
+	ID    string           `json:"id"`
+	URL   string           `json:"url"`
+	Title string           `json:"title"`
+	Items []CollectionItem `json:"items"`
+}
+
+var collectionPatterns = []struct {
+	typ CollectionType
+	re  *regexp.Regexp
+}{
+	{TypeFavorites, regexp.MustCompile(`zhihu\.com/collection/(\d+)`)},
+	{TypeUserVideos, regexp.MustCompile(`zhihu\.com/(?:people|org)/([\w-]+)/zvideos`)},
+	{TypeUserAnswers, regexp.MustCompile(`zhihu\.com/(?:people|org)/([\w-]+)/answers`)},
+	{TypeQuestion, regexp.MustCompile(`zhihu\.com/question/(\d+)/?(?:[?#].*)?$`)},
+	{TypeColumn, regexp.MustCompile(`zhihu\.com/column/([\w-]+)`)},
+	{TypeColumn, regexp.MustCompile(`zhuanlan\.zhihu\.com/([\w-]+)/?(?:[?#].*)?$`)},
+}
+
+// collectionPageDelay 翻页间隔，避免请求过快被限流
+const collectionPageDelay = 500 * time.Millisecond
+
+// ParseCollectionURL 识别合集链接，返回类型和 ID
+func ParseCollectionURL(raw string) (CollectionType, string, error) {
+	for _, p := range collectionPatterns {
+		if m := p.re.FindStringSubmatch(raw); m != nil && !(p.typ == TypeColumn && m[1] == "p") {
+			return p.typ, m[1], nil
+		}
+	}
+	return "", "", fmt.Errorf("不是知乎专栏、收藏夹、问题或用户视频 / 回答页链接: %s", raw)
+}
+
+// IsCollectionURL 判断是否为合集链接
+func IsCollectionURL(raw string) bool {
+	_, _, err := ParseCollectionURL(raw)
+	return err == nil
+}
+
+// collectionEntry 列表接口返回的一项。收藏夹的内容在 content 中，其他接口直接是内容本身
+type collectionEntry struct {
+	Type     string      `json:"type"`
+	ID       flexString  `json:"id"`
+	URL      string      `json:"url"`
+	Title    string      `json:"title"`
+	Content  interface{} `json:"content"`
+	Question struct {
+		Title string `json:"title"`
+	} `json:"question"`
+}
+
+type collectionPage struct {
+	Data   []json.RawMessage `json:"data"`
+	Paging struct {
+		IsEnd bool   `json:"is_end"`
+		Next  string `json:"next"`
+	} `json:"paging"`
+}
+
+// FetchCollection 翻页列出合集中的所有视频（最多 limit 个，0 为 DefaultCollectionLimit）。
+// 回答和文章中嵌入的视频也会列出；onProgress 在每页处理后收到已找到的视频数
+func FetchCollection(ctx context.Context, rawURL string, limit int, onProgress func(found int)) (*Collection, error) {
+	typ, id, err := ParseCollectionURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = DefaultCollectionLimit
+	}
+
+	c := &Collection{Type: typ, ID: id, URL: rawURL, Title: collectionTitle(ctx, typ, id)}
+	seen := map[string]bool{}
+	add := func(item CollectionItem) {
+		if !seen[item.URL] && len(c.Items) < limit {
+			seen[item.URL] = true
+			c.Items = append(c.Items, item)
+		}
+	}
+
+	next := collectionAPI(typ, id)
+	for next != "" && len(c.Items) < limit {
+		body, err := get(ctx, next)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if len(c.Items) > 0 {
+				// 已经拿到部分视频时不整体失败，下载已找到的部分
+				break
+			}
+			return nil, fmt.Errorf("获取合集内容失败: %v", err)
+		}
+		var page collectionPage
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("解析合集内容失败: %v", err)
+		}
+		for _, raw := range page.Data {
+			for _, item := range collectionItems(ctx, raw) {
+				add(item)
+			}
+		}
+		if onProgress != nil {
+			onProgress(len(c.Items))
+		}
+		if page.Paging.IsEnd || len(page.Data) == 0 {
+			break
+		}
+		next = strings.Replace(page.Paging.Next, "http://", "https://", 1)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(collectionPageDelay):
+		}
+	}
+
+	if len(c.Items) == 0 {
+		return nil, fmt.Errorf("合集中没有找到视频，可能需要登录或内容已被删除")
+	}
+	return c, nil
+}
+
+func collectionAPI(typ CollectionType, id string) string {
+	const api = "https://www.zhihu.com/api/v4/"
+	switch typ {
+	case TypeFavorites:
+		return api + "collections/" + id + "/items?offset=0&limit=20"
+	case TypeUserVideos:
+		return api + "members/" + id + "/zvideos?offset=0&limit=20"
+	case TypeUserAnswers:
+		return api + "members/" + id + "/answers?include=data%5B*%5D.content&offset=0&limit=20&sort_by=created"
+	case TypeQuestion:
+		return api + "questions/" + id + "/answers?include=data%5B*%5D.content&offset=0&limit=20&sort_by=default"
+	default:
+		return api + "columns/" + id + "/items?offset=0&limit=20"
+	}
+}
+
+// collectionTitle 获取合集名称，失败时使用 ID
+func collectionTitle(ctx context.Context, typ CollectionType, id string) string {
+	var apiURL string
+	switch typ {
+	case TypeFavorites:
+		apiURL = "https://www.zhihu.com/api/v4/collections/" + id
+	case TypeColumn:
+		apiURL = "https://www.zhihu.com/api/v4/columns/" + id
+	case TypeQuestion:
+		apiURL = "https://www.zhihu.com/api/v4/questions/" + id
+	case TypeUserVideos, TypeUserAnswers:
+		apiURL = "https://www.zhihu.com/api/v4/members/" + id
+	}
+
+	fallback := string(typ) + "_" + id
+	body, err := get(ctx, apiURL)
+	if err != nil {
+		return fallback
+	}
+	var info struct {
+		Title      string `json:"title"`
+		Name       string `json:"name"`
+		Collection struct {
+			Title string `json:"title"`
+		} `json:"collection"`
+	}
+	if json.Unmarshal(body, &info) != nil {
+		return fallback
+	}
+	switch {
+	case info.Collection.Title != "":
+		return info.Collection.Title
+	case info.Title != "":
+		return info.Title
+	case typ == TypeUserVideos && info.Name != "":
+		return info.Name + " 的视频"
+	case typ == TypeUserAnswers && info.Name != "":
+		return info.Name + " 的回答"
+	}
+	return fallback
+}
+
+// collectionItems 从列表中的一项提取视频：视频直接使用，回答和文章解析正文中嵌入的视频
+func collectionItems(ctx context.Context, raw json.RawMessage) []CollectionItem {
+	var entry collectionEntry
+	if json.Unmarshal(raw, &entry) != nil {
+		return nil
+	}
+	// 收藏夹：{"content": {...}}
+	if inner, ok := entry.Content.(map[string]interface{}); ok {
+		data, _ := json.Marshal(inner)
+		entry = collectionEntry{}
+		if json.Unmarshal(data, &entry) != nil {
+			return nil
+		}
+	}
+
+	title := entry.Title
+	if title == "" {
+		title = entry.Question.Title
+	}
+	switch entry.Type {
+	case "zvideo":
+		return []CollectionItem{{URL: "https://www.zhihu.com/zvideo/" + string(entry.ID), Title: title}}
+	case "answer", "article":
+	default:
+		return nil
+	}
+
+	source := canonicalURL(ContentType(entry.Type), string(entry.ID))
+	html, _ := entry.Content.(string)
+	if html == "" {
+		// 列表接口没有返回正文时单独获取
+		content, err := Fetch(ctx, source)
+		if err != nil {
+			return nil
+		}
+		html = content.HTML
+		if title == "" {
+			title = content.Title
+		}
+	}
+	doc, err := ToMarkdown(html)
+	if err != nil {
+		return nil
+	}
+	var items []CollectionItem
+	for i, v := range doc.Videos {
+		name := v.Title
+		if name == "" {
+			name = title
+			if len(doc.Videos) > 1 {
+				name = fmt.Sprintf("%s_%d", title, i+1)
+			}
+		}
+		items = append(items, CollectionItem{URL: v.URL, Title: name, Source: source})
+	}
+	return items
+}
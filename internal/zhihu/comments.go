@@ -0,0 +1,233 @@
+package zhihu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"zhihu-downloader/internal/atomicfile"
+)
+
+// Comment 一条评论。根评论的 Replies 为接口随评论返回的部分回复（通常是最热的几条），
+// 全部回复数为 ReplyCount
+type Comment struct {
+	ID         string    `json:"id"`
+	Author     string    `json:"author"`
+	ReplyTo    string    `json:"reply_to,omitempty"`
+	Content    string    `json:"content"`
+	Likes      int       `json:"likes"`
+	Created    time.Time `json:"created"`
+	ReplyCount int       `json:"reply_count,omitempty"`
+	Replies    []Comment `json:"replies,omitempty"`
+}
+
+// Comments 视频、回答或文章的评论（按热度排序）
+type Comments struct {
+	URL string `json:"url"`
+	// Key 内容标识，例如 zvideo:123
+	Key string `json:"key"`
+	// Total 知乎显示的评论总数（包括回复）
+	Total     int       `json:"total"`
+	Comments  []Comment `json:"comments"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// SavedComments 保存的评论文件
+type SavedComments struct {
+	JSONPath     string `json:"json_path"`
+	MarkdownPath string `json:"markdown_path"`
+	Count        int    `json:"count"`
+	Total        int    `json:"total"`
+}
+
+type commentItem struct {
+	ID         flexString    `json:"id"`
+	Content    string        `json:"content"`
+	Author     person        `json:"author"`
+	ReplyTo    person        `json:"reply_to_author"`
+	LikeCount  int           `json:"like_count"`
+	VoteCount  int           `json:"vote_count"`
+	Created    int64         `json:"created_time"`
+	ChildCount int           `json:"child_comment_count"`
+	ChildItems []commentItem `json:"child_comments"`
+}
+
+type commentPage struct {
+	Data   []commentItem `json:"data"`
+	Paging struct {
+		IsEnd bool   `json:"is_end"`
+		Next  string `json:"next"`
+	} `json:"paging"`
+	Counts struct {
+		Total int `json:"total_counts"`
+	} `json:"counts"`
+}
+
+// commentTarget 返回评论接口中的资源类型和 ID，只支持知乎视频（zvideo）、回答和文章
+func commentTarget(rawURL string) (resource, key string, err error) {
+	if m := zvideoRe.FindStringSubmatch(rawURL); m != nil {
+		return "zvideos/" + m[1], "zvideo:" + m[1], nil
+	}
+	if typ, id, err := ParseURL(rawURL); err == nil {
+		return string(typ) + "s/" + id, string(typ) + ":" + id, nil
+	}
+	return "", "", fmt.Errorf("只能获取知乎视频（zvideo）、回答和文章的评论: %s", rawURL)
+}
+
+// SupportsComments 判断链接是否可以获取评论
+func SupportsComments(rawURL string) bool {
+	_, _, err := commentTarget(rawURL)
+	return err == nil
+}
+
+// FetchComments 按热度翻页获取评论，最多 limit 条根评论（0 表示全部）
+func FetchComments(ctx context.Context, rawURL string, limit int) (*Comments, error) {
+	resource, key, err := commentTarget(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Comments{URL: rawURL, Key: key, Comments: []Comment{}, FetchedAt: time.Now()}
+	next := "https://www.zhihu.com/api/v4/comment_v5/" + resource + "/root_comment?order_by=score&limit=20&offset="
+	for next != "" && (limit <= 0 || len(c.Comments) < limit) {
+		body, err := get(ctx, next)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if len(c.Comments) > 0 {
+				// 已经拿到部分评论时保存已有的部分
+				break
+			}
+			return nil, fmt.Errorf("获取评论失败: %v", err)
+		}
+		var page commentPage
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("解析评论失败: %v", err)
+		}
+		if page.Counts.Total > 0 {
+			c.Total = page.Counts.Total
+		}
+		for _, item := range page.Data {
+			if limit > 0 && len(c.Comments) >= limit {
+				break
+			}
+			c.Comments = append(c.Comments, item.toComment())
+		}
+		if page.Paging.IsEnd || len(page.Data) == 0 {
+			break
+		}
+		next = strings.Replace(page.Paging.Next, "http://", "https://", 1)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(collectionPageDelay):
+		}
+	}
+	return c, nil
+}
+
+func (item *commentItem) toComment() Comment {
+	c := Comment{
+		ID:         string(item.ID),
+		Author:     item.Author.Name,
+		ReplyTo:    item.ReplyTo.Name,
+		Content:    commentText(item.Content),
+		Likes:      max(item.LikeCount, item.VoteCount),
+		ReplyCount: item.ChildCount,
+	}
+	if item.Created > 0 {
+		c.Created = time.Unix(item.Created, 0)
+	}
+	for _, child := range item.ChildItems {
+		c.Replies = append(c.Replies, child.toComment())
+	}
+	return c
+}
+
+// commentText 把评论 HTML（段落、链接、表情图片）转换为 Markdown 文本
+func commentText(content string) string {
+	if !strings.Contains(content, "<") {
+		return strings.TrimSpace(content)
+	}
+	doc, err := ToMarkdown(content)
+	if err != nil {
+		return strings.TrimSpace(content)
+	}
+	return strings.TrimSpace(doc.Markdown)
+}
+
+// Markdown 把评论格式化为 Markdown，回复以引用块列在评论下方
+func (c *Comments) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# 评论\n\n")
+	fmt.Fprintf(&b, "- 来源：%s\n", c.URL)
+	fmt.Fprintf(&b, "- 评论总数：%d（已保存 %d 条根评论，按热度排序）\n", c.Total, len(c.Comments))
+	fmt.Fprintf(&b, "- 获取时间：%s\n", c.FetchedAt.Format("2006-01-02 15:04"))
+
+	for _, comment := range c.Comments {
+		fmt.Fprintf(&b, "\n---\n\n**%s**%s\n\n", commentAuthor(comment.Author), commentMeta(comment))
+		b.WriteString(comment.Content + "\n")
+		for _, reply := range comment.Replies {
+			name := "**" + commentAuthor(reply.Author) + "**"
+			if reply.ReplyTo != "" && reply.ReplyTo != comment.Author {
+				name += " 回复 **" + commentAuthor(reply.ReplyTo) + "**"
+			}
+			text := strings.ReplaceAll(reply.Content, "\n", "\n> ")
+			fmt.Fprintf(&b, "\n> %s%s\n>\n> %s\n", name, commentMeta(reply), text)
+		}
+		if more := comment.ReplyCount - len(comment.Replies); more > 0 {
+			fmt.Fprintf(&b, "\n> ……还有 %d 条回复\n", more)
+		}
+	}
+	return b.String()
+}
+
+func commentAuthor(name string) string {
+	if name == "" {
+		return "匿名用户"
+	}
+	return name
+}
+
+func commentMeta(c Comment) string {
+	meta := fmt.Sprintf(" · %d 赞", c.Likes)
+	if !c.Created.IsZero() {
+		meta += " · " + c.Created.Format("2006-01-02 15:04")
+	}
+	return meta
+}
+
+// DefaultFilename 单独保存评论时的默认文件名，例如 zhihu_zvideo_123
+func (c *Comments) DefaultFilename() string {
+	return "zhihu_" + strings.Replace(c.Key, ":", "_", 1)
+}
+
+// Save 把评论保存为 <base>.comments.json 和 <base>.comments.md，base 为不含扩展名的路径
+func (c *Comments) Save(base string) (*SavedComments, error) {
+	if err := os.MkdirAll(filepath.Dir(base), 0755); err != nil {
+		return nil, fmt.Errorf("创建输出目录失败: %v", err)
+	}
+	saved := &SavedComments{
+		JSONPath:     base + ".comments.json",
+		MarkdownPath: base + ".comments.md",
+		Count:        len(c.Comments),
+		Total:        c.Total,
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := atomicfile.WriteFile(saved.JSONPath, data, 0644); err != nil {
+		return nil, fmt.Errorf("保存评论失败: %v", err)
+	}
+	if err := atomicfile.WriteFile(saved.MarkdownPath, []byte(c.Markdown()), 0644); err != nil {
+		return nil, fmt.Errorf("保存评论失败: %v", err)
+	}
+	return saved, nil
+}
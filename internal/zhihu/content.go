@@ -0,0 +1,222 @@
+// Package zhihu 抓取知乎回答/专栏文章，并转换为 Markdown 保存。
+package zhihu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+
+	"zhihu-downloader/internal/downloader"
+)
+
+// ContentType 内容类型
+type ContentType string
+
+const (
+	TypeAnswer  ContentType = "answer"
+	TypeArticle ContentType = "article"
+)
+
+// Content 知乎回答或文章
+type Content struct {
+	Type    ContentType
+	ID      string
+	URL     string
+	Title   string
+	Author  string
+	VoteUp  int
+	Created time.Time
+	// HTML 正文（知乎返回的原始 HTML）
+	HTML string
+}
+
+var (
+	answerRe      = regexp.MustCompile(`zhihu\.com/(?:question/\d+/)?answers?/(\d+)`)
+	articleRe     = regexp.MustCompile(`zhuanlan\.zhihu\.com/p/(\d+)`)
+	initialDataRe = regexp.MustCompile(`(?s)<script id="js-initialData" type="text/json">(.*?)</script>`)
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// ParseURL 识别回答/文章链接，返回内容类型和 ID
+func ParseURL(raw string) (ContentType, string, error) {
+	if m := answerRe.FindStringSubmatch(raw); m != nil {
+		return TypeAnswer, m[1], nil
+	}
+	if m := articleRe.FindStringSubmatch(raw); m != nil {
+		return TypeArticle, m[1], nil
+	}
+	return "", "", fmt.Errorf("不是知乎回答或文章链接: %s", raw)
+}
+
+// IsContentURL 判断是否为知乎回答或文章链接
+func IsContentURL(raw string) bool {
+	_, _, err := ParseURL(raw)
+	return err == nil
+}
+
+// Fetch 获取回答/文章内容。优先使用 API，失败时从页面内嵌的 js-initialData 中解析
+func Fetch(ctx context.Context, rawURL string) (*Content, error) {
+	typ, id, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	content, apiErr := fetchAPI(ctx, typ, id)
+	if apiErr != nil {
+		var pageErr error
+		content, pageErr = fetchPage(ctx, typ, id)
+		if pageErr != nil {
+			return nil, fmt.Errorf("获取内容失败: %v; %v", apiErr, pageErr)
+		}
+	}
+	if content.HTML == "" {
+		return nil, fmt.Errorf("内容为空，可能需要登录或已被删除")
+	}
+	content.Type = typ
+	content.ID = id
+	content.URL = canonicalURL(typ, id)
+	return content, nil
+}
+
+func canonicalURL(typ ContentType, id string) string {
+	if typ == TypeArticle {
+		return "https://zhuanlan.zhihu.com/p/" + id
+	}
+	return "https://www.zhihu.com/answer/" + id
+}
+
+// apiItem API 与 js-initialData 共用的字段
+type apiItem struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	Author  person `json:"author"`
+	// API 返回 voteup_count，页面数据为 voteupCount
+	VoteUp       int   `json:"voteup_count"`
+	VoteUpCamel  int   `json:"voteupCount"`
+	Created      int64 `json:"created"`
+	CreatedTime  int64 `json:"created_time"`
+	CreatedCamel int64 `json:"createdTime"`
+	Question     struct {
+		Title string `json:"title"`
+	} `json:"question"`
+}
+
+// person 作者信息；页面数据中作者可能只是一个引用字符串，此时忽略
+type person struct {
+	Name string
+}
+
+func (p *person) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Name string `json:"name"`
+	}
+	if json.Unmarshal(data, &v) == nil {
+		p.Name = v.Name
+	}
+	return nil
+}
+
+func (item *apiItem) toContent(typ ContentType) *Content {
+	c := &Content{
+		Title:  item.Title,
+		Author: item.Author.Name,
+		VoteUp: max(item.VoteUp, item.VoteUpCamel),
+		HTML:   item.Content,
+	}
+	if typ == TypeAnswer {
+		c.Title = item.Question.Title
+	}
+	if ts := max(item.Created, item.CreatedTime, item.CreatedCamel); ts > 0 {
+		c.Created = time.Unix(ts, 0)
+	}
+	return c
+}
+
+func fetchAPI(ctx context.Context, typ ContentType, id string) (*Content, error) {
+	var apiURL string
+	if typ == TypeArticle {
+		apiURL = "https://www.zhihu.com/api/v4/articles/" + id
+	} else {
+		apiURL = "https://www.zhihu.com/api/v4/answers/" + id +
+			"?include=content,voteup_count,created_time,question,author"
+	}
+
+	body, err := get(ctx, apiURL)
+	if err != nil {
+		return nil, err
+	}
+	var item apiItem
+	if err := json.Unmarshal(body, &item); err != nil {
+		return nil, fmt.Errorf("解析 API 响应失败: %v", err)
+	}
+	return item.toContent(typ), nil
+}
+
+func fetchPage(ctx context.Context, typ ContentType, id string) (*Content, error) {
+	body, err := get(ctx, canonicalURL(typ, id))
+	if err != nil {
+		return nil, err
+	}
+	m := initialDataRe.FindSubmatch(body)
+	if m == nil {
+		return nil, fmt.Errorf("页面中未找到内容数据")
+	}
+
+	var data struct {
+		InitialState struct {
+			Entities struct {
+				Answers   map[string]apiItem `json:"answers"`
+				Articles  map[string]apiItem `json:"articles"`
+				Questions map[string]struct {
+					Title string `json:"title"`
+				} `json:"questions"`
+			} `json:"entities"`
+		} `json:"initialState"`
+	}
+	if err := json.Unmarshal(m[1], &data); err != nil {
+		return nil, fmt.Errorf("解析页面数据失败: %v", err)
+	}
+
+	entities := data.InitialState.Entities
+	items := entities.Answers
+	if typ == TypeArticle {
+		items = entities.Articles
+	}
+	item, ok := items[id]
+	if !ok {
+		return nil, fmt.Errorf("页面数据中没有 %s %s", typ, id)
+	}
+	content := item.toContent(typ)
+	if content.Title == "" && typ == TypeAnswer {
+		// 页面数据里回答只引用问题，标题在 questions 中
+		for _, q := range entities.Questions {
+			content.Title = q.Title
+			break
+		}
+	}
+	return content, nil
+}
+
+func get(ctx context.Context, rawURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = downloader.HeadersFor(rawURL)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, rawURL)
+	}
+	return io.ReadAll(resp.Body)
+}
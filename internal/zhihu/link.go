@@ -0,0 +1,201 @@
+package zhihu
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"zhihu-downloader/internal/downloader"
+	"zhihu-downloader/internal/errcode"
+)
+
+// LinkType 链接类型
+type LinkType string
+
+const (
+	// LinkZVideo 知乎视频 zhihu.com/zvideo/<id>
+	LinkZVideo LinkType = "zvideo"
+	// LinkVideo 视频播放页 zhihu.com/video/<id>（Lens 视频 ID）
+	LinkVideo LinkType = "video"
+	// LinkAnswer / LinkArticle 回答和专栏文章，下载其中嵌入的第一个视频
+	LinkAnswer  LinkType = "answer"
+	LinkArticle LinkType = "article"
+	// LinkTraining 训练营视频
+	LinkTraining LinkType = "training"
+	// LinkPage 其他知乎页面（例如想法），从页面中查找视频
+	LinkPage LinkType = "page"
+	// LinkExternal 其他网站的视频页或直链，交给 yt-dlp / ffmpeg
+	LinkExternal LinkType = "external"
+)
+
+// Link 规范化之后的链接
+type Link struct {
+	Type LinkType `json:"type"`
+	// ID 视频、回答或文章 ID，LinkPage 和 LinkExternal 为空
+	ID string `json:"id,omitempty"`
+	// URL 规范化后的链接：知乎链接去掉分享参数，转为桌面版地址
+	URL string `json:"url"`
+}
+
+var (
+	// 分享文本中的链接，例如 “【标题】https://www.zhihu.com/zvideo/123?utm_psn=... 复制此链接...”
+	linkInTextRe = regexp.MustCompile(`(?i)(?:https?://|www\.|zhihu\.com/|zhuanlan\.zhihu\.com/)[^\s"'<>，。！？、；：“”‘’（）【】《》]+`)
+	// App 内嵌页面：zhihu.com/appview/answer/<id>、zhihu.com/appview/p/<id>
+	appAnswerRe  = regexp.MustCompile(`zhihu\.com/appview/answer/(\d+)`)
+	appArticleRe = regexp.MustCompile(`zhihu\.com/appview/p/(\d+)`)
+	trainingRe   = regexp.MustCompile(`zhihu\.com/.*training-video/`)
+	liveRe       = regexp.MustCompile(`zhihu\.com/lives?(?:/|$)`)
+	// 无法下载的知乎页面：用户主页、话题、搜索、热榜、首页
+	unsupportedRes = []struct {
+		re  *regexp.Regexp
+		msg string
+	}{
+		{regexp.MustCompile(`zhihu\.com/(?:people|org)/[\w-]+/?(?:[?#].*)?$`), "知乎用户主页不能直接下载，请使用用户的 /zvideos 或 /answers 页面下载合集"},
+		{regexp.MustCompile(`zhihu\.com/topic/`), "知乎话题页不能直接下载"},
+		{regexp.MustCompile(`zhihu\.com/search`), "知乎搜索页不能直接下载"},
+		{regexp.MustCompile(`zhihu\.com/(?:hot|billboard)`), "知乎热榜不能直接下载"},
+		{regexp.MustCompile(`zhihu\.com/?(?:[?#].*)?$`), "知乎首页不能直接下载"},
+	}
+)
+
+// shortLinkHosts 需要跟随跳转才能知道目标的短链接
+var shortLinkHosts = []string{"t.cn", "url.cn", "dwz.cn", "xg.zhihu.com"}
+
+// ParseLink 从用户输入（可以是带标题的分享文本）中提取链接并识别类型，不访问网络。
+// 知乎的合集链接和 Live 等不能下载单个视频的链接返回错误
+func ParseLink(raw string) (*Link, error) {
+	u, err := normalizeURL(raw)
+	if err != nil {
+		return nil, err
+	}
+	s := u.String()
+	if !isZhihuHost(u.Hostname()) {
+		return &Link{Type: LinkExternal, URL: s}, nil
+	}
+
+	switch {
+	case zvideoRe.MatchString(s):
+		id := zvideoRe.FindStringSubmatch(s)[1]
+		return &Link{Type: LinkZVideo, ID: id, URL: "https://www.zhihu.com/zvideo/" + id}, nil
+	case lensRe.MatchString(s):
+		id := lensRe.FindStringSubmatch(s)[1]
+		return &Link{Type: LinkVideo, ID: id, URL: "https://www.zhihu.com/video/" + id}, nil
+	case appAnswerRe.MatchString(s):
+		id := appAnswerRe.FindStringSubmatch(s)[1]
+		return &Link{Type: LinkAnswer, ID: id, URL: canonicalURL(TypeAnswer, id)}, nil
+	case appArticleRe.MatchString(s):
+		id := appArticleRe.FindStringSubmatch(s)[1]
+		return &Link{Type: LinkArticle, ID: id, URL: canonicalURL(TypeArticle, id)}, nil
+	}
+	if typ, id, err := ParseURL(s); err == nil {
+		return &Link{Type: LinkType(typ), ID: id, URL: canonicalURL(typ, id)}, nil
+	}
+	if trainingRe.MatchString(s) {
+		return &Link{Type: LinkTraining, URL: s}, nil
+	}
+	if liveRe.MatchString(s) {
+		return nil, fmt.Errorf("知乎 Live 是音频课程，暂不支持下载: %s", s)
+	}
+	if IsCollectionURL(s) {
+		return nil, fmt.Errorf("这是知乎问题、专栏、收藏夹或用户视频 / 回答页链接，请使用合集下载: %s", s)
+	}
+	for _, p := range unsupportedRes {
+		if p.re.MatchString(s) {
+			return nil, fmt.Errorf("%s: %s", p.msg, s)
+		}
+	}
+	return &Link{Type: LinkPage, URL: s}, nil
+}
+
+// ResolveLink 与 ParseLink 相同，但短链接和无法识别的知乎链接先跟随跳转，再按跳转后的地址识别。
+// 跳转失败时按原链接处理
+func ResolveLink(ctx context.Context, raw string) (*Link, error) {
+	link, err := ParseLink(raw)
+	if err != nil {
+		return nil, err
+	}
+	if link.Type != LinkPage && !(link.Type == LinkExternal && isShortLink(link.URL)) {
+		return link, nil
+	}
+	final, err := followRedirects(ctx, link.URL)
+	if err != nil || final == link.URL {
+		return link, nil
+	}
+	return ParseLink(final)
+}
+
+// normalizeURL 提取分享文本中的链接，补全协议，去掉 link.zhihu.com 外链跳转和知乎链接的分享参数
+func normalizeURL(raw string) (*url.URL, error) {
+	m := linkInTextRe.FindString(raw)
+	if m == "" {
+		return nil, errcode.Newf(errcode.URLInvalid, "没有找到链接: %s", strings.TrimSpace(raw))
+	}
+	raw = m
+	if !strings.Contains(strings.ToLower(raw), "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(unwrapLink(raw))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, errcode.Newf(errcode.URLInvalid, "无效的链接: %s", raw)
+	}
+	if !isZhihuHost(u.Hostname()) {
+		return u, nil
+	}
+
+	u.Scheme = "https"
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	// 登录页：https://www.zhihu.com/signin?next=/zvideo/123
+	if strings.HasPrefix(u.Path, "/signin") || strings.HasPrefix(u.Path, "/signup") {
+		if next := u.Query().Get("next"); strings.HasPrefix(next, "/") {
+			return normalizeURL("https://www.zhihu.com" + next)
+		}
+	}
+	q := u.Query()
+	for key := range q {
+		if strings.HasPrefix(key, "utm_") || key == "share_code" || key == "s_r" || key == "s_s_i" {
+			q.Del(key)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u, nil
+}
+
+func isZhihuHost(host string) bool {
+	host = strings.ToLower(host)
+	return host == "zhihu.com" || strings.HasSuffix(host, ".zhihu.com")
+}
+
+func isShortLink(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, h := range shortLinkHosts {
+		if host == h {
+			return true
+		}
+	}
+	return false
+}
+
+// followRedirects 访问链接并返回跳转后的地址。链接由用户提供，跳转时还没有经过 CheckURL，
+// 使用 downloader 的受检查的客户端，跳转到内网地址时连接被拒绝
+func followRedirects(ctx context.Context, rawURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header = downloader.HeadersFor(rawURL)
+	resp, err := downloader.GuardedClient(30 * time.Second).Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Request.URL.String(), nil
+}
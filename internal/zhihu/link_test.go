@@ -0,0 +1,39 @@
+package zhihu
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"zhihu-downloader/internal/downloader"
+)
+
+func TestFollowRedirectsGuarded(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/s" {
+			http.Redirect(w, r, "/zvideo/1", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+	defer downloader.SetURLPolicy(downloader.URLPolicy{})
+
+	tests := []struct {
+		name    string
+		policy  downloader.URLPolicy
+		want    string
+		wantErr bool
+	}{
+		{"内网地址", downloader.URLPolicy{}, "", true},
+		{"允许内网地址", downloader.URLPolicy{AllowPrivate: true}, srv.URL + "/zvideo/1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			downloader.SetURLPolicy(tt.policy)
+			got, err := followRedirects(context.Background(), srv.URL+"/s")
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Fatalf("followRedirects = %q, %v，应为 %q（出错: %v）", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,356 @@
+package zhihu
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Video 正文中嵌入的视频
+type Video struct {
+	ID    string `json:"id"`
+	Title string `json:"title,omitempty"`
+	URL   string `json:"url"`
+}
+
+// Document 转换后的 Markdown 正文
+type Document struct {
+	Markdown string
+	// Images 正文引用的图片地址（按出现顺序，已去重）
+	Images []string
+	Videos []Video
+}
+
+var (
+	spaceRe      = regexp.MustCompile(`[ \t\r\n]+`)
+	blankLinesRe = regexp.MustCompile(`\n{3,}`)
+)
+
+// ToMarkdown 把知乎正文 HTML 转换为 Markdown
+func ToMarkdown(content string) (*Document, error) {
+	nodes, err := html.ParseFragment(strings.NewReader(content), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("解析 HTML 失败: %v", err)
+	}
+
+	c := &converter{seen: map[string]bool{}}
+	for _, n := range nodes {
+		c.render(n)
+	}
+
+	md := blankLinesRe.ReplaceAllString(c.buf.String(), "\n\n")
+	return &Document{
+		Markdown: strings.TrimSpace(md) + "\n",
+		Images:   c.images,
+		Videos:   c.videos,
+	}, nil
+}
+
+type converter struct {
+	buf    strings.Builder
+	images []string
+	videos []Video
+	seen   map[string]bool
+	// 列表嵌套：每层记录有序列表的当前序号，无序列表为 0
+	lists []int
+	pre   bool
+}
+
+func (c *converter) blankLine() {
+	s := c.buf.String()
+	if s == "" || strings.HasSuffix(s, "\n\n") {
+		return
+	}
+	if strings.HasSuffix(s, "\n") {
+		c.buf.WriteString("\n")
+		return
+	}
+	c.buf.WriteString("\n\n")
+}
+
+func (c *converter) children(n *html.Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.render(child)
+	}
+}
+
+// sub 在独立的 converter 中渲染子节点，用于引用块等需要整体加前缀的场景
+func (c *converter) sub(n *html.Node) string {
+	s := &converter{seen: c.seen, lists: c.lists}
+	s.children(n)
+	c.images = append(c.images, s.images...)
+	c.videos = append(c.videos, s.videos...)
+	return strings.TrimSpace(s.buf.String())
+}
+
+func (c *converter) render(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		if c.pre {
+			c.buf.WriteString(n.Data)
+			return
+		}
+		text := spaceRe.ReplaceAllString(n.Data, " ")
+		if strings.HasSuffix(c.buf.String(), "\n") {
+			text = strings.TrimLeft(text, " ")
+		}
+		c.buf.WriteString(text)
+		return
+	case html.ElementNode:
+	default:
+		c.children(n)
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.P, atom.Div, atom.Section:
+		if len(c.lists) > 0 {
+			c.children(n)
+			return
+		}
+		c.blankLine()
+		c.children(n)
+		c.blankLine()
+	case atom.Br:
+		c.buf.WriteString("  \n")
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(n.Data[1] - '0')
+		c.blankLine()
+		c.buf.WriteString(strings.Repeat("#", level) + " ")
+		c.buf.WriteString(strings.TrimSpace(c.sub(n)))
+		c.blankLine()
+	case atom.B, atom.Strong:
+		c.wrap(n, "**")
+	case atom.I, atom.Em:
+		c.wrap(n, "*")
+	case atom.Del, atom.S:
+		c.wrap(n, "~~")
+	case atom.Code:
+		if c.pre {
+			c.children(n)
+			return
+		}
+		c.wrap(n, "`")
+	case atom.Pre:
+		c.renderPre(n)
+	case atom.Hr:
+		c.blankLine()
+		c.buf.WriteString("---")
+		c.blankLine()
+	case atom.Blockquote:
+		text := c.sub(n)
+		c.blankLine()
+		for _, line := range strings.Split(text, "\n") {
+			c.buf.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+		}
+		c.blankLine()
+	case atom.Ul, atom.Ol:
+		if len(c.lists) == 0 {
+			c.blankLine()
+		}
+		start := 0
+		if n.DataAtom == atom.Ol {
+			start = 1
+		}
+		c.lists = append(c.lists, start)
+		c.children(n)
+		c.lists = c.lists[:len(c.lists)-1]
+		if len(c.lists) == 0 {
+			c.blankLine()
+		}
+	case atom.Li:
+		c.renderListItem(n)
+	case atom.Figure:
+		c.blankLine()
+		c.children(n)
+		c.blankLine()
+	case atom.Figcaption:
+		if caption := strings.TrimSpace(c.sub(n)); caption != "" {
+			c.buf.WriteString("\n*" + caption + "*")
+		}
+	case atom.Img:
+		c.renderImage(n)
+	case atom.A:
+		c.renderLink(n)
+	case atom.Noscript, atom.Script, atom.Style:
+		// noscript 中是重复的图片，脚本和样式直接忽略
+	default:
+		c.children(n)
+	}
+}
+
+func (c *converter) wrap(n *html.Node, mark string) {
+	text := c.sub(n)
+	if text == "" {
+		return
+	}
+	c.buf.WriteString(mark + text + mark)
+}
+
+func (c *converter) renderPre(n *html.Node) {
+	lang := ""
+	for node := n; node != nil; node = node.FirstChild {
+		for _, class := range strings.Fields(attr(node, "class")) {
+			if strings.HasPrefix(class, "language-") {
+				lang = strings.TrimPrefix(class, "language-")
+			}
+		}
+	}
+
+	c.blankLine()
+	c.buf.WriteString("```" + lang + "\n")
+	c.pre = true
+	c.children(n)
+	c.pre = false
+	if !strings.HasSuffix(c.buf.String(), "\n") {
+		c.buf.WriteString("\n")
+	}
+	c.buf.WriteString("```")
+	c.blankLine()
+}
+
+func (c *converter) renderListItem(n *html.Node) {
+	depth := len(c.lists)
+	if depth == 0 {
+		c.children(n)
+		return
+	}
+
+	marker := "- "
+	if index := c.lists[depth-1]; index > 0 {
+		marker = fmt.Sprintf("%d. ", index)
+		c.lists[depth-1]++
+	}
+
+	if !strings.HasSuffix(c.buf.String(), "\n") {
+		c.buf.WriteString("\n")
+	}
+	c.buf.WriteString(strings.Repeat("  ", depth-1) + marker)
+	c.children(n)
+	if !strings.HasSuffix(c.buf.String(), "\n") {
+		c.buf.WriteString("\n")
+	}
+}
+
+func (c *converter) renderImage(n *html.Node) {
+	// 公式图片：alt 中是 LaTeX
+	if attr(n, "eeimg") != "" {
+		if formula := attr(n, "alt"); formula != "" {
+			c.buf.WriteString("$" + formula + "$")
+		}
+		return
+	}
+
+	src := firstAttr(n, "data-original", "data-actualsrc", "src")
+	if src == "" || strings.HasPrefix(src, "data:") {
+		return
+	}
+	if strings.HasPrefix(src, "//") {
+		src = "https:" + src
+	}
+	if !c.seen[src] {
+		c.seen[src] = true
+		c.images = append(c.images, src)
+	}
+	c.buf.WriteString(fmt.Sprintf("![%s](%s)", attr(n, "alt"), src))
+}
+
+func (c *converter) renderLink(n *html.Node) {
+	href := unwrapLink(attr(n, "href"))
+
+	// 视频卡片：<a class="video-box" data-lens-id="...">
+	if id := attr(n, "data-lens-id"); id != "" || hasClass(n, "video-box") {
+		if id == "" {
+			id = videoIDFromURL(href)
+		}
+		if id != "" {
+			video := Video{
+				ID:    id,
+				Title: attr(n, "data-name"),
+				URL:   "https://www.zhihu.com/video/" + id,
+			}
+			c.videos = append(c.videos, video)
+			title := video.Title
+			if title == "" {
+				title = "视频 " + id
+			}
+			c.blankLine()
+			c.buf.WriteString(fmt.Sprintf("[▶ %s](%s)", title, video.URL))
+			c.blankLine()
+			return
+		}
+	}
+
+	text := c.sub(n)
+	if text == "" {
+		text = href
+	}
+	if href == "" || strings.HasPrefix(href, "#") {
+		c.buf.WriteString(text)
+		return
+	}
+	c.buf.WriteString(fmt.Sprintf("[%s](%s)", text, href))
+}
+
+// unwrapLink 还原知乎外链跳转 https://link.zhihu.com/?target=...
+func unwrapLink(href string) string {
+	u, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	if u.Host == "link.zhihu.com" {
+		if target := u.Query().Get("target"); target != "" {
+			return target
+		}
+	}
+	if strings.HasPrefix(href, "//") {
+		return "https:" + href
+	}
+	return href
+}
+
+func videoIDFromURL(href string) string {
+	u, err := url.Parse(href)
+	if err != nil || !strings.HasSuffix(u.Hostname(), "zhihu.com") {
+		return ""
+	}
+	if id, ok := strings.CutPrefix(u.Path, "/video/"); ok {
+		return strings.Trim(id, "/")
+	}
+	return ""
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func firstAttr(n *html.Node, keys ...string) string {
+	for _, key := range keys {
+		if v := attr(n, key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func hasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(attr(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
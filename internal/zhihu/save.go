@@ -0,0 +1,172 @@
+package zhihu
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"zhihu-downloader/internal/atomicfile"
+	"zhihu-downloader/internal/downloader"
+)
+
+// SaveOptions 保存选项
+type SaveOptions struct {
+	OutputDir string
+	// Filename 输出文件名（不含扩展名），默认 zhihu_<类型>_<ID>
+	Filename string
+	// DownloadImages 为 true 时图片下载到 <文件名>_images 目录并改写为本地引用
+	DownloadImages bool
+}
+
+// Saved 保存结果
+type Saved struct {
+	MarkdownPath string
+	Title        string
+	// Images 图片地址；下载到本地时为本地文件路径
+	Images []string
+	Videos []Video
+}
+
+// DefaultFilename 默认文件名
+func (c *Content) DefaultFilename() string {
+	return fmt.Sprintf("zhihu_%s_%s", c.Type, c.ID)
+}
+
+// Save 把内容转换为 Markdown 写入 opts.OutputDir
+func Save(ctx context.Context, c *Content, opts SaveOptions) (*Saved, error) {
+	doc, err := ToMarkdown(c.HTML)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Filename == "" {
+		opts.Filename = c.DefaultFilename()
+	}
+	if err := downloader.ValidateFilename(opts.Filename); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(opts.OutputDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建输出目录失败: %v", err)
+	}
+
+	body := doc.Markdown
+	images := doc.Images
+	if opts.DownloadImages && len(doc.Images) > 0 {
+		dirName := opts.Filename + "_images"
+		local, err := downloadImages(ctx, doc.Images, filepath.Join(opts.OutputDir, dirName))
+		if err != nil {
+			return nil, err
+		}
+		images = make([]string, 0, len(doc.Images))
+		for _, src := range doc.Images {
+			name, ok := local[src]
+			if !ok {
+				images = append(images, src)
+				continue
+			}
+			// Markdown 中使用相对路径，方便整个目录移动
+			body = strings.ReplaceAll(body, "]("+src+")", "]("+dirName+"/"+name+")")
+			images = append(images, filepath.Join(opts.OutputDir, dirName, name))
+		}
+	}
+
+	mdPath := filepath.Join(opts.OutputDir, opts.Filename+".md")
+	if err := atomicfile.WriteFile(mdPath, []byte(header(c)+body), 0644); err != nil {
+		return nil, fmt.Errorf("写入 Markdown 失败: %v", err)
+	}
+
+	return &Saved{
+		MarkdownPath: mdPath,
+		Title:        c.Title,
+		Images:       images,
+		Videos:       doc.Videos,
+	}, nil
+}
+
+func header(c *Content) string {
+	var b strings.Builder
+	if c.Title != "" {
+		b.WriteString("# " + c.Title + "\n\n")
+	}
+
+	var meta []string
+	if c.Author != "" {
+		meta = append(meta, "作者："+c.Author)
+	}
+	if c.VoteUp > 0 {
+		meta = append(meta, fmt.Sprintf("赞同 %d", c.VoteUp))
+	}
+	if !c.Created.IsZero() {
+		meta = append(meta, c.Created.Format("2006-01-02"))
+	}
+	if len(meta) > 0 {
+		b.WriteString("> " + strings.Join(meta, " · ") + "  \n")
+	}
+	b.WriteString("> 原文：" + c.URL + "\n\n")
+	return b.String()
+}
+
+// downloadImages 按顺序下载图片，返回 原地址 -> 本地文件名
+func downloadImages(ctx context.Context, images []string, dir string) (map[string]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建图片目录失败: %v", err)
+	}
+
+	local := make(map[string]string, len(images))
+	for i, src := range images {
+		name := fmt.Sprintf("%03d%s", i+1, imageExt(src))
+		if err := downloadFile(ctx, src, filepath.Join(dir, name)); err != nil {
+			// 单张图片失败时保留远程引用
+			continue
+		}
+		local[src] = name
+	}
+	return local, nil
+}
+
+func imageExt(src string) string {
+	u, err := url.Parse(src)
+	if err != nil {
+		return ".jpg"
+	}
+	switch ext := strings.ToLower(path.Ext(u.Path)); ext {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp":
+		return ext
+	}
+	return ".jpg"
+}
+
+func downloadFile(ctx context.Context, src, dest string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return err
+	}
+	req.Header = downloader.HeadersFor(src)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	tmp := dest + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	f.Close()
+	return os.Rename(tmp, dest)
+}
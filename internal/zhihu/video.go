@@ -0,0 +1,488 @@
+package zhihu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"zhihu-downloader/internal/downloader"
+)
+
+// Rendition 一种清晰度的播放地址
+type Rendition struct {
+	Quality string  `json:"quality"`
+	Width   int     `json:"width,omitempty"`
+	Height  int     `json:"height,omitempty"`
+	Format  string  `json:"format"`
+	Size    int64   `json:"size,omitempty"`
+	Bitrate float64 `json:"bitrate,omitempty"`
+	URL     string  `json:"url"`
+}
+
+// Resolution 返回 "宽x高"，未知时为空
+func (r Rendition) Resolution() string {
+	if r.Width == 0 || r.Height == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%dx%d", r.Width, r.Height)
+}
+
+// VideoInfo 知乎视频信息及其可用清晰度
+type VideoInfo struct {
+	// ID Lens 视频 ID，直接从页面解析到播放地址时为空
+	ID    string `json:"id,omitempty"`
+	URL   string `json:"url"`
+	Title string `json:"title,omitempty"`
+	// Course 训练营课程名称
+	Course string `json:"course,omitempty"`
+	Author string `json:"author,omitempty"`
+	// Description 视频简介，只有 zvideo 有
+	Description string `json:"description,omitempty"`
+	Thumbnail   string `json:"thumbnail,omitempty"`
+	// Published 发布时间，未知时为空
+	Published *time.Time `json:"published,omitempty"`
+	// Duration 时长（秒）
+	Duration float64 `json:"duration,omitempty"`
+	// Qualities 可用清晰度，从高到低
+	Qualities []string `json:"qualities"`
+	// Renditions 按清晰度从高到低排列
+	Renditions []Rendition `json:"renditions"`
+}
+
+var (
+	zvideoRe = regexp.MustCompile(`zhihu\.com/zvideo/(\d+)`)
+	lensRe   = regexp.MustCompile(`(?:zhihu\.com/video|lens\.zhihu\.com/api(?:/v4)?/videos)/(\d+)`)
+	// 训练营页面直接嵌入的 MP4 地址，路径中带清晰度，例如 .../FHD/xxx.mp4?...
+	vzuuRe       = regexp.MustCompile(`https://vdn[0-9]*\.vzuu\.com/[^"'<>\s\\]+\.mp4\?[^"'<>\s\\]+`)
+	vzuuQuality  = regexp.MustCompile(`/(UHD|FHD|HD|SD|LD)/`)
+	pageTitleRe  = regexp.MustCompile(`(?s)"videoInfo"\s*:\s*\{.*?"title"\s*:\s*"([^"]+)"`)
+	pageCourseRe = regexp.MustCompile(`(?s)"course"\s*:\s*\{[^}]*"title"\s*:\s*"([^"]+)"`)
+	pageCoverRe  = regexp.MustCompile(`"(?:cover_url|coverUrl|thumbnail)"\s*:\s*"(https?://[^"]+)"`)
+	pageVideoRe  = []*regexp.Regexp{
+		regexp.MustCompile(`(?s)"resource"\s*:\s*\{[^}]*"data"\s*:\s*\{[^}]*"id"\s*:\s*"([a-zA-Z0-9_-]{20,})"`),
+		regexp.MustCompile(`"video_id"\s*:\s*"(\d{10,})"`),
+	}
+)
+
+// IsVideoURL 判断是否为知乎视频页面（zvideo、视频播放页或训练营视频）
+func IsVideoURL(raw string) bool {
+	return zvideoRe.MatchString(raw) || lensRe.MatchString(raw) || strings.Contains(raw, "/training-video/")
+}
+
+// VideoKey 返回知乎视频、回答或文章链接的唯一标识，例如 zvideo:123、video:123、answer:123、article:123。
+// 同一内容的不同链接（带分享参数、问题下的回答链接等）得到相同的标识，无法识别时返回空字符串
+func VideoKey(raw string) string {
+	if m := zvideoRe.FindStringSubmatch(raw); m != nil {
+		return "zvideo:" + m[1]
+	}
+	if m := lensRe.FindStringSubmatch(raw); m != nil {
+		return "video:" + m[1]
+	}
+	if t, id, err := ParseURL(raw); err == nil {
+		return string(t) + ":" + id
+	}
+	return ""
+}
+
+// FetchVideo 获取视频信息和各清晰度的播放地址。rawURL 先经过 ResolveLink 规范化，
+// 回答和文章下载正文中嵌入的第一个视频
+func FetchVideo(ctx context.Context, rawURL string) (*VideoInfo, error) {
+	link, err := ResolveLink(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	var video *VideoInfo
+	switch link.Type {
+	case LinkZVideo:
+		video, err = fetchZVideo(ctx, link.ID)
+	case LinkVideo:
+		video, err = fetchLens(ctx, link.ID)
+	case LinkAnswer, LinkArticle:
+		video, err = fetchEmbeddedVideo(ctx, link)
+	case LinkExternal:
+		return nil, fmt.Errorf("不是知乎链接: %s", link.URL)
+	default:
+		video, err = fetchVideoPage(ctx, link.URL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(video.Renditions) == 0 {
+		return nil, fmt.Errorf("没有可用的播放地址，可能需要登录或购买")
+	}
+
+	video.URL = link.URL
+	sort.SliceStable(video.Renditions, func(i, j int) bool {
+		return downloader.QualityRank(video.Renditions[i].Quality) < downloader.QualityRank(video.Renditions[j].Quality)
+	})
+	video.Qualities = make([]string, 0, len(video.Renditions))
+	for _, r := range video.Renditions {
+		video.Qualities = append(video.Qualities, r.Quality)
+	}
+	return video, nil
+}
+
+// Select 按请求的清晰度选择播放地址：有对应清晰度时直接使用，
+// 否则选择不高于请求的最高清晰度，都比请求高时选择最低的
+func (v *VideoInfo) Select(quality string) (*Rendition, error) {
+	if len(v.Renditions) == 0 {
+		return nil, fmt.Errorf("没有可用的播放地址")
+	}
+	quality, err := downloader.NormalizeQuality(quality)
+	if err != nil {
+		return nil, err
+	}
+	if quality == "" || quality == downloader.QualityBest {
+		return &v.Renditions[0], nil
+	}
+
+	want := downloader.QualityRank(quality)
+	for i := range v.Renditions {
+		if downloader.QualityRank(v.Renditions[i].Quality) >= want {
+			return &v.Renditions[i], nil
+		}
+	}
+	return &v.Renditions[len(v.Renditions)-1], nil
+}
+
+// ResolveStream 实现 downloader.Resolver：解析页面并选择清晰度。
+// 训练营视频的标题为 "课程名-章节名"
+func ResolveStream(ctx context.Context, pageURL, quality string) (*downloader.Stream, error) {
+	video, err := FetchVideo(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	r, err := video.Select(quality)
+	if err != nil {
+		return nil, err
+	}
+	title := video.Title
+	if video.Course != "" && title != "" {
+		title = video.Course + "-" + title
+	}
+	size := r.Size
+	if size <= 0 && r.Bitrate > 0 {
+		// Lens API 的码率单位为 kbps
+		size = int64(r.Bitrate * 1000 / 8 * video.Duration)
+	}
+	return &downloader.Stream{
+		URL:        r.URL,
+		Quality:    r.Quality,
+		Resolution: r.Resolution(),
+		Title:      title,
+		Author:     video.Author,
+		Size:       size,
+
+		Description: video.Description,
+		Published:   video.Published,
+	}, nil
+}
+
+// lensVideo Lens API 返回的视频信息
+type lensVideo struct {
+	Title      string                  `json:"title"`
+	Duration   float64                 `json:"duration"`
+	CoverURL   string                  `json:"cover_url"`
+	Thumbnail  string                  `json:"thumbnail"`
+	Playlist   map[string]lensPlayItem `json:"playlist"`
+	PlaylistV2 map[string]lensPlayItem `json:"playlist_v2"`
+}
+
+type lensPlayItem struct {
+	PlayURL string  `json:"play_url"`
+	URL     string  `json:"url"`
+	Format  string  `json:"format"`
+	Width   int     `json:"width"`
+	Height  int     `json:"height"`
+	Size    int64   `json:"size"`
+	Bitrate float64 `json:"bitrate"`
+}
+
+func (v *lensVideo) toVideo(id string) *VideoInfo {
+	video := &VideoInfo{ID: id, Title: v.Title, Duration: v.Duration, Thumbnail: v.CoverURL}
+	if video.Thumbnail == "" {
+		video.Thumbnail = v.Thumbnail
+	}
+	playlist := v.Playlist
+	if len(playlist) == 0 {
+		playlist = v.PlaylistV2
+	}
+	for key, item := range playlist {
+		playURL := item.PlayURL
+		if playURL == "" {
+			playURL = item.URL
+		}
+		if playURL == "" {
+			continue
+		}
+		quality := strings.ToLower(key)
+		if downloader.QualityRank(quality) == len(downloader.Qualities) {
+			quality = qualityForHeight(item.Height)
+		}
+		format := item.Format
+		if format == "" {
+			format = "mp4"
+			if strings.Contains(playURL, ".m3u8") {
+				format = "m3u8"
+			}
+		}
+		video.Renditions = append(video.Renditions, Rendition{
+			Quality: quality,
+			Width:   item.Width,
+			Height:  item.Height,
+			Format:  format,
+			Size:    item.Size,
+			Bitrate: item.Bitrate,
+			URL:     playURL,
+		})
+	}
+	return video
+}
+
+// flexString 兼容字符串和数字两种写法的 ID
+type flexString string
+
+func (s *flexString) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		*s = flexString(str)
+		return nil
+	}
+	var num json.Number
+	if err := json.Unmarshal(data, &num); err != nil {
+		return err
+	}
+	*s = flexString(num.String())
+	return nil
+}
+
+// qualityForHeight 按高度推断清晰度
+func qualityForHeight(height int) string {
+	switch {
+	case height >= 2160:
+		return downloader.QualityUHD
+	case height >= 1080:
+		return downloader.QualityFHD
+	case height >= 720:
+		return downloader.QualityHD
+	case height >= 480:
+		return downloader.QualitySD
+	default:
+		return downloader.QualityLD
+	}
+}
+
+func fetchLens(ctx context.Context, id string) (*VideoInfo, error) {
+	body, err := get(ctx, "https://lens.zhihu.com/api/v4/videos/"+url.PathEscape(id))
+	if err != nil {
+		return nil, err
+	}
+	var v lensVideo
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil, fmt.Errorf("解析视频信息失败: %v", err)
+	}
+	return v.toVideo(id), nil
+}
+
+// fetchZVideo 获取 zvideo 信息。zvideo ID 与 Lens ID 不同，先查询 zvideo API，失败时按 Lens ID 尝试
+func fetchZVideo(ctx context.Context, id string) (*VideoInfo, error) {
+	body, err := get(ctx, "https://www.zhihu.com/api/v4/zvideos/"+id)
+	if err != nil {
+		return fetchLens(ctx, id)
+	}
+
+	var data struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Author      person `json:"author"`
+		ImageURL    string `json:"image_url"`
+		PublishedAt int64  `json:"published_at"`
+		CreatedAt   int64  `json:"created_at"`
+		Video       struct {
+			lensVideo
+			VideoID flexString `json:"video_id"`
+		} `json:"video"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("解析视频信息失败: %v", err)
+	}
+
+	lensID := string(data.Video.VideoID)
+	video := data.Video.toVideo(lensID)
+	if len(video.Renditions) == 0 && lensID != "" {
+		if video, err = fetchLens(ctx, lensID); err != nil {
+			return nil, err
+		}
+	}
+	if data.Title != "" {
+		video.Title = data.Title
+	}
+	video.Author = data.Author.Name
+	video.Description = strings.TrimSpace(data.Description)
+	if data.ImageURL != "" {
+		video.Thumbnail = data.ImageURL
+	}
+	if ts := max(data.PublishedAt, data.CreatedAt); ts > 0 {
+		published := time.Unix(ts, 0)
+		video.Published = &published
+	}
+	return video, nil
+}
+
+// fetchEmbeddedVideo 获取回答或文章正文中嵌入的第一个视频，标题为视频标题，没有时使用回答或文章的标题
+func fetchEmbeddedVideo(ctx context.Context, link *Link) (*VideoInfo, error) {
+	content, err := Fetch(ctx, link.URL)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := ToMarkdown(content.HTML)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range doc.Videos {
+		if v.ID == "" {
+			continue
+		}
+		video, err := fetchLens(ctx, v.ID)
+		if err != nil {
+			return nil, err
+		}
+		video.Title = v.Title
+		if video.Title == "" {
+			video.Title = content.Title
+		}
+		video.Author = content.Author
+		if !content.Created.IsZero() {
+			published := content.Created
+			video.Published = &published
+		}
+		return video, nil
+	}
+	kind := "回答"
+	if link.Type == LinkArticle {
+		kind = "文章"
+	}
+	return nil, fmt.Errorf("这篇%s中没有视频（保存图文内容请使用 download_answer）: %s", kind, link.URL)
+}
+
+// fetchVideoPage 解析训练营等页面：优先使用页面嵌入的 MP4 地址，其次查找 Lens 视频 ID
+func fetchVideoPage(ctx context.Context, pageURL string) (*VideoInfo, error) {
+	body, err := get(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	page := html.UnescapeString(string(body))
+	// 页面 JSON 中的地址是转义过的
+	page = strings.NewReplacer(`\u002F`, "/", `\/`, "/", `\u0026`, "&").Replace(page)
+
+	video, err := findPageVideo(ctx, pageURL, page)
+	if err != nil {
+		return nil, err
+	}
+	if m := pageTitleRe.FindStringSubmatch(page); m != nil && video.Title == "" {
+		video.Title = m[1]
+	}
+	if m := pageCourseRe.FindStringSubmatch(page); m != nil {
+		video.Course = m[1]
+	}
+	if m := pageCoverRe.FindStringSubmatch(page); m != nil && video.Thumbnail == "" {
+		video.Thumbnail = m[1]
+	}
+	return video, nil
+}
+
+func findPageVideo(ctx context.Context, pageURL, page string) (*VideoInfo, error) {
+	video := &VideoInfo{}
+	seen := map[string]bool{}
+	for _, u := range vzuuRe.FindAllString(page, -1) {
+		m := vzuuQuality.FindStringSubmatch(u)
+		if m == nil {
+			continue
+		}
+		quality := strings.ToLower(m[1])
+		if seen[quality] {
+			continue
+		}
+		seen[quality] = true
+		video.Renditions = append(video.Renditions, Rendition{
+			Quality: quality,
+			Width:   qualityWidths[quality],
+			Height:  qualityHeights[quality],
+			Format:  "mp4",
+			URL:     u,
+		})
+	}
+	if len(video.Renditions) > 0 {
+		return video, nil
+	}
+
+	if strings.Contains(pageURL, "/training-video/") {
+		if video, err := fetchTrainingSection(ctx, pageURL); err == nil {
+			return video, nil
+		}
+	}
+
+	for _, re := range pageVideoRe {
+		if m := re.FindStringSubmatch(page); m != nil {
+			return fetchLens(ctx, m[1])
+		}
+	}
+	return nil, fmt.Errorf("页面中未找到视频，可能需要登录或购买")
+}
+
+// 页面嵌入的 MP4 只能从路径得知清晰度，分辨率按常见值填写
+var (
+	qualityWidths  = map[string]int{"uhd": 3840, "fhd": 1920, "hd": 1280, "sd": 854, "ld": 640}
+	qualityHeights = map[string]int{"uhd": 2160, "fhd": 1080, "hd": 720, "sd": 480, "ld": 360}
+)
+
+// fetchTrainingSection 通过训练营章节 API 获取 Lens 视频 ID
+func fetchTrainingSection(ctx context.Context, pageURL string) (*VideoInfo, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	sectionID := u.Path[strings.LastIndex(strings.TrimRight(u.Path, "/"), "/")+1:]
+	sectionID = strings.TrimRight(sectionID, "/")
+
+	endpoints := []string{
+		"https://www.zhihu.com/api/infinity/training/section/" + sectionID,
+		"https://www.zhihu.com/api/v4/market/training/section/" + sectionID,
+	}
+	var lastErr error
+	for _, endpoint := range endpoints {
+		body, err := get(ctx, endpoint)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		var data struct {
+			Title    string `json:"title"`
+			Resource struct {
+				Type string `json:"type"`
+				Data struct {
+					ID string `json:"id"`
+				} `json:"data"`
+			} `json:"resource"`
+		}
+		if err := json.Unmarshal(body, &data); err != nil || data.Resource.Type != "video" || data.Resource.Data.ID == "" {
+			lastErr = fmt.Errorf("章节不是视频")
+			continue
+		}
+		video, err := fetchLens(ctx, data.Resource.Data.ID)
+		if err != nil {
+			return nil, err
+		}
+		if data.Title != "" {
+			video.Title = data.Title
+		}
+		return video, nil
+	}
+	return nil, lastErr
+}
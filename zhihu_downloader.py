@@ -84,16 +84,19 @@ class ZhihuVideoDownloader:
         "Origin": "https://www.zhihu.com",
     }
     
-    def __init__(self, use_chrome_cookies: bool = True, cookie_file: str = None):
+    def __init__(self, use_chrome_cookies: bool = True, cookie_file: str = None, headers: dict = None):
         """
         初始化下载器
         
         Args:
             use_chrome_cookies: 是否使用 Chrome 的 cookies 进行鉴权
             cookie_file: 手动提供的 cookies 文件路径 (JSON 格式)
+            headers: 覆盖默认请求头 (User-Agent、Referer 等)
         """
         self.session = requests.Session()
         self.session.headers.update(self.HEADERS)
+        if headers:
+            self.session.headers.update(headers)
         
         if cookie_file:
             self._load_cookies_from_file(cookie_file)
@@ -458,7 +461,7 @@ class ZhihuVideoDownloader:
         # 添加 headers 以模拟浏览器请求
         cmd = [
             ffmpeg_path,
-            "-headers", f"User-Agent: {self.HEADERS['User-Agent']}\r\nReferer: https://www.zhihu.com/\r\n",
+            "-headers", f"User-Agent: {self.session.headers['User-Agent']}\r\nReferer: {self.session.headers['Referer']}\r\n",
             "-i", m3u8_url,
             "-c", "copy",  # 直接复制流，不重新编码
             "-bsf:a", "aac_adtstoasc",  # 处理 AAC 音频
@@ -794,16 +797,28 @@ def main():
         action="store_true",
         help="不使用任何 cookies (仅能下载免费公开视频)"
     )
+    parser.add_argument(
+        "-H", "--header",
+        action="append",
+        default=[],
+        help="附加或覆盖请求头，格式为 \"名称: 值\"，可以重复 (例如 User-Agent、Referer)"
+    )
     
     args = parser.parse_args()
     
+    headers = {}
+    for line in args.header:
+        name, sep, value = line.partition(":")
+        if sep and name.strip():
+            headers[name.strip()] = value.strip()
+    
     # 创建下载器
     if args.no_cookies:
-        downloader = ZhihuVideoDownloader(use_chrome_cookies=False)
+        downloader = ZhihuVideoDownloader(use_chrome_cookies=False, headers=headers)
     elif args.cookies:
-        downloader = ZhihuVideoDownloader(use_chrome_cookies=False, cookie_file=args.cookies)
+        downloader = ZhihuVideoDownloader(use_chrome_cookies=False, cookie_file=args.cookies, headers=headers)
     else:
-        downloader = ZhihuVideoDownloader(use_chrome_cookies=True)
+        downloader = ZhihuVideoDownloader(use_chrome_cookies=True, headers=headers)
     
     # 下载视频
     def progress_callback(progress):